@@ -0,0 +1,245 @@
+package apiimpl
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/auth"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/google/uuid"
+)
+
+// maxAuditBodySize json body larger than this is not read for audit targets
+const maxAuditBodySize = 64 * 1024
+
+// auditOperation action recorded for an operation, bodyFields are fields of json body copied into targets, fields
+// carrying secrets like password must never be listed
+type auditOperation struct {
+	action     string
+	bodyFields []string
+}
+
+// auditOperations mutating operations recorded in audit log by operation id, operations not listed here such as login
+// and uploading a single part of upload session are not recorded
+var auditOperations = map[string]auditOperation{
+	"createRepository":       {action: "repo.create", bodyFields: []string{"name"}},
+	"updateRepository":       {action: "repo.update", bodyFields: []string{"name", "head"}},
+	"deleteRepository":       {action: "repo.delete"},
+	"restoreRepository":      {action: "repo.restore"},
+	"purgeRepository":        {action: "repo.purge"},
+	"forkRepository":         {action: "repo.fork", bodyFields: []string{"name"}},
+	"changeVisible":          {action: "repo.visible"},
+	"archiveRepository":      {action: "repo.archive"},
+	"unarchiveRepository":    {action: "repo.unarchive"},
+	"updateRepositoryLimits": {action: "repo.limits"},
+	"migrateHashType":        {action: "repo.hash_migration"},
+	"runGarbageCollection":   {action: "repo.gc"},
+	"packObjects":            {action: "repo.pack"},
+	"checkConsistency":       {action: "repo.consistency"},
+	"rollbackRepository":     {action: "repo.rollback", bodyFields: []string{"time", "backup_prefix"}},
+	"getStorageCredentials":  {action: "repo.credentials"},
+
+	"createBranch":           {action: "ref.create", bodyFields: []string{"name", "source"}},
+	"deleteBranch":           {action: "ref.delete"},
+	"updateBranchProtection": {action: "ref.protect", bodyFields: []string{"is_protected", "allow_force_push", "required_approvals"}},
+	"createTag":              {action: "ref.create", bodyFields: []string{"name", "target"}},
+	"deleteTag":              {action: "ref.delete"},
+
+	"commitWip":               {action: "commit.create"},
+	"createCommitAttestation": {action: "commit.attest", bodyFields: []string{"name", "status"}},
+	"updateWip":               {action: "wip.update"},
+	"deleteWip":               {action: "wip.delete"},
+	"revertWipChanges":        {action: "wip.revert"},
+	"stageWipChanges":         {action: "wip.stage"},
+	"unstageWipChanges":       {action: "wip.unstage"},
+	"resetWip":                {action: "wip.reset"},
+
+	"uploadObject":          {action: "object.upload"},
+	"deleteObject":          {action: "object.delete"},
+	"batchUploadObject":     {action: "object.batch_upload"},
+	"batchDeleteObject":     {action: "object.batch_delete"},
+	"applyPathOperations":   {action: "object.path_operations"},
+	"createUploadSession":   {action: "upload.create"},
+	"completeUploadSession": {action: "upload.complete"},
+	"abortUploadSession":    {action: "upload.abort"},
+
+	"createMergeRequest": {action: "merge_request.create", bodyFields: []string{"source_branch_name", "target_branch_name"}},
+	"updateMergeRequest": {action: "merge_request.update"},
+	"merge":              {action: "merge_request.merge"},
+	"enableAutoMerge":    {action: "merge_request.enable_auto_merge"},
+	"disableAutoMerge":   {action: "merge_request.disable_auto_merge"},
+	"closeMergeRequest":  {action: "merge_request.close"},
+	"reopenMergeRequest": {action: "merge_request.reopen"},
+
+	"approveMergeRequest":        {action: "merge_request.approve"},
+	"revokeMergeRequestApproval": {action: "merge_request.revoke_approval"},
+	"checkMergeRequestItem":      {action: "merge_request.check_item", bodyFields: []string{"title", "checked"}},
+	"createReviewComment":        {action: "merge_request.comment", bodyFields: []string{"parent_id"}},
+	"updateReviewComment":        {action: "merge_request.update_comment", bodyFields: []string{"resolved"}},
+	"deleteReviewComment":        {action: "merge_request.delete_comment"},
+	"createMergeRequestCheck":    {action: "merge_request.check", bodyFields: []string{"name", "status"}},
+
+	"createShare":       {action: "share.create", bodyFields: []string{"ref_type", "ref_name"}},
+	"deleteShare":       {action: "share.delete"},
+	"inviteMember":      {action: "member.invite"},
+	"updateMemberGroup": {action: "member.update"},
+	"revokeMember":      {action: "member.revoke"},
+
+	"register":   {action: "user.register", bodyFields: []string{"name"}},
+	"createAksk": {action: "aksk.create"},
+	"deleteAksk": {action: "aksk.delete"},
+}
+
+// AuditLog record mutating operations which succeed. repository is resolved before request is handled so operations
+// deleting or renaming repository are recorded with the repository they operated. failing to record is logged only, it
+// never fails the request
+func AuditLog(swagger *openapi3.T, repo models.IRepo) func(next http.Handler) http.Handler {
+	router, err := gorillamux.NewRouter(swagger)
+	if err != nil {
+		panic(err)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				next.ServeHTTP(w, r)
+				return
+			}
+			route, pathParams, err := router.FindRoute(r)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			operation, ok := auditOperations[route.Operation.OperationID]
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			targets := make(map[string]string)
+			for name, value := range pathParams {
+				targets[name] = value
+			}
+			for name, values := range r.URL.Query() {
+				if len(values) > 0 {
+					targets[name] = values[0]
+				}
+			}
+			readBodyFields(r, operation.bodyFields, targets)
+
+			repositoryID := uuid.Nil
+			if repository, err := findRepository(r, repo, pathParams["owner"], pathParams["repository"]); err == nil {
+				repositoryID = repository.ID
+			}
+
+			recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(recorder, r)
+			if recorder.status >= http.StatusBadRequest {
+				return
+			}
+
+			actorID := uuid.Nil
+			if operator, err := auth.GetOperator(ctx); err == nil {
+				actorID = operator.ID
+				if operation.action == "repo.create" {
+					// repository exists only after it is created, it is owned by operator
+					if repository, err := repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(targets["name"]).SetOwnerID(operator.ID)); err == nil {
+						repositoryID = repository.ID
+					}
+				}
+			}
+
+			_, err = repo.AuditLogRepo().Insert(ctx, &models.AuditLog{
+				ActorID:      actorID,
+				Action:       operation.action,
+				RepositoryID: repositoryID,
+				Targets:      targets,
+				RemoteAddr:   remoteIP(r.RemoteAddr),
+				CreatedAt:    time.Now(),
+			})
+			if err != nil {
+				log.Errorf("record audit log of %s %v", operation.action, err)
+			}
+		})
+	}
+}
+
+func findRepository(r *http.Request, repo models.IRepo, ownerName, repositoryName string) (*models.Repository, error) {
+	if len(ownerName) == 0 || len(repositoryName) == 0 {
+		return nil, models.ErrNotFound
+	}
+	owner, err := repo.UserRepo().Get(r.Context(), models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		return nil, err
+	}
+	return repo.RepositoryRepo().Get(r.Context(), models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+}
+
+// readBodyFields copy string fields of json body into targets, body is restored for the handler
+func readBodyFields(r *http.Request, fields []string, targets map[string]string) {
+	if len(fields) == 0 || r.Body == nil || r.ContentLength > maxAuditBodySize {
+		return
+	}
+	mediaType, _, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/json" {
+		return
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r.Body, maxAuditBodySize+1))
+	r.Body = io.NopCloser(io.MultiReader(bytes.NewReader(data), r.Body))
+	if err != nil || len(data) > maxAuditBodySize {
+		return
+	}
+
+	body := make(map[string]interface{})
+	if json.Unmarshal(data, &body) != nil {
+		return
+	}
+	for _, field := range fields {
+		if value, ok := body[field].(string); ok {
+			targets[field] = value
+		}
+	}
+}
+
+func remoteIP(remoteAddr string) string {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return remoteAddr
+	}
+	return host
+}
+
+// statusRecorder remember status code written by handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (recorder *statusRecorder) WriteHeader(code int) {
+	if !recorder.wroteHeader {
+		recorder.status = code
+		recorder.wroteHeader = true
+	}
+	recorder.ResponseWriter.WriteHeader(code)
+}
+
+func (recorder *statusRecorder) Write(data []byte) (int, error) {
+	recorder.wroteHeader = true
+	return recorder.ResponseWriter.Write(data)
+}
+
+func (recorder *statusRecorder) Flush() {
+	if flusher, ok := recorder.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,99 @@
+package apiimpl
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+// ConsistencyTokenHeader carry consistency token returned by successful writes, clients present it on following
+// reads to see their own writes when reads are served by replica
+const ConsistencyTokenHeader = "X-Consistency-Token"
+
+// extensionReplicaRead mark operations which never write, only they read from replica. GET operations writing what
+// they read, such as creating wip or logging share access, are left unmarked so their reads are not stale
+const extensionReplicaRead = "x-replica-read"
+
+// ConsistencyToken let read only operations read from replica of db and return consistency token on others, it does
+// nothing if no replica is configured
+func ConsistencyToken(swagger *openapi3.T, db *models.ReplicaDB) func(next http.Handler) http.Handler {
+	router, err := gorillamux.NewRouter(swagger)
+	if err != nil {
+		panic(err)
+	}
+
+	return func(next http.Handler) http.Handler {
+		if db == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if isReplicaRead(router, r) {
+				var token models.ConsistencyToken
+				if value := r.Header.Get(ConsistencyTokenHeader); len(value) > 0 {
+					var err error
+					token, err = models.ParseConsistencyToken(value)
+					if err != nil {
+						http.Error(w, err.Error(), http.StatusBadRequest)
+						return
+					}
+				}
+				next.ServeHTTP(w, r.WithContext(models.WithReplicaRead(r.Context(), token)))
+				return
+			}
+
+			next.ServeHTTP(&tokenWriter{ResponseWriter: w, ctx: r.Context(), db: db}, r)
+		})
+	}
+}
+
+func isReplicaRead(router routers.Router, r *http.Request) bool {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		return false
+	}
+	route, _, err := router.FindRoute(r)
+	if err != nil {
+		return false
+	}
+	_, ok := route.Operation.Extensions[extensionReplicaRead]
+	return ok
+}
+
+// tokenWriter set consistency token header before header of successful response is written
+type tokenWriter struct {
+	http.ResponseWriter
+	ctx         context.Context
+	db          *models.ReplicaDB
+	wroteHeader bool
+}
+
+func (writer *tokenWriter) WriteHeader(code int) {
+	if !writer.wroteHeader {
+		writer.wroteHeader = true
+		if code < http.StatusBadRequest {
+			token, err := writer.db.ConsistencyToken(writer.ctx)
+			if err != nil {
+				log.Warnf("get consistency token %v", err)
+			} else {
+				writer.Header().Set(ConsistencyTokenHeader, token.String())
+			}
+		}
+	}
+	writer.ResponseWriter.WriteHeader(code)
+}
+
+func (writer *tokenWriter) Write(data []byte) (int, error) {
+	if !writer.wroteHeader {
+		writer.WriteHeader(http.StatusOK)
+	}
+	return writer.ResponseWriter.Write(data)
+}
+
+func (writer *tokenWriter) Flush() {
+	if flusher, ok := writer.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
@@ -0,0 +1,39 @@
+package apiimpl
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsReplicaRead(t *testing.T) {
+	swagger, err := api.GetSwagger()
+	require.NoError(t, err)
+	router, err := gorillamux.NewRouter(swagger)
+	require.NoError(t, err)
+
+	cases := []struct {
+		method  string
+		path    string
+		replica bool
+	}{
+		{method: http.MethodGet, path: "/repos/jimmy/happyrun", replica: true},
+		{method: http.MethodGet, path: "/object/jimmy/happyrun?refName=main&path=a.txt", replica: true},
+		{method: http.MethodHead, path: "/object/jimmy/happyrun?refName=main&path=a.txt", replica: true},
+		{method: http.MethodGet, path: "/repos/jimmy/happyrun/branches", replica: true},
+		// create wip if not exist
+		{method: http.MethodGet, path: "/wip/jimmy/happyrun?refName=main", replica: false},
+		// log share access
+		{method: http.MethodGet, path: "/shares/token/object?path=a.txt", replica: false},
+		{method: http.MethodPost, path: "/object/jimmy/happyrun?refName=main&path=a.txt", replica: false},
+		{method: http.MethodGet, path: "/not/exist", replica: false},
+	}
+	for _, c := range cases {
+		req := httptest.NewRequest(c.method, APIV1Prefix+c.path, nil)
+		require.Equal(t, c.replica, isReplicaRead(router, req), "%s %s", c.method, c.path)
+	}
+}
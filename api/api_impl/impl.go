@@ -24,4 +24,9 @@ type APIController struct {
 	controller.GroupController
 	controller.MemberController
 	controller.TagController
+	controller.ShareController
+	controller.EventController
+	controller.UploadSessionController
+	controller.AuditLogController
+	controller.DiagnosticsController
 }
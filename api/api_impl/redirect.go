@@ -0,0 +1,121 @@
+package apiimpl
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/GitDataAI/jiaozifs/auth"
+	"github.com/GitDataAI/jiaozifs/auth/rbac"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/models/rbacmodel"
+	"github.com/getkin/kin-openapi/openapi3"
+	"github.com/getkin/kin-openapi/routers/gorillamux"
+)
+
+// RepositoryAliasRedirect redirect requests addressing repository by an old name to the current name of repository.
+// GET and HEAD are answered with 301, other methods with 308 so that method and body are kept. only operators who can
+// read the repository are redirected, others fall through to handler so the new name of a private repository is not
+// revealed
+func RepositoryAliasRedirect(swagger *openapi3.T, repo models.IRepo, permissionCheck rbac.PermissionCheck) func(next http.Handler) http.Handler {
+	router, err := gorillamux.NewRouter(swagger)
+	if err != nil {
+		panic(err)
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, pathParams, err := router.FindRoute(r)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			ownerName, repositoryName := pathParams["owner"], pathParams["repository"]
+			if len(ownerName) == 0 || len(repositoryName) == 0 {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			ctx := r.Context()
+			owner, err := repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			_, err = repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+			if !errors.Is(err, models.ErrNotFound) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			alias, err := repo.RepositoryRepo().GetAlias(ctx, owner.ID, repositoryName)
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			repository, err := repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetID(alias.RepositoryID))
+			if err != nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if !canReadRepository(ctx, permissionCheck, repository) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			location, ok := replaceRepositoryInPath(r.URL.EscapedPath(), ownerName, repositoryName, repository.Name)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if len(r.URL.RawQuery) > 0 {
+				location += "?" + r.URL.RawQuery
+			}
+
+			code := http.StatusPermanentRedirect
+			if r.Method == http.MethodGet || r.Method == http.MethodHead {
+				code = http.StatusMovedPermanently
+			}
+			http.Redirect(w, r, location, code)
+		})
+	}
+}
+
+// canReadRepository check whether operator of request may read repository
+func canReadRepository(ctx context.Context, permissionCheck rbac.PermissionCheck, repository *models.Repository) bool {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		return false
+	}
+	resp, err := permissionCheck.AuthorizeMember(ctx, repository.ID, &rbac.AuthorizationRequest{
+		OperatorID: operator.ID,
+		RequiredPermissions: rbac.Node{
+			Permission: rbac.Permission{
+				Action:   rbacmodel.ReadRepositoryAction,
+				Resource: rbacmodel.RepoURArn(repository.OwnerID.String(), repository.ID.String()),
+			},
+		},
+	})
+	return err == nil && resp.Allowed
+}
+
+// replaceRepositoryInPath replace repository segment following owner segment in escaped url path
+func replaceRepositoryInPath(escapedPath, ownerName, oldName, newName string) (string, bool) {
+	segments := strings.Split(escapedPath, "/")
+	for i := 0; i+1 < len(segments); i++ {
+		owner, err := url.PathUnescape(segments[i])
+		if err != nil || owner != ownerName {
+			continue
+		}
+		name, err := url.PathUnescape(segments[i+1])
+		if err != nil || name != oldName {
+			continue
+		}
+		segments[i+1] = url.PathEscape(newName)
+		return strings.Join(segments, "/"), true
+	}
+	return "", false
+}
@@ -8,6 +8,7 @@ import (
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"github.com/GitDataAI/jiaozifs/auth/aksk"
 
@@ -19,8 +20,12 @@ import (
 	"github.com/GitDataAI/jiaozifs/api"
 	"github.com/GitDataAI/jiaozifs/auth"
 	"github.com/GitDataAI/jiaozifs/auth/crypt"
+	"github.com/GitDataAI/jiaozifs/auth/rbac"
 	"github.com/GitDataAI/jiaozifs/config"
 	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils/compress"
+	"github.com/GitDataAI/jiaozifs/utils/i18n"
+	"github.com/GitDataAI/jiaozifs/version"
 	"github.com/MadAppGang/httplog"
 	"github.com/flowchartsman/swaggerui"
 	"github.com/getkin/kin-openapi/openapi3filter"
@@ -28,8 +33,12 @@ import (
 	"github.com/go-chi/chi/v5"
 	"github.com/gorilla/sessions"
 	logging "github.com/ipfs/go-log/v2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"github.com/rs/cors"
 	"go.uber.org/fx"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
 )
 
 var log = logging.Logger("rpc")
@@ -46,6 +55,9 @@ func SetupAPI(lc fx.Lifecycle,
 	sessionStore sessions.Store,
 	repo models.IRepo,
 	verifier aksk.Verifier,
+	poolMonitor models.IPoolMonitor,
+	replicaDB *models.ReplicaDB,
+	permissionCheck rbac.PermissionCheck,
 	controller APIController) error {
 	swagger, err := api.GetSwagger()
 	if err != nil {
@@ -68,6 +80,8 @@ func SetupAPI(lc fx.Lifecycle,
 			AllowedHeaders:   []string{"*"},
 			AllowCredentials: true,
 		}).Handler,
+		i18n.Middleware,
+		compress.Middleware(apiConfig.MaxDecodedBodySize),
 	)
 	// Use our validation middleware to check all requests against the
 	// OpenAPI schema.
@@ -76,6 +90,9 @@ func SetupAPI(lc fx.Lifecycle,
 			AuthenticationFunc: openapi3filter.NoopAuthenticationFunc,
 		}),
 		auth.Middleware(swagger, authenticator, secretStore, repo.UserRepo(), repo.AkskRepo(), sessionStore, verifier),
+		RepositoryAliasRedirect(swagger, repo, permissionCheck),
+		AuditLog(swagger, repo),
+		ConsistencyToken(swagger, replicaDB),
 	)
 
 	raw, err := api.RawSpec()
@@ -90,6 +107,24 @@ func SetupAPI(lc fx.Lifecycle,
 		Version: "v1.0",
 	}))
 	r.Get("/status", h.HandlerFunc)
+	healthz, err := health.New(health.WithComponent(health.Component{
+		Name:    "jiaozifs",
+		Version: version.UserVersion(),
+	}), health.WithChecks(health.Config{
+		Name:    "database",
+		Timeout: models.DefaultPingTimeout,
+		Check:   poolMonitor.Ping,
+	}))
+	if err != nil {
+		return err
+	}
+	r.Get("/healthz", healthz.HandlerFunc)
+
+	err = prometheus.Register(models.NewPoolCollector(poolMonitor))
+	if err != nil && !errors.As(err, &prometheus.AlreadyRegisteredError{}) {
+		return err
+	}
+	r.Handle("/metrics", promhttp.Handler())
 
 	url, err := url.Parse(apiConfig.Listen)
 	if err != nil {
@@ -100,22 +135,58 @@ func SetupAPI(lc fx.Lifecycle,
 	if err != nil {
 		return err
 	}
+	server := newHTTPServer(r, apiConfig.HTTP)
 	log.Infof("Start listen api %s", listener.Addr())
 	go func() {
-		err := http.Serve(listener, r)
+		err := server.Serve(listener)
 		if err != nil && !errors.Is(err, http.ErrServerClosed) {
 			log.Errorf("listen address fail %s", err)
 		}
 	}()
 
 	lc.Append(fx.Hook{
-		OnStop: func(_ context.Context) error {
-			return listener.Close()
+		OnStop: func(ctx context.Context) error {
+			return server.Shutdown(ctx)
 		},
 	})
 	return nil
 }
 
+const (
+	defaultMaxConcurrentStreams = 250
+	defaultIdleTimeout          = 2 * time.Minute
+	defaultReadHeaderTimeout    = 30 * time.Second
+)
+
+// newHTTPServer build server keeping connections alive between requests, HTTP/2 is served over cleartext if enabled so
+// clients uploading many small files multiplex them on a few connections
+func newHTTPServer(handler http.Handler, cfg config.HTTPConfig) *http.Server {
+	maxStreams := cfg.MaxConcurrentStreams
+	if maxStreams == 0 {
+		maxStreams = defaultMaxConcurrentStreams
+	}
+	idleTimeout := cfg.IdleTimeout
+	if idleTimeout <= 0 {
+		idleTimeout = defaultIdleTimeout
+	}
+	readHeaderTimeout := cfg.ReadHeaderTimeout
+	if readHeaderTimeout <= 0 {
+		readHeaderTimeout = defaultReadHeaderTimeout
+	}
+
+	if cfg.EnableHTTP2 {
+		handler = h2c.NewHandler(handler, &http2.Server{
+			MaxConcurrentStreams: maxStreams,
+			IdleTimeout:          idleTimeout,
+		})
+	}
+	return &http.Server{
+		Handler:           handler,
+		IdleTimeout:       idleTimeout,
+		ReadHeaderTimeout: readHeaderTimeout,
+	}
+}
+
 // OapiRequestValidatorWithOptions Creates middleware to validate request by swagger spec.
 func OapiRequestValidatorWithOptions(swagger *openapi3.T, options *openapi3filter.Options) func(next http.Handler) http.Handler {
 	router, err := gorillamux.NewRouter(swagger)
@@ -4,12 +4,19 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"strconv"
 
 	"github.com/GitDataAI/jiaozifs/auth"
 	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils/i18n"
+	"github.com/GitDataAI/jiaozifs/utils/ratelimit"
+	logging "github.com/ipfs/go-log/v2"
 )
 
+var log = logging.Logger("api")
+
 type JiaozifsResponse struct {
 	http.ResponseWriter
 }
@@ -51,16 +58,44 @@ func (response *JiaozifsResponse) Unauthorized() {
 	response.WriteHeader(http.StatusUnauthorized)
 }
 
+// BadRequest response with 400, msg is translated into the negotiated language before formatting
 func (response *JiaozifsResponse) BadRequest(msg string, args ...any) {
 	response.WriteHeader(http.StatusBadRequest)
-	_, _ = response.Write([]byte(fmt.Sprintf(msg, args...)))
+	_, _ = response.Write([]byte(i18n.T(response.lang(), msg, args...)))
+}
+
+// modelErrorCodes status of errors mapped from constraint violations of database
+var modelErrorCodes = map[error]int{
+	models.ErrAlreadyExists: http.StatusConflict,
+	models.ErrForeignKey:    http.StatusConflict,
+	models.ErrInvalidValue:  http.StatusBadRequest,
 }
 
 // Error response with 500 and error message
 func (response *JiaozifsResponse) Error(err error) {
 	if errors.Is(err, models.ErrNotFound) {
 		response.WriteHeader(http.StatusNotFound)
-		_, _ = response.Write([]byte(err.Error()))
+		_, _ = response.Write([]byte(i18n.TError(response.lang(), err)))
+		return
+	}
+	if errors.Is(err, models.ErrRefConflict) || errors.Is(err, models.ErrStaleObject) {
+		response.WriteHeader(http.StatusConflict)
+		_, _ = response.Write([]byte(i18n.TError(response.lang(), err)))
+		return
+	}
+	if errors.Is(err, models.ErrBranchProtected) || errors.Is(err, models.ErrRepositoryArchived) || errors.Is(err, models.ErrAccessTokenDenied) || errors.Is(err, models.ErrHookRejected) {
+		response.WriteHeader(http.StatusForbidden)
+		_, _ = response.Write([]byte(i18n.TError(response.lang(), err)))
+		return
+	}
+	if errors.Is(err, models.ErrCommitTooLarge) {
+		response.WriteHeader(http.StatusRequestEntityTooLarge)
+		_, _ = response.Write([]byte(i18n.TError(response.lang(), err)))
+		return
+	}
+	if errors.Is(err, models.ErrQuotaExceeded) {
+		response.WriteHeader(http.StatusInsufficientStorage)
+		_, _ = response.Write([]byte(i18n.TError(response.lang(), err)))
 		return
 	}
 	if errors.Is(err, auth.ErrUserNotFound) {
@@ -68,15 +103,37 @@ func (response *JiaozifsResponse) Error(err error) {
 		return
 	}
 
+	// raw errors of database driver are only logged
+	for sentinel, code := range modelErrorCodes {
+		if errors.Is(err, sentinel) {
+			log.Debugf("request rejected %v", err)
+			response.WriteHeader(code)
+			_, _ = response.Write([]byte(i18n.TError(response.lang(), sentinel)))
+			return
+		}
+	}
+	if errors.Is(err, models.ErrDatabaseUnavailable) {
+		log.Errorf("database unavailable %v", err)
+		response.WriteHeader(http.StatusServiceUnavailable)
+		_, _ = response.Write([]byte(i18n.TError(response.lang(), models.ErrDatabaseUnavailable)))
+		return
+	}
+
+	var throttleErr *ratelimit.ThrottleError
+	if errors.As(err, &throttleErr) {
+		response.throttled(throttleErr)
+		return
+	}
+
 	var codeErr ErrCode
 	if errors.As(err, &codeErr) {
 		response.WriteHeader(int(codeErr))
-		_, _ = response.Write([]byte(err.Error()))
+		_, _ = response.Write([]byte(i18n.TError(response.lang(), err)))
 		return
 	}
 
 	response.WriteHeader(http.StatusInternalServerError)
-	_, _ = response.Write([]byte(err.Error()))
+	_, _ = response.Write([]byte(i18n.TError(response.lang(), err)))
 }
 
 // String response and string
@@ -93,6 +150,26 @@ func (response *JiaozifsResponse) String(msg string, code ...int) {
 	_, _ = response.Write([]byte(msg))
 }
 
+// throttled response with 429 and ThrottleError, Retry-After header tell client when to retry
+func (response *JiaozifsResponse) throttled(err *ratelimit.ThrottleError) {
+	retryAfter := int(math.Ceil(err.RetryAfter.Seconds()))
+	response.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+	response.JSON(ThrottleError{
+		Kind:       ThrottleErrorKind(err.Kind),
+		Limit:      err.Limit,
+		RetryAfter: retryAfter,
+		Message:    i18n.T(response.lang(), ratelimit.ThrottleMessage, err.Kind, err.Limit, err.RetryAfter),
+	}, http.StatusTooManyRequests)
+}
+
+// lang language negotiated by i18n middleware, default language if not negotiated
+func (response *JiaozifsResponse) lang() i18n.Lang {
+	if lang := response.Header().Get("Content-Language"); len(lang) > 0 {
+		return i18n.Lang(lang)
+	}
+	return i18n.DefaultLang
+}
+
 // Code response with uncommon code
 func (response *JiaozifsResponse) Code(code int) {
 	response.WriteHeader(code)
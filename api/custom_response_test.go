@@ -4,8 +4,11 @@ import (
 	"fmt"
 	"net/http"
 	"testing"
+	"time"
 
 	"github.com/GitDataAI/jiaozifs/auth"
+	"github.com/GitDataAI/jiaozifs/utils/ratelimit"
+	"github.com/stretchr/testify/require"
 
 	"github.com/GitDataAI/jiaozifs/models"
 
@@ -53,12 +56,26 @@ func TestJiaozifsResponse(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		resp := NewMockResponseWriter(ctrl)
 		jzResp := JiaozifsResponse{resp}
+		resp.EXPECT().Header().Return(make(http.Header)).AnyTimes()
 
 		resp.EXPECT().WriteHeader(http.StatusBadRequest)
 		resp.EXPECT().Write([]byte("bad request"))
 		jzResp.BadRequest("bad request")
 	})
 
+	t.Run("bad request translated", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		resp := NewMockResponseWriter(ctrl)
+		jzResp := JiaozifsResponse{resp}
+
+		header := make(http.Header)
+		header.Set("Content-Language", "zh")
+		resp.EXPECT().Header().Return(header).AnyTimes()
+		resp.EXPECT().WriteHeader(http.StatusBadRequest)
+		resp.EXPECT().Write([]byte("路径 a.txt 不存在"))
+		jzResp.BadRequest("path %s not found", "a.txt")
+	})
+
 	t.Run("code", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		resp := NewMockResponseWriter(ctrl)
@@ -71,6 +88,7 @@ func TestJiaozifsResponse(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		resp := NewMockResponseWriter(ctrl)
 		jzResp := JiaozifsResponse{resp}
+		resp.EXPECT().Header().Return(make(http.Header)).AnyTimes()
 
 		resp.EXPECT().WriteHeader(http.StatusInternalServerError)
 		resp.EXPECT().Write([]byte("mock"))
@@ -81,6 +99,7 @@ func TestJiaozifsResponse(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		resp := NewMockResponseWriter(ctrl)
 		jzResp := JiaozifsResponse{resp}
+		resp.EXPECT().Header().Return(make(http.Header)).AnyTimes()
 
 		resp.EXPECT().WriteHeader(http.StatusConflict)
 		resp.EXPECT().Write([]byte("mock code 409 msg Conflict"))
@@ -88,17 +107,43 @@ func TestJiaozifsResponse(t *testing.T) {
 		jzResp.Error(fmt.Errorf("mock %w", ErrCode(http.StatusConflict)))
 	})
 
+	t.Run("error throttled", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		resp := NewMockResponseWriter(ctrl)
+		jzResp := JiaozifsResponse{resp}
+		header := make(http.Header)
+		resp.EXPECT().Header().Return(header).AnyTimes()
+
+		err := fmt.Errorf("upload %w", &ratelimit.ThrottleError{Kind: ratelimit.KindWriteRate, Limit: 60, RetryAfter: 1500 * time.Millisecond})
+		resp.EXPECT().WriteHeader(http.StatusTooManyRequests)
+		resp.EXPECT().Write([]byte("{\"kind\":\"write_rate\",\"limit\":60,\"message\":\"repository write_rate limit 60 exceeded, retry after 1.5s\",\"retry_after\":2}\n"))
+		jzResp.Error(err)
+		require.Equal(t, "2", header.Get("Retry-After"))
+	})
+
 	t.Run("error not found", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		resp := NewMockResponseWriter(ctrl)
 		jzResp := JiaozifsResponse{resp}
+		resp.EXPECT().Header().Return(make(http.Header)).AnyTimes()
 
 		err := fmt.Errorf("mock %w", models.ErrNotFound)
 		resp.EXPECT().WriteHeader(http.StatusNotFound)
-		resp.EXPECT().Write([]byte(err.Error()))
+		resp.EXPECT().Write([]byte("mock not found"))
 		jzResp.Error(err)
 	})
 
+	t.Run("error already exists", func(t *testing.T) {
+		ctrl := gomock.NewController(t)
+		resp := NewMockResponseWriter(ctrl)
+		jzResp := JiaozifsResponse{resp}
+		resp.EXPECT().Header().Return(make(http.Header)).AnyTimes()
+
+		resp.EXPECT().WriteHeader(http.StatusConflict)
+		resp.EXPECT().Write([]byte(models.ErrAlreadyExists.Error()))
+		jzResp.Error(fmt.Errorf("repository mock %w", models.ErrAlreadyExists))
+	})
+
 	t.Run("error no auth", func(t *testing.T) {
 		ctrl := gomock.NewController(t)
 		resp := NewMockResponseWriter(ctrl)
@@ -0,0 +1,84 @@
+package api
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/utils/compress"
+	"golang.org/x/net/http2"
+)
+
+// HTTPOptions connection settings of client, tuned for uploading many small files concurrently
+type HTTPOptions struct {
+	// HTTP2 multiplex requests on one connection, cleartext HTTP/2 (h2c) is used for http urls and negotiated by TLS
+	// for https urls
+	HTTP2 bool
+	// MaxIdleConnsPerHost idle connections kept for reuse by HTTP/1.1 requests
+	MaxIdleConnsPerHost int
+	// IdleConnTimeout idle connection is closed after it
+	IdleConnTimeout time.Duration
+	// PingTimeout HTTP/2 connection without frames longer than it is health checked by a ping
+	PingTimeout time.Duration
+}
+
+// DefaultHTTPOptions HTTP2 is off as cleartext HTTP/2 fails against server not enabling it
+func DefaultHTTPOptions() HTTPOptions {
+	return HTTPOptions{
+		HTTP2:               false,
+		MaxIdleConnsPerHost: 64,
+		IdleConnTimeout:     90 * time.Second,
+		PingTimeout:         30 * time.Second,
+	}
+}
+
+// NewHTTPClient build client reusing connections, responses are negotiated to zstd or gzip and decoded transparently
+func NewHTTPClient(opts HTTPOptions) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	base := &http.Transport{
+		Proxy:                 http.ProxyFromEnvironment,
+		DialContext:           dialer.DialContext,
+		ForceAttemptHTTP2:     opts.HTTP2,
+		MaxIdleConns:          opts.MaxIdleConnsPerHost * 4,
+		MaxIdleConnsPerHost:   opts.MaxIdleConnsPerHost,
+		IdleConnTimeout:       opts.IdleConnTimeout,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: time.Second,
+	}
+
+	var transport http.RoundTripper = base
+	if opts.HTTP2 {
+		transport = &schemeTransport{
+			https: base,
+			http: &http2.Transport{
+				AllowHTTP: true,
+				// h2c with prior knowledge, connection is plain tcp
+				DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+					return dialer.DialContext(ctx, network, addr)
+				},
+				ReadIdleTimeout: opts.PingTimeout,
+			},
+		}
+	}
+	return &http.Client{Transport: compress.NewTransport(transport)}
+}
+
+// HTTPClientOption use client built from opts
+func HTTPClientOption(opts HTTPOptions) ClientOption {
+	return WithHTTPClient(NewHTTPClient(opts))
+}
+
+// schemeTransport send http requests by h2c and others by standard transport
+type schemeTransport struct {
+	http  http.RoundTripper
+	https http.RoundTripper
+}
+
+func (t *schemeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.URL.Scheme == "http" {
+		return t.http.RoundTrip(req)
+	}
+	return t.https.RoundTrip(req)
+}
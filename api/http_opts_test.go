@@ -0,0 +1,44 @@
+package api
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+func TestNewHTTPClient(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = fmt.Fprint(w, r.Proto)
+	})
+	server := httptest.NewServer(h2c.NewHandler(handler, &http2.Server{}))
+	defer server.Close()
+
+	get := func(client *http.Client) string {
+		resp, err := client.Get(server.URL)
+		require.NoError(t, err)
+		defer resp.Body.Close() //nolint
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	t.Run("http1", func(t *testing.T) {
+		client := NewHTTPClient(DefaultHTTPOptions())
+		require.Equal(t, "HTTP/1.1", get(client))
+	})
+
+	t.Run("h2c", func(t *testing.T) {
+		opts := DefaultHTTPOptions()
+		opts.HTTP2 = true
+		client := NewHTTPClient(opts)
+		for i := 0; i < 3; i++ {
+			require.Equal(t, "HTTP/2.0", get(client))
+		}
+	})
+}
@@ -3,6 +3,7 @@ package factory
 import (
 	"context"
 	"fmt"
+	"sync"
 
 	"github.com/GitDataAI/jiaozifs/block/ipfs"
 
@@ -14,6 +15,7 @@ import (
 	"github.com/GitDataAI/jiaozifs/block/mem"
 	"github.com/GitDataAI/jiaozifs/block/params"
 	s3a "github.com/GitDataAI/jiaozifs/block/s3"
+	"github.com/GitDataAI/jiaozifs/block/spool"
 	"github.com/GitDataAI/jiaozifs/block/transient"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	logging "github.com/ipfs/go-log/v2"
@@ -28,9 +30,30 @@ const (
 	googleAuthCloudPlatform = "https://www.googleapis.com/auth/cloud-platform"
 )
 
+// memAdapter adapter is built on every access of repository, in memory data is kept only if they share one store
+var memAdapter = sync.OnceValue(func() *mem.Adapter {
+	return mem.New(context.Background())
+})
+
 type BlockAdapterBuilder = func(context.Context, params.AdapterConfig) (block.Adapter, error)
 
 func BuildBlockAdapter(ctx context.Context, c params.AdapterConfig) (block.Adapter, error) {
+	adapter, err := buildBlockAdapter(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	p, err := c.BlockstoreSpoolParams()
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return adapter, nil
+	}
+	return spool.NewAdapter(adapter, *p)
+}
+
+func buildBlockAdapter(ctx context.Context, c params.AdapterConfig) (block.Adapter, error) {
 	blockstore := c.BlockstoreType()
 	log.With("type", blockstore).
 		Info("initialize blockstore adapter")
@@ -54,7 +77,7 @@ func BuildBlockAdapter(ctx context.Context, c params.AdapterConfig) (block.Adapt
 		}
 		return buildS3Adapter(ctx, p)
 	case block.BlockstoreTypeMem, "memory":
-		return mem.New(ctx), nil
+		return memAdapter(), nil
 	case block.BlockstoreTypeTransient:
 		return transient.New(ctx), nil
 	case block.BlockstoreTypeGS:
@@ -40,10 +40,11 @@ func newMPU() *mpu {
 
 func (m *mpu) get() []byte {
 	buf := bytes.NewBuffer(nil)
-	keys := make([]int, len(m.parts))
-	sort.Slice(keys, func(i, j int) bool {
-		return keys[i] < keys[j]
-	})
+	keys := make([]int, 0, len(m.parts))
+	for part := range m.parts {
+		keys = append(keys, part)
+	}
+	sort.Ints(keys)
 	for _, part := range keys {
 		buf.Write(m.parts[part])
 	}
@@ -120,7 +121,59 @@ func verifyObjectPointer(obj block.ObjectPointer) error {
 }
 
 func (a *Adapter) GetWalker(_ *url.URL) (block.Walker, error) {
-	return nil, fmt.Errorf("mem block adapter: %w", block.ErrOperationNotSupported)
+	return &walker{adapter: a}, nil
+}
+
+// walker list data of a storage namespace in key order, uri is the namespace root like the one formatted from
+// ResolveNamespace with an empty key
+type walker struct {
+	adapter *Adapter
+	mark    block.Mark
+}
+
+func (w *walker) Walk(_ context.Context, storageURI *url.URL, op block.WalkOptions, walkFn func(e block.ObjectStoreEntry) error) error {
+	namespace := strings.TrimSuffix(storageURI.String(), "/")
+	prefix := namespace + ":"
+
+	w.adapter.mutex.RLock()
+	type entry struct {
+		key  string
+		size int64
+	}
+	var entries []entry
+	for key, data := range w.adapter.data {
+		if strings.HasPrefix(key, prefix) && key > op.After {
+			entries = append(entries, entry{key: key, size: int64(len(data))})
+		}
+	}
+	w.adapter.mutex.RUnlock()
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].key < entries[j].key
+	})
+
+	for _, ent := range entries {
+		relativeKey := strings.TrimPrefix(ent.key, prefix)
+		w.mark.LastKey = ent.key
+		err := walkFn(block.ObjectStoreEntry{
+			FullKey:     ent.key,
+			RelativeKey: relativeKey,
+			Address:     namespace + "/" + relativeKey,
+			Size:        ent.size,
+		})
+		if err != nil {
+			return err
+		}
+	}
+	w.mark = block.Mark{}
+	return nil
+}
+
+func (w *walker) Marker() block.Mark {
+	return w.mark
+}
+
+func (w *walker) GetSkippedEntries() []block.ObjectStoreEntry {
+	return nil
 }
 
 func (a *Adapter) GetPreSignedURL(_ context.Context, obj block.ObjectPointer, _ block.PreSignMode) (string, time.Time, error) {
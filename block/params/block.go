@@ -12,6 +12,8 @@ type AdapterConfig interface {
 	BlockstoreGSParams() (GS, error)
 	BlockstoreIpfsParams() (Ipfs, error)
 	BlockstoreAzureParams() (Azure, error)
+	// BlockstoreSpoolParams return nil if spool is not enabled
+	BlockstoreSpoolParams() (*Spool, error)
 }
 
 type Mem struct{}
@@ -64,6 +66,10 @@ type S3 struct {
 	ClientLogRetries              bool
 	ClientLogRequest              bool
 	WebIdentity                   *S3WebIdentity
+	// AssumeRoleARN role assumed to issue scoped credentials of repository storage, issuing is disabled if empty
+	AssumeRoleARN string
+	// AssumeRoleEndpoint sts endpoint, default to aws sts
+	AssumeRoleEndpoint string
 }
 
 type GS struct {
@@ -84,3 +90,9 @@ type Azure struct {
 	// TestEndpointURL - For testing purposes, provide a custom URL to override the default URL template
 	TestEndpointURL string
 }
+
+// Spool uploads are written to local disk first and flushed to blockstore in background
+type Spool struct {
+	Path    string
+	Workers int
+}
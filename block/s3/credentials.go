@@ -0,0 +1,199 @@
+package s3
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block/params"
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/sts"
+)
+
+const (
+	// MinScopedCredentialsDuration shortest session allowed by sts AssumeRole
+	MinScopedCredentialsDuration = 15 * time.Minute
+	// DefaultScopedCredentialsDuration used when duration of scoped credentials is not specific
+	DefaultScopedCredentialsDuration = time.Hour
+)
+
+var (
+	ErrAssumeRoleNotConfigured = errors.New("assume role arn of s3 blockstore not configured")
+	ErrPrefixOutOfNamespace    = errors.New("prefix out of storage namespace")
+)
+
+// ScopedCredentials temporary credentials limited to Operations on objects under Prefix of Bucket
+type ScopedCredentials struct {
+	Operations      []Operation
+	AccessKeyID     string
+	SecretAccessKey string
+	SessionToken    string
+	Expiration      time.Time
+	Bucket          string
+	Prefix          string
+	Region          string
+	Endpoint        string
+}
+
+type policyDocument struct {
+	Version   string            `json:"Version"`
+	Statement []policyStatement `json:"Statement"`
+}
+
+type policyStatement struct {
+	Effect    string                         `json:"Effect"`
+	Action    []string                       `json:"Action"`
+	Resource  []string                       `json:"Resource"`
+	Condition map[string]map[string][]string `json:"Condition,omitempty"`
+}
+
+// Operation kind of object access granted by scoped credentials
+type Operation string
+
+const (
+	ReadOperation   Operation = "read"
+	WriteOperation  Operation = "write"
+	DeleteOperation Operation = "delete"
+	ListOperation   Operation = "list"
+)
+
+var ErrInvalidOperation = errors.New("invalid operation")
+
+var operationActions = map[Operation][]string{
+	ReadOperation:   {"s3:GetObject"},
+	WriteOperation:  {"s3:PutObject", "s3:AbortMultipartUpload", "s3:ListMultipartUploadParts"},
+	DeleteOperation: {"s3:DeleteObject"},
+}
+
+// PinPrefix join relative path to prefix of storage namespace, path escaping the namespace is rejected
+func PinPrefix(namespacePrefix, relativePath string) (string, error) {
+	if len(relativePath) == 0 {
+		return namespacePrefix, nil
+	}
+	if strings.HasPrefix(relativePath, "/") {
+		return "", fmt.Errorf("%s must be relative %w", relativePath, ErrPrefixOutOfNamespace)
+	}
+	for _, elem := range strings.Split(relativePath, "/") {
+		if elem == ".." {
+			return "", fmt.Errorf("%s %w", relativePath, ErrPrefixOutOfNamespace)
+		}
+	}
+	cleaned := path.Clean(relativePath)
+	if cleaned == "." {
+		return namespacePrefix, nil
+	}
+	if len(namespacePrefix) == 0 {
+		return cleaned, nil
+	}
+	return strings.TrimSuffix(namespacePrefix, "/") + "/" + cleaned, nil
+}
+
+// ScopedPolicy session policy allowing operations on objects under prefix of bucket only
+func ScopedPolicy(bucket, prefix string, operations []Operation) (string, error) {
+	objectPattern := "*"
+	if len(prefix) > 0 {
+		prefix = strings.TrimSuffix(prefix, "/") + "/"
+		objectPattern = prefix + "*"
+	}
+
+	var statements []policyStatement
+	var objectActions []string
+	for _, op := range operations {
+		if op == ListOperation {
+			listStatement := policyStatement{
+				Effect:   "Allow",
+				Action:   []string{"s3:ListBucket"},
+				Resource: []string{"arn:aws:s3:::" + bucket},
+			}
+			if len(prefix) > 0 {
+				listStatement.Condition = map[string]map[string][]string{
+					"StringLike": {"s3:prefix": {objectPattern}},
+				}
+			}
+			statements = append(statements, listStatement)
+			continue
+		}
+		actions, ok := operationActions[op]
+		if !ok {
+			return "", fmt.Errorf("%s %w", op, ErrInvalidOperation)
+		}
+		objectActions = append(objectActions, actions...)
+	}
+	if len(objectActions) > 0 {
+		statements = append([]policyStatement{{
+			Effect:   "Allow",
+			Action:   objectActions,
+			Resource: []string{fmt.Sprintf("arn:aws:s3:::%s/%s", bucket, objectPattern)},
+		}}, statements...)
+	}
+	if len(statements) == 0 {
+		return "", fmt.Errorf("no operation given %w", ErrInvalidOperation)
+	}
+
+	data, err := json.Marshal(policyDocument{
+		Version:   "2012-10-17",
+		Statement: statements,
+	})
+	if err != nil {
+		return "", err
+	}
+	return string(data), nil
+}
+
+// AssumeScopedRole exchange credentials of blockstore for temporary credentials which is limited to operations on prefix
+// of bucket by session policy, the role configured by AssumeRoleARN must be allowed to access the bucket
+func AssumeScopedRole(ctx context.Context, params params.S3, sessionName, bucket, prefix string, operations []Operation, duration time.Duration) (*ScopedCredentials, error) {
+	if len(params.AssumeRoleARN) == 0 {
+		return nil, ErrAssumeRoleNotConfigured
+	}
+	if len(prefix) > 0 {
+		prefix = strings.TrimSuffix(prefix, "/") + "/"
+	}
+	if duration <= 0 {
+		duration = DefaultScopedCredentialsDuration
+	}
+	if duration < MinScopedCredentialsDuration {
+		duration = MinScopedCredentialsDuration
+	}
+
+	policy, err := ScopedPolicy(bucket, prefix, operations)
+	if err != nil {
+		return nil, err
+	}
+
+	cfg, err := LoadConfig(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	client := sts.NewFromConfig(cfg, func(options *sts.Options) {
+		if len(params.AssumeRoleEndpoint) > 0 {
+			options.BaseEndpoint = aws.String(params.AssumeRoleEndpoint)
+		}
+	})
+
+	output, err := client.AssumeRole(ctx, &sts.AssumeRoleInput{
+		RoleArn:         aws.String(params.AssumeRoleARN),
+		RoleSessionName: aws.String(sessionName),
+		Policy:          aws.String(policy),
+		DurationSeconds: aws.Int32(int32(duration.Seconds())),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("assume role %s %w", params.AssumeRoleARN, err)
+	}
+
+	return &ScopedCredentials{
+		AccessKeyID:     aws.ToString(output.Credentials.AccessKeyId),
+		SecretAccessKey: aws.ToString(output.Credentials.SecretAccessKey),
+		SessionToken:    aws.ToString(output.Credentials.SessionToken),
+		Expiration:      aws.ToTime(output.Credentials.Expiration),
+		Operations:      operations,
+		Bucket:          bucket,
+		Prefix:          prefix,
+		Region:          cfg.Region,
+		Endpoint:        params.Endpoint,
+	}, nil
+}
@@ -0,0 +1,86 @@
+package s3_test
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block/params"
+	"github.com/GitDataAI/jiaozifs/block/s3"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScopedPolicy(t *testing.T) {
+	type statement struct {
+		Action    []string
+		Resource  []string
+		Condition map[string]map[string][]string
+	}
+	parse := func(policy string, count int) []statement {
+		doc := struct {
+			Statement []statement
+		}{}
+		require.NoError(t, json.Unmarshal([]byte(policy), &doc))
+		require.Len(t, doc.Statement, count)
+		return doc.Statement
+	}
+
+	t.Run("read and list", func(t *testing.T) {
+		policy, err := s3.ScopedPolicy("bucket1", "repo", []s3.Operation{s3.ReadOperation, s3.ListOperation})
+		require.NoError(t, err)
+		statements := parse(policy, 2)
+		require.Equal(t, []string{"s3:GetObject"}, statements[0].Action)
+		require.Equal(t, []string{"arn:aws:s3:::bucket1/repo/*"}, statements[0].Resource)
+		require.Equal(t, []string{"arn:aws:s3:::bucket1"}, statements[1].Resource)
+		require.Equal(t, []string{"repo/*"}, statements[1].Condition["StringLike"]["s3:prefix"])
+	})
+
+	t.Run("write only", func(t *testing.T) {
+		policy, err := s3.ScopedPolicy("bucket1", "a/b/", []s3.Operation{s3.WriteOperation})
+		require.NoError(t, err)
+		statements := parse(policy, 1)
+		require.Contains(t, statements[0].Action, "s3:PutObject")
+		require.NotContains(t, statements[0].Action, "s3:GetObject")
+		require.NotContains(t, statements[0].Action, "s3:DeleteObject")
+		require.Equal(t, []string{"arn:aws:s3:::bucket1/a/b/*"}, statements[0].Resource)
+	})
+
+	t.Run("list whole bucket", func(t *testing.T) {
+		policy, err := s3.ScopedPolicy("bucket1", "", []s3.Operation{s3.ListOperation})
+		require.NoError(t, err)
+		statements := parse(policy, 1)
+		require.Equal(t, []string{"s3:ListBucket"}, statements[0].Action)
+		require.Nil(t, statements[0].Condition)
+	})
+
+	t.Run("invalid operation", func(t *testing.T) {
+		_, err := s3.ScopedPolicy("bucket1", "repo", []s3.Operation{"admin"})
+		require.ErrorIs(t, err, s3.ErrInvalidOperation)
+		_, err = s3.ScopedPolicy("bucket1", "repo", nil)
+		require.ErrorIs(t, err, s3.ErrInvalidOperation)
+	})
+}
+
+func TestPinPrefix(t *testing.T) {
+	prefix, err := s3.PinPrefix("repo", "")
+	require.NoError(t, err)
+	require.Equal(t, "repo", prefix)
+
+	prefix, err = s3.PinPrefix("repo/", "a/./b/")
+	require.NoError(t, err)
+	require.Equal(t, "repo/a/b", prefix)
+
+	prefix, err = s3.PinPrefix("", "a")
+	require.NoError(t, err)
+	require.Equal(t, "a", prefix)
+
+	_, err = s3.PinPrefix("repo", "a/../../other")
+	require.ErrorIs(t, err, s3.ErrPrefixOutOfNamespace)
+	_, err = s3.PinPrefix("repo", "/other")
+	require.ErrorIs(t, err, s3.ErrPrefixOutOfNamespace)
+}
+
+func TestAssumeScopedRoleNotConfigured(t *testing.T) {
+	_, err := s3.AssumeScopedRole(context.Background(), params.S3{}, "session", "bucket1", "repo", []s3.Operation{s3.ReadOperation}, 0)
+	require.ErrorIs(t, err, s3.ErrAssumeRoleNotConfigured)
+}
@@ -0,0 +1,216 @@
+package spool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/block/params"
+)
+
+var _ block.Adapter = (*Adapter)(nil)
+
+// Adapter wrap adapter of object store with local spool, Put return once data is on local disk and reads of pending
+// uploads are served from spool. operations which need object in store, like copy and multipart upload, flush the
+// object first
+type Adapter struct {
+	block.Adapter
+	spool *Spool
+}
+
+func NewAdapter(adapter block.Adapter, p params.Spool) (*Adapter, error) {
+	s, err := Open(p.Path, adapter, p.Workers)
+	if err != nil {
+		return nil, err
+	}
+	return &Adapter{Adapter: adapter, spool: s}, nil
+}
+
+// Spool return spool used by adapter
+func (a *Adapter) Spool() *Spool {
+	return a.spool
+}
+
+func (a *Adapter) key(obj block.ObjectPointer) (string, error) {
+	qk, err := a.Adapter.ResolveNamespace(obj.StorageNamespace, obj.Identifier, obj.IdentifierType)
+	if err != nil {
+		return "", err
+	}
+	return qk.Format(), nil
+}
+
+func (a *Adapter) Put(_ context.Context, obj block.ObjectPointer, _ int64, reader io.Reader, _ block.PutOpts) error {
+	key, err := a.key(obj)
+	if err != nil {
+		return err
+	}
+	return a.spool.Write(key, obj, reader)
+}
+
+func (a *Adapter) Get(ctx context.Context, obj block.ObjectPointer, expectedSize int64) (io.ReadCloser, error) {
+	key, err := a.key(obj)
+	if err != nil {
+		return nil, err
+	}
+	f, ok, err := a.spool.Open(key)
+	if ok {
+		return f, err
+	}
+	return a.Adapter.Get(ctx, obj, expectedSize)
+}
+
+func (a *Adapter) GetRange(ctx context.Context, obj block.ObjectPointer, startPosition int64, endPosition int64) (io.ReadCloser, error) {
+	if startPosition < 0 || endPosition < startPosition {
+		return nil, block.ErrBadIndex
+	}
+	key, err := a.key(obj)
+	if err != nil {
+		return nil, err
+	}
+	f, ok, err := a.spool.Open(key)
+	if !ok {
+		return a.Adapter.GetRange(ctx, obj, startPosition, endPosition)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &struct {
+		io.Reader
+		io.Closer
+	}{
+		Reader: io.NewSectionReader(f, startPosition, endPosition-startPosition+1),
+		Closer: f,
+	}, nil
+}
+
+func (a *Adapter) Exists(ctx context.Context, obj block.ObjectPointer) (bool, error) {
+	key, err := a.key(obj)
+	if err != nil {
+		return false, err
+	}
+	if a.spool.Contains(key) {
+		return true, nil
+	}
+	return a.Adapter.Exists(ctx, obj)
+}
+
+func (a *Adapter) GetProperties(ctx context.Context, obj block.ObjectPointer) (block.Properties, error) {
+	err := a.flush(ctx, obj)
+	if err != nil {
+		return block.Properties{}, err
+	}
+	return a.Adapter.GetProperties(ctx, obj)
+}
+
+func (a *Adapter) GetWalker(uri *url.URL) (block.Walker, error) {
+	// walker list object store, objects in spool are flushed to be listed
+	err := a.spool.Flush(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	return a.Adapter.GetWalker(uri)
+}
+
+func (a *Adapter) GetPreSignedURL(ctx context.Context, obj block.ObjectPointer, mode block.PreSignMode) (string, time.Time, error) {
+	err := a.flush(ctx, obj)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return a.Adapter.GetPreSignedURL(ctx, obj, mode)
+}
+
+func (a *Adapter) Remove(ctx context.Context, obj block.ObjectPointer) error {
+	key, err := a.key(obj)
+	if err != nil {
+		return err
+	}
+	discarded := a.spool.Discard(func(k string) bool { return k == key }) > 0
+	// upload may be flushing and not able to discard, wait for it to land before remove
+	err = a.spool.FlushKey(ctx, key)
+	if err != nil {
+		return err
+	}
+	err = a.Adapter.Remove(ctx, obj)
+	if discarded && (errors.Is(err, block.ErrDataNotFound) || errors.Is(err, os.ErrNotExist)) {
+		return nil
+	}
+	return err
+}
+
+func (a *Adapter) RemoveNameSpace(ctx context.Context, storageNamespace string) error {
+	qk, err := a.Adapter.ResolveNamespace(storageNamespace, "", block.IdentifierTypeRelative)
+	if err != nil {
+		return err
+	}
+	prefix := qk.Format()
+	a.spool.Discard(func(key string) bool { return strings.HasPrefix(key, prefix) })
+	err = a.spool.Flush(ctx)
+	if err != nil {
+		return err
+	}
+	return a.Adapter.RemoveNameSpace(ctx, storageNamespace)
+}
+
+func (a *Adapter) Copy(ctx context.Context, sourceObj, destinationObj block.ObjectPointer) error {
+	err := a.flush(ctx, sourceObj)
+	if err != nil {
+		return err
+	}
+	return a.Adapter.Copy(ctx, sourceObj, destinationObj)
+}
+
+func (a *Adapter) CreateMultiPartUpload(ctx context.Context, obj block.ObjectPointer, r *http.Request, opts block.CreateMultiPartUploadOpts) (*block.CreateMultiPartUploadResponse, error) {
+	// pending upload of the same object must not overwrite the multipart upload later
+	err := a.flush(ctx, obj)
+	if err != nil {
+		return nil, err
+	}
+	return a.Adapter.CreateMultiPartUpload(ctx, obj, r, opts)
+}
+
+func (a *Adapter) UploadCopyPart(ctx context.Context, sourceObj, destinationObj block.ObjectPointer, uploadID string, partNumber int) (*block.UploadPartResponse, error) {
+	err := a.flush(ctx, sourceObj)
+	if err != nil {
+		return nil, err
+	}
+	return a.Adapter.UploadCopyPart(ctx, sourceObj, destinationObj, uploadID, partNumber)
+}
+
+func (a *Adapter) UploadCopyPartRange(ctx context.Context, sourceObj, destinationObj block.ObjectPointer, uploadID string, partNumber int, startPosition, endPosition int64) (*block.UploadPartResponse, error) {
+	err := a.flush(ctx, sourceObj)
+	if err != nil {
+		return nil, err
+	}
+	return a.Adapter.UploadCopyPartRange(ctx, sourceObj, destinationObj, uploadID, partNumber, startPosition, endPosition)
+}
+
+func (a *Adapter) RuntimeStats() map[string]string {
+	stats := a.Adapter.RuntimeStats()
+	if stats == nil {
+		stats = make(map[string]string)
+	}
+	spoolStats := a.spool.Stats()
+	stats["spool_pending"] = fmt.Sprint(spoolStats.Pending)
+	stats["spool_pending_bytes"] = fmt.Sprint(spoolStats.PendingBytes)
+	stats["spool_flushed"] = fmt.Sprint(spoolStats.Flushed)
+	stats["spool_failures"] = fmt.Sprint(spoolStats.Failures)
+	if !spoolStats.OldestPending.IsZero() {
+		stats["spool_oldest_pending"] = spoolStats.OldestPending.Format(time.RFC3339)
+	}
+	return stats
+}
+
+func (a *Adapter) flush(ctx context.Context, obj block.ObjectPointer) error {
+	key, err := a.key(obj)
+	if err != nil {
+		return err
+	}
+	return a.spool.FlushKey(ctx, key)
+}
@@ -0,0 +1,110 @@
+package spool
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"path"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/block/blocktest"
+	"github.com/GitDataAI/jiaozifs/block/local"
+	"github.com/GitDataAI/jiaozifs/block/params"
+	"github.com/stretchr/testify/require"
+)
+
+const testStorageNamespace = "local://test"
+
+func TestSpoolAdapter(t *testing.T) {
+	tmpDir := t.TempDir()
+	localPath := path.Join(tmpDir, "jiaozfs")
+	externalPath := block.BlockstoreTypeLocal + "://" + path.Join(tmpDir, "jiaozfs", "external")
+	localAdapter, err := local.NewAdapter(localPath, local.WithRemoveEmptyDir(false))
+	require.NoError(t, err)
+
+	adapter, err := NewAdapter(localAdapter, params.Spool{Path: path.Join(tmpDir, "spool")})
+	require.NoError(t, err)
+	blocktest.AdapterTest(t, &flushOnPutAdapter{Adapter: adapter}, testStorageNamespace, externalPath)
+}
+
+// flushOnPutAdapter wait for upload to land in object store, common adapter tests inspect object store layout after put
+type flushOnPutAdapter struct {
+	*Adapter
+}
+
+func (a *flushOnPutAdapter) Put(ctx context.Context, obj block.ObjectPointer, sizeBytes int64, reader io.Reader, opts block.PutOpts) error {
+	err := a.Adapter.Put(ctx, obj, sizeBytes, reader, opts)
+	if err != nil {
+		return err
+	}
+	return a.flush(ctx, obj)
+}
+
+// failingAdapter fail put until enabled
+type failingAdapter struct {
+	block.Adapter
+	enabled atomic.Bool
+}
+
+func (a *failingAdapter) Put(ctx context.Context, obj block.ObjectPointer, sizeBytes int64, reader io.Reader, opts block.PutOpts) error {
+	if !a.enabled.Load() {
+		return errors.New("object store unavailable")
+	}
+	return a.Adapter.Put(ctx, obj, sizeBytes, reader, opts)
+}
+
+func TestSpoolDurability(t *testing.T) {
+	ctx := context.Background()
+	tmpDir := t.TempDir()
+	localAdapter, err := local.NewAdapter(path.Join(tmpDir, "jiaozfs"), local.WithRemoveEmptyDir(false))
+	require.NoError(t, err)
+	target := &failingAdapter{Adapter: localAdapter}
+
+	spoolDir := path.Join(tmpDir, "spool")
+	s, err := newSpool(spoolDir, target, 1)
+	require.NoError(t, err)
+	adapter := &Adapter{Adapter: target, spool: s}
+
+	obj := block.ObjectPointer{StorageNamespace: testStorageNamespace, Identifier: "a/b", IdentifierType: block.IdentifierTypeRelative}
+	content := []byte("spooled content")
+	require.NoError(t, adapter.Put(ctx, obj, int64(len(content)), bytes.NewReader(content), block.PutOpts{}))
+
+	// readable from spool while object store is down
+	reader, err := adapter.Get(ctx, obj, int64(len(content)))
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	require.Equal(t, content, data)
+
+	exist, err := localAdapter.Exists(ctx, obj)
+	require.NoError(t, err)
+	require.False(t, exist)
+
+	require.Eventually(t, func() bool {
+		return s.Stats().Failures > 0
+	}, 5*time.Second, 10*time.Millisecond)
+	require.Equal(t, 1, s.Stats().Pending)
+
+	// pending upload survive restart and is flushed once object store is back
+	target.enabled.Store(true)
+	recovered, err := newSpool(spoolDir, target, 1)
+	require.NoError(t, err)
+	require.Equal(t, 1, recovered.Stats().Pending)
+
+	flushCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	require.NoError(t, recovered.Flush(flushCtx))
+	require.Equal(t, int64(1), recovered.Stats().Flushed)
+
+	reader, err = localAdapter.Get(ctx, obj, int64(len(content)))
+	require.NoError(t, err)
+	data, err = io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	require.Equal(t, content, data)
+}
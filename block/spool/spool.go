@@ -0,0 +1,414 @@
+package spool
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/google/uuid"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var log = logging.Logger("spool")
+
+const (
+	dataDir    = "data"
+	pendingDir = "pending"
+
+	DefaultWorkers = 4
+	maxBackoff     = time.Minute
+)
+
+// entry is an upload which landed on local disk and not flushed to object store yet, it is persisted beside the data,
+// pending entries are loaded and flushed again after restart
+type entry struct {
+	ID               string               `json:"id"`
+	Key              string               `json:"key"`
+	StorageNamespace string               `json:"storage_namespace"`
+	Identifier       string               `json:"identifier"`
+	IdentifierType   block.IdentifierType `json:"identifier_type"`
+	Size             int64                `json:"size"`
+	CreatedAt        time.Time            `json:"created_at"`
+	Attempts         int                  `json:"attempts"`
+	LastError        string               `json:"last_error,omitempty"`
+}
+
+func (e *entry) pointer() block.ObjectPointer {
+	return block.ObjectPointer{
+		StorageNamespace: e.StorageNamespace,
+		Identifier:       e.Identifier,
+		IdentifierType:   e.IdentifierType,
+	}
+}
+
+// Stats durability state of spool
+type Stats struct {
+	// Pending uploads stored on local disk only
+	Pending int
+	// PendingBytes size of pending uploads
+	PendingBytes int64
+	// Flushed uploads written to object store since start
+	Flushed int64
+	// Failures failed flush attempts since start, failed uploads are retried
+	Failures int64
+	// OldestPending creation time of the oldest pending upload
+	OldestPending time.Time
+}
+
+// Spool keep uploads on local disk and flush them to target adapter in background. an upload is durable once Write
+// returns because data and entry are synced to disk, target is written at least once afterward
+type Spool struct {
+	dir    string
+	target block.Adapter
+
+	lk       sync.Mutex
+	cond     *sync.Cond
+	pending  map[string]*entry
+	flushing map[string]bool
+	queue    chan string
+	flushed  int64
+	failures int64
+}
+
+var (
+	spoolsLk sync.Mutex
+	spools   = make(map[string]*Spool)
+)
+
+// Open return spool of dir, spool is shared by all adapters built with the same dir in process, so background flush
+// keep running while adapters are built per request. pending uploads left by former process are flushed
+func Open(dir string, target block.Adapter, workers int) (*Spool, error) {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	spoolsLk.Lock()
+	defer spoolsLk.Unlock()
+	if s, ok := spools[dir]; ok {
+		return s, nil
+	}
+
+	s, err := newSpool(dir, target, workers)
+	if err != nil {
+		return nil, err
+	}
+	spools[dir] = s
+	return s, nil
+}
+
+func newSpool(dir string, target block.Adapter, workers int) (*Spool, error) {
+	if workers <= 0 {
+		workers = DefaultWorkers
+	}
+	for _, sub := range []string{dataDir, pendingDir} {
+		err := os.MkdirAll(filepath.Join(dir, sub), 0755)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	s := &Spool{
+		dir:      dir,
+		target:   target,
+		pending:  make(map[string]*entry),
+		flushing: make(map[string]bool),
+		queue:    make(chan string, 1024),
+	}
+	s.cond = sync.NewCond(&s.lk)
+
+	entries, err := s.loadPending()
+	if err != nil {
+		return nil, err
+	}
+	for i := 0; i < workers; i++ {
+		go s.worker()
+	}
+	for _, e := range entries {
+		s.enqueue(e.Key)
+	}
+	if len(entries) > 0 {
+		log.Infof("recover %d pending uploads from spool %s", len(entries), dir)
+	}
+	return s, nil
+}
+
+func (s *Spool) loadPending() ([]*entry, error) {
+	files, err := os.ReadDir(filepath.Join(s.dir, pendingDir))
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*entry
+	for _, file := range files {
+		if !strings.HasSuffix(file.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(s.dir, pendingDir, file.Name()))
+		if err != nil {
+			return nil, err
+		}
+		e := &entry{}
+		err = json.Unmarshal(data, e)
+		if err != nil {
+			return nil, fmt.Errorf("parse spool entry %s %w", file.Name(), err)
+		}
+		// keep the latest upload of the same key
+		if former, ok := s.pending[e.Key]; ok {
+			if former.CreatedAt.After(e.CreatedAt) {
+				s.removeFiles(e.ID)
+				continue
+			}
+			s.removeFiles(former.ID)
+		}
+		s.pending[e.Key] = e
+	}
+	for _, e := range s.pending {
+		entries = append(entries, e)
+	}
+	return entries, nil
+}
+
+func (s *Spool) dataPath(id string) string {
+	return filepath.Join(s.dir, dataDir, id)
+}
+
+func (s *Spool) entryPath(id string) string {
+	return filepath.Join(s.dir, pendingDir, id+".json")
+}
+
+func (s *Spool) removeFiles(id string) {
+	_ = os.Remove(s.dataPath(id))
+	_ = os.Remove(s.entryPath(id))
+}
+
+// Write save reader to local disk, return after data is synced
+func (s *Spool) Write(key string, obj block.ObjectPointer, reader io.Reader) error {
+	e := &entry{
+		ID:               uuid.NewString(),
+		Key:              key,
+		StorageNamespace: obj.StorageNamespace,
+		Identifier:       obj.Identifier,
+		IdentifierType:   obj.IdentifierType,
+		CreatedAt:        time.Now(),
+	}
+
+	size, err := writeFileSync(s.dataPath(e.ID), reader)
+	if err != nil {
+		s.removeFiles(e.ID)
+		return err
+	}
+	e.Size = size
+
+	err = s.saveEntry(e)
+	if err != nil {
+		s.removeFiles(e.ID)
+		return err
+	}
+
+	s.lk.Lock()
+	former, ok := s.pending[key]
+	if ok && !s.flushing[key] {
+		s.removeFiles(former.ID)
+	}
+	s.pending[key] = e
+	s.lk.Unlock()
+
+	s.enqueue(key)
+	return nil
+}
+
+func (s *Spool) saveEntry(e *entry) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	tmpPath := s.entryPath(e.ID) + ".tmp"
+	_, err = writeFileSync(tmpPath, strings.NewReader(string(data)))
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, s.entryPath(e.ID))
+}
+
+func writeFileSync(path string, reader io.Reader) (int64, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close() //nolint
+
+	size, err := io.Copy(f, reader)
+	if err != nil {
+		return 0, err
+	}
+	return size, f.Sync()
+}
+
+// Open return reader of pending upload, ok is false if key is not in spool
+func (s *Spool) Open(key string) (*os.File, bool, error) {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	e, ok := s.pending[key]
+	if !ok {
+		return nil, false, nil
+	}
+	f, err := os.Open(s.dataPath(e.ID))
+	if err != nil {
+		return nil, true, err
+	}
+	return f, true, nil
+}
+
+// Contains check whether key is pending in spool
+func (s *Spool) Contains(key string) bool {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	_, ok := s.pending[key]
+	return ok
+}
+
+// Discard drop pending uploads whose key match, return number of uploads dropped
+func (s *Spool) Discard(match func(key string) bool) int {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	count := 0
+	for key, e := range s.pending {
+		if !match(key) || s.flushing[key] {
+			continue
+		}
+		s.removeFiles(e.ID)
+		delete(s.pending, key)
+		count++
+	}
+	s.cond.Broadcast()
+	return count
+}
+
+func (s *Spool) enqueue(key string) {
+	select {
+	case s.queue <- key:
+	default:
+		// queue is full, enqueue without blocking writer
+		go func() { s.queue <- key }()
+	}
+}
+
+func (s *Spool) worker() {
+	for key := range s.queue {
+		s.lk.Lock()
+		e, ok := s.pending[key]
+		if !ok || s.flushing[key] {
+			s.lk.Unlock()
+			continue
+		}
+		s.flushing[key] = true
+		s.lk.Unlock()
+
+		err := s.upload(context.Background(), e)
+
+		s.lk.Lock()
+		delete(s.flushing, key)
+		current := s.pending[key]
+		switch {
+		case err != nil:
+			s.failures++
+			e.Attempts++
+			e.LastError = err.Error()
+			log.Warnf("flush %s to object store failed %d times %v", key, e.Attempts, err)
+			if current == e {
+				_ = s.saveEntry(e)
+				s.retryLater(key, e.Attempts)
+			} else {
+				s.removeFiles(e.ID)
+			}
+		case current == e:
+			s.flushed++
+			delete(s.pending, key)
+			s.removeFiles(e.ID)
+		default:
+			// replaced by a newer upload while flushing, drop this one and flush the new one
+			s.removeFiles(e.ID)
+			s.enqueue(key)
+		}
+		s.cond.Broadcast()
+		s.lk.Unlock()
+	}
+}
+
+func (s *Spool) upload(ctx context.Context, e *entry) error {
+	f, err := os.Open(s.dataPath(e.ID))
+	if err != nil {
+		return err
+	}
+	defer f.Close() //nolint
+	return s.target.Put(ctx, e.pointer(), e.Size, f, block.PutOpts{})
+}
+
+func (s *Spool) retryLater(key string, attempts int) {
+	backoff := time.Second << (attempts - 1)
+	if backoff > maxBackoff || backoff <= 0 {
+		backoff = maxBackoff
+	}
+	time.AfterFunc(backoff, func() {
+		s.enqueue(key)
+	})
+}
+
+// FlushKey wait until upload of key is flushed to object store, return immediately if key is not pending
+func (s *Spool) FlushKey(ctx context.Context, key string) error {
+	return s.wait(ctx, func() bool {
+		_, ok := s.pending[key]
+		return !ok
+	})
+}
+
+// Flush wait until all pending uploads are flushed to object store
+func (s *Spool) Flush(ctx context.Context) error {
+	return s.wait(ctx, func() bool {
+		return len(s.pending) == 0
+	})
+}
+
+func (s *Spool) wait(ctx context.Context, done func() bool) error {
+	stop := context.AfterFunc(ctx, func() {
+		s.lk.Lock()
+		s.cond.Broadcast()
+		s.lk.Unlock()
+	})
+	defer stop()
+
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	for !done() {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		s.cond.Wait()
+	}
+	return nil
+}
+
+func (s *Spool) Stats() Stats {
+	s.lk.Lock()
+	defer s.lk.Unlock()
+	stats := Stats{
+		Pending:  len(s.pending),
+		Flushed:  s.flushed,
+		Failures: s.failures,
+	}
+	for _, e := range s.pending {
+		stats.PendingBytes += e.Size
+		if stats.OldestPending.IsZero() || e.CreatedAt.Before(stats.OldestPending) {
+			stats.OldestPending = e.CreatedAt
+		}
+	}
+	return stats
+}
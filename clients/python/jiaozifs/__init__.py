@@ -0,0 +1,11 @@
+"""Python client of jiaozifs.
+
+``Client`` wrap the low level client generated from the OpenAPI spec in
+``jiaozifs_api`` with authentication, pagination iterators and upload
+helpers.
+"""
+
+from .client import DEFAULT_HOST, Client
+from .pagination import paginate, paginate_list
+
+__all__ = ["Client", "DEFAULT_HOST", "paginate", "paginate_list"]
@@ -0,0 +1,228 @@
+"""Thin wrapper over the generated jiaozifs_api package."""
+
+import base64
+import json
+import os
+from typing import Any, Dict, Iterator, Optional, Union
+from urllib.parse import quote, urlencode
+
+import urllib3
+
+import jiaozifs_api
+
+from .pagination import DEFAULT_PAGE_SIZE, paginate, paginate_list
+from .upload import Body, read_body, walk_files
+
+DEFAULT_HOST = "http://localhost:34913/api/v1"
+
+
+class Client:
+    """Client of a jiaozifs server.
+
+    Authenticate with a JWT ``token`` or ``username`` and ``password``, the
+    generated APIs are exposed as attributes, e.g. ``client.repo`` or
+    ``client.objects``, for endpoints not wrapped here.
+    """
+
+    def __init__(
+        self,
+        host: str = DEFAULT_HOST,
+        token: Optional[str] = None,
+        username: Optional[str] = None,
+        password: Optional[str] = None,
+    ) -> None:
+        self.host = host.rstrip("/")
+        self._token = token
+        self._username = username
+        self._password = password
+
+        configuration = jiaozifs_api.Configuration(host=self.host)
+        if token:
+            configuration.access_token = token
+        if username:
+            configuration.username = username
+            configuration.password = password
+        self.api_client = jiaozifs_api.ApiClient(configuration)
+        self._http = urllib3.PoolManager()
+
+        self.auth = jiaozifs_api.AuthApi(self.api_client)
+        self.repo = jiaozifs_api.RepoApi(self.api_client)
+        self.branches = jiaozifs_api.BranchesApi(self.api_client)
+        self.tags = jiaozifs_api.TagsApi(self.api_client)
+        self.commit = jiaozifs_api.CommitApi(self.api_client)
+        self.objects = jiaozifs_api.ObjectsApi(self.api_client)
+        self.wip = jiaozifs_api.WipApi(self.api_client)
+        self.merge_requests = jiaozifs_api.MergerequestApi(self.api_client)
+
+    @classmethod
+    def login(cls, name: str, password: str, host: str = DEFAULT_HOST) -> "Client":
+        """Login with user name and password, return client using the issued token."""
+        client = cls(host)
+        resp = client._request(
+            "POST",
+            "/auth/login",
+            body=json.dumps({"name": name, "password": password}).encode(),
+            headers={"Content-Type": "application/json"},
+        )
+        return cls(host, token=json.loads(resp.data)["token"])
+
+    @classmethod
+    def from_env(cls) -> "Client":
+        """Build client from JIAOZIFS_HOST, JIAOZIFS_TOKEN or JIAOZIFS_USERNAME and JIAOZIFS_PASSWORD."""
+        return cls(
+            os.environ.get("JIAOZIFS_HOST", DEFAULT_HOST),
+            token=os.environ.get("JIAOZIFS_TOKEN"),
+            username=os.environ.get("JIAOZIFS_USERNAME"),
+            password=os.environ.get("JIAOZIFS_PASSWORD"),
+        )
+
+    # pagination
+
+    def iter_repositories(self, prefix: Optional[str] = None, page_size: int = DEFAULT_PAGE_SIZE) -> Iterator[Any]:
+        """Iterate repositories of the authenticated user."""
+        return paginate(
+            self.repo.list_repository_of_authenticated_user,
+            offset=int,
+            page_size=page_size,
+            prefix=prefix,
+        )
+
+    def iter_branches(
+        self, owner: str, repository: str, prefix: Optional[str] = None, page_size: int = DEFAULT_PAGE_SIZE
+    ) -> Iterator[Any]:
+        """Iterate branches of repository."""
+        return paginate(
+            self.branches.list_branches,
+            page_size=page_size,
+            owner=owner,
+            repository=repository,
+            prefix=prefix,
+        )
+
+    def iter_commits(
+        self,
+        owner: str,
+        repository: str,
+        ref_name: Optional[str] = None,
+        metadata: Optional[Dict[str, str]] = None,
+        page_size: int = DEFAULT_PAGE_SIZE,
+    ) -> Iterator[Any]:
+        """Iterate commits of ref newest first, optionally only those with all metadata."""
+        return paginate_list(
+            self.repo.get_commits_in_ref,
+            cursor=lambda commit: commit.committer.when,
+            page_size=page_size,
+            owner=owner,
+            repository=repository,
+            ref_name=ref_name,
+            metadata=_metadata_params(metadata),
+        )
+
+    # upload
+
+    def upload(
+        self,
+        owner: str,
+        repository: str,
+        ref_name: str,
+        path: str,
+        body: Body,
+        replace: bool = False,
+    ) -> Any:
+        """Upload body to path in wip of branch, body is bytes, a readable or path of local file."""
+        reader, should_close = read_body(body)
+        try:
+            headers = {"Content-Type": "application/octet-stream"}
+            size = _body_size(reader)
+            if size is not None:
+                headers["Content-Length"] = str(size)
+            resp = self._request(
+                "POST",
+                f"/object/{quote(owner)}/{quote(repository)}",
+                query={"refName": ref_name, "path": path, "isReplace": str(replace).lower()},
+                body=reader,
+                headers=headers,
+            )
+        finally:
+            if should_close:
+                reader.close()
+        return jiaozifs_api.ObjectStats.from_json(resp.data.decode())
+
+    def upload_dir(
+        self,
+        owner: str,
+        repository: str,
+        ref_name: str,
+        local_dir: Union[str, "os.PathLike[str]"],
+        prefix: str = "",
+        replace: bool = False,
+    ) -> Iterator[Any]:
+        """Upload files under local_dir recursively, yield stats of each object uploaded."""
+        for local_path, path in walk_files(local_dir, prefix):
+            yield self.upload(owner, repository, ref_name, path, local_path, replace=replace)
+
+    def commit_wip(
+        self,
+        owner: str,
+        repository: str,
+        ref_name: str,
+        msg: str,
+        metadata: Optional[Dict[str, str]] = None,
+    ) -> Any:
+        """Commit uploaded changes of wip to branch."""
+        return self.wip.commit_wip(
+            owner=owner,
+            repository=repository,
+            ref_name=ref_name,
+            msg=msg,
+            metadata=_metadata_params(metadata),
+        )
+
+    def _auth_headers(self) -> Dict[str, str]:
+        if self._token:
+            return {"Authorization": f"Bearer {self._token}"}
+        if self._username:
+            credential = f"{self._username}:{self._password or ''}".encode()
+            return {"Authorization": "Basic " + base64.b64encode(credential).decode()}
+        return {}
+
+    def _request(
+        self,
+        method: str,
+        path: str,
+        query: Optional[Dict[str, str]] = None,
+        body: Any = None,
+        headers: Optional[Dict[str, str]] = None,
+    ) -> Any:
+        url = self.host + path
+        if query:
+            url += "?" + urlencode(query)
+        resp = self._http.request(
+            method,
+            url,
+            body=body,
+            headers={**self._auth_headers(), **(headers or {})},
+            preload_content=True,
+        )
+        if resp.status >= 300:
+            exc = jiaozifs_api.ApiException(status=resp.status, reason=resp.reason)
+            exc.body = resp.data.decode(errors="replace")
+            raise exc
+        return resp
+
+
+def _metadata_params(metadata: Optional[Dict[str, str]]) -> Optional[list]:
+    if not metadata:
+        return None
+    return [f"{k}={v}" for k, v in metadata.items()]
+
+
+def _body_size(reader: Any) -> Optional[int]:
+    try:
+        return os.fstat(reader.fileno()).st_size - reader.tell()
+    except (AttributeError, OSError, ValueError):
+        pass
+    try:
+        return len(reader.getbuffer()) - reader.tell()
+    except (AttributeError, ValueError):
+        return None
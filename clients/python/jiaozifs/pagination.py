@@ -0,0 +1,49 @@
+"""Iterate over list endpoints page by page."""
+
+from typing import Any, Callable, Iterator, Optional, TypeVar
+
+T = TypeVar("T")
+
+DEFAULT_PAGE_SIZE = 100
+
+
+def paginate(
+    fetch: Callable[..., Any],
+    offset: Callable[[str], Any] = str,
+    page_size: int = DEFAULT_PAGE_SIZE,
+    **kwargs: Any,
+) -> Iterator[Any]:
+    """Yield results of an endpoint returning ``{pagination, results}``.
+
+    ``fetch`` is called with ``after`` and ``amount`` besides ``kwargs`` until
+    ``pagination.has_more`` is false. ``offset`` converts ``next_offset`` of
+    the response to the type of ``after`` parameter, use ``int`` for endpoints
+    paginated by time.
+    """
+    after: Optional[Any] = None
+    while True:
+        page = fetch(after=after, amount=page_size, **kwargs)
+        yield from page.results
+        if not page.pagination.has_more or not page.pagination.next_offset:
+            return
+        after = offset(page.pagination.next_offset)
+
+
+def paginate_list(
+    fetch: Callable[..., Any],
+    cursor: Callable[[T], Any],
+    page_size: int = DEFAULT_PAGE_SIZE,
+    **kwargs: Any,
+) -> Iterator[T]:
+    """Yield items of an endpoint returning a plain list, like commits.
+
+    ``cursor`` return ``after`` of the next page from the last item, the last
+    page is the one shorter than ``page_size``.
+    """
+    after: Optional[Any] = None
+    while True:
+        items = fetch(after=after, amount=page_size, **kwargs)
+        yield from items
+        if len(items) < page_size:
+            return
+        after = cursor(items[-1])
@@ -0,0 +1,33 @@
+"""Upload helpers streaming local files as object content."""
+
+import io
+import os
+from typing import IO, Iterator, Tuple, Union
+
+Body = Union[bytes, str, "os.PathLike[str]", IO[bytes]]
+
+
+def read_body(body: Body) -> Tuple[IO[bytes], bool]:
+    """Return readable of body and whether caller has to close it.
+
+    ``str`` and path like values are treated as path of local file.
+    """
+    if isinstance(body, bytes):
+        return io.BytesIO(body), True
+    if isinstance(body, (str, os.PathLike)):
+        return open(body, "rb"), True
+    return body, False
+
+
+def walk_files(local_dir: Union[str, "os.PathLike[str]"], prefix: str = "") -> Iterator[Tuple[str, str]]:
+    """Yield ``(local path, object path)`` of files under local_dir.
+
+    object path is relative to local_dir with ``/`` separator and prefix
+    prepended.
+    """
+    prefix = prefix.strip("/")
+    for root, _, files in os.walk(local_dir):
+        for name in sorted(files):
+            local_path = os.path.join(root, name)
+            rel = os.path.relpath(local_path, local_dir).replace(os.sep, "/")
+            yield local_path, f"{prefix}/{rel}" if prefix else rel
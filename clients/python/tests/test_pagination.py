@@ -0,0 +1,87 @@
+import os
+import tempfile
+import unittest
+from types import SimpleNamespace
+
+from jiaozifs.pagination import paginate, paginate_list
+from jiaozifs.upload import read_body, walk_files
+
+
+def fake_list(items, has_more_after=None):
+    """Return fetch of endpoint responding {pagination, results} over items."""
+    calls = []
+
+    def fetch(after=None, amount=None, **kwargs):
+        calls.append((after, amount, kwargs))
+        start = 0 if after is None else after
+        page = items[start : start + amount]
+        has_more = start + amount < len(items)
+        return SimpleNamespace(
+            results=page,
+            pagination=SimpleNamespace(has_more=has_more, next_offset=str(start + amount) if has_more else ""),
+        )
+
+    return fetch, calls
+
+
+class PaginateTest(unittest.TestCase):
+    def test_paginate(self):
+        fetch, calls = fake_list(list(range(5)))
+        self.assertEqual(list(paginate(fetch, offset=int, page_size=2, owner="jimmy")), [0, 1, 2, 3, 4])
+        self.assertEqual(
+            calls,
+            [(None, 2, {"owner": "jimmy"}), (2, 2, {"owner": "jimmy"}), (4, 2, {"owner": "jimmy"})],
+        )
+
+    def test_paginate_empty(self):
+        fetch, calls = fake_list([])
+        self.assertEqual(list(paginate(fetch)), [])
+        self.assertEqual(len(calls), 1)
+
+    def test_paginate_list(self):
+        commits = [SimpleNamespace(when=when) for when in (50, 40, 30, 20, 10)]
+
+        def fetch(after=None, amount=None):
+            page = [c for c in commits if after is None or c.when < after]
+            return page[:amount]
+
+        result = paginate_list(fetch, cursor=lambda c: c.when, page_size=2)
+        self.assertEqual([c.when for c in result], [50, 40, 30, 20, 10])
+
+
+class UploadTest(unittest.TestCase):
+    def test_read_body(self):
+        reader, should_close = read_body(b"abc")
+        self.assertTrue(should_close)
+        self.assertEqual(reader.read(), b"abc")
+
+        with tempfile.NamedTemporaryFile(delete=False) as f:
+            f.write(b"file")
+        try:
+            reader, should_close = read_body(f.name)
+            self.assertTrue(should_close)
+            self.assertEqual(reader.read(), b"file")
+            reader.close()
+
+            with open(f.name, "rb") as opened:
+                reader, should_close = read_body(opened)
+                self.assertFalse(should_close)
+                self.assertIs(reader, opened)
+        finally:
+            os.remove(f.name)
+
+    def test_walk_files(self):
+        with tempfile.TemporaryDirectory() as d:
+            os.makedirs(os.path.join(d, "b"))
+            for name in ("a.txt", os.path.join("b", "c.txt")):
+                with open(os.path.join(d, name), "w") as f:
+                    f.write(name)
+            self.assertEqual(
+                sorted(path for _, path in walk_files(d, "/data/")),
+                ["data/a.txt", "data/b/c.txt"],
+            )
+            self.assertEqual(sorted(path for _, path in walk_files(d)), ["a.txt", "b/c.txt"])
+
+
+if __name__ == "__main__":
+    unittest.main()
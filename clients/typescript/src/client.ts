@@ -0,0 +1,163 @@
+import {
+  AuthApi,
+  BranchesApi,
+  CommitApi,
+  Configuration,
+  MergerequestApi,
+  ObjectsApi,
+  ObjectStatsFromJSON,
+  RepoApi,
+  ResponseError,
+  TagsApi,
+  WipApi,
+} from "./generated";
+import type { Branch, Commit, ObjectStats, Repository, Wip } from "./generated";
+import { DEFAULT_PAGE_SIZE, paginate, paginateList } from "./pagination";
+
+export const DEFAULT_BASE_PATH = "http://localhost:34913/api/v1";
+
+export interface ClientOptions {
+  basePath?: string;
+  /** JWT token issued by login */
+  token?: string;
+  username?: string;
+  password?: string;
+  fetchApi?: typeof fetch;
+}
+
+export interface UploadOptions {
+  replace?: boolean;
+}
+
+/**
+ * Client of a jiaozifs server. the generated APIs are exposed as fields, e.g. client.repo or client.objects,
+ * for endpoints not wrapped here.
+ */
+export class Client {
+  readonly basePath: string;
+  readonly configuration: Configuration;
+  readonly auth: AuthApi;
+  readonly repo: RepoApi;
+  readonly branches: BranchesApi;
+  readonly tags: TagsApi;
+  readonly commit: CommitApi;
+  readonly objects: ObjectsApi;
+  readonly wip: WipApi;
+  readonly mergeRequests: MergerequestApi;
+
+  private readonly options: ClientOptions;
+
+  constructor(options: ClientOptions = {}) {
+    this.options = options;
+    this.basePath = (options.basePath ?? DEFAULT_BASE_PATH).replace(/\/+$/, "");
+    this.configuration = new Configuration({
+      basePath: this.basePath,
+      accessToken: options.token,
+      username: options.token ? undefined : options.username,
+      password: options.token ? undefined : options.password,
+      fetchApi: options.fetchApi,
+    });
+    this.auth = new AuthApi(this.configuration);
+    this.repo = new RepoApi(this.configuration);
+    this.branches = new BranchesApi(this.configuration);
+    this.tags = new TagsApi(this.configuration);
+    this.commit = new CommitApi(this.configuration);
+    this.objects = new ObjectsApi(this.configuration);
+    this.wip = new WipApi(this.configuration);
+    this.mergeRequests = new MergerequestApi(this.configuration);
+  }
+
+  /** login with user name and password, return client using the issued token */
+  static async login(name: string, password: string, options: ClientOptions = {}): Promise<Client> {
+    const client = new Client(options);
+    const resp = await client.request("POST", "/auth/login", {
+      body: JSON.stringify({ name, password }),
+      headers: { "Content-Type": "application/json" },
+    });
+    const { token } = await resp.json();
+    return new Client({ ...options, token });
+  }
+
+  /** iterate repositories of the authenticated user */
+  iterRepositories(prefix?: string, pageSize: number = DEFAULT_PAGE_SIZE): AsyncGenerator<Repository> {
+    return paginate(
+      (page) => this.repo.listRepositoryOfAuthenticatedUser({ prefix, ...page }),
+      Number,
+      pageSize,
+    );
+  }
+
+  /** iterate branches of repository */
+  iterBranches(owner: string, repository: string, prefix?: string, pageSize: number = DEFAULT_PAGE_SIZE): AsyncGenerator<Branch> {
+    return paginate((page) => this.branches.listBranches({ owner, repository, prefix, ...page }), undefined, pageSize);
+  }
+
+  /** iterate commits of ref newest first, optionally only those with all metadata */
+  iterCommits(
+    owner: string,
+    repository: string,
+    refName?: string,
+    metadata?: Record<string, string>,
+    pageSize: number = DEFAULT_PAGE_SIZE,
+  ): AsyncGenerator<Commit> {
+    return paginateList(
+      (page) => this.repo.getCommitsInRef({ owner, repository, refName, metadata: metadataParams(metadata), ...page }),
+      (commit) => commit.committer.when,
+      pageSize,
+    );
+  }
+
+  /** upload body to path in wip of branch */
+  async upload(
+    owner: string,
+    repository: string,
+    refName: string,
+    path: string,
+    body: BodyInit,
+    options: UploadOptions = {},
+  ): Promise<ObjectStats> {
+    const query = new URLSearchParams({ refName, path, isReplace: String(options.replace ?? false) });
+    const resp = await this.request("POST", `/object/${encodeURIComponent(owner)}/${encodeURIComponent(repository)}?${query}`, {
+      body,
+      headers: { "Content-Type": "application/octet-stream" },
+      // required by fetch of node to send stream body
+      duplex: "half",
+    } as RequestInit);
+    return ObjectStatsFromJSON(await resp.json());
+  }
+
+  /** commit uploaded changes of wip to branch */
+  commitWip(owner: string, repository: string, refName: string, msg: string, metadata?: Record<string, string>): Promise<Wip> {
+    return this.wip.commitWip({ owner, repository, refName, msg, metadata: metadataParams(metadata) });
+  }
+
+  private authHeaders(): Record<string, string> {
+    if (this.options.token) {
+      return { Authorization: `Bearer ${this.options.token}` };
+    }
+    if (this.options.username) {
+      return { Authorization: `Basic ${btoa(`${this.options.username}:${this.options.password ?? ""}`)}` };
+    }
+    return {};
+  }
+
+  private async request(method: string, path: string, init: RequestInit): Promise<Response> {
+    const fetchApi = this.options.fetchApi ?? fetch;
+    const resp = await fetchApi(this.basePath + path, {
+      ...init,
+      method,
+      headers: { ...this.authHeaders(), ...(init.headers as Record<string, string>) },
+    });
+    if (resp.status >= 300) {
+      throw new ResponseError(resp, `${method} ${path} failed with ${resp.status}: ${await resp.text()}`);
+    }
+    return resp;
+  }
+}
+
+function metadataParams(metadata?: Record<string, string>): Array<string> | undefined {
+  if (!metadata) {
+    return undefined;
+  }
+  return Object.entries(metadata).map(([k, v]) => `${k}=${v}`);
+}
@@ -0,0 +1,5 @@
+export * from "./generated";
+export { Client, DEFAULT_BASE_PATH } from "./client";
+export type { ClientOptions, UploadOptions } from "./client";
+export { DEFAULT_PAGE_SIZE, paginate, paginateList } from "./pagination";
+export type { Page, PageParams } from "./pagination";
@@ -0,0 +1,52 @@
+export const DEFAULT_PAGE_SIZE = 100;
+
+export interface Page<T> {
+  pagination: { hasMore: boolean; nextOffset: string };
+  results: Array<T>;
+}
+
+export interface PageParams<A> {
+  after?: A;
+  amount?: number;
+}
+
+/**
+ * paginate yield results of an endpoint returning {pagination, results} until pagination.hasMore is false.
+ * offset converts nextOffset of the response to the type of after parameter, use Number for endpoints
+ * paginated by time.
+ */
+export async function* paginate<T, A = string>(
+  fetchPage: (params: PageParams<A>) => Promise<Page<T>>,
+  offset: (nextOffset: string) => A = (nextOffset) => nextOffset as unknown as A,
+  pageSize: number = DEFAULT_PAGE_SIZE,
+): AsyncGenerator<T> {
+  let after: A | undefined;
+  for (;;) {
+    const page = await fetchPage({ after, amount: pageSize });
+    yield* page.results;
+    if (!page.pagination.hasMore || !page.pagination.nextOffset) {
+      return;
+    }
+    after = offset(page.pagination.nextOffset);
+  }
+}
+
+/**
+ * paginateList yield items of an endpoint returning a plain list, like commits. cursor return after of the
+ * next page from the last item, the last page is the one shorter than pageSize.
+ */
+export async function* paginateList<T, A>(
+  fetchPage: (params: PageParams<A>) => Promise<Array<T>>,
+  cursor: (last: T) => A,
+  pageSize: number = DEFAULT_PAGE_SIZE,
+): AsyncGenerator<T> {
+  let after: A | undefined;
+  for (;;) {
+    const items = await fetchPage({ after, amount: pageSize });
+    yield* items;
+    if (items.length < pageSize) {
+      return;
+    }
+    after = cursor(items[items.length - 1]);
+  }
+}
@@ -0,0 +1,400 @@
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/spf13/cobra"
+)
+
+const (
+	batchOpUpload = "upload"
+	batchOpDelete = "delete"
+	batchOpCopy   = "copy"
+	batchOpCommit = "commit"
+)
+
+// batchOp one operation read from stdin, owner/repo/ref fallback to flags when empty
+type batchOp struct {
+	ID    string `json:"id,omitempty"`
+	Op    string `json:"op"`
+	Owner string `json:"owner,omitempty"`
+	Repo  string `json:"repo,omitempty"`
+	Ref   string `json:"ref,omitempty"`
+	Path  string `json:"path,omitempty"`
+	// File local file to upload
+	File    string `json:"file,omitempty"`
+	Replace bool   `json:"replace,omitempty"`
+	// SrcRef SrcRefType SrcPath object to copy, object in same repository is copied from wip of ref by default
+	SrcRef     string `json:"src_ref,omitempty"`
+	SrcRefType string `json:"src_ref_type,omitempty"`
+	SrcPath    string `json:"src_path,omitempty"`
+	// Msg Metadata used by commit
+	Msg      string   `json:"msg,omitempty"`
+	Metadata []string `json:"metadata,omitempty"`
+
+	line int
+}
+
+func (op *batchOp) target() string {
+	return op.Owner + "/" + op.Repo + "@" + op.Ref
+}
+
+func (op *batchOp) validate() error {
+	if len(op.Owner) == 0 || len(op.Repo) == 0 || len(op.Ref) == 0 {
+		return errors.New("owner, repo and ref must be set")
+	}
+	switch op.Op {
+	case batchOpUpload:
+		if len(op.Path) == 0 || len(op.File) == 0 {
+			return errors.New("path and file must be set for upload")
+		}
+	case batchOpDelete:
+		if len(op.Path) == 0 {
+			return errors.New("path must be set for delete")
+		}
+	case batchOpCopy:
+		if len(op.Path) == 0 || len(op.SrcPath) == 0 {
+			return errors.New("path and src_path must be set for copy")
+		}
+	case batchOpCommit:
+	default:
+		return fmt.Errorf("unknown op %q", op.Op)
+	}
+	return nil
+}
+
+// batchResult written to stdout as one json line for each operation
+type batchResult struct {
+	Line     int    `json:"line"`
+	ID       string `json:"id,omitempty"`
+	Op       string `json:"op"`
+	Target   string `json:"target,omitempty"`
+	Path     string `json:"path,omitempty"`
+	OK       bool   `json:"ok"`
+	Skipped  bool   `json:"skipped,omitempty"`
+	Error    string `json:"error,omitempty"`
+	Commit   string `json:"commit,omitempty"`
+	Duration int64  `json:"duration_ms"`
+}
+
+type batchSummary struct {
+	Total     int   `json:"total"`
+	Succeeded int   `json:"succeeded"`
+	Failed    int   `json:"failed"`
+	Skipped   int   `json:"skipped"`
+	Failures  []int `json:"failures,omitempty"`
+	Duration  int64 `json:"duration_ms"`
+}
+
+var batchCmd = &cobra.Command{
+	Use:   "batch",
+	Short: "run newline-delimited json operations (upload, delete, copy, commit) read from stdin",
+	Long: `run newline-delimited json operations read from stdin, for example:
+
+  {"op":"upload","path":"data/a.csv","file":"./a.csv","replace":true}
+  {"op":"copy","path":"data/b.csv","src_ref":"main","src_ref_type":"branch","src_path":"raw/b.csv"}
+  {"op":"delete","path":"data/old.csv"}
+  {"op":"commit","msg":"daily load"}
+
+owner, repo and ref of operation fallback to flags. operations of the same owner/repo/ref are run in order,
+operations of different ones are run in parallel. a result line is written to stdout for every operation
+and a summary line at last`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		client, err := GetClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		owner, err := cmd.Flags().GetString("owner")
+		if err != nil {
+			return err
+		}
+		repo, err := cmd.Flags().GetString("repo")
+		if err != nil {
+			return err
+		}
+		refName, err := cmd.Flags().GetString("ref-name")
+		if err != nil {
+			return err
+		}
+
+		parallel, err := cmd.Flags().GetInt("parallel")
+		if err != nil {
+			return err
+		}
+		if parallel <= 0 {
+			parallel = 1
+		}
+
+		continueOnError, err := cmd.Flags().GetBool("continue-on-error")
+		if err != nil {
+			return err
+		}
+
+		runner := &batchRunner{
+			client:          client,
+			continueOnError: continueOnError,
+			sem:             make(chan struct{}, parallel),
+			queues:          make(map[string]chan *batchOp),
+			results:         make(chan *batchResult, parallel),
+		}
+
+		summary := runner.Run(cmd.Context(), cmd.InOrStdin(), cmd.OutOrStdout(), func(op *batchOp) {
+			if len(op.Owner) == 0 {
+				op.Owner = owner
+			}
+			if len(op.Repo) == 0 {
+				op.Repo = repo
+			}
+			if len(op.Ref) == 0 {
+				op.Ref = refName
+			}
+		})
+		if summary.Failed > 0 {
+			return fmt.Errorf("%d of %d operations failed", summary.Failed, summary.Total)
+		}
+		return nil
+	},
+}
+
+// batchRunner run operations of each owner/repo/ref in a queue, at most cap(sem) operations are running at the same time.
+// wip tree is updated by read-modify-write on server, so operations of one wip must not run concurrently
+type batchRunner struct {
+	client          *api.Client
+	continueOnError bool
+	sem             chan struct{}
+
+	queues  map[string]chan *batchOp
+	wg      sync.WaitGroup
+	results chan *batchResult
+}
+
+func (runner *batchRunner) Run(ctx context.Context, in io.Reader, out io.Writer, fillDefault func(*batchOp)) *batchSummary {
+	start := time.Now()
+	summary := &batchSummary{}
+	encoder := json.NewEncoder(out)
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for result := range runner.results {
+			summary.Total++
+			switch {
+			case result.OK:
+				summary.Succeeded++
+			case result.Skipped:
+				summary.Skipped++
+			default:
+				summary.Failed++
+				summary.Failures = append(summary.Failures, result.Line)
+			}
+			_ = encoder.Encode(result)
+		}
+	}()
+
+	scanner := bufio.NewScanner(in)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		content := scanner.Bytes()
+		if len(bytes.TrimSpace(content)) == 0 {
+			continue
+		}
+
+		op := &batchOp{}
+		if err := json.Unmarshal(content, op); err != nil {
+			runner.results <- &batchResult{Line: line, Error: fmt.Sprintf("invalid operation %v", err)}
+			continue
+		}
+		op.line = line
+		fillDefault(op)
+		if err := op.validate(); err != nil {
+			runner.results <- &batchResult{Line: line, ID: op.ID, Op: op.Op, Path: op.Path, Error: err.Error()}
+			continue
+		}
+		runner.enqueue(ctx, op)
+	}
+	if err := scanner.Err(); err != nil {
+		runner.results <- &batchResult{Line: line + 1, Error: fmt.Sprintf("read stdin %v", err)}
+	}
+
+	for _, queue := range runner.queues {
+		close(queue)
+	}
+	runner.wg.Wait()
+	close(runner.results)
+	<-done
+
+	summary.Duration = time.Since(start).Milliseconds()
+	_ = encoder.Encode(map[string]*batchSummary{"summary": summary})
+	return summary
+}
+
+func (runner *batchRunner) enqueue(ctx context.Context, op *batchOp) {
+	target := op.target()
+	queue, ok := runner.queues[target]
+	if !ok {
+		queue = make(chan *batchOp, 64)
+		runner.queues[target] = queue
+		runner.wg.Add(1)
+		go runner.runQueue(ctx, queue)
+	}
+	queue <- op
+}
+
+func (runner *batchRunner) runQueue(ctx context.Context, queue chan *batchOp) {
+	defer runner.wg.Done()
+
+	var failed error
+	wipReady := false
+	for op := range queue {
+		result := &batchResult{Line: op.line, ID: op.ID, Op: op.Op, Target: op.target(), Path: op.Path}
+		if failed != nil {
+			result.Skipped = true
+			result.Error = fmt.Sprintf("skipped after failure of previous operation: %v", failed)
+			runner.results <- result
+			continue
+		}
+
+		runner.sem <- struct{}{}
+		start := time.Now()
+		var err error
+		if !wipReady {
+			//make sure wip exit
+			err = runner.prepareWip(ctx, op)
+			wipReady = err == nil
+		}
+		if err == nil {
+			result.Commit, err = runner.exec(ctx, op)
+		}
+		<-runner.sem
+
+		result.Duration = time.Since(start).Milliseconds()
+		if err != nil {
+			result.Error = err.Error()
+			if !runner.continueOnError {
+				failed = err
+			}
+		} else {
+			result.OK = true
+		}
+		runner.results <- result
+	}
+}
+
+func (runner *batchRunner) prepareWip(ctx context.Context, op *batchOp) error {
+	resp, err := runner.client.GetWip(ctx, op.Owner, op.Repo, &api.GetWipParams{RefName: op.Ref})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusCreated {
+		return fmt.Errorf("get wip of %s failed %d, %s", op.target(), resp.StatusCode, tryLogError(resp))
+	}
+	return nil
+}
+
+func (runner *batchRunner) exec(ctx context.Context, op *batchOp) (string, error) {
+	switch op.Op {
+	case batchOpUpload:
+		fs, err := os.Open(op.File)
+		if err != nil {
+			return "", err
+		}
+		defer fs.Close() //nolint
+		return "", runner.upload(ctx, op, fs)
+	case batchOpDelete:
+		resp, err := runner.client.DeleteObject(ctx, op.Owner, op.Repo, &api.DeleteObjectParams{
+			RefName: op.Ref,
+			Path:    op.Path,
+		})
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close() //nolint
+		if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusNoContent {
+			return "", fmt.Errorf("delete %s failed %d, %s", op.Path, resp.StatusCode, tryLogError(resp))
+		}
+		return "", nil
+	case batchOpCopy:
+		srcRef, refType := op.Ref, api.RefTypeWip
+		if len(op.SrcRef) > 0 {
+			srcRef, refType = op.SrcRef, api.RefTypeBranch
+		}
+		if len(op.SrcRefType) > 0 {
+			refType = api.RefType(op.SrcRefType)
+		}
+		resp, err := runner.client.GetObject(ctx, op.Owner, op.Repo, &api.GetObjectParams{
+			Type:    refType,
+			RefName: srcRef,
+			Path:    op.SrcPath,
+		})
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close() //nolint
+		if resp.StatusCode != http.StatusOK {
+			return "", fmt.Errorf("read %s failed %d, %s", op.SrcPath, resp.StatusCode, tryLogError(resp))
+		}
+		return "", runner.upload(ctx, op, resp.Body)
+	case batchOpCommit:
+		params := &api.CommitWipParams{
+			Msg:     op.Msg,
+			RefName: op.Ref,
+		}
+		if len(op.Metadata) > 0 {
+			params.Metadata = &op.Metadata
+		}
+		resp, err := runner.client.CommitWip(ctx, op.Owner, op.Repo, params)
+		if err != nil {
+			return "", err
+		}
+		result, err := api.ParseCommitWipResponse(resp)
+		if err != nil {
+			return "", err
+		}
+		if result.StatusCode() != http.StatusCreated || result.JSON201 == nil {
+			return "", fmt.Errorf("commit %s failed %d, %s", op.target(), result.StatusCode(), string(result.Body))
+		}
+		return result.JSON201.BaseCommit, nil
+	}
+	return "", fmt.Errorf("unknown op %q", op.Op)
+}
+
+func (runner *batchRunner) upload(ctx context.Context, op *batchOp, body io.Reader) error {
+	resp, err := runner.client.UploadObjectWithBody(ctx, op.Owner, op.Repo, &api.UploadObjectParams{
+		RefName:   op.Ref,
+		Path:      op.Path,
+		IsReplace: utils.Bool(op.Replace),
+	}, "application/octet-stream", body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint
+	if resp.StatusCode != http.StatusCreated && resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("upload %s failed %d, %s", op.Path, resp.StatusCode, tryLogError(resp))
+	}
+	return nil
+}
+
+func init() {
+	rootCmd.AddCommand(batchCmd)
+	batchCmd.Flags().String("owner", "", "default owner of operations")
+	batchCmd.Flags().String("repo", "", "default repo of operations")
+	batchCmd.Flags().String("ref-name", "main", "default branch of operations")
+	batchCmd.Flags().Int("parallel", 4, "number of operations run at the same time, operations of the same branch are run in order")
+	batchCmd.Flags().Bool("continue-on-error", false, "keep running operations of branch after one of them failed")
+}
@@ -0,0 +1,187 @@
+package cmd
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBatchOpValidate(t *testing.T) {
+	cases := []struct {
+		name string
+		op   batchOp
+		err  string
+	}{
+		{name: "missing ref", op: batchOp{Op: batchOpCommit, Owner: "o", Repo: "r"}, err: "owner, repo and ref must be set"},
+		{name: "missing repo", op: batchOp{Op: batchOpCommit, Owner: "o", Ref: "main"}, err: "owner, repo and ref must be set"},
+		{name: "upload", op: batchOp{Op: batchOpUpload, Owner: "o", Repo: "r", Ref: "main", Path: "a.txt", File: "./a.txt"}},
+		{name: "upload without file", op: batchOp{Op: batchOpUpload, Owner: "o", Repo: "r", Ref: "main", Path: "a.txt"}, err: "path and file must be set for upload"},
+		{name: "upload without path", op: batchOp{Op: batchOpUpload, Owner: "o", Repo: "r", Ref: "main", File: "./a.txt"}, err: "path and file must be set for upload"},
+		{name: "delete", op: batchOp{Op: batchOpDelete, Owner: "o", Repo: "r", Ref: "main", Path: "a.txt"}},
+		{name: "delete without path", op: batchOp{Op: batchOpDelete, Owner: "o", Repo: "r", Ref: "main"}, err: "path must be set for delete"},
+		{name: "copy", op: batchOp{Op: batchOpCopy, Owner: "o", Repo: "r", Ref: "main", Path: "b.txt", SrcPath: "a.txt"}},
+		{name: "copy without src path", op: batchOp{Op: batchOpCopy, Owner: "o", Repo: "r", Ref: "main", Path: "b.txt"}, err: "path and src_path must be set for copy"},
+		{name: "commit", op: batchOp{Op: batchOpCommit, Owner: "o", Repo: "r", Ref: "main"}},
+		{name: "unknown op", op: batchOp{Op: "move", Owner: "o", Repo: "r", Ref: "main"}, err: `unknown op "move"`},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			err := c.op.validate()
+			if len(c.err) == 0 {
+				require.NoError(t, err)
+				return
+			}
+			require.EqualError(t, err, c.err)
+		})
+	}
+}
+
+// newBatchServer fake api server, paths containing "fail" are rejected and repository "nowip" has no wip
+func newBatchServer() *httptest.Server {
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := r.URL.Query().Get("path")
+		switch {
+		case strings.HasPrefix(r.URL.Path, "/api/v1/wip/o/nowip"):
+			http.Error(w, "wip not found", http.StatusNotFound)
+		case strings.HasSuffix(r.URL.Path, "/commit"):
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"base_commit":"c0ffee"}`))
+		case strings.HasPrefix(r.URL.Path, "/api/v1/wip/"):
+			w.WriteHeader(http.StatusOK)
+		case strings.Contains(path, "fail"):
+			http.Error(w, "rejected "+path, http.StatusConflict)
+		case r.Method == http.MethodGet:
+			_, _ = w.Write([]byte("content"))
+		case r.Method == http.MethodPost:
+			w.WriteHeader(http.StatusCreated)
+		default:
+			w.WriteHeader(http.StatusOK)
+		}
+	}))
+}
+
+func runBatch(t *testing.T, client *api.Client, continueOnError bool, lines ...string) ([]batchResult, *batchSummary) {
+	runner := &batchRunner{
+		client:          client,
+		continueOnError: continueOnError,
+		sem:             make(chan struct{}, 2),
+		queues:          make(map[string]chan *batchOp),
+		results:         make(chan *batchResult, 2),
+	}
+	out := &bytes.Buffer{}
+	summary := runner.Run(context.Background(), strings.NewReader(strings.Join(lines, "\n")), out, func(op *batchOp) {
+		if len(op.Owner) == 0 {
+			op.Owner = "o"
+		}
+		if len(op.Repo) == 0 {
+			op.Repo = "r"
+		}
+		if len(op.Ref) == 0 {
+			op.Ref = "main"
+		}
+	})
+
+	outLines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	results := make([]batchResult, len(outLines)-1)
+	for i, line := range outLines[:len(outLines)-1] {
+		require.NoError(t, json.Unmarshal([]byte(line), &results[i]))
+	}
+	require.Contains(t, outLines[len(outLines)-1], `"summary"`)
+	return results, summary
+}
+
+func resultOfLine(results []batchResult, line int) batchResult {
+	for _, result := range results {
+		if result.Line == line {
+			return result
+		}
+	}
+	return batchResult{}
+}
+
+func TestBatchRunner(t *testing.T) {
+	server := newBatchServer()
+	defer server.Close()
+	client, err := api.NewClient(server.URL + "/api/v1")
+	require.NoError(t, err)
+
+	file := filepath.Join(t.TempDir(), "a.txt")
+	require.NoError(t, os.WriteFile(file, []byte("a"), 0644))
+
+	t.Run("all succeed", func(t *testing.T) {
+		results, summary := runBatch(t, client, false,
+			`{"op":"upload","path":"a.txt","file":"`+file+`"}`,
+			``,
+			`{"op":"copy","path":"b.txt","src_ref":"main","src_path":"a.txt"}`,
+			`{"op":"delete","path":"c.txt"}`,
+			`{"op":"commit","msg":"load"}`,
+		)
+		require.Equal(t, 4, summary.Total)
+		require.Equal(t, 4, summary.Succeeded)
+		require.Empty(t, summary.Failures)
+		require.Equal(t, "c0ffee", resultOfLine(results, 5).Commit)
+		require.Equal(t, "o/r@main", resultOfLine(results, 1).Target)
+	})
+
+	t.Run("invalid lines", func(t *testing.T) {
+		results, summary := runBatch(t, client, false,
+			`{"op":"upload"`,
+			`{"op":"delete"}`,
+			`{"op":"move","path":"a.txt"}`,
+		)
+		require.Equal(t, 3, summary.Failed)
+		require.Equal(t, []int{1, 2, 3}, summary.Failures)
+		require.Contains(t, resultOfLine(results, 1).Error, "invalid operation")
+		require.Equal(t, "path must be set for delete", resultOfLine(results, 2).Error)
+		require.Equal(t, `unknown op "move"`, resultOfLine(results, 3).Error)
+	})
+
+	t.Run("failure skip rest of branch", func(t *testing.T) {
+		results, summary := runBatch(t, client, false,
+			`{"op":"upload","path":"a.txt","file":"`+filepath.Join(t.TempDir(), "missing.txt")+`"}`,
+			`{"op":"delete","path":"c.txt"}`,
+			`{"op":"delete","path":"c.txt","ref":"dev"}`,
+		)
+		require.Equal(t, 1, summary.Failed)
+		require.Equal(t, 1, summary.Skipped)
+		require.Equal(t, 1, summary.Succeeded)
+		require.Contains(t, resultOfLine(results, 1).Error, "no such file")
+		require.True(t, resultOfLine(results, 2).Skipped)
+		require.True(t, resultOfLine(results, 3).OK)
+	})
+
+	t.Run("continue on error", func(t *testing.T) {
+		results, summary := runBatch(t, client, true,
+			`{"op":"delete","path":"fail.txt"}`,
+			`{"op":"copy","path":"b.txt","src_path":"fail.txt"}`,
+			`{"op":"upload","path":"fail.txt","file":"`+file+`"}`,
+			`{"op":"delete","path":"c.txt"}`,
+		)
+		require.Equal(t, 3, summary.Failed)
+		require.Equal(t, 1, summary.Succeeded)
+		require.Equal(t, []int{1, 2, 3}, summary.Failures)
+		require.Contains(t, resultOfLine(results, 1).Error, "delete fail.txt failed 409")
+		require.Contains(t, resultOfLine(results, 2).Error, "read fail.txt failed 409")
+		require.Contains(t, resultOfLine(results, 3).Error, "upload fail.txt failed 409")
+	})
+
+	t.Run("wip not ready", func(t *testing.T) {
+		results, summary := runBatch(t, client, false,
+			`{"op":"delete","path":"c.txt","repo":"nowip"}`,
+			`{"op":"commit","repo":"nowip"}`,
+		)
+		require.Equal(t, 1, summary.Failed)
+		require.Equal(t, 1, summary.Skipped)
+		require.Contains(t, resultOfLine(results, 1).Error, "get wip of o/nowip@main failed 404")
+	})
+}
@@ -2,6 +2,7 @@ package cmd
 
 import (
 	"context"
+	"encoding/hex"
 
 	"github.com/GitDataAI/jiaozifs/auth/rbac"
 
@@ -18,11 +19,14 @@ import (
 	"github.com/GitDataAI/jiaozifs/models"
 	"github.com/GitDataAI/jiaozifs/models/migrations"
 	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/ratelimit"
 	"github.com/GitDataAI/jiaozifs/version"
+	"github.com/GitDataAI/jiaozifs/versionmgr"
 	"github.com/gorilla/sessions"
 	logging "github.com/ipfs/go-log/v2"
 	"github.com/spf13/cobra"
 	"github.com/uptrace/bun"
+	"go.uber.org/fx"
 )
 
 var log = logging.Logger("main")
@@ -49,50 +53,172 @@ var daemonCmd = &cobra.Command{
 		}
 		log.Debug(string(cfgData))
 
-		shutdown := make(utils.Shutdown)
-		stop, err := fx_opt.New(cmd.Context(),
-			fx_opt.Override(new(context.Context), cmd.Context()),
-			fx_opt.Override(new(utils.Shutdown), shutdown),
-			//version
-			fx_opt.Override(new(version.IChecker), version.NewVersionChecker),
-			//config
-			fx_opt.Override(new(*config.Config), cfg),
-			fx_opt.Override(new(*config.APIConfig), &cfg.API),
-			fx_opt.Override(new(*config.AuthConfig), &cfg.Auth),
-			fx_opt.Override(new(*config.DatabaseConfig), &cfg.Database),
-			fx_opt.Override(new(params.AdapterConfig), &cfg.Blockstore),
-			//database
-			fx_opt.Override(new(*bun.DB), models.SetupDatabase),
-			fx_opt.Override(new(models.IRepo), func(db *bun.DB) models.IRepo {
-				return models.NewRepo(db)
-			}),
-			fx_opt.Override(new(models.IUserRepo), func(repo models.IRepo) models.IUserRepo {
-				return repo.UserRepo()
-			}),
-
-			fx_opt.Override(fx_opt.NextInvoke(), migrations.MigrateDatabase),
-			//permission
-			fx_opt.Override(new(rbac.PermissionCheck), func(repo models.IRepo) rbac.PermissionCheck {
-				return rbac.NewRbacAuth(repo)
-			}),
-
-			//api
-			fx_opt.Override(new(crypt.SecretStore), auth.NewSectetStore),
-			fx_opt.Override(new(sessions.Store), auth.NewSessionStore),
-			fx_opt.Override(new(*auth.BasicAuthenticator), auth.NewBasicAuthenticator),
-			fx_opt.Override(new(aksk.Verifier), auth.NewAkskVerifier),
-			fx_opt.Override(fx_opt.NextInvoke(), apiImpl.SetupAPI),
-		)
-		if err != nil {
-			return err
-		}
+		return runDaemon(cmd.Context(), cfg)
+	},
+}
 
-		go utils.CatchSig(cmd.Context(), shutdown)
+// runDaemon start api server with cfg and block until shutdown
+func runDaemon(ctx context.Context, cfg *config.Config) error {
+	shutdown := make(utils.Shutdown)
+	stop, err := fx_opt.New(ctx,
+		fx_opt.Override(new(context.Context), ctx),
+		fx_opt.Override(new(utils.Shutdown), shutdown),
+		//version
+		fx_opt.Override(new(version.IChecker), version.NewVersionChecker),
+		//config
+		fx_opt.Override(new(*config.Config), cfg),
+		fx_opt.Override(new(*config.APIConfig), &cfg.API),
+		fx_opt.Override(new(*config.AuthConfig), &cfg.Auth),
+		fx_opt.Override(new(*config.DatabaseConfig), &cfg.Database),
+		fx_opt.Override(new(params.AdapterConfig), &cfg.Blockstore),
+		//database
+		fx_opt.Override(new(*bun.DB), models.SetupDatabase),
+		fx_opt.Override(new(*models.ReplicaDB), func(ctx context.Context, lc fx.Lifecycle, db *bun.DB, dbConfig *config.DatabaseConfig) (*models.ReplicaDB, error) {
+			return models.SetupReplicaDatabase(ctx, lc, models.NewRetryDB(db, dbConfig), dbConfig)
+		}),
+		fx_opt.Override(new(models.IRepo), func(db *bun.DB, replicaDB *models.ReplicaDB, dbConfig *config.DatabaseConfig) models.IRepo {
+			if replicaDB != nil {
+				return models.NewRepo(replicaDB)
+			}
+			return models.NewRepo(models.NewRetryDB(db, dbConfig))
+		}),
+		fx_opt.Override(new(models.IUserRepo), func(repo models.IRepo) models.IUserRepo {
+			return repo.UserRepo()
+		}),
+		fx_opt.Override(new(models.IPoolMonitor), func(db *bun.DB) models.IPoolMonitor {
+			return models.NewPoolMonitor(db.DB, 0)
+		}),
 
-		<-shutdown
-		log.Info("graceful shutdown")
-		return stop(cmd.Context())
-	},
+		fx_opt.Override(fx_opt.NextInvoke(), migrations.MigrateDatabase),
+		//permission
+		fx_opt.Override(new(rbac.PermissionCheck), func(repo models.IRepo) rbac.PermissionCheck {
+			return rbac.NewRbacAuth(repo)
+		}),
+
+		//commit batcher
+		fx_opt.Override(new(*versionmgr.CommitBatcher), func(cfg *config.APIConfig) *versionmgr.CommitBatcher {
+			return versionmgr.NewCommitBatcher(cfg.BatchCommitWindow, cfg.BatchCommitMaxWrites)
+		}),
+		//stale upload sessions
+		fx_opt.Override(new(*versionmgr.UploadSessionReaper), func(repo models.IRepo, publicAdapterConfig params.AdapterConfig, cfg *config.APIConfig) *versionmgr.UploadSessionReaper {
+			return versionmgr.NewUploadSessionReaper(repo, publicAdapterConfig, cfg.UploadSessionTTL, cfg.UploadReapInterval)
+		}),
+		fx_opt.Override(fx_opt.NextInvoke(), func(lc fx.Lifecycle, ctx context.Context, reaper *versionmgr.UploadSessionReaper) {
+			lc.Append(fx.Hook{
+				OnStart: func(_ context.Context) error {
+					reaper.Start(ctx)
+					return nil
+				},
+				OnStop: func(_ context.Context) error {
+					reaper.Stop()
+					return nil
+				},
+			})
+		}),
+		//pack consolidation
+		fx_opt.Override(new(*versionmgr.Repacker), func(repo models.IRepo, publicAdapterConfig params.AdapterConfig, cfg *config.APIConfig) *versionmgr.Repacker {
+			return versionmgr.NewRepacker(repo, publicAdapterConfig, cfg.RepackInterval, cfg.RepackBytesPerSecond)
+		}),
+		fx_opt.Override(fx_opt.NextInvoke(), func(lc fx.Lifecycle, ctx context.Context, repacker *versionmgr.Repacker) {
+			lc.Append(fx.Hook{
+				OnStart: func(_ context.Context) error {
+					repacker.Start(ctx)
+					return nil
+				},
+				OnStop: func(_ context.Context) error {
+					repacker.Stop()
+					return nil
+				},
+			})
+		}),
+		//merge request auto merge
+		fx_opt.Override(new(*versionmgr.AutoMerger), func(repo models.IRepo, publicAdapterConfig params.AdapterConfig, cfg *config.APIConfig, authConfig *config.AuthConfig) (*versionmgr.AutoMerger, error) {
+			secretKey, err := hex.DecodeString(authConfig.SecretKey)
+			if err != nil {
+				return nil, err
+			}
+			return versionmgr.NewAutoMerger(repo, publicAdapterConfig, secretKey, cfg.AutoMergeInterval), nil
+		}),
+		fx_opt.Override(fx_opt.NextInvoke(), func(lc fx.Lifecycle, ctx context.Context, merger *versionmgr.AutoMerger) {
+			lc.Append(fx.Hook{
+				OnStart: func(_ context.Context) error {
+					merger.Start(ctx)
+					return nil
+				},
+				OnStop: func(_ context.Context) error {
+					merger.Stop()
+					return nil
+				},
+			})
+		}),
+		//purged repository cleanup
+		fx_opt.Override(new(*versionmgr.RepositoryCleaner), func(repo models.IRepo, publicAdapterConfig params.AdapterConfig, cfg *config.APIConfig) *versionmgr.RepositoryCleaner {
+			return versionmgr.NewRepositoryCleaner(repo, publicAdapterConfig, cfg.CleanInterval, cfg.CleanBatchSize)
+		}),
+		fx_opt.Override(fx_opt.NextInvoke(), func(lc fx.Lifecycle, ctx context.Context, cleaner *versionmgr.RepositoryCleaner) {
+			lc.Append(fx.Hook{
+				OnStart: func(_ context.Context) error {
+					cleaner.Start(ctx)
+					return nil
+				},
+				OnStop: func(_ context.Context) error {
+					cleaner.Stop()
+					return nil
+				},
+			})
+		}),
+		//usage reports
+		fx_opt.Override(new(*versionmgr.UsageReporter), func(repo models.IRepo, cfg *config.APIConfig) *versionmgr.UsageReporter {
+			return versionmgr.NewUsageReporter(repo, cfg.UsageReportInterval)
+		}),
+		fx_opt.Override(fx_opt.NextInvoke(), func(lc fx.Lifecycle, ctx context.Context, reporter *versionmgr.UsageReporter) {
+			lc.Append(fx.Hook{
+				OnStart: func(_ context.Context) error {
+					reporter.Start(ctx)
+					return nil
+				},
+				OnStop: func(_ context.Context) error {
+					reporter.Stop()
+					return nil
+				},
+			})
+		}),
+		//async commits
+		fx_opt.Override(new(*versionmgr.CommitQueue), func(repo models.IRepo, publicAdapterConfig params.AdapterConfig, cfg *config.APIConfig) *versionmgr.CommitQueue {
+			return versionmgr.NewCommitQueue(repo, publicAdapterConfig, cfg.AsyncCommitInterval, cfg.AsyncCommitWorkers).
+				SetCommitLimits(versionmgr.CommitLimits{MaxEntries: cfg.MaxCommitEntries, MaxBytes: cfg.MaxCommitBytes}).
+				SetQuotaWarningThresholds(cfg.QuotaWarningThresholds)
+		}),
+		fx_opt.Override(fx_opt.NextInvoke(), func(lc fx.Lifecycle, ctx context.Context, queue *versionmgr.CommitQueue) {
+			lc.Append(fx.Hook{
+				OnStart: func(_ context.Context) error {
+					queue.Start(ctx)
+					return nil
+				},
+				OnStop: func(_ context.Context) error {
+					queue.Stop()
+					return nil
+				},
+			})
+		}),
+		//repository write limits
+		fx_opt.Override(new(*ratelimit.Limiter), ratelimit.NewLimiter),
+		//api
+		fx_opt.Override(new(crypt.SecretStore), auth.NewSectetStore),
+		fx_opt.Override(new(sessions.Store), auth.NewSessionStore),
+		fx_opt.Override(new(*auth.BasicAuthenticator), auth.NewBasicAuthenticator),
+		fx_opt.Override(new(aksk.Verifier), auth.NewAkskVerifier),
+		fx_opt.Override(fx_opt.NextInvoke(), apiImpl.SetupAPI),
+	)
+	if err != nil {
+		return err
+	}
+
+	go utils.CatchSig(ctx, shutdown)
+
+	<-shutdown
+	log.Info("graceful shutdown")
+	return stop(ctx)
 }
 
 func init() {
@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/i18n"
+	"github.com/spf13/cobra"
+)
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "show changes between two commits, diffs fetched before are read from local cache",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		owner, err := cmd.Flags().GetString("owner")
+		if err != nil {
+			return err
+		}
+		repo, err := cmd.Flags().GetString("repo")
+		if err != nil {
+			return err
+		}
+		if len(owner) == 0 || len(repo) == 0 {
+			return errors.New("owner and repo must be set")
+		}
+
+		base, err := cmd.Flags().GetString("base")
+		if err != nil {
+			return err
+		}
+		head, err := cmd.Flags().GetString("head")
+		if err != nil {
+			return err
+		}
+		if len(base) == 0 || len(head) == 0 {
+			return errors.New("base and head must be set")
+		}
+
+		path, err := cmd.Flags().GetString("path")
+		if err != nil {
+			return err
+		}
+
+		offline, err := cmd.Flags().GetBool("offline")
+		if err != nil {
+			return err
+		}
+
+		cache, err := openMetaCache(cmd, owner, repo)
+		if err != nil {
+			return err
+		}
+
+		// commits never change, so does the diff between them
+		if changes, ok := cache.Diff(base, head, path); ok {
+			printChanges(changes)
+			return nil
+		}
+		if offline {
+			return errors.New(i18n.T(cliLang, "diff of %s...%s not in local cache", base, head))
+		}
+
+		client, err := GetClient(cmd)
+		if err != nil {
+			return err
+		}
+		resp, err := client.CompareCommit(ctx, owner, repo, base+"..."+head, &api.CompareCommitParams{Path: utils.String(path)})
+		if isUnreachable(err) {
+			return fmt.Errorf(i18n.T(cliLang, "%w, diff of %s...%s not in local cache"), err, base, head)
+		}
+		if err != nil {
+			return err
+		}
+
+		result, err := api.ParseCompareCommitResponse(resp)
+		if err != nil {
+			return err
+		}
+		if result.StatusCode() != http.StatusOK || result.JSON200 == nil {
+			return fmt.Errorf("compare %s...%s failed %d, %s", base, head, result.StatusCode(), string(result.Body))
+		}
+
+		cache.PutDiff(base, head, path, *result.JSON200)
+		printChanges(*result.JSON200)
+		return cache.Save()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	diffCmd.Flags().String("owner", "", "owner")
+	diffCmd.Flags().String("repo", "", "repo")
+	diffCmd.Flags().String("base", "", "base commit hash")
+	diffCmd.Flags().String("head", "", "head commit hash")
+	diffCmd.Flags().String("path", "", "only show changes under path")
+	diffCmd.Flags().Bool("offline", false, "read from local cache only, do not ask server")
+}
@@ -0,0 +1,163 @@
+package cmd
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/GitDataAI/jiaozifs/utils/i18n"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor",
+	Short: "measure latency and throughput to server and storage of repository, and check version compatibility",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		count, err := cmd.Flags().GetInt("count")
+		if err != nil {
+			return err
+		}
+		if count <= 0 {
+			return errors.New("count must be positive")
+		}
+		size, err := cmd.Flags().GetInt64("size")
+		if err != nil {
+			return err
+		}
+		owner, err := cmd.Flags().GetString("owner")
+		if err != nil {
+			return err
+		}
+		repo, err := cmd.Flags().GetString("repo")
+		if err != nil {
+			return err
+		}
+
+		swagger, err := api.GetSwagger()
+		if err != nil {
+			return err
+		}
+		client, err := GetClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		// latency
+		var total, minLatency, maxLatency time.Duration
+		var serverAPIVersion string
+		for i := 0; i < count; i++ {
+			start := time.Now()
+			resp, err := client.DiagnosticPing(ctx)
+			if err != nil {
+				return err
+			}
+			latency := time.Since(start)
+			result, err := api.ParseDiagnosticPingResponse(resp)
+			if err != nil {
+				return err
+			}
+			if result.JSON200 == nil {
+				return fmt.Errorf("ping failed %d, %s", result.StatusCode(), string(result.Body))
+			}
+			serverAPIVersion = result.JSON200.ApiVersion
+
+			total += latency
+			if i == 0 || latency < minLatency {
+				minLatency = latency
+			}
+			maxLatency = max(maxLatency, latency)
+		}
+		fmt.Print(i18n.T(cliLang, "Latency min %s, avg %s, max %s of %d pings\n", minLatency, total/time.Duration(count), maxLatency, count))
+
+		if apiMajor(swagger.Info.Version) == apiMajor(serverAPIVersion) {
+			fmt.Print(i18n.T(cliLang, "API version %s compatible with server %s\n", swagger.Info.Version, serverAPIVersion))
+		} else {
+			fmt.Print(i18n.T(cliLang, "API version %s incompatible with server %s, upgrade client or server\n", swagger.Info.Version, serverAPIVersion))
+		}
+
+		// api throughput
+		start := time.Now()
+		resp, err := client.DiagnosticDownload(ctx, &api.DiagnosticDownloadParams{Size: &size})
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close() //nolint
+		if resp.StatusCode != http.StatusOK {
+			return fmt.Errorf("download failed %d, %s", resp.StatusCode, tryLogError(resp))
+		}
+		downloaded, err := io.Copy(io.Discard, resp.Body)
+		if err != nil {
+			return err
+		}
+		fmt.Print(i18n.T(cliLang, "API download %s in %s, %s/s\n", humanBytes(downloaded), time.Since(start), throughput(downloaded, time.Since(start))))
+
+		content := make([]byte, size)
+		_, err = rand.Read(content)
+		if err != nil {
+			return err
+		}
+		start = time.Now()
+		uploadResp, err := client.DiagnosticUploadWithBody(ctx, "application/octet-stream", bytes.NewReader(content))
+		if err != nil {
+			return err
+		}
+		uploadResult, err := api.ParseDiagnosticUploadResponse(uploadResp)
+		if err != nil {
+			return err
+		}
+		if uploadResult.JSON200 == nil {
+			return fmt.Errorf("upload failed %d, %s", uploadResult.StatusCode(), string(uploadResult.Body))
+		}
+		fmt.Print(i18n.T(cliLang, "API upload %s in %s, %s/s\n", humanBytes(uploadResult.JSON200.Bytes), time.Since(start), throughput(uploadResult.JSON200.Bytes, time.Since(start))))
+
+		// storage throughput
+		if len(owner) == 0 || len(repo) == 0 {
+			return nil
+		}
+		storageResp, err := client.DiagnoseStorage(ctx, owner, repo, &api.DiagnoseStorageParams{Size: &size})
+		if err != nil {
+			return err
+		}
+		storageResult, err := api.ParseDiagnoseStorageResponse(storageResp)
+		if err != nil {
+			return err
+		}
+		if storageResult.JSON200 == nil {
+			return fmt.Errorf("diagnose storage of %s/%s failed %d, %s", owner, repo, storageResult.StatusCode(), string(storageResult.Body))
+		}
+		probe := storageResult.JSON200
+		writeTime := time.Duration(probe.WriteMs) * time.Millisecond
+		readTime := time.Duration(probe.ReadMs) * time.Millisecond
+		fmt.Print(i18n.T(cliLang, "Storage %s write %s in %s, %s/s\n", probe.BlockstoreType, humanBytes(probe.Bytes), writeTime, throughput(probe.Bytes, writeTime)))
+		fmt.Print(i18n.T(cliLang, "Storage %s read %s in %s, %s/s\n", probe.BlockstoreType, humanBytes(probe.Bytes), readTime, throughput(probe.Bytes, readTime)))
+		return nil
+	},
+}
+
+// apiMajor major part of semantic version, client and server of same major version are compatible
+func apiMajor(version string) string {
+	major, _, _ := strings.Cut(strings.TrimPrefix(version, "v"), ".")
+	return major
+}
+
+func throughput(size int64, elapsed time.Duration) string {
+	if elapsed <= 0 {
+		return humanBytes(size)
+	}
+	return humanBytes(int64(float64(size) / elapsed.Seconds()))
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().Int("count", 5, "number of pings measuring latency")
+	doctorCmd.Flags().Int64("size", 1<<20, "bytes transferred measuring throughput, at most 64MiB")
+	doctorCmd.Flags().String("owner", "", "owner of repository whose storage is measured, storage is skipped if not set")
+	doctorCmd.Flags().String("repo", "", "repository whose storage is measured, storage is skipped if not set")
+}
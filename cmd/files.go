@@ -1,8 +1,10 @@
 package cmd
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
 	"net/http"
 	"os"
@@ -11,6 +13,9 @@ import (
 	"strings"
 
 	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/i18n"
+	"github.com/GitDataAI/jiaozifs/utils/pathutil"
+	"github.com/GitDataAI/jiaozifs/utils/readahead"
 
 	"github.com/GitDataAI/jiaozifs/api"
 
@@ -88,13 +93,64 @@ var uploadCmd = &cobra.Command{
 			return err
 		}
 
+		resume, err := cmd.Flags().GetBool("resume")
+		if err != nil {
+			return err
+		}
+
+		journalPath, err := cmd.Flags().GetString("journal")
+		if err != nil {
+			return err
+		}
+		target := fmt.Sprintf("%s/%s/%s:%s<-%s", owner, repo, refName, uploadPath, path)
+		if len(journalPath) == 0 {
+			journalPath, err = journalPathFor(target)
+			if err != nil {
+				return err
+			}
+		}
+
+		journal := &uploadJournal{path: journalPath, Target: target, Entries: make(map[string]journalEntry)}
+		if resume {
+			journal, err = loadUploadJournal(journalPath, target)
+			if err != nil {
+				return err
+			}
+		}
+
+		noIgnore, err := cmd.Flags().GetBool("no-ignore")
+		if err != nil {
+			return err
+		}
+
 		var files []string
+		var totalBytes int64
 		if st.IsDir() {
+			var ignoreMatcher *pathutil.IgnoreMatcher
+			if !noIgnore {
+				ignoreMatcher, err = pathutil.LoadIgnoreMatcher(path)
+				if err != nil {
+					return err
+				}
+			}
+
+			rootPath := path
 			err = filepath.Walk(path, func(path string, info fs.FileInfo, _ error) error {
+				relativePath, err := filepath.Rel(rootPath, path)
+				if err != nil {
+					return err
+				}
+				if ignoreMatcher.Match(relativePath, info.IsDir()) {
+					if info.IsDir() {
+						return filepath.SkipDir
+					}
+					return nil
+				}
 				if info.IsDir() {
 					return nil
 				}
 				files = append(files, path)
+				totalBytes += info.Size()
 				return nil
 			})
 			if err != nil {
@@ -102,18 +158,15 @@ var uploadCmd = &cobra.Command{
 			}
 		}
 
-		fmt.Printf("Files dected, %d files need to be uploaded\n", len(files))
+		fmt.Print(i18n.T(cliLang, "Files dected, %d files need to be uploaded\n", len(files)))
 		_, err = client.GetWip(cmd.Context(), owner, repo, &api.GetWipParams{RefName: refName})
 		if err != nil {
 			return err
 		}
 
+		stats := newTransferStats(int64(len(files)), totalBytes)
 		basename := filepath.Base(path)
 		for _, file := range files {
-			fs, err := os.Open(file)
-			if err != nil {
-				return err
-			}
 			relativePath := strings.Replace(file, path, "", 1)
 
 			var destPath string
@@ -123,26 +176,55 @@ var uploadCmd = &cobra.Command{
 				destPath = path2.Join(uploadPath, relativePath)
 			}
 
-			resp, err := client.UploadObjectWithBody(cmd.Context(), owner, repo, &api.UploadObjectParams{
+			info, err := os.Stat(file)
+			if err != nil {
+				return err
+			}
+			if journal.IsDone(destPath, info) {
+				stats.Skip(info.Size())
+				continue
+			}
+
+			err = uploadFile(cmd, client, stats, file, info, &api.UploadObjectParams{
 				RefName: refName,
 				// Path relative to the ref
 				Path:      destPath,
 				IsReplace: utils.Bool(replace),
-			}, "application/json", fs)
+			}, owner, repo)
 			if err != nil {
-				return err
+				return fmt.Errorf(i18n.T(cliLang, "%w, rerun with --resume to continue"), err)
 			}
 
-			if resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusOK {
-				fmt.Println("Upload file success ", file, " dest path", destPath)
-				continue
+			err = journal.MarkDone(destPath, info)
+			if err != nil {
+				return err
 			}
-			return fmt.Errorf("upload file failed %d, %s", resp.StatusCode, tryLogError(resp))
 		}
-		return nil
+		stats.Summary()
+		return journal.Remove()
 	},
 }
 
+func uploadFile(cmd *cobra.Command, client *api.Client, stats *transferStats, file string, info os.FileInfo, params *api.UploadObjectParams, owner, repo string) error {
+	fs, err := os.Open(file)
+	if err != nil {
+		return err
+	}
+	defer fs.Close() //nolint
+
+	resp, err := client.UploadObjectWithBody(cmd.Context(), owner, repo, params, "application/json", stats.Wrap(fs, file, info.Size()))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close() //nolint
+
+	if resp.StatusCode == http.StatusCreated || resp.StatusCode == http.StatusOK {
+		stats.FileDone()
+		return nil
+	}
+	return fmt.Errorf("upload file %s failed %d, %s", file, resp.StatusCode, tryLogError(resp))
+}
+
 // versionCmd represents the version command
 var downloadCmd = &cobra.Command{
 	Use:   "download",
@@ -200,12 +282,21 @@ var downloadCmd = &cobra.Command{
 			return err
 		}
 
-		if len(output) == 0 {
+		if len(output) > 0 {
 			fileName = output
 		}
 
-		opjResp, err := client.GetObject(ctx, owner, repo, &api.GetObjectParams{
-			// Type type indicate to retrieve from wip/branch/tag, default branch
+		chunkSize, err := cmd.Flags().GetInt64("chunk-size")
+		if err != nil {
+			return err
+		}
+
+		window, err := cmd.Flags().GetInt("readahead")
+		if err != nil {
+			return err
+		}
+
+		headResp, err := client.HeadObject(ctx, owner, repo, &api.HeadObjectParams{
 			Type:    api.RefType(refType),
 			RefName: refName,
 			Path:    path,
@@ -213,13 +304,59 @@ var downloadCmd = &cobra.Command{
 		if err != nil {
 			return err
 		}
+		_ = headResp.Body.Close()
+		if headResp.StatusCode != http.StatusOK {
+			return fmt.Errorf("get object %s failed %d", path, headResp.StatusCode)
+		}
+
+		var reader io.ReadCloser
+		if headResp.Header.Get("Accept-Ranges") == "none" || headResp.ContentLength < 0 {
+			// masked content can only be fetched as a whole
+			resp, err := client.GetObject(ctx, owner, repo, &api.GetObjectParams{
+				Type:    api.RefType(refType),
+				RefName: refName,
+				Path:    path,
+			})
+			if err != nil {
+				return err
+			}
+			if resp.StatusCode != http.StatusOK {
+				defer resp.Body.Close() //nolint
+				return fmt.Errorf("get object %s failed %d, %s", path, resp.StatusCode, tryLogError(resp))
+			}
+			reader = resp.Body
+		} else {
+			// fetch object in ranges, chunks after a sequential read are fetched ahead while the former one is written
+			reader = readahead.NewReader(ctx, headResp.ContentLength, func(ctx context.Context, offset, length int64) (io.ReadCloser, error) {
+				resp, err := client.GetObject(ctx, owner, repo, &api.GetObjectParams{
+					Type:    api.RefType(refType),
+					RefName: refName,
+					Path:    path,
+					Range:   utils.String(fmt.Sprintf("bytes=%d-%d", offset, offset+length-1)),
+				})
+				if err != nil {
+					return nil, err
+				}
+				if resp.StatusCode != http.StatusPartialContent && resp.StatusCode != http.StatusOK {
+					defer resp.Body.Close() //nolint
+					return nil, fmt.Errorf("get object %s range %d-%d failed %d, %s", path, offset, offset+length-1, resp.StatusCode, tryLogError(resp))
+				}
+				return resp.Body, nil
+			}, readahead.Options{ChunkSize: chunkSize, Window: window})
+		}
+		defer reader.Close() //nolint
 
-		headObj, err := api.ParseGetObjectResponse(opjResp)
+		file, err := os.OpenFile(fileName, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
 		if err != nil {
 			return err
 		}
+		defer file.Close() //nolint
 
-		return os.WriteFile(fileName, headObj.Body, 0666)
+		_, err = io.Copy(file, reader)
+		if err != nil {
+			return err
+		}
+		return file.Close()
 	},
 }
 
@@ -233,6 +370,9 @@ func init() {
 	uploadCmd.Flags().String("upload-path", "", "path to save in server")
 	uploadCmd.Flags().Bool("replace", true, "path to save in server")
 	uploadCmd.Flags().Bool("ignore-root-name", false, "ignore root name")
+	uploadCmd.Flags().Bool("resume", false, "continue an interrupted upload, skip files recorded in journal")
+	uploadCmd.Flags().String("journal", "", "path of upload journal (default is $HOME/.jiaozifs/journal/<task>.json)")
+	uploadCmd.Flags().Bool("no-ignore", false, "upload all files, do not respect rules in "+pathutil.IgnoreFileName)
 
 	rootCmd.AddCommand(downloadCmd)
 	downloadCmd.Flags().String("path", "", "path of files to upload")
@@ -240,5 +380,7 @@ func init() {
 	downloadCmd.Flags().String("repo", "", "repo")
 	downloadCmd.Flags().String("ref-name", "main", "branch name")
 	downloadCmd.Flags().String("ref-type", "branch", "reference type")
-	downloadCmd.Flags().String("output", "branch", "reference type")
+	downloadCmd.Flags().String("output", "", "local file to save (default is base name of path)")
+	downloadCmd.Flags().Int64("chunk-size", readahead.DefaultChunkSize, "bytes fetched by each range request")
+	downloadCmd.Flags().Int("readahead", readahead.DefaultWindow, "max chunks fetched ahead of sequential reads, 0 to disable")
 }
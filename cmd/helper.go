@@ -1,6 +1,7 @@
 package cmd
 
 import (
+	"context"
 	"io"
 	"net/http"
 
@@ -16,10 +17,20 @@ func GetClient(cmd *cobra.Command) (*api.Client, error) {
 	user := cmd.Flags().Lookup("user").Value.String()
 	password := cmd.Flags().Lookup("password").Value.String()
 
+	langOpt := api.WithRequestEditorFn(func(_ context.Context, req *http.Request) error {
+		req.Header.Set("Accept-Language", string(cliLang))
+		return nil
+	})
+	httpOpts := api.DefaultHTTPOptions()
+	httpOpts.HTTP2, _ = cmd.Flags().GetBool("http2")
+	if maxIdleConns, _ := cmd.Flags().GetInt("max-idle-conns"); maxIdleConns > 0 {
+		httpOpts.MaxIdleConnsPerHost = maxIdleConns
+	}
+	httpOpt := api.HTTPClientOption(httpOpts)
 	if len(ak) > 0 {
-		return api.NewClient(url, api.AkSkOption(ak, sk))
+		return api.NewClient(url, api.AkSkOption(ak, sk), langOpt, httpOpt)
 	}
-	return api.NewClient(url, api.UPOption(user, password))
+	return api.NewClient(url, api.UPOption(user, password), langOpt, httpOpt)
 }
 
 func tryLogError(resp *http.Response) string {
@@ -0,0 +1,152 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/GitDataAI/jiaozifs/block/factory"
+	"github.com/GitDataAI/jiaozifs/config"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils/i18n"
+	"github.com/GitDataAI/jiaozifs/utils/pathutil"
+	"github.com/GitDataAI/jiaozifs/versionmgr"
+	"github.com/spf13/cobra"
+)
+
+var importCmd = &cobra.Command{
+	Use:   "import",
+	Short: "bulk import local directory or object store prefix into an empty branch of repository",
+	Long: `import run against database and storage of jiaozifs instance directly, files are uploaded through storage adapter
+of repository and committed as the initial commit of branch. owner of repository is the committer.
+
+objects of uri are listed from bucket, or read from --inventory which is the manifest.json of an S3 Inventory in CSV
+format or a csv file with Key, Size and optional Bucket columns in header, listing a huge bucket live takes hours.
+
+progress is saved after every batch, run the same import again to continue an interrupted one`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		ownerName, err := cmd.Flags().GetString("owner")
+		if err != nil {
+			return err
+		}
+		repoName, err := cmd.Flags().GetString("repo")
+		if err != nil {
+			return err
+		}
+		refName, err := cmd.Flags().GetString("ref-name")
+		if err != nil {
+			return err
+		}
+		dir, err := cmd.Flags().GetString("path")
+		if err != nil {
+			return err
+		}
+		uri, err := cmd.Flags().GetString("uri")
+		if err != nil {
+			return err
+		}
+		msg, err := cmd.Flags().GetString("message")
+		if err != nil {
+			return err
+		}
+		batchSize, err := cmd.Flags().GetInt("batch-size")
+		if err != nil {
+			return err
+		}
+		noIgnore, err := cmd.Flags().GetBool("no-ignore")
+		if err != nil {
+			return err
+		}
+		restart, err := cmd.Flags().GetBool("restart")
+		if err != nil {
+			return err
+		}
+		inventory, err := cmd.Flags().GetString("inventory")
+		if err != nil {
+			return err
+		}
+		if (len(dir) == 0) == (len(uri) == 0) {
+			return errors.New(i18n.T(cliLang, "one and only one of path and uri must be specified"))
+		}
+		if len(inventory) > 0 && len(uri) == 0 {
+			return errors.New(i18n.T(cliLang, "inventory can only be used with uri"))
+		}
+
+		cfg, err := config.LoadConfig(cfgFile)
+		if err != nil {
+			return err
+		}
+
+		bunDB, err := models.NewBunDBFromConfig(ctx, &cfg.Database)
+		if err != nil {
+			return err
+		}
+		repo := models.NewRepo(bunDB)
+
+		owner, err := repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+		if err != nil {
+			return err
+		}
+		repository, err := repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetOwnerID(owner.ID).SetName(repoName))
+		if err != nil {
+			return err
+		}
+
+		workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, owner, repository, repo, &cfg.Blockstore)
+		if err != nil {
+			return err
+		}
+
+		var source versionmgr.ImportSource
+		if len(dir) > 0 {
+			var ignoreMatcher *pathutil.IgnoreMatcher
+			if !noIgnore {
+				ignoreMatcher, err = pathutil.LoadIgnoreMatcher(dir)
+				if err != nil {
+					return err
+				}
+			}
+			source = versionmgr.NewDirImportSource(dir, ignoreMatcher)
+		} else {
+			adapter, err := factory.BuildBlockAdapter(ctx, &cfg.Blockstore)
+			if err != nil {
+				return err
+			}
+			if len(inventory) > 0 {
+				source, err = versionmgr.NewInventoryImportSource(adapter, uri, inventory)
+			} else {
+				source, err = versionmgr.NewObjectStoreImportSource(adapter, uri)
+			}
+			if err != nil {
+				return err
+			}
+		}
+
+		result, err := versionmgr.NewImporter(workRepo, batchSize).SetRestart(restart).Import(ctx, source, refName, msg)
+		if err != nil {
+			return fmt.Errorf("import %w", err)
+		}
+		if result.Resumed > 0 {
+			fmt.Print(i18n.T(cliLang, "Resume interrupted import, %d files imported before\n", result.Resumed))
+		}
+		fmt.Print(i18n.T(cliLang, "Import %d files (%s) in commit %s\n", result.Files, humanBytes(result.Bytes), result.Commit.Hash.Hex()))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(importCmd)
+
+	importCmd.Flags().String("owner", "", "owner")
+	importCmd.Flags().String("repo", "", "repo")
+	importCmd.Flags().String("ref-name", "main", "branch name, branch must have no commit")
+	importCmd.Flags().String("path", "", "local directory to import")
+	importCmd.Flags().String("uri", "", "object store prefix to import, like s3://bucket/dataset/")
+	importCmd.Flags().StringP("message", "m", "import dataset", "message of initial commit")
+	importCmd.Flags().Int("batch-size", versionmgr.DefaultImportBatchSize, "number of files added to tree in one transaction")
+	importCmd.Flags().Bool("no-ignore", false, "import all files, do not respect rules in "+pathutil.IgnoreFileName)
+	importCmd.Flags().String("inventory", "", "S3 Inventory manifest.json or csv listing objects of uri, local path or object store uri")
+	importCmd.Flags().Bool("restart", false, "discard progress of interrupted import and import from the first file")
+	_ = importCmd.MarkFlagRequired("owner")
+	_ = importCmd.MarkFlagRequired("repo")
+}
@@ -0,0 +1,143 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/i18n"
+	"github.com/spf13/cobra"
+)
+
+var logCmd = &cobra.Command{
+	Use:   "log",
+	Short: "show commits of ref, commits fetched before are read from local cache",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		owner, err := cmd.Flags().GetString("owner")
+		if err != nil {
+			return err
+		}
+		repo, err := cmd.Flags().GetString("repo")
+		if err != nil {
+			return err
+		}
+		if len(owner) == 0 || len(repo) == 0 {
+			return errors.New("owner and repo must be set")
+		}
+
+		refName, err := cmd.Flags().GetString("ref-name")
+		if err != nil {
+			return err
+		}
+		if len(refName) == 0 {
+			return errors.New("ref-name must be set")
+		}
+
+		amount, err := cmd.Flags().GetInt("amount")
+		if err != nil {
+			return err
+		}
+		if amount <= 0 {
+			return errors.New("amount must be positive")
+		}
+
+		offline, err := cmd.Flags().GetBool("offline")
+		if err != nil {
+			return err
+		}
+
+		cache, err := openMetaCache(cmd, owner, repo)
+		if err != nil {
+			return err
+		}
+
+		var commits []api.Commit
+		if !offline {
+			client, err := GetClient(cmd)
+			if err != nil {
+				return err
+			}
+			commits, err = fetchLog(ctx, client, cache, owner, repo, refName, amount)
+			if isUnreachable(err) {
+				fmt.Print(i18n.T(cliLang, "Server unreachable, read from local cache\n"))
+				offline = true
+			} else if err != nil {
+				return err
+			}
+		}
+
+		if offline {
+			head, ok := cache.RefHead(refName)
+			if !ok {
+				return errors.New(i18n.T(cliLang, "ref %s not in local cache", refName))
+			}
+			commits, _ = cache.Log(head, amount)
+		}
+
+		for _, commit := range commits {
+			fmt.Printf("commit %s\n", commit.Hash)
+			fmt.Printf("Author: %s <%s>\n", commit.Author.Name, commit.Author.Email)
+			fmt.Printf("Date:   %s\n\n", time.UnixMilli(commit.Author.When).Format(time.RFC1123Z))
+			for _, line := range strings.Split(commit.Message, "\n") {
+				fmt.Printf("    %s\n", line)
+			}
+			fmt.Println()
+		}
+		return cache.Save()
+	},
+}
+
+// fetchLog get commits of ref from server, only the head commit is requested if history of it is already cached
+func fetchLog(ctx context.Context, client *api.Client, cache *metaCache, owner, repo, refName string, amount int) ([]api.Commit, error) {
+	commits, err := requestLog(ctx, client, owner, repo, refName, 1)
+	if err != nil || len(commits) == 0 {
+		return commits, err
+	}
+
+	cached, ok := cache.Log(commits[0].Hash, amount)
+	if ok {
+		cache.PutLog(refName, cached, amount)
+		return cached, nil
+	}
+
+	commits, err = requestLog(ctx, client, owner, repo, refName, amount)
+	if err != nil {
+		return nil, err
+	}
+	cache.PutLog(refName, commits, amount)
+	return commits, nil
+}
+
+func requestLog(ctx context.Context, client *api.Client, owner, repo, refName string, amount int) ([]api.Commit, error) {
+	resp, err := client.GetCommitsInRef(ctx, owner, repo, &api.GetCommitsInRefParams{
+		RefName: utils.String(refName),
+		Amount:  utils.Int(amount),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := api.ParseGetCommitsInRefResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	if result.StatusCode() != http.StatusOK || result.JSON200 == nil {
+		return nil, fmt.Errorf("get commits of %s failed %d, %s", refName, result.StatusCode(), string(result.Body))
+	}
+	return result.JSON200.Results, nil
+}
+
+func init() {
+	rootCmd.AddCommand(logCmd)
+	logCmd.Flags().String("owner", "", "owner")
+	logCmd.Flags().String("repo", "", "repo")
+	logCmd.Flags().String("ref-name", "main", "branch or tag name")
+	logCmd.Flags().Int("amount", 20, "max number of commits to show")
+	logCmd.Flags().Bool("offline", false, "read from local cache only, do not ask server")
+}
@@ -0,0 +1,232 @@
+package cmd
+
+import (
+	"crypto/md5" //nolint
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/mitchellh/go-homedir"
+	"github.com/spf13/cobra"
+)
+
+var defaultMetaCacheDir = "~/.jiaozifs/meta-cache"
+
+// commitHistory commits reachable from a head commit in the order returned by server, never change for the same head
+type commitHistory struct {
+	Hashes []string `json:"hashes"`
+	// Complete all commits to the root are included
+	Complete bool `json:"complete"`
+}
+
+// cachedChanges wip changes of a ref, they change over time so fetch time is recorded
+type cachedChanges struct {
+	Changes   []api.Change `json:"changes"`
+	FetchedAt time.Time    `json:"fetched_at"`
+}
+
+// metaCache is a local store of repository metadata fetched from server, commits and diffs between commits are immutable
+// and reused without asking server again, refs and wip changes are kept for offline use only
+type metaCache struct {
+	lk        sync.Mutex
+	path      string
+	Commits   map[string]api.Commit    `json:"commits"`
+	Histories map[string]commitHistory `json:"histories"`
+	Refs      map[string]string        `json:"refs"`
+	Diffs     map[string][]api.Change  `json:"diffs"`
+	Wips      map[string]cachedChanges `json:"wips"`
+}
+
+func metaCachePathFor(serverURL, owner, repo string) (string, error) {
+	dir, err := homedir.Expand(defaultMetaCacheDir)
+	if err != nil {
+		return "", err
+	}
+	sum := md5.Sum([]byte(serverURL + "/" + owner + "/" + repo)) //nolint
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json"), nil
+}
+
+func newMetaCache(path string) *metaCache {
+	return &metaCache{
+		path:      path,
+		Commits:   make(map[string]api.Commit),
+		Histories: make(map[string]commitHistory),
+		Refs:      make(map[string]string),
+		Diffs:     make(map[string][]api.Change),
+		Wips:      make(map[string]cachedChanges),
+	}
+}
+
+func loadMetaCache(path string) (*metaCache, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return newMetaCache(path), nil
+		}
+		return nil, err
+	}
+
+	cache := newMetaCache(path)
+	err = json.Unmarshal(data, cache)
+	if err != nil {
+		//broken cache just rebuild it
+		return newMetaCache(path), nil //nolint
+	}
+	if cache.Commits == nil {
+		cache.Commits = make(map[string]api.Commit)
+	}
+	if cache.Histories == nil {
+		cache.Histories = make(map[string]commitHistory)
+	}
+	if cache.Refs == nil {
+		cache.Refs = make(map[string]string)
+	}
+	if cache.Diffs == nil {
+		cache.Diffs = make(map[string][]api.Change)
+	}
+	if cache.Wips == nil {
+		cache.Wips = make(map[string]cachedChanges)
+	}
+	return cache, nil
+}
+
+// PutLog record commits of ref, amount is the number of commits requested
+func (cache *metaCache) PutLog(refName string, commits []api.Commit, amount int) {
+	if len(commits) == 0 {
+		return
+	}
+	cache.lk.Lock()
+	defer cache.lk.Unlock()
+
+	hashes := make([]string, 0, len(commits))
+	for _, commit := range commits {
+		cache.Commits[commit.Hash] = commit
+		hashes = append(hashes, commit.Hash)
+	}
+	head := commits[0].Hash
+	cache.Refs[refName] = head
+	if history, ok := cache.Histories[head]; ok && len(history.Hashes) >= len(hashes) {
+		return
+	}
+	cache.Histories[head] = commitHistory{
+		Hashes:   hashes,
+		Complete: len(commits) < amount,
+	}
+}
+
+// Log return at most amount commits from head, ok is false if cache doesn't hold enough of them, commits cached are
+// still returned then
+func (cache *metaCache) Log(head string, amount int) ([]api.Commit, bool) {
+	cache.lk.Lock()
+	defer cache.lk.Unlock()
+
+	history, ok := cache.Histories[head]
+	if !ok {
+		return nil, false
+	}
+	hashes := history.Hashes
+	if len(hashes) > amount {
+		hashes = hashes[:amount]
+	}
+	commits := make([]api.Commit, 0, len(hashes))
+	for _, hash := range hashes {
+		commit, ok := cache.Commits[hash]
+		if !ok {
+			return commits, false
+		}
+		commits = append(commits, commit)
+	}
+	return commits, len(commits) == amount || history.Complete
+}
+
+// RefHead return head commit of ref when it is fetched last time
+func (cache *metaCache) RefHead(refName string) (string, bool) {
+	cache.lk.Lock()
+	defer cache.lk.Unlock()
+	head, ok := cache.Refs[refName]
+	return head, ok
+}
+
+func diffKey(base, head, path string) string {
+	return base + "..." + head + ":" + path
+}
+
+func (cache *metaCache) PutDiff(base, head, path string, changes []api.Change) {
+	cache.lk.Lock()
+	defer cache.lk.Unlock()
+	cache.Diffs[diffKey(base, head, path)] = changes
+}
+
+func (cache *metaCache) Diff(base, head, path string) ([]api.Change, bool) {
+	cache.lk.Lock()
+	defer cache.lk.Unlock()
+	changes, ok := cache.Diffs[diffKey(base, head, path)]
+	return changes, ok
+}
+
+func (cache *metaCache) PutWip(refName string, changes []api.Change) {
+	cache.lk.Lock()
+	defer cache.lk.Unlock()
+	cache.Wips[refName] = cachedChanges{Changes: changes, FetchedAt: time.Now()}
+}
+
+func (cache *metaCache) Wip(refName string) (cachedChanges, bool) {
+	cache.lk.Lock()
+	defer cache.lk.Unlock()
+	changes, ok := cache.Wips[refName]
+	return changes, ok
+}
+
+func (cache *metaCache) Save() error {
+	cache.lk.Lock()
+	data, err := json.Marshal(cache)
+	cache.lk.Unlock()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(cache.path), 0755)
+	if err != nil {
+		return err
+	}
+	tmpPath := cache.path + ".tmp"
+	err = os.WriteFile(tmpPath, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, cache.path)
+}
+
+// isUnreachable check whether request failed before reaching server, cached metadata is used then
+func isUnreachable(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr)
+}
+
+// openMetaCache load metadata cache of repository on server the command talk to
+func openMetaCache(cmd *cobra.Command, owner, repo string) (*metaCache, error) {
+	cachePath, err := metaCachePathFor(cmd.Flags().Lookup("url").Value.String(), owner, repo)
+	if err != nil {
+		return nil, err
+	}
+	return loadMetaCache(cachePath)
+}
+
+func printChanges(changes []api.Change) {
+	for _, change := range changes {
+		switch change.Action {
+		case api.ChangeActionInsert:
+			fmt.Printf("A\t%s\n", change.Path)
+		case api.ChangeActionDelete:
+			fmt.Printf("D\t%s\n", change.Path)
+		default:
+			fmt.Printf("M\t%s\n", change.Path)
+		}
+	}
+}
@@ -0,0 +1,206 @@
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/stretchr/testify/require"
+)
+
+func makeCommits(hashes ...string) []api.Commit {
+	commits := make([]api.Commit, 0, len(hashes))
+	for _, hash := range hashes {
+		signature := api.Signature{Name: "jimmy", Email: "jimmy@gg.com"}
+		commits = append(commits, api.Commit{Hash: hash, Message: "commit " + hash, Author: signature, Committer: signature})
+	}
+	return commits
+}
+
+func commitHashes(commits []api.Commit) []string {
+	hashes := make([]string, 0, len(commits))
+	for _, commit := range commits {
+		hashes = append(hashes, commit.Hash)
+	}
+	return hashes
+}
+
+func TestMetaCacheLog(t *testing.T) {
+	cache := newMetaCache(filepath.Join(t.TempDir(), "meta.json"))
+
+	_, ok := cache.Log("c3", 2)
+	require.False(t, ok)
+
+	cache.PutLog("main", makeCommits("c3", "c2"), 2)
+	head, ok := cache.RefHead("main")
+	require.True(t, ok)
+	require.Equal(t, "c3", head)
+
+	cases := []struct {
+		name   string
+		amount int
+		hashes []string
+		ok     bool
+	}{
+		{name: "fewer than cached", amount: 1, hashes: []string{"c3"}, ok: true},
+		{name: "all cached", amount: 2, hashes: []string{"c3", "c2"}, ok: true},
+		{name: "more than cached", amount: 5, hashes: []string{"c3", "c2"}, ok: false},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			commits, ok := cache.Log("c3", c.amount)
+			require.Equal(t, c.ok, ok)
+			require.Equal(t, c.hashes, commitHashes(commits))
+		})
+	}
+
+	t.Run("complete history", func(t *testing.T) {
+		cache.PutLog("main", makeCommits("c3", "c2", "c1"), 10)
+		commits, ok := cache.Log("c3", 10)
+		require.True(t, ok)
+		require.Equal(t, []string{"c3", "c2", "c1"}, commitHashes(commits))
+	})
+
+	t.Run("shorter log keep longer history", func(t *testing.T) {
+		cache.PutLog("dev", makeCommits("c3"), 1)
+		require.Len(t, cache.Histories["c3"].Hashes, 3)
+		head, ok := cache.RefHead("dev")
+		require.True(t, ok)
+		require.Equal(t, "c3", head)
+	})
+
+	t.Run("missing commit", func(t *testing.T) {
+		delete(cache.Commits, "c2")
+		commits, ok := cache.Log("c3", 3)
+		require.False(t, ok)
+		require.Equal(t, []string{"c3"}, commitHashes(commits))
+	})
+}
+
+func TestMetaCacheChanges(t *testing.T) {
+	cache := newMetaCache(filepath.Join(t.TempDir(), "meta.json"))
+	changes := []api.Change{{Path: "a.txt", Action: api.ChangeActionInsert}}
+
+	cache.PutDiff("c1", "c2", "", changes)
+	diff, ok := cache.Diff("c1", "c2", "")
+	require.True(t, ok)
+	require.Equal(t, changes, diff)
+	_, ok = cache.Diff("c1", "c2", "sub")
+	require.False(t, ok)
+	_, ok = cache.Diff("c2", "c1", "")
+	require.False(t, ok)
+
+	_, ok = cache.Wip("main")
+	require.False(t, ok)
+	cache.PutWip("main", changes)
+	wip, ok := cache.Wip("main")
+	require.True(t, ok)
+	require.Equal(t, changes, wip.Changes)
+	require.False(t, wip.FetchedAt.IsZero())
+}
+
+func TestMetaCacheSaveLoad(t *testing.T) {
+	cachePath := filepath.Join(t.TempDir(), "cache", "meta.json")
+	cache, err := loadMetaCache(cachePath)
+	require.NoError(t, err)
+	require.Empty(t, cache.Commits)
+
+	cache.PutLog("main", makeCommits("c2", "c1"), 10)
+	cache.PutDiff("c1", "c2", "", []api.Change{{Path: "a.txt", Action: api.ChangeActionDelete}})
+	cache.PutWip("main", []api.Change{{Path: "b.txt", Action: api.ChangeActionModify}})
+	require.NoError(t, cache.Save())
+
+	loaded, err := loadMetaCache(cachePath)
+	require.NoError(t, err)
+	commits, ok := loaded.Log("c2", 10)
+	require.True(t, ok)
+	require.Equal(t, []string{"c2", "c1"}, commitHashes(commits))
+	head, ok := loaded.RefHead("main")
+	require.True(t, ok)
+	require.Equal(t, "c2", head)
+	_, ok = loaded.Diff("c1", "c2", "")
+	require.True(t, ok)
+	_, ok = loaded.Wip("main")
+	require.True(t, ok)
+
+	t.Run("broken cache rebuilt", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(cachePath, []byte("{broken"), 0644))
+		loaded, err := loadMetaCache(cachePath)
+		require.NoError(t, err)
+		require.Empty(t, loaded.Commits)
+		require.NotNil(t, loaded.Wips)
+	})
+
+	t.Run("path per repository", func(t *testing.T) {
+		a, err := metaCachePathFor("http://localhost:34913", "o", "r")
+		require.NoError(t, err)
+		b, err := metaCachePathFor("http://localhost:34913", "o", "r2")
+		require.NoError(t, err)
+		require.NotEqual(t, a, b)
+	})
+}
+
+func TestFetchLog(t *testing.T) {
+	history := makeCommits("c3", "c2", "c1")
+	var amounts []int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		amount, err := strconv.Atoi(r.URL.Query().Get("amount"))
+		require.NoError(t, err)
+		amounts = append(amounts, amount)
+		if amount > len(history) {
+			amount = len(history)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(api.CommitList{Results: history[:amount]})
+	}))
+	defer server.Close()
+	client, err := api.NewClient(server.URL + "/api/v1")
+	require.NoError(t, err)
+
+	ctx := context.Background()
+	cache := newMetaCache(filepath.Join(t.TempDir(), "meta.json"))
+
+	t.Run("first fetch", func(t *testing.T) {
+		commits, err := fetchLog(ctx, client, cache, "o", "r", "main", 10)
+		require.NoError(t, err)
+		require.Equal(t, []string{"c3", "c2", "c1"}, commitHashes(commits))
+		require.Equal(t, []int{1, 10}, amounts)
+	})
+
+	t.Run("history cached", func(t *testing.T) {
+		amounts = nil
+		commits, err := fetchLog(ctx, client, cache, "o", "r", "main", 2)
+		require.NoError(t, err)
+		require.Equal(t, []string{"c3", "c2"}, commitHashes(commits))
+		require.Equal(t, []int{1}, amounts, "only head is requested")
+	})
+
+	t.Run("new head", func(t *testing.T) {
+		amounts = nil
+		history = append(makeCommits("c4"), history...)
+		commits, err := fetchLog(ctx, client, cache, "o", "r", "main", 2)
+		require.NoError(t, err)
+		require.Equal(t, []string{"c4", "c3"}, commitHashes(commits))
+		require.Equal(t, []int{1, 2}, amounts)
+		head, _ := cache.RefHead("main")
+		require.Equal(t, "c4", head)
+	})
+
+	t.Run("server unreachable", func(t *testing.T) {
+		offline, err := api.NewClient("http://127.0.0.1:1/api/v1")
+		require.NoError(t, err)
+		_, err = fetchLog(ctx, offline, cache, "o", "r", "main", 2)
+		require.Error(t, err)
+		require.True(t, isUnreachable(err))
+
+		commits, ok := cache.Log("c4", 2)
+		require.True(t, ok)
+		require.Equal(t, []string{"c4", "c3"}, commitHashes(commits))
+	})
+}
@@ -0,0 +1,107 @@
+package cmd
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/config"
+	"github.com/GitDataAI/jiaozifs/models"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+// mockMemoryConn in memory sqlite database shared by connections of this process while one of them is open
+const mockMemoryConn = "file:jiaozifs-mock?mode=memory&cache=shared"
+
+// mockCmd represents the mock command
+var mockCmd = &cobra.Command{
+	Use:   "mock",
+	Short: "run a throwaway jiaozifs for client development",
+	Long: `serve the api with an in memory sqlite database and an in memory blockstore, nothing need to be set up and all
+state is dropped on exit. with --data state is kept in a sqlite file and a local blockstore under the directory instead`,
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		dataDir, err := cmd.Flags().GetString("data")
+		if err != nil {
+			return err
+		}
+
+		keepData := len(dataDir) > 0
+		if !keepData {
+			// only config file is written here
+			dataDir, err = os.MkdirTemp(os.TempDir(), "jiaozifs-mock-*")
+			if err != nil {
+				return err
+			}
+			defer os.RemoveAll(dataDir) //nolint
+		}
+		dataDir, err = filepath.Abs(dataDir)
+		if err != nil {
+			return err
+		}
+
+		cfgPath := filepath.Join(dataDir, "config.toml")
+		_, err = os.Stat(cfgPath)
+		freshData := os.IsNotExist(err)
+		if freshData {
+			secret := make([]byte, 32)
+			_, err = rand.Read(secret)
+			if err != nil {
+				return err
+			}
+			viper.Set("auth.secretKey", hex.EncodeToString(secret))
+			viper.Set("database.type", config.DatabaseTypeSQLite)
+			if keepData {
+				viper.Set("database.connection", filepath.Join(dataDir, "jiaozifs.db"))
+				viper.Set("blockstore.type", block.BlockstoreTypeLocal)
+				viper.Set("blockstore.local.path", filepath.Join(dataDir, "blockstore"))
+			} else {
+				viper.Set("database.connection", mockMemoryConn)
+				viper.Set("blockstore.type", block.BlockstoreTypeMem)
+			}
+			err = config.InitConfig(cfgPath)
+			if err != nil {
+				return err
+			}
+		}
+
+		cfg, err := config.LoadConfig(cfgPath)
+		if err != nil {
+			return err
+		}
+
+		if !keepData {
+			// hold a connection so the in memory database outlive connections of init and of daemon
+			holdDB, err := models.NewBunDBFromConfig(cmd.Context(), &cfg.Database)
+			if err != nil {
+				return err
+			}
+			defer holdDB.Close() //nolint
+		}
+
+		err = logging.SetLogLevel("*", cfg.Log.Level)
+		if err != nil {
+			return err
+		}
+
+		if freshData {
+			err = initRbac(cmd, &cfg.Database)
+			if err != nil {
+				return err
+			}
+		}
+
+		log.Infof("mock server listen on %s, data in %s", cfg.API.Listen, dataDir)
+		return runDaemon(cmd.Context(), cfg)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(mockCmd)
+	mockCmd.Flags().String("data", "", "keep state in this directory across runs, temporary directory removed on exit if not specific")
+	mockCmd.Flags().String("super_username", "admin", "super name")
+	mockCmd.Flags().String("super_password", "jiaozifs", "super user password")
+}
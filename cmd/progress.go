@@ -0,0 +1,138 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/utils/i18n"
+)
+
+// transferStats collect per file and aggregate transfer information of a upload/download task
+type transferStats struct {
+	totalFiles int64
+	totalBytes int64
+
+	doneFiles    int64
+	skippedFiles int64
+	doneBytes    int64
+
+	start time.Time
+	out   io.Writer
+}
+
+func newTransferStats(totalFiles, totalBytes int64) *transferStats {
+	return &transferStats{
+		totalFiles: totalFiles,
+		totalBytes: totalBytes,
+		start:      time.Now(),
+		out:        os.Stdout,
+	}
+}
+
+// Skip mark a file as skipped, its bytes are counted as transferred
+func (stats *transferStats) Skip(size int64) {
+	atomic.AddInt64(&stats.skippedFiles, 1)
+	atomic.AddInt64(&stats.doneFiles, 1)
+	atomic.AddInt64(&stats.doneBytes, size)
+}
+
+// FileDone mark a file as finished
+func (stats *transferStats) FileDone() {
+	atomic.AddInt64(&stats.doneFiles, 1)
+}
+
+// Wrap return a reader which report the progress of the file while reading
+func (stats *transferStats) Wrap(reader io.Reader, name string, size int64) io.Reader {
+	return &progressReader{
+		reader: reader,
+		stats:  stats,
+		name:   name,
+		size:   size,
+	}
+}
+
+func (stats *transferStats) speed() float64 {
+	elapsed := time.Since(stats.start).Seconds()
+	if elapsed <= 0 {
+		return 0
+	}
+	return float64(atomic.LoadInt64(&stats.doneBytes)) / elapsed
+}
+
+func (stats *transferStats) print(name string, fileRead, fileSize int64) {
+	_, _ = fmt.Fprintf(stats.out, "\r[%d/%d] %s %s %s/s %s",
+		atomic.LoadInt64(&stats.doneFiles),
+		stats.totalFiles,
+		progressBar(atomic.LoadInt64(&stats.doneBytes), stats.totalBytes, 20),
+		percent(fileRead, fileSize),
+		humanBytes(int64(stats.speed())),
+		name,
+	)
+}
+
+// Summary print aggregate result of the transfer
+func (stats *transferStats) Summary() {
+	_, _ = fmt.Fprint(stats.out, i18n.T(cliLang, "\nTransfer finished, %d files (%d skipped), %s in %s, average %s/s\n",
+		atomic.LoadInt64(&stats.doneFiles),
+		atomic.LoadInt64(&stats.skippedFiles),
+		humanBytes(atomic.LoadInt64(&stats.doneBytes)),
+		time.Since(stats.start).Round(time.Second),
+		humanBytes(int64(stats.speed())),
+	))
+}
+
+type progressReader struct {
+	reader io.Reader
+	stats  *transferStats
+	name   string
+	size   int64
+	read   int64
+	last   time.Time
+}
+
+func (r *progressReader) Read(p []byte) (int, error) {
+	n, err := r.reader.Read(p)
+	r.read += int64(n)
+	atomic.AddInt64(&r.stats.doneBytes, int64(n))
+	//avoid flush terminal too frequently
+	if time.Since(r.last) > 200*time.Millisecond || err == io.EOF {
+		r.last = time.Now()
+		r.stats.print(r.name, r.read, r.size)
+	}
+	return n, err
+}
+
+func progressBar(done, total int64, width int) string {
+	filled := width
+	if total > 0 {
+		filled = int(float64(done) / float64(total) * float64(width))
+	}
+	if filled > width {
+		filled = width
+	}
+	return "[" + strings.Repeat("=", filled) + strings.Repeat(" ", width-filled) + "]"
+}
+
+func percent(done, total int64) string {
+	if total <= 0 {
+		return "100.0%"
+	}
+	return fmt.Sprintf("%5.1f%%", float64(done)/float64(total)*100)
+}
+
+func humanBytes(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
@@ -3,12 +3,16 @@ package cmd
 import (
 	"os"
 
+	"github.com/GitDataAI/jiaozifs/utils/i18n"
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
 )
 
 var cfgFile string
 
+// cliLang language of cli output and of messages asked from server
+var cliLang = i18n.DefaultLang
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "jiaozifs",
@@ -43,5 +47,12 @@ func init() {
 	rootCmd.PersistentFlags().String("password", "", "password")
 
 	rootCmd.PersistentFlags().String("url", "http://127.0.0.1:34913", "url")
+	rootCmd.PersistentFlags().String("lang", string(i18n.FromEnv()), "language of output, en or zh (default detect from LANG)")
+	rootCmd.PersistentFlags().Bool("http2", false, "multiplex requests over HTTP/2, server listen on http must enable http2")
+	rootCmd.PersistentFlags().Int("max-idle-conns", 64, "idle connections to server kept for reuse")
+
+	cobra.OnInitialize(func() {
+		cliLang = i18n.Parse(rootCmd.PersistentFlags().Lookup("lang").Value.String())
+	})
 
 }
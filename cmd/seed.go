@@ -0,0 +1,62 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/GitDataAI/jiaozifs/config"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/models/migrations"
+	"github.com/GitDataAI/jiaozifs/seed"
+	"github.com/GitDataAI/jiaozifs/utils/i18n"
+	"github.com/spf13/cobra"
+)
+
+var seedCmd = &cobra.Command{
+	Use:   "seed",
+	Short: "set up users, organizations and repositories from a manifest",
+}
+
+var applySeedCmd = &cobra.Command{
+	Use:   "apply <file.yaml>",
+	Short: "create what is missing in manifest, applying the same manifest again changes nothing",
+	Args:  cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		manifest, err := seed.Load(args[0])
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.LoadConfig(cfgFile)
+		if err != nil {
+			return err
+		}
+
+		bunDB, err := models.NewBunDBFromConfig(cmd.Context(), &cfg.Database)
+		if err != nil {
+			return err
+		}
+
+		err = migrations.MigrateDatabase(cmd.Context(), bunDB, &cfg.Database)
+		if err != nil {
+			return err
+		}
+
+		result, err := seed.Apply(cmd.Context(), models.NewRepo(bunDB), manifest, cfg.Blockstore.BlockstoreType())
+		if err != nil {
+			return fmt.Errorf("apply seed %w", err)
+		}
+		for _, item := range result.Created {
+			fmt.Println("created", item)
+		}
+		for _, item := range result.Updated {
+			fmt.Println("updated", item)
+		}
+		fmt.Print(i18n.T(cliLang, "Seed applied, %d created, %d updated\n", len(result.Created), len(result.Updated)))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(seedCmd)
+	seedCmd.AddCommand(applySeedCmd)
+}
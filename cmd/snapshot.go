@@ -0,0 +1,124 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/block/factory"
+	"github.com/GitDataAI/jiaozifs/config"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/models/migrations"
+	"github.com/GitDataAI/jiaozifs/snapshot"
+	"github.com/GitDataAI/jiaozifs/utils/i18n"
+	"github.com/GitDataAI/jiaozifs/versionmgr"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot",
+	Short: "export or import metadata snapshot of jiaozifs instance",
+}
+
+var exportSnapshotCmd = &cobra.Command{
+	Use:   "export",
+	Short: "export a consistent snapshot of metadata with manifest of required storage objects",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		output, err := cmd.Flags().GetString("output")
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.LoadConfig(cfgFile)
+		if err != nil {
+			return err
+		}
+
+		bunDB, err := models.NewBunDBFromConfig(cmd.Context(), &cfg.Database)
+		if err != nil {
+			return err
+		}
+
+		manifest, err := snapshot.Export(cmd.Context(), bunDB, output)
+		if err != nil {
+			return fmt.Errorf("export snapshot %w", err)
+		}
+		fmt.Print(i18n.T(cliLang, "Export success, %d tables, %d storage objects required\n", len(manifest.Tables), len(manifest.Objects)))
+		return nil
+	},
+}
+
+var importSnapshotCmd = &cobra.Command{
+	Use:   "import",
+	Short: "import snapshot into an empty database",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		input, err := cmd.Flags().GetString("input")
+		if err != nil {
+			return err
+		}
+
+		verifyStorage, err := cmd.Flags().GetBool("verify-storage")
+		if err != nil {
+			return err
+		}
+
+		cfg, err := config.LoadConfig(cfgFile)
+		if err != nil {
+			return err
+		}
+
+		bunDB, err := models.NewBunDBFromConfig(cmd.Context(), &cfg.Database)
+		if err != nil {
+			return err
+		}
+
+		err = migrations.MigrateDatabase(cmd.Context(), bunDB, &cfg.Database)
+		if err != nil {
+			return err
+		}
+
+		manifest, err := snapshot.Import(cmd.Context(), bunDB, input)
+		if err != nil {
+			return fmt.Errorf("import snapshot %w", err)
+		}
+		fmt.Print(i18n.T(cliLang, "Import success, %d tables\n", len(manifest.Tables)))
+
+		if !verifyStorage {
+			return nil
+		}
+
+		repo := models.NewRepo(bunDB)
+		missing, err := snapshot.VerifyStorage(cmd.Context(), manifest, func(ctx context.Context, repositoryID uuid.UUID) (block.Adapter, error) {
+			repository, err := repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetID(repositoryID))
+			if err != nil {
+				return nil, err
+			}
+			if repository.UsePublicStorage {
+				return factory.BuildBlockAdapter(ctx, &cfg.Blockstore)
+			}
+			return versionmgr.AdapterFromConfig(ctx, *repository.StorageAdapterParams)
+		})
+		if err != nil {
+			return fmt.Errorf("verify storage %w", err)
+		}
+		if len(missing) > 0 {
+			return fmt.Errorf(i18n.T(cliLang, "%d storage objects missing", len(missing)))
+		}
+		fmt.Print(i18n.T(cliLang, "All %d storage objects exist\n", len(manifest.Objects)))
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+
+	snapshotCmd.AddCommand(exportSnapshotCmd)
+	exportSnapshotCmd.Flags().StringP("output", "o", "", "directory to write snapshot")
+	_ = exportSnapshotCmd.MarkFlagRequired("output")
+
+	snapshotCmd.AddCommand(importSnapshotCmd)
+	importSnapshotCmd.Flags().StringP("input", "i", "", "directory of snapshot")
+	importSnapshotCmd.Flags().Bool("verify-storage", false, "check all storage objects in manifest exist after import")
+	_ = importSnapshotCmd.MarkFlagRequired("input")
+}
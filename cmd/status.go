@@ -0,0 +1,93 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/GitDataAI/jiaozifs/utils/i18n"
+	"github.com/spf13/cobra"
+)
+
+var statusCmd = &cobra.Command{
+	Use:   "status",
+	Short: "show changes in wip of ref, last fetched changes are shown when server unreachable",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		owner, err := cmd.Flags().GetString("owner")
+		if err != nil {
+			return err
+		}
+		repo, err := cmd.Flags().GetString("repo")
+		if err != nil {
+			return err
+		}
+		if len(owner) == 0 || len(repo) == 0 {
+			return errors.New("owner and repo must be set")
+		}
+
+		refName, err := cmd.Flags().GetString("ref-name")
+		if err != nil {
+			return err
+		}
+		if len(refName) == 0 {
+			return errors.New("ref-name must be set")
+		}
+
+		offline, err := cmd.Flags().GetBool("offline")
+		if err != nil {
+			return err
+		}
+
+		cache, err := openMetaCache(cmd, owner, repo)
+		if err != nil {
+			return err
+		}
+
+		var changes []api.Change
+		if !offline {
+			client, err := GetClient(cmd)
+			if err != nil {
+				return err
+			}
+			resp, err := client.GetWipChanges(ctx, owner, repo, &api.GetWipChangesParams{RefName: refName})
+			if isUnreachable(err) {
+				fmt.Print(i18n.T(cliLang, "Server unreachable, read from local cache\n"))
+				offline = true
+			} else if err != nil {
+				return err
+			} else {
+				result, err := api.ParseGetWipChangesResponse(resp)
+				if err != nil {
+					return err
+				}
+				if result.StatusCode() != http.StatusOK || result.JSON200 == nil {
+					return fmt.Errorf("get wip changes of %s failed %d, %s", refName, result.StatusCode(), string(result.Body))
+				}
+				changes = *result.JSON200
+				cache.PutWip(refName, changes)
+			}
+		}
+
+		if offline {
+			cached, ok := cache.Wip(refName)
+			if !ok {
+				return errors.New(i18n.T(cliLang, "wip of %s not in local cache", refName))
+			}
+			fmt.Print(i18n.T(cliLang, "Changes fetched at %s\n", cached.FetchedAt.Format("2006-01-02 15:04:05")))
+			changes = cached.Changes
+		}
+
+		printChanges(changes)
+		return cache.Save()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(statusCmd)
+	statusCmd.Flags().String("owner", "", "owner")
+	statusCmd.Flags().String("repo", "", "repo")
+	statusCmd.Flags().String("ref-name", "main", "branch name")
+	statusCmd.Flags().Bool("offline", false, "read from local cache only, do not ask server")
+}
@@ -0,0 +1,429 @@
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"os"
+	path2 "path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/utils/i18n"
+	"github.com/GitDataAI/jiaozifs/utils/pathutil"
+	"github.com/google/uuid"
+	"github.com/spf13/cobra"
+)
+
+type localFile struct {
+	file string
+	info os.FileInfo
+	hash string
+}
+
+// syncPlan describe what need to do to make remote same as local
+type syncPlan struct {
+	uploads   []string
+	deletes   []string
+	unchanged int
+}
+
+var syncCmd = &cobra.Command{
+	Use:   "sync",
+	Short: "sync local directory to wip of server, only changed files are uploaded",
+	RunE: func(cmd *cobra.Command, _ []string) error {
+		ctx := cmd.Context()
+		client, err := GetClient(cmd)
+		if err != nil {
+			return err
+		}
+
+		localDir, err := cmd.Flags().GetString("path")
+		if err != nil {
+			return err
+		}
+		if len(localDir) == 0 {
+			return errors.New("path must be set")
+		}
+		localDir = filepath.Clean(localDir)
+
+		owner, err := cmd.Flags().GetString("owner")
+		if err != nil {
+			return err
+		}
+		repo, err := cmd.Flags().GetString("repo")
+		if err != nil {
+			return err
+		}
+		if len(owner) == 0 || len(repo) == 0 {
+			return errors.New("owner and repo must be set")
+		}
+
+		refName, err := cmd.Flags().GetString("ref-name")
+		if err != nil {
+			return err
+		}
+		if len(refName) == 0 {
+			return errors.New("ref-name must be set")
+		}
+
+		uploadPath, err := cmd.Flags().GetString("upload-path")
+		if err != nil {
+			return err
+		}
+		uploadPath = path2.Clean("/" + uploadPath)
+
+		deleteRemote, err := cmd.Flags().GetBool("delete")
+		if err != nil {
+			return err
+		}
+
+		dryRun, err := cmd.Flags().GetBool("dry-run")
+		if err != nil {
+			return err
+		}
+
+		parallel, err := cmd.Flags().GetInt("parallel")
+		if err != nil {
+			return err
+		}
+		if parallel <= 0 {
+			parallel = 1
+		}
+
+		cachePath, err := cmd.Flags().GetString("cache")
+		if err != nil {
+			return err
+		}
+		if len(cachePath) == 0 {
+			cachePath, err = syncCachePathFor(localDir)
+			if err != nil {
+				return err
+			}
+		}
+		cache, err := loadSyncCache(cachePath)
+		if err != nil {
+			return err
+		}
+
+		//make sure wip exit
+		_, err = client.GetWip(ctx, owner, repo, &api.GetWipParams{RefName: refName})
+		if err != nil {
+			return err
+		}
+
+		hashType, err := remoteHashType(ctx, client, owner, repo)
+		if err != nil {
+			return err
+		}
+
+		noIgnore, err := cmd.Flags().GetBool("no-ignore")
+		if err != nil {
+			return err
+		}
+		var ignoreMatcher *pathutil.IgnoreMatcher
+		if !noIgnore {
+			ignoreMatcher, err = pathutil.LoadIgnoreMatcher(localDir)
+			if err != nil {
+				return err
+			}
+		}
+
+		localFiles, dirs, err := scanLocalDir(localDir, uploadPath, ignoreMatcher)
+		if err != nil {
+			return err
+		}
+
+		err = hashLocalFiles(ctx, cache, localFiles, hashType, parallel)
+		if err != nil {
+			return err
+		}
+
+		remoteEntries, err := listRemoteDirs(ctx, client, owner, repo, refName, dirs, parallel)
+		if err != nil {
+			return err
+		}
+
+		plan := makeSyncPlan(localFiles, dirs, remoteEntries, deleteRemote, func(remotePath string, isDir bool) bool {
+			relativePath := strings.TrimPrefix(remotePath, uploadPath)
+			return ignoreMatcher.Match(relativePath, isDir)
+		})
+		fmt.Print(i18n.T(cliLang, "Sync plan: %d to upload, %d to delete, %d unchanged\n", len(plan.uploads), len(plan.deletes), plan.unchanged))
+		if dryRun {
+			for _, p := range plan.uploads {
+				fmt.Print(i18n.T(cliLang, "upload %s\n", p))
+			}
+			for _, p := range plan.deletes {
+				fmt.Print(i18n.T(cliLang, "delete %s\n", p))
+			}
+			return cache.Save()
+		}
+
+		// server apply changes to the wip tree one by one, files are transferred in parallel
+		var totalBytes int64
+		for _, p := range plan.uploads {
+			totalBytes += localFiles[p].info.Size()
+		}
+		stats := newTransferStats(int64(len(plan.uploads)), totalBytes)
+		err = runParallel(ctx, plan.uploads, parallel, func(p string) error {
+			file := localFiles[p]
+			return uploadFile(cmd, client, stats, file.file, file.info, &api.UploadObjectParams{
+				RefName:   refName,
+				Path:      p,
+				IsReplace: utils.Bool(true),
+			}, owner, repo)
+		})
+		if err != nil {
+			_ = cache.Save()
+			return err
+		}
+		if len(plan.uploads) > 0 {
+			stats.Summary()
+		}
+
+		err = runParallel(ctx, plan.deletes, parallel, func(p string) error {
+			resp, err := client.DeleteObject(ctx, owner, repo, &api.DeleteObjectParams{
+				RefName: refName,
+				Path:    p,
+			})
+			if err != nil {
+				return err
+			}
+			_ = resp.Body.Close()
+			if resp.StatusCode != http.StatusOK {
+				return fmt.Errorf("delete %s failed %d", p, resp.StatusCode)
+			}
+			fmt.Print(i18n.T(cliLang, "Delete success %s\n", p))
+			return nil
+		})
+		if err != nil {
+			_ = cache.Save()
+			return err
+		}
+
+		exists := make(map[string]struct{}, len(localFiles))
+		for _, file := range localFiles {
+			exists[file.file] = struct{}{}
+		}
+		cache.Prune(exists)
+		return cache.Save()
+	},
+}
+
+// scanLocalDir walk local directory, return files keyed by remote path and all remote directories need to compare.
+// ignored files are treated as not exist in local directory
+func scanLocalDir(localDir string, uploadPath string, ignoreMatcher *pathutil.IgnoreMatcher) (map[string]*localFile, map[string]struct{}, error) {
+	localFiles := make(map[string]*localFile)
+	dirs := map[string]struct{}{uploadPath: {}}
+	err := filepath.Walk(localDir, func(file string, info fs.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		relativePath, err := filepath.Rel(localDir, file)
+		if err != nil {
+			return err
+		}
+		if ignoreMatcher.Match(relativePath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		remotePath := path2.Join(uploadPath, filepath.ToSlash(relativePath))
+		if info.IsDir() {
+			dirs[remotePath] = struct{}{}
+			return nil
+		}
+		localFiles[remotePath] = &localFile{file: file, info: info}
+		return nil
+	})
+	return localFiles, dirs, err
+}
+
+// remoteHashType return hash algorithm of remote repository, blob hash of local files must be calculated with it
+func remoteHashType(ctx context.Context, client *api.Client, owner, repo string) (hash.HashType, error) {
+	resp, err := client.GetRepository(ctx, owner, repo)
+	if err != nil {
+		return hash.Md5, err
+	}
+	repoResp, err := api.ParseGetRepositoryResponse(resp)
+	if err != nil {
+		return hash.Md5, err
+	}
+	if repoResp.JSON200 == nil {
+		return hash.Md5, fmt.Errorf("get repository %s failed %d %s", repo, repoResp.StatusCode(), string(repoResp.Body))
+	}
+	if repoResp.JSON200.HashType == nil {
+		return hash.Md5, nil
+	}
+	return hash.ParseHashType(string(*repoResp.JSON200.HashType))
+}
+
+// hashLocalFiles calculate blob hash of local files in parallel, unchanged files read checksum from cache
+func hashLocalFiles(ctx context.Context, cache *syncCache, localFiles map[string]*localFile, hashType hash.HashType, parallel int) error {
+	files := make([]*localFile, 0, len(localFiles))
+	for _, file := range localFiles {
+		files = append(files, file)
+	}
+	return runParallel(ctx, files, parallel, func(file *localFile) error {
+		checkSum, err := cache.CheckSum(file.file, file.info)
+		if err != nil {
+			return err
+		}
+		blob, err := models.NewBlobWithHashType(hashType, models.DefaultLeafProperty(), uuid.Nil, checkSum, file.info.Size())
+		if err != nil {
+			return err
+		}
+		file.hash = blob.Hash.Hex()
+		return nil
+	})
+}
+
+// syncExistsBatchSize paths checked by one batch exists request, limited by server
+const syncExistsBatchSize = 1000
+
+// listRemoteDirs fetch entries of remote directories by batch exists requests sent in parallel, missing directory
+// return no entries
+func listRemoteDirs(ctx context.Context, client *api.Client, owner, repo, refName string, dirs map[string]struct{}, parallel int) (map[string]api.FullTreeEntry, error) {
+	dirList := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		dirList = append(dirList, dir)
+	}
+	var batches [][]string
+	for len(dirList) > 0 {
+		size := min(syncExistsBatchSize, len(dirList))
+		batches = append(batches, dirList[:size])
+		dirList = dirList[size:]
+	}
+
+	lk := sync.Mutex{}
+	remoteEntries := make(map[string]api.FullTreeEntry)
+	err := runParallel(ctx, batches, parallel, func(batch []string) error {
+		resp, err := client.BatchPathExists(ctx, owner, repo, &api.BatchPathExistsParams{
+			RefName: refName,
+			Type:    api.RefTypeWip,
+		}, api.BatchPathExistsJSONRequestBody{Paths: batch})
+		if err != nil {
+			return err
+		}
+		existsResp, err := api.ParseBatchPathExistsResponse(resp)
+		if err != nil {
+			return err
+		}
+		if existsResp.JSON200 == nil {
+			return fmt.Errorf("list remote dirs failed %d %s", existsResp.StatusCode(), string(existsResp.Body))
+		}
+
+		lk.Lock()
+		defer lk.Unlock()
+		for _, result := range *existsResp.JSON200 {
+			if result.Entries == nil {
+				continue
+			}
+			for _, entry := range *result.Entries {
+				remoteEntries[path2.Join(result.Path, entry.Name)] = entry
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return remoteEntries, nil
+}
+
+// runParallel call fn on items with at most parallel goroutines, stop at first error
+func runParallel[T any](ctx context.Context, items []T, parallel int, fn func(T) error) error {
+	itemCh := make(chan T)
+	errCh := make(chan error, parallel)
+	wg := sync.WaitGroup{}
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for item := range itemCh {
+				if err := fn(item); err != nil {
+					errCh <- err
+					return
+				}
+			}
+		}()
+	}
+
+	var err error
+LOOP:
+	for _, item := range items {
+		select {
+		case <-ctx.Done():
+			err = ctx.Err()
+			break LOOP
+		case err = <-errCh:
+			break LOOP
+		case itemCh <- item:
+		}
+	}
+	close(itemCh)
+	wg.Wait()
+	close(errCh)
+	if err != nil {
+		return err
+	}
+	return <-errCh
+}
+
+// makeSyncPlan compare local files with remote entries, ignored remote files are never deleted
+func makeSyncPlan(localFiles map[string]*localFile, dirs map[string]struct{}, remoteEntries map[string]api.FullTreeEntry, deleteRemote bool, ignored func(remotePath string, isDir bool) bool) *syncPlan {
+	plan := &syncPlan{}
+	for remotePath, file := range localFiles {
+		entry, ok := remoteEntries[remotePath]
+		if ok && !entry.IsDir && entry.Hash == file.hash {
+			plan.unchanged++
+			continue
+		}
+		plan.uploads = append(plan.uploads, remotePath)
+	}
+
+	if deleteRemote {
+		for remotePath, entry := range remoteEntries {
+			if _, ok := localFiles[remotePath]; ok && !entry.IsDir {
+				continue
+			}
+			if _, ok := dirs[remotePath]; ok && entry.IsDir {
+				continue
+			}
+			if ignored(remotePath, entry.IsDir) {
+				continue
+			}
+			plan.deletes = append(plan.deletes, remotePath)
+		}
+	}
+	sort.Strings(plan.uploads)
+	//delete deeper path first
+	sort.Slice(plan.deletes, func(i, j int) bool {
+		return strings.Count(plan.deletes[i], "/") > strings.Count(plan.deletes[j], "/")
+	})
+	return plan
+}
+
+func init() {
+	rootCmd.AddCommand(syncCmd)
+
+	syncCmd.Flags().String("path", "", "local directory to sync")
+	syncCmd.Flags().String("owner", "", "owner")
+	syncCmd.Flags().String("repo", "", "repo")
+	syncCmd.Flags().String("ref-name", "main", "branch name")
+	syncCmd.Flags().String("upload-path", "", "path to save in server")
+	syncCmd.Flags().Bool("delete", false, "delete remote files which not exist in local directory")
+	syncCmd.Flags().Bool("dry-run", false, "only print sync plan")
+	syncCmd.Flags().Int("parallel", 8, "parallelism of hashing, remote listing and transfers")
+	syncCmd.Flags().String("cache", "", "path of local change detection cache (default is $HOME/.jiaozifs/sync-cache/<dir>.json)")
+	syncCmd.Flags().Bool("no-ignore", false, "sync all files, do not respect rules in "+pathutil.IgnoreFileName)
+}
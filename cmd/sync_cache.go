@@ -0,0 +1,130 @@
+package cmd
+
+import (
+	"crypto/md5" //nolint
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/mitchellh/go-homedir"
+)
+
+var defaultSyncCacheDir = "~/.jiaozifs/sync-cache"
+
+// syncCacheEntry remember the checksum of a local file, valid while size and mtime not changed
+type syncCacheEntry struct {
+	Size     int64     `json:"size"`
+	ModTime  time.Time `json:"mod_time"`
+	CheckSum string    `json:"check_sum"`
+}
+
+// syncCache is a local cache of (path, size, mtime, hash), used to skip rehash unchanged files
+type syncCache struct {
+	lk      sync.Mutex
+	path    string
+	Entries map[string]syncCacheEntry `json:"entries"`
+}
+
+func syncCachePathFor(localDir string) (string, error) {
+	dir, err := homedir.Expand(defaultSyncCacheDir)
+	if err != nil {
+		return "", err
+	}
+	absPath, err := filepath.Abs(localDir)
+	if err != nil {
+		return "", err
+	}
+	sum := md5.Sum([]byte(absPath)) //nolint
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json"), nil
+}
+
+func loadSyncCache(path string) (*syncCache, error) {
+	cache := &syncCache{
+		path:    path,
+		Entries: make(map[string]syncCacheEntry),
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return cache, nil
+		}
+		return nil, err
+	}
+	err = json.Unmarshal(data, cache)
+	if err != nil {
+		//broken cache just rebuild it
+		return &syncCache{path: path, Entries: make(map[string]syncCacheEntry)}, nil //nolint
+	}
+	if cache.Entries == nil {
+		cache.Entries = make(map[string]syncCacheEntry)
+	}
+	return cache, nil
+}
+
+// CheckSum return md5 checksum of local file, read from cache if file not changed
+func (cache *syncCache) CheckSum(file string, info os.FileInfo) (hash.Hash, error) {
+	cache.lk.Lock()
+	entry, ok := cache.Entries[file]
+	cache.lk.Unlock()
+	if ok && entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime()) {
+		return hash.FromHex(entry.CheckSum)
+	}
+
+	fs, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer fs.Close() //nolint
+
+	hasher := md5.New() //nolint
+	_, err = io.Copy(hasher, fs)
+	if err != nil {
+		return nil, err
+	}
+	checkSum := hash.Hash(hasher.Sum(nil))
+
+	cache.lk.Lock()
+	cache.Entries[file] = syncCacheEntry{
+		Size:     info.Size(),
+		ModTime:  info.ModTime(),
+		CheckSum: checkSum.Hex(),
+	}
+	cache.lk.Unlock()
+	return checkSum, nil
+}
+
+// Prune remove entries of files not exist any more
+func (cache *syncCache) Prune(exists map[string]struct{}) {
+	cache.lk.Lock()
+	defer cache.lk.Unlock()
+	for file := range cache.Entries {
+		if _, ok := exists[file]; !ok {
+			delete(cache.Entries, file)
+		}
+	}
+}
+
+func (cache *syncCache) Save() error {
+	cache.lk.Lock()
+	data, err := json.Marshal(cache)
+	cache.lk.Unlock()
+	if err != nil {
+		return err
+	}
+	err = os.MkdirAll(filepath.Dir(cache.path), 0755)
+	if err != nil {
+		return err
+	}
+	tmpPath := cache.path + ".tmp"
+	err = os.WriteFile(tmpPath, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, cache.path)
+}
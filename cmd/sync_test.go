@@ -0,0 +1,146 @@
+package cmd
+
+import (
+	"crypto/md5" //nolint
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMakeSyncPlan(t *testing.T) {
+	localFiles := map[string]*localFile{
+		"/data/new.txt":       {hash: "h1"},
+		"/data/same.txt":      {hash: "h2"},
+		"/data/changed.txt":   {hash: "h3"},
+		"/data/sub/a.txt":     {hash: "h4"},
+		"/data/was_dir":       {hash: "h5"},
+		"/data/sub/deep/b.md": {hash: "h6"},
+	}
+	dirs := map[string]struct{}{
+		"/data":          {},
+		"/data/sub":      {},
+		"/data/sub/deep": {},
+	}
+	remoteEntries := map[string]api.FullTreeEntry{
+		"/data/same.txt":      {Hash: "h2"},
+		"/data/changed.txt":   {Hash: "old"},
+		"/data/was_dir":       {Hash: "h5", IsDir: true},
+		"/data/sub":           {IsDir: true},
+		"/data/sub/deep":      {IsDir: true},
+		"/data/sub/deep/b.md": {Hash: "h6"},
+		"/data/gone.txt":      {Hash: "h7"},
+		"/data/sub/gone.txt":  {Hash: "h8"},
+		"/data/gone_dir":      {IsDir: true},
+		"/data/ignored.log":   {Hash: "h9"},
+	}
+	ignored := func(remotePath string, _ bool) bool {
+		return filepath.Ext(remotePath) == ".log"
+	}
+
+	cases := []struct {
+		name         string
+		deleteRemote bool
+		uploads      []string
+		deletes      []string
+		unchanged    int
+	}{
+		{
+			name:      "keep remote files",
+			uploads:   []string{"/data/changed.txt", "/data/new.txt", "/data/sub/a.txt", "/data/was_dir"},
+			unchanged: 2,
+		},
+		{
+			name:         "delete remote files",
+			deleteRemote: true,
+			uploads:      []string{"/data/changed.txt", "/data/new.txt", "/data/sub/a.txt", "/data/was_dir"},
+			deletes:      []string{"/data/sub/gone.txt", "/data/gone.txt", "/data/gone_dir", "/data/was_dir"},
+			unchanged:    2,
+		},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			plan := makeSyncPlan(localFiles, dirs, remoteEntries, c.deleteRemote, ignored)
+			require.Equal(t, c.uploads, plan.uploads)
+			require.Equal(t, c.unchanged, plan.unchanged)
+			require.ElementsMatch(t, c.deletes, plan.deletes)
+			for i := 1; i < len(plan.deletes); i++ {
+				require.GreaterOrEqual(t, strings.Count(plan.deletes[i-1], "/"), strings.Count(plan.deletes[i], "/"), "deeper path deleted first")
+			}
+		})
+	}
+}
+
+func TestSyncCache(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	require.NoError(t, os.WriteFile(file, []byte("hello"), 0644))
+	sum := md5.Sum([]byte("hello")) //nolint
+	helloSum := hex.EncodeToString(sum[:])
+
+	cachePath := filepath.Join(dir, "cache", "sync.json")
+	cache, err := loadSyncCache(cachePath)
+	require.NoError(t, err)
+	require.Empty(t, cache.Entries)
+
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+
+	t.Run("hash and remember", func(t *testing.T) {
+		checkSum, err := cache.CheckSum(file, info)
+		require.NoError(t, err)
+		require.Equal(t, helloSum, checkSum.Hex())
+		require.Equal(t, helloSum, cache.Entries[file].CheckSum)
+	})
+
+	t.Run("unchanged file read from cache", func(t *testing.T) {
+		entry := cache.Entries[file]
+		entry.CheckSum = "00112233445566778899aabbccddeeff"
+		cache.Entries[file] = entry
+
+		checkSum, err := cache.CheckSum(file, info)
+		require.NoError(t, err)
+		require.Equal(t, "00112233445566778899aabbccddeeff", checkSum.Hex())
+	})
+
+	t.Run("changed file rehashed", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(file, []byte("hello world"), 0644))
+		modTime := info.ModTime().Add(time.Second)
+		require.NoError(t, os.Chtimes(file, modTime, modTime))
+		info, err = os.Stat(file)
+		require.NoError(t, err)
+
+		checkSum, err := cache.CheckSum(file, info)
+		require.NoError(t, err)
+		sum := md5.Sum([]byte("hello world")) //nolint
+		require.Equal(t, hex.EncodeToString(sum[:]), checkSum.Hex())
+	})
+
+	t.Run("save and load", func(t *testing.T) {
+		require.NoError(t, cache.Save())
+		loaded, err := loadSyncCache(cachePath)
+		require.NoError(t, err)
+		require.Equal(t, cache.Entries[file].CheckSum, loaded.Entries[file].CheckSum)
+		require.Equal(t, cache.Entries[file].Size, loaded.Entries[file].Size)
+		require.True(t, cache.Entries[file].ModTime.Equal(loaded.Entries[file].ModTime))
+	})
+
+	t.Run("prune", func(t *testing.T) {
+		cache.Entries["/removed.txt"] = syncCacheEntry{CheckSum: helloSum}
+		cache.Prune(map[string]struct{}{file: {}})
+		require.Len(t, cache.Entries, 1)
+		require.Contains(t, cache.Entries, file)
+	})
+
+	t.Run("broken cache rebuilt", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(cachePath, []byte("{broken"), 0644))
+		loaded, err := loadSyncCache(cachePath)
+		require.NoError(t, err)
+		require.Empty(t, loaded.Entries)
+	})
+}
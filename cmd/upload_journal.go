@@ -0,0 +1,115 @@
+package cmd
+
+import (
+	"crypto/md5" //nolint
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/mitchellh/go-homedir"
+)
+
+var defaultJournalDir = "~/.jiaozifs/journal"
+
+// journalEntry record a file which has been uploaded successfully
+type journalEntry struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mod_time"`
+}
+
+// uploadJournal record finished files of a upload task, so that an interrupted task can continue where it stopped
+type uploadJournal struct {
+	lk      sync.Mutex
+	path    string
+	Target  string                  `json:"target"`
+	Entries map[string]journalEntry `json:"entries"`
+}
+
+// journalPathFor return a stable journal file path for a upload target
+func journalPathFor(target string) (string, error) {
+	dir, err := homedir.Expand(defaultJournalDir)
+	if err != nil {
+		return "", err
+	}
+	sum := md5.Sum([]byte(target)) //nolint
+	return filepath.Join(dir, hex.EncodeToString(sum[:])+".json"), nil
+}
+
+// loadUploadJournal load journal from path, return an empty journal if not exist
+func loadUploadJournal(path string, target string) (*uploadJournal, error) {
+	journal := &uploadJournal{
+		path:    path,
+		Target:  target,
+		Entries: make(map[string]journalEntry),
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return journal, nil
+		}
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, journal)
+	if err != nil {
+		return nil, err
+	}
+	if journal.Entries == nil {
+		journal.Entries = make(map[string]journalEntry)
+	}
+	return journal, nil
+}
+
+// IsDone check whether the file has been uploaded and not changed since then
+func (journal *uploadJournal) IsDone(destPath string, info os.FileInfo) bool {
+	journal.lk.Lock()
+	defer journal.lk.Unlock()
+	entry, ok := journal.Entries[destPath]
+	if !ok {
+		return false
+	}
+	return entry.Size == info.Size() && entry.ModTime.Equal(info.ModTime())
+}
+
+// MarkDone record file as uploaded and flush journal to disk
+func (journal *uploadJournal) MarkDone(destPath string, info os.FileInfo) error {
+	journal.lk.Lock()
+	defer journal.lk.Unlock()
+	journal.Entries[destPath] = journalEntry{
+		Size:    info.Size(),
+		ModTime: info.ModTime(),
+	}
+	return journal.save()
+}
+
+func (journal *uploadJournal) save() error {
+	data, err := json.Marshal(journal)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(journal.path), 0755)
+	if err != nil {
+		return err
+	}
+	// write to temp file and rename, avoid broken journal when process was killed
+	tmpPath := journal.path + ".tmp"
+	err = os.WriteFile(tmpPath, data, 0644)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, journal.path)
+}
+
+// Remove delete journal file after the whole task finished
+func (journal *uploadJournal) Remove() error {
+	err := os.Remove(journal.path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,121 @@
+package cmd
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadJournal(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "a.txt")
+	require.NoError(t, os.WriteFile(file, []byte("hello"), 0644))
+	info, err := os.Stat(file)
+	require.NoError(t, err)
+
+	journalPath := filepath.Join(dir, "journal", "upload.json")
+	journal, err := loadUploadJournal(journalPath, "o/r@main:/data")
+	require.NoError(t, err)
+	require.Empty(t, journal.Entries)
+	require.False(t, journal.IsDone("/data/a.txt", info))
+
+	t.Run("mark done", func(t *testing.T) {
+		require.NoError(t, journal.MarkDone("/data/a.txt", info))
+		require.True(t, journal.IsDone("/data/a.txt", info))
+		require.False(t, journal.IsDone("/data/b.txt", info))
+		require.FileExists(t, journalPath)
+		require.NoFileExists(t, journalPath+".tmp")
+	})
+
+	t.Run("resume from disk", func(t *testing.T) {
+		loaded, err := loadUploadJournal(journalPath, "o/r@main:/data")
+		require.NoError(t, err)
+		require.Equal(t, "o/r@main:/data", loaded.Target)
+		require.True(t, loaded.IsDone("/data/a.txt", info))
+	})
+
+	t.Run("changed file upload again", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(file, []byte("hello world"), 0644))
+		modTime := info.ModTime().Add(time.Second)
+		require.NoError(t, os.Chtimes(file, modTime, modTime))
+		changed, err := os.Stat(file)
+		require.NoError(t, err)
+		require.False(t, journal.IsDone("/data/a.txt", changed))
+	})
+
+	t.Run("broken journal", func(t *testing.T) {
+		brokenPath := filepath.Join(dir, "broken.json")
+		require.NoError(t, os.WriteFile(brokenPath, []byte("{broken"), 0644))
+		_, err := loadUploadJournal(brokenPath, "o/r@main:/data")
+		require.Error(t, err)
+	})
+
+	t.Run("remove", func(t *testing.T) {
+		require.NoError(t, journal.Remove())
+		require.NoFileExists(t, journalPath)
+		require.NoError(t, journal.Remove(), "remove twice")
+	})
+}
+
+func TestJournalPathFor(t *testing.T) {
+	a, err := journalPathFor("o/r@main:/data")
+	require.NoError(t, err)
+	again, err := journalPathFor("o/r@main:/data")
+	require.NoError(t, err)
+	b, err := journalPathFor("o/r@dev:/data")
+	require.NoError(t, err)
+
+	require.Equal(t, a, again)
+	require.NotEqual(t, a, b)
+	require.Equal(t, ".json", filepath.Ext(a))
+}
+
+func TestTransferStats(t *testing.T) {
+	out := &bytes.Buffer{}
+	stats := newTransferStats(2, 15)
+	stats.out = out
+
+	stats.Skip(5)
+	reader := stats.Wrap(strings.NewReader("0123456789"), "b.txt", 10)
+	buf := &bytes.Buffer{}
+	_, err := buf.ReadFrom(reader)
+	require.NoError(t, err)
+	stats.FileDone()
+
+	require.Equal(t, "0123456789", buf.String())
+	require.Equal(t, int64(2), stats.doneFiles)
+	require.Equal(t, int64(1), stats.skippedFiles)
+	require.Equal(t, int64(15), stats.doneBytes)
+	require.Contains(t, out.String(), "b.txt")
+	require.Contains(t, out.String(), "100.0%")
+
+	out.Reset()
+	stats.Summary()
+	require.Contains(t, out.String(), "2 files (1 skipped)")
+}
+
+func TestProgressFormat(t *testing.T) {
+	cases := []struct {
+		done, total int64
+		bar         string
+		percent     string
+	}{
+		{done: 0, total: 10, bar: "[          ]", percent: "  0.0%"},
+		{done: 5, total: 10, bar: "[=====     ]", percent: " 50.0%"},
+		{done: 12, total: 10, bar: "[==========]", percent: "120.0%"},
+		{done: 0, total: 0, bar: "[==========]", percent: "100.0%"},
+	}
+	for _, c := range cases {
+		require.Equal(t, c.bar, progressBar(c.done, c.total, 10))
+		require.Equal(t, c.percent, percent(c.done, c.total))
+	}
+
+	require.Equal(t, "512 B", humanBytes(512))
+	require.Equal(t, "1.5 KiB", humanBytes(1536))
+	require.Equal(t, "3.0 GiB", humanBytes(3<<30))
+}
@@ -40,6 +40,8 @@ type BlockStoreConfig struct {
 			SessionDuration     time.Duration `mapstructure:"session_duration" json:"session_duration"`
 			SessionExpiryWindow time.Duration `mapstructure:"session_expiry_window" json:"session_expiry_window"`
 		} `mapstructure:"web_identity"`
+		AssumeRoleARN      string `mapstructure:"assume_role_arn" json:"assume_role_arn"`
+		AssumeRoleEndpoint string `mapstructure:"assume_role_endpoint" json:"assume_role_endpoint"`
 	} `mapstructure:"s3" json:"s3"`
 	Azure *struct {
 		TryTimeout         time.Duration `mapstructure:"try_timeout" json:"try_timeout"`
@@ -51,6 +53,11 @@ type BlockStoreConfig struct {
 		// TestEndpointURL for testing purposes
 		TestEndpointURL string `mapstructure:"test_endpoint_url" json:"test_endpoint_url"`
 	} `mapstructure:"azure" json:"azure"`
+	// Spool optional, write uploads to local disk and flush them to blockstore asynchronously
+	Spool *struct {
+		Path    string `mapstructure:"path" json:"path"`
+		Workers int    `mapstructure:"workers" json:"workers"`
+	} `mapstructure:"spool" json:"spool"`
 	GS *struct {
 		S3Endpoint         string        `mapstructure:"s3_endpoint" json:"s3_endpoint"`
 		CredentialsFile    string        `mapstructure:"credentials_file" json:"credentials_file"`
@@ -105,6 +112,8 @@ func (c *BlockStoreConfig) BlockstoreS3Params() (params.S3, error) {
 		ClientLogRetries:              c.S3.ClientLogRetries,
 		ClientLogRequest:              c.S3.ClientLogRequest,
 		WebIdentity:                   webIdentity,
+		AssumeRoleARN:                 c.S3.AssumeRoleARN,
+		AssumeRoleEndpoint:            c.S3.AssumeRoleEndpoint,
 	}, nil
 }
 
@@ -146,6 +155,20 @@ func (c *BlockStoreConfig) BlockstoreAzureParams() (params.Azure, error) {
 	}, nil
 }
 
+func (c *BlockStoreConfig) BlockstoreSpoolParams() (*params.Spool, error) {
+	if c.Spool == nil || len(c.Spool.Path) == 0 {
+		return nil, nil
+	}
+	spoolPath, err := homedir.Expand(c.Spool.Path)
+	if err != nil {
+		return nil, fmt.Errorf("parse spool path %s: %w", c.Spool.Path, err)
+	}
+	return &params.Spool{
+		Path:    spoolPath,
+		Workers: c.Spool.Workers,
+	}, nil
+}
+
 type SecureString string
 
 // String returns an elided version.  It is safe to call for logging.
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"os"
 	"path"
+	"time"
 
 	"github.com/mitchellh/go-homedir"
 	ms "github.com/mitchellh/mapstructure"
@@ -32,11 +33,81 @@ type LogConfig struct {
 
 type APIConfig struct {
 	Listen string `mapstructure:"listen"`
+	// BatchCommitWindow writes of batch commit mode to the same branch within this window are coalesced into one commit
+	BatchCommitWindow time.Duration `mapstructure:"batch_commit_window"`
+	// BatchCommitMaxWrites batch is committed before window ends when it reaches this number of writes
+	BatchCommitMaxWrites int `mapstructure:"batch_commit_max_writes"`
+	// MaxUploadSessions multipart upload sessions a user can have in progress, zero means unlimited
+	MaxUploadSessions int `mapstructure:"max_upload_sessions"`
+	// UploadSessionTTL upload session idle longer than ttl is aborted and its parts dropped from storage
+	UploadSessionTTL time.Duration `mapstructure:"upload_session_ttl"`
+	// UploadReapInterval how often stale upload sessions are checked
+	UploadReapInterval time.Duration `mapstructure:"upload_reap_interval"`
+	// RepackInterval how often fragmented packs of repositories are consolidated
+	RepackInterval time.Duration `mapstructure:"repack_interval"`
+	// RepackBytesPerSecond limit of bytes read from storage by repacking, zero means unlimited
+	RepackBytesPerSecond int64 `mapstructure:"repack_bytes_per_second"`
+	// AutoMergeInterval how often merge requests queued for auto merge are retried
+	AutoMergeInterval time.Duration `mapstructure:"auto_merge_interval"`
+	// CleanInterval how often unfinished deletion jobs of purged repositories are retried
+	CleanInterval time.Duration `mapstructure:"clean_interval"`
+	// CleanBatchSize rows of purged repository removed by a single statement
+	CleanBatchSize int `mapstructure:"clean_batch_size"`
+	// UsageReportInterval how often pending usage reports of repositories are generated
+	UsageReportInterval time.Duration `mapstructure:"usage_report_interval"`
+	// AsyncCommitInterval how often pending tickets of async commits are looked for besides ones triggered by api
+	AsyncCommitInterval time.Duration `mapstructure:"async_commit_interval"`
+	// AsyncCommitWorkers branches committed concurrently by async commits
+	AsyncCommitWorkers int `mapstructure:"async_commit_workers"`
+	// MaxCommitEntries files a single commit can change, zero means unlimited
+	MaxCommitEntries int `mapstructure:"max_commit_entries"`
+	// MaxCommitBytes total size of files a single commit can add or modify, zero means unlimited
+	MaxCommitBytes int64 `mapstructure:"max_commit_bytes"`
+	// QuotaWarningThresholds percentages of storage quota of repository at which commits record a quota warning event
+	QuotaWarningThresholds []int `mapstructure:"quota_warning_thresholds"`
+	// MaxDecodedBodySize bytes a gzip or zstd encoded request body can decode to, larger requests are rejected with 413.
+	// zero means unlimited
+	MaxDecodedBodySize int64 `mapstructure:"max_decoded_body_size"`
+	// HTTP connection settings of api server
+	HTTP HTTPConfig `mapstructure:"http"`
 }
 
+// HTTPConfig tune connections of api server for clients sending many small requests concurrently, zero values fall
+// back to defaults of server
+type HTTPConfig struct {
+	// EnableHTTP2 serve HTTP/2 over cleartext (h2c) besides HTTP/1.1, so a client multiplex requests on one connection
+	EnableHTTP2 bool `mapstructure:"enable_http2"`
+	// MaxConcurrentStreams streams a HTTP/2 client can open on one connection
+	MaxConcurrentStreams uint32 `mapstructure:"max_concurrent_streams"`
+	// IdleTimeout keep-alive connection without request longer than it is closed
+	IdleTimeout time.Duration `mapstructure:"idle_timeout"`
+	// ReadHeaderTimeout time allowed to read headers of a request
+	ReadHeaderTimeout time.Duration `mapstructure:"read_header_timeout"`
+}
+
+const (
+	DatabaseTypePostgres = "postgres"
+	DatabaseTypeSQLite   = "sqlite"
+)
+
 type DatabaseConfig struct {
+	// Type postgres or sqlite, connection of sqlite is path of database file
+	Type       string `mapstructure:"type"`
 	Connection string `mapstructure:"connection"`
 	Debug      bool   `mapstructure:"debug"`
+	// ObjectPartitions number of hash partitions by repository of objects table on postgres, not partitioned if less
+	// than 2. only applied when migration rebuilding objects table runs
+	ObjectPartitions int `mapstructure:"object_partitions"`
+	// QueryTimeout cancel statements running longer than it on postgres, zero means no timeout
+	QueryTimeout time.Duration `mapstructure:"query_timeout"`
+	// MaxRetries retry statements of api failed by bad connection and transactions failed by serialization failure,
+	// deadlock or bad connection at most this times
+	MaxRetries int `mapstructure:"max_retries"`
+	// RetryBackoff wait before first retry, doubled by every retry
+	RetryBackoff time.Duration `mapstructure:"retry_backoff"`
+	// ReplicaConnection read replica of postgres serving GET requests of api, requests presenting consistency token
+	// returned by a write read from primary until replica replays the write. empty means all reads use primary
+	ReplicaConnection string `mapstructure:"replica_connection"`
 }
 
 type AuthConfig struct {
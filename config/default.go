@@ -2,6 +2,7 @@ package config
 
 import (
 	"encoding/hex"
+	"time"
 )
 
 var DefaultLocalBSPath = "~/.jiaozifs/blockstore"
@@ -12,7 +13,36 @@ var defaultCfg = Config{
 		Level: "INFO",
 	},
 	API: APIConfig{
-		Listen: "http://127.0.0.1:34913",
+		Listen:                 "http://127.0.0.1:34913",
+		BatchCommitWindow:      200 * time.Millisecond,
+		BatchCommitMaxWrites:   1000,
+		MaxUploadSessions:      100,
+		UploadSessionTTL:       24 * time.Hour,
+		UploadReapInterval:     10 * time.Minute,
+		RepackInterval:         6 * time.Hour,
+		RepackBytesPerSecond:   16 << 20,
+		AutoMergeInterval:      time.Minute,
+		CleanInterval:          time.Minute,
+		CleanBatchSize:         1000,
+		UsageReportInterval:    time.Minute,
+		AsyncCommitInterval:    time.Minute,
+		AsyncCommitWorkers:     4,
+		MaxCommitEntries:       100000,
+		QuotaWarningThresholds: []int{80, 90},
+		MaxDecodedBodySize:     1 << 30,
+		HTTP: HTTPConfig{
+			EnableHTTP2:          true,
+			MaxConcurrentStreams: 250,
+			IdleTimeout:          2 * time.Minute,
+			ReadHeaderTimeout:    30 * time.Second,
+		},
+	},
+	Database: DatabaseConfig{
+		Type:             DatabaseTypePostgres,
+		ObjectPartitions: 16,
+		QueryTimeout:     30 * time.Second,
+		MaxRetries:       3,
+		RetryBackoff:     50 * time.Millisecond,
 	},
 	Blockstore: BlockStoreConfig{
 		Type: "local",
@@ -0,0 +1,89 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/GitDataAI/jiaozifs/auth/rbac"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/models/rbacmodel"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/google/uuid"
+	"go.uber.org/fx"
+)
+
+// AuditLogController expose audit logs recorded by audit middleware to admins
+type AuditLogController struct {
+	fx.In
+	BaseController
+
+	Repo models.IRepo
+}
+
+func (auditCtl AuditLogController) ListAuditLogs(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, params api.ListAuditLogsParams) {
+	// audit logs cover every user and repository, only policies granting all resources pass
+	if !auditCtl.authorize(ctx, w, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.ListAuditLogsAction,
+			Resource: rbacmodel.All,
+		},
+	}) {
+		return
+	}
+
+	listParams := models.NewListAuditLogParams()
+	if params.ActorId != nil {
+		listParams.SetActorID(*params.ActorId)
+	}
+	if params.RepositoryId != nil {
+		listParams.SetRepositoryID(*params.RepositoryId)
+	}
+	if params.Action != nil {
+		listParams.SetAction(*params.Action)
+	}
+	if params.After != nil {
+		listParams.SetAfter(time.UnixMilli(*params.After))
+	}
+	pageAmount := utils.IntValue(params.Amount)
+	if pageAmount > utils.DefaultMaxPerPage || pageAmount <= 0 {
+		listParams.SetAmount(utils.DefaultMaxPerPage)
+	} else {
+		listParams.SetAmount(pageAmount)
+	}
+
+	logs, hasMore, err := auditCtl.Repo.AuditLogRepo().List(ctx, listParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	results := utils.Silent(utils.ArrMap(logs, auditLogToDto))
+	pagMag := utils.PaginationFor(hasMore, results, "CreatedAt")
+	pagination := api.Pagination{
+		HasMore:    pagMag.HasMore,
+		MaxPerPage: pagMag.MaxPerPage,
+		NextOffset: pagMag.NextOffset,
+		Results:    pagMag.Results,
+	}
+	w.JSON(api.AuditLogList{
+		Pagination: pagination,
+		Results:    results,
+	})
+}
+
+func auditLogToDto(in *models.AuditLog) (api.AuditLog, error) {
+	auditLog := api.AuditLog{
+		Id:         in.ID,
+		ActorId:    in.ActorID,
+		Action:     in.Action,
+		Targets:    in.Targets,
+		RemoteAddr: in.RemoteAddr,
+		CreatedAt:  in.CreatedAt.UnixMilli(),
+	}
+	if in.RepositoryID != uuid.Nil {
+		repositoryID := in.RepositoryID
+		auditLog.RepositoryId = &repositoryID
+	}
+	return auditLog, nil
+}
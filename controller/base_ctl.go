@@ -11,6 +11,12 @@ import (
 	"github.com/GitDataAI/jiaozifs/auth"
 
 	"github.com/GitDataAI/jiaozifs/auth/rbac"
+	"github.com/GitDataAI/jiaozifs/config"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/models/rbacmodel"
+	"github.com/GitDataAI/jiaozifs/utils/masking"
+	"github.com/GitDataAI/jiaozifs/utils/ratelimit"
+	"github.com/GitDataAI/jiaozifs/versionmgr"
 	"go.uber.org/fx"
 )
 
@@ -18,6 +24,7 @@ type BaseController struct {
 	fx.In
 
 	PermissionCheck rbac.PermissionCheck
+	Limiter         *ratelimit.Limiter
 }
 
 func (c *BaseController) authorize(ctx context.Context, w *api.JiaozifsResponse, perms rbac.Node) bool {
@@ -73,3 +80,89 @@ func (c *BaseController) authorizeMember(ctx context.Context, w *api.JiaozifsRes
 	}
 	return true
 }
+
+// maskedColumns return columns of objPath masked for operator, nil if file is not masked or operator may read it
+// unmasked
+func (c *BaseController) maskedColumns(ctx context.Context, repository *models.Repository, objPath string) (map[string]masking.Method, error) {
+	if _, ok := masking.FormatFromPath(objPath); !ok {
+		return nil, nil
+	}
+	columns := repository.MaskedColumns(objPath)
+	if len(columns) == 0 {
+		return nil, nil
+	}
+
+	unmask, err := c.canUnmask(ctx, repository)
+	if err != nil || unmask {
+		return nil, err
+	}
+	return maskMethodsFromModel(columns), nil
+}
+
+// canUnmask check whether operator may read files of repository without masking rules applied
+func (c *BaseController) canUnmask(ctx context.Context, repository *models.Repository) (bool, error) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		return false, err
+	}
+	resp, err := c.PermissionCheck.AuthorizeMember(ctx, repository.ID, &rbac.AuthorizationRequest{
+		OperatorID: operator.ID,
+		RequiredPermissions: rbac.Node{
+			Permission: rbac.Permission{
+				Action:   rbacmodel.UnmaskObjectAction,
+				Resource: rbacmodel.RepoURArn(repository.OwnerID.String(), repository.ID.String()),
+			},
+		},
+	})
+	if err != nil {
+		return false, err
+	}
+	return resp.Allowed, nil
+}
+
+// throttleWrite count a write to repository, response with 429 if write limits of repository exceeded
+func (c *BaseController) throttleWrite(w *api.JiaozifsResponse, repository *models.Repository) bool {
+	err := c.Limiter.Allow(repository.ID.String(), repositoryLimits(repository))
+	if err != nil {
+		w.Error(err)
+		return false
+	}
+	return true
+}
+
+// throttleUpload count a write and take an upload slot of repository, release must be called when upload finish
+func (c *BaseController) throttleUpload(w *api.JiaozifsResponse, repository *models.Repository) (func(), bool) {
+	release, err := c.Limiter.AcquireUpload(repository.ID.String(), repositoryLimits(repository))
+	if err != nil {
+		w.Error(err)
+		return nil, false
+	}
+	return release, true
+}
+
+func repositoryLimits(repository *models.Repository) ratelimit.Limits {
+	return ratelimit.Limits{
+		WritesPerMinute:      repository.WriteRateLimit,
+		Burst:                repository.WriteBurst,
+		MaxConcurrentUploads: repository.MaxConcurrentUploads,
+	}
+}
+
+// commitLimits limits of commits made by api, bulk import is not limited
+func commitLimits(cfg *config.APIConfig) versionmgr.CommitLimits {
+	if cfg == nil {
+		return versionmgr.CommitLimits{}
+	}
+	return versionmgr.CommitLimits{
+		MaxEntries: cfg.MaxCommitEntries,
+		MaxBytes:   cfg.MaxCommitBytes,
+	}
+}
+
+// quotaWarningThresholds thresholds of storage quota warned by commits made by api, nil falls back to defaults
+func quotaWarningThresholds(cfg *config.APIConfig) []int {
+	if cfg == nil {
+		return nil
+	}
+	return cfg.QuotaWarningThresholds
+}
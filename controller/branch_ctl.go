@@ -2,9 +2,7 @@ package controller
 
 import (
 	"context"
-	"errors"
 	"net/http"
-	"strings"
 
 	"github.com/GitDataAI/jiaozifs/auth/rbac"
 	"github.com/GitDataAI/jiaozifs/block/params"
@@ -51,7 +49,11 @@ func (bct BranchController) ListBranches(ctx context.Context, w *api.JiaozifsRes
 
 	listBranchParams := models.NewListBranchParams()
 	if params.Prefix != nil && len(*params.Prefix) > 0 {
-		listBranchParams.SetName(*params.Prefix, models.PrefixMatch)
+		match, ok := matchModeFromDto(w, *params.Prefix, params.Match)
+		if !ok {
+			return
+		}
+		listBranchParams.SetName(*params.Prefix, match)
 	}
 	if params.After != nil && len(*params.After) > 0 {
 		listBranchParams.SetAfter(*params.After)
@@ -70,11 +72,17 @@ func (bct BranchController) ListBranches(ctx context.Context, w *api.JiaozifsRes
 	}
 	results := utils.Silent(utils.ArrMap(branches, branchToDto))
 	pagMag := utils.PaginationFor(hasMore, results, "Name")
+	total, err := bct.Repo.BranchRepo().Count(ctx, listBranchParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
 	pagination := api.Pagination{
 		HasMore:    pagMag.HasMore,
 		MaxPerPage: pagMag.MaxPerPage,
 		NextOffset: pagMag.NextOffset,
 		Results:    pagMag.Results,
+		Total:      &total,
 	}
 	w.JSON(api.BranchList{
 		Pagination: pagination,
@@ -82,6 +90,16 @@ func (bct BranchController) ListBranches(ctx context.Context, w *api.JiaozifsRes
 	})
 }
 
+// AddBranch create branch from head of source branch
+func (bct BranchController) AddBranch(ctx context.Context, w *api.JiaozifsResponse, r *http.Request, body api.AddBranchJSONRequestBody, ownerName string, repositoryName string) {
+	bct.CreateBranch(ctx, w, r, api.CreateBranchJSONRequestBody(body), ownerName, repositoryName)
+}
+
+// RemoveBranch delete branch, HEAD branch of repository is refused
+func (bct BranchController) RemoveBranch(ctx context.Context, w *api.JiaozifsResponse, r *http.Request, ownerName string, repositoryName string, params api.RemoveBranchParams) {
+	bct.DeleteBranch(ctx, w, r, ownerName, repositoryName, api.DeleteBranchParams{RefName: params.RefName})
+}
+
 func (bct BranchController) CreateBranch(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, body api.CreateBranchJSONRequestBody, ownerName string, repositoryName string) {
 	if err := validator.ValidateBranchName(body.Name); err != nil {
 		w.BadRequest(err.Error())
@@ -118,7 +136,7 @@ func (bct BranchController) CreateBranch(ctx context.Context, w *api.JiaozifsRes
 
 	//get source branch
 	sourceBranch, err := bct.Repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetName(body.Source).SetRepositoryID(repository.ID))
-	if err != nil && !errors.Is(err, models.ErrNotFound) {
+	if err != nil {
 		w.Error(err)
 		return
 	}
@@ -137,10 +155,6 @@ func (bct BranchController) CreateBranch(ctx context.Context, w *api.JiaozifsRes
 
 	newBranch, err := workRepo.CreateBranch(ctx, body.Name)
 	if err != nil {
-		if strings.Contains(err.Error(), "already exit") {
-			w.Code(http.StatusConflict)
-			return
-		}
 		w.Error(err)
 		return
 	}
@@ -234,6 +248,59 @@ func (bct BranchController) GetBranch(ctx context.Context, w *api.JiaozifsRespon
 	w.JSON(utils.Silent(branchToDto(ref)))
 }
 
+func (bct BranchController) UpdateBranchProtection(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, body api.UpdateBranchProtectionJSONRequestBody, ownerName string, repositoryName string, params api.UpdateBranchProtectionParams) {
+	owner, err := bct.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := bct.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetOwnerID(owner.ID).SetName(repositoryName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	// members able to merge must not be able to drop protection of branches
+	if !bct.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.WriteConfigAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	requiredApprovals := utils.IntValue(body.RequiredApprovals)
+	if requiredApprovals < 0 {
+		w.BadRequest("required approvals must not be negative")
+		return
+	}
+
+	branch, err := bct.Repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetName(params.RefName).SetRepositoryID(repository.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	err = bct.Repo.BranchRepo().UpdateProtection(ctx, branch.ID, models.BranchProtection{
+		IsProtected:       body.IsProtected,
+		AllowForcePush:    utils.BoolValue(body.AllowForcePush),
+		RequiredApprovals: requiredApprovals,
+	})
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	branch, err = bct.Repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetID(branch.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.JSON(utils.Silent(branchToDto(branch)))
+}
+
 func branchToDto(in *models.Branch) (api.Branch, error) {
 	return api.Branch{
 		CommitHash:   in.CommitHash.Hex(),
@@ -244,5 +311,10 @@ func branchToDto(in *models.Branch) (api.Branch, error) {
 		Name:         in.Name,
 		RepositoryId: in.RepositoryID,
 		UpdatedAt:    in.UpdatedAt.UnixMilli(),
+		Version:      in.Version,
+
+		IsProtected:       in.IsProtected,
+		AllowForcePush:    in.AllowForcePush,
+		RequiredApprovals: in.RequiredApprovals,
 	}, nil
 }
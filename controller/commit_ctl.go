@@ -13,6 +13,7 @@ import (
 	"github.com/GitDataAI/jiaozifs/auth"
 	"github.com/GitDataAI/jiaozifs/auth/rbac"
 	"github.com/GitDataAI/jiaozifs/block/params"
+	"github.com/GitDataAI/jiaozifs/config"
 	"github.com/GitDataAI/jiaozifs/models"
 	"github.com/GitDataAI/jiaozifs/models/rbacmodel"
 	"github.com/GitDataAI/jiaozifs/utils"
@@ -27,6 +28,7 @@ type CommitController struct {
 
 	Repo                models.IRepo
 	PublicStorageConfig params.AdapterConfig
+	AuthConfig          *config.AuthConfig
 }
 
 func (commitCtl CommitController) GetEntriesInRef(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.GetEntriesInRefParams) {
@@ -115,6 +117,12 @@ func (commitCtl CommitController) GetEntriesInRef(ctx context.Context, w *api.Ji
 			return
 		}
 
+		commitHash, err = versionmgr.ResolveHash(ctx, commitCtl.Repo, repository, commitHash)
+		if err != nil {
+			w.Error(err)
+			return
+		}
+
 		if !commitHash.IsEmpty() {
 			commit, err := commitCtl.Repo.CommitRepo(repository.ID).Commit(ctx, commitHash)
 			if err != nil {
@@ -135,7 +143,17 @@ func (commitCtl CommitController) GetEntriesInRef(ctx context.Context, w *api.Ji
 		return
 	}
 
+	hidden, err := privatePathFilter(ctx, commitCtl.Repo, repository)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
 	path := versionmgr.CleanPath(utils.StringValue(params.Path))
+	if len(path) > 0 && hidden(path) {
+		w.NotFound()
+		return
+	}
 	treeEntry, err := workTree.Ls(ctx, path)
 	if err != nil {
 		if errors.Is(err, versionmgr.ErrPathNotFound) {
@@ -145,16 +163,23 @@ func (commitCtl CommitController) GetEntriesInRef(ctx context.Context, w *api.Ji
 		w.Error(err)
 		return
 	}
-	apiTreeEntries := make([]api.FullTreeEntry, len(treeEntry))
-	for index, entry := range treeEntry {
-		apiTreeEntries[index] = api.FullTreeEntry{
+	apiTreeEntries := make([]api.FullTreeEntry, 0, len(treeEntry))
+	for _, entry := range treeEntry {
+		entryPath := entry.Name
+		if len(path) > 0 {
+			entryPath = path + "/" + entry.Name
+		}
+		if hidden(entryPath) {
+			continue
+		}
+		apiTreeEntries = append(apiTreeEntries, api.FullTreeEntry{
 			CreatedAt: entry.CreatedAt.UnixMilli(),
 			Hash:      entry.Hash.Hex(),
 			IsDir:     entry.IsDir,
 			Name:      entry.Name,
 			Size:      entry.Size,
 			UpdatedAt: entry.UpdatedAt.UnixMilli(),
-		}
+		})
 	}
 	w.JSON(apiTreeEntries)
 }
@@ -187,6 +212,17 @@ func (commitCtl CommitController) CompareCommit(ctx context.Context, w *api.Jiao
 		return
 	}
 
+	hidden, err := privatePathFilter(ctx, commitCtl.Repo, repository)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	// private path is responded as missing, not to reveal it exists
+	if hidden(utils.StringValue(params.Path)) {
+		w.NotFound()
+		return
+	}
+
 	baseHead := strings.Split(basehead, "...")
 	if len(baseHead) != 2 {
 		w.BadRequest("invalid basehead must be base...head")
@@ -217,7 +253,7 @@ func (commitCtl CommitController) CompareCommit(ctx context.Context, w *api.Jiao
 		return
 	}
 
-	changesResp, err := changesToDTO(changes)
+	changesResp, err := changesToDTO(changes, hidden)
 	if err != nil {
 		w.Error(err)
 		return
@@ -225,6 +261,92 @@ func (commitCtl CommitController) CompareCommit(ctx context.Context, w *api.Jiao
 	w.JSON(changesResp)
 }
 
+func (commitCtl CommitController) CompareContent(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, basehead string, params api.CompareContentParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := commitCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := commitCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !commitCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.ReadCommitAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	baseHead := strings.Split(basehead, "...")
+	if len(baseHead) != 2 {
+		w.BadRequest("invalid basehead must be base...head")
+		return
+	}
+
+	toCommitHash, err := hex.DecodeString(baseHead[1])
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if _, ok := versionmgr.FindMergeDriver(params.Path); !ok {
+		w.BadRequest("no merge driver registered for path %s", params.Path)
+		return
+	}
+
+	hidden, err := privatePathFilter(ctx, commitCtl.Repo, repository)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	// private path is responded as missing, not to reveal it exists
+	if hidden(params.Path) {
+		w.NotFound()
+		return
+	}
+
+	masks, err := commitCtl.maskedColumns(ctx, repository, params.Path)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, commitCtl.Repo, commitCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	err = workRepo.CheckOut(ctx, versionmgr.InCommit, baseHead[0])
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	contentDiff, err := workRepo.DiffContent(ctx, toCommitHash, params.Path, masks)
+	if errors.Is(err, versionmgr.ErrFileTooLarge) {
+		w.BadRequest("path %s is too large to compare content", params.Path)
+		return
+	}
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.JSON(contentDiffToDto(contentDiff))
+}
+
 func (commitCtl CommitController) GetCommitChanges(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, commitID string, params api.GetCommitChangesParams) {
 	operator, err := auth.GetOperator(ctx)
 	if err != nil {
@@ -253,6 +375,17 @@ func (commitCtl CommitController) GetCommitChanges(ctx context.Context, w *api.J
 		return
 	}
 
+	hidden, err := privatePathFilter(ctx, commitCtl.Repo, repository)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	// private path is responded as missing, not to reveal it exists
+	if hidden(utils.StringValue(params.Path)) {
+		w.NotFound()
+		return
+	}
+
 	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, commitCtl.Repo, commitCtl.PublicStorageConfig)
 	if err != nil {
 		w.Error(err)
@@ -271,7 +404,7 @@ func (commitCtl CommitController) GetCommitChanges(ctx context.Context, w *api.J
 		return
 	}
 
-	changesResp, err := changesToDTO(changes)
+	changesResp, err := changesToDTO(changes, hidden)
 	if err != nil {
 		w.Error(err)
 		return
@@ -279,8 +412,156 @@ func (commitCtl CommitController) GetCommitChanges(ctx context.Context, w *api.J
 	w.JSON(changesResp)
 }
 
+// GetCommit get commit with summary of its changes, summary of commits older than stats is computed on first read
+func (commitCtl CommitController) GetCommit(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, commitID string) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, commit, ok := commitCtl.commitTarget(ctx, w, ownerName, repositoryName, commitID, rbacmodel.ReadCommitAction)
+	if !ok {
+		return
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, commitCtl.Repo, commitCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	stats, err := workRepo.CommitStats(ctx, commit)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	commitDto := commitToDto(commit)
+	commitDto.Stats = &api.CommitStats{
+		FilesAdded:    stats.FilesAdded,
+		FilesRemoved:  stats.FilesRemoved,
+		FilesModified: stats.FilesModified,
+		BytesAdded:    stats.BytesAdded,
+		BytesRemoved:  stats.BytesRemoved,
+	}
+	w.JSON(commitDto)
+}
+
+// CreateCommitAttestation attach attestation of external system like ci to commit, it is signed by server secret so
+// merge checks only trust attestations created through api
+func (commitCtl CommitController) CreateCommitAttestation(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, body api.CreateCommitAttestationJSONRequestBody, ownerName string, repositoryName string, commitID string) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, commit, ok := commitCtl.commitTarget(ctx, w, ownerName, repositoryName, commitID, rbacmodel.CreateCommitAction)
+	if !ok {
+		return
+	}
+
+	secretKey, err := hex.DecodeString(commitCtl.AuthConfig.SecretKey)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	attestation, ok := newAttestation(w, body, repository.ID, commit.Hash, operator.ID, secretKey)
+	if !ok {
+		return
+	}
+	attestation, err = commitCtl.Repo.AttestationRepo().Insert(ctx, attestation)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.JSON(attestationToDto(attestation, secretKey), http.StatusCreated)
+}
+
+func (commitCtl CommitController) ListCommitAttestations(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, commitID string, params api.ListCommitAttestationsParams) {
+	repository, commit, ok := commitCtl.commitTarget(ctx, w, ownerName, repositoryName, commitID, rbacmodel.ReadCommitAction)
+	if !ok {
+		return
+	}
+
+	secretKey, err := hex.DecodeString(commitCtl.AuthConfig.SecretKey)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	listParams := models.NewListAttestationParams().SetRepositoryID(repository.ID).SetCommitHash(commit.Hash)
+	if params.Name != nil {
+		listParams.SetName(*params.Name)
+	}
+	attestations, err := commitCtl.Repo.AttestationRepo().List(ctx, listParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	results := make([]api.Attestation, 0, len(attestations))
+	for _, attestation := range attestations {
+		results = append(results, attestationToDto(attestation, secretKey))
+	}
+	w.JSON(results)
+}
+
+// commitTarget find repository and commit after operator is authorized to do action
+func (commitCtl CommitController) commitTarget(ctx context.Context, w *api.JiaozifsResponse, ownerName, repositoryName, commitID, action string) (*models.Repository, *models.Commit, bool) {
+	owner, err := commitCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return nil, nil, false
+	}
+
+	repository, err := commitCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return nil, nil, false
+	}
+
+	if !commitCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   action,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return nil, nil, false
+	}
+
+	commitHash, err := hash.FromHex(commitID)
+	if err != nil {
+		w.BadRequest("invalid commit hash %s", commitID)
+		return nil, nil, false
+	}
+	commit, err := commitCtl.Repo.CommitRepo(repository.ID).Commit(ctx, commitHash)
+	if err != nil {
+		w.Error(err)
+		return nil, nil, false
+	}
+	return repository, commit, true
+}
+
+func attestationToDto(attestation *models.Attestation, secretKey []byte) api.Attestation {
+	return api.Attestation{
+		Id:           attestation.ID,
+		RepositoryId: attestation.RepositoryID,
+		CommitHash:   attestation.CommitHash.Hex(),
+		Name:         attestation.Name,
+		Status:       api.AttestationStatus(attestation.Status),
+		Description:  attestation.Description,
+		Url:          attestation.URL,
+		CreatorId:    attestation.CreatorID,
+		Signature:    attestation.Signature,
+		Verified:     attestation.Verify(secretKey),
+		CreatedAt:    attestation.CreatedAt.UnixMilli(),
+	}
+}
+
 func commitToDto(commit *models.Commit) *api.Commit {
-	return &api.Commit{
+	commitDto := &api.Commit{
 		Author: api.Signature{
 			Email: openapi_types.Email(commit.Author.Email),
 			Name:  commit.Author.Name,
@@ -300,4 +581,8 @@ func commitToDto(commit *models.Commit) *api.Commit {
 		TreeHash:     commit.TreeHash.Hex(),
 		UpdatedAt:    commit.UpdatedAt.UnixMilli(),
 	}
+	if len(commit.Metadata) > 0 {
+		commitDto.Metadata = &commit.Metadata
+	}
+	return commitDto
 }
@@ -0,0 +1,147 @@
+package controller
+
+import (
+	"context"
+	"crypto/rand"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/GitDataAI/jiaozifs/auth"
+	"github.com/GitDataAI/jiaozifs/auth/rbac"
+	"github.com/GitDataAI/jiaozifs/block/params"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/models/rbacmodel"
+	"github.com/GitDataAI/jiaozifs/versionmgr"
+	"go.uber.org/fx"
+)
+
+const (
+	// defaultDiagnosticSize bytes transferred by diagnostics if size is not given
+	defaultDiagnosticSize int64 = 1 << 20
+	// maxDiagnosticSize keep diagnostics from being used to exhaust bandwidth or memory of server
+	maxDiagnosticSize int64 = 64 << 20
+)
+
+type DiagnosticsController struct {
+	fx.In
+	BaseController
+
+	PublicStorageConfig params.AdapterConfig
+	Repo                models.IRepo
+}
+
+// diagnosticSize return size asked for, false if it is out of range
+func diagnosticSize(size *int64) (int64, bool) {
+	if size == nil {
+		return defaultDiagnosticSize, true
+	}
+	return *size, *size >= 0 && *size <= maxDiagnosticSize
+}
+
+func (diagnosticsCtl DiagnosticsController) DiagnosticPing(_ context.Context, w *api.JiaozifsResponse, _ *http.Request) {
+	swagger, err := api.GetSwagger()
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.JSON(api.DiagnosticPing{
+		ServerTime: time.Now().UnixMilli(),
+		ApiVersion: swagger.Info.Version,
+	})
+}
+
+func (diagnosticsCtl DiagnosticsController) DiagnosticDownload(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, params api.DiagnosticDownloadParams) {
+	_, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	size, ok := diagnosticSize(params.Size)
+	if !ok {
+		w.BadRequest("size must be between 0 and %d", maxDiagnosticSize)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	w.WriteHeader(http.StatusOK)
+	_, err = io.CopyN(w, rand.Reader, size)
+	if err != nil {
+		commonLog.Debugf("write diagnostic download %v", err)
+	}
+}
+
+func (diagnosticsCtl DiagnosticsController) DiagnosticUpload(ctx context.Context, w *api.JiaozifsResponse, r *http.Request) {
+	_, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	start := time.Now()
+	received, err := io.Copy(io.Discard, http.MaxBytesReader(w, r.Body, maxDiagnosticSize))
+	if err != nil {
+		w.BadRequest(err.Error())
+		return
+	}
+	w.JSON(api.DiagnosticTransfer{
+		Bytes:      received,
+		DurationMs: time.Since(start).Milliseconds(),
+	})
+}
+
+func (diagnosticsCtl DiagnosticsController) DiagnoseStorage(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.DiagnoseStorageParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := diagnosticsCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := diagnosticsCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !diagnosticsCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.WriteObjectAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	size, ok := diagnosticSize(params.Size)
+	if !ok {
+		w.BadRequest("size must be between 0 and %d", maxDiagnosticSize)
+		return
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, diagnosticsCtl.Repo, diagnosticsCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	probe, err := workRepo.ProbeStorage(ctx, size)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.JSON(api.StorageDiagnostic{
+		BlockstoreType: probe.BlockstoreType,
+		Bytes:          probe.Bytes,
+		WriteMs:        probe.Write.Milliseconds(),
+		ReadMs:         probe.Read.Milliseconds(),
+	})
+}
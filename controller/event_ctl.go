@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/GitDataAI/jiaozifs/auth/rbac"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/models/rbacmodel"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"go.uber.org/fx"
+)
+
+// EventController expose event log of repository, consumer keep the returned cursor and read from it next time
+type EventController struct {
+	fx.In
+	BaseController
+
+	Repo models.IRepo
+}
+
+func (eventCtl EventController) ListEvents(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.ListEventsParams) {
+	owner, err := eventCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := eventCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !eventCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.ReadRepositoryAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	cursor := utils.Int64Value(params.Cursor)
+	listParams := models.NewListEventParams().SetRepositoryID(repository.ID).SetAfter(cursor)
+	pageAmount := utils.IntValue(params.Amount)
+	if pageAmount > utils.DefaultMaxPerPage || pageAmount <= 0 {
+		listParams.SetAmount(utils.DefaultMaxPerPage)
+	} else {
+		listParams.SetAmount(pageAmount)
+	}
+
+	events, hasMore, err := eventCtl.Repo.EventRepo().List(ctx, listParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	results := utils.Silent(utils.ArrMap(events, eventToDto))
+	if len(events) > 0 {
+		cursor = events[len(events)-1].Seq
+	}
+	w.JSON(api.EventList{
+		HasMore:    hasMore,
+		NextCursor: cursor,
+		Results:    results,
+	})
+}
+
+func eventToDto(in *models.Event) (api.Event, error) {
+	return api.Event{
+		Seq:          in.Seq,
+		Type:         string(in.Type),
+		RepositoryId: in.RepositoryID,
+		ActorId:      in.ActorID,
+		Payload:      in.Payload,
+		CreatedAt:    in.CreatedAt.UnixMilli(),
+	}, nil
+}
@@ -1,21 +1,34 @@
 package controller
 
 import (
+	"context"
 	"encoding/hex"
+	"errors"
+	"regexp"
+	"strings"
 
 	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/GitDataAI/jiaozifs/auth"
+	"github.com/GitDataAI/jiaozifs/models"
 	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/utils/masking"
+	"github.com/GitDataAI/jiaozifs/utils/tabular"
 	"github.com/GitDataAI/jiaozifs/versionmgr"
+	"github.com/google/uuid"
 )
 
-func changesToDTO(changes *versionmgr.Changes) ([]api.Change, error) {
+func changesToDTO(changes *versionmgr.Changes, hidden func(string) bool) ([]api.Change, error) {
 	changesResp := make([]api.Change, 0)
 	err := changes.ForEach(func(change versionmgr.IChange) error {
+		fullPath := change.Path()
+		if hidden(fullPath) {
+			return nil
+		}
 		action, err := change.Action()
 		if err != nil {
 			return err
 		}
-		fullPath := change.Path()
 		apiChange := api.Change{
 			Action: api.ChangeAction(action),
 			Path:   fullPath,
@@ -34,3 +47,212 @@ func changesToDTO(changes *versionmgr.Changes) ([]api.Change, error) {
 	}
 	return changesResp, nil
 }
+
+// pageAmount amount of list params, max page size if not specified or out of range
+func pageAmount(amount *int) int {
+	pageAmount := utils.IntValue(amount)
+	if pageAmount > utils.DefaultMaxPerPage || pageAmount <= 0 {
+		return utils.DefaultMaxPerPage
+	}
+	return pageAmount
+}
+
+// listSortFromDto convert sort of list params, field of dto used as offset of next page is returned with it.
+// bad request is written if sort is invalid
+func listSortFromDto(w *api.JiaozifsResponse, field *api.SortField, direction *api.SortDirection) (models.ListSort, string, bool) {
+	if field == nil {
+		return models.ListSort{}, "UpdatedAt", true
+	}
+	sort := models.ListSort{Field: models.SortField(*field)}
+	if direction != nil {
+		sort.Direction = models.SortDirection(*direction)
+	}
+	if !sort.Valid() {
+		w.BadRequest("invalid sort %s %s", sort.Field, sort.Direction)
+		return models.ListSort{}, "", false
+	}
+
+	switch sort.Field {
+	case models.SortByName:
+		return sort, "Name", true
+	case models.SortByCreatedAt:
+		return sort, "CreatedAt", true
+	default:
+		return sort, "UpdatedAt", true
+	}
+}
+
+// newAttestation build attestation of creation body on commit signed by secretKey, bad request is written if body is invalid
+func newAttestation(w *api.JiaozifsResponse, body api.AttestationCreation, repositoryID uuid.UUID, commitHash hash.Hash, creatorID uuid.UUID, secretKey []byte) (*models.Attestation, bool) {
+	status := models.AttestationStatus(body.Status)
+	if len(body.Name) == 0 {
+		w.BadRequest("attestation name must not be empty")
+		return nil, false
+	}
+	if !status.Valid() {
+		w.BadRequest("invalid attestation status %s", body.Status)
+		return nil, false
+	}
+
+	attestation := models.NewAttestation(repositoryID, commitHash, body.Name, status, creatorID)
+	attestation.Description = body.Description
+	attestation.URL = body.Url
+	attestation.Sign(secretKey)
+	return attestation, true
+}
+
+// matchModeFromDto convert match mode of list params, prefix match if not specified. bad request is written if name
+// is not a valid regular expression in regex mode
+func matchModeFromDto(w *api.JiaozifsResponse, name string, mode *api.MatchMode) (models.MatchMode, bool) {
+	if mode == nil {
+		return models.PrefixMatch, true
+	}
+	match := models.PrefixMatch
+	switch *mode {
+	case api.Exact:
+		match = models.ExactMatch
+	case api.Suffix:
+		match = models.SuffixMatch
+	case api.Like:
+		match = models.LikeMatch
+	case api.Iprefix:
+		match = models.IPrefixMatch
+	case api.Isuffix:
+		match = models.ISuffixMatch
+	case api.Ilike:
+		match = models.ILikeMatch
+	case api.Regex:
+		match = models.RegexMatch
+	case api.Iregex:
+		match = models.IRegexMatch
+	}
+	if match.IsRegex() {
+		if _, err := regexp.Compile(name); err != nil {
+			w.BadRequest("invalid regular expression %s: %v", name, err)
+			return match, false
+		}
+	}
+	return match, true
+}
+
+// metadataFromDto parse key=value pairs of commit metadata, write bad request and return false if malformed
+func metadataFromDto(w *api.JiaozifsResponse, values *[]string) (map[string]string, bool) {
+	if values == nil || len(*values) == 0 {
+		return nil, true
+	}
+	metadata := make(map[string]string, len(*values))
+	for _, value := range *values {
+		k, v, ok := strings.Cut(value, "=")
+		if !ok || len(k) == 0 {
+			w.BadRequest("metadata %s must be in key=value format", value)
+			return nil, false
+		}
+		metadata[k] = v
+	}
+	return metadata, true
+}
+
+// visibleAll path filter hiding nothing, for callers who can see whole repository
+func visibleAll(string) bool { return false }
+
+// privatePathFilter return func telling whether path is hidden from operator. private prefixes of public repository are
+// hidden from users who can read repository only because it is public
+func privatePathFilter(ctx context.Context, repo models.IRepo, repository *models.Repository) (func(string) bool, error) {
+	if !repository.Visible || len(repository.PrivatePrefixes) == 0 {
+		return visibleAll, nil
+	}
+
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if operator.ID == repository.OwnerID {
+		return visibleAll, nil
+	}
+
+	_, err = repo.MemberRepo().GetMember(ctx, models.NewGetMemberParams().SetUserID(operator.ID).SetRepoID(repository.ID))
+	if err == nil {
+		return visibleAll, nil
+	}
+	if !errors.Is(err, models.ErrNotFound) {
+		return nil, err
+	}
+	return repository.IsPrivatePath, nil
+}
+
+// maskMethodsFromModel convert masked columns of repository to masking methods, nil if no column is masked
+func maskMethodsFromModel(columns map[string]string) map[string]masking.Method {
+	if len(columns) == 0 {
+		return nil
+	}
+	methods := make(map[string]masking.Method, len(columns))
+	for column, method := range columns {
+		methods[column] = masking.Method(method)
+	}
+	return methods
+}
+
+func schemaVersionToDto(version *versionmgr.SchemaVersion) api.FileSchemaVersion {
+	dto := api.FileSchemaVersion{
+		Commit:  *commitToDto(version.Commit),
+		Changes: schemaChangesToDto(version.Changes),
+	}
+	if version.Columns != nil {
+		columns := make([]api.SchemaColumn, len(version.Columns))
+		for index, column := range version.Columns {
+			columns[index] = api.SchemaColumn{Name: column.Name, Type: api.SchemaColumnType(column.Type)}
+		}
+		dto.Columns = &columns
+	}
+	return dto
+}
+
+func fileSchemaChangesToDto(fileChanges []*versionmgr.FileSchemaChange) []api.FileSchemaChange {
+	dtos := make([]api.FileSchemaChange, len(fileChanges))
+	for index, fileChange := range fileChanges {
+		dtos[index] = api.FileSchemaChange{
+			Path:    fileChange.Path,
+			Changes: schemaChangesToDto(fileChange.Changes),
+		}
+	}
+	return dtos
+}
+
+func schemaChangesToDto(changes []tabular.Change) []api.SchemaChange {
+	dtos := make([]api.SchemaChange, len(changes))
+	for index, change := range changes {
+		dtos[index] = api.SchemaChange{
+			Column: change.Column,
+			Kind:   api.SchemaChangeKind(change.Kind),
+		}
+		if len(change.OldType) > 0 {
+			dtos[index].OldType = utils.String(string(change.OldType))
+		}
+		if len(change.NewType) > 0 {
+			dtos[index].NewType = utils.String(string(change.NewType))
+		}
+	}
+	return dtos
+}
+
+func contentDiffToDto(contentDiff *versionmgr.ContentDiff) api.ContentDiff {
+	changes := make([]api.ContentChange, 0, len(contentDiff.Changes))
+	for _, change := range contentDiff.Changes {
+		contentChange := api.ContentChange{
+			Kind: api.ContentChangeKind(change.Kind),
+			Key:  change.Key,
+		}
+		if change.Kind != versionmgr.ContentAdded {
+			contentChange.OldValue = utils.String(change.Old)
+		}
+		if change.Kind != versionmgr.ContentRemoved {
+			contentChange.NewValue = utils.String(change.New)
+		}
+		changes = append(changes, contentChange)
+	}
+	return api.ContentDiff{
+		Path:    contentDiff.Path,
+		Driver:  contentDiff.Driver,
+		Changes: changes,
+	}
+}
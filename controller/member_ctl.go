@@ -9,6 +9,7 @@ import (
 	"github.com/GitDataAI/jiaozifs/utils"
 
 	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/GitDataAI/jiaozifs/auth"
 	"github.com/GitDataAI/jiaozifs/auth/rbac"
 	"github.com/GitDataAI/jiaozifs/models"
 	"go.uber.org/fx"
@@ -22,6 +23,12 @@ type MemberController struct {
 }
 
 func (memberCtl MemberController) UpdateMemberGroup(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.UpdateMemberGroupParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
 	owner, err := memberCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
 	if err != nil {
 		w.Error(err)
@@ -43,7 +50,17 @@ func (memberCtl MemberController) UpdateMemberGroup(ctx context.Context, w *api.
 	}
 
 	listMemberParams := models.NewUpdateMemberParams().SetFilterUserID(params.UserId).SetFilterRepoID(repository.ID).SetUpdateGroupID(params.GroupId)
-	err = memberCtl.Repo.MemberRepo().UpdateMember(ctx, listMemberParams)
+	err = memberCtl.Repo.Transaction(ctx, func(repo models.IRepo) error {
+		err := repo.MemberRepo().UpdateMember(ctx, listMemberParams)
+		if err != nil {
+			return err
+		}
+		_, err = repo.EventRepo().Insert(ctx, models.NewEvent(models.MemberUpdatedEvent, repository.ID, operator.ID, map[string]string{
+			"user":  params.UserId.String(),
+			"group": params.GroupId.String(),
+		}))
+		return err
+	})
 	if err != nil {
 		w.Error(err)
 		return
@@ -52,6 +69,12 @@ func (memberCtl MemberController) UpdateMemberGroup(ctx context.Context, w *api.
 }
 
 func (memberCtl MemberController) InviteMember(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.InviteMemberParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
 	owner, err := memberCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
 	if err != nil {
 		w.Error(err)
@@ -74,15 +97,28 @@ func (memberCtl MemberController) InviteMember(ctx context.Context, w *api.Jiaoz
 
 	if owner.ID == params.UserId {
 		w.BadRequest("not need to invite self")
+		return
 	}
 
 	// todo user need to confirm?
-	member, err := memberCtl.Repo.MemberRepo().Insert(ctx, &models.Member{
-		UserID:    params.UserId,
-		RepoID:    repository.ID,
-		GroupID:   params.GroupId,
-		CreatedAt: time.Now(),
-		UpdatedAt: time.Now(),
+	var member *models.Member
+	err = memberCtl.Repo.Transaction(ctx, func(repo models.IRepo) error {
+		var err error
+		member, err = repo.MemberRepo().Insert(ctx, &models.Member{
+			UserID:    params.UserId,
+			RepoID:    repository.ID,
+			GroupID:   params.GroupId,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		_, err = repo.EventRepo().Insert(ctx, models.NewEvent(models.MemberAddedEvent, repository.ID, operator.ID, map[string]string{
+			"user":  params.UserId.String(),
+			"group": params.GroupId.String(),
+		}))
+		return err
 	})
 	if err != nil {
 		w.Error(err)
@@ -93,6 +129,12 @@ func (memberCtl MemberController) InviteMember(ctx context.Context, w *api.Jiaoz
 }
 
 func (memberCtl MemberController) RevokeMember(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.RevokeMemberParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
 	owner, err := memberCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
 	if err != nil {
 		w.Error(err)
@@ -113,7 +155,16 @@ func (memberCtl MemberController) RevokeMember(ctx context.Context, w *api.Jiaoz
 		return
 	}
 
-	_, err = memberCtl.Repo.MemberRepo().DeleteMember(ctx, models.NewDeleteMemberParams().SetRepoID(repository.ID).SetUserID(params.UserId))
+	err = memberCtl.Repo.Transaction(ctx, func(repo models.IRepo) error {
+		_, err := repo.MemberRepo().DeleteMember(ctx, models.NewDeleteMemberParams().SetRepoID(repository.ID).SetUserID(params.UserId))
+		if err != nil {
+			return err
+		}
+		_, err = repo.EventRepo().Insert(ctx, models.NewEvent(models.MemberRemovedEvent, repository.ID, operator.ID, map[string]string{
+			"user": params.UserId.String(),
+		}))
+		return err
+	})
 	if err != nil {
 		w.Error(err)
 		return
@@ -121,7 +172,7 @@ func (memberCtl MemberController) RevokeMember(ctx context.Context, w *api.Jiaoz
 	w.OK()
 }
 
-func (memberCtl MemberController) ListMembers(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string) {
+func (memberCtl MemberController) ListMembers(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.ListMembersParams) {
 	owner, err := memberCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
 	if err != nil {
 		w.Error(err)
@@ -142,13 +193,28 @@ func (memberCtl MemberController) ListMembers(ctx context.Context, w *api.Jiaozi
 		return
 	}
 
-	listMemberParams := models.NewListMembersParams().SetRepoID(repository.ID)
-	members, err := memberCtl.Repo.MemberRepo().ListMember(ctx, listMemberParams)
+	listMemberParams := models.NewListMembersParams().SetRepoID(repository.ID).SetAmount(pageAmount(params.Amount))
+	if params.After != nil {
+		listMemberParams.SetAfter(time.UnixMilli(*params.After))
+	}
+	members, hasMore, err := memberCtl.Repo.MemberRepo().ListMember(ctx, listMemberParams)
 	if err != nil {
 		w.Error(err)
 		return
 	}
-	w.JSON(utils.Silent(utils.ArrMap(members, memberToDto)))
+
+	results := utils.Silent(utils.ArrMap(members, memberToDto))
+	pagMag := utils.PaginationFor(hasMore, results, "CreatedAt")
+	pagination := api.Pagination{
+		HasMore:    pagMag.HasMore,
+		MaxPerPage: pagMag.MaxPerPage,
+		NextOffset: pagMag.NextOffset,
+		Results:    pagMag.Results,
+	}
+	w.JSON(api.MemberList{
+		Pagination: pagination,
+		Results:    results,
+	})
 }
 
 func memberToDto(m *models.Member) (api.Member, error) {
@@ -2,9 +2,11 @@ package controller
 
 import (
 	"context"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/GitDataAI/jiaozifs/auth/rbac"
@@ -18,7 +20,9 @@ import (
 	"github.com/GitDataAI/jiaozifs/api"
 	"github.com/GitDataAI/jiaozifs/auth"
 	"github.com/GitDataAI/jiaozifs/block/params"
+	"github.com/GitDataAI/jiaozifs/config"
 	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/google/uuid"
 	"go.uber.org/fx"
 )
 
@@ -28,6 +32,8 @@ type MergeRequestController struct {
 
 	Repo                models.IRepo
 	PublicStorageConfig params.AdapterConfig
+	AuthConfig          *config.AuthConfig
+	AutoMerger          *versionmgr.AutoMerger
 }
 
 func (mrCtl MergeRequestController) ListMergeRequests(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.ListMergeRequestsParams) {
@@ -74,7 +80,8 @@ func (mrCtl MergeRequestController) ListMergeRequests(ctx context.Context, w *ap
 	}
 
 	results := make([]api.MergeRequest, len(mrs))
-	for index, mr := range mrs {
+	for index := range mrs {
+		mr := &mrs[index]
 		results[index] = api.MergeRequest{
 			Title:        mr.Title,
 			Description:  mr.Description,
@@ -84,6 +91,11 @@ func (mrCtl MergeRequestController) ListMergeRequests(ctx context.Context, w *ap
 			SourceRepoId: mr.SourceRepoID,
 			TargetBranch: mr.TargetBranchID,
 			TargetRepoId: mr.TargetRepoID,
+			Reviewers:    &mr.Reviewers,
+			Approvals:    &mr.Approvals,
+			Checklist:    checklistToDto(mr.Checklist),
+			IssueRefs:    issueRefsToDto(repository.IssueRefs(mr.Title, utils.StringValue(mr.Description))),
+			AutoMerge:    &mr.AutoMerge,
 			CreatedAt:    mr.CreatedAt.UnixMilli(),
 			UpdatedAt:    mr.UpdatedAt.UnixMilli(),
 		}
@@ -130,7 +142,7 @@ func (mrCtl MergeRequestController) CreateMergeRequest(ctx context.Context, w *a
 	}
 
 	if body.SourceBranchName == body.TargetBranchName {
-		w.BadRequest(fmt.Sprintf("source branch name %s and target branch name %s can not be same", body.SourceBranchName, body.SourceBranchName))
+		w.BadRequest("source branch name %s and target branch name %s can not be same", body.SourceBranchName, body.TargetBranchName)
 		return
 	}
 
@@ -149,7 +161,7 @@ func (mrCtl MergeRequestController) CreateMergeRequest(ctx context.Context, w *a
 	params := models.NewGetMergeRequestParams().SetTargetRepo(repository.ID).SetTargetBranch(targetBranch.ID).SetSourceBranch(sourceBranch.ID).SetState(models.MergeStateInit)
 	_, err = mrCtl.Repo.MergeRequestRepo().Get(ctx, params)
 	if err == nil {
-		w.BadRequest(fmt.Sprintf("repo %s merge request between %s and %s already exists", repositoryName, body.SourceBranchName, body.TargetBranchName))
+		w.BadRequest("repo %s merge request between %s and %s already exists", repositoryName, body.SourceBranchName, body.TargetBranchName)
 		return
 	}
 
@@ -158,6 +170,26 @@ func (mrCtl MergeRequestController) CreateMergeRequest(ctx context.Context, w *a
 		return
 	}
 
+	// default reviewers and checklist of target branch are always asked
+	rules := repository.MergeRulesOf(targetBranch.Name)
+	var otherReviewers []uuid.UUID
+	if body.Reviewers != nil {
+		otherReviewers = *body.Reviewers
+	}
+	reviewers, err := mrCtl.reviewersOf(ctx, rules, otherReviewers)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	var checklist []models.ChecklistItem
+	for _, rule := range rules {
+		for _, title := range rule.Checklist {
+			if !slices.ContainsFunc(checklist, func(item models.ChecklistItem) bool { return item.Title == title }) {
+				checklist = append(checklist, models.ChecklistItem{Title: title})
+			}
+		}
+	}
+
 	mrModel, err := mrCtl.Repo.MergeRequestRepo().Insert(ctx, &models.MergeRequest{
 		TargetBranchID: targetBranch.ID,
 		SourceBranchID: sourceBranch.ID,
@@ -167,6 +199,8 @@ func (mrCtl MergeRequestController) CreateMergeRequest(ctx context.Context, w *a
 		MergeState:     models.MergeStateInit,
 		Description:    body.Description,
 		AuthorID:       operator.ID,
+		Reviewers:      reviewers,
+		Checklist:      checklist,
 		CreatedAt:      time.Now(),
 		UpdatedAt:      time.Now(),
 	})
@@ -205,6 +239,11 @@ func (mrCtl MergeRequestController) CreateMergeRequest(ctx context.Context, w *a
 		SourceRepoId: mrModel.SourceRepoID,
 		TargetBranch: mrModel.TargetBranchID,
 		TargetRepoId: mrModel.TargetRepoID,
+		Reviewers:    &mrModel.Reviewers,
+		Approvals:    &mrModel.Approvals,
+		Checklist:    checklistToDto(mrModel.Checklist),
+		IssueRefs:    issueRefsToDto(repository.IssueRefs(mrModel.Title, utils.StringValue(mrModel.Description))),
+		AutoMerge:    &mrModel.AutoMerge,
 		CreatedAt:    mrModel.CreatedAt.UnixMilli(),
 		UpdatedAt:    mrModel.UpdatedAt.UnixMilli(),
 	}
@@ -214,6 +253,20 @@ func (mrCtl MergeRequestController) CreateMergeRequest(ctx context.Context, w *a
 		w.Error(err)
 		return
 	}
+
+	err = mrCtl.fillMergeability(ctx, &resp, repository, mrModel, sourceBranch, targetBranch, changePairs)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	schemaChanges, err := workRepo.MergeSchemaChanges(ctx, changePairs)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	schemaChangesDto := fileSchemaChangesToDto(schemaChanges)
+	resp.SchemaChanges = &schemaChangesDto
 	//get merge state
 	w.JSON(resp, http.StatusCreated)
 }
@@ -292,6 +345,11 @@ func (mrCtl MergeRequestController) GetMergeRequest(ctx context.Context, w *api.
 		SourceRepoId: mergeRequest.SourceRepoID,
 		TargetBranch: mergeRequest.TargetBranchID,
 		TargetRepoId: mergeRequest.TargetRepoID,
+		Reviewers:    &mergeRequest.Reviewers,
+		Approvals:    &mergeRequest.Approvals,
+		Checklist:    checklistToDto(mergeRequest.Checklist),
+		IssueRefs:    issueRefsToDto(repository.IssueRefs(mergeRequest.Title, utils.StringValue(mergeRequest.Description))),
+		AutoMerge:    &mergeRequest.AutoMerge,
 		CreatedAt:    mergeRequest.CreatedAt.UnixMilli(),
 		UpdatedAt:    mergeRequest.UpdatedAt.UnixMilli(),
 	}
@@ -300,6 +358,20 @@ func (mrCtl MergeRequestController) GetMergeRequest(ctx context.Context, w *api.
 		w.Error(err)
 		return
 	}
+
+	err = mrCtl.fillMergeability(ctx, &resp, repository, mergeRequest, sourceBranch, targetBranch, changePairs)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	schemaChanges, err := workRepo.MergeSchemaChanges(ctx, changePairs)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	schemaChangesDto := fileSchemaChangesToDto(schemaChanges)
+	resp.SchemaChanges = &schemaChangesDto
 	w.JSON(resp)
 }
 
@@ -333,7 +405,43 @@ func (mrCtl MergeRequestController) UpdateMergeRequest(ctx context.Context, w *a
 		updateParams.SetDescription(utils.StringValue(body.Description))
 	}
 	if body.Status != nil {
-		updateParams.SetState(models.MergeState(utils.IntValue(body.Status)))
+		mergeRequest, err := mrCtl.Repo.MergeRequestRepo().Get(ctx, models.NewGetMergeRequestParams().SetTargetRepo(repository.ID).SetNumber(mrSeq))
+		if err != nil {
+			w.Error(err)
+			return
+		}
+		state := models.MergeState(utils.IntValue(body.Status))
+		err = mrCtl.checkStateChange(ctx, mergeRequest, state)
+		if err != nil {
+			w.Error(err)
+			return
+		}
+		updateParams.SetState(state)
+		if state == models.MergeStateClosed {
+			updateParams.ClearAutoMerge()
+		}
+	}
+	if body.Reviewers != nil {
+		mergeRequest, err := mrCtl.Repo.MergeRequestRepo().Get(ctx, models.NewGetMergeRequestParams().SetTargetRepo(repository.ID).SetNumber(mrSeq))
+		if err != nil {
+			w.Error(err)
+			return
+		}
+		targetBranch, err := mrCtl.Repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetID(mergeRequest.TargetBranchID))
+		if err != nil {
+			w.Error(err)
+			return
+		}
+		reviewers, err := mrCtl.reviewersOf(ctx, repository.MergeRulesOf(targetBranch.Name), *body.Reviewers)
+		if err != nil {
+			w.Error(err)
+			return
+		}
+		// approvals of removed reviewers are dropped
+		approvals := slices.DeleteFunc(slices.Clone(mergeRequest.Approvals), func(id uuid.UUID) bool {
+			return !slices.Contains(reviewers, id)
+		})
+		updateParams.SetReviewers(reviewers).SetApprovals(approvals)
 	}
 
 	err = mrCtl.Repo.MergeRequestRepo().UpdateByID(ctx, updateParams)
@@ -378,6 +486,10 @@ func (mrCtl MergeRequestController) Merge(ctx context.Context, w *api.JiaozifsRe
 		w.Error(err)
 		return
 	}
+	if mergeRequest.MergeState != models.MergeStateInit {
+		w.BadRequest("merge request %d is not open", mrSeq)
+		return
+	}
 
 	var commit *models.Commit
 	err = mrCtl.Repo.Transaction(ctx, func(repo models.IRepo) error {
@@ -396,12 +508,46 @@ func (mrCtl MergeRequestController) Merge(ctx context.Context, w *api.JiaozifsRe
 			return err
 		}
 
+		secretKey, err := hex.DecodeString(mrCtl.AuthConfig.SecretKey)
+		if err != nil {
+			return err
+		}
+		err = versionmgr.CheckMergeRequest(ctx, repo, repository, mergeRequest, sourceBranch, targetBranch, secretKey)
+		if errors.Is(err, models.ErrMergeRuleUnmet) {
+			return fmt.Errorf("%w %w", err, api.ErrCode(http.StatusForbidden))
+		}
+		if err != nil {
+			return err
+		}
+
 		err = workRepo.CheckOut(ctx, versionmgr.InBranch, targetBranch.Name)
 		if err != nil {
 			return err
 		}
 
-		commit, err = workRepo.Merge(ctx, sourceBranch.CommitHash, body.Msg, versionmgr.ResolveFromSelector(utils.Map(body.ConflictResolve)))
+		if utils.BoolValue(body.FastForwardOnly) {
+			commit, err = workRepo.FastForward(ctx, sourceBranch.CommitHash)
+		} else {
+			// conflicts neither selected nor merged by driver keep target branch unless strategy is given
+			strategyName := versionmgr.OursStrategy
+			if body.Strategy != nil {
+				strategyName = *body.Strategy
+			}
+			var strategy versionmgr.MergeStrategy
+			strategy, err = versionmgr.GetMergeStrategy(strategyName)
+			if err != nil {
+				return fmt.Errorf("%w %w", err, api.ErrCode(http.StatusBadRequest))
+			}
+			resolver := versionmgr.ResolveFromSelectorOr(utils.Map(body.ConflictResolve), workRepo.StrategyResolver(ctx, versionmgr.WithMergeDrivers(strategy)))
+			commit, err = workRepo.Merge(ctx, sourceBranch.CommitHash, body.Msg, resolver)
+		}
+		if errors.Is(err, versionmgr.ErrConflict) {
+			return fmt.Errorf("%w %w", err, api.ErrCode(http.StatusConflict))
+		}
+		var notFastForwardErr versionmgr.NotFastForwardError
+		if errors.As(err, &notFastForwardErr) {
+			return fmt.Errorf("%w %w", err, api.ErrCode(http.StatusConflict))
+		}
 		if err != nil {
 			return err
 		}
@@ -416,6 +562,380 @@ func (mrCtl MergeRequestController) Merge(ctx context.Context, w *api.JiaozifsRe
 	w.JSON(commitToDto(commit))
 }
 
+func (mrCtl MergeRequestController) ApproveMergeRequest(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, mrSeq uint64) {
+	operator, mergeRequest, ok := mrCtl.openMergeRequest(ctx, w, ownerName, repositoryName, mrSeq, rbacmodel.ReadMergeRequestAction)
+	if !ok {
+		return
+	}
+
+	if operator.ID == mergeRequest.AuthorID {
+		w.BadRequest("author can not approve own merge request")
+		return
+	}
+	if !slices.Contains(mergeRequest.Reviewers, operator.ID) {
+		w.Forbidden()
+		return
+	}
+	if slices.Contains(mergeRequest.Approvals, operator.ID) {
+		w.OK()
+		return
+	}
+
+	err := mrCtl.Repo.MergeRequestRepo().UpdateByID(ctx, models.NewUpdateMergeRequestParams(mergeRequest.TargetRepoID, mergeRequest.Sequence).
+		SetApprovals(append(mergeRequest.Approvals, operator.ID)))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	mrCtl.AutoMerger.Trigger()
+	w.OK()
+}
+
+func (mrCtl MergeRequestController) RevokeMergeRequestApproval(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, mrSeq uint64) {
+	operator, mergeRequest, ok := mrCtl.openMergeRequest(ctx, w, ownerName, repositoryName, mrSeq, rbacmodel.ReadMergeRequestAction)
+	if !ok {
+		return
+	}
+
+	approvals := slices.DeleteFunc(slices.Clone(mergeRequest.Approvals), func(id uuid.UUID) bool {
+		return id == operator.ID
+	})
+	err := mrCtl.Repo.MergeRequestRepo().UpdateByID(ctx, models.NewUpdateMergeRequestParams(mergeRequest.TargetRepoID, mergeRequest.Sequence).SetApprovals(approvals))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.OK()
+}
+
+func (mrCtl MergeRequestController) CheckMergeRequestItem(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, body api.CheckMergeRequestItemJSONRequestBody, ownerName string, repositoryName string, mrSeq uint64) {
+	operator, mergeRequest, ok := mrCtl.openMergeRequest(ctx, w, ownerName, repositoryName, mrSeq, rbacmodel.UpdateMergeRequestAction)
+	if !ok {
+		return
+	}
+
+	index := slices.IndexFunc(mergeRequest.Checklist, func(item models.ChecklistItem) bool {
+		return item.Title == body.Title
+	})
+	if index < 0 {
+		w.BadRequest("checklist item %s not found", body.Title)
+		return
+	}
+	item := &mergeRequest.Checklist[index]
+	item.Checked = body.Checked
+	item.CheckedBy = uuid.Nil
+	if body.Checked {
+		item.CheckedBy = operator.ID
+	}
+
+	err := mrCtl.Repo.MergeRequestRepo().UpdateByID(ctx, models.NewUpdateMergeRequestParams(mergeRequest.TargetRepoID, mergeRequest.Sequence).SetChecklist(mergeRequest.Checklist))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	mrCtl.AutoMerger.Trigger()
+	w.JSON(checklistToDto(mergeRequest.Checklist))
+}
+
+// EnableAutoMerge queue merge request to be merged on behalf of operator once merge rules of target branch are met and
+// it has no conflict, enabling again moves it to the end of queue
+func (mrCtl MergeRequestController) EnableAutoMerge(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, body api.EnableAutoMergeJSONRequestBody, ownerName string, repositoryName string, mrSeq uint64) {
+	operator, mergeRequest, ok := mrCtl.openMergeRequest(ctx, w, ownerName, repositoryName, mrSeq, rbacmodel.MergeMergeRequestAction)
+	if !ok {
+		return
+	}
+
+	err := mrCtl.Repo.MergeRequestRepo().UpdateByID(ctx, models.NewUpdateMergeRequestParams(mergeRequest.TargetRepoID, mergeRequest.Sequence).
+		SetAutoMerge(operator.ID, utils.StringValue(body.Msg)))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	mrCtl.AutoMerger.Trigger()
+	w.OK()
+}
+
+// DisableAutoMerge remove merge request from auto merge queue
+func (mrCtl MergeRequestController) DisableAutoMerge(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, mrSeq uint64) {
+	_, mergeRequest, ok := mrCtl.openMergeRequest(ctx, w, ownerName, repositoryName, mrSeq, rbacmodel.MergeMergeRequestAction)
+	if !ok {
+		return
+	}
+
+	err := mrCtl.Repo.MergeRequestRepo().UpdateByID(ctx, models.NewUpdateMergeRequestParams(mergeRequest.TargetRepoID, mergeRequest.Sequence).ClearAutoMerge())
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.OK()
+}
+
+// CloseMergeRequest close open merge request without merging
+func (mrCtl MergeRequestController) CloseMergeRequest(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, mrSeq uint64) {
+	mrCtl.changeState(ctx, w, ownerName, repositoryName, mrSeq, models.MergeStateClosed)
+}
+
+// ReopenMergeRequest reopen closed merge request
+func (mrCtl MergeRequestController) ReopenMergeRequest(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, mrSeq uint64) {
+	mrCtl.changeState(ctx, w, ownerName, repositoryName, mrSeq, models.MergeStateInit)
+}
+
+// GetMergeRequestChecks latest status of checks reported on head commit of source branch, required checks of merge
+// rules not reported yet are pending
+func (mrCtl MergeRequestController) GetMergeRequestChecks(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, mrSeq uint64) {
+	_, mergeRequest, ok := mrCtl.openMergeRequest(ctx, w, ownerName, repositoryName, mrSeq, rbacmodel.ReadMergeRequestAction)
+	if !ok {
+		return
+	}
+
+	repository, err := mrCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetID(mergeRequest.TargetRepoID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	targetBranch, err := mrCtl.Repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetID(mergeRequest.TargetBranchID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	sourceBranch, err := mrCtl.Repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetID(mergeRequest.SourceBranchID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	secretKey, err := hex.DecodeString(mrCtl.AuthConfig.SecretKey)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	attestations, err := mrCtl.Repo.AttestationRepo().List(ctx, models.NewListAttestationParams().SetRepositoryID(mergeRequest.SourceRepoID).SetCommitHash(sourceBranch.CommitHash))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	rules := repository.MergeRulesOf(targetBranch.Name)
+	statuses := models.CheckStatuses(rules, attestations, secretKey)
+	checks := make([]api.MergeRequestCheck, 0, len(statuses))
+	for _, status := range statuses {
+		check := api.MergeRequestCheck{
+			Name:     status.Name,
+			Status:   api.AttestationStatus(status.Status),
+			Required: status.Required,
+		}
+		if status.Attestation != nil {
+			attestation := attestationToDto(status.Attestation, secretKey)
+			check.Attestation = &attestation
+		}
+		checks = append(checks, check)
+	}
+	w.JSON(api.MergeRequestChecks{
+		HeadCommit: sourceBranch.CommitHash.Hex(),
+		Passed:     models.CheckRequiredChecks(rules, attestations, secretKey) == nil,
+		Checks:     checks,
+	})
+}
+
+// CreateMergeRequestCheck report status of check on head commit of source branch, it is attestation of the commit in
+// source repository, so it still counts after the branch is merged through another merge request
+func (mrCtl MergeRequestController) CreateMergeRequestCheck(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, body api.CreateMergeRequestCheckJSONRequestBody, ownerName string, repositoryName string, mrSeq uint64) {
+	operator, mergeRequest, ok := mrCtl.openMergeRequest(ctx, w, ownerName, repositoryName, mrSeq, rbacmodel.UpdateMergeRequestAction)
+	if !ok {
+		return
+	}
+
+	sourceBranch, err := mrCtl.Repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetID(mergeRequest.SourceBranchID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	secretKey, err := hex.DecodeString(mrCtl.AuthConfig.SecretKey)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	attestation, ok := newAttestation(w, body, mergeRequest.SourceRepoID, sourceBranch.CommitHash, operator.ID, secretKey)
+	if !ok {
+		return
+	}
+	attestation, err = mrCtl.Repo.AttestationRepo().Insert(ctx, attestation)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	mrCtl.AutoMerger.Trigger()
+	w.JSON(attestationToDto(attestation, secretKey), http.StatusCreated)
+}
+
+// openMergeRequest get merge request not merged or closed yet after operator is authorized to do action
+func (mrCtl MergeRequestController) openMergeRequest(ctx context.Context, w *api.JiaozifsResponse, ownerName string, repositoryName string, mrSeq uint64, action string) (*models.User, *models.MergeRequest, bool) {
+	operator, mergeRequest, ok := mrCtl.findMergeRequest(ctx, w, ownerName, repositoryName, mrSeq, action)
+	if !ok {
+		return nil, nil, false
+	}
+	if mergeRequest.MergeState != models.MergeStateInit {
+		w.BadRequest("merge request %d is not open", mrSeq)
+		return nil, nil, false
+	}
+	return operator, mergeRequest, true
+}
+
+func (mrCtl MergeRequestController) findMergeRequest(ctx context.Context, w *api.JiaozifsResponse, ownerName string, repositoryName string, mrSeq uint64, action string) (*models.User, *models.MergeRequest, bool) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return nil, nil, false
+	}
+
+	owner, err := mrCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return nil, nil, false
+	}
+
+	repository, err := mrCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetOwnerID(owner.ID).SetName(repositoryName))
+	if err != nil {
+		w.Error(err)
+		return nil, nil, false
+	}
+
+	if !mrCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   action,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return nil, nil, false
+	}
+
+	mergeRequest, err := mrCtl.Repo.MergeRequestRepo().Get(ctx, models.NewGetMergeRequestParams().SetTargetRepo(repository.ID).SetNumber(mrSeq))
+	if err != nil {
+		w.Error(err)
+		return nil, nil, false
+	}
+	return operator, mergeRequest, true
+}
+
+// checkStateChange only open merge request can be closed and only closed one can be reopened, merged is final. reopen
+// fails when its branches are gone or another merge request between them is open
+func (mrCtl MergeRequestController) checkStateChange(ctx context.Context, mergeRequest *models.MergeRequest, state models.MergeState) error {
+	switch state {
+	case models.MergeStateClosed:
+		if mergeRequest.MergeState != models.MergeStateInit {
+			return fmt.Errorf("merge request %d is not open %w", mergeRequest.Sequence, api.ErrCode(http.StatusBadRequest))
+		}
+		return nil
+	case models.MergeStateInit:
+		if mergeRequest.MergeState != models.MergeStateClosed {
+			return fmt.Errorf("merge request %d is not closed %w", mergeRequest.Sequence, api.ErrCode(http.StatusBadRequest))
+		}
+		for _, branchID := range []uuid.UUID{mergeRequest.SourceBranchID, mergeRequest.TargetBranchID} {
+			_, err := mrCtl.Repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetID(branchID))
+			if err != nil {
+				return err
+			}
+		}
+		_, err := mrCtl.Repo.MergeRequestRepo().Get(ctx, models.NewGetMergeRequestParams().SetTargetRepo(mergeRequest.TargetRepoID).
+			SetTargetBranch(mergeRequest.TargetBranchID).SetSourceBranch(mergeRequest.SourceBranchID).SetState(models.MergeStateInit))
+		if err == nil {
+			return fmt.Errorf("another merge request between the same branches is open %w", api.ErrCode(http.StatusBadRequest))
+		}
+		if errors.Is(err, models.ErrNotFound) {
+			return nil
+		}
+		return err
+	default:
+		return fmt.Errorf("status %d only allow %d (open) and %d (closed), use merge api to merge %w", state, models.MergeStateInit, models.MergeStateClosed, api.ErrCode(http.StatusBadRequest))
+	}
+}
+
+// changeState close or reopen merge request, auto merge of closed merge request is canceled
+func (mrCtl MergeRequestController) changeState(ctx context.Context, w *api.JiaozifsResponse, ownerName string, repositoryName string, mrSeq uint64, state models.MergeState) {
+	_, mergeRequest, ok := mrCtl.findMergeRequest(ctx, w, ownerName, repositoryName, mrSeq, rbacmodel.UpdateMergeRequestAction)
+	if !ok {
+		return
+	}
+	err := mrCtl.checkStateChange(ctx, mergeRequest, state)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	updateParams := models.NewUpdateMergeRequestParams(mergeRequest.TargetRepoID, mergeRequest.Sequence).SetState(state)
+	if state == models.MergeStateClosed {
+		updateParams.ClearAutoMerge()
+	}
+	err = mrCtl.Repo.MergeRequestRepo().UpdateByID(ctx, updateParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.OK()
+}
+
+// fillMergeability tell whether merge request can be merged as it is, conflicts are counted after merge drivers
+func (mrCtl MergeRequestController) fillMergeability(ctx context.Context, resp *api.MergeRequestFullState, repository *models.Repository, mergeRequest *models.MergeRequest, sourceBranch, targetBranch *models.Branch, changePairs []*versionmgr.ChangePair) error {
+	conflicts := 0
+	for _, pair := range changePairs {
+		if pair.IsConflict {
+			conflicts++
+		}
+	}
+
+	secretKey, err := hex.DecodeString(mrCtl.AuthConfig.SecretKey)
+	if err != nil {
+		return err
+	}
+	err = versionmgr.CheckMergeRequest(ctx, mrCtl.Repo, repository, mergeRequest, sourceBranch, targetBranch, secretKey)
+	if errors.Is(err, models.ErrMergeRuleUnmet) {
+		resp.MergeBlockedBy = utils.String(err.Error())
+	} else if err != nil {
+		return err
+	}
+
+	mergeable := mergeRequest.MergeState == models.MergeStateInit && conflicts == 0 && resp.MergeBlockedBy == nil
+	resp.Conflicts = &conflicts
+	resp.Mergeable = &mergeable
+	return nil
+}
+
+// reviewersOf return default reviewers of rules followed by other reviewers, every reviewer must exist
+func (mrCtl MergeRequestController) reviewersOf(ctx context.Context, rules []models.MergeRule, others []uuid.UUID) ([]uuid.UUID, error) {
+	var reviewers []uuid.UUID
+	for _, rule := range rules {
+		reviewers = append(reviewers, rule.DefaultReviewers...)
+	}
+	reviewers = append(reviewers, others...)
+
+	var result []uuid.UUID
+	for _, reviewer := range reviewers {
+		if slices.Contains(result, reviewer) {
+			continue
+		}
+		_, err := mrCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetID(reviewer))
+		if err != nil {
+			return nil, fmt.Errorf("reviewer %s %w", reviewer, err)
+		}
+		result = append(result, reviewer)
+	}
+	return result, nil
+}
+
+func checklistToDto(checklist []models.ChecklistItem) *[]api.ChecklistItem {
+	dtos := make([]api.ChecklistItem, len(checklist))
+	for i, item := range checklist {
+		dtos[i] = api.ChecklistItem{
+			Title:   item.Title,
+			Checked: item.Checked,
+		}
+		if item.CheckedBy != uuid.Nil {
+			dtos[i].CheckedBy = &checklist[i].CheckedBy
+		}
+	}
+	return &dtos
+}
+
 func changePairToDTO(pairs []*versionmgr.ChangePair) ([]api.ChangePair, error) {
 
 	var changes = make([]api.ChangePair, len(pairs))
@@ -430,6 +950,13 @@ func changePairToDTO(pairs []*versionmgr.ChangePair) ([]api.ChangePair, error) {
 			Path:       path,
 			IsConflict: ch.IsConflict,
 		}
+		if len(ch.Driver) > 0 {
+			pair.Driver = utils.String(ch.Driver)
+		}
+		if len(ch.ConflictKeys) > 0 {
+			conflictKeys := ch.ConflictKeys
+			pair.ConflictKeys = &conflictKeys
+		}
 
 		if ch.Left != nil {
 			leftAction, err := ch.Left.Action()
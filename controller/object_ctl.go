@@ -9,6 +9,7 @@ import (
 	"mime"
 	"mime/multipart"
 	"net/http"
+	"slices"
 	"time"
 
 	"github.com/GitDataAI/jiaozifs/auth/rbac"
@@ -17,12 +18,14 @@ import (
 	"github.com/GitDataAI/jiaozifs/api"
 	"github.com/GitDataAI/jiaozifs/auth"
 	"github.com/GitDataAI/jiaozifs/block/params"
+	"github.com/GitDataAI/jiaozifs/config"
 	"github.com/GitDataAI/jiaozifs/models"
 	"github.com/GitDataAI/jiaozifs/models/filemode"
 	"github.com/GitDataAI/jiaozifs/models/rbacmodel"
 	"github.com/GitDataAI/jiaozifs/utils"
 	"github.com/GitDataAI/jiaozifs/utils/hash"
 	"github.com/GitDataAI/jiaozifs/utils/httputil"
+	"github.com/GitDataAI/jiaozifs/utils/masking"
 	"github.com/GitDataAI/jiaozifs/versionmgr"
 	"github.com/go-openapi/swag"
 	logging "github.com/ipfs/go-log/v2"
@@ -37,6 +40,8 @@ type ObjectController struct {
 
 	PublicStorageConfig params.AdapterConfig
 	Repo                models.IRepo
+	Batcher             *versionmgr.CommitBatcher
+	Config              *config.APIConfig
 }
 
 func (oct ObjectController) DeleteObject(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.DeleteObjectParams) { //nolint
@@ -67,6 +72,10 @@ func (oct ObjectController) DeleteObject(ctx context.Context, w *api.JiaozifsRes
 		return
 	}
 
+	if !oct.throttleWrite(w, repository) {
+		return
+	}
+
 	ref, err := oct.Repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(repository.ID).SetName(params.RefName))
 	if err != nil {
 		w.Error(err)
@@ -79,24 +88,33 @@ func (oct ObjectController) DeleteObject(ctx context.Context, w *api.JiaozifsRes
 		return
 	}
 
-	treeHash := hash.Empty
-	if !wip.CurrentTree.IsEmpty() {
-		treeHash = wip.CurrentTree
-	}
+	// tree of wip is read under lock, deletes and uploads of concurrent requests are not lost
+	err = oct.Repo.Transaction(ctx, func(repo models.IRepo) error {
+		wip, err := repo.WipRepo().LockByID(ctx, wip.ID)
+		if err != nil {
+			return err
+		}
 
-	workTree, err := versionmgr.NewWorkTree(ctx, oct.Repo.FileTreeRepo(repository.ID), models.NewRootTreeEntry(treeHash))
-	if err != nil {
-		w.Error(err)
-		return
-	}
+		treeHash := hash.Empty
+		if !wip.CurrentTree.IsEmpty() {
+			treeHash = wip.CurrentTree
+		}
+
+		workTree, err := versionmgr.NewWorkTree(ctx, repo.FileTreeRepo(repository.ID), models.NewRootTreeEntry(treeHash))
+		if err != nil {
+			return err
+		}
 
-	err = workTree.RemoveEntry(ctx, versionmgr.CleanPath(params.Path))
+		err = workTree.RemoveEntry(ctx, versionmgr.CleanPath(params.Path))
+		if err != nil {
+			return err
+		}
+		return repo.WipRepo().UpdateCurrentTree(ctx, wip.ID, workTree.Root().Hash())
+	})
 	if errors.Is(err, versionmgr.ErrPathNotFound) {
-		w.BadRequest(fmt.Sprintf("path %s not found", params.Path))
+		w.BadRequest("path %s not found", params.Path)
 		return
 	}
-
-	err = oct.Repo.WipRepo().UpdateByID(ctx, models.NewUpdateWipParams(wip.ID).SetCurrentTree(workTree.Root().Hash()))
 	if err != nil {
 		w.Error(err)
 		return
@@ -132,6 +150,17 @@ func (oct ObjectController) GetObject(ctx context.Context, w *api.JiaozifsRespon
 		return
 	}
 
+	hidden, err := privatePathFilter(ctx, oct.Repo, repository)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	// private path is responded as missing, not to reveal it exists
+	if hidden(params.Path) {
+		w.NotFound()
+		return
+	}
+
 	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, oct.Repo, oct.PublicStorageConfig)
 	if err != nil {
 		w.Error(err)
@@ -144,35 +173,71 @@ func (oct ObjectController) GetObject(ctx context.Context, w *api.JiaozifsRespon
 		return
 	}
 
-	workTree, err := workRepo.RootTree(ctx)
+	masks, err := oct.maskedColumns(ctx, repository, params.Path)
 	if err != nil {
 		w.Error(err)
 		return
 	}
 
-	blob, name, err := workTree.FindBlob(ctx, versionmgr.CleanPath(params.Path))
+	err = serveObject(ctx, w, workRepo, params.Path, params.Range, masks)
+	if err != nil {
+		objLog.With(
+			"user", ownerName,
+			"repo", repositoryName,
+			"path", params.Path).
+			Debugf("GetObject copy content %v", err)
+	}
+}
+
+// serveObject write content of object in path to response, failures before content was sent are responded directly,
+// only error of copying content is returned. content with masked columns is sent as a whole, byte range is ignored then
+func serveObject(ctx context.Context, w *api.JiaozifsResponse, workRepo *versionmgr.WorkRepository, path string, byteRange *string, masks map[string]masking.Method) error {
+	workTree, err := workRepo.RootTree(ctx)
 	if err != nil {
-		if errors.Is(err, versionmgr.ErrPathNotFound) {
-			w.BadRequest(fmt.Sprintf("path %s not found", params.Path))
-			return
-		}
 		w.Error(err)
-		return
+		return nil
 	}
 
-	reader, err := workRepo.ReadBlob(ctx, blob, params.Range)
+	blob, name, err := workTree.FindBlob(ctx, versionmgr.CleanPath(path))
 	if err != nil {
+		if errors.Is(err, versionmgr.ErrPathNotFound) {
+			w.NotFound()
+			return nil
+		}
 		w.Error(err)
-		return
+		return nil
 	}
-	defer reader.Close() //nolint
-	// handle partial response if byte range supplied
-	if params.Range != nil {
-		rng, err := httputil.ParseRange(*params.Range, blob.Size)
+
+	format, masked := masking.FormatFromPath(name)
+	masked = masked && len(masks) > 0
+	if masked {
+		byteRange = nil
+	}
+
+	// check range before reading, unsatisfiable range is not an error of storage
+	var rng httputil.Range
+	if byteRange != nil {
+		rng, err = httputil.ParseRange(*byteRange, blob.Size)
 		if err != nil {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", blob.Size))
 			w.String("Requested Range Not Satisfiable", http.StatusRequestedRangeNotSatisfiable)
-			return
+			return nil
 		}
+	}
+
+	reader, err := workRepo.ReadBlob(ctx, blob, byteRange)
+	if err != nil {
+		w.Error(err)
+		return nil
+	}
+	defer reader.Close() //nolint
+	if masked {
+		// size of masked content is unknown until all of it is sent
+		maskedReader := masking.NewReader(reader, format, masks)
+		defer maskedReader.Close() //nolint
+		reader = maskedReader
+	} else if byteRange != nil {
+		// handle partial response if byte range supplied
 		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rng.StartOffset, rng.EndOffset, blob.Size))
 		w.Header().Set("Content-Length", fmt.Sprintf("%d", rng.EndOffset-rng.StartOffset+1))
 		w.Code(http.StatusPartialContent)
@@ -180,8 +245,13 @@ func (oct ObjectController) GetObject(ctx context.Context, w *api.JiaozifsRespon
 		w.Header().Set("Content-Length", fmt.Sprint(blob.Size))
 	}
 
-	etag := httputil.ETag(blob.CheckSum.Hex())
-	w.Header().Set("ETag", etag)
+	if masked {
+		w.Header().Set("Accept-Ranges", "none")
+	} else {
+		etag := httputil.ETag(blob.CheckSum.Hex())
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Accept-Ranges", "bytes")
+	}
 	lastModified := httputil.HeaderTimestamp(blob.CreatedAt)
 	w.Header().Set("Last-Modified", lastModified)
 	w.Header().Set("Content-Type", httputil.ExtensionsByType(name))
@@ -193,14 +263,7 @@ func (oct ObjectController) GetObject(ctx context.Context, w *api.JiaozifsRespon
 	w.Header().Set("Content-Security-Policy", "default-src 'none'")
 	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, name))
 	_, err = io.Copy(w, reader)
-	if err != nil {
-		objLog.With(
-			"user", ownerName,
-			"repo", repositoryName,
-			"path", params.Path).
-			Debugf("GetObject copy content %v", err)
-
-	}
+	return err
 }
 
 func (oct ObjectController) HeadObject(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.HeadObjectParams) {
@@ -231,6 +294,17 @@ func (oct ObjectController) HeadObject(ctx context.Context, w *api.JiaozifsRespo
 		return
 	}
 
+	hidden, err := privatePathFilter(ctx, oct.Repo, repository)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	// private path is responded as missing, not to reveal it exists
+	if hidden(params.Path) {
+		w.NotFound()
+		return
+	}
+
 	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, oct.Repo, oct.PublicStorageConfig)
 	if err != nil {
 		w.Error(err)
@@ -252,23 +326,34 @@ func (oct ObjectController) HeadObject(ctx context.Context, w *api.JiaozifsRespo
 	blob, name, err := workTree.FindBlob(ctx, versionmgr.CleanPath(params.Path))
 	if err != nil {
 		if errors.Is(err, versionmgr.ErrPathNotFound) {
-			w.BadRequest(fmt.Sprintf("path %s not found", params.Path))
+			w.NotFound()
 			return
 		}
 		w.Error(err)
 		return
 	}
 
+	masks, err := oct.maskedColumns(ctx, repository, params.Path)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
 	//lookup files
-	etag := httputil.ETag(blob.CheckSum.Hex())
-	w.Header().Set("ETag", etag)
 	lastModified := httputil.HeaderTimestamp(blob.CreatedAt)
 	w.Header().Set("Last-Modified", lastModified)
-	w.Header().Set("Accept-Ranges", "bytes")
 	w.Header().Set("Content-Type", httputil.ExtensionsByType(name))
 	// for security, make sure the browser and any proxies en route don't cache the response
 	w.Header().Set("Cache-Control", "no-store, must-revalidate")
 	w.Header().Set("Expires", "0")
+	if _, ok := masking.FormatFromPath(name); ok && len(masks) > 0 {
+		// masked content is served as a whole and its size is unknown in advance
+		w.Header().Set("Accept-Ranges", "none")
+		return
+	}
+	etag := httputil.ETag(blob.CheckSum.Hex())
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Accept-Ranges", "bytes")
 
 	// calculate possible byte range, if any.
 	if params.Range != nil {
@@ -300,55 +385,23 @@ func (oct ObjectController) UploadObject(ctx context.Context, w *api.JiaozifsRes
 
 	if !oct.authorizeMember(ctx, w, repository.ID, rbac.Node{
 		Permission: rbac.Permission{
-			Action:   rbacmodel.ReadObjectAction,
+			Action:   rbacmodel.WriteObjectAction,
 			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
 		},
 	}) {
 		return
 	}
 
-	// read request body parse multipart for "content" and upload the data
-	contentType := r.Header.Get("Content-Type")
-	mediaType, p, err := mime.ParseMediaType(contentType)
-	if err != nil {
-		w.Error(err)
+	release, ok := oct.throttleUpload(w, repository)
+	if !ok {
 		return
 	}
+	defer release()
 
-	reader := r.Body
-	if mediaType == "multipart/form-data" {
-		// handle multipart upload
-		boundary, ok := p["boundary"]
-		if !ok {
-			w.Error(err)
-			return
-		}
-
-		contentUploaded := false
-		partReader := multipart.NewReader(r.Body, boundary)
-		for !contentUploaded {
-			part, err := partReader.NextPart()
-			if err == io.EOF {
-				break
-			}
-			if err != nil {
-				w.Error(err)
-				return
-			}
-			contentType = part.Header.Get("Content-Type")
-			partName := part.FormName()
-			if partName == "content" {
-				reader = part
-				contentUploaded = true
-			} else { //close not target part
-				_ = part.Close()
-			}
-
-		}
-		if !contentUploaded {
-			w.Error(fmt.Errorf("multipart upload missing key 'content': %w", http.ErrMissingFile))
-			return
-		}
+	reader, contentType, err := readUploadContent(r)
+	if err != nil {
+		w.Error(err)
+		return
 	}
 	defer reader.Close() //nolint
 
@@ -376,64 +429,123 @@ func (oct ObjectController) UploadObject(ctx context.Context, w *api.JiaozifsRes
 		return
 	}
 
-	workTree, err := workRepo.RootTree(ctx)
+	blob, err := workRepo.WriteBlob(ctx, reader, r.ContentLength, models.DefaultLeafProperty())
 	if err != nil {
 		w.Error(err)
 		return
 	}
 
-	blob, err := workRepo.WriteBlob(ctx, reader, r.ContentLength, models.DefaultLeafProperty())
+	path := versionmgr.CleanPath(params.Path)
+	err = putBlobInWip(ctx, workRepo, path, blob, utils.BoolValue(params.IsReplace))
 	if err != nil {
 		w.Error(err)
 		return
 	}
 
-	path := versionmgr.CleanPath(params.Path)
-	err = oct.Repo.Transaction(ctx, func(dRepo models.IRepo) error {
+	w.JSON(api.ObjectStats{
+		Checksum:    blob.CheckSum.Hex(),
+		Mtime:       time.Now().Unix(),
+		Path:        path,
+		PathMode:    utils.Uint32(uint32(filemode.Regular)),
+		SizeBytes:   swag.Int64(blob.Size),
+		ContentType: &contentType,
+		Metadata:    &api.ObjectUserMetadata{},
+	}, http.StatusCreated)
+}
+
+// putBlobInWip add blob at path of wip checked out by workRepo, existing object with different content is replaced only
+// if isReplace
+func putBlobInWip(ctx context.Context, workRepo *versionmgr.WorkRepository, path string, blob *models.Blob, isReplace bool) error {
+	return workRepo.ChangeInWip(ctx, func(workTree *versionmgr.WorkTree) error {
 		oldData, _, err := workTree.FindBlob(ctx, path)
 		if err != nil && !errors.Is(err, versionmgr.ErrPathNotFound) {
 			return err
 		}
 		if oldData == nil {
-			err = workTree.AddLeaf(ctx, path, blob)
-			if err != nil {
-				return err
-			}
-			return dRepo.WipRepo().UpdateByID(ctx, models.NewUpdateWipParams(workRepo.CurWip().ID).SetCurrentTree(workTree.Root().Hash()))
+			return workTree.AddLeaf(ctx, path, blob)
 		}
 
 		if bytes.Equal(oldData.CheckSum, blob.CheckSum) {
 			return nil
 		}
 
-		if !utils.BoolValue(params.IsReplace) {
+		if !isReplace {
 			return fmt.Errorf("object exit %w", api.ErrCode(http.StatusConflict))
 		}
 
 		//allow to update
-		err = workTree.ReplaceLeaf(ctx, path, blob)
-		if err != nil {
-			return err
-		}
-		return dRepo.WipRepo().UpdateByID(ctx, models.NewUpdateWipParams(workRepo.CurWip().ID).SetCurrentTree(workTree.Root().Hash()))
+		return workTree.ReplaceLeaf(ctx, path, blob)
 	})
+}
+
+func (oct ObjectController) GetFiles(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.GetFilesParams) {
+	owner, err := oct.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
 	if err != nil {
 		w.Error(err)
 		return
 	}
 
-	w.JSON(api.ObjectStats{
-		Checksum:    blob.CheckSum.Hex(),
-		Mtime:       time.Now().Unix(),
-		Path:        path,
-		PathMode:    utils.Uint32(uint32(filemode.Regular)),
-		SizeBytes:   swag.Int64(blob.Size),
-		ContentType: &contentType,
-		Metadata:    &api.ObjectUserMetadata{},
-	}, http.StatusCreated)
+	repository, err := oct.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetOwnerID(owner.ID).SetName(repositoryName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !oct.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.ListObjectsAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, oct.Repo, oct.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	err = workRepo.CheckOut(ctx, versionmgr.WorkRepoState(params.Type), params.RefName)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	workTree, err := workRepo.RootTree(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	treeManifest, err := workTree.GetTreeManifest(ctx, utils.StringValue(params.Pattern))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	hidden, err := privatePathFilter(ctx, oct.Repo, repository)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	files := make([]string, 0, len(treeManifest.FileList))
+	for _, file := range treeManifest.FileList {
+		if !hidden(file) {
+			files = append(files, file)
+		}
+	}
+	w.JSON(files)
 }
 
-func (oct ObjectController) GetFiles(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.GetFilesParams) {
+// BatchPathExists check many paths in ref with one request, private paths hidden from operator are reported as missing
+func (oct ObjectController) BatchPathExists(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, body api.BatchPathExistsJSONRequestBody, ownerName string, repositoryName string, params api.BatchPathExistsParams) {
 	owner, err := oct.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
 	if err != nil {
 		w.Error(err)
@@ -448,7 +560,7 @@ func (oct ObjectController) GetFiles(ctx context.Context, w *api.JiaozifsRespons
 
 	if !oct.authorizeMember(ctx, w, repository.ID, rbac.Node{
 		Permission: rbac.Permission{
-			Action:   rbacmodel.ListObjectsAction,
+			Action:   rbacmodel.ReadObjectAction,
 			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
 		},
 	}) {
@@ -479,11 +591,398 @@ func (oct ObjectController) GetFiles(ctx context.Context, w *api.JiaozifsRespons
 		return
 	}
 
-	treeManifest, err := workTree.GetTreeManifest(ctx, utils.StringValue(params.Pattern))
+	hidden, err := privatePathFilter(ctx, oct.Repo, repository)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	results := make([]api.PathExistence, 0, len(body.Paths))
+	for _, p := range body.Paths {
+		result := api.PathExistence{Path: p}
+		objPath := versionmgr.CleanPath(p)
+		if len(objPath) > 0 && hidden(objPath) {
+			results = append(results, result)
+			continue
+		}
+
+		treeEntries, err := workTree.Ls(ctx, objPath)
+		if errors.Is(err, versionmgr.ErrNotDirectory) {
+			blob, _, err := workTree.FindBlob(ctx, objPath)
+			if err != nil {
+				w.Error(err)
+				return
+			}
+			result.Exists = true
+			result.IsDir = utils.Bool(false)
+			result.Hash = utils.String(blob.Hash.Hex())
+			results = append(results, result)
+			continue
+		}
+		if errors.Is(err, versionmgr.ErrPathNotFound) {
+			results = append(results, result)
+			continue
+		}
+		if err != nil {
+			w.Error(err)
+			return
+		}
+
+		entries := make([]api.FullTreeEntry, 0, len(treeEntries))
+		for _, entry := range treeEntries {
+			entryPath := entry.Name
+			if len(objPath) > 0 {
+				entryPath = objPath + "/" + entry.Name
+			}
+			if hidden(entryPath) {
+				continue
+			}
+			entries = append(entries, api.FullTreeEntry{
+				CreatedAt: entry.CreatedAt.UnixMilli(),
+				Hash:      entry.Hash.Hex(),
+				IsDir:     entry.IsDir,
+				Name:      entry.Name,
+				Size:      entry.Size,
+				UpdatedAt: entry.UpdatedAt.UnixMilli(),
+			})
+		}
+		result.Exists = true
+		result.IsDir = utils.Bool(true)
+		result.Entries = &entries
+		results = append(results, result)
+	}
+	w.JSON(results)
+}
+
+// ExportDelta objects changed between commit synced last by consumer and head of ref. private paths hidden from operator
+// are left out and masked content is not presigned, consumer read it by GetObject
+func (oct ObjectController) ExportDelta(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.ExportDeltaParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := oct.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := oct.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetOwnerID(owner.ID).SetName(repositoryName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !oct.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.ReadObjectAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	sinceCommit := hash.Empty
+	if params.Since != nil {
+		sinceCommit, err = hash.FromHex(*params.Since)
+		if err != nil {
+			w.BadRequest("invalid commit hash %s", *params.Since)
+			return
+		}
+	}
+
+	hidden, err := privatePathFilter(ctx, oct.Repo, repository)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	unmask, err := oct.canUnmask(ctx, repository)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, oct.Repo, oct.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	err = workRepo.CheckOut(ctx, versionmgr.WorkRepoState(params.Type), params.RefName)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	objects, err := workRepo.ExportDelta(ctx, sinceCommit, versionmgr.DeltaExportOption{
+		PathPrefix: versionmgr.CleanPath(utils.StringValue(params.Path)),
+		Hidden:     hidden,
+		NoPresign: func(objPath string) bool {
+			_, ok := masking.FormatFromPath(objPath)
+			return ok && !unmask && len(repository.MaskedColumns(objPath)) > 0
+		},
+	})
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	resp := api.DeltaExport{
+		Objects: make([]api.DeltaObject, 0, len(objects)),
+	}
+	if workRepo.CurCommit() != nil {
+		resp.HeadCommit = workRepo.CurCommit().Hash.Hex()
+	}
+	if !sinceCommit.IsEmpty() {
+		resp.SinceCommit = utils.String(sinceCommit.Hex())
+	}
+	for _, object := range objects {
+		dto := api.DeltaObject{
+			Path:   object.Path,
+			Action: api.ChangeAction(object.Action),
+		}
+		if object.Blob != nil {
+			dto.Checksum = utils.String(object.Blob.CheckSum.Hex())
+			dto.SizeBytes = utils.Int64(object.Blob.Size)
+		}
+		if len(object.URL) > 0 {
+			dto.Url = utils.String(object.URL)
+			dto.UrlExpiresAt = utils.Int64(object.ExpiresAt.UnixMilli())
+		}
+		resp.Objects = append(resp.Objects, dto)
+	}
+	w.JSON(resp)
+}
+
+// readUploadContent return body of upload, multipart body is parsed for part "content"
+func readUploadContent(r *http.Request) (io.ReadCloser, string, error) {
+	contentType := r.Header.Get("Content-Type")
+	if len(contentType) == 0 {
+		// raw body streamed without declaring its type
+		return r.Body, "application/octet-stream", nil
+	}
+	mediaType, p, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, "", err
+	}
+
+	if mediaType != "multipart/form-data" {
+		return r.Body, contentType, nil
+	}
+
+	// handle multipart upload
+	boundary, ok := p["boundary"]
+	if !ok {
+		return nil, "", fmt.Errorf("multipart upload missing boundary %w", api.ErrCode(http.StatusBadRequest))
+	}
+
+	partReader := multipart.NewReader(r.Body, boundary)
+	for {
+		part, err := partReader.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, "", err
+		}
+		if part.FormName() == "content" {
+			return part, part.Header.Get("Content-Type"), nil
+		}
+		_ = part.Close() //close not target part
+	}
+	return nil, "", fmt.Errorf("multipart upload missing key 'content': %w", http.ErrMissingFile)
+}
+
+func (oct ObjectController) BatchUploadObject(ctx context.Context, w *api.JiaozifsResponse, r *http.Request, ownerName string, repositoryName string, params api.BatchUploadObjectParams) {
+	reader, _, err := readUploadContent(r)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	defer reader.Close() //nolint
+
+	err = validator.ValidateObjectPath(params.Path)
+	if err != nil {
+		w.BadRequest("%s %s", params.Path, err.Error())
+		return
+	}
+
+	workRepo, repository, ok := oct.batchWorkRepo(ctx, w, ownerName, repositoryName, rbacmodel.WriteObjectAction)
+	if !ok {
+		return
+	}
+
+	release, ok := oct.throttleUpload(w, repository)
+	if !ok {
+		return
+	}
+	defer release()
+
+	blob, err := workRepo.WriteBlob(ctx, reader, r.ContentLength, models.DefaultLeafProperty())
 	if err != nil {
 		w.Error(err)
 		return
 	}
 
-	w.JSON(treeManifest.FileList)
+	receipt, err := oct.Batcher.Submit(ctx, workRepo, params.RefName, versionmgr.BatchWrite{
+		Path:      params.Path,
+		Blob:      blob,
+		IsReplace: utils.BoolValue(params.IsReplace),
+	})
+	if !oct.writeBatchError(w, params.Path, err) {
+		return
+	}
+	w.JSON(commitReceiptToDto(receipt), http.StatusCreated)
+}
+
+func (oct ObjectController) BatchDeleteObject(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.BatchDeleteObjectParams) {
+	workRepo, repository, ok := oct.batchWorkRepo(ctx, w, ownerName, repositoryName, rbacmodel.DeleteObjectAction)
+	if !ok {
+		return
+	}
+
+	if !oct.throttleWrite(w, repository) {
+		return
+	}
+
+	receipt, err := oct.Batcher.Submit(ctx, workRepo, params.RefName, versionmgr.BatchWrite{
+		Path: params.Path,
+	})
+	if !oct.writeBatchError(w, params.Path, err) {
+		return
+	}
+	w.JSON(commitReceiptToDto(receipt))
+}
+
+func (oct ObjectController) ApplyPathOperations(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, body api.ApplyPathOperationsJSONRequestBody, ownerName string, repositoryName string, params api.ApplyPathOperationsParams) {
+	ops := make([]versionmgr.PathOp, len(body.Operations))
+	actions := []string{}
+	for i, operation := range body.Operations {
+		ops[i] = versionmgr.PathOp{
+			Type:        versionmgr.PathOpType(operation.Type),
+			Pattern:     operation.Pattern,
+			Destination: utils.StringValue(operation.Destination),
+			IsReplace:   utils.BoolValue(operation.IsReplace),
+		}
+		if err := ops[i].Validate(); err != nil {
+			w.BadRequest("operation %d is invalid, %v", i, err)
+			return
+		}
+
+		switch ops[i].Type {
+		case versionmgr.PathOpDelete:
+			actions = append(actions, rbacmodel.DeleteObjectAction)
+		case versionmgr.PathOpCopy:
+			actions = append(actions, rbacmodel.WriteObjectAction)
+		case versionmgr.PathOpMove:
+			actions = append(actions, rbacmodel.WriteObjectAction, rbacmodel.DeleteObjectAction)
+		}
+	}
+	slices.Sort(actions)
+	actions = slices.Compact(actions)
+
+	workRepo, repository, ok := oct.batchWorkRepo(ctx, w, ownerName, repositoryName, actions...)
+	if !ok {
+		return
+	}
+
+	if !oct.throttleWrite(w, repository) {
+		return
+	}
+
+	err := workRepo.CheckOut(ctx, versionmgr.InBranch, params.RefName)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	commit, affected, err := workRepo.CommitPathOps(ctx, body.Message, ops)
+	if errors.Is(err, versionmgr.ErrObjectExist) {
+		w.Error(fmt.Errorf("%w %w", err, api.ErrCode(http.StatusConflict)))
+		return
+	}
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	result := api.PathOperationsResult{Affected: affected}
+	if commit != nil {
+		result.CommitHash = utils.String(commit.Hash.Hex())
+	}
+	w.JSON(result)
+}
+
+// batchWorkRepo authorize operator to change object and commit on branch, return work repository for batch commit
+func (oct ObjectController) batchWorkRepo(ctx context.Context, w *api.JiaozifsResponse, ownerName string, repositoryName string, actions ...string) (*versionmgr.WorkRepository, *models.Repository, bool) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return nil, nil, false
+	}
+
+	owner, err := oct.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return nil, nil, false
+	}
+
+	repository, err := oct.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetOwnerID(owner.ID).SetName(repositoryName))
+	if err != nil {
+		w.Error(err)
+		return nil, nil, false
+	}
+
+	nodes := []rbac.Node{}
+	for _, action := range append(actions, rbacmodel.CreateCommitAction) {
+		nodes = append(nodes, rbac.Node{
+			Permission: rbac.Permission{
+				Action:   action,
+				Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+			},
+		})
+	}
+	if !oct.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Type:  rbac.NodeTypeAnd,
+		Nodes: nodes,
+	}) {
+		return nil, nil, false
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, oct.Repo, oct.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return nil, nil, false
+	}
+	workRepo.SetCommitLimits(commitLimits(oct.Config))
+	workRepo.SetQuotaWarningThresholds(quotaWarningThresholds(oct.Config))
+	return workRepo, repository, true
+}
+
+// writeBatchError write error of batched write to response, return false if error was written
+func (oct ObjectController) writeBatchError(w *api.JiaozifsResponse, path string, err error) bool {
+	if err == nil {
+		return true
+	}
+	if errors.Is(err, versionmgr.ErrPathNotFound) {
+		w.BadRequest("path %s not found", path)
+		return false
+	}
+	if errors.Is(err, versionmgr.ErrObjectExist) {
+		w.Error(fmt.Errorf("%w %w", err, api.ErrCode(http.StatusConflict)))
+		return false
+	}
+	w.Error(err)
+	return false
+}
+
+func commitReceiptToDto(receipt *versionmgr.CommitReceipt) api.CommitReceipt {
+	return api.CommitReceipt{
+		Path:       receipt.Path,
+		CommitHash: receipt.CommitHash.Hex(),
+		Index:      receipt.Index,
+		BatchSize:  receipt.BatchSize,
+	}
 }
@@ -3,10 +3,17 @@ package controller
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
 	"time"
+	"unicode"
 
 	"github.com/GitDataAI/jiaozifs/auth/rbac"
 	"github.com/GitDataAI/jiaozifs/controller/validator"
@@ -14,12 +21,14 @@ import (
 
 	"github.com/GitDataAI/jiaozifs/api"
 	"github.com/GitDataAI/jiaozifs/auth"
-	"github.com/GitDataAI/jiaozifs/block/factory"
+	"github.com/GitDataAI/jiaozifs/block"
 	"github.com/GitDataAI/jiaozifs/block/params"
+	"github.com/GitDataAI/jiaozifs/block/s3"
 	"github.com/GitDataAI/jiaozifs/config"
 	"github.com/GitDataAI/jiaozifs/models"
 	"github.com/GitDataAI/jiaozifs/utils"
 	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/utils/masking"
 	"github.com/GitDataAI/jiaozifs/versionmgr"
 	"github.com/google/uuid"
 	logging "github.com/ipfs/go-log/v2"
@@ -36,6 +45,8 @@ type RepositoryController struct {
 
 	Repo                models.IRepo
 	PublicStorageConfig params.AdapterConfig
+	Cleaner             *versionmgr.RepositoryCleaner
+	Reporter            *versionmgr.UsageReporter
 }
 
 func (repositoryCtl RepositoryController) ListRepositoryOfAuthenticatedUser(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, params api.ListRepositoryOfAuthenticatedUserParams) {
@@ -56,11 +67,26 @@ func (repositoryCtl RepositoryController) ListRepositoryOfAuthenticatedUser(ctx
 
 	listRepoParams := models.NewListRepoParams()
 	if params.Prefix != nil && len(*params.Prefix) > 0 {
-		listRepoParams.SetName(*params.Prefix, models.PrefixMatch)
+		match, ok := matchModeFromDto(w, *params.Prefix, params.Match)
+		if !ok {
+			return
+		}
+		listRepoParams.SetName(*params.Prefix, match)
+	}
+	sort, offsetField, ok := listSortFromDto(w, params.Sort, params.Direction)
+	if !ok {
+		return
 	}
+	listRepoParams.SetSort(sort.Field, sort.Direction)
 	if params.After != nil {
 		listRepoParams.SetAfter(time.UnixMilli(utils.Int64Value(params.After)))
 	}
+	if params.AfterName != nil {
+		listRepoParams.SetAfterName(*params.AfterName)
+	}
+	if params.AfterId != nil {
+		listRepoParams.SetAfterID(*params.AfterId)
+	}
 	pageAmount := utils.IntValue(params.Amount)
 	if pageAmount > utils.DefaultMaxPerPage || pageAmount <= 0 {
 		listRepoParams.SetAmount(utils.DefaultMaxPerPage)
@@ -78,12 +104,19 @@ func (repositoryCtl RepositoryController) ListRepositoryOfAuthenticatedUser(ctx
 	for _, repo := range repositories {
 		results = append(results, *repositoryToDto(repo))
 	}
-	pagMag := utils.PaginationFor(hasMore, results, "UpdatedAt")
+	pagMag := utils.PaginationFor(hasMore, results, offsetField)
+	total, err := repositoryCtl.Repo.RepositoryRepo().Count(ctx, listRepoParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
 	pagination := api.Pagination{
-		HasMore:    pagMag.HasMore,
-		MaxPerPage: pagMag.MaxPerPage,
-		NextOffset: pagMag.NextOffset,
-		Results:    pagMag.Results,
+		HasMore:      pagMag.HasMore,
+		MaxPerPage:   pagMag.MaxPerPage,
+		NextOffset:   pagMag.NextOffset,
+		NextOffsetId: pagMag.NextOffsetID,
+		Results:      pagMag.Results,
+		Total:        &total,
 	}
 	w.JSON(api.RepositoryList{
 		Pagination: pagination,
@@ -110,11 +143,26 @@ func (repositoryCtl RepositoryController) ListRepository(ctx context.Context, w
 
 	listRepoParams := models.NewListRepoParams().SetOwnerID(owner.ID)
 	if params.Prefix != nil && len(*params.Prefix) > 0 {
-		listRepoParams.SetName(*params.Prefix, models.PrefixMatch)
+		match, ok := matchModeFromDto(w, *params.Prefix, params.Match)
+		if !ok {
+			return
+		}
+		listRepoParams.SetName(*params.Prefix, match)
 	}
+	sort, offsetField, ok := listSortFromDto(w, params.Sort, params.Direction)
+	if !ok {
+		return
+	}
+	listRepoParams.SetSort(sort.Field, sort.Direction)
 	if params.After != nil {
 		listRepoParams.SetAfter(time.UnixMilli(*params.After))
 	}
+	if params.AfterName != nil {
+		listRepoParams.SetAfterName(*params.AfterName)
+	}
+	if params.AfterId != nil {
+		listRepoParams.SetAfterID(*params.AfterId)
+	}
 	pageAmount := utils.IntValue(params.Amount)
 	if pageAmount > utils.DefaultMaxPerPage || pageAmount <= 0 {
 		listRepoParams.SetAmount(utils.DefaultMaxPerPage)
@@ -131,12 +179,19 @@ func (repositoryCtl RepositoryController) ListRepository(ctx context.Context, w
 	for _, repo := range repositories {
 		results = append(results, *repositoryToDto(repo))
 	}
-	pagMag := utils.PaginationFor(hasMore, results, "UpdatedAt")
+	pagMag := utils.PaginationFor(hasMore, results, offsetField)
+	total, err := repositoryCtl.Repo.RepositoryRepo().Count(ctx, listRepoParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
 	pagination := api.Pagination{
-		HasMore:    pagMag.HasMore,
-		MaxPerPage: pagMag.MaxPerPage,
-		NextOffset: pagMag.NextOffset,
-		Results:    pagMag.Results,
+		HasMore:      pagMag.HasMore,
+		MaxPerPage:   pagMag.MaxPerPage,
+		NextOffset:   pagMag.NextOffset,
+		NextOffsetId: pagMag.NextOffsetID,
+		Results:      pagMag.Results,
+		Total:        &total,
 	}
 	w.JSON(api.RepositoryList{
 		Pagination: pagination,
@@ -147,11 +202,26 @@ func (repositoryCtl RepositoryController) ListRepository(ctx context.Context, w
 func (repositoryCtl RepositoryController) ListPublicRepository(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, params api.ListPublicRepositoryParams) {
 	listRepoParams := models.NewListRepoParams().SetVisible(true)
 	if params.Prefix != nil && len(*params.Prefix) > 0 {
-		listRepoParams.SetName(*params.Prefix, models.PrefixMatch)
+		match, ok := matchModeFromDto(w, *params.Prefix, params.Match)
+		if !ok {
+			return
+		}
+		listRepoParams.SetName(*params.Prefix, match)
+	}
+	sort, offsetField, ok := listSortFromDto(w, params.Sort, params.Direction)
+	if !ok {
+		return
 	}
+	listRepoParams.SetSort(sort.Field, sort.Direction)
 	if params.After != nil {
 		listRepoParams.SetAfter(time.UnixMilli(*params.After))
 	}
+	if params.AfterName != nil {
+		listRepoParams.SetAfterName(*params.AfterName)
+	}
+	if params.AfterId != nil {
+		listRepoParams.SetAfterID(*params.AfterId)
+	}
 	pageAmount := utils.IntValue(params.Amount)
 	if pageAmount > utils.DefaultMaxPerPage || pageAmount <= 0 {
 		listRepoParams.SetAmount(utils.DefaultMaxPerPage)
@@ -168,12 +238,19 @@ func (repositoryCtl RepositoryController) ListPublicRepository(ctx context.Conte
 	for _, repo := range repositories {
 		results = append(results, *repositoryToDto(repo))
 	}
-	pagMag := utils.PaginationFor(hasMore, results, "UpdatedAt")
+	pagMag := utils.PaginationFor(hasMore, results, offsetField)
+	total, err := repositoryCtl.Repo.RepositoryRepo().Count(ctx, listRepoParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
 	pagination := api.Pagination{
-		HasMore:    pagMag.HasMore,
-		MaxPerPage: pagMag.MaxPerPage,
-		NextOffset: pagMag.NextOffset,
-		Results:    pagMag.Results,
+		HasMore:      pagMag.HasMore,
+		MaxPerPage:   pagMag.MaxPerPage,
+		NextOffset:   pagMag.NextOffset,
+		NextOffsetId: pagMag.NextOffsetID,
+		Results:      pagMag.Results,
+		Total:        &total,
 	}
 	w.JSON(api.RepositoryList{
 		Pagination: pagination,
@@ -203,6 +280,17 @@ func (repositoryCtl RepositoryController) CreateRepository(ctx context.Context,
 		return
 	}
 
+	// name of repository in trash is kept until purged
+	_, err = repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(body.Name).SetOwnerID(operator.ID).SetDeleted())
+	if err == nil {
+		w.BadRequest("repository %s is in trash, restore or purge it first", body.Name)
+		return
+	}
+	if !errors.Is(err, models.ErrNotFound) {
+		w.Error(err)
+		return
+	}
+
 	var usePublicStorage = true
 	storageConfig := utils.StringValue(body.BlockstoreConfig)
 	repoID := uuid.New()
@@ -257,6 +345,21 @@ func (repositoryCtl RepositoryController) CreateRepository(ctx context.Context,
 			return err
 		}
 		createdRepo, err = repo.RepositoryRepo().Insert(ctx, repository)
+		if err != nil {
+			return err
+		}
+
+		_, err = repo.EventRepo().Insert(ctx, models.NewEvent(models.RepositoryCreatedEvent, repoID, operator.ID, map[string]string{
+			"owner":      operator.Name,
+			"repository": repository.Name,
+		}))
+		if err != nil {
+			return err
+		}
+		_, err = repo.EventRepo().Insert(ctx, models.NewEvent(models.BranchCreatedEvent, repoID, operator.ID, map[string]string{
+			"branch": defaultRef.Name,
+			"commit": defaultRef.CommitHash.Hex(),
+		}))
 		return err
 	})
 	if err != nil {
@@ -268,6 +371,12 @@ func (repositoryCtl RepositoryController) CreateRepository(ctx context.Context,
 }
 
 func (repositoryCtl RepositoryController) DeleteRepository(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.DeleteRepositoryParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
 	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
 	if err != nil {
 		w.Error(err)
@@ -289,49 +398,26 @@ func (repositoryCtl RepositoryController) DeleteRepository(ctx context.Context,
 		return
 	}
 
+	if utils.BoolValue(params.Permanent) {
+		repositoryCtl.purgeRepository(ctx, w, operator, owner, repository, utils.BoolValue(params.IsCleanData))
+		return
+	}
+
 	err = repositoryCtl.Repo.Transaction(ctx, func(repo models.IRepo) error {
-		// delete repository
+		// move repository to trash, branches, commits and data are kept for restoring
 		affectRows, err := repo.RepositoryRepo().Delete(ctx, models.NewDeleteRepoParams().SetID(repository.ID))
 		if err != nil {
 			return err
 		}
 
 		if affectRows == 0 {
-			return fmt.Errorf("repo not found %w", models.ErrNotFound)
+			return fmt.Errorf("repo %w", models.ErrNotFound)
 		}
 
-		//delete branch
-		_, err = repo.BranchRepo().Delete(ctx, models.NewDeleteBranchParams().SetRepositoryID(repository.ID))
-		if err != nil {
-			return err
-		}
-
-		//delete commit
-		_, err = repo.CommitRepo(repository.ID).Delete(ctx, models.NewDeleteParams())
-		if err != nil {
-			return err
-		}
-
-		//delete tag
-		_, err = repo.TagRepo().Delete(ctx, models.NewDeleteTagParams().SetRepositoryID(repository.ID))
-		if err != nil {
-			return err
-		}
-
-		// delete tree
-		_, err = repo.FileTreeRepo(repository.ID).Delete(ctx, models.NewDeleteTreeParams())
-		if err != nil {
-			return err
-		}
-
-		//delete wip
-		_, err = repo.WipRepo().Delete(ctx, models.NewDeleteWipParams().SetRepositoryID(repository.ID))
-		if err != nil {
-			return err
-		}
-
-		//delete all membership
-		_, err = repo.MemberRepo().DeleteMember(ctx, models.NewDeleteMemberParams().SetRepoID(repository.ID))
+		_, err = repo.EventRepo().Insert(ctx, models.NewEvent(models.RepositoryDeletedEvent, repository.ID, operator.ID, map[string]string{
+			"owner":      owner.Name,
+			"repository": repository.Name,
+		}))
 		return err
 	})
 	if err != nil {
@@ -339,118 +425,130 @@ func (repositoryCtl RepositoryController) DeleteRepository(ctx context.Context,
 		return
 	}
 
-	//clean repo data
-	if repository.UsePublicStorage { //todo for use custom storage, maybe add a config in setting or params in delete repository api
-		adapter, err := factory.BuildBlockAdapter(ctx, repositoryCtl.PublicStorageConfig)
-		if err != nil {
-			w.Error(err)
-			return
-		}
-		err = adapter.RemoveNameSpace(ctx, *repository.StorageNamespace)
-		if err != nil {
-			w.Error(err)
-			return
-		}
-	} else if utils.BoolValue(params.IsCleanData) {
-		cfg := config.BlockStoreConfig{}
-		err = json.Unmarshal([]byte(utils.StringValue(repository.StorageAdapterParams)), &cfg)
-		if err != nil {
-			w.Error(err)
-			return
-		}
-		adapter, err := factory.BuildBlockAdapter(ctx, &cfg)
-		if err != nil {
-			w.Error(err)
-			return
-		}
-		err = adapter.RemoveNameSpace(ctx, *repository.StorageNamespace)
-		if err != nil {
-			w.Error(err)
-			return
-		}
-	}
-
 	w.OK()
 }
 
-func (repositoryCtl RepositoryController) GetRepository(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string) {
-	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
-	if err != nil {
-		w.Error(err)
-		return
-	}
-
-	repo, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+// ListDeletedRepositories list repositories of operator in trash
+func (repositoryCtl RepositoryController) ListDeletedRepositories(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, params api.ListDeletedRepositoriesParams) {
+	operator, err := auth.GetOperator(ctx)
 	if err != nil {
 		w.Error(err)
 		return
 	}
 
-	if !repositoryCtl.authorizeMember(ctx, w, repo.ID, rbac.Node{
+	if !repositoryCtl.authorize(ctx, w, rbac.Node{
 		Permission: rbac.Permission{
-			Action:   rbacmodel.ReadRepositoryAction,
-			Resource: rbacmodel.RepoURArn(owner.ID.String(), repo.ID.String()),
+			Action:   rbacmodel.ListRepositoriesAction,
+			Resource: rbacmodel.RepoUArn(operator.ID.String()),
 		},
 	}) {
 		return
 	}
 
-	w.JSON(repositoryToDto(repo))
+	listRepoParams := models.NewListRepoParams().SetOwnerID(operator.ID)
+	if params.Prefix != nil && len(*params.Prefix) > 0 {
+		match, ok := matchModeFromDto(w, *params.Prefix, params.Match)
+		if !ok {
+			return
+		}
+		listRepoParams.SetName(*params.Prefix, match)
+	}
+	if params.After != nil {
+		listRepoParams.SetAfter(time.UnixMilli(*params.After))
+	}
+	listRepoParams.SetAmount(pageAmount(params.Amount))
+
+	repositories, hasMore, err := repositoryCtl.Repo.RepositoryRepo().ListDeleted(ctx, listRepoParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	results := make([]api.Repository, 0, len(repositories))
+	for _, repo := range repositories {
+		results = append(results, *repositoryToDto(repo))
+	}
+	pagMag := utils.PaginationFor(hasMore, results, "DeletedAt")
+	pagination := api.Pagination{
+		HasMore:    pagMag.HasMore,
+		MaxPerPage: pagMag.MaxPerPage,
+		NextOffset: pagMag.NextOffset,
+		Results:    pagMag.Results,
+	}
+	w.JSON(api.RepositoryList{
+		Pagination: pagination,
+		Results:    results,
+	})
 }
 
-func (repositoryCtl RepositoryController) UpdateRepository(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, body api.UpdateRepositoryJSONRequestBody, ownerName string, repositoryName string) {
+// RestoreRepository take repository back from trash
+func (repositoryCtl RepositoryController) RestoreRepository(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
 	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
 	if err != nil {
 		w.Error(err)
 		return
 	}
 
-	repo, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID).SetDeleted())
 	if err != nil {
 		w.Error(err)
 		return
 	}
 
-	if !repositoryCtl.authorizeMember(ctx, w, repo.ID, rbac.Node{
+	if !repositoryCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
 		Permission: rbac.Permission{
-			Action:   rbacmodel.UpdateRepositoryAction,
-			Resource: rbacmodel.RepoURArn(owner.ID.String(), repo.ID.String()),
+			Action:   rbacmodel.DeleteRepositoryAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
 		},
 	}) {
 		return
 	}
 
-	params := models.NewUpdateRepoParams(repo.ID)
-	if body.Head != nil {
-		_, err = repositoryCtl.Repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(repo.ID).SetName(utils.StringValue(body.Head)))
+	err = repositoryCtl.Repo.Transaction(ctx, func(repo models.IRepo) error {
+		affectRows, err := repo.RepositoryRepo().Restore(ctx, repository.ID)
 		if err != nil {
-			w.Error(err)
-			return
+			return err
 		}
 
-		params.SetHead(utils.StringValue(body.Head))
-	}
+		if affectRows == 0 {
+			return fmt.Errorf("repo in trash %w", models.ErrNotFound)
+		}
 
-	if body.Description != nil {
-		params.SetDescription(utils.StringValue(body.Description))
+		_, err = repo.EventRepo().Insert(ctx, models.NewEvent(models.RepositoryRestoredEvent, repository.ID, operator.ID, map[string]string{
+			"owner":      owner.Name,
+			"repository": repository.Name,
+		}))
+		return err
+	})
+	if err != nil {
+		w.Error(err)
+		return
 	}
 
-	err = repositoryCtl.Repo.RepositoryRepo().UpdateByID(ctx, params)
+	repository.DeletedAt = time.Time{}
+	w.JSON(repositoryToDto(repository))
+}
+
+// PurgeRepository delete repository in trash permanently
+func (repositoryCtl RepositoryController) PurgeRepository(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.PurgeRepositoryParams) {
+	operator, err := auth.GetOperator(ctx)
 	if err != nil {
 		w.Error(err)
 		return
 	}
-	w.OK()
-}
 
-func (repositoryCtl RepositoryController) GetCommitsInRef(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.GetCommitsInRefParams) {
 	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
 	if err != nil {
 		w.Error(err)
 		return
 	}
 
-	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetOwnerID(owner.ID).SetName(repositoryName))
+	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID).SetDeleted())
 	if err != nil {
 		w.Error(err)
 		return
@@ -458,94 +556,1655 @@ func (repositoryCtl RepositoryController) GetCommitsInRef(ctx context.Context, w
 
 	if !repositoryCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
 		Permission: rbac.Permission{
-			Action:   rbacmodel.ReadCommitAction,
+			Action:   rbacmodel.DeleteRepositoryAction,
 			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
 		},
 	}) {
 		return
 	}
 
-	refName := repository.HEAD
-	if params.RefName != nil {
-		refName = *params.RefName
-	}
-	ref, err := repositoryCtl.Repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(repository.ID).SetName(refName))
+	repositoryCtl.purgeRepository(ctx, w, operator, owner, repository, utils.BoolValue(params.IsCleanData))
+}
+
+// purgeRepository remove repository and enqueue a deletion job removing its branches, commits, trees and data in
+// background, data of custom storage is removed only if cleanData
+func (repositoryCtl RepositoryController) purgeRepository(ctx context.Context, w *api.JiaozifsResponse, operator, owner *models.User, repository *models.Repository, cleanData bool) {
+	var job *models.RepositoryDeletionJob
+	err := repositoryCtl.Repo.Transaction(ctx, func(repo models.IRepo) error {
+		// delete repository, it is unreachable from now on
+		affectRows, err := repo.RepositoryRepo().Purge(ctx, models.NewDeleteRepoParams().SetID(repository.ID))
+		if err != nil {
+			return err
+		}
+
+		if affectRows == 0 {
+			return fmt.Errorf("repo %w", models.ErrNotFound)
+		}
+
+		job, err = repo.RepositoryDeletionRepo().Insert(ctx, models.NewRepositoryDeletionJob(repository, operator.ID, cleanData))
+		if err != nil {
+			return err
+		}
+
+		_, err = repo.EventRepo().Insert(ctx, models.NewEvent(models.RepositoryPurgedEvent, repository.ID, operator.ID, map[string]string{
+			"owner":      owner.Name,
+			"repository": repository.Name,
+		}))
+		return err
+	})
 	if err != nil {
 		w.Error(err)
 		return
 	}
 
-	if ref.CommitHash.IsEmpty() {
-		w.JSON([]api.Commit{})
+	repositoryCtl.Cleaner.Trigger()
+	w.JSON(repositoryDeletionToDto(job), http.StatusAccepted)
+}
+
+// GetRepositoryDeletion get progress of deletion job, only operator purged the repository and owner of repository can
+// see it as members of repository are gone with it
+func (repositoryCtl RepositoryController) GetRepositoryDeletion(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, jobID uuid.UUID) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
 		return
 	}
 
-	commit, err := repositoryCtl.Repo.CommitRepo(repository.ID).Commit(ctx, ref.CommitHash)
+	job, err := repositoryCtl.Repo.RepositoryDeletionRepo().Get(ctx, jobID)
 	if err != nil {
 		w.Error(err)
 		return
 	}
 
-	var commits []api.Commit
-	commitNode := versionmgr.NewWrapCommitNode(repositoryCtl.Repo.CommitRepo(repository.ID), commit)
-	iter := versionmgr.NewCommitPreorderIter(ctx, commitNode, nil, nil)
-	for {
-		commit, err := iter.Next()
-		if err == nil {
-			if params.After != nil {
-				parseTime := time.UnixMilli(*params.After)
-				if commit.Commit().Committer.When.Add(time.Nanosecond).After(parseTime) {
-					continue
-				}
-			}
-			if params.Amount != nil && len(commits) == *params.Amount {
-				break
-			}
-			modelCommit := commit.Commit()
-			commits = append(commits, *commitToDto(modelCommit))
-			continue
-		}
-		if err == io.EOF {
-			break
-		}
-		w.Error(err)
+	if job.OperatorID != operator.ID && job.OwnerID != operator.ID {
+		w.Forbidden()
 		return
 	}
-	w.JSON(commits)
+	w.JSON(repositoryDeletionToDto(job))
 }
 
-func (repositoryCtl RepositoryController) ChangeVisible(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.ChangeVisibleParams) {
+func repositoryDeletionToDto(job *models.RepositoryDeletionJob) *api.RepositoryDeletion {
+	var finishedAt *int64
+	if !job.FinishedAt.IsZero() {
+		finishedAt = utils.Int64(job.FinishedAt.UnixMilli())
+	}
+	return &api.RepositoryDeletion{
+		Id:             job.ID,
+		RepositoryId:   job.RepositoryID,
+		RepositoryName: job.RepositoryName,
+		State:          api.RepositoryDeletionState(job.State),
+		Step:           utils.String(job.Step),
+		Deleted:        job.Deleted,
+		Error:          utils.String(job.Error),
+		CreatedAt:      job.CreatedAt.UnixMilli(),
+		UpdatedAt:      job.UpdatedAt.UnixMilli(),
+		FinishedAt:     finishedAt,
+	}
+}
+
+// ForkRepository fork repository for operator, fork share storage of upstream and start with its branches and tags
+func (repositoryCtl RepositoryController) ForkRepository(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, body api.ForkRepositoryJSONRequestBody, ownerName string, repositoryName string) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
 	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
 	if err != nil {
 		w.Error(err)
 		return
 	}
 
-	repo, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	upstream, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
 	if err != nil {
 		w.Error(err)
 		return
 	}
 
-	if !repositoryCtl.authorizeMember(ctx, w, repo.ID, rbac.Node{
+	if !repositoryCtl.authorizeMember(ctx, w, upstream.ID, rbac.Node{
 		Permission: rbac.Permission{
-			Action:   rbacmodel.UpdateVisibleAction,
-			Resource: rbacmodel.RepoURArn(owner.ID.String(), repo.ID.String()),
+			Action:   rbacmodel.ReadRepositoryAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), upstream.ID.String()),
 		},
 	}) {
 		return
 	}
 
-	updateParams := models.NewUpdateRepoParams(repo.ID).SetVisible(params.Visible)
-	err = repositoryCtl.Repo.RepositoryRepo().UpdateByID(ctx, updateParams)
-	if err != nil {
-		w.Error(err)
-		return
+	if !repositoryCtl.authorize(ctx, w, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.CreateRepositoryAction,
+			Resource: rbacmodel.RepoUArn(operator.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	name := upstream.Name
+	if body.Name != nil {
+		name = *body.Name
+	}
+	err = validator.ValidateRepoName(name)
+	if err != nil {
+		w.BadRequest(err.Error())
+		return
+	}
+
+	_, err = repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(name).SetOwnerID(operator.ID))
+	if err == nil {
+		w.Error(fmt.Errorf("repository %s %w", name, models.ErrAlreadyExists))
+		return
+	}
+	if !errors.Is(err, models.ErrNotFound) {
+		w.Error(err)
+		return
+	}
+	// name of repository in trash is kept until purged
+	_, err = repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(name).SetOwnerID(operator.ID).SetDeleted())
+	if err == nil {
+		w.BadRequest("repository %s is in trash, restore or purge it first", name)
+		return
+	}
+	if !errors.Is(err, models.ErrNotFound) {
+		w.Error(err)
+		return
+	}
+
+	description := upstream.Description
+	if body.Description != nil {
+		description = body.Description
+	}
+	visible := upstream.Visible
+	if body.Visible != nil {
+		if *body.Visible && !upstream.Visible {
+			w.BadRequest("fork of private repository must be private")
+			return
+		}
+		visible = *body.Visible
+	}
+	fork := &models.Repository{
+		ID:          uuid.New(),
+		Name:        name,
+		Visible:     visible,
+		Description: description,
+		HEAD:        upstream.HEAD,
+		OwnerID:     operator.ID, // this api only fork repo for operator
+		CreatorID:   operator.ID,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+
+	var forked *models.Repository
+	err = repositoryCtl.Repo.Transaction(ctx, func(repo models.IRepo) error {
+		forked, err = versionmgr.ForkRepository(ctx, repo, upstream, fork)
+		if err != nil {
+			return err
+		}
+
+		_, err = repo.EventRepo().Insert(ctx, models.NewEvent(models.RepositoryCreatedEvent, forked.ID, operator.ID, map[string]string{
+			"owner":      operator.Name,
+			"repository": forked.Name,
+		}))
+		if err != nil {
+			return err
+		}
+		_, err = repo.EventRepo().Insert(ctx, models.NewEvent(models.RepositoryForkedEvent, upstream.ID, operator.ID, map[string]string{
+			"owner":      owner.Name,
+			"repository": upstream.Name,
+			"fork_owner": operator.Name,
+			"fork":       forked.Name,
+		}))
+		return err
+	})
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	w.JSON(repositoryToDto(forked), http.StatusCreated)
+}
+
+// ListForks list repositories forked from repository
+func (repositoryCtl RepositoryController) ListForks(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.ListForksParams) {
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !repositoryCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.ReadRepositoryAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	listRepoParams := models.NewListRepoParams().SetForkedFromID(repository.ID)
+	sort, offsetField, ok := listSortFromDto(w, params.Sort, params.Direction)
+	if !ok {
+		return
+	}
+	listRepoParams.SetSort(sort.Field, sort.Direction)
+	if params.After != nil {
+		listRepoParams.SetAfter(time.UnixMilli(*params.After))
+	}
+	if params.AfterName != nil {
+		listRepoParams.SetAfterName(*params.AfterName)
+	}
+	if params.AfterId != nil {
+		listRepoParams.SetAfterID(*params.AfterId)
+	}
+	listRepoParams.SetAmount(pageAmount(params.Amount))
+
+	forks, hasMore, err := repositoryCtl.Repo.RepositoryRepo().List(ctx, listRepoParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	results := make([]api.Repository, 0, len(forks))
+	for _, fork := range forks {
+		results = append(results, *repositoryToDto(fork))
+	}
+	pagMag := utils.PaginationFor(hasMore, results, offsetField)
+	total, err := repositoryCtl.Repo.RepositoryRepo().Count(ctx, listRepoParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	pagination := api.Pagination{
+		HasMore:      pagMag.HasMore,
+		MaxPerPage:   pagMag.MaxPerPage,
+		NextOffset:   pagMag.NextOffset,
+		NextOffsetId: pagMag.NextOffsetID,
+		Results:      pagMag.Results,
+		Total:        &total,
+	}
+	w.JSON(api.RepositoryList{
+		Pagination: pagination,
+		Results:    results,
+	})
+}
+
+func (repositoryCtl RepositoryController) GetRepository(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string) {
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repo, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !repositoryCtl.authorizeMember(ctx, w, repo.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.ReadRepositoryAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repo.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	w.JSON(repositoryToDto(repo))
+}
+
+func (repositoryCtl RepositoryController) UpdateRepository(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, body api.UpdateRepositoryJSONRequestBody, ownerName string, repositoryName string) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repo, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !repositoryCtl.authorizeMember(ctx, w, repo.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.UpdateRepositoryAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repo.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	params := models.NewUpdateRepoParams(repo.ID)
+	if body.Version != nil {
+		params.SetVersion(*body.Version)
+	}
+	renamed := body.Name != nil && *body.Name != repo.Name
+	if renamed {
+		err = validator.ValidateRepoName(*body.Name)
+		if err != nil {
+			w.BadRequest(err.Error())
+			return
+		}
+
+		// name of repository in trash is kept until purged
+		for _, getParams := range []*models.GetRepoParams{
+			models.NewGetRepoParams().SetName(*body.Name).SetOwnerID(owner.ID),
+			models.NewGetRepoParams().SetName(*body.Name).SetOwnerID(owner.ID).SetDeleted(),
+		} {
+			_, err = repositoryCtl.Repo.RepositoryRepo().Get(ctx, getParams)
+			if err == nil {
+				w.Error(fmt.Errorf("repository %s %w", *body.Name, models.ErrAlreadyExists))
+				return
+			}
+			if !errors.Is(err, models.ErrNotFound) {
+				w.Error(err)
+				return
+			}
+		}
+	}
+
+	if body.Visible != nil && *body.Visible != repo.Visible {
+		if !repositoryCtl.authorizeMember(ctx, w, repo.ID, rbac.Node{
+			Permission: rbac.Permission{
+				Action:   rbacmodel.UpdateVisibleAction,
+				Resource: rbacmodel.RepoURArn(owner.ID.String(), repo.ID.String()),
+			},
+		}) {
+			return
+		}
+		params.SetVisible(*body.Visible)
+	}
+
+	if body.Head != nil {
+		_, err = repositoryCtl.Repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(repo.ID).SetName(utils.StringValue(body.Head)))
+		if err != nil {
+			w.Error(err)
+			return
+		}
+
+		params.SetHead(utils.StringValue(body.Head))
+	}
+
+	if body.Description != nil {
+		params.SetDescription(utils.StringValue(body.Description))
+	}
+
+	if body.CommitMessageTemplate != nil {
+		_, err = versionmgr.ParseCommitMessageTemplate(*body.CommitMessageTemplate)
+		if err != nil {
+			w.BadRequest("invalid commit message template %v", err)
+			return
+		}
+		params.SetCommitMessageTemplate(*body.CommitMessageTemplate)
+	}
+
+	if body.PrivatePrefixes != nil {
+		prefixes := make([]string, 0, len(*body.PrivatePrefixes))
+		for _, prefix := range *body.PrivatePrefixes {
+			prefix = versionmgr.CleanPath(prefix)
+			if len(prefix) == 0 {
+				w.BadRequest("private prefix must not be empty")
+				return
+			}
+			err = validator.ValidateObjectPath(prefix)
+			if err != nil {
+				w.BadRequest(err.Error())
+				return
+			}
+			prefixes = append(prefixes, prefix)
+		}
+		params.SetPrivatePrefixes(prefixes)
+	}
+
+	if body.MaskingRules != nil {
+		// users reading masked content must not be able to loosen rules
+		if !repositoryCtl.authorizeMember(ctx, w, repo.ID, rbac.Node{
+			Permission: rbac.Permission{
+				Action:   rbacmodel.UnmaskObjectAction,
+				Resource: rbacmodel.RepoURArn(owner.ID.String(), repo.ID.String()),
+			},
+		}) {
+			return
+		}
+
+		rules := make([]models.MaskingRule, 0, len(*body.MaskingRules))
+		for _, rule := range *body.MaskingRules {
+			rulePath := versionmgr.CleanPath(rule.Path)
+			if len(rulePath) == 0 {
+				w.BadRequest("masking rule path must not be empty")
+				return
+			}
+			if _, err = path.Match(rulePath, ""); err != nil {
+				w.BadRequest("invalid masking rule path %s", rule.Path)
+				return
+			}
+			if len(rule.Columns) == 0 {
+				w.BadRequest("masking rule of %s has no column", rule.Path)
+				return
+			}
+			if !masking.Method(rule.Method).Valid() {
+				w.BadRequest("unsupported masking method %s", rule.Method)
+				return
+			}
+			rules = append(rules, models.MaskingRule{
+				Path:    rulePath,
+				Columns: rule.Columns,
+				Method:  string(rule.Method),
+			})
+		}
+		params.SetMaskingRules(rules)
+	}
+
+	if body.MergeRules != nil {
+		// members able to merge must not be able to drop review of protected branches
+		if !repositoryCtl.authorizeMember(ctx, w, repo.ID, rbac.Node{
+			Permission: rbac.Permission{
+				Action:   rbacmodel.WriteConfigAction,
+				Resource: rbacmodel.RepoURArn(owner.ID.String(), repo.ID.String()),
+			},
+		}) {
+			return
+		}
+
+		rules := make([]models.MergeRule, 0, len(*body.MergeRules))
+		for _, rule := range *body.MergeRules {
+			if len(rule.Branch) == 0 {
+				w.BadRequest("merge rule branch must not be empty")
+				return
+			}
+			if _, err = path.Match(rule.Branch, ""); err != nil {
+				w.BadRequest("invalid merge rule branch %s", rule.Branch)
+				return
+			}
+			mergeRule := models.MergeRule{Branch: rule.Branch}
+			if rule.DefaultReviewers != nil {
+				for _, reviewer := range *rule.DefaultReviewers {
+					_, err = repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetID(reviewer))
+					if err != nil {
+						w.Error(err)
+						return
+					}
+				}
+				mergeRule.DefaultReviewers = *rule.DefaultReviewers
+			}
+			if rule.Checklist != nil {
+				mergeRule.Checklist = *rule.Checklist
+			}
+			if rule.RequiredChecks != nil {
+				mergeRule.RequiredChecks = *rule.RequiredChecks
+			}
+			rules = append(rules, mergeRule)
+		}
+		params.SetMergeRules(rules)
+	}
+
+	if body.IssueLinks != nil {
+		links := make([]models.IssueLink, 0, len(*body.IssueLinks))
+		for _, link := range *body.IssueLinks {
+			if len(link.Prefix) == 0 || strings.ContainsFunc(link.Prefix, unicode.IsSpace) {
+				w.BadRequest("invalid issue prefix %s", link.Prefix)
+				return
+			}
+			issueURL, err := url.Parse(strings.ReplaceAll(link.UrlTemplate, "{id}", "1"))
+			if err != nil || !strings.Contains(link.UrlTemplate, "{id}") || (issueURL.Scheme != "http" && issueURL.Scheme != "https") {
+				w.BadRequest("invalid issue url template %s", link.UrlTemplate)
+				return
+			}
+			links = append(links, models.IssueLink{
+				Prefix:      link.Prefix,
+				URLTemplate: link.UrlTemplate,
+			})
+		}
+		params.SetIssueLinks(links)
+	}
+
+	if body.Hooks != nil {
+		// hooks guard commits of members, only those able to change config can drop them
+		if !repositoryCtl.authorizeMember(ctx, w, repo.ID, rbac.Node{
+			Permission: rbac.Permission{
+				Action:   rbacmodel.WriteConfigAction,
+				Resource: rbacmodel.RepoURArn(owner.ID.String(), repo.ID.String()),
+			},
+		}) {
+			return
+		}
+
+		hooks := make([]models.Hook, 0, len(*body.Hooks))
+		for _, hook := range *body.Hooks {
+			if len(hook.Name) == 0 || slices.ContainsFunc(hooks, func(h models.Hook) bool { return h.Name == hook.Name }) {
+				w.BadRequest("hook name must be unique and not empty")
+				return
+			}
+			modelHook := models.Hook{
+				Name:         hook.Name,
+				Branch:       utils.StringValue(hook.Branch),
+				Type:         models.HookType(hook.Type),
+				Paths:        utils.Slice(hook.Paths),
+				MetadataKeys: utils.Slice(hook.MetadataKeys),
+				Message:      utils.StringValue(hook.Message),
+			}
+			if _, err = path.Match(modelHook.Branch, ""); err != nil {
+				w.BadRequest("invalid branch %s of hook %s", modelHook.Branch, hook.Name)
+				return
+			}
+			if len(hook.Events) == 0 {
+				w.BadRequest("hook %s has no event", hook.Name)
+				return
+			}
+			for _, event := range hook.Events {
+				if !models.HookEvent(event).Valid() {
+					w.BadRequest("unsupported event %s of hook %s", event, hook.Name)
+					return
+				}
+				modelHook.Events = append(modelHook.Events, models.HookEvent(event))
+			}
+			switch modelHook.Type {
+			case models.DenyPathsHook:
+				if len(modelHook.Paths) == 0 {
+					w.BadRequest("hook %s has no path", hook.Name)
+					return
+				}
+				for _, pattern := range modelHook.Paths {
+					if _, err = path.Match(pattern, ""); err != nil {
+						w.BadRequest("invalid path %s of hook %s", pattern, hook.Name)
+						return
+					}
+				}
+			case models.RequireMetadataHook:
+				if len(modelHook.MetadataKeys) == 0 {
+					w.BadRequest("hook %s has no metadata key", hook.Name)
+					return
+				}
+			default:
+				w.BadRequest("unsupported hook type %s", hook.Type)
+				return
+			}
+			hooks = append(hooks, modelHook)
+		}
+		params.SetHooks(hooks)
+	}
+
+	err = repositoryCtl.Repo.Transaction(ctx, func(dRepo models.IRepo) error {
+		err := dRepo.RepositoryRepo().UpdateByID(ctx, params)
+		if err != nil || !renamed {
+			return err
+		}
+
+		err = dRepo.RepositoryRepo().Rename(ctx, repo.ID, *body.Name)
+		if err != nil {
+			return err
+		}
+		_, err = dRepo.EventRepo().Insert(ctx, models.NewEvent(models.RepositoryRenamedEvent, repo.ID, operator.ID, map[string]string{
+			"owner":      owner.Name,
+			"repository": *body.Name,
+			"old_name":   repo.Name,
+		}))
+		return err
+	})
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.OK()
+}
+
+func (repositoryCtl RepositoryController) UpdateRepositoryLimits(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, body api.UpdateRepositoryLimitsJSONRequestBody, ownerName string, repositoryName string) {
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repo, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !repositoryCtl.authorizeMember(ctx, w, repo.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.UpdateLimitsAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repo.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	params := models.NewUpdateRepoParams(repo.ID)
+	for _, limit := range []*int{body.WriteRateLimit, body.WriteBurst, body.MaxConcurrentUploads} {
+		if limit != nil && *limit < 0 {
+			w.BadRequest("limits must not be negative")
+			return
+		}
+	}
+	if body.WriteRateLimit != nil {
+		params.SetWriteRateLimit(*body.WriteRateLimit)
+	}
+	if body.WriteBurst != nil {
+		params.SetWriteBurst(*body.WriteBurst)
+	}
+	if body.MaxConcurrentUploads != nil {
+		params.SetMaxConcurrentUploads(*body.MaxConcurrentUploads)
+	}
+	if body.StorageQuota != nil {
+		if *body.StorageQuota < 0 {
+			w.BadRequest("limits must not be negative")
+			return
+		}
+		params.SetStorageQuota(*body.StorageQuota)
+	}
+
+	err = repositoryCtl.Repo.RepositoryRepo().UpdateByID(ctx, params)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.OK()
+}
+
+func (repositoryCtl RepositoryController) GetCommitsInRef(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.GetCommitsInRefParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetOwnerID(owner.ID).SetName(repositoryName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !repositoryCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.ReadCommitAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	metadata, ok := metadataFromDto(w, params.Metadata)
+	if !ok {
+		return
+	}
+
+	if params.Issue != nil && len(repository.IssueRefs(*params.Issue)) == 0 {
+		w.BadRequest("issue %s does not match any issue link", *params.Issue)
+		return
+	}
+
+	refType := versionmgr.InBranch
+	if params.RefType != nil {
+		refType = versionmgr.WorkRepoState(*params.RefType)
+	}
+	if refType != versionmgr.InBranch && refType != versionmgr.InTag && refType != versionmgr.InCommit {
+		w.BadRequest("commits ref type (%s) only allow branch, tag and commit", refType)
+		return
+	}
+
+	refName := repository.HEAD
+	if params.RefName != nil {
+		refName = *params.RefName
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, repositoryCtl.Repo, repositoryCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	err = workRepo.CheckOut(ctx, refType, refName)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	commits := make([]api.Commit, 0)
+	hasMore := false
+	if workRepo.CurCommit() == nil {
+		w.JSON(commitListOf(hasMore, commits))
+		return
+	}
+
+	commitNode := versionmgr.NewWrapCommitNode(repositoryCtl.Repo.CommitRepo(repository.ID), workRepo.CurCommit())
+	iter := versionmgr.NewCommitPreorderIter(ctx, commitNode, nil, nil)
+	for {
+		commit, err := iter.Next()
+		if err == nil {
+			if params.After != nil {
+				parseTime := time.UnixMilli(*params.After)
+				if commit.Commit().Committer.When.Add(time.Nanosecond).After(parseTime) {
+					continue
+				}
+			}
+			if !commit.Commit().MatchMetadata(metadata) {
+				continue
+			}
+			if params.Issue != nil && !slices.ContainsFunc(repository.IssueRefs(commit.Commit().Message), func(ref models.IssueRef) bool {
+				return ref.ID == *params.Issue
+			}) {
+				continue
+			}
+			if params.Amount != nil && len(commits) == *params.Amount {
+				hasMore = true
+				break
+			}
+			modelCommit := commit.Commit()
+			commits = append(commits, *commitToDto(modelCommit))
+			continue
+		}
+		if err == io.EOF {
+			break
+		}
+		w.Error(err)
+		return
+	}
+	w.JSON(commitListOf(hasMore, commits))
+}
+
+// commitListOf page of commit log, next offset is committer time of last commit which used as after of next page
+func commitListOf(hasMore bool, commits []api.Commit) api.CommitList {
+	pagination := api.Pagination{
+		HasMore:    hasMore,
+		MaxPerPage: utils.DefaultMaxPerPage,
+		Results:    len(commits),
+	}
+	if hasMore && len(commits) > 0 {
+		pagination.NextOffset = strconv.FormatInt(commits[len(commits)-1].Committer.When, 10)
+	}
+	return api.CommitList{
+		Pagination: pagination,
+		Results:    commits,
+	}
+}
+
+func (repositoryCtl RepositoryController) GetFileLog(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.GetFileLogParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetOwnerID(owner.ID).SetName(repositoryName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !repositoryCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.ReadCommitAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	if string(params.RefType) != string(versionmgr.InBranch) && string(params.RefType) != string(versionmgr.InTag) && string(params.RefType) != string(versionmgr.InCommit) {
+		w.BadRequest("file log ref type (%s) only allow branch, tag and commit", params.RefType)
+		return
+	}
+
+	hidden, err := privatePathFilter(ctx, repositoryCtl.Repo, repository)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	// private path is responded as missing, not to reveal it exists
+	if hidden(params.Path) {
+		w.NotFound()
+		return
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, repositoryCtl.Repo, repositoryCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	err = workRepo.CheckOut(ctx, versionmgr.WorkRepoState(params.RefType), params.RefName)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	commits := make([]api.Commit, 0)
+	if workRepo.CurCommit() == nil {
+		w.JSON(commits)
+		return
+	}
+
+	logParams := versionmgr.NewFileLogParams()
+	if params.After != nil {
+		logParams.SetAfter(time.UnixMilli(*params.After))
+	}
+	if params.Amount != nil {
+		logParams.SetAmount(*params.Amount)
+	}
+	fileLog, err := workRepo.FileLog(ctx, workRepo.CurCommit().Hash, params.Path, logParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	for _, commit := range fileLog {
+		commits = append(commits, *commitToDto(commit))
+	}
+	w.JSON(commits)
+}
+
+func (repositoryCtl RepositoryController) GetBloatAnalysis(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.GetBloatAnalysisParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetOwnerID(owner.ID).SetName(repositoryName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !repositoryCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.ReadCommitAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	if string(params.RefType) != string(versionmgr.InBranch) && string(params.RefType) != string(versionmgr.InTag) && string(params.RefType) != string(versionmgr.InCommit) {
+		w.BadRequest("bloat analysis ref type (%s) only allow branch, tag and commit", params.RefType)
+		return
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, repositoryCtl.Repo, repositoryCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	err = workRepo.CheckOut(ctx, versionmgr.WorkRepoState(params.RefType), params.RefName)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if workRepo.CurCommit() == nil {
+		w.JSON(api.BloatAnalysis{
+			LargestFiles:    []api.LargeFile{},
+			GrowingPrefixes: []api.PrefixGrowth{},
+		})
+		return
+	}
+
+	analysisParams := versionmgr.NewBloatAnalysisParams().
+		SetAmount(utils.IntValue(params.Amount)).
+		SetCommits(utils.IntValue(params.Commits)).
+		SetDepth(utils.IntValue(params.Depth))
+	analysis, err := workRepo.AnalyzeBloat(ctx, workRepo.CurCommit().Hash, analysisParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	hidden, err := privatePathFilter(ctx, repositoryCtl.Repo, repository)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	result := api.BloatAnalysis{
+		Commits:         analysis.Commits,
+		LargestFiles:    make([]api.LargeFile, 0, len(analysis.LargestFiles)),
+		GrowingPrefixes: make([]api.PrefixGrowth, 0, len(analysis.GrowingPrefixes)),
+	}
+	for _, file := range analysis.LargestFiles {
+		if hidden(file.Path) {
+			continue
+		}
+		result.LargestFiles = append(result.LargestFiles, api.LargeFile{
+			Path: file.Path,
+			Hash: file.Hash.Hex(),
+			Size: file.Size,
+		})
+	}
+	for _, growth := range analysis.GrowingPrefixes {
+		if hidden(growth.Prefix) {
+			continue
+		}
+		result.GrowingPrefixes = append(result.GrowingPrefixes, api.PrefixGrowth{
+			Prefix:       growth.Prefix,
+			Commits:      growth.Commits,
+			BytesAdded:   growth.BytesAdded,
+			BytesRemoved: growth.BytesRemoved,
+			NetBytes:     growth.NetBytes(),
+		})
+	}
+	w.JSON(result)
+}
+
+func (repositoryCtl RepositoryController) ListPartitions(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.ListPartitionsParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetOwnerID(owner.ID).SetName(repositoryName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !repositoryCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.ReadObjectAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	if string(params.RefType) != string(versionmgr.InBranch) && string(params.RefType) != string(versionmgr.InTag) && string(params.RefType) != string(versionmgr.InCommit) {
+		w.BadRequest("partitions ref type (%s) only allow branch, tag and commit", params.RefType)
+		return
+	}
+
+	var predicates []versionmgr.PartitionPredicate
+	for _, filter := range utils.Slice(params.Filter) {
+		predicate, err := versionmgr.ParsePartitionPredicate(filter)
+		if err != nil {
+			w.BadRequest("partition filter %s must be like year>=2023 or region=us|eu", filter)
+			return
+		}
+		predicates = append(predicates, predicate)
+	}
+
+	hidden, err := privatePathFilter(ctx, repositoryCtl.Repo, repository)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	path := versionmgr.CleanPath(utils.StringValue(params.Path))
+	if len(path) > 0 && hidden(path) {
+		w.NotFound()
+		return
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, repositoryCtl.Repo, repositoryCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	err = workRepo.CheckOut(ctx, versionmgr.WorkRepoState(params.RefType), params.RefName)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	result := api.PartitionListing{
+		Keys:       []string{},
+		Partitions: []api.Partition{},
+	}
+	if workRepo.CurCommit() == nil {
+		w.JSON(result)
+		return
+	}
+
+	listing, err := workRepo.ListPartitions(ctx, workRepo.CurCommit().Hash, path, predicates)
+	if err != nil {
+		if errors.Is(err, versionmgr.ErrPathNotFound) {
+			w.NotFound()
+			return
+		}
+		if errors.Is(err, versionmgr.ErrNotDirectory) {
+			w.BadRequest("path %s must be a directory", path)
+			return
+		}
+		w.Error(err)
+		return
+	}
+
+	result.Keys = listing.Keys
+	for _, partition := range listing.Partitions {
+		if hidden(partition.Path) {
+			continue
+		}
+		result.Partitions = append(result.Partitions, api.Partition{
+			Path:   partition.Path,
+			Values: partition.Values,
+		})
+	}
+	w.JSON(result)
+}
+
+func (repositoryCtl RepositoryController) GetSchemaHistory(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.GetSchemaHistoryParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetOwnerID(owner.ID).SetName(repositoryName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !repositoryCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.ReadCommitAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	if string(params.RefType) != string(versionmgr.InBranch) && string(params.RefType) != string(versionmgr.InTag) && string(params.RefType) != string(versionmgr.InCommit) {
+		w.BadRequest("schema history ref type (%s) only allow branch, tag and commit", params.RefType)
+		return
+	}
+
+	hidden, err := privatePathFilter(ctx, repositoryCtl.Repo, repository)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	// private path is responded as missing, not to reveal it exists
+	if hidden(params.Path) {
+		w.NotFound()
+		return
+	}
+
+	if _, ok := masking.FormatFromPath(params.Path); !ok {
+		w.BadRequest("path %s is not a csv or jsonl file", params.Path)
+		return
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, repositoryCtl.Repo, repositoryCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	err = workRepo.CheckOut(ctx, versionmgr.WorkRepoState(params.RefType), params.RefName)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	versions := make([]api.FileSchemaVersion, 0)
+	if workRepo.CurCommit() == nil {
+		w.JSON(versions)
+		return
+	}
+
+	logParams := versionmgr.NewFileLogParams()
+	if params.After != nil {
+		logParams.SetAfter(time.UnixMilli(*params.After))
+	}
+	if params.Amount != nil {
+		logParams.SetAmount(*params.Amount)
+	}
+	schemaHistory, err := workRepo.SchemaHistory(ctx, workRepo.CurCommit().Hash, params.Path, logParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	for _, version := range schemaHistory {
+		versions = append(versions, schemaVersionToDto(version))
+	}
+	w.JSON(versions)
+}
+
+func (repositoryCtl RepositoryController) ChangeVisible(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.ChangeVisibleParams) {
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repo, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !repositoryCtl.authorizeMember(ctx, w, repo.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.UpdateVisibleAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repo.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	updateParams := models.NewUpdateRepoParams(repo.ID).SetVisible(params.Visible)
+	err = repositoryCtl.Repo.RepositoryRepo().UpdateByID(ctx, updateParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.OK()
+}
+
+// ArchiveRepository make repository read only
+func (repositoryCtl RepositoryController) ArchiveRepository(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string) {
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !repositoryCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.ArchiveRepositoryAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	repositoryCtl.setArchived(ctx, w, repository, true)
+}
+
+// UnarchiveRepository make archived repository writable again, only admins are allowed
+func (repositoryCtl RepositoryController) UnarchiveRepository(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string) {
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !repositoryCtl.authorize(ctx, w, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.UnarchiveRepositoryAction,
+			Resource: rbacmodel.All,
+		},
+	}) {
+		return
+	}
+
+	repositoryCtl.setArchived(ctx, w, repository, false)
+}
+
+func (repositoryCtl RepositoryController) setArchived(ctx context.Context, w *api.JiaozifsResponse, repository *models.Repository, archived bool) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	eventType := models.RepositoryArchivedEvent
+	if !archived {
+		eventType = models.RepositoryUnarchivedEvent
+	}
+	err = repositoryCtl.Repo.Transaction(ctx, func(repo models.IRepo) error {
+		err := repo.RepositoryRepo().UpdateByID(ctx, models.NewUpdateRepoParams(repository.ID).SetArchived(archived))
+		if err != nil {
+			return err
+		}
+		_, err = repo.EventRepo().Insert(ctx, models.NewEvent(eventType, repository.ID, operator.ID, map[string]string{
+			"repository": repository.Name,
+		}))
+		return err
+	})
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err = repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetID(repository.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.JSON(repositoryToDto(repository))
+}
+
+func (repositoryCtl RepositoryController) GetArchive(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.GetArchiveParams) {
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !repositoryCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.ReadObjectAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, repositoryCtl.Repo, repositoryCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if string(params.RefType) != string(versionmgr.InBranch) && string(params.RefType) != string(versionmgr.InTag) && string(params.RefType) != string(versionmgr.InCommit) {
+		w.BadRequest("archive ref type (%s) only allow branch, tag and commit", params.RefType)
+		return
+	}
+
+	err = workRepo.CheckOut(ctx, versionmgr.WorkRepoState(params.RefType), params.RefName)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	hidden, err := privatePathFilter(ctx, repositoryCtl.Repo, repository)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	subPath := versionmgr.CleanPath(utils.StringValue(params.Path))
+	if len(subPath) > 0 && hidden(subPath) {
+		w.BadRequest("path %s not found", subPath)
+		return
+	}
+	// files in archive can not be masked, archive is only for users reading them unmasked
+	if len(repository.MaskingRules) > 0 {
+		unmask, err := repositoryCtl.canUnmask(ctx, repository)
+		if err != nil {
+			w.Error(err)
+			return
+		}
+		if !unmask {
+			w.Forbidden()
+			return
+		}
+	}
+	if params.ArchiveType != api.Car {
+		rootTree, err := workRepo.RootTree(ctx)
+		if err != nil {
+			w.Error(err)
+			return
+		}
+		// check path before response header written
+		subTree, err := rootTree.FindTree(ctx, subPath)
+		if err != nil {
+			w.Error(err)
+			return
+		}
+
+		name := repository.Name
+		if len(subPath) > 0 {
+			name = subTree.Name()
+		}
+		w.Header().Set("Content-Type", "application/octet-stream")
+		w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fmt.Sprintf("%s.%s", name, params.ArchiveType)))
+		err = workRepo.WriteArchive(ctx, w, versionmgr.ArchiveType(params.ArchiveType), subPath, hidden)
+		if err != nil {
+			objLog.With(
+				"user", ownerName,
+				"repo", repositoryName,
+				"reftype", params.RefType,
+				"refname", params.RefName,
+			).Debugf("stream archive content %v", err)
+		}
+		return
+	}
+
+	if len(subPath) > 0 {
+		w.BadRequest("archive path not support car files")
+		return
+	}
+	// car file holds whole dag of commit, private paths can not be cut out of it
+	if slices.ContainsFunc(repository.PrivatePrefixes, hidden) {
+		w.Forbidden()
+		return
+	}
+
+	readeCloser, size, err := workRepo.Archive(ctx, versionmgr.ArchiveType(params.ArchiveType))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	defer readeCloser.Close() //nolint
+	w.Header().Set("Content-Length", fmt.Sprint(size))
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fmt.Sprintf("%s.%s", repository.Name, params.ArchiveType)))
+	_, err = io.Copy(w, readeCloser)
+	if err != nil {
+		objLog.With(
+			"user", ownerName,
+			"repo", repositoryName,
+			"reftype", params.RefType,
+			"refname", params.RefName,
+		).Debugf("archive copy content %v", err)
+
+	}
+	w.OK()
+}
+
+// RunGarbageCollection remove objects not reachable from branches, tags and wips
+func (repositoryCtl RepositoryController) RunGarbageCollection(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.RunGarbageCollectionParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !repositoryCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.UpdateRepositoryAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	gcOpt := versionmgr.GcOption{
+		DryRun:      utils.BoolValue(params.DryRun),
+		GracePeriod: versionmgr.DefaultGcGracePeriod,
+	}
+	if params.GracePeriod != nil {
+		if *params.GracePeriod < 0 {
+			w.BadRequest("grace period must not be negative")
+			return
+		}
+		gcOpt.GracePeriod = time.Duration(*params.GracePeriod) * time.Second
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, repositoryCtl.Repo, repositoryCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	result, err := workRepo.GarbageCollector().GC(ctx, gcOpt)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	w.JSON(api.GcResult{
+		DryRun:                 result.DryRun,
+		ReachableCommits:       result.ReachableCommits,
+		ReachableObjects:       result.ReachableObjects,
+		UnreachableCommits:     result.UnreachableCommits,
+		UnreachableTrees:       result.UnreachableTrees,
+		UnreachableBlobs:       result.UnreachableBlobs,
+		ReclaimableFiles:       result.ReclaimableFiles,
+		ReclaimableBytes:       result.ReclaimableBytes,
+		UnreachablePackedBlobs: &result.UnreachablePackedBlobs,
+	})
+}
+
+// CheckConsistency compare metadata of repository with its storage, it touches storage shared by forks so only admins
+// granted all resources are allowed
+func (repositoryCtl RepositoryController) CheckConsistency(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.CheckConsistencyParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !repositoryCtl.authorize(ctx, w, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.CheckConsistencyAction,
+			Resource: rbacmodel.All,
+		},
+	}) {
+		return
+	}
+
+	checkOpt := versionmgr.ConsistencyOption{
+		Repair:      utils.BoolValue(params.Repair),
+		GracePeriod: versionmgr.DefaultGcGracePeriod,
+	}
+	if params.Orphans != nil {
+		switch *params.Orphans {
+		case api.Report:
+			checkOpt.Orphans = versionmgr.OrphanReport
+		case api.Quarantine:
+			checkOpt.Orphans = versionmgr.OrphanQuarantine
+		case api.Remove:
+			checkOpt.Orphans = versionmgr.OrphanRemove
+		default:
+			w.BadRequest("unknown orphan action %s", *params.Orphans)
+			return
+		}
+	}
+	if params.GracePeriod != nil {
+		if *params.GracePeriod < 0 {
+			w.BadRequest("grace period must not be negative")
+			return
+		}
+		checkOpt.GracePeriod = time.Duration(*params.GracePeriod) * time.Second
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, repositoryCtl.Repo, repositoryCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	report, err := workRepo.ConsistencyChecker().Check(ctx, checkOpt)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	missing := make([]api.MissingBlob, 0, len(report.Missing))
+	for _, blob := range report.Missing {
+		var pack *string
+		if !blob.Pack.IsEmpty() {
+			pack = utils.String(blob.Pack.Hex())
+		}
+		missing = append(missing, api.MissingBlob{
+			Hash:     blob.Hash.Hex(),
+			CheckSum: blob.CheckSum.Hex(),
+			Size:     blob.Size,
+			Pack:     pack,
+		})
+	}
+	orphans := make([]api.OrphanFile, 0, len(report.Orphans))
+	for _, orphan := range report.Orphans {
+		var modifiedAt *int64
+		if !orphan.ModifiedAt.IsZero() {
+			modifiedAt = utils.Int64(orphan.ModifiedAt.UnixMilli())
+		}
+		orphans = append(orphans, api.OrphanFile{
+			Path:       orphan.Path,
+			Size:       orphan.Size,
+			ModifiedAt: modifiedAt,
+		})
+	}
+	w.JSON(api.ConsistencyReport{
+		CheckedBlobs:   report.CheckedBlobs,
+		Missing:        missing,
+		DanglingPacked: report.DanglingPacked,
+		OrphansScanned: report.OrphansScanned,
+		Orphans:        orphans,
+		Repaired:       report.Repaired,
+		Quarantined:    report.Quarantined,
+		Removed:        report.Removed,
+	})
+}
+
+// RollbackRepository roll all branches and tags of repository back to a past time, heads discarded are kept as backup refs
+func (repositoryCtl RepositoryController) RollbackRepository(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, body api.RollbackRepositoryJSONRequestBody, ownerName string, repositoryName string) {
+	backupPrefix := utils.StringValue(body.BackupPrefix)
+	if len(backupPrefix) > 0 {
+		if err := validator.ValidateRefPrefix(backupPrefix); err != nil {
+			w.BadRequest(err.Error())
+			return
+		}
+	}
+
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !repositoryCtl.authorize(ctx, w, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.RollbackRepositoryAction,
+			Resource: rbacmodel.All,
+		},
+	}) {
+		return
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, repositoryCtl.Repo, repositoryCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	result, err := workRepo.RollbackTo(ctx, time.UnixMilli(body.Time), backupPrefix)
+	if errors.Is(err, versionmgr.ErrRollbackBeforeCreation) {
+		w.BadRequest(err.Error())
+		return
+	}
+	if err != nil {
+		w.Error(err)
+		return
 	}
-	w.OK()
+	w.JSON(api.RollbackResult{
+		Branches: refRollbacksToDto(result.Branches),
+		Tags:     refRollbacksToDto(result.Tags),
+	})
 }
 
-func (repositoryCtl RepositoryController) GetArchive(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.GetArchiveParams) {
+func refRollbacksToDto(changes []versionmgr.RefRollback) []api.RefRollback {
+	dtos := make([]api.RefRollback, 0, len(changes))
+	for _, change := range changes {
+		dto := api.RefRollback{Name: change.Name}
+		if change.Old != nil {
+			dto.Old = utils.String(change.Old.Hex())
+		}
+		if change.New != nil {
+			dto.New = utils.String(change.New.Hex())
+		}
+		if len(change.Backup) > 0 {
+			dto.Backup = utils.String(change.Backup)
+		}
+		dtos = append(dtos, dto)
+	}
+	return dtos
+}
+
+// PackObjects move content of small blobs into packs
+func (repositoryCtl RepositoryController) PackObjects(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.PackObjectsParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
 	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
 	if err != nil {
 		w.Error(err)
@@ -560,71 +2219,495 @@ func (repositoryCtl RepositoryController) GetArchive(ctx context.Context, w *api
 
 	if !repositoryCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
 		Permission: rbac.Permission{
-			Action:   rbacmodel.ReadObjectAction,
+			Action:   rbacmodel.UpdateRepositoryAction,
 			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
 		},
 	}) {
 		return
 	}
 
+	packOpt := versionmgr.PackOption{Threshold: versionmgr.DefaultPackThreshold}
+	if params.Threshold != nil {
+		if *params.Threshold <= 0 {
+			w.BadRequest("pack threshold must be positive")
+			return
+		}
+		packOpt.Threshold = *params.Threshold
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, repositoryCtl.Repo, repositoryCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	result, err := workRepo.Packer().Pack(ctx, packOpt)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	w.JSON(api.PackResult{
+		Packs:   result.Packs,
+		Objects: result.Objects,
+		Bytes:   result.Bytes,
+	})
+}
+
+// storageOperationActions permission required to issue credentials allowing the operation
+var storageOperationActions = map[api.StorageOperation]string{
+	api.StorageOperationRead:   rbacmodel.ReadObjectAction,
+	api.StorageOperationList:   rbacmodel.ListObjectsAction,
+	api.StorageOperationWrite:  rbacmodel.WriteObjectAction,
+	api.StorageOperationDelete: rbacmodel.DeleteObjectAction,
+}
+
+// GetStorageCredentials exchange token for temporary s3 credentials limited to operations on storage prefix of repository,
+// every issuance is recorded in event log
+func (repositoryCtl RepositoryController) GetStorageCredentials(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.GetStorageCredentialsParams) {
 	operator, err := auth.GetOperator(ctx)
 	if err != nil {
 		w.Error(err)
 		return
 	}
 
-	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, repositoryCtl.Repo, repositoryCtl.PublicStorageConfig)
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
 	if err != nil {
 		w.Error(err)
 		return
 	}
 
-	if string(params.RefType) != string(versionmgr.InBranch) && string(params.RefType) != string(versionmgr.InTag) {
-		w.BadRequest("archive ref type (%s) only allow branch and tag", params.RefType)
+	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
 		return
 	}
 
-	err = workRepo.CheckOut(ctx, versionmgr.WorkRepoState(params.RefType), params.RefName)
+	operations := []api.StorageOperation{api.StorageOperationRead, api.StorageOperationList}
+	if params.Operations != nil && len(*params.Operations) > 0 {
+		operations = *params.Operations
+	}
+	var permissions []rbac.Node
+	var scopedOperations []s3.Operation
+	for _, op := range operations {
+		action, ok := storageOperationActions[op]
+		if !ok {
+			w.BadRequest("storage operation %s not support", op)
+			return
+		}
+		permissions = append(permissions, rbac.Node{
+			Permission: rbac.Permission{
+				Action:   action,
+				Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+			},
+		})
+		scopedOperations = append(scopedOperations, s3.Operation(op))
+	}
+	// objects are read raw from storage, masking rules can not be applied
+	if len(repository.MaskingRules) > 0 {
+		permissions = append(permissions, rbac.Node{
+			Permission: rbac.Permission{
+				Action:   rbacmodel.UnmaskObjectAction,
+				Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+			},
+		})
+	}
+	if !repositoryCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Type:  rbac.NodeTypeAnd,
+		Nodes: permissions,
+	}) {
+		return
+	}
+
+	// storage is keyed by content not path, credentials would read private paths hidden from operator
+	hidden, err := privatePathFilter(ctx, repositoryCtl.Repo, repository)
 	if err != nil {
 		w.Error(err)
 		return
 	}
+	if slices.ContainsFunc(repository.PrivatePrefixes, hidden) {
+		w.Forbidden()
+		return
+	}
 
-	readeCloser, size, err := workRepo.Archive(ctx, versionmgr.ArchiveType(params.ArchiveType))
+	var expiresIn time.Duration
+	if params.ExpiresIn != nil {
+		expiresIn = time.Duration(*params.ExpiresIn) * time.Second
+		if expiresIn < s3.MinScopedCredentialsDuration {
+			w.BadRequest("credentials must be valid for at least %d seconds", int64(s3.MinScopedCredentialsDuration.Seconds()))
+			return
+		}
+	}
+
+	adapterConfig, err := versionmgr.AdapterConfigOf(repository, repositoryCtl.PublicStorageConfig)
 	if err != nil {
 		w.Error(err)
 		return
 	}
-	defer readeCloser.Close() //nolint
-	w.Header().Set("Content-Length", fmt.Sprint(size))
-	w.Header().Set("Content-Type", "application/octet-stream")
-	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fmt.Sprintf("%s.%s", repository.Name, params.ArchiveType)))
-	_, err = io.Copy(w, readeCloser)
+	if adapterConfig.BlockstoreType() != block.BlockstoreTypeS3 {
+		w.BadRequest("storage credentials only supported for s3 storage")
+		return
+	}
+	s3Params, err := adapterConfig.BlockstoreS3Params()
 	if err != nil {
-		objLog.With(
-			"user", ownerName,
-			"repo", repositoryName,
-			"reftype", params.RefType,
-			"refname", params.RefName,
-		).Debugf("archive copy content %v", err)
+		w.Error(err)
+		return
+	}
+	if len(s3Params.AssumeRoleARN) == 0 {
+		w.BadRequest("assume role of s3 storage not configured")
+		return
+	}
+
+	qk, err := block.DefaultResolveNamespace(utils.StringValue(repository.StorageNamespace), "", block.IdentifierTypeRelative)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	bucket, namespacePrefix := s3.ExtractParamsFromQK(qk)
+	prefix, err := s3.PinPrefix(namespacePrefix, utils.StringValue(params.Prefix))
+	if err != nil {
+		w.BadRequest("prefix %s out of storage namespace", utils.StringValue(params.Prefix))
+		return
+	}
+
+	creds, err := s3.AssumeScopedRole(ctx, s3Params, "jiaozifs-"+operator.ID.String(), bucket, prefix, scopedOperations, expiresIn)
+	if err != nil {
+		w.Error(err)
+		return
+	}
 
+	opNames := make([]string, 0, len(operations))
+	for _, op := range operations {
+		opNames = append(opNames, string(op))
 	}
-	w.OK()
+	_, err = repositoryCtl.Repo.EventRepo().Insert(ctx, models.NewEvent(models.StorageCredentialsIssuedEvent, repository.ID, operator.ID, map[string]string{
+		"operations": strings.Join(opNames, ","),
+		"bucket":     creds.Bucket,
+		"prefix":     creds.Prefix,
+		"expiration": creds.Expiration.Format(time.RFC3339),
+	}))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	w.JSON(api.StorageCredentials{
+		Operations:      operations,
+		AccessKeyId:     creds.AccessKeyID,
+		SecretAccessKey: creds.SecretAccessKey,
+		SessionToken:    creds.SessionToken,
+		Expiration:      creds.Expiration.UnixMilli(),
+		Bucket:          creds.Bucket,
+		Prefix:          creds.Prefix,
+		Region:          utils.String(creds.Region),
+		Endpoint:        utils.String(creds.Endpoint),
+	})
+}
+
+// MigrateHashType rewrite history of repository with another hash algorithm
+func (repositoryCtl RepositoryController) MigrateHashType(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.MigrateHashTypeParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !repositoryCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.UpdateRepositoryAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	hashType, err := hash.ParseHashType(string(params.HashType))
+	if err != nil {
+		w.BadRequest(err.Error())
+		return
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, repositoryCtl.Repo, repositoryCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	result, err := workRepo.HashMigrator().Migrate(ctx, hashType)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	w.JSON(api.HashMigrationResult{
+		From:    api.HashType(result.From.String()),
+		To:      api.HashType(result.To.String()),
+		Commits: result.Commits,
+		Objects: result.Objects,
+	})
+}
+
+// GetRepositoryStats storage usage of repository, counters are recalculated from commits and objects if asked
+func (repositoryCtl RepositoryController) GetRepositoryStats(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.GetRepositoryStatsParams) {
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !repositoryCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.ReadRepositoryAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	var stats *models.RepositoryStats
+	if params.Recalculate != nil && *params.Recalculate {
+		stats, err = repositoryCtl.Repo.RepositoryRepo().RecalculateStats(ctx, repository.ID)
+	} else {
+		stats, err = repositoryCtl.Repo.RepositoryRepo().GetStats(ctx, repository.ID)
+	}
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	w.JSON(api.RepositoryStats{
+		RepositoryId: stats.RepositoryID,
+		BlobBytes:    stats.BlobBytes,
+		ObjectCount:  stats.ObjectCount,
+		CommitCount:  stats.CommitCount,
+		UpdatedAt:    stats.UpdatedAt.UnixMilli(),
+	})
 }
 
 func repositoryToDto(repository *models.Repository) *api.Repository {
+	hashType := api.HashType(repository.HashType.String())
+	var deletedAt *int64
+	if !repository.DeletedAt.IsZero() {
+		deletedAt = utils.Int64(repository.DeletedAt.UnixMilli())
+	}
+	var forkedFromID *uuid.UUID
+	if repository.ForkedFromID != uuid.Nil {
+		forkedFromID = &repository.ForkedFromID
+	}
 	return &api.Repository{
-		CreatedAt:            repository.CreatedAt.UnixMilli(),
-		CreatorId:            repository.CreatorID,
-		Description:          repository.Description,
-		Visible:              repository.Visible,
-		Head:                 repository.HEAD,
-		Id:                   repository.ID,
-		Name:                 repository.Name,
-		UpdatedAt:            repository.UpdatedAt.UnixMilli(),
-		OwnerId:              repository.OwnerID,
-		StorageAdapterParams: repository.StorageAdapterParams,
-		StorageNamespace:     repository.StorageNamespace,
-		UsePublicStorage:     repository.UsePublicStorage,
+		CreatedAt:             repository.CreatedAt.UnixMilli(),
+		CreatorId:             repository.CreatorID,
+		Description:           repository.Description,
+		CommitMessageTemplate: repository.CommitMessageTemplate,
+		Visible:               repository.Visible,
+		Head:                  repository.HEAD,
+		Id:                    repository.ID,
+		Name:                  repository.Name,
+		UpdatedAt:             repository.UpdatedAt.UnixMilli(),
+		OwnerId:               repository.OwnerID,
+		StorageAdapterParams:  repository.StorageAdapterParams,
+		StorageNamespace:      repository.StorageNamespace,
+		UsePublicStorage:      repository.UsePublicStorage,
+		Archived:              repository.Archived,
+		HashType:              &hashType,
+		WriteRateLimit:        &repository.WriteRateLimit,
+		WriteBurst:            &repository.WriteBurst,
+		MaxConcurrentUploads:  &repository.MaxConcurrentUploads,
+		StorageQuota:          &repository.StorageQuota,
+		QuotaWarning:          &repository.QuotaWarning,
+		DeletedAt:             deletedAt,
+		PrivatePrefixes:       &repository.PrivatePrefixes,
+		MaskingRules:          maskingRulesToDto(repository.MaskingRules),
+		MergeRules:            mergeRulesToDto(repository.MergeRules),
+		Hooks:                 hooksToDto(repository.Hooks),
+		IssueLinks:            issueLinksToDto(repository.IssueLinks),
+		Version:               repository.Version,
+		ForkedFromId:          forkedFromID,
+	}
+}
+
+func mergeRulesToDto(rules []models.MergeRule) *[]api.MergeRule {
+	dtos := make([]api.MergeRule, len(rules))
+	for i := range rules {
+		dtos[i] = api.MergeRule{
+			Branch:           rules[i].Branch,
+			DefaultReviewers: &rules[i].DefaultReviewers,
+			Checklist:        &rules[i].Checklist,
+			RequiredChecks:   &rules[i].RequiredChecks,
+		}
+	}
+	return &dtos
+}
+
+func hooksToDto(hooks []models.Hook) *[]api.Hook {
+	dtos := make([]api.Hook, len(hooks))
+	for i := range hooks {
+		events := make([]api.HookEvents, len(hooks[i].Events))
+		for j, event := range hooks[i].Events {
+			events[j] = api.HookEvents(event)
+		}
+		dtos[i] = api.Hook{
+			Name:         hooks[i].Name,
+			Events:       events,
+			Branch:       &hooks[i].Branch,
+			Type:         api.HookType(hooks[i].Type),
+			Paths:        &hooks[i].Paths,
+			MetadataKeys: &hooks[i].MetadataKeys,
+			Message:      &hooks[i].Message,
+		}
+	}
+	return &dtos
+}
+
+func issueLinksToDto(links []models.IssueLink) *[]api.IssueLink {
+	dtos := make([]api.IssueLink, len(links))
+	for i, link := range links {
+		dtos[i] = api.IssueLink{
+			Prefix:      link.Prefix,
+			UrlTemplate: link.URLTemplate,
+		}
+	}
+	return &dtos
+}
+
+func issueRefsToDto(refs []models.IssueRef) *[]api.IssueRef {
+	dtos := make([]api.IssueRef, len(refs))
+	for i, ref := range refs {
+		dtos[i] = api.IssueRef{
+			Id:  ref.ID,
+			Url: ref.URL,
+		}
+	}
+	return &dtos
+}
+
+func maskingRulesToDto(rules []models.MaskingRule) *[]api.MaskingRule {
+	dtos := make([]api.MaskingRule, len(rules))
+	for i, rule := range rules {
+		dtos[i] = api.MaskingRule{
+			Path:    rule.Path,
+			Columns: rule.Columns,
+			Method:  api.MaskingRuleMethod(rule.Method),
+		}
+	}
+	return &dtos
+}
+
+// CreateUsageReport request usage report of repository, it is generated by background reporter
+func (repositoryCtl RepositoryController) CreateUsageReport(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, ok := repositoryCtl.usageReportRepository(ctx, w, ownerName, repositoryName)
+	if !ok {
+		return
+	}
+
+	report, err := repositoryCtl.Repo.UsageReportRepo().Insert(ctx, models.NewUsageReport(repository.ID, operator.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	repositoryCtl.Reporter.Trigger()
+	w.JSON(usageReportToDto(report), http.StatusAccepted)
+}
+
+func (repositoryCtl RepositoryController) GetUsageReport(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, reportID uuid.UUID) {
+	repository, ok := repositoryCtl.usageReportRepository(ctx, w, ownerName, repositoryName)
+	if !ok {
+		return
+	}
+
+	report, err := repositoryCtl.Repo.UsageReportRepo().Get(ctx, models.NewGetUsageReportParams().SetID(reportID).SetRepositoryID(repository.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.JSON(usageReportToDto(report))
+}
+
+// DownloadUsageReport write csv of report, report must be done
+func (repositoryCtl RepositoryController) DownloadUsageReport(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, reportID uuid.UUID) {
+	repository, ok := repositoryCtl.usageReportRepository(ctx, w, ownerName, repositoryName)
+	if !ok {
+		return
+	}
+
+	report, err := repositoryCtl.Repo.UsageReportRepo().Get(ctx, models.NewGetUsageReportParams().SetID(reportID).SetRepositoryID(repository.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	if report.State != models.UsageReportDone {
+		w.BadRequest("usage report is %s", report.State)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/csv")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fmt.Sprintf("%s-usage-%s.csv", repository.Name, report.FinishedAt.Format(time.DateOnly))))
+	w.Header().Set("Content-Length", fmt.Sprint(len(report.Content)))
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write(report.Content)
+}
+
+// usageReportRepository find repository whose usage report operator is authorized to access
+func (repositoryCtl RepositoryController) usageReportRepository(ctx context.Context, w *api.JiaozifsResponse, ownerName, repositoryName string) (*models.Repository, bool) {
+	owner, err := repositoryCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return nil, false
+	}
+
+	repository, err := repositoryCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return nil, false
+	}
+
+	if !repositoryCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.UsageReportAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return nil, false
+	}
+	return repository, true
+}
+
+func usageReportToDto(report *models.UsageReport) *api.UsageReport {
+	var finishedAt *int64
+	if !report.FinishedAt.IsZero() {
+		finishedAt = utils.Int64(report.FinishedAt.UnixMilli())
+	}
+	return &api.UsageReport{
+		Id:           report.ID,
+		RepositoryId: report.RepositoryID,
+		State:        api.UsageReportState(report.State),
+		Error:        utils.String(report.Error),
+		CreatedAt:    report.CreatedAt.UnixMilli(),
+		UpdatedAt:    report.UpdatedAt.UnixMilli(),
+		FinishedAt:   finishedAt,
 	}
 }
@@ -0,0 +1,273 @@
+package controller
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/models/rbacmodel"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/versionmgr"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+)
+
+// ListReviewComments list threads of merge request, readers of merge request can review it
+func (mrCtl MergeRequestController) ListReviewComments(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, mrSeq uint64, params api.ListReviewCommentsParams) {
+	_, mergeRequest, ok := mrCtl.findMergeRequest(ctx, w, ownerName, repositoryName, mrSeq, rbacmodel.ReadMergeRequestAction)
+	if !ok {
+		return
+	}
+
+	listParams := models.NewListReviewCommentParams().SetMergeRequestID(mergeRequest.ID)
+	if params.Path != nil {
+		listParams.SetPath(versionmgr.CleanPath(*params.Path))
+	}
+	if params.Resolved != nil {
+		listParams.SetResolved(*params.Resolved)
+	}
+	comments, err := mrCtl.Repo.ReviewCommentRepo().List(ctx, listParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	sourceHead, err := mrCtl.sourceHead(ctx, mergeRequest)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	results := make([]api.ReviewComment, 0, len(comments))
+	for _, comment := range comments {
+		results = append(results, reviewCommentToDto(comment, sourceHead))
+	}
+	w.JSON(results)
+}
+
+// CreateReviewComment start a thread or reply to one, reply is attached to root of thread it replies to
+func (mrCtl MergeRequestController) CreateReviewComment(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, body api.CreateReviewCommentJSONRequestBody, ownerName string, repositoryName string, mrSeq uint64) {
+	operator, mergeRequest, ok := mrCtl.findMergeRequest(ctx, w, ownerName, repositoryName, mrSeq, rbacmodel.ReadMergeRequestAction)
+	if !ok {
+		return
+	}
+
+	if len(body.Body) == 0 {
+		w.BadRequest("comment body must not be empty")
+		return
+	}
+
+	sourceHead, err := mrCtl.sourceHead(ctx, mergeRequest)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	comment := &models.ReviewComment{
+		RepositoryID:   mergeRequest.TargetRepoID,
+		MergeRequestID: mergeRequest.ID,
+		AuthorID:       operator.ID,
+		Body:           body.Body,
+		CommitHash:     sourceHead,
+		CreatedAt:      time.Now(),
+		UpdatedAt:      time.Now(),
+	}
+	if body.ParentId != nil {
+		parent, err := mrCtl.Repo.ReviewCommentRepo().Get(ctx, models.NewGetReviewCommentParams().SetID(*body.ParentId).SetMergeRequestID(mergeRequest.ID))
+		if err != nil {
+			w.Error(err)
+			return
+		}
+		comment.ParentID = parent.ID
+		if !parent.IsRoot() {
+			comment.ParentID = parent.ParentID
+		}
+		// replies follow anchor of thread
+		comment.Path, comment.StartLine, comment.EndLine, comment.StartByte, comment.EndByte = parent.Path, parent.StartLine, parent.EndLine, parent.StartByte, parent.EndByte
+	} else if body.Anchor != nil {
+		err = setReviewAnchor(comment, body.Anchor)
+		if err != nil {
+			w.BadRequest(err.Error())
+			return
+		}
+	}
+
+	comment, err = mrCtl.Repo.ReviewCommentRepo().Insert(ctx, comment)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.JSON(reviewCommentToDto(comment, sourceHead), http.StatusCreated)
+}
+
+func (mrCtl MergeRequestController) GetReviewComment(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, mrSeq uint64, commentID openapi_types.UUID) {
+	_, mergeRequest, ok := mrCtl.findMergeRequest(ctx, w, ownerName, repositoryName, mrSeq, rbacmodel.ReadMergeRequestAction)
+	if !ok {
+		return
+	}
+
+	comment, err := mrCtl.Repo.ReviewCommentRepo().Get(ctx, models.NewGetReviewCommentParams().SetID(commentID).SetMergeRequestID(mergeRequest.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	sourceHead, err := mrCtl.sourceHead(ctx, mergeRequest)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.JSON(reviewCommentToDto(comment, sourceHead))
+}
+
+// UpdateReviewComment edit body by author or resolve thread by any reviewer
+func (mrCtl MergeRequestController) UpdateReviewComment(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, body api.UpdateReviewCommentJSONRequestBody, ownerName string, repositoryName string, mrSeq uint64, commentID openapi_types.UUID) {
+	operator, mergeRequest, ok := mrCtl.findMergeRequest(ctx, w, ownerName, repositoryName, mrSeq, rbacmodel.ReadMergeRequestAction)
+	if !ok {
+		return
+	}
+
+	comment, err := mrCtl.Repo.ReviewCommentRepo().Get(ctx, models.NewGetReviewCommentParams().SetID(commentID).SetMergeRequestID(mergeRequest.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	updateParams := models.NewUpdateReviewCommentParams(comment.ID)
+	if body.Body != nil {
+		if comment.AuthorID != operator.ID {
+			w.Forbidden()
+			return
+		}
+		if len(*body.Body) == 0 {
+			w.BadRequest("comment body must not be empty")
+			return
+		}
+		updateParams.SetBody(*body.Body)
+	}
+	if body.Resolved != nil {
+		if !comment.IsRoot() {
+			w.BadRequest("only root comment of thread can be resolved")
+			return
+		}
+		updateParams.SetResolved(*body.Resolved, operator.ID)
+	}
+	err = mrCtl.Repo.ReviewCommentRepo().Update(ctx, updateParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	comment, err = mrCtl.Repo.ReviewCommentRepo().Get(ctx, models.NewGetReviewCommentParams().SetID(comment.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	sourceHead, err := mrCtl.sourceHead(ctx, mergeRequest)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.JSON(reviewCommentToDto(comment, sourceHead))
+}
+
+// DeleteReviewComment delete comment by its author, replies are deleted with root comment
+func (mrCtl MergeRequestController) DeleteReviewComment(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, mrSeq uint64, commentID openapi_types.UUID) {
+	operator, mergeRequest, ok := mrCtl.findMergeRequest(ctx, w, ownerName, repositoryName, mrSeq, rbacmodel.ReadMergeRequestAction)
+	if !ok {
+		return
+	}
+
+	comment, err := mrCtl.Repo.ReviewCommentRepo().Get(ctx, models.NewGetReviewCommentParams().SetID(commentID).SetMergeRequestID(mergeRequest.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	if comment.AuthorID != operator.ID {
+		w.Forbidden()
+		return
+	}
+
+	_, err = mrCtl.Repo.ReviewCommentRepo().Delete(ctx, models.NewDeleteReviewCommentParams().SetID(comment.ID).SetMergeRequestID(mergeRequest.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.OK()
+}
+
+// sourceHead current head of source branch, empty when source branch is deleted
+func (mrCtl MergeRequestController) sourceHead(ctx context.Context, mergeRequest *models.MergeRequest) (hash.Hash, error) {
+	sourceBranch, err := mrCtl.Repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetID(mergeRequest.SourceBranchID))
+	if errors.Is(err, models.ErrNotFound) {
+		return hash.Hash{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return sourceBranch.CommitHash, nil
+}
+
+func setReviewAnchor(comment *models.ReviewComment, anchor *api.ReviewAnchor) error {
+	comment.Path = versionmgr.CleanPath(anchor.Path)
+	if len(comment.Path) == 0 {
+		return errors.New("anchor path must not be empty")
+	}
+
+	hasLines := anchor.StartLine != nil || anchor.EndLine != nil
+	hasBytes := anchor.StartByte != nil || anchor.EndByte != nil
+	if hasLines && hasBytes {
+		return errors.New("anchor can not have both line range and byte range")
+	}
+	if hasLines {
+		comment.StartLine = utils.IntValue(anchor.StartLine)
+		comment.EndLine = utils.IntValue(anchor.EndLine)
+		if anchor.EndLine == nil {
+			comment.EndLine = comment.StartLine
+		}
+		if comment.StartLine < 1 || comment.EndLine < comment.StartLine {
+			return errors.New("anchor line range is invalid, lines start from 1")
+		}
+	}
+	if hasBytes {
+		comment.StartByte = utils.Int64Value(anchor.StartByte)
+		comment.EndByte = utils.Int64Value(anchor.EndByte)
+		if comment.StartByte < 0 || comment.EndByte <= comment.StartByte {
+			return errors.New("anchor byte range is invalid")
+		}
+	}
+	return nil
+}
+
+func reviewCommentToDto(comment *models.ReviewComment, sourceHead hash.Hash) api.ReviewComment {
+	dto := api.ReviewComment{
+		Id:             comment.ID,
+		MergeRequestId: comment.MergeRequestID,
+		AuthorId:       comment.AuthorID,
+		Body:           comment.Body,
+		CommitHash:     comment.CommitHash.Hex(),
+		Outdated:       !bytes.Equal(comment.CommitHash, sourceHead),
+		Resolved:       comment.Resolved,
+		CreatedAt:      comment.CreatedAt.UnixMilli(),
+		UpdatedAt:      comment.UpdatedAt.UnixMilli(),
+	}
+	if !comment.IsRoot() {
+		dto.ParentId = &comment.ParentID
+	}
+	if comment.Resolved {
+		dto.ResolvedBy = &comment.ResolvedBy
+	}
+	if len(comment.Path) > 0 {
+		dto.Anchor = &api.ReviewAnchor{Path: comment.Path}
+		if comment.StartLine > 0 {
+			dto.Anchor.StartLine = &comment.StartLine
+			dto.Anchor.EndLine = &comment.EndLine
+		}
+		if comment.EndByte > 0 {
+			dto.Anchor.StartByte = &comment.StartByte
+			dto.Anchor.EndByte = &comment.EndByte
+		}
+	}
+	return dto
+}
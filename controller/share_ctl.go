@@ -0,0 +1,467 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/GitDataAI/jiaozifs/auth"
+	"github.com/GitDataAI/jiaozifs/auth/rbac"
+	"github.com/GitDataAI/jiaozifs/block/params"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/models/rbacmodel"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/versionmgr"
+	logging "github.com/ipfs/go-log/v2"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+	"go.uber.org/fx"
+)
+
+var shareLog = logging.Logger("share_ctl")
+
+// ShareController hand a whole branch or tag to people without account, anyone holding the token can
+// browse, download and archive the ref until the share expired
+type ShareController struct {
+	fx.In
+	BaseController
+
+	Repo                models.IRepo
+	PublicStorageConfig params.AdapterConfig
+}
+
+func (shareCtl ShareController) ListShares(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.ListSharesParams) {
+	owner, err := shareCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := shareCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !shareCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.UpdateRepositoryAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	listParams := models.NewListShareParams().SetRepositoryID(repository.ID).SetAmount(pageAmount(params.Amount))
+	if params.Prefix != nil && len(*params.Prefix) > 0 {
+		match, ok := matchModeFromDto(w, *params.Prefix, params.Match)
+		if !ok {
+			return
+		}
+		listParams.SetRefName(*params.Prefix, match)
+	}
+	if params.After != nil {
+		listParams.SetAfter(time.UnixMilli(*params.After))
+	}
+	if params.AfterId != nil {
+		listParams.SetAfterID(*params.AfterId)
+	}
+	shares, hasMore, err := shareCtl.Repo.ShareRepo().List(ctx, listParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	results := utils.Silent(utils.ArrMap(shares, shareToDto))
+	pagMag := utils.PaginationFor(hasMore, results, "CreatedAt")
+	pagination := api.Pagination{
+		HasMore:      pagMag.HasMore,
+		MaxPerPage:   pagMag.MaxPerPage,
+		NextOffset:   pagMag.NextOffset,
+		NextOffsetId: pagMag.NextOffsetID,
+		Results:      pagMag.Results,
+	}
+	w.JSON(api.ShareList{
+		Pagination: pagination,
+		Results:    results,
+	})
+}
+
+func (shareCtl ShareController) CreateShare(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, body api.CreateShareJSONRequestBody, ownerName string, repositoryName string) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := shareCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := shareCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !shareCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.UpdateRepositoryAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	if body.RefType != api.RefTypeBranch && body.RefType != api.RefTypeTag {
+		w.BadRequest("share ref type (%s) only allow branch and tag", body.RefType)
+		return
+	}
+	if body.ExpiresIn <= 0 {
+		w.BadRequest("expires in must be positive")
+		return
+	}
+
+	// make sure ref exist
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, shareCtl.Repo, shareCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	err = workRepo.CheckOut(ctx, versionmgr.WorkRepoState(body.RefType), body.RefName)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	token, err := models.GenerateShareToken()
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	now := time.Now()
+	share, err := shareCtl.Repo.ShareRepo().Insert(ctx, &models.Share{
+		RepositoryID: repository.ID,
+		RefType:      string(body.RefType),
+		RefName:      body.RefName,
+		TokenHash:    models.HashShareToken(token),
+		Description:  body.Description,
+		CreatorID:    operator.ID,
+		ExpiredAt:    now.Add(time.Duration(body.ExpiresIn) * time.Second),
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	})
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	shareDto, err := shareToDto(share)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	// only hash of token is kept, this is the only chance to see it
+	shareDto.Token = &token
+	w.JSON(shareDto, http.StatusCreated)
+}
+
+func (shareCtl ShareController) DeleteShare(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, shareID openapi_types.UUID) {
+	owner, err := shareCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := shareCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !shareCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.UpdateRepositoryAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	err = shareCtl.Repo.Transaction(ctx, func(repo models.IRepo) error {
+		affectedRows, err := repo.ShareRepo().Delete(ctx, models.NewDeleteShareParams().SetID(shareID).SetRepositoryID(repository.ID))
+		if err != nil {
+			return err
+		}
+		if affectedRows == 0 {
+			return fmt.Errorf("share %s %w", shareID, models.ErrNotFound)
+		}
+		return nil
+	})
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.OK()
+}
+
+func (shareCtl ShareController) ListShareAccessLogs(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, shareID openapi_types.UUID, params api.ListShareAccessLogsParams) {
+	owner, err := shareCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := shareCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !shareCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.UpdateRepositoryAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	share, err := shareCtl.Repo.ShareRepo().Get(ctx, models.NewGetShareParams().SetID(shareID).SetRepositoryID(repository.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	listParams := models.NewListShareAccessLogParams().SetShareID(share.ID)
+	if params.After != nil {
+		listParams.SetAfter(time.UnixMilli(*params.After))
+	}
+	if params.AfterId != nil {
+		listParams.SetAfterID(*params.AfterId)
+	}
+	pageAmount := utils.IntValue(params.Amount)
+	if pageAmount > utils.DefaultMaxPerPage || pageAmount <= 0 {
+		listParams.SetAmount(utils.DefaultMaxPerPage)
+	} else {
+		listParams.SetAmount(pageAmount)
+	}
+
+	logs, hasMore, err := shareCtl.Repo.ShareRepo().ListAccessLog(ctx, listParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	results := utils.Silent(utils.ArrMap(logs, shareAccessLogToDto))
+	pagMag := utils.PaginationFor(hasMore, results, "CreatedAt")
+	pagination := api.Pagination{
+		HasMore:      pagMag.HasMore,
+		MaxPerPage:   pagMag.MaxPerPage,
+		NextOffset:   pagMag.NextOffset,
+		NextOffsetId: pagMag.NextOffsetID,
+		Results:      pagMag.Results,
+	}
+	w.JSON(api.ShareAccessLogList{
+		Pagination: pagination,
+		Results:    results,
+	})
+}
+
+func (shareCtl ShareController) GetSharedRef(ctx context.Context, w *api.JiaozifsResponse, r *http.Request, token string) {
+	share, repository, _, ok := shareCtl.openShare(ctx, w, r, token, models.ShareBrowseAction, "")
+	if !ok {
+		return
+	}
+
+	owner, err := shareCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetID(repository.OwnerID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.JSON(api.SharedRef{
+		Owner:       owner.Name,
+		Repository:  repository.Name,
+		RefType:     api.RefType(share.RefType),
+		RefName:     share.RefName,
+		Description: share.Description,
+		ExpiredAt:   share.ExpiredAt.UnixMilli(),
+	})
+}
+
+func (shareCtl ShareController) GetSharedEntries(ctx context.Context, w *api.JiaozifsResponse, r *http.Request, token string, params api.GetSharedEntriesParams) {
+	path := versionmgr.CleanPath(utils.StringValue(params.Path))
+	_, repository, workRepo, ok := shareCtl.openShare(ctx, w, r, token, models.ShareBrowseAction, path)
+	if !ok {
+		return
+	}
+
+	workTree, err := workRepo.RootTree(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	treeEntry, err := workTree.Ls(ctx, path)
+	if err != nil {
+		if errors.Is(err, versionmgr.ErrPathNotFound) {
+			w.NotFound()
+			return
+		}
+		w.Error(err)
+		return
+	}
+	apiTreeEntries := make([]api.FullTreeEntry, 0, len(treeEntry))
+	for _, entry := range treeEntry {
+		entryPath := entry.Name
+		if len(path) > 0 {
+			entryPath = path + "/" + entry.Name
+		}
+		if repository.IsPrivatePath(entryPath) {
+			continue
+		}
+		apiTreeEntries = append(apiTreeEntries, api.FullTreeEntry{
+			CreatedAt: entry.CreatedAt.UnixMilli(),
+			Hash:      entry.Hash.Hex(),
+			IsDir:     entry.IsDir,
+			Name:      entry.Name,
+			Size:      entry.Size,
+			UpdatedAt: entry.UpdatedAt.UnixMilli(),
+		})
+	}
+	w.JSON(apiTreeEntries)
+}
+
+func (shareCtl ShareController) GetSharedObject(ctx context.Context, w *api.JiaozifsResponse, r *http.Request, token string, params api.GetSharedObjectParams) {
+	_, repository, workRepo, ok := shareCtl.openShare(ctx, w, r, token, models.ShareDownloadAction, versionmgr.CleanPath(params.Path))
+	if !ok {
+		return
+	}
+
+	// anyone holding the link reads the share, masking rules always apply
+	err := serveObject(ctx, w, workRepo, params.Path, params.Range, maskMethodsFromModel(repository.MaskedColumns(params.Path)))
+	if err != nil {
+		shareLog.With("path", params.Path).Debugf("GetSharedObject copy content %v", err)
+	}
+}
+
+func (shareCtl ShareController) GetSharedArchive(ctx context.Context, w *api.JiaozifsResponse, r *http.Request, token string, params api.GetSharedArchiveParams) {
+	if params.ArchiveType == api.Car {
+		w.BadRequest("shared archive not support car files")
+		return
+	}
+	share, repository, workRepo, ok := shareCtl.openShare(ctx, w, r, token, models.ShareArchiveAction, "")
+	if !ok {
+		return
+	}
+	// files in archive can not be masked, nor private paths left out
+	if len(repository.MaskingRules) > 0 || len(repository.PrivatePrefixes) > 0 {
+		w.Forbidden()
+		return
+	}
+
+	// check tree before response header written
+	_, err := workRepo.RootTree(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, fmt.Sprintf("%s-%s.%s", repository.Name, share.RefName, params.ArchiveType)))
+	err = workRepo.WriteArchive(ctx, w, versionmgr.ArchiveType(params.ArchiveType), "", nil)
+	if err != nil {
+		shareLog.With("share", share.ID).Debugf("GetSharedArchive stream content %v", err)
+	}
+}
+
+// openShare check token and return the checked out work repository of shared ref, every successful open is recorded
+// in access log. expired share and private path are treated as not exist
+func (shareCtl ShareController) openShare(ctx context.Context, w *api.JiaozifsResponse, r *http.Request, token string, action models.ShareAction, path string) (*models.Share, *models.Repository, *versionmgr.WorkRepository, bool) {
+	share, err := shareCtl.Repo.ShareRepo().Get(ctx, models.NewGetShareParams().SetToken(token))
+	if err != nil {
+		if errors.Is(err, models.ErrNotFound) {
+			w.NotFound()
+			return nil, nil, nil, false
+		}
+		w.Error(err)
+		return nil, nil, nil, false
+	}
+	if share.IsExpired(time.Now()) {
+		w.NotFound()
+		return nil, nil, nil, false
+	}
+
+	repository, err := shareCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetID(share.RepositoryID))
+	if err != nil {
+		w.Error(err)
+		return nil, nil, nil, false
+	}
+
+	// receivers are not members, private paths are hidden from them like from other non-members
+	if len(path) > 0 && repository.IsPrivatePath(path) {
+		w.NotFound()
+		return nil, nil, nil, false
+	}
+
+	// read with the identity of share creator
+	creator, err := shareCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetID(share.CreatorID))
+	if err != nil {
+		w.Error(err)
+		return nil, nil, nil, false
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, creator, repository, shareCtl.Repo, shareCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return nil, nil, nil, false
+	}
+	err = workRepo.CheckOut(ctx, versionmgr.WorkRepoState(share.RefType), share.RefName)
+	if err != nil {
+		w.Error(err)
+		return nil, nil, nil, false
+	}
+
+	_, err = shareCtl.Repo.ShareRepo().InsertAccessLog(ctx, &models.ShareAccessLog{
+		ShareID:    share.ID,
+		Action:     action,
+		Path:       path,
+		RemoteAddr: r.RemoteAddr,
+		UserAgent:  r.UserAgent(),
+		CreatedAt:  time.Now(),
+	})
+	if err != nil {
+		w.Error(err)
+		return nil, nil, nil, false
+	}
+	return share, repository, workRepo, true
+}
+
+func shareToDto(in *models.Share) (api.Share, error) {
+	return api.Share{
+		Id:           in.ID,
+		RepositoryId: in.RepositoryID,
+		RefType:      api.RefType(in.RefType),
+		RefName:      in.RefName,
+		Description:  in.Description,
+		CreatorId:    in.CreatorID,
+		ExpiredAt:    in.ExpiredAt.UnixMilli(),
+		CreatedAt:    in.CreatedAt.UnixMilli(),
+		UpdatedAt:    in.UpdatedAt.UnixMilli(),
+	}, nil
+}
+
+func shareAccessLogToDto(in *models.ShareAccessLog) (api.ShareAccessLog, error) {
+	return api.ShareAccessLog{
+		Id:         in.ID,
+		ShareId:    in.ShareID,
+		Action:     api.ShareAccessLogAction(in.Action),
+		Path:       in.Path,
+		RemoteAddr: in.RemoteAddr,
+		UserAgent:  in.UserAgent,
+		CreatedAt:  in.CreatedAt.UnixMilli(),
+	}, nil
+}
@@ -195,11 +195,37 @@ func (tagCtl TagController) ListTags(ctx context.Context, w *api.JiaozifsRespons
 
 	listTagParams := models.NewListTagParams()
 	if params.Prefix != nil && len(*params.Prefix) > 0 {
-		listTagParams.SetName(*params.Prefix, models.PrefixMatch)
+		match, ok := matchModeFromDto(w, *params.Prefix, params.Match)
+		if !ok {
+			return
+		}
+		listTagParams.SetName(*params.Prefix, match)
 	}
+	if params.Type != nil {
+		switch *params.Type {
+		case api.Lightweight:
+			listTagParams.SetType(models.LightweightTag)
+		case api.Annotated:
+			listTagParams.SetType(models.AnnotatedTag)
+		default:
+			w.BadRequest("unsupported tag type %s", *params.Type)
+			return
+		}
+	}
+	sort, offsetField, ok := listSortFromDto(w, params.Sort, params.Direction)
+	if !ok {
+		return
+	}
+	listTagParams.SetSort(sort.Field, sort.Direction)
 	if params.After != nil {
 		listTagParams.SetAfter(time.UnixMilli(*params.After))
 	}
+	if params.AfterName != nil {
+		listTagParams.SetAfterName(*params.AfterName)
+	}
+	if params.AfterId != nil {
+		listTagParams.SetAfterID(*params.AfterId)
+	}
 
 	pageAmount := utils.IntValue(params.Amount)
 	if pageAmount > utils.DefaultMaxPerPage || pageAmount <= 0 {
@@ -214,12 +240,19 @@ func (tagCtl TagController) ListTags(ctx context.Context, w *api.JiaozifsRespons
 		return
 	}
 	results := utils.Silent(utils.ArrMap(tags, tagToDto))
-	pagMag := utils.PaginationFor(hasMore, results, "UpdatedAt")
+	pagMag := utils.PaginationFor(hasMore, results, offsetField)
+	total, err := tagCtl.Repo.TagRepo().Count(ctx, listTagParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
 	pagination := api.Pagination{
-		HasMore:    pagMag.HasMore,
-		MaxPerPage: pagMag.MaxPerPage,
-		NextOffset: pagMag.NextOffset,
-		Results:    pagMag.Results,
+		HasMore:      pagMag.HasMore,
+		MaxPerPage:   pagMag.MaxPerPage,
+		NextOffset:   pagMag.NextOffset,
+		NextOffsetId: pagMag.NextOffsetID,
+		Results:      pagMag.Results,
+		Total:        &total,
 	}
 	w.JSON(api.TagList{
 		Pagination: pagination,
@@ -235,6 +268,7 @@ func tagToDto(in *models.Tag) (api.Tag, error) {
 		RepositoryId: in.RepositoryID,
 		CreatorId:    in.CreatorID,
 		Target:       in.Target.Hex(),
+		Type:         api.TagType(in.Type.String()),
 		UpdatedAt:    in.UpdatedAt.UnixMilli(),
 	}, nil
 }
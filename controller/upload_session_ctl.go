@@ -0,0 +1,306 @@
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	"github.com/GitDataAI/jiaozifs/auth"
+	"github.com/GitDataAI/jiaozifs/auth/rbac"
+	"github.com/GitDataAI/jiaozifs/block/params"
+	"github.com/GitDataAI/jiaozifs/config"
+	"github.com/GitDataAI/jiaozifs/controller/validator"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/models/filemode"
+	"github.com/GitDataAI/jiaozifs/models/rbacmodel"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/versionmgr"
+	"github.com/go-openapi/swag"
+	"github.com/google/uuid"
+	openapi_types "github.com/oapi-codegen/runtime/types"
+	"go.uber.org/fx"
+)
+
+type UploadSessionController struct {
+	fx.In
+	BaseController
+
+	PublicStorageConfig params.AdapterConfig
+	Repo                models.IRepo
+	Config              *config.APIConfig
+}
+
+func (uploadCtl UploadSessionController) ListUploadSessions(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.ListUploadSessionsParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := uploadCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := uploadCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !uploadCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.ReadRepositoryAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	listParams := models.NewListUploadSessionParams().SetRepositoryID(repository.ID).SetCreatorID(operator.ID).SetAmount(pageAmount(params.Amount))
+	if params.Prefix != nil && len(*params.Prefix) > 0 {
+		match, ok := matchModeFromDto(w, *params.Prefix, params.Match)
+		if !ok {
+			return
+		}
+		listParams.SetPath(*params.Prefix, match)
+	}
+	if params.After != nil {
+		listParams.SetAfter(time.UnixMilli(*params.After))
+	}
+	sessions, hasMore, err := uploadCtl.Repo.UploadSessionRepo().List(ctx, listParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	results := utils.Silent(utils.ArrMap(sessions, uploadCtl.uploadSessionToDto))
+	pagMag := utils.PaginationFor(hasMore, results, "CreatedAt")
+	pagination := api.Pagination{
+		HasMore:    pagMag.HasMore,
+		MaxPerPage: pagMag.MaxPerPage,
+		NextOffset: pagMag.NextOffset,
+		Results:    pagMag.Results,
+	}
+	w.JSON(api.UploadSessionList{
+		Pagination: pagination,
+		Results:    results,
+	})
+}
+
+func (uploadCtl UploadSessionController) CreateUploadSession(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.CreateUploadSessionParams) {
+	err := validator.ValidateObjectPath(params.Path)
+	if err != nil {
+		w.BadRequest("%s %s", params.Path, err.Error())
+		return
+	}
+
+	workRepo, repository, ok := uploadCtl.uploadWorkRepo(ctx, w, ownerName, repositoryName)
+	if !ok {
+		return
+	}
+
+	if !uploadCtl.throttleWrite(w, repository) {
+		return
+	}
+
+	_, err = uploadCtl.Repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(repository.ID).SetName(params.RefName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if uploadCtl.Config.MaxUploadSessions > 0 {
+		count, err := uploadCtl.Repo.UploadSessionRepo().CountByCreator(ctx, workRepo.Operator().ID)
+		if err != nil {
+			w.Error(err)
+			return
+		}
+		if count >= uploadCtl.Config.MaxUploadSessions {
+			w.Error(fmt.Errorf("upload sessions in progress reach limit %d %w", uploadCtl.Config.MaxUploadSessions, api.ErrCode(http.StatusTooManyRequests)))
+			return
+		}
+	}
+
+	session, err := workRepo.CreateUploadSession(ctx, params.RefName, versionmgr.CleanPath(params.Path))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.JSON(utils.Silent(uploadCtl.uploadSessionToDto(session)), http.StatusCreated)
+}
+
+func (uploadCtl UploadSessionController) UploadPart(ctx context.Context, w *api.JiaozifsResponse, r *http.Request, ownerName string, repositoryName string, sessionID openapi_types.UUID, params api.UploadPartParams) {
+	workRepo, repository, ok := uploadCtl.uploadWorkRepo(ctx, w, ownerName, repositoryName)
+	if !ok {
+		return
+	}
+
+	session, ok := uploadCtl.getSession(ctx, w, workRepo, repository, sessionID)
+	if !ok {
+		return
+	}
+
+	release, ok := uploadCtl.throttleUpload(w, repository)
+	if !ok {
+		return
+	}
+	defer release()
+
+	part, err := workRepo.UploadPart(ctx, session, params.PartNumber, r.Body, r.ContentLength)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.JSON(uploadPartToDto(*part))
+}
+
+func (uploadCtl UploadSessionController) CompleteUploadSession(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, body api.CompleteUploadSessionJSONRequestBody, ownerName string, repositoryName string, sessionID openapi_types.UUID, params api.CompleteUploadSessionParams) {
+	workRepo, repository, ok := uploadCtl.uploadWorkRepo(ctx, w, ownerName, repositoryName)
+	if !ok {
+		return
+	}
+
+	session, ok := uploadCtl.getSession(ctx, w, workRepo, repository, sessionID)
+	if !ok {
+		return
+	}
+
+	if !uploadCtl.throttleWrite(w, repository) {
+		return
+	}
+
+	err := workRepo.CheckOut(ctx, versionmgr.InWip, session.RefName)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	var partNumbers []int
+	if body.PartNumbers != nil {
+		partNumbers = *body.PartNumbers
+	}
+	blob, err := workRepo.CompleteUploadSession(ctx, session, partNumbers, models.DefaultLeafProperty())
+	if errors.Is(err, versionmgr.ErrUploadSessionNoPart) || errors.Is(err, versionmgr.ErrUploadSessionPartMissing) {
+		w.BadRequest(err.Error())
+		return
+	}
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	err = putBlobInWip(ctx, workRepo, session.Path, blob, utils.BoolValue(params.IsReplace))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	w.JSON(api.ObjectStats{
+		Checksum:  blob.CheckSum.Hex(),
+		Mtime:     time.Now().Unix(),
+		Path:      session.Path,
+		PathMode:  utils.Uint32(uint32(filemode.Regular)),
+		SizeBytes: swag.Int64(blob.Size),
+		Metadata:  &api.ObjectUserMetadata{},
+	}, http.StatusCreated)
+}
+
+func (uploadCtl UploadSessionController) AbortUploadSession(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, sessionID openapi_types.UUID) {
+	workRepo, repository, ok := uploadCtl.uploadWorkRepo(ctx, w, ownerName, repositoryName)
+	if !ok {
+		return
+	}
+
+	session, ok := uploadCtl.getSession(ctx, w, workRepo, repository, sessionID)
+	if !ok {
+		return
+	}
+
+	err := workRepo.AbortUploadSession(ctx, session)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.Code(http.StatusNoContent)
+}
+
+// uploadWorkRepo authorize operator to write object of repository, return work repository to upload with
+func (uploadCtl UploadSessionController) uploadWorkRepo(ctx context.Context, w *api.JiaozifsResponse, ownerName string, repositoryName string) (*versionmgr.WorkRepository, *models.Repository, bool) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return nil, nil, false
+	}
+
+	owner, err := uploadCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return nil, nil, false
+	}
+
+	repository, err := uploadCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetOwnerID(owner.ID).SetName(repositoryName))
+	if err != nil {
+		w.Error(err)
+		return nil, nil, false
+	}
+
+	if !uploadCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.WriteObjectAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return nil, nil, false
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, uploadCtl.Repo, uploadCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return nil, nil, false
+	}
+	return workRepo, repository, true
+}
+
+// getSession get upload session of operator in repository, session of other users is not visible
+func (uploadCtl UploadSessionController) getSession(ctx context.Context, w *api.JiaozifsResponse, workRepo *versionmgr.WorkRepository, repository *models.Repository, sessionID uuid.UUID) (*models.UploadSession, bool) {
+	session, err := uploadCtl.Repo.UploadSessionRepo().Get(ctx, models.NewGetUploadSessionParams().
+		SetID(sessionID).
+		SetRepositoryID(repository.ID).
+		SetCreatorID(workRepo.Operator().ID))
+	if err != nil {
+		w.Error(err)
+		return nil, false
+	}
+	return session, true
+}
+
+func (uploadCtl UploadSessionController) uploadSessionToDto(session *models.UploadSession) (api.UploadSession, error) {
+	ttl := uploadCtl.Config.UploadSessionTTL
+	if ttl <= 0 {
+		ttl = versionmgr.DefaultUploadSessionTTL
+	}
+	return api.UploadSession{
+		Id:           session.ID,
+		RepositoryId: session.RepositoryID,
+		CreatorId:    session.CreatorID,
+		RefName:      session.RefName,
+		Path:         session.Path,
+		Parts:        utils.Silent(utils.ArrMap(session.Parts, func(part models.UploadPart) (api.UploadPart, error) { return uploadPartToDto(part), nil })),
+		LastActiveAt: session.LastActiveAt.UnixMilli(),
+		ExpiresAt:    session.LastActiveAt.Add(ttl).UnixMilli(),
+		CreatedAt:    session.CreatedAt.UnixMilli(),
+	}, nil
+}
+
+func uploadPartToDto(part models.UploadPart) api.UploadPart {
+	return api.UploadPart{
+		PartNumber: part.PartNumber,
+		Etag:       part.ETag,
+		Size:       part.Size,
+	}
+}
@@ -106,7 +106,7 @@ func (userCtl UserController) Register(ctx context.Context, w *api.JiaozifsRespo
 	}
 
 	if count1+count2 > 0 {
-		w.BadRequest(fmt.Sprintf("username %s or email %s not found ", body.Name, body.Email))
+		w.BadRequest("username %s or email %s not found ", body.Name, body.Email)
 	}
 
 	// reserve temporarily
@@ -15,6 +15,9 @@ var (
 	ReValidUser = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]{1,28}[a-zA-Z0-9]$`)
 	ReValidPath = regexp.MustCompile(`^[^\x00/:*?"<>|]*/?([^/\s\x00:*?"<>|]+/)*[^/\s\x00:*?"<>|]+(?:\.[a-zA-Z0-9]+)?$`)
 
+	// ReValidRefPrefix prefix of generated refs
+	ReValidRefPrefix = regexp.MustCompile(`^\w{1,20}$`)
+
 	// RepoNameBlackList forbid repo name, reserve for routes
 	RepoNameBlackList = []string{"repository", "repositories", "wip", "wips", "object", "objects", "tags", "tag", "commit", "commits", "ref", "refs", "repo", "repos", "user", "users"}
 )
@@ -28,6 +31,7 @@ var (
 	ErrInvalidTagName    = errors.New("tag name must start with a number or letter, can only contain numbers, letters, dot, or hyphens, and must be between 3 and 63 characters in length")
 	ErrInvalidUsername   = errors.New("invalid username: it must start and end with a letter or digit, can contain letters, digits, hyphens, and cannot start or end with a hyphen; the length must be between 3 and 30 characters")
 	ErrInvalidObjectPath = errors.New("invalid object path: it must not contain null characters or NTFS forbidden characters")
+	ErrInvalidRefPrefix  = errors.New("invalid ref prefix: only numbers, letters or underscores")
 )
 
 func ValidateBranchName(name string) error {
@@ -57,6 +61,15 @@ func ValidateBranchName(name string) error {
 	return nil
 }
 
+// ValidateRefPrefix check prefix put before names of generated branches and tags, prefix is a single segment of at most
+// 20 word characters so generated names are valid for both branches and tags
+func ValidateRefPrefix(prefix string) error {
+	if !ReValidRefPrefix.MatchString(prefix) {
+		return ErrInvalidRefPrefix
+	}
+	return nil
+}
+
 func ValidateRepoName(name string) error {
 	for _, blackName := range RepoNameBlackList {
 		if name == blackName {
@@ -94,6 +94,24 @@ func TestValidateTagName(t *testing.T) {
 	}
 }
 
+func TestValidateRefPrefix(t *testing.T) {
+	validPrefixes := []string{"backup", "backup_1700000000", "b"}
+	for _, prefix := range validPrefixes {
+		err := ValidateRefPrefix(prefix)
+		if err != nil {
+			t.Errorf("Expected no error for ref prefix '%s', but got: %s", prefix, err)
+		}
+	}
+
+	invalidPrefixes := []string{"", "backup/main", "back-up", "backup_of_repository_2024"}
+	for _, prefix := range invalidPrefixes {
+		err := ValidateRefPrefix(prefix)
+		if err == nil {
+			t.Errorf("expect error for ref prefix '%s'", prefix)
+		}
+	}
+}
+
 func TestValidateUsername(t *testing.T) {
 	//Validate Username
 	validUsernames := []string{"user123", "username", "user_name", "user-123"}
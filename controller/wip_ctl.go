@@ -3,18 +3,22 @@ package controller
 import (
 	"bytes"
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/GitDataAI/jiaozifs/api"
 	"github.com/GitDataAI/jiaozifs/auth"
 	"github.com/GitDataAI/jiaozifs/auth/rbac"
 	"github.com/GitDataAI/jiaozifs/block/params"
+	"github.com/GitDataAI/jiaozifs/config"
 	"github.com/GitDataAI/jiaozifs/models"
 	"github.com/GitDataAI/jiaozifs/models/rbacmodel"
 	"github.com/GitDataAI/jiaozifs/utils"
 	"github.com/GitDataAI/jiaozifs/utils/hash"
 	"github.com/GitDataAI/jiaozifs/versionmgr"
+	"github.com/google/uuid"
 	"go.uber.org/fx"
 )
 
@@ -24,6 +28,8 @@ type WipController struct {
 
 	Repo                models.IRepo
 	PublicStorageConfig params.AdapterConfig
+	Config              *config.APIConfig
+	CommitQueue         *versionmgr.CommitQueue
 }
 
 // GetWip get wip of specific repository, operator only get himself wip
@@ -91,7 +97,7 @@ func (wipCtl WipController) GetWip(ctx context.Context, w *api.JiaozifsResponse,
 }
 
 // ListWip return wips of branches, operator only see himself wips in specific repository
-func (wipCtl WipController) ListWip(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string) {
+func (wipCtl WipController) ListWip(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.ListWipParams) {
 	operator, err := auth.GetOperator(ctx)
 	if err != nil {
 		w.Error(err)
@@ -119,21 +125,186 @@ func (wipCtl WipController) ListWip(ctx context.Context, w *api.JiaozifsResponse
 		return
 	}
 
-	wips, err := wipCtl.Repo.WipRepo().List(ctx, models.NewListWipParams().SetCreatorID(operator.ID).SetRepositoryID(repository.ID))
+	listParams := models.NewListWipParams().SetCreatorID(operator.ID).SetRepositoryID(repository.ID).SetAmount(pageAmount(params.Amount))
+	if params.RefName != nil {
+		ref, err := wipCtl.Repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetName(*params.RefName).SetRepositoryID(repository.ID))
+		if err != nil {
+			w.Error(err)
+			return
+		}
+		listParams.SetRefID(ref.ID)
+	}
+	if params.After != nil {
+		listParams.SetAfter(time.UnixMilli(*params.After))
+	}
+	wips, hasMore, err := wipCtl.Repo.WipRepo().List(ctx, listParams)
 	if err != nil {
 		w.Error(err)
 		return
 	}
 
-	apiWips := make([]*api.Wip, len(wips))
+	results := make([]api.Wip, len(wips))
 	for index, wip := range wips {
-		apiWips[index] = wipToDto(wip)
+		results[index] = *wipToDto(wip)
+	}
+	pagMag := utils.PaginationFor(hasMore, results, "UpdatedAt")
+	pagination := api.Pagination{
+		HasMore:    pagMag.HasMore,
+		MaxPerPage: pagMag.MaxPerPage,
+		NextOffset: pagMag.NextOffset,
+		Results:    pagMag.Results,
+	}
+	w.JSON(api.WipList{
+		Pagination: pagination,
+		Results:    results,
+	})
+}
+
+// ListWipsOfAuthenticatedUser list wips of operator in all repositories, so stale ones can be found and cleaned up
+func (wipCtl WipController) ListWipsOfAuthenticatedUser(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, params api.ListWipsOfAuthenticatedUserParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	listParams := models.NewListWipParams().SetCreatorID(operator.ID).SetAmount(pageAmount(params.Amount))
+	if params.After != nil {
+		listParams.SetAfter(time.UnixMilli(*params.After))
 	}
-	w.JSON(apiWips)
+	wips, hasMore, err := wipCtl.Repo.WipRepo().List(ctx, listParams)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	results := make([]api.Wip, len(wips))
+	for index, wip := range wips {
+		results[index] = *wipToDto(wip)
+	}
+	pagMag := utils.PaginationFor(hasMore, results, "UpdatedAt")
+	pagination := api.Pagination{
+		HasMore:    pagMag.HasMore,
+		MaxPerPage: pagMag.MaxPerPage,
+		NextOffset: pagMag.NextOffset,
+		Results:    pagMag.Results,
+	}
+	w.JSON(api.WipList{
+		Pagination: pagination,
+		Results:    results,
+	})
 }
 
 // CommitWip commit wip to branch, operator only could operator himself wip
 func (wipCtl WipController) CommitWip(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName, repositoryName string, params api.CommitWipParams) {
+	operator, repository, ok := wipCtl.commitRepository(ctx, w, ownerName, repositoryName)
+	if !ok {
+		return
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, wipCtl.Repo, wipCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	err = workRepo.CheckOut(ctx, versionmgr.InWip, params.RefName)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if params.XJobId != nil {
+		workRepo.SetCommitMessageVars(map[string]string{"job_id": *params.XJobId})
+	}
+
+	metadata, ok := metadataFromDto(w, params.Metadata)
+	if !ok {
+		return
+	}
+	workRepo.SetCommitMetadata(metadata)
+	workRepo.SetCommitLimits(commitLimits(wipCtl.Config))
+	workRepo.SetQuotaWarningThresholds(quotaWarningThresholds(wipCtl.Config))
+
+	if utils.BoolValue(params.Amend) {
+		_, err = workRepo.AmendCommit(ctx, params.Msg)
+	} else {
+		_, err = workRepo.CommitChanges(ctx, params.Msg)
+	}
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	w.JSON(wipToDto(workRepo.CurWip()), http.StatusCreated)
+}
+
+// CommitWipAsync validate wip and accept its commit as a ticket made by commit queue in background
+func (wipCtl WipController) CommitWipAsync(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName, repositoryName string, params api.CommitWipAsyncParams) {
+	operator, repository, ok := wipCtl.commitRepository(ctx, w, ownerName, repositoryName)
+	if !ok {
+		return
+	}
+
+	err := repository.CheckWritable()
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, wipCtl.Repo, wipCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	err = workRepo.CheckOut(ctx, versionmgr.InWip, params.RefName)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	if !bytes.Equal(workRepo.CurBranch().CommitHash, workRepo.CurWip().BaseCommit) {
+		w.BadRequest("base commit not equal with branch, please update wip")
+		return
+	}
+	amend := utils.BoolValue(params.Amend)
+	if amend && workRepo.CurBranch().CommitHash.IsEmpty() {
+		w.BadRequest("no commit to amend in branch %s", params.RefName)
+		return
+	}
+
+	metadata, ok := metadataFromDto(w, params.Metadata)
+	if !ok {
+		return
+	}
+
+	unfinished, err := wipCtl.Repo.CommitTicketRepo().Get(ctx, models.NewGetCommitTicketParams().SetRepositoryID(repository.ID).SetCreatorID(operator.ID).SetRefName(params.RefName).SetUnfinished())
+	if err == nil {
+		w.Error(fmt.Errorf("async commit %s of branch %s is not finished %w", unfinished.ID, params.RefName, models.ErrAlreadyExists))
+		return
+	}
+	if !errors.Is(err, models.ErrNotFound) {
+		w.Error(err)
+		return
+	}
+
+	ticket := models.NewCommitTicket(repository.ID, operator.ID, params.RefName, params.Msg)
+	ticket.Metadata = metadata
+	ticket.Amend = amend
+	if params.XJobId != nil {
+		ticket.MessageVars = map[string]string{"job_id": *params.XJobId}
+	}
+	ticket, err = wipCtl.Repo.CommitTicketRepo().Insert(ctx, ticket)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	wipCtl.CommitQueue.Trigger()
+	w.JSON(commitTicketToDto(ticket), http.StatusAccepted)
+}
+
+// GetCommitTicket get status of async commit, operator only see his own tickets
+func (wipCtl WipController) GetCommitTicket(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, ticketID uuid.UUID) {
 	operator, err := auth.GetOperator(ctx)
 	if err != nil {
 		w.Error(err)
@@ -152,6 +323,43 @@ func (wipCtl WipController) CommitWip(ctx context.Context, w *api.JiaozifsRespon
 		return
 	}
 
+	if !wipCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.ReadWipAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	ticket, err := wipCtl.Repo.CommitTicketRepo().Get(ctx, models.NewGetCommitTicketParams().SetID(ticketID).SetRepositoryID(repository.ID).SetCreatorID(operator.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.JSON(commitTicketToDto(ticket))
+}
+
+// commitRepository find repository operator is authorized to commit wip to, write of repository is throttled
+func (wipCtl WipController) commitRepository(ctx context.Context, w *api.JiaozifsResponse, ownerName, repositoryName string) (*models.User, *models.Repository, bool) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return nil, nil, false
+	}
+
+	owner, err := wipCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return nil, nil, false
+	}
+
+	repository, err := wipCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return nil, nil, false
+	}
+
 	if !wipCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
 		Type: rbac.NodeTypeAnd,
 		Nodes: []rbac.Node{
@@ -175,28 +383,35 @@ func (wipCtl WipController) CommitWip(ctx context.Context, w *api.JiaozifsRespon
 			},
 		},
 	}) {
-		return
+		return nil, nil, false
 	}
 
-	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, wipCtl.Repo, wipCtl.PublicStorageConfig)
-	if err != nil {
-		w.Error(err)
-		return
-	}
-
-	err = workRepo.CheckOut(ctx, versionmgr.InWip, params.RefName)
-	if err != nil {
-		w.Error(err)
-		return
+	if !wipCtl.throttleWrite(w, repository) {
+		return nil, nil, false
 	}
+	return operator, repository, true
+}
 
-	_, err = workRepo.CommitChanges(ctx, params.Msg)
-	if err != nil {
-		w.Error(err)
-		return
+func commitTicketToDto(ticket *models.CommitTicket) *api.CommitTicket {
+	var commit *string
+	if !ticket.CommitHash.IsEmpty() {
+		commit = utils.String(ticket.CommitHash.Hex())
+	}
+	var finishedAt *int64
+	if !ticket.FinishedAt.IsZero() {
+		finishedAt = utils.Int64(ticket.FinishedAt.UnixMilli())
+	}
+	return &api.CommitTicket{
+		Id:           ticket.ID,
+		RepositoryId: ticket.RepositoryID,
+		RefName:      ticket.RefName,
+		State:        api.CommitTicketState(ticket.State),
+		Commit:       commit,
+		Error:        utils.String(ticket.Error),
+		CreatedAt:    ticket.CreatedAt.UnixMilli(),
+		UpdatedAt:    ticket.UpdatedAt.UnixMilli(),
+		FinishedAt:   finishedAt,
 	}
-
-	w.JSON(wipToDto(workRepo.CurWip()), http.StatusCreated)
 }
 
 func (wipCtl WipController) UpdateWip(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, body api.UpdateWipJSONRequestBody, ownerName string, repositoryName string, params api.UpdateWipParams) {
@@ -240,6 +455,9 @@ func (wipCtl WipController) UpdateWip(ctx context.Context, w *api.JiaozifsRespon
 	}
 
 	updateParams := models.NewUpdateWipParams(wip.ID)
+	if body.Version != nil {
+		updateParams.SetVersion(*body.Version)
+	}
 	if body.BaseCommit != nil {
 		baseCommitHash, err := hash.FromHex(utils.StringValue(body.BaseCommit))
 		if err != nil {
@@ -247,6 +465,12 @@ func (wipCtl WipController) UpdateWip(ctx context.Context, w *api.JiaozifsRespon
 			return
 		}
 
+		baseCommitHash, err = versionmgr.ResolveHash(ctx, wipCtl.Repo, repository, baseCommitHash)
+		if err != nil {
+			w.Error(err)
+			return
+		}
+
 		if !baseCommitHash.IsEmpty() {
 			_, err = wipCtl.Repo.CommitRepo(repository.ID).Commit(ctx, baseCommitHash)
 			if err != nil {
@@ -263,6 +487,12 @@ func (wipCtl WipController) UpdateWip(ctx context.Context, w *api.JiaozifsRespon
 			return
 		}
 
+		currentTreeHash, err = versionmgr.ResolveHash(ctx, wipCtl.Repo, repository, currentTreeHash)
+		if err != nil {
+			w.Error(err)
+			return
+		}
+
 		if !currentTreeHash.IsEmpty() {
 			_, err = wipCtl.Repo.FileTreeRepo(repository.ID).TreeNode(ctx, currentTreeHash)
 			if err != nil {
@@ -415,7 +645,7 @@ func (wipCtl WipController) GetWipChanges(ctx context.Context, w *api.JiaozifsRe
 		return
 	}
 
-	changesResp, err := changesToDTO(changes)
+	changesResp, err := changesToDTO(changes, visibleAll)
 	if err != nil {
 		w.Error(err)
 		return
@@ -469,8 +699,221 @@ func (wipCtl WipController) RevertWipChanges(ctx context.Context, w *api.Jiaozif
 	w.OK()
 }
 
+// StageWipChanges stage changes of paths, only staged changes will be committed once stage was used, empty path stage all
+func (wipCtl WipController) StageWipChanges(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.StageWipChangesParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := wipCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := wipCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !wipCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.WriteWipAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, wipCtl.Repo, wipCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	err = workRepo.CheckOut(ctx, versionmgr.InWip, params.RefName)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	var paths []string
+	if params.Path != nil {
+		paths = *params.Path
+	}
+	err = workRepo.Stage(ctx, paths...)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	w.OK()
+}
+
+// UnstageWipChanges remove changes of paths from stage, changes are still kept in wip, empty path unstage all
+func (wipCtl WipController) UnstageWipChanges(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.UnstageWipChangesParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := wipCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := wipCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !wipCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.WriteWipAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, wipCtl.Repo, wipCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	err = workRepo.CheckOut(ctx, versionmgr.InWip, params.RefName)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	var paths []string
+	if params.Path != nil {
+		paths = *params.Path
+	}
+	err = workRepo.Unstage(ctx, paths...)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	w.OK()
+}
+
+// GetStagedChanges return changes staged in wip, operator only see himself wip
+func (wipCtl WipController) GetStagedChanges(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.GetStagedChangesParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := wipCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := wipCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !wipCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.ReadWipAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, wipCtl.Repo, wipCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	err = workRepo.CheckOut(ctx, versionmgr.InWip, params.RefName)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	changes, err := workRepo.GetStagedChanges(ctx, utils.StringValue(params.Path))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	changesResp, err := changesToDTO(changes, visibleAll)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+	w.JSON(changesResp)
+}
+
+// ResetWip drop staged and unstaged changes in wip, reset wip to head of branch
+func (wipCtl WipController) ResetWip(ctx context.Context, w *api.JiaozifsResponse, _ *http.Request, ownerName string, repositoryName string, params api.ResetWipParams) {
+	operator, err := auth.GetOperator(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	owner, err := wipCtl.Repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(ownerName))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	repository, err := wipCtl.Repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetName(repositoryName).SetOwnerID(owner.ID))
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	if !wipCtl.authorizeMember(ctx, w, repository.ID, rbac.Node{
+		Permission: rbac.Permission{
+			Action:   rbacmodel.WriteWipAction,
+			Resource: rbacmodel.RepoURArn(owner.ID.String(), repository.ID.String()),
+		},
+	}) {
+		return
+	}
+
+	workRepo, err := versionmgr.NewWorkRepositoryFromConfig(ctx, operator, repository, wipCtl.Repo, wipCtl.PublicStorageConfig)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	err = workRepo.CheckOut(ctx, versionmgr.InWip, params.RefName)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	err = workRepo.ResetWip(ctx)
+	if err != nil {
+		w.Error(err)
+		return
+	}
+
+	w.OK()
+}
+
 func wipToDto(wip *models.WorkingInProcess) *api.Wip {
-	return &api.Wip{
+	dto := &api.Wip{
 		BaseCommit:   wip.BaseCommit.Hex(),
 		CreatedAt:    wip.CreatedAt.UnixMilli(),
 		CreatorId:    wip.CreatorID,
@@ -480,5 +923,10 @@ func wipToDto(wip *models.WorkingInProcess) *api.Wip {
 		RepositoryId: wip.RepositoryID,
 		State:        int(wip.State),
 		UpdatedAt:    wip.UpdatedAt.UnixMilli(),
+		Version:      wip.Version,
+	}
+	if wip.StagedTree != nil {
+		dto.StagedTree = utils.String(wip.StagedTree.Hex())
 	}
+	return dto
 }
@@ -0,0 +1,99 @@
+package integrationtest
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	apiimpl "github.com/GitDataAI/jiaozifs/api/api_impl"
+	"github.com/google/uuid"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func AsyncCommitSpec(ctx context.Context, urlStr string) func(c convey.C) {
+	client, _ := api.NewClient(urlStr + apiimpl.APIV1Prefix)
+	return func(c convey.C) {
+		userName := "ada"
+		repoName := "async_commit_test"
+		branchName := "main"
+		var ticket *api.CommitTicket
+
+		c.Convey("init", func(_ convey.C) {
+			_ = createUser(ctx, client, userName)
+			loginAndSwitch(ctx, client, userName, false)
+			_ = createRepo(ctx, client, repoName, false)
+			_ = createWip(ctx, client, userName, repoName, branchName)
+			_ = uploadObject(ctx, client, userName, repoName, branchName, "data/a.csv", true)
+		})
+
+		c.Convey("commit wip async", func(c convey.C) {
+			c.Convey("no auth", func() {
+				re := client.RequestEditors
+				client.RequestEditors = nil
+				resp, err := client.CommitWipAsync(ctx, userName, repoName, &api.CommitWipAsyncParams{
+					RefName: branchName,
+					Msg:     "async",
+				})
+				client.RequestEditors = re
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusUnauthorized)
+			})
+
+			c.Convey("fail to commit non exit wip", func() {
+				resp, err := client.CommitWipAsync(ctx, userName, repoName, &api.CommitWipAsyncParams{
+					RefName: "feat/no_wip",
+					Msg:     "async",
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
+			})
+
+			c.Convey("success to accept commit", func() {
+				resp, err := client.CommitWipAsync(ctx, userName, repoName, &api.CommitWipAsyncParams{
+					RefName:  branchName,
+					Msg:      "async",
+					Metadata: &[]string{"run_id=42"},
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusAccepted)
+
+				result, err := api.ParseCommitWipAsyncResponse(resp)
+				convey.So(err, convey.ShouldBeNil)
+				ticket = result.JSON202
+				convey.So(ticket.RefName, convey.ShouldEqual, branchName)
+			})
+		})
+
+		c.Convey("get commit ticket", func(c convey.C) {
+			c.Convey("fail to get non exit ticket", func() {
+				resp, err := client.GetCommitTicket(ctx, userName, repoName, uuid.New())
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
+			})
+
+			c.Convey("ticket is done by worker", func() {
+				var result *api.GetCommitTicketResponse
+				for i := 0; i < 50; i++ {
+					resp, err := client.GetCommitTicket(ctx, userName, repoName, ticket.Id)
+					convey.So(err, convey.ShouldBeNil)
+					convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+					result, err = api.ParseGetCommitTicketResponse(resp)
+					convey.So(err, convey.ShouldBeNil)
+					if result.JSON200.State == api.CommitTicketStateDone || result.JSON200.State == api.CommitTicketStateFailed {
+						break
+					}
+					time.Sleep(100 * time.Millisecond)
+				}
+				convey.So(result.JSON200.State, convey.ShouldEqual, api.CommitTicketStateDone)
+
+				resp, err := client.GetBranch(ctx, userName, repoName, &api.GetBranchParams{RefName: branchName})
+				convey.So(err, convey.ShouldBeNil)
+				branch, err := api.ParseGetBranchResponse(resp)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(*result.JSON200.Commit, convey.ShouldEqual, branch.JSON200.CommitHash)
+			})
+		})
+	}
+}
@@ -277,5 +277,49 @@ func BranchSpec(ctx context.Context, urlStr string) func(c convey.C) {
 				convey.So(getResp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
 			})
 		})
+
+		c.Convey("manage branches by collection", func(c convey.C) {
+			c.Convey("fail to add branch from non exit source", func() {
+				resp, err := client.AddBranch(ctx, userName, repoName, api.AddBranchJSONRequestBody{
+					Name:   "feat/fourth_branch",
+					Source: "feat/not_exit",
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
+			})
+
+			c.Convey("add branch successful", func() {
+				resp, err := client.AddBranch(ctx, userName, repoName, api.AddBranchJSONRequestBody{
+					Name:   "feat/fourth_branch",
+					Source: "main",
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusCreated)
+
+				listResp, err := client.ListBranches(ctx, userName, repoName, &api.ListBranchesParams{Prefix: utils.String("feat/fourth")})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(listResp.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+				listBranchesResp, err := api.ParseListBranchesResponse(listResp)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(listBranchesResp.JSON200.Results, convey.ShouldHaveLength, 1)
+			})
+
+			c.Convey("fail to remove HEAD branch", func() {
+				resp, err := client.RemoveBranch(ctx, userName, repoName, &api.RemoveBranchParams{RefName: "main"})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusBadRequest)
+			})
+
+			c.Convey("remove branch successful", func() {
+				resp, err := client.RemoveBranch(ctx, userName, repoName, &api.RemoveBranchParams{RefName: "feat/fourth_branch"})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+				getResp, err := client.GetBranch(ctx, userName, repoName, &api.GetBranchParams{RefName: "feat/fourth_branch"})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(getResp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
+			})
+		})
 	}
 }
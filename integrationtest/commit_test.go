@@ -301,7 +301,7 @@ func GetEntriesInRefSpec(ctx context.Context, urlStr string) func(c convey.C) {
 				getCommitsResult, err := api.ParseGetCommitsInRefResponse(getCommitsResp)
 				convey.So(err, convey.ShouldBeNil)
 
-				commit := (*getCommitsResult.JSON200)[0]
+				commit := getCommitsResult.JSON200.Results[0]
 				resp, err := client.GetEntriesInRef(ctx, userName, repoName, &api.GetEntriesInRefParams{
 					Path: utils.String("/"),
 					Ref:  utils.String(commit.Hash),
@@ -438,7 +438,7 @@ func GetCommitChangesSpec(ctx context.Context, urlStr string) func(c convey.C) {
 				result, err := api.ParseGetCommitsInRefResponse(resp)
 				convey.So(err, convey.ShouldBeNil)
 
-				commits = *result.JSON200
+				commits = result.JSON200.Results
 			})
 
 			c.Convey("no auth", func() {
@@ -496,13 +496,13 @@ func GetCommitChangesSpec(ctx context.Context, urlStr string) func(c convey.C) {
 				convey.So(*result.JSON200, convey.ShouldHaveLength, 3)
 
 				convey.So((*result.JSON200)[0].Path, convey.ShouldEqual, "g/m.dat")
-				convey.So((*result.JSON200)[0].Action, convey.ShouldEqual, api.N1)
+				convey.So((*result.JSON200)[0].Action, convey.ShouldEqual, api.ChangeActionInsert)
 
 				convey.So((*result.JSON200)[1].Path, convey.ShouldEqual, "g/x.dat")
-				convey.So((*result.JSON200)[1].Action, convey.ShouldEqual, api.N2)
+				convey.So((*result.JSON200)[1].Action, convey.ShouldEqual, api.ChangeActionDelete)
 
 				convey.So((*result.JSON200)[2].Path, convey.ShouldEqual, "m.dat")
-				convey.So((*result.JSON200)[2].Action, convey.ShouldEqual, api.N3)
+				convey.So((*result.JSON200)[2].Action, convey.ShouldEqual, api.ChangeActionModify)
 			})
 
 			c.Convey("success to get first commit changes", func() {
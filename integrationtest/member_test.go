@@ -268,38 +268,38 @@ func MemberSpec(ctx context.Context, urlStr string) func(c convey.C) {
 			c.Convey("no auth", func() {
 				re := client.RequestEditors
 				client.RequestEditors = nil
-				resp, err := client.ListMembers(ctx, user2.Name, repo2.Name)
+				resp, err := client.ListMembers(ctx, user2.Name, repo2.Name, &api.ListMembersParams{})
 				client.RequestEditors = re
 				convey.ShouldBeNil(c, err)
 				convey.ShouldBeNil(c, resp)
 			})
 
 			c.Convey("not exit owner", func() {
-				resp, err := client.ListMembers(ctx, "fake_owner", repo2.Name)
+				resp, err := client.ListMembers(ctx, "fake_owner", repo2.Name, &api.ListMembersParams{})
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
 			})
 
 			c.Convey("not exit repo", func() {
-				resp, err := client.ListMembers(ctx, user2.Name, "mock_repo")
+				resp, err := client.ListMembers(ctx, user2.Name, "mock_repo", &api.ListMembersParams{})
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
 			})
 
 			c.Convey("list for other repo", func() {
-				resp, err := client.ListMembers(ctx, user1.Name, repo1.Name)
+				resp, err := client.ListMembers(ctx, user1.Name, repo1.Name, &api.ListMembersParams{})
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusUnauthorized)
 			})
 
 			c.Convey("update success", func() {
-				resp, err := client.ListMembers(ctx, user2.Name, repo2.Name)
+				resp, err := client.ListMembers(ctx, user2.Name, repo2.Name, &api.ListMembersParams{})
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
 
 				result, err := api.ParseListMembersResponse(resp)
 				convey.So(err, convey.ShouldBeNil)
-				convey.ShouldHaveLength(1, len(*result.JSON200))
+				convey.ShouldHaveLength(1, len(result.JSON200.Results))
 			})
 		})
 	}
@@ -191,6 +191,11 @@ func MergeRequestSpec(ctx context.Context, urlStr string) func(c convey.C) {
 				resp, err := client.GetMergeRequest(ctx, userName, repoName, *firstMrID)
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+				result, err := api.ParseGetMergeRequestResponse(resp)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(*result.JSON200.Mergeable, convey.ShouldBeTrue)
+				convey.So(*result.JSON200.Conflicts, convey.ShouldEqual, 0)
 			})
 		})
 
@@ -254,6 +259,70 @@ func MergeRequestSpec(ctx context.Context, urlStr string) func(c convey.C) {
 			})
 		})
 
+		c.Convey("close and reopen merge request", func(c convey.C) {
+			c.Convey("no auth", func() {
+				re := client.RequestEditors
+				client.RequestEditors = nil
+				resp, err := client.CloseMergeRequest(ctx, userName, repoName, *firstMrID)
+				client.RequestEditors = re
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusUnauthorized)
+			})
+
+			c.Convey("fail to reopen open merge request", func() {
+				resp, err := client.ReopenMergeRequest(ctx, userName, repoName, *firstMrID)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusBadRequest)
+			})
+
+			c.Convey("fail to set merged by update", func() {
+				resp, err := client.UpdateMergeRequest(ctx, userName, repoName, *firstMrID, api.UpdateMergeRequestJSONRequestBody{
+					Status: utils.Int(int(models.MergeStateMerged)),
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusBadRequest)
+			})
+
+			c.Convey("success to close merge request", func() {
+				resp, err := client.CloseMergeRequest(ctx, userName, repoName, *firstMrID)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+				getResp, err := client.GetMergeRequest(ctx, userName, repoName, *firstMrID)
+				convey.So(err, convey.ShouldBeNil)
+				result, err := api.ParseGetMergeRequestResponse(getResp)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(result.JSON200.MergeStatus, convey.ShouldEqual, int(models.MergeStateClosed))
+				convey.So(*result.JSON200.Mergeable, convey.ShouldBeFalse)
+			})
+
+			c.Convey("fail to close closed merge request", func() {
+				resp, err := client.CloseMergeRequest(ctx, userName, repoName, *firstMrID)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusBadRequest)
+			})
+
+			c.Convey("fail to merge closed merge request", func() {
+				resp, err := client.Merge(ctx, userName, repoName, *firstMrID, api.MergeJSONRequestBody{
+					Msg: "test merge",
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusBadRequest)
+			})
+
+			c.Convey("success to reopen merge request", func() {
+				resp, err := client.ReopenMergeRequest(ctx, userName, repoName, *firstMrID)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+				getResp, err := client.GetMergeRequest(ctx, userName, repoName, *firstMrID)
+				convey.So(err, convey.ShouldBeNil)
+				result, err := api.ParseGetMergeRequestResponse(getResp)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(result.JSON200.MergeStatus, convey.ShouldEqual, int(models.MergeStateInit))
+			})
+		})
+
 		c.Convey("create many mergequests", func(_ convey.C) {
 			for i := 0; i < 10; i++ {
 				branchName := fmt.Sprintf("feat/list_merge_test_%d", i)
@@ -411,6 +480,20 @@ func MergeRequestSpec(ctx context.Context, urlStr string) func(c convey.C) {
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(int(models.MergeStateMerged), convey.ShouldEqual, (*updatedResult.JSON200).MergeStatus)
 			})
+
+			c.Convey("fail to merge merged merge request", func() {
+				resp, err := client.Merge(ctx, userName, repoName, *firstMrID, api.MergeJSONRequestBody{
+					Msg: "test merge",
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusBadRequest)
+			})
+
+			c.Convey("fail to reopen merged merge request", func() {
+				resp, err := client.ReopenMergeRequest(ctx, userName, repoName, *firstMrID)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusBadRequest)
+			})
 		})
 	}
 }
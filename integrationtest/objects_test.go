@@ -227,7 +227,7 @@ func ObjectSpec(ctx context.Context, urlStr string) func(c convey.C) {
 					Type:    api.RefTypeBranch,
 				})
 				convey.So(err, convey.ShouldBeNil)
-				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusBadRequest)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
 			})
 
 			c.Convey("success to head object", func() {
@@ -313,7 +313,7 @@ func ObjectSpec(ctx context.Context, urlStr string) func(c convey.C) {
 					Type:    api.RefTypeBranch,
 				})
 				convey.So(err, convey.ShouldBeNil)
-				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusBadRequest)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
 			})
 
 			c.Convey("success to get object", func() {
@@ -333,6 +333,34 @@ func ObjectSpec(ctx context.Context, urlStr string) func(c convey.C) {
 				exectEtag := fmt.Sprintf(`"%s"`, hex.EncodeToString(reader.Md5.Sum(nil)))
 				convey.So(etag, convey.ShouldEqual, exectEtag)
 			})
+
+			c.Convey("success to get object range", func() {
+				resp, err := client.GetObject(ctx, userName, repoName, &api.GetObjectParams{
+					RefName: branchName,
+					Path:    "a.bin",
+					Type:    api.RefTypeBranch,
+					Range:   utils.String("bytes=2-4"),
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusPartialContent)
+				convey.So(resp.Header.Get("Content-Range"), convey.ShouldEqual, "bytes 2-4/8")
+
+				data, err := io.ReadAll(resp.Body)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(data, convey.ShouldResemble, []byte{3, 4, 5})
+			})
+
+			c.Convey("fail to get object out of range", func() {
+				resp, err := client.GetObject(ctx, userName, repoName, &api.GetObjectParams{
+					RefName: branchName,
+					Path:    "a.bin",
+					Type:    api.RefTypeBranch,
+					Range:   utils.String("bytes=10-"),
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusRequestedRangeNotSatisfiable)
+				convey.So(resp.Header.Get("Content-Range"), convey.ShouldEqual, "bytes */8")
+			})
 		})
 
 		c.Convey("get files", func(c convey.C) {
@@ -419,5 +447,75 @@ func ObjectSpec(ctx context.Context, urlStr string) func(c convey.C) {
 			})
 
 		})
+
+		c.Convey("batch path exists", func(c convey.C) {
+			repoName := "testBatchPathExists"
+			c.Convey("init", func() {
+				_ = createRepo(ctx, client, repoName, false)
+				_ = createWip(ctx, client, userName, repoName, "main")
+				_ = uploadObject(ctx, client, userName, repoName, "main", "a/b.txt", true)
+				_ = uploadObject(ctx, client, userName, repoName, "main", "a/c/d.txt", true)
+			})
+
+			c.Convey("no auth", func() {
+				re := client.RequestEditors
+				client.RequestEditors = nil
+				resp, err := client.BatchPathExists(ctx, userName, repoName, &api.BatchPathExistsParams{
+					RefName: "main",
+					Type:    api.RefTypeWip,
+				}, api.BatchPathExistsJSONRequestBody{Paths: []string{"a"}})
+				client.RequestEditors = re
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusUnauthorized)
+			})
+
+			c.Convey("fail in non exit repo", func() {
+				resp, err := client.BatchPathExists(ctx, userName, "fakerepo", &api.BatchPathExistsParams{
+					RefName: "main",
+					Type:    api.RefTypeWip,
+				}, api.BatchPathExistsJSONRequestBody{Paths: []string{"a"}})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
+			})
+
+			c.Convey("forbidden in others", func() {
+				resp, err := client.BatchPathExists(ctx, "jimmy", "happygo", &api.BatchPathExistsParams{
+					RefName: "main",
+					Type:    api.RefTypeWip,
+				}, api.BatchPathExistsJSONRequestBody{Paths: []string{"a"}})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusUnauthorized)
+			})
+
+			c.Convey("success", func() {
+				resp, err := client.BatchPathExists(ctx, userName, repoName, &api.BatchPathExistsParams{
+					RefName: "main",
+					Type:    api.RefTypeWip,
+				}, api.BatchPathExistsJSONRequestBody{Paths: []string{"a", "a/b.txt", "a/x.txt", "/"}})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+				result, err := api.ParseBatchPathExistsResponse(resp)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(*result.JSON200, convey.ShouldHaveLength, 4)
+
+				dir := (*result.JSON200)[0]
+				convey.So(dir.Exists, convey.ShouldBeTrue)
+				convey.So(*dir.IsDir, convey.ShouldBeTrue)
+				convey.So(*dir.Entries, convey.ShouldHaveLength, 2)
+
+				file := (*result.JSON200)[1]
+				convey.So(file.Exists, convey.ShouldBeTrue)
+				convey.So(*file.IsDir, convey.ShouldBeFalse)
+				convey.So(file.Hash, convey.ShouldNotBeNil)
+				convey.So(file.Entries, convey.ShouldBeNil)
+
+				convey.So((*result.JSON200)[2].Exists, convey.ShouldBeFalse)
+
+				root := (*result.JSON200)[3]
+				convey.So(root.Exists, convey.ShouldBeTrue)
+				convey.So(*root.Entries, convey.ShouldHaveLength, 1)
+			})
+		})
 	}
 }
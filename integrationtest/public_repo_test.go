@@ -108,6 +108,32 @@ func PublicRepoSpec(ctx context.Context, urlStr string) func(c convey.C) {
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
 			})
+
+			c.Convey("private path not found", func() {
+				client.RequestEditors = user1Token
+				resp, err := client.UpdateRepository(ctx, user1Name, testRepoName, api.UpdateRepositoryJSONRequestBody{
+					PrivatePrefixes: &[]string{"secret"},
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+				client.RequestEditors = user2Token
+
+				resp, err = client.GetSchemaHistory(ctx, user1Name, testRepoName, &api.GetSchemaHistoryParams{
+					RefName: "main",
+					RefType: api.RefTypeBranch,
+					Path:    "secret/a.txt",
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
+
+				resp, err = client.GetObject(ctx, user1Name, testRepoName, &api.GetObjectParams{
+					RefName: "main",
+					Type:    api.RefTypeBranch,
+					Path:    "secret/a.txt",
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
+			})
 		})
 
 		c.Convey("list public repo", func() {
@@ -2,6 +2,7 @@ package integrationtest
 
 import (
 	"context"
+	"io"
 	"net/http"
 	"strconv"
 
@@ -9,6 +10,7 @@ import (
 	apiimpl "github.com/GitDataAI/jiaozifs/api/api_impl"
 	"github.com/GitDataAI/jiaozifs/controller"
 	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/google/uuid"
 	"github.com/smartystreets/goconvey/convey"
 )
 
@@ -162,6 +164,43 @@ func RepoSpec(ctx context.Context, urlStr string) func(c convey.C) {
 				convey.So(len(newListRepos.JSON200.Results), convey.ShouldEqual, 1)
 			})
 
+			c.Convey("page through repositories sorted by time", func() {
+				sortField := api.CreatedAt
+				for _, direction := range []api.SortDirection{api.Asc, api.Desc} {
+					direction := direction
+					var names []string
+					params := &api.ListRepositoryOfAuthenticatedUserParams{
+						Sort:      &sortField,
+						Direction: &direction,
+						Amount:    utils.Int(1),
+					}
+					for i := 0; i < 5; i++ {
+						resp, err := client.ListRepositoryOfAuthenticatedUser(ctx, params)
+						convey.So(err, convey.ShouldBeNil)
+						convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+						result, err := api.ParseListRepositoryOfAuthenticatedUserResponse(resp)
+						convey.So(err, convey.ShouldBeNil)
+						for _, repo := range result.JSON200.Results {
+							names = append(names, repo.Name)
+						}
+						if !result.JSON200.Pagination.HasMore {
+							break
+						}
+
+						after, err := strconv.ParseInt(result.JSON200.Pagination.NextOffset, 10, 64)
+						convey.So(err, convey.ShouldBeNil)
+						convey.So(result.JSON200.Pagination.NextOffsetId, convey.ShouldNotBeNil)
+						afterID, err := uuid.Parse(*result.JSON200.Pagination.NextOffsetId)
+						convey.So(err, convey.ShouldBeNil)
+						params.After = utils.Int64(after)
+						params.AfterId = &afterID
+					}
+					convey.So(names, convey.ShouldHaveLength, 2)
+					convey.So(names, convey.ShouldContain, repoName)
+					convey.So(names, convey.ShouldContain, "happygo")
+				}
+			})
+
 			c.Convey("success list repository of authenticatedUser, set page amount 0", func() {
 				resp, err := client.ListRepositoryOfAuthenticatedUser(ctx, &api.ListRepositoryOfAuthenticatedUserParams{
 					Amount: utils.Int(0),
@@ -275,6 +314,10 @@ func RepoSpec(ctx context.Context, urlStr string) func(c convey.C) {
 				resp, err := client.GetRepository(ctx, userName, "happyrun_mock")
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
+
+				data, err := io.ReadAll(resp.Body)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(string(data), convey.ShouldNotContainSubstring, "sql:")
 			})
 
 			c.Convey("get from non exit user", func() {
@@ -290,6 +333,30 @@ func RepoSpec(ctx context.Context, urlStr string) func(c convey.C) {
 			})
 		})
 
+		c.Convey("get repository stats", func(c convey.C) {
+			c.Convey("get stats", func() {
+				resp, err := client.GetRepositoryStats(ctx, userName, repoName, &api.GetRepositoryStatsParams{})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+				statsResult, err := api.ParseGetRepositoryStatsResponse(resp)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(statsResult.JSON200.ObjectCount, convey.ShouldBeGreaterThanOrEqualTo, 0)
+			})
+
+			c.Convey("recalculate stats", func() {
+				resp, err := client.GetRepositoryStats(ctx, userName, repoName, &api.GetRepositoryStatsParams{Recalculate: utils.Bool(true)})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+			})
+
+			c.Convey("get other's repo stats", func() {
+				resp, err := client.GetRepositoryStats(ctx, "admin2", "admin2_repo", &api.GetRepositoryStatsParams{})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusUnauthorized)
+			})
+		})
+
 		c.Convey("update repository", func(c convey.C) {
 			c.Convey("no auth", func() {
 				re := client.RequestEditors
@@ -365,6 +432,73 @@ func RepoSpec(ctx context.Context, urlStr string) func(c convey.C) {
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
 			})
+
+			c.Convey("rename to invalid name", func() {
+				resp, err := client.UpdateRepository(ctx, userName, repoName, api.UpdateRepositoryJSONRequestBody{
+					Name: utils.String("happyrun1@#%"),
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusBadRequest)
+			})
+
+			c.Convey("rename to exist repository", func() {
+				resp, err := client.UpdateRepository(ctx, userName, repoName, api.UpdateRepositoryJSONRequestBody{
+					Name: utils.String("happygo"),
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusConflict)
+			})
+
+			c.Convey("rename and change visible", func() {
+				resp, err := client.UpdateRepository(ctx, userName, repoName, api.UpdateRepositoryJSONRequestBody{
+					Name:    utils.String("happyrun_renamed"),
+					Visible: utils.Bool(true),
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+				getResp, err := client.GetRepository(ctx, userName, "happyrun_renamed")
+				convey.So(err, convey.ShouldBeNil)
+				getResult, err := api.ParseGetRepositoryResponse(getResp)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(getResult.JSON200.Visible, convey.ShouldBeTrue)
+
+				//old name is redirected to new name
+				getResp, err = client.GetRepository(ctx, userName, repoName)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(getResp.StatusCode, convey.ShouldEqual, http.StatusOK)
+				getResult, err = api.ParseGetRepositoryResponse(getResp)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(getResult.JSON200.Name, convey.ShouldEqual, "happyrun_renamed")
+
+				resp, err = client.UpdateRepository(ctx, userName, "happyrun_renamed", api.UpdateRepositoryJSONRequestBody{
+					Name:    utils.String(repoName),
+					Visible: utils.Bool(false),
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+			})
+
+			c.Convey("old name of private repository not redirected without permission", func() {
+				noRedirectClient, err := api.NewClient(urlStr+apiimpl.APIV1Prefix, api.WithHTTPClient(&http.Client{
+					CheckRedirect: func(*http.Request, []*http.Request) error {
+						return http.ErrUseLastResponse
+					},
+				}))
+				convey.So(err, convey.ShouldBeNil)
+
+				noRedirectClient.RequestEditors = client.RequestEditors
+				resp, err := noRedirectClient.GetRepository(ctx, userName, "happyrun_renamed")
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusMovedPermanently)
+				convey.So(resp.Header.Get("Location"), convey.ShouldEqual, apiimpl.APIV1Prefix+"/repos/"+userName+"/"+repoName)
+
+				noRedirectClient.RequestEditors = nil
+				resp, err = noRedirectClient.GetRepository(ctx, userName, "happyrun_renamed")
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
+				convey.So(resp.Header.Get("Location"), convey.ShouldBeEmpty)
+			})
 		})
 
 		c.Convey("get commits in repository", func(c convey.C) {
@@ -418,8 +552,8 @@ func RepoSpec(ctx context.Context, urlStr string) func(c convey.C) {
 
 				result, err := api.ParseGetCommitsInRefResponse(resp)
 				convey.So(err, convey.ShouldBeNil)
-				convey.So(*result.JSON200, convey.ShouldHaveLength, 1)
-				convey.So((*result.JSON200)[0].Message, convey.ShouldEqual, "first commit")
+				convey.So(result.JSON200.Results, convey.ShouldHaveLength, 1)
+				convey.So(result.JSON200.Results[0].Message, convey.ShouldEqual, "first commit")
 			})
 
 			c.Convey("add double commit to branch", func(_ convey.C) {
@@ -438,11 +572,12 @@ func RepoSpec(ctx context.Context, urlStr string) func(c convey.C) {
 
 				result, err := api.ParseGetCommitsInRefResponse(resp)
 				convey.So(err, convey.ShouldBeNil)
-				convey.So(*result.JSON200, convey.ShouldHaveLength, 3)
-				convey.So((*result.JSON200)[0].Message, convey.ShouldEqual, "third commit")
+				convey.So(result.JSON200.Results, convey.ShouldHaveLength, 3)
+				convey.So(result.JSON200.Pagination.HasMore, convey.ShouldBeFalse)
+				convey.So(result.JSON200.Results[0].Message, convey.ShouldEqual, "third commit")
 
 				newResp, err := client.GetCommitsInRef(ctx, userName, repoName, &api.GetCommitsInRefParams{
-					After:   utils.Int64((*result.JSON200)[0].Committer.When),
+					After:   utils.Int64(result.JSON200.Results[0].Committer.When),
 					Amount:  utils.Int(1),
 					RefName: utils.String(controller.DefaultBranchName),
 				})
@@ -451,8 +586,25 @@ func RepoSpec(ctx context.Context, urlStr string) func(c convey.C) {
 
 				newResult, err := api.ParseGetCommitsInRefResponse(newResp)
 				convey.So(err, convey.ShouldBeNil)
-				convey.So(*newResult.JSON200, convey.ShouldHaveLength, 1)
-				convey.So((*newResult.JSON200)[0].Message, convey.ShouldEqual, "second commit")
+				convey.So(newResult.JSON200.Results, convey.ShouldHaveLength, 1)
+				convey.So(newResult.JSON200.Results[0].Message, convey.ShouldEqual, "second commit")
+				convey.So(newResult.JSON200.Pagination.HasMore, convey.ShouldBeTrue)
+
+				nextOffset, err := strconv.ParseInt(newResult.JSON200.Pagination.NextOffset, 10, 64)
+				convey.So(err, convey.ShouldBeNil)
+				refType := api.RefTypeCommit
+				lastResp, err := client.GetCommitsInRef(ctx, userName, repoName, &api.GetCommitsInRefParams{
+					After:   utils.Int64(nextOffset),
+					RefName: utils.String(result.JSON200.Results[0].Hash),
+					RefType: &refType,
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lastResp.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+				lastResult, err := api.ParseGetCommitsInRefResponse(lastResp)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(lastResult.JSON200.Results, convey.ShouldHaveLength, 1)
+				convey.So(lastResult.JSON200.Results[0].Message, convey.ShouldEqual, "first commit")
 			})
 		})
 
@@ -492,6 +644,64 @@ func RepoSpec(ctx context.Context, urlStr string) func(c convey.C) {
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(getResp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
 			})
+
+			c.Convey("list repository in trash", func() {
+				resp, err := client.ListDeletedRepositories(ctx, &api.ListDeletedRepositoriesParams{})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+				result, err := api.ParseListDeletedRepositoriesResponse(resp)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(result.JSON200.Results, convey.ShouldHaveLength, 1)
+				convey.So(result.JSON200.Results[0].Name, convey.ShouldEqual, repoName)
+				convey.So(result.JSON200.Results[0].DeletedAt, convey.ShouldNotBeNil)
+			})
+
+			c.Convey("create repository with name in trash", func() {
+				resp, err := client.CreateRepository(ctx, api.CreateRepositoryJSONRequestBody{
+					Name: repoName,
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusBadRequest)
+			})
+
+			c.Convey("restore repository", func() {
+				resp, err := client.RestoreRepository(ctx, userName, repoName)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+				getResp, err := client.GetRepository(ctx, userName, repoName)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(getResp.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+				resp, err = client.RestoreRepository(ctx, userName, repoName)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
+			})
+
+			c.Convey("purge repository", func() {
+				resp, err := client.PurgeRepository(ctx, userName, repoName, &api.PurgeRepositoryParams{})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
+
+				resp, err = client.DeleteRepository(ctx, userName, repoName, &api.DeleteRepositoryParams{})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+				resp, err = client.PurgeRepository(ctx, userName, repoName, &api.PurgeRepositoryParams{})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusAccepted)
+
+				purgeResult, err := api.ParsePurgeRepositoryResponse(resp)
+				convey.So(err, convey.ShouldBeNil)
+				deletionResp, err := client.GetRepositoryDeletion(ctx, purgeResult.JSON202.Id)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(deletionResp.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+				resp, err = client.RestoreRepository(ctx, userName, repoName)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
+			})
 		})
 
 		c.Convey("get archive file", func(c convey.C) {
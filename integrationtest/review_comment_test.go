@@ -0,0 +1,169 @@
+package integrationtest
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/GitDataAI/jiaozifs/api"
+	apiimpl "github.com/GitDataAI/jiaozifs/api/api_impl"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/smartystreets/goconvey/convey"
+)
+
+func ReviewCommentSpec(ctx context.Context, urlStr string) func(c convey.C) {
+	client, _ := api.NewClient(urlStr + apiimpl.APIV1Prefix)
+	return func(c convey.C) {
+		userName := "lily"
+		repoName := "review_test"
+		branchName := "feat/review"
+		var mrSeq uint64
+		var root *api.ReviewComment
+
+		c.Convey("init", func(_ convey.C) {
+			_ = createUser(ctx, client, userName)
+			loginAndSwitch(ctx, client, userName, false)
+			_ = createRepo(ctx, client, repoName, false)
+			_ = createBranch(ctx, client, userName, repoName, "main", branchName)
+			_ = createWip(ctx, client, userName, repoName, branchName)
+			_ = uploadObject(ctx, client, userName, repoName, branchName, "data/a.csv", true)
+			_ = commitWip(ctx, client, userName, repoName, branchName, "test")
+			mrSeq = createMergeRequest(ctx, client, userName, repoName, branchName, "main").Sequence
+		})
+
+		c.Convey("create review comment", func(c convey.C) {
+			c.Convey("no auth", func() {
+				re := client.RequestEditors
+				client.RequestEditors = nil
+				resp, err := client.CreateReviewComment(ctx, userName, repoName, mrSeq, api.CreateReviewCommentJSONRequestBody{
+					Body: "check header",
+				})
+				client.RequestEditors = re
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusUnauthorized)
+			})
+
+			c.Convey("fail to create comment in non exit merge request", func() {
+				resp, err := client.CreateReviewComment(ctx, userName, repoName, 1000, api.CreateReviewCommentJSONRequestBody{
+					Body: "check header",
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
+			})
+
+			c.Convey("fail to create comment with invalid line range", func() {
+				resp, err := client.CreateReviewComment(ctx, userName, repoName, mrSeq, api.CreateReviewCommentJSONRequestBody{
+					Body: "check header",
+					Anchor: &api.ReviewAnchor{
+						Path:      "data/a.csv",
+						StartLine: utils.Int(3),
+						EndLine:   utils.Int(1),
+					},
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusBadRequest)
+			})
+
+			c.Convey("success to create comment", func() {
+				resp, err := client.CreateReviewComment(ctx, userName, repoName, mrSeq, api.CreateReviewCommentJSONRequestBody{
+					Body: "check header",
+					Anchor: &api.ReviewAnchor{
+						Path:      "/data/a.csv",
+						StartLine: utils.Int(1),
+					},
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusCreated)
+
+				result, err := api.ParseCreateReviewCommentResponse(resp)
+				convey.So(err, convey.ShouldBeNil)
+				root = result.JSON201
+				convey.So(root.Anchor.Path, convey.ShouldEqual, "data/a.csv")
+				convey.So(*root.Anchor.EndLine, convey.ShouldEqual, 1)
+				convey.So(root.Outdated, convey.ShouldBeFalse)
+			})
+
+			c.Convey("success to reply comment", func() {
+				resp, err := client.CreateReviewComment(ctx, userName, repoName, mrSeq, api.CreateReviewCommentJSONRequestBody{
+					Body:     "fixed",
+					ParentId: &root.Id,
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusCreated)
+
+				result, err := api.ParseCreateReviewCommentResponse(resp)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(*result.JSON201.ParentId, convey.ShouldEqual, root.Id)
+				convey.So(result.JSON201.Anchor.Path, convey.ShouldEqual, "data/a.csv")
+			})
+		})
+
+		c.Convey("list review comments", func(c convey.C) {
+			c.Convey("success to list comments", func() {
+				resp, err := client.ListReviewComments(ctx, userName, repoName, mrSeq, &api.ListReviewCommentsParams{})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+				result, err := api.ParseListReviewCommentsResponse(resp)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(*result.JSON200, convey.ShouldHaveLength, 2)
+				convey.So((*result.JSON200)[0].Id, convey.ShouldEqual, root.Id)
+			})
+
+			c.Convey("success to list comments by path", func() {
+				resp, err := client.ListReviewComments(ctx, userName, repoName, mrSeq, &api.ListReviewCommentsParams{
+					Path: utils.String("data/b.csv"),
+				})
+				convey.So(err, convey.ShouldBeNil)
+				result, err := api.ParseListReviewCommentsResponse(resp)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(*result.JSON200, convey.ShouldHaveLength, 0)
+			})
+		})
+
+		c.Convey("update review comment", func(c convey.C) {
+			c.Convey("success to resolve thread", func() {
+				resp, err := client.UpdateReviewComment(ctx, userName, repoName, mrSeq, root.Id, api.UpdateReviewCommentJSONRequestBody{
+					Body:     utils.String("check header and types"),
+					Resolved: utils.Bool(true),
+				})
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+				result, err := api.ParseUpdateReviewCommentResponse(resp)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(result.JSON200.Resolved, convey.ShouldBeTrue)
+				convey.So(result.JSON200.Body, convey.ShouldEqual, "check header and types")
+			})
+
+			c.Convey("success to list unresolved threads", func() {
+				resp, err := client.ListReviewComments(ctx, userName, repoName, mrSeq, &api.ListReviewCommentsParams{
+					Resolved: utils.Bool(false),
+				})
+				convey.So(err, convey.ShouldBeNil)
+				result, err := api.ParseListReviewCommentsResponse(resp)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(*result.JSON200, convey.ShouldHaveLength, 0)
+			})
+		})
+
+		c.Convey("delete review comment", func(c convey.C) {
+			c.Convey("success to delete thread", func() {
+				resp, err := client.DeleteReviewComment(ctx, userName, repoName, mrSeq, root.Id)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
+
+				getResp, err := client.ListReviewComments(ctx, userName, repoName, mrSeq, &api.ListReviewCommentsParams{})
+				convey.So(err, convey.ShouldBeNil)
+				result, err := api.ParseListReviewCommentsResponse(getResp)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(*result.JSON200, convey.ShouldHaveLength, 0)
+			})
+
+			c.Convey("fail to get deleted comment", func() {
+				resp, err := client.GetReviewComment(ctx, userName, repoName, mrSeq, root.Id)
+				convey.So(err, convey.ShouldBeNil)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
+			})
+		})
+	}
+}
@@ -23,9 +23,11 @@ func TestSpec(t *testing.T) {
 	convey.Convey("wip test", t, WipSpec(ctx, urlStr))
 	convey.Convey("wip object test", t, WipObjectSpec(ctx, urlStr))
 	convey.Convey("update wip test", t, UpdateWipSpec(ctx, urlStr))
+	convey.Convey("async commit test", t, AsyncCommitSpec(ctx, urlStr))
 	convey.Convey("get entries test", t, GetEntriesInRefSpec(ctx, urlStr))
 	convey.Convey("commit changes test", t, GetCommitChangesSpec(ctx, urlStr))
 	convey.Convey("merge request test", t, MergeRequestSpec(ctx, urlStr))
+	convey.Convey("review comment test", t, ReviewCommentSpec(ctx, urlStr))
 	convey.Convey("group test", t, GroupSpec(ctx, urlStr))
 	convey.Convey("member test", t, MemberSpec(ctx, urlStr))
 	convey.Convey("public repo test", t, PublicRepoSpec(ctx, urlStr))
@@ -99,7 +99,7 @@ func WipObjectSpec(ctx context.Context, urlStr string) func(c convey.C) {
 					Type:    api.RefTypeWip,
 				})
 				convey.So(err, convey.ShouldBeNil)
-				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusBadRequest)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
 			})
 
 			c.Convey("success to head object", func() {
@@ -183,7 +183,7 @@ func WipObjectSpec(ctx context.Context, urlStr string) func(c convey.C) {
 					Type:    api.RefTypeWip,
 				})
 				convey.So(err, convey.ShouldBeNil)
-				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusBadRequest)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
 			})
 
 			c.Convey("success to get object", func() {
@@ -292,7 +292,7 @@ func WipObjectSpec(ctx context.Context, urlStr string) func(c convey.C) {
 					Type:    api.RefTypeWip,
 				})
 				convey.So(err, convey.ShouldBeNil)
-				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusBadRequest)
+				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
 			})
 		})
 		testBranchName := "test/empty_branch"
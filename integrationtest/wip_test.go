@@ -26,13 +26,13 @@ func WipSpec(ctx context.Context, urlStr string) func(c convey.C) {
 		})
 
 		c.Convey("list non exit wip", func(_ convey.C) {
-			resp, err := client.ListWip(ctx, userName, repoName)
+			resp, err := client.ListWip(ctx, userName, repoName, &api.ListWipParams{})
 			convey.So(err, convey.ShouldBeNil)
 			convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
 
 			respResult, err := api.ParseListWipResponse(resp)
 			convey.So(err, convey.ShouldBeNil)
-			convey.So(respResult.JSON200, convey.ShouldHaveLength, 0)
+			convey.So(respResult.JSON200.Results, convey.ShouldHaveLength, 0)
 		})
 
 		c.Convey("create wip", func() {
@@ -110,36 +110,36 @@ func WipSpec(ctx context.Context, urlStr string) func(c convey.C) {
 			c.Convey("no auth", func() {
 				re := client.RequestEditors
 				client.RequestEditors = nil
-				resp, err := client.ListWip(ctx, userName, repoName)
+				resp, err := client.ListWip(ctx, userName, repoName, &api.ListWipParams{})
 				client.RequestEditors = re
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusUnauthorized)
 			})
 
 			c.Convey("success list wips", func() {
-				resp, err := client.ListWip(ctx, userName, repoName)
+				resp, err := client.ListWip(ctx, userName, repoName, &api.ListWipParams{})
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusOK)
 
 				respResult, err := api.ParseListWipResponse(resp)
 				convey.So(err, convey.ShouldBeNil)
-				convey.So(respResult.JSON200, convey.ShouldHaveLength, 2)
+				convey.So(respResult.JSON200.Results, convey.ShouldHaveLength, 2)
 			})
 
 			c.Convey("fail to list wip from non exit user", func() {
-				resp, err := client.ListWip(ctx, "mock_owner", repoName)
+				resp, err := client.ListWip(ctx, "mock_owner", repoName, &api.ListWipParams{})
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
 			})
 
 			c.Convey("fail to list wips in non exit branch", func() {
-				resp, err := client.ListWip(ctx, userName, "mockrepo")
+				resp, err := client.ListWip(ctx, userName, "mockrepo", &api.ListWipParams{})
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusNotFound)
 			})
 
 			c.Convey("fail to list wip in others's repo", func() {
-				resp, err := client.ListWip(ctx, "jimmy", "happygo")
+				resp, err := client.ListWip(ctx, "jimmy", "happygo", &api.ListWipParams{})
 				convey.So(err, convey.ShouldBeNil)
 				convey.So(resp.StatusCode, convey.ShouldEqual, http.StatusUnauthorized)
 			})
@@ -0,0 +1,279 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// AccessMode what holder of access token may do with objects in scope
+type AccessMode string
+
+const (
+	AccessModeRead  AccessMode = "read"
+	AccessModeWrite AccessMode = "write"
+)
+
+// Valid check whether mode is known
+func (mode AccessMode) Valid() bool {
+	return mode == AccessModeRead || mode == AccessModeWrite
+}
+
+// Allows check whether mode covers required mode, write access implies read access
+func (mode AccessMode) Allows(required AccessMode) bool {
+	return mode == required || (mode == AccessModeWrite && required == AccessModeRead)
+}
+
+// AccessToken grant temporary access of objects under path prefix of a repository to anyone holding the token, used to
+// sign urls reading or writing objects without credentials of user
+type AccessToken struct {
+	bun.BaseModel `bun:"table:access_tokens"`
+	ID            uuid.UUID `bun:"id,pk,type:uuid,default:uuid_generate_v4()" json:"id"`
+	RepositoryID  uuid.UUID `bun:"repository_id,type:uuid,notnull" json:"repository_id"`
+	// PathPrefix objects the token is scoped to, empty for whole repository
+	PathPrefix string     `bun:"path_prefix,notnull,default:''" json:"path_prefix"`
+	Mode       AccessMode `bun:"mode,notnull" json:"mode"`
+	// TokenHash sha256 of secret carried by signed url, the token itself is only shown once on creation
+	TokenHash string    `bun:"token_hash,unique,notnull" json:"-"`
+	CreatorID uuid.UUID `bun:"creator_id,type:uuid,notnull" json:"creator_id"`
+	ExpiredAt time.Time `bun:"expired_at,type:timestamp,notnull" json:"expired_at"`
+
+	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+	UpdatedAt time.Time `bun:"updated_at,type:timestamp,notnull" json:"updated_at"`
+}
+
+// IsExpired check whether access token can not be used anymore
+func (token *AccessToken) IsExpired(now time.Time) bool {
+	return !now.Before(token.ExpiredAt)
+}
+
+// Covers check whether objPath is under path prefix of access token
+func (token *AccessToken) Covers(objPath string) bool {
+	prefix := strings.Trim(token.PathPrefix, "/")
+	if len(prefix) == 0 {
+		return true
+	}
+	objPath = strings.Trim(path.Clean("/"+objPath), "/")
+	return objPath == prefix || strings.HasPrefix(objPath, prefix+"/")
+}
+
+// Validate return ErrAccessTokenDenied if access token is expired, or does not grant mode on objPath of repository
+func (token *AccessToken) Validate(now time.Time, repositoryID uuid.UUID, objPath string, mode AccessMode) error {
+	if token.IsExpired(now) {
+		return fmt.Errorf("access token expired at %s %w", token.ExpiredAt, ErrAccessTokenDenied)
+	}
+	if token.RepositoryID != repositoryID || !token.Covers(objPath) {
+		return fmt.Errorf("path %s out of scope of access token %w", objPath, ErrAccessTokenDenied)
+	}
+	if !token.Mode.Allows(mode) {
+		return fmt.Errorf("%s access not granted by access token %w", mode, ErrAccessTokenDenied)
+	}
+	return nil
+}
+
+// GenerateAccessToken create a random token for access token
+func GenerateAccessToken() (string, error) {
+	tokenBytes, err := io.ReadAll(io.LimitReader(rand.Reader, 32))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(tokenBytes), nil
+}
+
+// HashAccessToken hash stored for access token, access tokens are looked up by it so a leaked table does not leak
+// usable tokens
+func HashAccessToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type GetAccessTokenParams struct {
+	id           uuid.UUID
+	repositoryID uuid.UUID
+	tokenHash    *string
+}
+
+func NewGetAccessTokenParams() *GetAccessTokenParams {
+	return &GetAccessTokenParams{}
+}
+
+func (gap *GetAccessTokenParams) SetID(id uuid.UUID) *GetAccessTokenParams {
+	gap.id = id
+	return gap
+}
+
+func (gap *GetAccessTokenParams) SetRepositoryID(repositoryID uuid.UUID) *GetAccessTokenParams {
+	gap.repositoryID = repositoryID
+	return gap
+}
+
+// SetToken find access token by hash of token carried by signed url
+func (gap *GetAccessTokenParams) SetToken(token string) *GetAccessTokenParams {
+	tokenHash := HashAccessToken(token)
+	gap.tokenHash = &tokenHash
+	return gap
+}
+
+type ListAccessTokenParams struct {
+	repositoryID uuid.UUID
+	creatorID    uuid.UUID
+	after        *time.Time
+	amount       int
+}
+
+func NewListAccessTokenParams() *ListAccessTokenParams {
+	return &ListAccessTokenParams{}
+}
+
+func (lap *ListAccessTokenParams) SetRepositoryID(repositoryID uuid.UUID) *ListAccessTokenParams {
+	lap.repositoryID = repositoryID
+	return lap
+}
+
+func (lap *ListAccessTokenParams) SetCreatorID(creatorID uuid.UUID) *ListAccessTokenParams {
+	lap.creatorID = creatorID
+	return lap
+}
+
+func (lap *ListAccessTokenParams) SetAfter(after time.Time) *ListAccessTokenParams {
+	lap.after = &after
+	return lap
+}
+
+// SetAmount limit number of access tokens returned, all access tokens are returned if not set
+func (lap *ListAccessTokenParams) SetAmount(amount int) *ListAccessTokenParams {
+	lap.amount = amount
+	return lap
+}
+
+type DeleteAccessTokenParams struct {
+	id            uuid.UUID
+	repositoryID  uuid.UUID
+	expiredBefore *time.Time
+}
+
+func NewDeleteAccessTokenParams() *DeleteAccessTokenParams {
+	return &DeleteAccessTokenParams{}
+}
+
+func (dap *DeleteAccessTokenParams) SetID(id uuid.UUID) *DeleteAccessTokenParams {
+	dap.id = id
+	return dap
+}
+
+func (dap *DeleteAccessTokenParams) SetRepositoryID(repositoryID uuid.UUID) *DeleteAccessTokenParams {
+	dap.repositoryID = repositoryID
+	return dap
+}
+
+// SetExpiredBefore only delete access tokens expired before the time
+func (dap *DeleteAccessTokenParams) SetExpiredBefore(expiredBefore time.Time) *DeleteAccessTokenParams {
+	dap.expiredBefore = &expiredBefore
+	return dap
+}
+
+type IAccessTokenRepo interface {
+	Insert(ctx context.Context, token *AccessToken) (*AccessToken, error)
+	Get(ctx context.Context, params *GetAccessTokenParams) (*AccessToken, error)
+	List(ctx context.Context, params *ListAccessTokenParams) ([]*AccessToken, bool, error)
+	Delete(ctx context.Context, params *DeleteAccessTokenParams) (int64, error)
+}
+
+var _ IAccessTokenRepo = (*AccessTokenRepo)(nil)
+
+type AccessTokenRepo struct {
+	db bun.IDB
+}
+
+func NewAccessTokenRepo(db bun.IDB) IAccessTokenRepo {
+	return &AccessTokenRepo{db: db}
+}
+
+func (a *AccessTokenRepo) Insert(ctx context.Context, token *AccessToken) (*AccessToken, error) {
+	if !token.Mode.Valid() {
+		return nil, fmt.Errorf("access mode %s %w", token.Mode, ErrInvalidValue)
+	}
+	_, err := a.db.NewInsert().Model(token).Exec(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return token, nil
+}
+
+func (a *AccessTokenRepo) Get(ctx context.Context, params *GetAccessTokenParams) (*AccessToken, error) {
+	token := &AccessToken{}
+	query := a.db.NewSelect().Model(token)
+
+	if uuid.Nil != params.id {
+		query = query.Where("id = ?", params.id)
+	}
+
+	if uuid.Nil != params.repositoryID {
+		query = query.Where("repository_id = ?", params.repositoryID)
+	}
+
+	if params.tokenHash != nil {
+		query = query.Where("token_hash = ?", *params.tokenHash)
+	}
+
+	err := query.Limit(1).Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return token, nil
+}
+
+func (a *AccessTokenRepo) List(ctx context.Context, params *ListAccessTokenParams) ([]*AccessToken, bool, error) {
+	var tokens []*AccessToken
+	query := a.db.NewSelect().Model(&tokens)
+
+	if uuid.Nil != params.repositoryID {
+		query = query.Where("repository_id = ?", params.repositoryID)
+	}
+
+	if uuid.Nil != params.creatorID {
+		query = query.Where("creator_id = ?", params.creatorID)
+	}
+
+	query = query.Order("created_at DESC")
+	if params.after != nil {
+		query = query.Where("created_at < ?", *params.after)
+	}
+
+	err := query.Limit(params.amount).Scan(ctx)
+	if err != nil {
+		return nil, false, toModelError(err)
+	}
+	return tokens, params.amount > 0 && len(tokens) == params.amount, nil
+}
+
+func (a *AccessTokenRepo) Delete(ctx context.Context, params *DeleteAccessTokenParams) (int64, error) {
+	query := a.db.NewDelete().Model((*AccessToken)(nil))
+
+	if uuid.Nil != params.id {
+		query = query.Where("id = ?", params.id)
+	}
+
+	if uuid.Nil != params.repositoryID {
+		query = query.Where("repository_id = ?", params.repositoryID)
+	}
+
+	if params.expiredBefore != nil {
+		query = query.Where("expired_at < ?", *params.expiredBefore)
+	}
+
+	result, err := query.Exec(ctx)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+	return result.RowsAffected()
+}
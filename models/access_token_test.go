@@ -0,0 +1,116 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessTokenRepo(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewAccessTokenRepo(db)
+	repositoryID := uuid.New()
+
+	newToken := func(expiredAt time.Time) *models.AccessToken {
+		tokenModel := &models.AccessToken{}
+		require.NoError(t, gofakeit.Struct(tokenModel))
+		tokenModel.RepositoryID = repositoryID
+		tokenModel.Mode = models.AccessModeRead
+		tokenModel.ExpiredAt = expiredAt
+		return tokenModel
+	}
+
+	plainToken, err := models.GenerateAccessToken()
+	require.NoError(t, err)
+	tokenModel := newToken(time.Now().Add(time.Hour))
+	tokenModel.TokenHash = models.HashAccessToken(plainToken)
+	token, err := repo.Insert(ctx, tokenModel)
+	require.NoError(t, err)
+
+	t.Run("invalid mode", func(t *testing.T) {
+		tokenModel := newToken(time.Now())
+		tokenModel.Mode = "admin"
+		_, err := repo.Insert(ctx, tokenModel)
+		require.ErrorIs(t, err, models.ErrInvalidValue)
+	})
+
+	t.Run("get", func(t *testing.T) {
+		byToken, err := repo.Get(ctx, models.NewGetAccessTokenParams().SetToken(plainToken))
+		require.NoError(t, err)
+		require.True(t, cmp.Equal(token, byToken, testhelper.DBTimeCmpOpt))
+
+		// stored hash is not a usable token
+		_, err = repo.Get(ctx, models.NewGetAccessTokenParams().SetToken(token.TokenHash))
+		require.ErrorIs(t, err, models.ErrNotFound)
+
+		_, err = repo.Get(ctx, models.NewGetAccessTokenParams().SetID(token.ID).SetRepositoryID(uuid.New()))
+		require.ErrorIs(t, err, models.ErrNotFound)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		_, err := repo.Insert(ctx, newToken(time.Now().Add(-time.Hour)))
+		require.NoError(t, err)
+
+		tokens, hasMore, err := repo.List(ctx, models.NewListAccessTokenParams().SetRepositoryID(repositoryID))
+		require.NoError(t, err)
+		require.Len(t, tokens, 2)
+		require.False(t, hasMore)
+
+		page, hasMore, err := repo.List(ctx, models.NewListAccessTokenParams().SetRepositoryID(repositoryID).SetAmount(1))
+		require.NoError(t, err)
+		require.True(t, hasMore)
+		require.Equal(t, tokens[0].ID, page[0].ID)
+	})
+
+	t.Run("delete expired", func(t *testing.T) {
+		affectedRows, err := repo.Delete(ctx, models.NewDeleteAccessTokenParams().SetRepositoryID(repositoryID).SetExpiredBefore(time.Now()))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), affectedRows)
+
+		_, err = repo.Get(ctx, models.NewGetAccessTokenParams().SetID(token.ID))
+		require.NoError(t, err)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		affectedRows, err := repo.Delete(ctx, models.NewDeleteAccessTokenParams().SetID(token.ID).SetRepositoryID(repositoryID))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), affectedRows)
+
+		_, err = repo.Get(ctx, models.NewGetAccessTokenParams().SetID(token.ID))
+		require.ErrorIs(t, err, models.ErrNotFound)
+	})
+}
+
+func TestAccessTokenValidate(t *testing.T) {
+	now := time.Now()
+	repositoryID := uuid.New()
+	token := &models.AccessToken{
+		RepositoryID: repositoryID,
+		PathPrefix:   "data/",
+		Mode:         models.AccessModeRead,
+		ExpiredAt:    now.Add(time.Minute),
+	}
+
+	require.NoError(t, token.Validate(now, repositoryID, "data/a.txt", models.AccessModeRead))
+	require.NoError(t, token.Validate(now, repositoryID, "/data", models.AccessModeRead))
+	require.ErrorIs(t, token.Validate(now, repositoryID, "database/a.txt", models.AccessModeRead), models.ErrAccessTokenDenied)
+	require.ErrorIs(t, token.Validate(now, repositoryID, "data/../b.txt", models.AccessModeRead), models.ErrAccessTokenDenied)
+	require.ErrorIs(t, token.Validate(now, uuid.New(), "data/a.txt", models.AccessModeRead), models.ErrAccessTokenDenied)
+	require.ErrorIs(t, token.Validate(now, repositoryID, "data/a.txt", models.AccessModeWrite), models.ErrAccessTokenDenied)
+	require.ErrorIs(t, token.Validate(now.Add(time.Minute), repositoryID, "data/a.txt", models.AccessModeRead), models.ErrAccessTokenDenied)
+
+	token.Mode = models.AccessModeWrite
+	token.PathPrefix = ""
+	require.NoError(t, token.Validate(now, repositoryID, "b.txt", models.AccessModeRead))
+	require.NoError(t, token.Validate(now, repositoryID, "b.txt", models.AccessModeWrite))
+}
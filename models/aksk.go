@@ -117,7 +117,7 @@ func NewAkskRepo(db bun.IDB) IAkskRepo {
 func (a AkskRepo) Insert(ctx context.Context, akSk *AkSk) (*AkSk, error) {
 	_, err := a.db.NewInsert().Model(akSk).Exec(ctx)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
 	}
 	return akSk, nil
 }
@@ -140,7 +140,7 @@ func (a AkskRepo) Get(ctx context.Context, params *GetAkSkParams) (*AkSk, error)
 
 	err := query.Limit(1).Scan(ctx)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
 	}
 	return repo, nil
 }
@@ -159,7 +159,7 @@ func (a AkskRepo) List(ctx context.Context, params *ListAkSkParams) ([]*AkSk, bo
 	}
 
 	err := query.Limit(params.amount).Scan(ctx)
-	return branches, len(branches) == params.amount, err
+	return branches, len(branches) == params.amount, toModelError(err)
 }
 
 func (a AkskRepo) Delete(ctx context.Context, params *DeleteAkSkParams) (int64, error) {
@@ -179,7 +179,7 @@ func (a AkskRepo) Delete(ctx context.Context, params *DeleteAkSkParams) (int64,
 
 	sqlResult, err := query.Exec(ctx)
 	if err != nil {
-		return 0, err
+		return 0, toModelError(err)
 	}
 	affectedRows, err := sqlResult.RowsAffected()
 	if err != nil {
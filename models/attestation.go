@@ -0,0 +1,233 @@
+package models
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// AttestationStatus result of check reported by external system
+type AttestationStatus string
+
+const (
+	AttestationPending AttestationStatus = "pending"
+	AttestationSuccess AttestationStatus = "success"
+	AttestationFailure AttestationStatus = "failure"
+)
+
+func (status AttestationStatus) Valid() bool {
+	switch status {
+	case AttestationPending, AttestationSuccess, AttestationFailure:
+		return true
+	}
+	return false
+}
+
+// Attestation statement of external system such as ci about a commit, eg. tests passed or data validated. attestations
+// are append only, the latest one of a name on a commit is its current result. Signature is hmac of the content by
+// server secret, so attestations modified out of api are not trusted by merge checks
+type Attestation struct {
+	bun.BaseModel `bun:"table:attestations"`
+	ID            uuid.UUID `bun:"id,pk,type:uuid,default:uuid_generate_v4()" json:"id"`
+	RepositoryID  uuid.UUID `bun:"repository_id,type:uuid,notnull" json:"repository_id"`
+	CommitHash    hash.Hash `bun:"commit_hash,type:bytea,notnull" json:"commit_hash"`
+	// Name of check, eg. unit-test, required checks of merge rules refer to it
+	Name        string            `bun:"name,notnull" json:"name"`
+	Status      AttestationStatus `bun:"status,notnull" json:"status"`
+	Description *string           `bun:"description" json:"description,omitempty"`
+	// URL link to details of the check, eg. pipeline run
+	URL       *string   `bun:"url" json:"url,omitempty"`
+	CreatorID uuid.UUID `bun:"creator_id,type:uuid,notnull" json:"creator_id"`
+	Signature string    `bun:"signature,notnull" json:"signature"`
+
+	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+}
+
+// NewAttestation create unsigned attestation, Sign must be called after optional fields are set. created time is
+// truncated to microsecond kept by database, so signature still match after read back
+func NewAttestation(repositoryID uuid.UUID, commitHash hash.Hash, name string, status AttestationStatus, creatorID uuid.UUID) *Attestation {
+	return &Attestation{
+		ID:           uuid.New(),
+		RepositoryID: repositoryID,
+		CommitHash:   commitHash,
+		Name:         name,
+		Status:       status,
+		CreatorID:    creatorID,
+		CreatedAt:    time.Now().Truncate(time.Microsecond),
+	}
+}
+
+func (attestation *Attestation) content() []byte {
+	return []byte(strings.Join([]string{
+		attestation.ID.String(),
+		attestation.RepositoryID.String(),
+		attestation.CommitHash.Hex(),
+		attestation.Name,
+		string(attestation.Status),
+		utils.StringValue(attestation.Description),
+		utils.StringValue(attestation.URL),
+		attestation.CreatorID.String(),
+		strconv.FormatInt(attestation.CreatedAt.UnixMicro(), 10),
+	}, "\n"))
+}
+
+func (attestation *Attestation) signature(secretKey []byte) []byte {
+	mac := hmac.New(sha256.New, secretKey)
+	mac.Write(attestation.content())
+	return mac.Sum(nil)
+}
+
+// Sign set signature of attestation, should be called again after any field changed
+func (attestation *Attestation) Sign(secretKey []byte) {
+	attestation.Signature = hex.EncodeToString(attestation.signature(secretKey))
+}
+
+// Verify check whether attestation is signed by secretKey and not changed since then
+func (attestation *Attestation) Verify(secretKey []byte) bool {
+	signature, err := hex.DecodeString(attestation.Signature)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(signature, attestation.signature(secretKey))
+}
+
+// CheckStatus current result of a check, Attestation is nil and status is pending if the check has not been reported
+type CheckStatus struct {
+	Name        string
+	Status      AttestationStatus
+	Required    bool
+	Attestation *Attestation
+}
+
+// CheckStatuses latest verified result of checks reported in attestations or required by rules, required checks come
+// first in order of rules. attestations should be sorted from newest to oldest as returned by IAttestationRepo.List
+func CheckStatuses(rules []MergeRule, attestations []*Attestation, secretKey []byte) []CheckStatus {
+	var statuses []CheckStatus
+	index := make(map[string]int)
+	for _, rule := range rules {
+		for _, name := range rule.RequiredChecks {
+			if _, ok := index[name]; !ok {
+				index[name] = len(statuses)
+				statuses = append(statuses, CheckStatus{Name: name, Status: AttestationPending, Required: true})
+			}
+		}
+	}
+
+	reported := make(map[string]bool)
+	for _, attestation := range attestations {
+		if reported[attestation.Name] || !attestation.Verify(secretKey) {
+			continue
+		}
+		reported[attestation.Name] = true
+		i, ok := index[attestation.Name]
+		if !ok {
+			i = len(statuses)
+			statuses = append(statuses, CheckStatus{Name: attestation.Name})
+		}
+		statuses[i].Status = attestation.Status
+		statuses[i].Attestation = attestation
+	}
+	return statuses
+}
+
+// CheckRequiredChecks return ErrMergeRuleUnmet if the latest verified attestation of a required check in rules is missing
+// or not success, attestations should be sorted from newest to oldest as returned by IAttestationRepo.List
+func CheckRequiredChecks(rules []MergeRule, attestations []*Attestation, secretKey []byte) error {
+	for _, status := range CheckStatuses(rules, attestations, secretKey) {
+		if !status.Required {
+			continue
+		}
+		if status.Attestation == nil {
+			return fmt.Errorf("required check %s is not reported %w", status.Name, ErrMergeRuleUnmet)
+		}
+		if status.Status != AttestationSuccess {
+			return fmt.Errorf("required check %s is %s %w", status.Name, status.Status, ErrMergeRuleUnmet)
+		}
+	}
+	return nil
+}
+
+type ListAttestationParams struct {
+	repositoryID uuid.UUID
+	commitHash   hash.Hash
+	name         *string
+}
+
+func NewListAttestationParams() *ListAttestationParams {
+	return &ListAttestationParams{}
+}
+
+func (lap *ListAttestationParams) SetRepositoryID(repositoryID uuid.UUID) *ListAttestationParams {
+	lap.repositoryID = repositoryID
+	return lap
+}
+
+func (lap *ListAttestationParams) SetCommitHash(commitHash hash.Hash) *ListAttestationParams {
+	lap.commitHash = commitHash
+	return lap
+}
+
+func (lap *ListAttestationParams) SetName(name string) *ListAttestationParams {
+	lap.name = &name
+	return lap
+}
+
+type IAttestationRepo interface {
+	Insert(ctx context.Context, attestation *Attestation) (*Attestation, error)
+	// List attestations of commit from newest to oldest
+	List(ctx context.Context, params *ListAttestationParams) ([]*Attestation, error)
+	Delete(ctx context.Context, repositoryID uuid.UUID) (int64, error)
+}
+
+var _ IAttestationRepo = (*AttestationRepo)(nil)
+
+type AttestationRepo struct {
+	db bun.IDB
+}
+
+func NewAttestationRepo(db bun.IDB) IAttestationRepo {
+	return &AttestationRepo{db: db}
+}
+
+func (a *AttestationRepo) Insert(ctx context.Context, attestation *Attestation) (*Attestation, error) {
+	_, err := a.db.NewInsert().Model(attestation).Exec(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return attestation, nil
+}
+
+func (a *AttestationRepo) List(ctx context.Context, params *ListAttestationParams) ([]*Attestation, error) {
+	var attestations []*Attestation
+	query := a.db.NewSelect().Model(&attestations).
+		Where("repository_id = ?", params.repositoryID).
+		Where("commit_hash = ?", params.commitHash)
+
+	if params.name != nil {
+		query = query.Where("name = ?", *params.name)
+	}
+
+	err := query.Order("created_at DESC").Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return attestations, nil
+}
+
+func (a *AttestationRepo) Delete(ctx context.Context, repositoryID uuid.UUID) (int64, error) {
+	sqlResult, err := a.db.NewDelete().Model((*Attestation)(nil)).Where("repository_id = ?", repositoryID).Exec(ctx)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+	return sqlResult.RowsAffected()
+}
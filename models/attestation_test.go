@@ -0,0 +1,106 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttestationRepo(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewAttestationRepo(db)
+	repositoryID, creatorID := uuid.New(), uuid.New()
+	secretKey := []byte("secret")
+
+	attest := func(commitHash hash.Hash, name string, status models.AttestationStatus) *models.Attestation {
+		attestation := models.NewAttestation(repositoryID, commitHash, name, status, creatorID)
+		attestation.URL = utils.String("https://ci.example.com/runs/1")
+		attestation.Sign(secretKey)
+		attestation, err := repo.Insert(ctx, attestation)
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond)
+		return attestation
+	}
+
+	attest(hash.Hash("a"), "unit-test", models.AttestationFailure)
+	attest(hash.Hash("a"), "validate", models.AttestationSuccess)
+	latest := attest(hash.Hash("a"), "unit-test", models.AttestationSuccess)
+	attest(hash.Hash("b"), "unit-test", models.AttestationFailure)
+
+	attestations, err := repo.List(ctx, models.NewListAttestationParams().SetRepositoryID(repositoryID).SetCommitHash(hash.Hash("a")))
+	require.NoError(t, err)
+	require.Len(t, attestations, 3)
+	require.Equal(t, latest.ID, attestations[0].ID)
+	for _, attestation := range attestations {
+		require.True(t, attestation.Verify(secretKey))
+		require.False(t, attestation.Verify([]byte("other")))
+	}
+
+	attestations, err = repo.List(ctx, models.NewListAttestationParams().SetRepositoryID(repositoryID).SetCommitHash(hash.Hash("a")).SetName("validate"))
+	require.NoError(t, err)
+	require.Len(t, attestations, 1)
+
+	affected, err := repo.Delete(ctx, repositoryID)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), affected)
+}
+
+func TestCheckRequiredChecks(t *testing.T) {
+	secretKey := []byte("secret")
+	repositoryID, creatorID := uuid.New(), uuid.New()
+	rules := []models.MergeRule{{Branch: "main", RequiredChecks: []string{"unit-test"}}}
+
+	newAttestation := func(status models.AttestationStatus) *models.Attestation {
+		attestation := models.NewAttestation(repositoryID, hash.Hash("a"), "unit-test", status, creatorID)
+		attestation.Sign(secretKey)
+		return attestation
+	}
+
+	require.NoError(t, models.CheckRequiredChecks([]models.MergeRule{{Branch: "main"}}, nil, secretKey))
+	require.ErrorIs(t, models.CheckRequiredChecks(rules, nil, secretKey), models.ErrMergeRuleUnmet)
+
+	success, failure := newAttestation(models.AttestationSuccess), newAttestation(models.AttestationFailure)
+	require.NoError(t, models.CheckRequiredChecks(rules, []*models.Attestation{success, failure}, secretKey))
+	require.ErrorIs(t, models.CheckRequiredChecks(rules, []*models.Attestation{failure, success}, secretKey), models.ErrMergeRuleUnmet)
+
+	// attestation changed out of api is ignored
+	failure.Status = models.AttestationSuccess
+	require.ErrorIs(t, models.CheckRequiredChecks(rules, []*models.Attestation{failure}, secretKey), models.ErrMergeRuleUnmet)
+}
+
+func TestCheckStatuses(t *testing.T) {
+	secretKey := []byte("secret")
+	repositoryID, creatorID := uuid.New(), uuid.New()
+	rules := []models.MergeRule{{Branch: "main", RequiredChecks: []string{"unit-test", "lint"}}, {Branch: "*", RequiredChecks: []string{"lint"}}}
+
+	newAttestation := func(name string, status models.AttestationStatus) *models.Attestation {
+		attestation := models.NewAttestation(repositoryID, hash.Hash("a"), name, status, creatorID)
+		attestation.Sign(secretKey)
+		return attestation
+	}
+
+	statuses := models.CheckStatuses(rules, []*models.Attestation{
+		newAttestation("coverage", models.AttestationSuccess),
+		newAttestation("unit-test", models.AttestationFailure),
+		newAttestation("unit-test", models.AttestationSuccess),
+	}, secretKey)
+	require.Len(t, statuses, 3)
+	require.Equal(t, "unit-test", statuses[0].Name)
+	require.Equal(t, models.AttestationFailure, statuses[0].Status)
+	require.True(t, statuses[0].Required)
+	require.Equal(t, "lint", statuses[1].Name)
+	require.Equal(t, models.AttestationPending, statuses[1].Status)
+	require.Nil(t, statuses[1].Attestation)
+	require.Equal(t, "coverage", statuses[2].Name)
+	require.False(t, statuses[2].Required)
+}
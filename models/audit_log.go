@@ -0,0 +1,116 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// AuditLog record a mutating operation which succeed. audit logs are kept after repository is purged, ActorID is
+// uuid.Nil if request is not authenticated such as register
+type AuditLog struct {
+	bun.BaseModel `bun:"table:audit_logs"`
+	ID            uuid.UUID `bun:"id,pk,type:uuid,default:uuid_generate_v4()" json:"id"`
+	ActorID       uuid.UUID `bun:"actor_id,type:uuid" json:"actor_id"`
+	// Action operation like repo.create, ref.update, commit.create, wip.delete
+	Action       string    `bun:"action,notnull" json:"action"`
+	RepositoryID uuid.UUID `bun:"repository_id,type:uuid" json:"repository_id"`
+	// Targets identify objects operated, path and query parameters of request
+	Targets    map[string]string `bun:"targets,type:jsonb" json:"targets"`
+	RemoteAddr string            `bun:"remote_addr" json:"remote_addr"`
+
+	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+}
+
+type ListAuditLogParams struct {
+	actorID      uuid.UUID
+	repositoryID uuid.UUID
+	action       *string
+	after        *time.Time
+	amount       int
+}
+
+func NewListAuditLogParams() *ListAuditLogParams {
+	return &ListAuditLogParams{}
+}
+
+func (lp *ListAuditLogParams) SetActorID(actorID uuid.UUID) *ListAuditLogParams {
+	lp.actorID = actorID
+	return lp
+}
+
+func (lp *ListAuditLogParams) SetRepositoryID(repositoryID uuid.UUID) *ListAuditLogParams {
+	lp.repositoryID = repositoryID
+	return lp
+}
+
+func (lp *ListAuditLogParams) SetAction(action string) *ListAuditLogParams {
+	lp.action = &action
+	return lp
+}
+
+// SetAfter list logs created before after, logs are listed newest first
+func (lp *ListAuditLogParams) SetAfter(after time.Time) *ListAuditLogParams {
+	lp.after = &after
+	return lp
+}
+
+func (lp *ListAuditLogParams) SetAmount(amount int) *ListAuditLogParams {
+	lp.amount = amount
+	return lp
+}
+
+type IAuditLogRepo interface {
+	Insert(ctx context.Context, log *AuditLog) (*AuditLog, error)
+	// List logs newest first
+	List(ctx context.Context, params *ListAuditLogParams) ([]*AuditLog, bool, error)
+}
+
+var _ IAuditLogRepo = (*AuditLogRepo)(nil)
+
+type AuditLogRepo struct {
+	db bun.IDB
+}
+
+func NewAuditLogRepo(db bun.IDB) IAuditLogRepo {
+	return &AuditLogRepo{db: db}
+}
+
+func (a *AuditLogRepo) Insert(ctx context.Context, log *AuditLog) (*AuditLog, error) {
+	_, err := a.db.NewInsert().Model(log).Exec(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return log, nil
+}
+
+func (a *AuditLogRepo) List(ctx context.Context, params *ListAuditLogParams) ([]*AuditLog, bool, error) {
+	logs := make([]*AuditLog, 0)
+	query := a.db.NewSelect().Model(&logs)
+
+	if uuid.Nil != params.actorID {
+		query = query.Where("actor_id = ?", params.actorID)
+	}
+	if uuid.Nil != params.repositoryID {
+		query = query.Where("repository_id = ?", params.repositoryID)
+	}
+	if params.action != nil {
+		query = query.Where("action = ?", *params.action)
+	}
+
+	query = query.Order("created_at DESC")
+	if params.after != nil {
+		query = query.Where("created_at < ?", *params.after)
+	}
+	if params.amount > 0 {
+		query = query.Limit(params.amount)
+	}
+
+	err := query.Scan(ctx)
+	if err != nil {
+		return nil, false, toModelError(err)
+	}
+	return logs, params.amount > 0 && len(logs) == params.amount, nil
+}
@@ -0,0 +1,62 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAuditLogRepo(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewAuditLogRepo(db)
+	actorID := uuid.New()
+	repositoryID := uuid.New()
+
+	now := time.Now()
+	for i, action := range []string{"repo.create", "commit.create", "commit.create"} {
+		_, err := repo.Insert(ctx, &models.AuditLog{
+			ActorID:      actorID,
+			Action:       action,
+			RepositoryID: repositoryID,
+			Targets:      map[string]string{"branch": "main"},
+			RemoteAddr:   "127.0.0.1",
+			CreatedAt:    now.Add(time.Duration(i) * time.Second),
+		})
+		require.NoError(t, err)
+	}
+	_, err := repo.Insert(ctx, &models.AuditLog{
+		Action:    "user.register",
+		Targets:   map[string]string{},
+		CreatedAt: now,
+	})
+	require.NoError(t, err)
+
+	logs, hasMore, err := repo.List(ctx, models.NewListAuditLogParams())
+	require.NoError(t, err)
+	require.False(t, hasMore)
+	require.Len(t, logs, 4)
+
+	logs, hasMore, err = repo.List(ctx, models.NewListAuditLogParams().SetActorID(actorID).SetAmount(2))
+	require.NoError(t, err)
+	require.True(t, hasMore)
+	require.Len(t, logs, 2)
+	require.Equal(t, "commit.create", logs[0].Action)
+	require.Equal(t, "main", logs[0].Targets["branch"])
+
+	logs, _, err = repo.List(ctx, models.NewListAuditLogParams().SetActorID(actorID).SetAfter(logs[1].CreatedAt))
+	require.NoError(t, err)
+	require.Len(t, logs, 1)
+	require.Equal(t, "repo.create", logs[0].Action)
+
+	logs, _, err = repo.List(ctx, models.NewListAuditLogParams().SetRepositoryID(repositoryID).SetAction("commit.create"))
+	require.NoError(t, err)
+	require.Len(t, logs, 2)
+}
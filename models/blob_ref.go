@@ -0,0 +1,202 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// BlobRef number of blob objects of repository whose content is CheckSum. forks share storage of upstream, content in
+// storage is only removed when no repository using that storage references it anymore. refs are updated in the same
+// statement batch as objects are inserted or deleted
+type BlobRef struct {
+	bun.BaseModel `bun:"table:blob_refs"`
+	RepositoryID  uuid.UUID `bun:"repository_id,pk,type:uuid,notnull" json:"repository_id"`
+	CheckSum      hash.Hash `bun:"check_sum,pk,type:bytea" json:"check_sum"`
+	RefCount      int64     `bun:"ref_count,notnull" json:"ref_count"`
+
+	UpdatedAt time.Time `bun:"updated_at,type:timestamp,notnull" json:"updated_at"`
+}
+
+// blobRefDelta collect changes of refs by content before they are written in one statement
+type blobRefDelta map[string]*BlobRef
+
+func (delta blobRefDelta) add(repositoryID uuid.UUID, checkSum hash.Hash, count int64) {
+	if len(checkSum) == 0 {
+		return
+	}
+	ref, ok := delta[checkSum.Hex()]
+	if !ok {
+		ref = &BlobRef{RepositoryID: repositoryID, CheckSum: checkSum}
+		delta[checkSum.Hex()] = ref
+	}
+	ref.RefCount += count
+}
+
+// addBlobRefs apply delta to refs of repository, refs dropped to zero are removed
+func addBlobRefs(ctx context.Context, db bun.IDB, delta blobRefDelta) error {
+	refs := make([]*BlobRef, 0, len(delta))
+	var released [][]byte
+	now := time.Now()
+	for _, ref := range delta {
+		if ref.RefCount == 0 {
+			continue
+		}
+		ref.UpdatedAt = now
+		refs = append(refs, ref)
+		if ref.RefCount < 0 {
+			released = append(released, ref.CheckSum)
+		}
+	}
+	if len(refs) == 0 {
+		return nil
+	}
+
+	for start := 0; start < len(refs); start += objectBatchSize {
+		batch := refs[start:min(start+objectBatchSize, len(refs))]
+		_, err := db.NewInsert().Model(&batch).
+			On("CONFLICT (repository_id, check_sum) DO UPDATE").
+			Set("ref_count = ?TableAlias.ref_count + EXCLUDED.ref_count").
+			Set("updated_at = EXCLUDED.updated_at").
+			Exec(ctx)
+		if err != nil {
+			return toModelError(err)
+		}
+	}
+
+	for start := 0; start < len(released); start += objectBatchSize {
+		_, err := db.NewDelete().Model((*BlobRef)(nil)).
+			Where("repository_id = ?", refs[0].RepositoryID).
+			Where("check_sum IN (?)", bun.In(released[start:min(start+objectBatchSize, len(released))])).
+			Where("ref_count <= 0").
+			Exec(ctx)
+		if err != nil {
+			return toModelError(err)
+		}
+	}
+	return nil
+}
+
+type CountBlobRefsParams struct {
+	storageNamespace *string
+	repositoryID     *uuid.UUID
+	excludeID        *uuid.UUID
+	checkSums        []hash.Hash
+}
+
+func NewCountBlobRefsParams() *CountBlobRefsParams {
+	return &CountBlobRefsParams{}
+}
+
+// SetStorageNamespace count refs of all repositories using storage namespace, upstream and its forks
+func (params *CountBlobRefsParams) SetStorageNamespace(storageNamespace string) *CountBlobRefsParams {
+	params.storageNamespace = &storageNamespace
+	return params
+}
+
+func (params *CountBlobRefsParams) SetRepositoryID(repositoryID uuid.UUID) *CountBlobRefsParams {
+	params.repositoryID = &repositoryID
+	return params
+}
+
+// SetExcludeRepositoryID skip refs of repository, used to find out whether others still reference the content
+func (params *CountBlobRefsParams) SetExcludeRepositoryID(repositoryID uuid.UUID) *CountBlobRefsParams {
+	params.excludeID = &repositoryID
+	return params
+}
+
+func (params *CountBlobRefsParams) SetCheckSums(checkSums ...hash.Hash) *CountBlobRefsParams {
+	params.checkSums = checkSums
+	return params
+}
+
+type IBlobRefRepo interface {
+	// Count return refs by hex of content, content not referenced is absent
+	Count(ctx context.Context, params *CountBlobRefsParams) (map[string]int64, error)
+	// Recalculate rebuild refs of repository from its objects, refs of repository created before refs were tracked are
+	// filled this way
+	Recalculate(ctx context.Context, repositoryID uuid.UUID) error
+}
+
+var _ IBlobRefRepo = (*BlobRefRepo)(nil)
+
+type BlobRefRepo struct {
+	db bun.IDB
+}
+
+func NewBlobRefRepo(db bun.IDB) IBlobRefRepo {
+	return &BlobRefRepo{db: db}
+}
+
+func (r *BlobRefRepo) Count(ctx context.Context, params *CountBlobRefsParams) (map[string]int64, error) {
+	counts := make(map[string]int64, len(params.checkSums))
+	for start := 0; start < len(params.checkSums); start += objectBatchSize {
+		// bun expand element of named slice type as a nested list, pass raw bytes instead
+		batch := make([][]byte, 0, objectBatchSize)
+		for _, checkSum := range params.checkSums[start:min(start+objectBatchSize, len(params.checkSums))] {
+			batch = append(batch, checkSum)
+		}
+
+		query := r.db.NewSelect().Model((*BlobRef)(nil)).
+			ColumnExpr("blob_ref.check_sum").
+			ColumnExpr("SUM(blob_ref.ref_count) AS ref_count").
+			Where("blob_ref.check_sum IN (?)", bun.In(batch)).
+			Where("blob_ref.ref_count > 0").
+			GroupExpr("blob_ref.check_sum")
+		if params.storageNamespace != nil {
+			// purged repositories have no row left, their refs no longer hold content
+			query = query.Join("JOIN repositories AS repository ON repository.id = blob_ref.repository_id").
+				Where("repository.storage_namespace = ?", *params.storageNamespace)
+		}
+		if params.repositoryID != nil {
+			query = query.Where("blob_ref.repository_id = ?", *params.repositoryID)
+		}
+		if params.excludeID != nil {
+			query = query.Where("blob_ref.repository_id <> ?", *params.excludeID)
+		}
+
+		var rows []struct {
+			CheckSum []byte `bun:"check_sum"`
+			RefCount int64  `bun:"ref_count"`
+		}
+		err := query.Scan(ctx, &rows)
+		if err != nil {
+			return nil, toModelError(err)
+		}
+		for _, row := range rows {
+			counts[hash.Hash(row.CheckSum).Hex()] += row.RefCount
+		}
+	}
+	return counts, nil
+}
+
+func (r *BlobRefRepo) Recalculate(ctx context.Context, repositoryID uuid.UUID) error {
+	_, err := r.db.NewDelete().Model((*BlobRef)(nil)).Where("repository_id = ?", repositoryID).Exec(ctx)
+	if err != nil {
+		return toModelError(err)
+	}
+
+	var rows []struct {
+		CheckSum []byte `bun:"check_sum"`
+		RefCount int64  `bun:"ref_count"`
+	}
+	err = r.db.NewSelect().Model((*FileTree)(nil)).
+		ColumnExpr("check_sum").
+		ColumnExpr("COUNT(*) AS ref_count").
+		Where("repository_id = ?", repositoryID).
+		Where("type = ?", BlobObject).
+		GroupExpr("check_sum").
+		Scan(ctx, &rows)
+	if err != nil {
+		return toModelError(err)
+	}
+
+	delta := blobRefDelta{}
+	for _, row := range rows {
+		delta.add(repositoryID, row.CheckSum, row.RefCount)
+	}
+	return addBlobRefs(ctx, r.db, delta)
+}
@@ -0,0 +1,95 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/models/filemode"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBlobRefRepo(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	makeRepo := func(name string) *models.Repository {
+		repoModel := &models.Repository{}
+		require.NoError(t, gofakeit.Struct(repoModel))
+		repoModel.Name = name
+		repoModel.StorageNamespace = utils.String("mem://shared")
+		newRepo, err := repo.RepositoryRepo().Insert(ctx, repoModel)
+		require.NoError(t, err)
+		return newRepo
+	}
+	makeBlob := func(repoID uuid.UUID, checkSum hash.Hash, mode filemode.FileMode) models.FileTree {
+		blob, err := models.NewBlob(models.Property{Mode: mode}, repoID, checkSum, 10)
+		require.NoError(t, err)
+		return *blob.FileTree()
+	}
+
+	upstream := makeRepo("upstream")
+	fork := makeRepo("fork")
+	shared := hash.Hash("shared content")
+	own := hash.Hash("own content")
+
+	// same content under different modes is referenced twice
+	_, err := repo.FileTreeRepo(upstream.ID).BatchInsert(ctx, []models.FileTree{
+		makeBlob(upstream.ID, shared, filemode.Regular),
+		makeBlob(upstream.ID, shared, filemode.Executable),
+	})
+	require.NoError(t, err)
+	ownBlob := makeBlob(upstream.ID, own, filemode.Regular)
+	_, err = repo.FileTreeRepo(upstream.ID).Insert(ctx, &ownBlob)
+	require.NoError(t, err)
+	_, err = repo.FileTreeRepo(fork.ID).BatchInsert(ctx, []models.FileTree{makeBlob(fork.ID, shared, filemode.Regular)})
+	require.NoError(t, err)
+
+	count := func(params *models.CountBlobRefsParams) map[string]int64 {
+		refs, err := repo.BlobRefRepo().Count(ctx, params.SetCheckSums(shared, own))
+		require.NoError(t, err)
+		return refs
+	}
+
+	refs := count(models.NewCountBlobRefsParams().SetStorageNamespace("mem://shared"))
+	require.Equal(t, map[string]int64{shared.Hex(): 3, own.Hex(): 1}, refs)
+
+	refs = count(models.NewCountBlobRefsParams().SetStorageNamespace("mem://shared").SetExcludeRepositoryID(upstream.ID))
+	require.Equal(t, map[string]int64{shared.Hex(): 1}, refs)
+
+	refs = count(models.NewCountBlobRefsParams().SetRepositoryID(upstream.ID))
+	require.Equal(t, map[string]int64{shared.Hex(): 2, own.Hex(): 1}, refs)
+
+	t.Run("delete release refs", func(t *testing.T) {
+		_, err := repo.FileTreeRepo(upstream.ID).Delete(ctx, models.NewDeleteTreeParams().SetHash(ownBlob.Hash))
+		require.NoError(t, err)
+
+		refs := count(models.NewCountBlobRefsParams().SetRepositoryID(upstream.ID))
+		require.Equal(t, map[string]int64{shared.Hex(): 2}, refs)
+	})
+
+	t.Run("recalculate", func(t *testing.T) {
+		_, err := db.NewDelete().Model((*models.BlobRef)(nil)).Where("repository_id = ?", upstream.ID).Exec(ctx)
+		require.NoError(t, err)
+		require.Empty(t, count(models.NewCountBlobRefsParams().SetRepositoryID(upstream.ID)))
+
+		require.NoError(t, repo.BlobRefRepo().Recalculate(ctx, upstream.ID))
+		refs := count(models.NewCountBlobRefsParams().SetRepositoryID(upstream.ID))
+		require.Equal(t, map[string]int64{shared.Hex(): 2}, refs)
+	})
+
+	t.Run("purged repository hold no ref", func(t *testing.T) {
+		_, err := repo.RepositoryRepo().Purge(ctx, models.NewDeleteRepoParams().SetID(fork.ID))
+		require.NoError(t, err)
+
+		refs := count(models.NewCountBlobRefsParams().SetStorageNamespace("mem://shared").SetExcludeRepositoryID(upstream.ID))
+		require.Empty(t, refs)
+	})
+}
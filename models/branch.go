@@ -2,6 +2,7 @@ package models
 
 import (
 	"context"
+	"fmt"
 	"time"
 
 	"github.com/GitDataAI/jiaozifs/utils/hash"
@@ -21,11 +22,43 @@ type Branch struct {
 	Description *string `bun:"description" json:"description,omitempty"`
 	// CreatorID who create this branch
 	CreatorID uuid.UUID `bun:"creator_id,type:uuid,notnull" json:"creator_id"`
+	// Version increased by every update, given to UpdateByID to reject update if branch changed since it was read
+	Version int64 `bun:"version,notnull,default:0" json:"version"`
+	// IsProtected protected branch can't be deleted or committed directly, it is only changed by merge requests
+	IsProtected bool `bun:"is_protected,notnull,default:false" json:"is_protected"`
+	// AllowForcePush allow head of protected branch to be replaced by commit not descend from it, such as rollback, commits and amends are still rejected
+	AllowForcePush bool `bun:"allow_force_push,notnull,default:false" json:"allow_force_push"`
+	// RequiredApprovals number of approvals merge request to this branch must get before merged
+	RequiredApprovals int `bun:"required_approvals,notnull,default:0" json:"required_approvals"`
 
 	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
 	UpdatedAt time.Time `bun:"updated_at,type:timestamp,notnull" json:"updated_at"`
 }
 
+// BranchProtection protection policy of branch
+type BranchProtection struct {
+	IsProtected       bool
+	AllowForcePush    bool
+	RequiredApprovals int
+}
+
+// Protection policy of branch
+func (branch *Branch) Protection() BranchProtection {
+	return BranchProtection{
+		IsProtected:       branch.IsProtected,
+		AllowForcePush:    branch.AllowForcePush,
+		RequiredApprovals: branch.RequiredApprovals,
+	}
+}
+
+// CheckForcePush return ErrBranchProtected if head of branch is not allowed to be replaced by commit not descend from it
+func (branch *Branch) CheckForcePush() error {
+	if branch.IsProtected && !branch.AllowForcePush {
+		return fmt.Errorf("force push to branch %s %w", branch.Name, ErrBranchProtected)
+	}
+	return nil
+}
+
 type GetBranchParams struct {
 	id           uuid.UUID
 	repositoryID uuid.UUID
@@ -78,6 +111,8 @@ func (gup *DeleteBranchParams) SetName(name string) *DeleteBranchParams {
 type UpdateBranchParams struct {
 	id         uuid.UUID
 	commitHash hash.Hash
+	name       *string
+	version    *int64
 }
 
 func NewUpdateBranchParams(id uuid.UUID) *UpdateBranchParams {
@@ -89,6 +124,18 @@ func (up *UpdateBranchParams) SetCommitHash(commitHash hash.Hash) *UpdateBranchP
 	return up
 }
 
+// SetName rename branch, wips of branch are kept as they are bound to id of branch
+func (up *UpdateBranchParams) SetName(name string) *UpdateBranchParams {
+	up.name = &name
+	return up
+}
+
+// SetVersion only update branch at version, see Branch.Version
+func (up *UpdateBranchParams) SetVersion(version int64) *UpdateBranchParams {
+	up.version = &version
+	return up
+}
+
 type ListBranchParams struct {
 	RepositoryID uuid.UUID
 	Name         *string
@@ -124,10 +171,17 @@ func (gup *ListBranchParams) SetAmount(amount int) *ListBranchParams {
 
 type IBranchRepo interface {
 	Insert(ctx context.Context, repo *Branch) (*Branch, error)
+	// UpdateByID update branch, return ErrStaleObject if version is set and branch not at the version any more
 	UpdateByID(ctx context.Context, params *UpdateBranchParams) error
+	// UpdateCommitHash move branch from oldHash to newHash, return ErrRefConflict if branch not point to oldHash any more
+	UpdateCommitHash(ctx context.Context, id uuid.UUID, oldHash, newHash hash.Hash) error
+	// UpdateProtection replace protection policy of branch, return ErrNotFound if branch not exist
+	UpdateProtection(ctx context.Context, id uuid.UUID, protection BranchProtection) error
 	Get(ctx context.Context, id *GetBranchParams) (*Branch, error)
 
 	List(ctx context.Context, params *ListBranchParams) ([]*Branch, bool, error)
+	// Count number of branches matching params, pagination of params is ignored
+	Count(ctx context.Context, params *ListBranchParams) (int, error)
 	Delete(ctx context.Context, params *DeleteBranchParams) (int64, error)
 }
 
@@ -144,7 +198,7 @@ func NewBranchRepo(db bun.IDB) IBranchRepo {
 func (r BranchRepo) Insert(ctx context.Context, branch *Branch) (*Branch, error) {
 	_, err := r.db.NewInsert().Model(branch).Exec(ctx)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
 	}
 	return branch, nil
 }
@@ -167,31 +221,14 @@ func (r BranchRepo) Get(ctx context.Context, params *GetBranchParams) (*Branch,
 
 	err := query.Limit(1).Scan(ctx)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
 	}
 	return repo, nil
 }
 
 func (r BranchRepo) List(ctx context.Context, params *ListBranchParams) ([]*Branch, bool, error) {
 	var branches []*Branch
-	query := r.db.NewSelect().Model(&branches)
-
-	if uuid.Nil != params.RepositoryID {
-		query = query.Where("repository_id = ?", params.RepositoryID)
-	}
-
-	if params.Name != nil {
-		switch params.NameMatch {
-		case ExactMatch:
-			query = query.Where("name = ?", *params.Name)
-		case PrefixMatch:
-			query = query.Where("name LIKE ?", *params.Name+"%")
-		case SuffixMatch:
-			query = query.Where("name LIKE ?", "%"+*params.Name)
-		case LikeMatch:
-			query = query.Where("name LIKE ?", "%"+*params.Name+"%")
-		}
-	}
+	query := r.listQuery(&branches, params)
 
 	query = query.Order("name ASC")
 	if params.After != nil {
@@ -199,7 +236,25 @@ func (r BranchRepo) List(ctx context.Context, params *ListBranchParams) ([]*Bran
 	}
 
 	err := query.Limit(params.Amount).Scan(ctx)
-	return branches, len(branches) == params.Amount, err
+	return branches, len(branches) == params.Amount, toModelError(err)
+}
+
+func (r BranchRepo) Count(ctx context.Context, params *ListBranchParams) (int, error) {
+	var branches []*Branch
+	return r.listQuery(&branches, params).Count(ctx)
+}
+
+func (r BranchRepo) listQuery(branches *[]*Branch, params *ListBranchParams) *bun.SelectQuery {
+	query := r.db.NewSelect().Model(branches)
+
+	if uuid.Nil != params.RepositoryID {
+		query = query.Where("repository_id = ?", params.RepositoryID)
+	}
+
+	if params.Name != nil {
+		query = params.NameMatch.apply(query, "name", *params.Name)
+	}
+	return query
 }
 
 func (r BranchRepo) Delete(ctx context.Context, params *DeleteBranchParams) (int64, error) {
@@ -219,7 +274,7 @@ func (r BranchRepo) Delete(ctx context.Context, params *DeleteBranchParams) (int
 
 	sqlResult, err := query.Exec(ctx)
 	if err != nil {
-		return 0, err
+		return 0, toModelError(err)
 	}
 	affectedRows, err := sqlResult.RowsAffected()
 	if err != nil {
@@ -233,6 +288,54 @@ func (r BranchRepo) UpdateByID(ctx context.Context, updateModel *UpdateBranchPar
 	if updateModel.commitHash != nil {
 		updateQuery.Set("commit_hash = ?", updateModel.commitHash)
 	}
-	_, err := updateQuery.Exec(ctx)
-	return err
+	if updateModel.name != nil {
+		updateQuery.Set("name = ?", *updateModel.name)
+	}
+	return execVersionedUpdate(ctx, updateQuery, updateModel.version)
+}
+
+func (r BranchRepo) UpdateProtection(ctx context.Context, id uuid.UUID, protection BranchProtection) error {
+	sqlResult, err := r.db.NewUpdate().Model((*Branch)(nil)).
+		Set("is_protected = ?", protection.IsProtected).
+		Set("allow_force_push = ?", protection.AllowForcePush).
+		Set("required_approvals = ?", protection.RequiredApprovals).
+		Set("version = version + 1").
+		Where("id = ?", id).
+		Exec(ctx)
+	if err != nil {
+		return toModelError(err)
+	}
+	affectedRows, err := sqlResult.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affectedRows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r BranchRepo) UpdateCommitHash(ctx context.Context, id uuid.UUID, oldHash, newHash hash.Hash) error {
+	updateQuery := r.db.NewUpdate().Model((*Branch)(nil)).
+		Set("commit_hash = ?", newHash).
+		Set("version = version + 1").
+		Where("id = ?", id)
+	if oldHash.IsEmpty() {
+		updateQuery.Where("(commit_hash IS NULL OR commit_hash = ?)", hash.Empty)
+	} else {
+		updateQuery.Where("commit_hash = ?", oldHash)
+	}
+
+	sqlResult, err := updateQuery.Exec(ctx)
+	if err != nil {
+		return toModelError(err)
+	}
+	affectedRows, err := sqlResult.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affectedRows == 0 {
+		return ErrRefConflict
+	}
+	return nil
 }
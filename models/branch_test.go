@@ -44,6 +44,13 @@ func TestRefRepoInsert(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, mockHash, branchAfterUpdated.CommitHash)
 
+	err = repo.UpdateByID(ctx, models.NewUpdateBranchParams(newBranch.ID).SetName("renamed"))
+	require.NoError(t, err)
+	branchAfterUpdated, err = repo.Get(ctx, models.NewGetBranchParams().SetID(newBranch.ID))
+	require.NoError(t, err)
+	require.Equal(t, "renamed", branchAfterUpdated.Name)
+	require.NoError(t, repo.UpdateByID(ctx, models.NewUpdateBranchParams(newBranch.ID).SetName(newBranch.Name)))
+
 	list, _, err := repo.List(ctx, models.NewListBranchParams().SetRepositoryID(branch.RepositoryID))
 	require.NoError(t, err)
 	require.Len(t, list, 1)
@@ -72,6 +79,16 @@ func TestRefRepoInsert(t *testing.T) {
 	require.Len(t, list1, 1)
 	require.True(t, hasMore)
 
+	// Count ignore pagination
+	count, err := repo.Count(ctx, models.NewListBranchParams().SetRepositoryID(branch.RepositoryID).SetName(secModel.Name, models.ExactMatch).SetAmount(1))
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	all, _, err := repo.List(ctx, models.NewListBranchParams().SetRepositoryID(branch.RepositoryID))
+	require.NoError(t, err)
+	count, err = repo.Count(ctx, models.NewListBranchParams().SetRepositoryID(branch.RepositoryID).SetAmount(1))
+	require.NoError(t, err)
+	require.Equal(t, len(all), count)
+
 	// PrefixMatch
 	list2, hasMore, err := repo.List(ctx, models.NewListBranchParams().SetRepositoryID(branch.RepositoryID).SetName(secModel.Name[:3], models.PrefixMatch).SetAmount(1))
 	require.NoError(t, err)
@@ -104,3 +121,70 @@ func TestRefRepoInsert(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, list6, 1)
 }
+
+func TestRefRepoUpdateCommitHash(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewBranchRepo(db)
+
+	branchModel := &models.Branch{}
+	require.NoError(t, gofakeit.Struct(branchModel))
+	branchModel.Name = "feat/cas"
+	branchModel.CommitHash = hash.Empty
+	newBranch, err := repo.Insert(ctx, branchModel)
+	require.NoError(t, err)
+
+	firstHash := hash.Hash("first hash")
+	err = repo.UpdateCommitHash(ctx, newBranch.ID, hash.Empty, firstHash)
+	require.NoError(t, err)
+
+	//old hash not match
+	err = repo.UpdateCommitHash(ctx, newBranch.ID, hash.Hash("other hash"), hash.Hash("second hash"))
+	require.ErrorIs(t, err, models.ErrRefConflict)
+
+	secondHash := hash.Hash("second hash")
+	err = repo.UpdateCommitHash(ctx, newBranch.ID, firstHash, secondHash)
+	require.NoError(t, err)
+
+	branch, err := repo.Get(ctx, models.NewGetBranchParams().SetID(newBranch.ID))
+	require.NoError(t, err)
+	require.Equal(t, secondHash, branch.CommitHash)
+	require.Equal(t, newBranch.Version+2, branch.Version)
+
+	//branch moved since version was read
+	err = repo.UpdateByID(ctx, models.NewUpdateBranchParams(newBranch.ID).SetCommitHash(firstHash).SetVersion(newBranch.Version))
+	require.ErrorIs(t, err, models.ErrStaleObject)
+
+	err = repo.UpdateByID(ctx, models.NewUpdateBranchParams(newBranch.ID).SetCommitHash(firstHash).SetVersion(branch.Version))
+	require.NoError(t, err)
+}
+
+func TestBranchRepoUpdateProtection(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewBranchRepo(db)
+
+	branchModel := &models.Branch{}
+	require.NoError(t, gofakeit.Struct(branchModel))
+	newBranch, err := repo.Insert(ctx, branchModel)
+	require.NoError(t, err)
+
+	protection := models.BranchProtection{IsProtected: true, RequiredApprovals: 2}
+	require.NoError(t, repo.UpdateProtection(ctx, newBranch.ID, protection))
+
+	branch, err := repo.Get(ctx, models.NewGetBranchParams().SetID(newBranch.ID))
+	require.NoError(t, err)
+	require.Equal(t, protection, branch.Protection())
+	require.ErrorIs(t, branch.CheckForcePush(), models.ErrBranchProtected)
+
+	require.NoError(t, repo.UpdateProtection(ctx, newBranch.ID, models.BranchProtection{IsProtected: true, AllowForcePush: true}))
+	branch, err = repo.Get(ctx, models.NewGetBranchParams().SetID(newBranch.ID))
+	require.NoError(t, err)
+	require.NoError(t, branch.CheckForcePush())
+
+	require.ErrorIs(t, repo.UpdateProtection(ctx, uuid.New(), protection), models.ErrNotFound)
+}
@@ -2,6 +2,8 @@ package models
 
 import (
 	"context"
+	"fmt"
+	"sort"
 	"time"
 
 	"github.com/GitDataAI/jiaozifs/utils/hash"
@@ -38,13 +40,20 @@ type Commit struct {
 	TreeHash hash.Hash `bun:"tree_hash,type:bytea,notnull" json:"tree_hash"`
 	// ParentHashes are the hashes of the parent commits of the commit.
 	ParentHashes []hash.Hash `bun:"parent_hashes,type:bytea[]" json:"parent_hashes"`
+	// Metadata is arbitrary key/value set by caller, like id of pipeline run or data lineage tags.
+	Metadata map[string]string `bun:"metadata,type:jsonb" json:"metadata,omitempty"`
 
 	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
 	UpdatedAt time.Time `bun:"updated_at,type:timestamp,notnull" json:"updated_at"`
 }
 
 func (commit *Commit) GetHash() (hash.Hash, error) {
-	hasher := hash.NewHasher(hash.Md5)
+	return commit.GetHashWithType(hash.Md5)
+}
+
+// GetHashWithType calculate hash of commit by hashType
+func (commit *Commit) GetHashWithType(hashType hash.HashType) (hash.Hash, error) {
+	hasher := hash.NewHasher(hashType)
 	err := hasher.WriteInt8(int8(CommitObject))
 	if err != nil {
 		return nil, err
@@ -101,7 +110,35 @@ func (commit *Commit) GetHash() (hash.Hash, error) {
 		}
 	}
 
-	return hasher.Md5.Sum(nil), nil
+	// metadata is hashed only when present to keep hash of commits without metadata unchanged
+	keys := make([]string, 0, len(commit.Metadata))
+	for k := range commit.Metadata {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		err = hasher.WriteString(k)
+		if err != nil {
+			return nil, err
+		}
+		err = hasher.WriteString(commit.Metadata[k])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return hasher.Sum(hashType), nil
+}
+
+// MatchMetadata check whether commit metadata contains all key/value of filter
+func (commit *Commit) MatchMetadata(filter map[string]string) bool {
+	for k, v := range filter {
+		value, ok := commit.Metadata[k]
+		if !ok || value != v {
+			return false
+		}
+	}
+	return true
 }
 
 func (commit *Commit) NumParents() int {
@@ -124,8 +161,16 @@ func (params *DeleteParams) SetHash(hash hash.Hash) *DeleteParams {
 type ICommitRepo interface {
 	RepositoryID() uuid.UUID
 	Commit(ctx context.Context, hash hash.Hash) (*Commit, error)
+	// ListByHashes get commits of hashes in order of hashes, ErrNotFound is returned if any of them not exist
+	ListByHashes(ctx context.Context, hashes []hash.Hash) ([]*Commit, error)
 	Insert(ctx context.Context, commit *Commit) (*Commit, error)
+	List(ctx context.Context) ([]*Commit, error)
+	// Count number of commits in repository
+	Count(ctx context.Context) (int, error)
 	Delete(ctx context.Context, params *DeleteParams) (int64, error)
+	// InsertStats save changes summary of commit
+	InsertStats(ctx context.Context, stats *CommitStats) error
+	Stats(ctx context.Context, hash hash.Hash) (*CommitStats, error)
 }
 type CommitRepo struct {
 	db           bun.IDB
@@ -149,22 +194,73 @@ func (cr CommitRepo) Commit(ctx context.Context, hash hash.Hash) (*Commit, error
 		Where("repository_id = ?", cr.repositoryID).
 		Where("hash = ?", hash).Scan(ctx)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
 	}
 	return commit, nil
 }
 
+func (cr CommitRepo) ListByHashes(ctx context.Context, hashes []hash.Hash) ([]*Commit, error) {
+	found := make(map[string]*Commit, len(hashes))
+	for start := 0; start < len(hashes); start += objectBatchSize {
+		// bun expand element of named slice type as a nested list, pass raw bytes instead
+		batch := make([][]byte, 0, objectBatchSize)
+		for _, h := range hashes[start:min(start+objectBatchSize, len(hashes))] {
+			batch = append(batch, h)
+		}
+		var commits []*Commit
+		err := cr.db.NewSelect().Model(&commits).
+			Where("repository_id = ?", cr.repositoryID).
+			Where("hash IN (?)", bun.In(batch)).
+			Scan(ctx)
+		if err != nil {
+			return nil, toModelError(err)
+		}
+		for _, commit := range commits {
+			found[commit.Hash.Hex()] = commit
+		}
+	}
+
+	result := make([]*Commit, len(hashes))
+	for i, h := range hashes {
+		commit, ok := found[h.Hex()]
+		if !ok {
+			return nil, fmt.Errorf("commit %s %w", h.Hex(), ErrNotFound)
+		}
+		result[i] = commit
+	}
+	return result, nil
+}
+
 func (cr CommitRepo) Insert(ctx context.Context, commit *Commit) (*Commit, error) {
 	if commit.RepositoryID != cr.repositoryID {
 		return nil, ErrRepoIDMisMatch
 	}
 	_, err := cr.db.NewInsert().Model(commit).Exec(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	err = addRepoStats(ctx, cr.db, cr.repositoryID, 0, 0, 1)
 	if err != nil {
 		return nil, err
 	}
 	return commit, nil
 }
 
+func (cr CommitRepo) List(ctx context.Context) ([]*Commit, error) {
+	var commits []*Commit
+	err := cr.db.NewSelect().Model(&commits).
+		Where("repository_id = ?", cr.repositoryID).
+		Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return commits, nil
+}
+
+func (cr CommitRepo) Count(ctx context.Context) (int, error) {
+	return cr.db.NewSelect().Model((*Commit)(nil)).Where("repository_id = ?", cr.repositoryID).Count(ctx)
+}
+
 func (cr CommitRepo) Delete(ctx context.Context, params *DeleteParams) (int64, error) {
 	query := cr.db.NewDelete().Model((*Commit)(nil)).Where("repository_id = ?", cr.repositoryID)
 	if params.hash != nil {
@@ -173,11 +269,20 @@ func (cr CommitRepo) Delete(ctx context.Context, params *DeleteParams) (int64, e
 
 	sqlResult, err := query.Exec(ctx)
 	if err != nil {
-		return 0, err
+		return 0, toModelError(err)
 	}
 	affectedRows, err := sqlResult.RowsAffected()
 	if err != nil {
 		return 0, err
 	}
-	return affectedRows, err
+
+	statsQuery := cr.db.NewDelete().Model((*CommitStats)(nil)).Where("repository_id = ?", cr.repositoryID)
+	if params.hash != nil {
+		statsQuery = statsQuery.Where("commit_hash = ?", params.hash)
+	}
+	_, err = statsQuery.Exec(ctx)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+	return affectedRows, addRepoStats(ctx, cr.db, cr.repositoryID, 0, 0, -affectedRows)
 }
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// CommitStats summary of changes of commit against its first parent, computed once when commit is created so history
+// can be shown without diffing trees again. a modified file counts its old size as removed and new size as added
+type CommitStats struct {
+	bun.BaseModel `bun:"table:commit_stats"`
+	RepositoryID  uuid.UUID `bun:"repository_id,pk,type:uuid" json:"repository_id"`
+	CommitHash    hash.Hash `bun:"commit_hash,pk,type:bytea" json:"commit_hash"`
+
+	FilesAdded    int64 `bun:"files_added,notnull,default:0" json:"files_added"`
+	FilesRemoved  int64 `bun:"files_removed,notnull,default:0" json:"files_removed"`
+	FilesModified int64 `bun:"files_modified,notnull,default:0" json:"files_modified"`
+	BytesAdded    int64 `bun:"bytes_added,notnull,default:0" json:"bytes_added"`
+	BytesRemoved  int64 `bun:"bytes_removed,notnull,default:0" json:"bytes_removed"`
+
+	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+}
+
+// InsertStats save stats of commit, stats already saved are kept as commit of the same hash has the same changes
+func (cr CommitRepo) InsertStats(ctx context.Context, stats *CommitStats) error {
+	if stats.RepositoryID != cr.repositoryID {
+		return ErrRepoIDMisMatch
+	}
+	_, err := cr.db.NewInsert().Model(stats).On("CONFLICT DO NOTHING").Exec(ctx)
+	return toModelError(err)
+}
+
+// Stats get stats of commit, ErrNotFound is returned for commits created before stats were introduced
+func (cr CommitRepo) Stats(ctx context.Context, hash hash.Hash) (*CommitStats, error) {
+	stats := &CommitStats{}
+	err := cr.db.NewSelect().Model(stats).
+		Where("repository_id = ?", cr.repositoryID).
+		Where("commit_hash = ?", hash).
+		Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return stats, nil
+}
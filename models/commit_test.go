@@ -6,6 +6,7 @@ import (
 
 	"github.com/GitDataAI/jiaozifs/models"
 	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/uuid"
@@ -31,6 +32,35 @@ func TestCommitRepo(t *testing.T) {
 
 	require.True(t, cmp.Equal(commitModel, newCommitModel, testhelper.DBTimeCmpOpt))
 
+	t.Run("list", func(t *testing.T) {
+		commits, err := commitRepo.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, commits, 1)
+		require.Equal(t, newCommitModel.Hash, commits[0].Hash)
+
+		count, err := commitRepo.Count(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+	})
+
+	t.Run("list by hashes", func(t *testing.T) {
+		otherModel := &models.Commit{}
+		require.NoError(t, gofakeit.Struct(otherModel))
+		otherModel.RepositoryID = repoID
+		_, err := commitRepo.Insert(ctx, otherModel)
+		require.NoError(t, err)
+
+		commits, err := commitRepo.ListByHashes(ctx, []hash.Hash{otherModel.Hash, newCommitModel.Hash, otherModel.Hash})
+		require.NoError(t, err)
+		require.Len(t, commits, 3)
+		require.Equal(t, otherModel.Hash, commits[0].Hash)
+		require.Equal(t, newCommitModel.Hash, commits[1].Hash)
+		require.Equal(t, otherModel.Hash, commits[2].Hash)
+
+		_, err = models.NewCommitRepo(db, uuid.New()).ListByHashes(ctx, []hash.Hash{newCommitModel.Hash})
+		require.ErrorIs(t, err, models.ErrNotFound)
+	})
+
 	t.Run("mis match repo id", func(t *testing.T) {
 		mistMatchModel := &models.Commit{}
 		require.NoError(t, gofakeit.Struct(mistMatchModel))
@@ -75,3 +105,32 @@ func TestDeleteCommit(t *testing.T) {
 		require.Equal(t, int64(5), affectRows)
 	})
 }
+
+func TestCommitMetadata(t *testing.T) {
+	commit := &models.Commit{}
+	require.NoError(t, gofakeit.Struct(commit))
+	commit.Metadata = nil
+	noMetadataHash, err := commit.GetHash()
+	require.NoError(t, err)
+
+	commit.Metadata = map[string]string{}
+	emptyMetadataHash, err := commit.GetHash()
+	require.NoError(t, err)
+	require.Equal(t, noMetadataHash, emptyMetadataHash)
+
+	commit.Metadata = map[string]string{"run_id": "42", "lineage": "raw"}
+	metadataHash, err := commit.GetHash()
+	require.NoError(t, err)
+	require.NotEqual(t, noMetadataHash, metadataHash)
+
+	for i := 0; i < 10; i++ {
+		sameHash, err := commit.GetHash()
+		require.NoError(t, err)
+		require.Equal(t, metadataHash, sameHash)
+	}
+
+	require.True(t, commit.MatchMetadata(nil))
+	require.True(t, commit.MatchMetadata(map[string]string{"run_id": "42"}))
+	require.False(t, commit.MatchMetadata(map[string]string{"run_id": "43"}))
+	require.False(t, commit.MatchMetadata(map[string]string{"job": "42"}))
+}
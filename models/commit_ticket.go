@@ -0,0 +1,205 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// CommitTicketState progress of an async commit
+type CommitTicketState string
+
+const (
+	CommitTicketPending CommitTicketState = "pending"
+	CommitTicketRunning CommitTicketState = "running"
+	CommitTicketDone    CommitTicketState = "done"
+	CommitTicketFailed  CommitTicketState = "failed"
+)
+
+// CommitTicket commit of wip accepted by api and made by background worker, so writers need not wait for tree building.
+// tickets of the same branch are committed in order they are created
+type CommitTicket struct {
+	bun.BaseModel `bun:"table:commit_tickets"`
+	ID            uuid.UUID `bun:"id,pk,type:uuid,default:uuid_generate_v4()" json:"id"`
+	RepositoryID  uuid.UUID `bun:"repository_id,type:uuid,notnull" json:"repository_id"`
+	CreatorID     uuid.UUID `bun:"creator_id,type:uuid,notnull" json:"creator_id"`
+	// RefName branch whose wip of creator is committed
+	RefName string `bun:"ref_name,notnull" json:"ref_name"`
+	Message string `bun:"message" json:"message"`
+	// MessageVars variables of commit message template, like job_id
+	MessageVars map[string]string `bun:"message_vars,type:jsonb" json:"message_vars,omitempty"`
+	Metadata    map[string]string `bun:"metadata,type:jsonb" json:"metadata,omitempty"`
+	Amend       bool              `bun:"amend,notnull" json:"amend"`
+
+	State CommitTicketState `bun:"state,notnull" json:"state"`
+	// CommitHash commit made for ticket, set when ticket is done
+	CommitHash hash.Hash `bun:"commit_hash,type:bytea" json:"commit_hash"`
+	// Error why commit failed
+	Error string `bun:"error" json:"error"`
+
+	CreatedAt  time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+	UpdatedAt  time.Time `bun:"updated_at,type:timestamp,notnull" json:"updated_at"`
+	FinishedAt time.Time `bun:"finished_at,type:timestamp,nullzero" json:"finished_at"`
+}
+
+// NewCommitTicket pending ticket committing wip of creator on branch refName
+func NewCommitTicket(repositoryID, creatorID uuid.UUID, refName, message string) *CommitTicket {
+	now := time.Now()
+	return &CommitTicket{
+		RepositoryID: repositoryID,
+		CreatorID:    creatorID,
+		RefName:      refName,
+		Message:      message,
+		State:        CommitTicketPending,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
+type GetCommitTicketParams struct {
+	id           uuid.UUID
+	repositoryID uuid.UUID
+	creatorID    uuid.UUID
+	refName      *string
+	unfinished   bool
+}
+
+func NewGetCommitTicketParams() *GetCommitTicketParams {
+	return &GetCommitTicketParams{}
+}
+
+func (gp *GetCommitTicketParams) SetID(id uuid.UUID) *GetCommitTicketParams {
+	gp.id = id
+	return gp
+}
+
+func (gp *GetCommitTicketParams) SetRepositoryID(repositoryID uuid.UUID) *GetCommitTicketParams {
+	gp.repositoryID = repositoryID
+	return gp
+}
+
+func (gp *GetCommitTicketParams) SetCreatorID(creatorID uuid.UUID) *GetCommitTicketParams {
+	gp.creatorID = creatorID
+	return gp
+}
+
+func (gp *GetCommitTicketParams) SetRefName(refName string) *GetCommitTicketParams {
+	gp.refName = &refName
+	return gp
+}
+
+// SetUnfinished get ticket pending or running only
+func (gp *GetCommitTicketParams) SetUnfinished() *GetCommitTicketParams {
+	gp.unfinished = true
+	return gp
+}
+
+type UpdateCommitTicketParams struct {
+	id         uuid.UUID
+	state      *CommitTicketState
+	commitHash hash.Hash
+	errorMsg   *string
+}
+
+func NewUpdateCommitTicketParams(id uuid.UUID) *UpdateCommitTicketParams {
+	return &UpdateCommitTicketParams{id: id}
+}
+
+func (up *UpdateCommitTicketParams) SetState(state CommitTicketState) *UpdateCommitTicketParams {
+	up.state = &state
+	return up
+}
+
+func (up *UpdateCommitTicketParams) SetCommitHash(commitHash hash.Hash) *UpdateCommitTicketParams {
+	up.commitHash = commitHash
+	return up
+}
+
+func (up *UpdateCommitTicketParams) SetError(errorMsg string) *UpdateCommitTicketParams {
+	up.errorMsg = &errorMsg
+	return up
+}
+
+type ICommitTicketRepo interface {
+	Insert(ctx context.Context, ticket *CommitTicket) (*CommitTicket, error)
+	Get(ctx context.Context, params *GetCommitTicketParams) (*CommitTicket, error)
+	// ListUnfinished tickets pending or interrupted while running from the oldest
+	ListUnfinished(ctx context.Context) ([]*CommitTicket, error)
+	Update(ctx context.Context, params *UpdateCommitTicketParams) error
+}
+
+var _ ICommitTicketRepo = (*CommitTicketRepo)(nil)
+
+type CommitTicketRepo struct {
+	db bun.IDB
+}
+
+func NewCommitTicketRepo(db bun.IDB) ICommitTicketRepo {
+	return &CommitTicketRepo{db: db}
+}
+
+func (r *CommitTicketRepo) Insert(ctx context.Context, ticket *CommitTicket) (*CommitTicket, error) {
+	_, err := r.db.NewInsert().Model(ticket).Exec(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return ticket, nil
+}
+
+func (r *CommitTicketRepo) Get(ctx context.Context, params *GetCommitTicketParams) (*CommitTicket, error) {
+	ticket := &CommitTicket{}
+	query := r.db.NewSelect().Model(ticket)
+	if params.id != uuid.Nil {
+		query = query.Where("id = ?", params.id)
+	}
+	if params.repositoryID != uuid.Nil {
+		query = query.Where("repository_id = ?", params.repositoryID)
+	}
+	if params.creatorID != uuid.Nil {
+		query = query.Where("creator_id = ?", params.creatorID)
+	}
+	if params.refName != nil {
+		query = query.Where("ref_name = ?", *params.refName)
+	}
+	if params.unfinished {
+		query = query.Where("state IN (?)", bun.In([]CommitTicketState{CommitTicketPending, CommitTicketRunning}))
+	}
+	err := query.Limit(1).Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return ticket, nil
+}
+
+func (r *CommitTicketRepo) ListUnfinished(ctx context.Context) ([]*CommitTicket, error) {
+	var tickets []*CommitTicket
+	err := r.db.NewSelect().Model(&tickets).
+		Where("state IN (?)", bun.In([]CommitTicketState{CommitTicketPending, CommitTicketRunning})).
+		Order("created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return tickets, nil
+}
+
+func (r *CommitTicketRepo) Update(ctx context.Context, params *UpdateCommitTicketParams) error {
+	query := r.db.NewUpdate().Model((*CommitTicket)(nil)).Where("id = ?", params.id)
+	if params.state != nil {
+		query = query.Set("state = ?", *params.state)
+		if *params.state == CommitTicketDone || *params.state == CommitTicketFailed {
+			query = query.Set("finished_at = ?", time.Now())
+		}
+	}
+	if params.commitHash != nil {
+		query = query.Set("commit_hash = ?", params.commitHash)
+	}
+	if params.errorMsg != nil {
+		query = query.Set("error = ?", *params.errorMsg)
+	}
+	_, err := query.Set("updated_at = ?", time.Now()).Exec(ctx)
+	return toModelError(err)
+}
@@ -0,0 +1,63 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitTicketRepo(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewCommitTicketRepo(db)
+	repositoryID := uuid.New()
+	creatorID := uuid.New()
+
+	ticket := models.NewCommitTicket(repositoryID, creatorID, "main", "commit")
+	ticket.Metadata = map[string]string{"run_id": "42"}
+	ticket, err := repo.Insert(ctx, ticket)
+	require.NoError(t, err)
+	_, err = repo.Insert(ctx, models.NewCommitTicket(repositoryID, creatorID, "feat", "commit"))
+	require.NoError(t, err)
+
+	t.Run("get", func(t *testing.T) {
+		got, err := repo.Get(ctx, models.NewGetCommitTicketParams().SetID(ticket.ID).SetRepositoryID(repositoryID).SetCreatorID(creatorID))
+		require.NoError(t, err)
+		require.Equal(t, models.CommitTicketPending, got.State)
+		require.Equal(t, "42", got.Metadata["run_id"])
+
+		_, err = repo.Get(ctx, models.NewGetCommitTicketParams().SetID(ticket.ID).SetCreatorID(uuid.New()))
+		require.ErrorIs(t, err, models.ErrNotFound)
+
+		unfinished, err := repo.Get(ctx, models.NewGetCommitTicketParams().SetRepositoryID(repositoryID).SetCreatorID(creatorID).SetRefName("main").SetUnfinished())
+		require.NoError(t, err)
+		require.Equal(t, ticket.ID, unfinished.ID)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		commitHash := hash.Hash("commit hash")
+		err := repo.Update(ctx, models.NewUpdateCommitTicketParams(ticket.ID).SetState(models.CommitTicketDone).SetCommitHash(commitHash))
+		require.NoError(t, err)
+
+		got, err := repo.Get(ctx, models.NewGetCommitTicketParams().SetID(ticket.ID))
+		require.NoError(t, err)
+		require.Equal(t, models.CommitTicketDone, got.State)
+		require.Equal(t, commitHash.Hex(), got.CommitHash.Hex())
+		require.False(t, got.FinishedAt.IsZero())
+
+		_, err = repo.Get(ctx, models.NewGetCommitTicketParams().SetRepositoryID(repositoryID).SetRefName("main").SetUnfinished())
+		require.ErrorIs(t, err, models.ErrNotFound)
+
+		tickets, err := repo.ListUnfinished(ctx)
+		require.NoError(t, err)
+		require.Len(t, tickets, 1)
+		require.Equal(t, "feat", tickets[0].RefName)
+	})
+}
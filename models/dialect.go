@@ -0,0 +1,20 @@
+package models
+
+import (
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+// isPostgres check whether db is postgres, features like row lock and advisory lock are missing in sqlite where
+// writes are serialized anyway
+func isPostgres(db bun.IDB) bool {
+	return db.Dialect().Name() == dialect.PG
+}
+
+// forUpdate lock selected rows until transaction end
+func forUpdate(db bun.IDB, query *bun.SelectQuery) *bun.SelectQuery {
+	if isPostgres(db) {
+		return query.For("UPDATE")
+	}
+	return query
+}
@@ -2,6 +2,89 @@ package models
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/uptrace/bun/driver/pgdriver"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
 )
 
 var ErrNotFound = sql.ErrNoRows
+
+// ErrAlreadyExists object violate unique constraint, such as name of repository or branch is taken
+var ErrAlreadyExists = errors.New("object already exists")
+
+// ErrForeignKey object refer to another object not exist, or is still referred by others
+var ErrForeignKey = errors.New("object refer to missing object or is referred by others")
+
+// ErrInvalidValue value of object violate not null or check constraint, or not fit type of column
+var ErrInvalidValue = errors.New("invalid value of object")
+
+// ErrRefConflict ref has been moved by others since it was read
+var ErrRefConflict = errors.New("ref has been updated by others, please retry")
+
+// ErrStaleObject object has been updated by others since the version given to update was read
+var ErrStaleObject = errors.New("object has been updated by others, please reload and retry")
+
+// ErrBranchProtected operation not allowed by protection policy of branch
+var ErrBranchProtected = errors.New("branch is protected")
+
+// ErrRepositoryArchived mutating operation on archived repository which is read only
+var ErrRepositoryArchived = errors.New("repository is archived")
+
+// ErrCommitTooLarge commit changes more files or bytes than limits of server
+var ErrCommitTooLarge = errors.New("commit is too large, split changes into smaller commits or use bulk import")
+
+// ErrQuotaExceeded write would take repository over its storage quota
+var ErrQuotaExceeded = errors.New("storage quota of repository exceeded, remove data or ask admin to raise quota")
+
+// ErrAccessTokenDenied access token is expired or does not grant access asked for
+var ErrAccessTokenDenied = errors.New("access denied by access token")
+
+// ErrMergeRuleUnmet merge request has not got approvals or checked items required by merge rules of target branch
+var ErrMergeRuleUnmet = errors.New("merge rule of target branch is not met")
+
+// ErrHookRejected commit or merge rejected by a hook of repository
+var ErrHookRejected = errors.New("rejected by hook of repository")
+
+// ErrDatabaseUnavailable statement timed out or kept failing by transient errors after retries
+var ErrDatabaseUnavailable = errors.New("database is temporarily unavailable, please retry later")
+
+// toModelError map constraint errors of postgres and sqlite to errors of models, so callers can branch on them
+// without knowing database in use. driver error is kept in chain of returned error
+func toModelError(err error) error {
+	if err == nil || errors.Is(err, ErrAlreadyExists) || errors.Is(err, ErrForeignKey) || errors.Is(err, ErrInvalidValue) {
+		return err
+	}
+
+	var modelErr error
+	var pgErr pgdriver.Error
+	var sqliteErr *sqlite.Error
+	switch {
+	case errors.As(err, &pgErr):
+		code := pgErr.Field('C')
+		switch {
+		case code == "23505": // unique_violation
+			modelErr = ErrAlreadyExists
+		case code == "23503": // foreign_key_violation
+			modelErr = ErrForeignKey
+		case code == "23502", code == "23514", strings.HasPrefix(code, "22"): // not_null_violation, check_violation, data_exception
+			modelErr = ErrInvalidValue
+		}
+	case errors.As(err, &sqliteErr):
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			modelErr = ErrAlreadyExists
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			modelErr = ErrForeignKey
+		case sqlite3.SQLITE_CONSTRAINT_NOTNULL, sqlite3.SQLITE_CONSTRAINT_CHECK, sqlite3.SQLITE_CONSTRAINT_DATATYPE:
+			modelErr = ErrInvalidValue
+		}
+	}
+	if modelErr == nil {
+		return err
+	}
+	return fmt.Errorf("%w %w", modelErr, err)
+}
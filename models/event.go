@@ -0,0 +1,152 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// EventType kind of domain event
+type EventType string
+
+const (
+	RepositoryCreatedEvent  EventType = "repository.created"
+	RepositoryDeletedEvent  EventType = "repository.deleted"
+	RepositoryRestoredEvent EventType = "repository.restored"
+	RepositoryPurgedEvent   EventType = "repository.purged"
+	RepositoryRenamedEvent  EventType = "repository.renamed"
+	// RepositoryForkedEvent repository was forked, recorded on upstream repository
+	RepositoryForkedEvent EventType = "repository.forked"
+	// RepositoryRolledBackEvent branches and tags of repository were rolled back to their state at a past time
+	RepositoryRolledBackEvent EventType = "repository.rolled_back"
+	RepositoryArchivedEvent   EventType = "repository.archived"
+	RepositoryUnarchivedEvent EventType = "repository.unarchived"
+	// RepositoryQuotaWarningEvent usage of repository crossed a warning threshold of its storage quota
+	RepositoryQuotaWarningEvent EventType = "repository.quota_warning"
+
+	CommitCreatedEvent EventType = "commit.created"
+
+	BranchCreatedEvent EventType = "branch.created"
+	BranchUpdatedEvent EventType = "branch.updated"
+	BranchDeletedEvent EventType = "branch.deleted"
+
+	TagCreatedEvent EventType = "tag.created"
+	TagDeletedEvent EventType = "tag.deleted"
+
+	MemberAddedEvent   EventType = "member.added"
+	MemberUpdatedEvent EventType = "member.updated"
+	MemberRemovedEvent EventType = "member.removed"
+
+	// StorageCredentialsIssuedEvent scoped storage credentials issued to operator, for auditing direct storage access
+	StorageCredentialsIssuedEvent EventType = "storage.credentials_issued"
+)
+
+// eventLockKey advisory lock key taken by every event writer, see EventRepo.Insert
+const eventLockKey = 0x6576656e7473 // "events"
+
+// Event is a record in append only event log, Seq is strictly increasing in the order events were committed,
+// so consumer can use the last seen Seq as cursor to read new events without missing any
+type Event struct {
+	bun.BaseModel `bun:"table:events"`
+	Seq           int64             `bun:"seq,pk,autoincrement" json:"seq"`
+	Type          EventType         `bun:"type,notnull" json:"type"`
+	RepositoryID  uuid.UUID         `bun:"repository_id,type:uuid,notnull" json:"repository_id"`
+	ActorID       uuid.UUID         `bun:"actor_id,type:uuid" json:"actor_id"`
+	Payload       map[string]string `bun:"payload,type:jsonb" json:"payload"`
+
+	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+}
+
+func NewEvent(eventType EventType, repositoryID, actorID uuid.UUID, payload map[string]string) *Event {
+	return &Event{
+		Type:         eventType,
+		RepositoryID: repositoryID,
+		ActorID:      actorID,
+		Payload:      payload,
+		CreatedAt:    time.Now(),
+	}
+}
+
+type ListEventParams struct {
+	repositoryID uuid.UUID
+	after        int64
+	createdAfter *time.Time
+	amount       int
+}
+
+func NewListEventParams() *ListEventParams {
+	return &ListEventParams{}
+}
+
+func (lep *ListEventParams) SetRepositoryID(repositoryID uuid.UUID) *ListEventParams {
+	lep.repositoryID = repositoryID
+	return lep
+}
+
+// SetAfter only return events with seq greater than after
+func (lep *ListEventParams) SetAfter(after int64) *ListEventParams {
+	lep.after = after
+	return lep
+}
+
+// SetCreatedAfter only return events created later than t
+func (lep *ListEventParams) SetCreatedAfter(t time.Time) *ListEventParams {
+	lep.createdAfter = &t
+	return lep
+}
+
+func (lep *ListEventParams) SetAmount(amount int) *ListEventParams {
+	lep.amount = amount
+	return lep
+}
+
+type IEventRepo interface {
+	// Insert append event to log, should be called in the same transaction with the change it describes
+	Insert(ctx context.Context, event *Event) (*Event, error)
+	// List events in seq order
+	List(ctx context.Context, params *ListEventParams) ([]*Event, bool, error)
+}
+
+var _ IEventRepo = (*EventRepo)(nil)
+
+type EventRepo struct {
+	db bun.IDB
+}
+
+func NewEventRepo(db bun.IDB) IEventRepo {
+	return &EventRepo{db: db}
+}
+
+// Insert take a transaction level lock before insert. sequence value is allocated when inserted but visible when committed,
+// without the lock a smaller seq may become visible after a bigger one was consumed and get lost by consumer
+func (e *EventRepo) Insert(ctx context.Context, event *Event) (*Event, error) {
+	if isPostgres(e.db) {
+		_, err := e.db.ExecContext(ctx, "SELECT pg_advisory_xact_lock(?)", eventLockKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	_, err := e.db.NewInsert().Model(event).Returning("seq").Exec(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return event, nil
+}
+
+func (e *EventRepo) List(ctx context.Context, params *ListEventParams) ([]*Event, bool, error) {
+	var events []*Event
+	query := e.db.NewSelect().Model(&events).Where("seq > ?", params.after)
+
+	if uuid.Nil != params.repositoryID {
+		query = query.Where("repository_id = ?", params.repositoryID)
+	}
+	if params.createdAfter != nil {
+		query = query.Where("created_at > ?", *params.createdAfter)
+	}
+
+	err := query.Order("seq ASC").Limit(params.amount).Scan(ctx)
+	return events, len(events) == params.amount, toModelError(err)
+}
@@ -0,0 +1,62 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEventRepo(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewEventRepo(db)
+	repositoryID := uuid.New()
+	actorID := uuid.New()
+
+	var seqs []int64
+	for i := 0; i < 5; i++ {
+		event, err := repo.Insert(ctx, models.NewEvent(models.CommitCreatedEvent, repositoryID, actorID, map[string]string{"branch": "main"}))
+		require.NoError(t, err)
+		seqs = append(seqs, event.Seq)
+	}
+	_, err := repo.Insert(ctx, models.NewEvent(models.TagCreatedEvent, uuid.New(), actorID, nil))
+	require.NoError(t, err)
+
+	for i := 1; i < len(seqs); i++ {
+		require.Greater(t, seqs[i], seqs[i-1])
+	}
+
+	t.Run("list from beginning", func(t *testing.T) {
+		events, hasMore, err := repo.List(ctx, models.NewListEventParams().SetRepositoryID(repositoryID).SetAmount(3))
+		require.NoError(t, err)
+		require.True(t, hasMore)
+		require.Len(t, events, 3)
+		require.Equal(t, seqs[0], events[0].Seq)
+		require.Equal(t, "main", events[0].Payload["branch"])
+	})
+
+	t.Run("list after cursor", func(t *testing.T) {
+		events, _, err := repo.List(ctx, models.NewListEventParams().SetRepositoryID(repositoryID).SetAfter(seqs[2]).SetAmount(10))
+		require.NoError(t, err)
+		require.Len(t, events, 2)
+		require.Equal(t, seqs[3], events[0].Seq)
+		require.Equal(t, seqs[4], events[1].Seq)
+	})
+
+	t.Run("list created after time", func(t *testing.T) {
+		events, _, err := repo.List(ctx, models.NewListEventParams().SetRepositoryID(repositoryID).SetCreatedAfter(time.Now().Add(-time.Hour)).SetAmount(10))
+		require.NoError(t, err)
+		require.Len(t, events, 5)
+
+		events, _, err = repo.List(ctx, models.NewListEventParams().SetRepositoryID(repositoryID).SetCreatedAfter(time.Now().Add(time.Hour)).SetAmount(10))
+		require.NoError(t, err)
+		require.Empty(t, events)
+	})
+}
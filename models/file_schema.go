@@ -0,0 +1,95 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/utils/tabular"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// FileSchema columns of a tabular file version. schema only depends on content and format, it is keyed by checksum of
+// blob so versions sharing content share schema and schemas survive hash migration of the repository
+type FileSchema struct {
+	bun.BaseModel `bun:"table:file_schemas"`
+	RepositoryID  uuid.UUID        `bun:"repository_id,pk,type:uuid,notnull" json:"repository_id"`
+	CheckSum      hash.Hash        `bun:"check_sum,pk,type:bytea" json:"check_sum"`
+	Format        string           `bun:"format,pk" json:"format"`
+	Columns       []tabular.Column `bun:"columns,type:jsonb" json:"columns"`
+
+	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+}
+
+type GetFileSchemaParams struct {
+	repositoryID uuid.UUID
+	checkSum     hash.Hash
+	format       string
+}
+
+func NewGetFileSchemaParams() *GetFileSchemaParams {
+	return &GetFileSchemaParams{}
+}
+
+func (gp *GetFileSchemaParams) SetRepositoryID(repositoryID uuid.UUID) *GetFileSchemaParams {
+	gp.repositoryID = repositoryID
+	return gp
+}
+
+func (gp *GetFileSchemaParams) SetCheckSum(checkSum hash.Hash) *GetFileSchemaParams {
+	gp.checkSum = checkSum
+	return gp
+}
+
+func (gp *GetFileSchemaParams) SetFormat(format string) *GetFileSchemaParams {
+	gp.format = format
+	return gp
+}
+
+type IFileSchemaRepo interface {
+	// Insert save schema, schema already exist is kept
+	Insert(ctx context.Context, schema *FileSchema) error
+	Get(ctx context.Context, params *GetFileSchemaParams) (*FileSchema, error)
+	Delete(ctx context.Context, repositoryID uuid.UUID) (int64, error)
+}
+
+var _ IFileSchemaRepo = (*FileSchemaRepo)(nil)
+
+type FileSchemaRepo struct {
+	db bun.IDB
+}
+
+func NewFileSchemaRepo(db bun.IDB) IFileSchemaRepo {
+	return &FileSchemaRepo{db: db}
+}
+
+func (f *FileSchemaRepo) Insert(ctx context.Context, schema *FileSchema) error {
+	_, err := f.db.NewInsert().
+		Model(schema).
+		On("CONFLICT (repository_id, check_sum, format) DO NOTHING").
+		Exec(ctx)
+	return toModelError(err)
+}
+
+func (f *FileSchemaRepo) Get(ctx context.Context, params *GetFileSchemaParams) (*FileSchema, error) {
+	schema := &FileSchema{}
+	err := f.db.NewSelect().Model(schema).
+		Where("repository_id = ?", params.repositoryID).
+		Where("check_sum = ?", params.checkSum).
+		Where("format = ?", params.format).
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return schema, nil
+}
+
+func (f *FileSchemaRepo) Delete(ctx context.Context, repositoryID uuid.UUID) (int64, error) {
+	sqlResult, err := f.db.NewDelete().Model((*FileSchema)(nil)).Where("repository_id = ?", repositoryID).Exec(ctx)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+	return sqlResult.RowsAffected()
+}
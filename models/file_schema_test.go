@@ -0,0 +1,44 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/utils/tabular"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileSchemaRepo(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewFileSchemaRepo(db)
+	repositoryID := uuid.New()
+
+	columns := []tabular.Column{{Name: "id", Type: tabular.TypeInteger}, {Name: "name", Type: tabular.TypeString}}
+	err := repo.Insert(ctx, &models.FileSchema{RepositoryID: repositoryID, CheckSum: hash.Hash("a"), Format: "csv", Columns: columns, CreatedAt: time.Now()})
+	require.NoError(t, err)
+
+	// existing schema is kept
+	err = repo.Insert(ctx, &models.FileSchema{RepositoryID: repositoryID, CheckSum: hash.Hash("a"), Format: "csv", Columns: columns[:1], CreatedAt: time.Now()})
+	require.NoError(t, err)
+	err = repo.Insert(ctx, &models.FileSchema{RepositoryID: repositoryID, CheckSum: hash.Hash("a"), Format: "jsonl", Columns: columns[:1], CreatedAt: time.Now()})
+	require.NoError(t, err)
+
+	schema, err := repo.Get(ctx, models.NewGetFileSchemaParams().SetRepositoryID(repositoryID).SetCheckSum(hash.Hash("a")).SetFormat("csv"))
+	require.NoError(t, err)
+	require.Equal(t, columns, schema.Columns)
+
+	_, err = repo.Get(ctx, models.NewGetFileSchemaParams().SetRepositoryID(repositoryID).SetCheckSum(hash.Hash("b")).SetFormat("csv"))
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	affectedRows, err := repo.Delete(ctx, repositoryID)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), affectedRows)
+}
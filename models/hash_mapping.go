@@ -0,0 +1,101 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// HashMapping record the new hash of a commit or object after repository history was rewritten with another hash algorithm,
+// so hashes held by clients before migration can still be resolved
+type HashMapping struct {
+	bun.BaseModel `bun:"table:hash_mappings"`
+	RepositoryID  uuid.UUID  `bun:"repository_id,pk,type:uuid,notnull" json:"repository_id"`
+	OldHash       hash.Hash  `bun:"old_hash,pk,type:bytea" json:"old_hash"`
+	NewHash       hash.Hash  `bun:"new_hash,type:bytea,notnull" json:"new_hash"`
+	Type          ObjectType `bun:"type,notnull" json:"type"`
+
+	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+}
+
+type GetHashMappingParams struct {
+	repositoryID uuid.UUID
+	oldHash      hash.Hash
+}
+
+func NewGetHashMappingParams() *GetHashMappingParams {
+	return &GetHashMappingParams{}
+}
+
+func (gp *GetHashMappingParams) SetRepositoryID(repositoryID uuid.UUID) *GetHashMappingParams {
+	gp.repositoryID = repositoryID
+	return gp
+}
+
+func (gp *GetHashMappingParams) SetOldHash(oldHash hash.Hash) *GetHashMappingParams {
+	gp.oldHash = oldHash
+	return gp
+}
+
+type IHashMappingRepo interface {
+	// Upsert insert mappings, new hash is replaced if old hash already exist
+	Upsert(ctx context.Context, mappings []*HashMapping) error
+	Get(ctx context.Context, params *GetHashMappingParams) (*HashMapping, error)
+	List(ctx context.Context, repositoryID uuid.UUID) ([]*HashMapping, error)
+	Delete(ctx context.Context, repositoryID uuid.UUID) (int64, error)
+}
+
+var _ IHashMappingRepo = (*HashMappingRepo)(nil)
+
+type HashMappingRepo struct {
+	db bun.IDB
+}
+
+func NewHashMappingRepo(db bun.IDB) IHashMappingRepo {
+	return &HashMappingRepo{db: db}
+}
+
+func (h *HashMappingRepo) Upsert(ctx context.Context, mappings []*HashMapping) error {
+	if len(mappings) == 0 {
+		return nil
+	}
+	_, err := h.db.NewInsert().
+		Model(&mappings).
+		On("CONFLICT (repository_id, old_hash) DO UPDATE").
+		Set("new_hash = EXCLUDED.new_hash").
+		Exec(ctx)
+	return toModelError(err)
+}
+
+func (h *HashMappingRepo) Get(ctx context.Context, params *GetHashMappingParams) (*HashMapping, error) {
+	mapping := &HashMapping{}
+	err := h.db.NewSelect().Model(mapping).
+		Where("repository_id = ?", params.repositoryID).
+		Where("old_hash = ?", params.oldHash).
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return mapping, nil
+}
+
+func (h *HashMappingRepo) List(ctx context.Context, repositoryID uuid.UUID) ([]*HashMapping, error) {
+	var mappings []*HashMapping
+	err := h.db.NewSelect().Model(&mappings).Where("repository_id = ?", repositoryID).Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return mappings, nil
+}
+
+func (h *HashMappingRepo) Delete(ctx context.Context, repositoryID uuid.UUID) (int64, error) {
+	sqlResult, err := h.db.NewDelete().Model((*HashMapping)(nil)).Where("repository_id = ?", repositoryID).Exec(ctx)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+	return sqlResult.RowsAffected()
+}
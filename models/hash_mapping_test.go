@@ -0,0 +1,52 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashMappingRepo(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewHashMappingRepo(db)
+	repositoryID := uuid.New()
+
+	err := repo.Upsert(ctx, []*models.HashMapping{
+		{RepositoryID: repositoryID, OldHash: hash.Hash("old1"), NewHash: hash.Hash("new1"), Type: models.CommitObject, CreatedAt: time.Now()},
+		{RepositoryID: repositoryID, OldHash: hash.Hash("old2"), NewHash: hash.Hash("new2"), Type: models.BlobObject, CreatedAt: time.Now()},
+	})
+	require.NoError(t, err)
+
+	mapping, err := repo.Get(ctx, models.NewGetHashMappingParams().SetRepositoryID(repositoryID).SetOldHash(hash.Hash("old1")))
+	require.NoError(t, err)
+	require.Equal(t, hash.Hash("new1"), mapping.NewHash)
+
+	_, err = repo.Get(ctx, models.NewGetHashMappingParams().SetRepositoryID(uuid.New()).SetOldHash(hash.Hash("old1")))
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	// replace new hash
+	err = repo.Upsert(ctx, []*models.HashMapping{
+		{RepositoryID: repositoryID, OldHash: hash.Hash("old1"), NewHash: hash.Hash("newer1"), Type: models.CommitObject, CreatedAt: time.Now()},
+	})
+	require.NoError(t, err)
+	mapping, err = repo.Get(ctx, models.NewGetHashMappingParams().SetRepositoryID(repositoryID).SetOldHash(hash.Hash("old1")))
+	require.NoError(t, err)
+	require.Equal(t, hash.Hash("newer1"), mapping.NewHash)
+
+	mappings, err := repo.List(ctx, repositoryID)
+	require.NoError(t, err)
+	require.Len(t, mappings, 2)
+
+	affectedRows, err := repo.Delete(ctx, repositoryID)
+	require.NoError(t, err)
+	require.Equal(t, int64(2), affectedRows)
+}
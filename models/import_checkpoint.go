@@ -0,0 +1,84 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// ImportCheckpoint progress of an unfinished import into branch, saved with every batch of files added to tree so an
+// interrupted import continues after the last file of saved batch. removed when initial commit of branch is created
+type ImportCheckpoint struct {
+	bun.BaseModel `bun:"table:import_checkpoints"`
+	RepositoryID  uuid.UUID `bun:"repository_id,pk,type:uuid,notnull" json:"repository_id"`
+	BranchName    string    `bun:"branch_name,pk,notnull" json:"branch_name"`
+	// Source where files are imported from, like local directory or object store prefix
+	Source string `bun:"source,notnull" json:"source"`
+	// RootHash root of tree holding files imported so far
+	RootHash hash.Hash `bun:"root_hash,type:bytea,notnull" json:"root_hash"`
+	// LastKey path of last file imported, files are walked in the same order every run
+	LastKey string `bun:"last_key,notnull" json:"last_key"`
+	Files   int64  `bun:"files,notnull" json:"files"`
+	Bytes   int64  `bun:"bytes,notnull" json:"bytes"`
+
+	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+	UpdatedAt time.Time `bun:"updated_at,type:timestamp,notnull" json:"updated_at"`
+}
+
+type IImportCheckpointRepo interface {
+	// Save insert checkpoint or replace progress of existing one
+	Save(ctx context.Context, checkpoint *ImportCheckpoint) error
+	Get(ctx context.Context, repositoryID uuid.UUID, branchName string) (*ImportCheckpoint, error)
+	Delete(ctx context.Context, repositoryID uuid.UUID, branchName string) (int64, error)
+}
+
+var _ IImportCheckpointRepo = (*ImportCheckpointRepo)(nil)
+
+type ImportCheckpointRepo struct {
+	db bun.IDB
+}
+
+func NewImportCheckpointRepo(db bun.IDB) IImportCheckpointRepo {
+	return &ImportCheckpointRepo{db: db}
+}
+
+func (r *ImportCheckpointRepo) Save(ctx context.Context, checkpoint *ImportCheckpoint) error {
+	_, err := r.db.NewInsert().
+		Model(checkpoint).
+		On("CONFLICT (repository_id, branch_name) DO UPDATE").
+		Set("source = EXCLUDED.source").
+		Set("root_hash = EXCLUDED.root_hash").
+		Set("last_key = EXCLUDED.last_key").
+		Set("files = EXCLUDED.files").
+		Set("bytes = EXCLUDED.bytes").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+	return toModelError(err)
+}
+
+func (r *ImportCheckpointRepo) Get(ctx context.Context, repositoryID uuid.UUID, branchName string) (*ImportCheckpoint, error) {
+	checkpoint := &ImportCheckpoint{}
+	err := r.db.NewSelect().Model(checkpoint).
+		Where("repository_id = ?", repositoryID).
+		Where("branch_name = ?", branchName).
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return checkpoint, nil
+}
+
+func (r *ImportCheckpointRepo) Delete(ctx context.Context, repositoryID uuid.UUID, branchName string) (int64, error) {
+	res, err := r.db.NewDelete().Model((*ImportCheckpoint)(nil)).
+		Where("repository_id = ?", repositoryID).
+		Where("branch_name = ?", branchName).
+		Exec(ctx)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+	return res.RowsAffected()
+}
@@ -100,6 +100,8 @@ func (p *UpdateMemberParams) SetUpdateGroupID(groupID uuid.UUID) *UpdateMemberPa
 
 type ListMembersParams struct {
 	repoID uuid.UUID
+	after  *time.Time
+	amount int
 }
 
 func NewListMembersParams() *ListMembersParams {
@@ -111,10 +113,21 @@ func (p *ListMembersParams) SetRepoID(repo uuid.UUID) *ListMembersParams {
 	return p
 }
 
+func (p *ListMembersParams) SetAfter(after time.Time) *ListMembersParams {
+	p.after = &after
+	return p
+}
+
+// SetAmount limit number of members returned, all members are returned if not set
+func (p *ListMembersParams) SetAmount(amount int) *ListMembersParams {
+	p.amount = amount
+	return p
+}
+
 type IMemberRepo interface {
 	Insert(ctx context.Context, member *Member) (*Member, error)
 	GetMember(ctx context.Context, params *GetMemberParams) (*Member, error)
-	ListMember(ctx context.Context, params *ListMembersParams) ([]*Member, error)
+	ListMember(ctx context.Context, params *ListMembersParams) ([]*Member, bool, error)
 	DeleteMember(ctx context.Context, params *DeleteMemberParams) (int64, error)
 	UpdateMember(ctx context.Context, params *UpdateMemberParams) error
 }
@@ -132,7 +145,7 @@ func NewMemberRepo(db bun.IDB) IMemberRepo {
 func (a MemberRepo) Insert(ctx context.Context, member *Member) (*Member, error) {
 	_, err := a.db.NewInsert().Model(member).Exec(ctx)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
 	}
 	return member, nil
 }
@@ -151,9 +164,9 @@ func (a MemberRepo) GetMember(ctx context.Context, params *GetMemberParams) (*Me
 	if params.userID != uuid.Nil {
 		query = query.Where("user_id = ?", params.userID)
 	}
-	return member, query.Limit(1).Scan(ctx)
+	return member, toModelError(query.Limit(1).Scan(ctx))
 }
-func (a MemberRepo) ListMember(ctx context.Context, params *ListMembersParams) ([]*Member, error) {
+func (a MemberRepo) ListMember(ctx context.Context, params *ListMembersParams) ([]*Member, bool, error) {
 	var members []*Member
 	query := a.db.NewSelect().Model(&members)
 
@@ -162,12 +175,15 @@ func (a MemberRepo) ListMember(ctx context.Context, params *ListMembersParams) (
 	}
 
 	query = query.Order("created_at DESC")
+	if params.after != nil {
+		query = query.Where("created_at < ?", *params.after)
+	}
 
-	err := query.Scan(ctx)
+	err := query.Limit(params.amount).Scan(ctx)
 	if err != nil {
-		return nil, err
+		return nil, false, toModelError(err)
 	}
-	return members, nil
+	return members, params.amount > 0 && len(members) == params.amount, nil
 }
 
 func (a MemberRepo) UpdateMember(ctx context.Context, params *UpdateMemberParams) error {
@@ -186,7 +202,7 @@ func (a MemberRepo) UpdateMember(ctx context.Context, params *UpdateMemberParams
 	updateQuery.Set("updated_at = ?", params.updateTime)
 
 	_, err := updateQuery.Exec(ctx)
-	return err
+	return toModelError(err)
 }
 
 func (a MemberRepo) DeleteMember(ctx context.Context, params *DeleteMemberParams) (int64, error) {
@@ -202,7 +218,7 @@ func (a MemberRepo) DeleteMember(ctx context.Context, params *DeleteMemberParams
 
 	sqlResult, err := query.Exec(ctx)
 	if err != nil {
-		return 0, err
+		return 0, toModelError(err)
 	}
 	affectedRows, err := sqlResult.RowsAffected()
 	if err != nil {
@@ -66,9 +66,17 @@ func TestMemberRepo(t *testing.T) {
 		}
 
 		listMemberParams := models.NewListMembersParams().SetRepoID(repoID)
-		listMembers, err := memberRepo.ListMember(ctx, listMemberParams)
+		listMembers, _, err := memberRepo.ListMember(ctx, listMemberParams)
 		require.NoError(t, err)
 		require.True(t, cmp.Equal(listMembers, utils.Reverse(members), testhelper.DBTimeCmpOpt))
+
+		page, hasMore, err := memberRepo.ListMember(ctx, models.NewListMembersParams().SetRepoID(repoID).SetAmount(4))
+		require.NoError(t, err)
+		require.True(t, hasMore)
+		require.Len(t, page, 4)
+		page, _, err = memberRepo.ListMember(ctx, models.NewListMembersParams().SetRepoID(repoID).SetAfter(page[3].CreatedAt).SetAmount(4))
+		require.NoError(t, err)
+		require.Equal(t, listMembers[4].ID, page[0].ID)
 	})
 
 	t.Run("delete member", func(t *testing.T) {
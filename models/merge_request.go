@@ -2,6 +2,8 @@ package models
 
 import (
 	"context"
+	"fmt"
+	"slices"
 	"time"
 
 	"github.com/google/uuid"
@@ -30,11 +32,68 @@ type MergeRequest struct {
 	Description    *string    `bun:"description" json:"description"`
 
 	AuthorID uuid.UUID `bun:"author_id,type:bytea,notnull" json:"author_id"`
+	// Reviewers users asked to review, default reviewers of target branch are added on creation
+	Reviewers []uuid.UUID `bun:"reviewers,type:jsonb" json:"reviewers"`
+	// Approvals reviewers approved this merge request
+	Approvals []uuid.UUID `bun:"approvals,type:jsonb" json:"approvals"`
+	// Checklist items copied from merge rules of target branch on creation
+	Checklist []ChecklistItem `bun:"checklist,type:jsonb" json:"checklist"`
+
+	// AutoMerge merged by auto merger once merge rules of target branch are met and it has no conflict
+	AutoMerge bool `bun:"auto_merge,notnull,default:false" json:"auto_merge"`
+	// AutoMergeBy user enabled auto merge, merge commit is made on behalf of this user
+	AutoMergeBy uuid.UUID `bun:"auto_merge_by,type:bytea" json:"auto_merge_by"`
+	// AutoMergeMsg message of merge commit made by auto merger
+	AutoMergeMsg string `bun:"auto_merge_msg" json:"auto_merge_msg"`
+	// AutoMergeAt time auto merge enabled, queued merge requests into the same branch are merged in this order
+	AutoMergeAt time.Time `bun:"auto_merge_at,type:timestamp" json:"auto_merge_at"`
 
 	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
 	UpdatedAt time.Time `bun:"updated_at,type:timestamp,notnull" json:"updated_at"`
 }
 
+// ChecklistItem a step must be done before merge
+type ChecklistItem struct {
+	Title     string    `json:"title"`
+	Checked   bool      `json:"checked"`
+	CheckedBy uuid.UUID `json:"checked_by"`
+}
+
+// CheckMergeRules return ErrMergeRuleUnmet if a default reviewer other than author has not approved or a checklist
+// item of rules is not checked
+func (mr *MergeRequest) CheckMergeRules(rules []MergeRule) error {
+	for _, rule := range rules {
+		for _, reviewer := range rule.DefaultReviewers {
+			if reviewer != mr.AuthorID && !slices.Contains(mr.Approvals, reviewer) {
+				return fmt.Errorf("reviewer %s has not approved %w", reviewer, ErrMergeRuleUnmet)
+			}
+		}
+		for _, title := range rule.Checklist {
+			if !slices.ContainsFunc(mr.Checklist, func(item ChecklistItem) bool {
+				return item.Title == title && item.Checked
+			}) {
+				return fmt.Errorf("checklist item %q is not checked %w", title, ErrMergeRuleUnmet)
+			}
+		}
+	}
+	return nil
+}
+
+// CheckApprovals return ErrMergeRuleUnmet if merge request has less than required approvals, approval of author is
+// not counted
+func (mr *MergeRequest) CheckApprovals(required int) error {
+	approvals := 0
+	for _, approver := range mr.Approvals {
+		if approver != mr.AuthorID {
+			approvals++
+		}
+	}
+	if approvals < required {
+		return fmt.Errorf("%d of %d required approvals %w", approvals, required, ErrMergeRuleUnmet)
+	}
+	return nil
+}
+
 type GetMergeRequestParams struct {
 	id             uuid.UUID
 	sequence       *uint64
@@ -98,12 +157,18 @@ func (dmr *DeleteMergeRequestParams) SetTargetRepo(targetRepoID uuid.UUID) *Dele
 }
 
 type UpdateMergeRequestParams struct {
-	sequence    uint64
-	targetRepo  uuid.UUID
-	updateTime  time.Time
-	title       *string
-	description *string
-	state       *MergeState
+	sequence     uint64
+	targetRepo   uuid.UUID
+	updateTime   time.Time
+	title        *string
+	description  *string
+	state        *MergeState
+	reviewers    *[]uuid.UUID
+	approvals    *[]uuid.UUID
+	checklist    *[]ChecklistItem
+	autoMerge    *bool
+	autoMergeBy  uuid.UUID
+	autoMergeMsg string
 }
 
 func NewUpdateMergeRequestParams(targetRepoID uuid.UUID, sequence uint64) *UpdateMergeRequestParams {
@@ -129,6 +194,39 @@ func (u *UpdateMergeRequestParams) SetState(state MergeState) *UpdateMergeReques
 	return u
 }
 
+func (u *UpdateMergeRequestParams) SetReviewers(reviewers []uuid.UUID) *UpdateMergeRequestParams {
+	u.reviewers = &reviewers
+	return u
+}
+
+func (u *UpdateMergeRequestParams) SetApprovals(approvals []uuid.UUID) *UpdateMergeRequestParams {
+	u.approvals = &approvals
+	return u
+}
+
+func (u *UpdateMergeRequestParams) SetChecklist(checklist []ChecklistItem) *UpdateMergeRequestParams {
+	u.checklist = &checklist
+	return u
+}
+
+// SetAutoMerge queue merge request to be merged by auto merger on behalf of user with msg
+func (u *UpdateMergeRequestParams) SetAutoMerge(userID uuid.UUID, msg string) *UpdateMergeRequestParams {
+	autoMerge := true
+	u.autoMerge = &autoMerge
+	u.autoMergeBy = userID
+	u.autoMergeMsg = msg
+	return u
+}
+
+// ClearAutoMerge remove merge request from auto merge queue
+func (u *UpdateMergeRequestParams) ClearAutoMerge() *UpdateMergeRequestParams {
+	autoMerge := false
+	u.autoMerge = &autoMerge
+	u.autoMergeBy = uuid.Nil
+	u.autoMergeMsg = ""
+	return u
+}
+
 type ListMergeRequestParams struct {
 	after        *time.Time
 	amount       int
@@ -166,6 +264,8 @@ type IMergeRequestRepo interface {
 	List(ctx context.Context, params *ListMergeRequestParams) ([]MergeRequest, bool, error)
 	UpdateByID(ctx context.Context, params *UpdateMergeRequestParams) error
 	Delete(ctx context.Context, params *DeleteMergeRequestParams) (int64, error)
+	// ListAutoMerge open merge requests of all repositories queued for auto merge, in the order auto merge was enabled
+	ListAutoMerge(ctx context.Context) ([]MergeRequest, error)
 }
 
 var _ IMergeRequestRepo = (*MergeRequestRepo)(nil)
@@ -183,14 +283,14 @@ func (m MergeRequestRepo) Insert(ctx context.Context, mr *MergeRequest) (*MergeR
 		WITH INCNUMBER AS (
 				SELECT MAX(mr_sequence) as max_seq from merge_requests WHERE merge_requests.target_repo_id  = ?
 			)
-		INSERT INTO merge_requests (mr_sequence, target_branch_id,source_branch_id,source_repo_id,target_repo_id,title,merge_state,description,author_id,created_at,updated_at)
-		SELECT COALESCE(max_seq,0)+1, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? FROM INCNUMBER
-		RETURNING merge_requests.id, merge_requests.mr_sequence;
+		INSERT INTO merge_requests (mr_sequence, target_branch_id,source_branch_id,source_repo_id,target_repo_id,title,merge_state,description,author_id,reviewers,approvals,checklist,auto_merge,auto_merge_by,auto_merge_msg,auto_merge_at,created_at,updated_at)
+		SELECT COALESCE(max_seq,0)+1, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ? FROM INCNUMBER
+		RETURNING id, mr_sequence;
 `,
-		mr.TargetRepoID, mr.TargetBranchID, mr.SourceBranchID, mr.SourceRepoID, mr.TargetRepoID, mr.Title, mr.MergeState, mr.Description, mr.AuthorID, mr.CreatedAt, mr.UpdatedAt,
+		mr.TargetRepoID, mr.TargetBranchID, mr.SourceBranchID, mr.SourceRepoID, mr.TargetRepoID, mr.Title, mr.MergeState, mr.Description, mr.AuthorID, mr.Reviewers, mr.Approvals, mr.Checklist, mr.AutoMerge, mr.AutoMergeBy, mr.AutoMergeMsg, mr.AutoMergeAt, mr.CreatedAt, mr.UpdatedAt,
 	).Exec(ctx, mr)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
 	}
 	return mr, nil
 }
@@ -222,7 +322,7 @@ func (m MergeRequestRepo) Get(ctx context.Context, params *GetMergeRequestParams
 	if params.sourceBranchID != uuid.Nil {
 		query = query.Where("source_branch_id = ?", params.sourceBranchID)
 	}
-	return mergeRequest, query.Limit(1).Scan(ctx)
+	return mergeRequest, toModelError(query.Limit(1).Scan(ctx))
 }
 
 func (m MergeRequestRepo) List(ctx context.Context, params *ListMergeRequestParams) ([]MergeRequest, bool, error) {
@@ -242,7 +342,17 @@ func (m MergeRequestRepo) List(ctx context.Context, params *ListMergeRequestPara
 	}
 
 	err := query.Limit(params.amount).Scan(ctx)
-	return mergeRequest, len(mergeRequest) == params.amount, err
+	return mergeRequest, len(mergeRequest) == params.amount, toModelError(err)
+}
+
+func (m MergeRequestRepo) ListAutoMerge(ctx context.Context) ([]MergeRequest, error) {
+	mergeRequests := make([]MergeRequest, 0)
+	err := m.db.NewSelect().Model(&mergeRequests).
+		Where("merge_state = ?", MergeStateInit).
+		Where("auto_merge = ?", true).
+		Order("auto_merge_at ASC", "mr_sequence ASC").
+		Scan(ctx)
+	return mergeRequests, toModelError(err)
 }
 
 func (m MergeRequestRepo) Delete(ctx context.Context, params *DeleteMergeRequestParams) (int64, error) {
@@ -255,7 +365,7 @@ func (m MergeRequestRepo) Delete(ctx context.Context, params *DeleteMergeRequest
 	}
 	sqlResult, err := query.Exec(ctx)
 	if err != nil {
-		return 0, err
+		return 0, toModelError(err)
 	}
 	affectedRows, err := sqlResult.RowsAffected()
 	if err != nil {
@@ -280,6 +390,21 @@ func (m MergeRequestRepo) UpdateByID(ctx context.Context, updateModel *UpdateMer
 	if updateModel.state != nil {
 		updateQuery.Set("merge_state = ?", *updateModel.state)
 	}
+	if updateModel.reviewers != nil {
+		updateQuery.Set("reviewers = ?", *updateModel.reviewers)
+	}
+	if updateModel.approvals != nil {
+		updateQuery.Set("approvals = ?", *updateModel.approvals)
+	}
+	if updateModel.checklist != nil {
+		updateQuery.Set("checklist = ?", *updateModel.checklist)
+	}
+	if updateModel.autoMerge != nil {
+		updateQuery.Set("auto_merge = ?", *updateModel.autoMerge).
+			Set("auto_merge_by = ?", updateModel.autoMergeBy).
+			Set("auto_merge_msg = ?", updateModel.autoMergeMsg).
+			Set("auto_merge_at = ?", updateModel.updateTime)
+	}
 	_, err := updateQuery.Exec(ctx)
-	return err
+	return toModelError(err)
 }
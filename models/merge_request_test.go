@@ -109,4 +109,95 @@ func TestMergeRequestRepoInsert(t *testing.T) {
 
 		require.Equal(t, models.MergeStateClosed, mrModel.MergeState)
 	})
+
+	t.Run("update review", func(t *testing.T) {
+		mrModel := &models.MergeRequest{}
+		require.NoError(t, gofakeit.Struct(mrModel))
+		newMrModel, err := mrRepo.Insert(ctx, mrModel)
+		require.NoError(t, err)
+
+		reviewers := []uuid.UUID{uuid.New(), uuid.New()}
+		checklist := []models.ChecklistItem{{Title: "schema checked", Checked: true, CheckedBy: reviewers[0]}}
+		err = mrRepo.UpdateByID(ctx, models.NewUpdateMergeRequestParams(newMrModel.TargetRepoID, newMrModel.Sequence).
+			SetReviewers(reviewers).
+			SetApprovals(reviewers[:1]).
+			SetChecklist(checklist))
+		require.NoError(t, err)
+
+		mrModel, err = mrRepo.Get(ctx, models.NewGetMergeRequestParams().SetID(newMrModel.ID))
+		require.NoError(t, err)
+		require.Equal(t, reviewers, mrModel.Reviewers)
+		require.Equal(t, reviewers[:1], mrModel.Approvals)
+		require.Equal(t, checklist, mrModel.Checklist)
+	})
+
+	t.Run("auto merge queue", func(t *testing.T) {
+		var queued []*models.MergeRequest
+		for i := 0; i < 2; i++ {
+			mrModel := &models.MergeRequest{}
+			require.NoError(t, gofakeit.Struct(mrModel))
+			mrModel.MergeState = models.MergeStateInit
+			mrModel.AutoMerge = false
+			newMrModel, err := mrRepo.Insert(ctx, mrModel)
+			require.NoError(t, err)
+			queued = append(queued, newMrModel)
+		}
+
+		userID := uuid.New()
+		require.NoError(t, mrRepo.UpdateByID(ctx, models.NewUpdateMergeRequestParams(queued[1].TargetRepoID, queued[1].Sequence).SetAutoMerge(userID, "merge it")))
+		require.NoError(t, mrRepo.UpdateByID(ctx, models.NewUpdateMergeRequestParams(queued[0].TargetRepoID, queued[0].Sequence).SetAutoMerge(userID, "")))
+
+		positionOf := func(mrs []models.MergeRequest, id uuid.UUID) int {
+			for i, mr := range mrs {
+				if mr.ID == id {
+					return i
+				}
+			}
+			return -1
+		}
+		mrs, err := mrRepo.ListAutoMerge(ctx)
+		require.NoError(t, err)
+		require.Less(t, positionOf(mrs, queued[1].ID), positionOf(mrs, queued[0].ID))
+		require.GreaterOrEqual(t, positionOf(mrs, queued[1].ID), 0)
+		mr := mrs[positionOf(mrs, queued[1].ID)]
+		require.True(t, mr.AutoMerge)
+		require.Equal(t, userID, mr.AutoMergeBy)
+		require.Equal(t, "merge it", mr.AutoMergeMsg)
+
+		require.NoError(t, mrRepo.UpdateByID(ctx, models.NewUpdateMergeRequestParams(queued[1].TargetRepoID, queued[1].Sequence).ClearAutoMerge()))
+		require.NoError(t, mrRepo.UpdateByID(ctx, models.NewUpdateMergeRequestParams(queued[0].TargetRepoID, queued[0].Sequence).SetState(models.MergeStateMerged)))
+		mrs, err = mrRepo.ListAutoMerge(ctx)
+		require.NoError(t, err)
+		require.Equal(t, -1, positionOf(mrs, queued[0].ID))
+		require.Equal(t, -1, positionOf(mrs, queued[1].ID))
+	})
+}
+
+func TestMergeRequestCheckMergeRules(t *testing.T) {
+	author, reviewer := uuid.New(), uuid.New()
+	rules := []models.MergeRule{{Branch: "main", DefaultReviewers: []uuid.UUID{author, reviewer}, Checklist: []string{"schema checked"}}}
+	mr := &models.MergeRequest{
+		AuthorID:  author,
+		Checklist: []models.ChecklistItem{{Title: "schema checked"}},
+	}
+
+	require.NoError(t, mr.CheckMergeRules(nil))
+	require.ErrorIs(t, mr.CheckMergeRules(rules), models.ErrMergeRuleUnmet)
+
+	mr.Approvals = []uuid.UUID{reviewer}
+	require.ErrorIs(t, mr.CheckMergeRules(rules), models.ErrMergeRuleUnmet)
+
+	mr.Checklist[0].Checked = true
+	require.NoError(t, mr.CheckMergeRules(rules))
+}
+
+func TestMergeRequestCheckApprovals(t *testing.T) {
+	author, reviewer := uuid.New(), uuid.New()
+	mr := &models.MergeRequest{AuthorID: author, Approvals: []uuid.UUID{author}}
+
+	require.NoError(t, mr.CheckApprovals(0))
+	require.ErrorIs(t, mr.CheckApprovals(1), models.ErrMergeRuleUnmet)
+
+	mr.Approvals = append(mr.Approvals, reviewer)
+	require.NoError(t, mr.CheckApprovals(1))
 }
@@ -6,18 +6,21 @@ import (
 	"github.com/GitDataAI/jiaozifs/models"
 	"github.com/GitDataAI/jiaozifs/models/rbacmodel"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
 )
 
 func init() {
 	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
-		//common
-		_, err := db.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp";`)
-		if err != nil {
-			return err
+		//common, uuid_generate_v4 is replaced by dialect in sqlite
+		if db.Dialect().Name() == dialect.PG {
+			_, err := db.Exec(`CREATE EXTENSION IF NOT EXISTS "uuid-ossp";`)
+			if err != nil {
+				return err
+			}
 		}
 
 		//user
-		_, err = db.NewCreateTable().
+		_, err := db.NewCreateTable().
 			Model((*models.User)(nil)).
 			Exec(ctx)
 		if err != nil {
@@ -0,0 +1,35 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		//share
+		_, err := db.NewCreateTable().
+			Model((*models.Share)(nil)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = db.NewCreateTable().
+			Model((*models.ShareAccessLog)(nil)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = db.NewCreateIndex().
+			Model((*models.ShareAccessLog)(nil)).
+			Index("share_id_idx").
+			Column("share_id").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		return nil
+	}, nil)
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		//events
+		_, err := db.NewCreateTable().
+			Model((*models.Event)(nil)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = db.NewCreateIndex().
+			Model((*models.Event)(nil)).
+			Index("event_repository_id_seq_idx").
+			Column("repository_id", "seq").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		return nil
+	}, nil)
+}
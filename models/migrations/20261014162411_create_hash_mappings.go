@@ -0,0 +1,25 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		//hash mappings
+		_, err := db.NewCreateTable().
+			Model((*models.HashMapping)(nil)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		err = addColumns(ctx, db, (*models.Repository)(nil), "hash_type")
+		if err != nil {
+			return err
+		}
+		return nil
+	}, nil)
+}
@@ -0,0 +1,14 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		return addColumns(ctx, db, (*models.Repository)(nil), "write_rate_limit", "write_burst", "max_concurrent_uploads")
+	}, nil)
+}
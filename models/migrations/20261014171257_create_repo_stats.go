@@ -0,0 +1,20 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewCreateTable().
+			Model((*models.RepositoryStats)(nil)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		return nil
+	}, nil)
+}
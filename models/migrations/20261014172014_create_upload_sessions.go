@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		//upload session
+		_, err := db.NewCreateTable().
+			Model((*models.UploadSession)(nil)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = db.NewCreateIndex().
+			Model((*models.UploadSession)(nil)).
+			Index("upload_session_creator_id_idx").
+			Column("creator_id").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		return nil
+	}, nil)
+}
@@ -0,0 +1,28 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		_, err := db.NewCreateTable().
+			Model((*models.RepositoryAlias)(nil)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = db.NewCreateIndex().
+			Model((*models.RepositoryAlias)(nil)).
+			Index("repository_alias_repository_id_idx").
+			Column("repository_id").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		return nil
+	}, nil)
+}
@@ -0,0 +1,29 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		//audit logs
+		_, err := db.NewCreateTable().
+			Model((*models.AuditLog)(nil)).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		_, err = db.NewCreateIndex().
+			Model((*models.AuditLog)(nil)).
+			Index("audit_log_created_at_idx").
+			Column("created_at").
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+		return nil
+	}, nil)
+}
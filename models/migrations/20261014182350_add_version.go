@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		err := addColumns(ctx, db, (*models.Repository)(nil), "version")
+		if err != nil {
+			return err
+		}
+		err = addColumns(ctx, db, (*models.Branch)(nil), "version")
+		if err != nil {
+			return err
+		}
+		err = addColumns(ctx, db, (*models.WorkingInProcess)(nil), "version")
+		if err != nil {
+			return err
+		}
+		return nil
+	}, nil)
+}
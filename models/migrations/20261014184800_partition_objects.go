@@ -0,0 +1,73 @@
+package migrations
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		//rebuild objects table with primary key leading by repository_id, rows are copied from old table
+		_, err := db.ExecContext(ctx, "ALTER TABLE trees RENAME TO trees_old")
+		if err != nil {
+			return err
+		}
+		if db.Dialect().Name() == dialect.PG {
+			//constraint of old table keeps its name, free it for new table
+			_, err = db.ExecContext(ctx, "ALTER TABLE trees_old RENAME CONSTRAINT trees_pkey TO trees_old_pkey")
+			if err != nil {
+				return err
+			}
+		}
+
+		err = createObjectTable(ctx, db, objectPartitions(ctx))
+		if err != nil {
+			return err
+		}
+
+		var columns []string
+		for _, field := range db.Table(reflect.TypeOf((*models.FileTree)(nil))).Fields {
+			columns = append(columns, string(field.SQLName))
+		}
+		_, err = db.ExecContext(ctx, fmt.Sprintf("INSERT INTO trees (%[1]s) SELECT %[1]s FROM trees_old", strings.Join(columns, ", ")))
+		if err != nil {
+			return err
+		}
+
+		_, err = db.ExecContext(ctx, "DROP TABLE trees_old")
+		return err
+	}, nil)
+}
+
+// createObjectTable create table of tree and blob objects. on postgres the table is split into partitions by hash of
+// repository_id, each repository lives in one partition with its own index, so scanning objects of a repository in gc or
+// deleting a repository does not touch objects of other repositories. sqlite is not partitioned, primary key leading with
+// repository_id serves the same queries
+func createObjectTable(ctx context.Context, db *bun.DB, partitions int) error {
+	query := db.NewCreateTable().Model((*models.FileTree)(nil))
+	partitioned := db.Dialect().Name() == dialect.PG && partitions > 1
+	if partitioned {
+		query = query.PartitionBy("HASH (repository_id)")
+	}
+	_, err := query.Exec(ctx)
+	if err != nil {
+		return err
+	}
+	if !partitioned {
+		return nil
+	}
+
+	for i := 0; i < partitions; i++ {
+		_, err = db.ExecContext(ctx, fmt.Sprintf("CREATE TABLE trees_p%d PARTITION OF trees FOR VALUES WITH (MODULUS %d, REMAINDER %d)", i, partitions, i))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
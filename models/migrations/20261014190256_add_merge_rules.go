@@ -0,0 +1,22 @@
+package migrations
+
+import (
+	"context"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/uptrace/bun"
+)
+
+func init() {
+	Migrations.MustRegister(func(ctx context.Context, db *bun.DB) error {
+		err := addColumns(ctx, db, (*models.Repository)(nil), "merge_rules")
+		if err != nil {
+			return err
+		}
+		err = addColumns(ctx, db, (*models.MergeRequest)(nil), "reviewers", "approvals", "checklist")
+		if err != nil {
+			return err
+		}
+		return nil
+	}, nil)
+}
@@ -2,14 +2,22 @@ package migrations
 
 import (
 	"context"
+	"fmt"
+	"reflect"
 
+	"github.com/GitDataAI/jiaozifs/config"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
 	"github.com/uptrace/bun/migrate"
 )
 
 var Migrations = migrate.NewMigrations()
 
-func MigrateDatabase(ctx context.Context, sqlDB *bun.DB) error {
+type databaseConfigKey struct{}
+
+// MigrateDatabase apply migrations not applied yet, cfg decides layout of tables created by migrations
+func MigrateDatabase(ctx context.Context, sqlDB *bun.DB, cfg *config.DatabaseConfig) error {
+	ctx = context.WithValue(ctx, databaseConfigKey{}, cfg)
 	migrator := migrate.NewMigrator(sqlDB, Migrations)
 	err := migrator.Init(ctx)
 	if err != nil {
@@ -19,3 +27,59 @@ func MigrateDatabase(ctx context.Context, sqlDB *bun.DB) error {
 	_, err = migrator.Migrate(ctx)
 	return err
 }
+
+// objectPartitions number of partitions of objects table migrations are running with
+func objectPartitions(ctx context.Context) int {
+	cfg, ok := ctx.Value(databaseConfigKey{}).(*config.DatabaseConfig)
+	if !ok || cfg == nil {
+		return 0
+	}
+	return cfg.ObjectPartitions
+}
+
+// addColumns add columns of model to its table, columns are defined by tags of model as creating table does. tables
+// created by init migration are built from latest models, so columns already existing are skipped
+func addColumns(ctx context.Context, db *bun.DB, model interface{}, columns ...string) error {
+	table := db.Table(reflect.TypeOf(model))
+	for _, column := range columns {
+		field, ok := table.FieldMap[column]
+		if !ok {
+			return fmt.Errorf("column %s not found in table %s", column, table.Name)
+		}
+
+		exist, err := columnExist(ctx, db, table.Name, column)
+		if err != nil {
+			return err
+		}
+		if exist {
+			continue
+		}
+
+		definition := field.CreateTableSQLType
+		if field.NotNull {
+			definition += " NOT NULL"
+		}
+		if len(field.SQLDefault) > 0 {
+			definition += " DEFAULT " + field.SQLDefault
+		}
+		_, err = db.NewAddColumn().
+			Model(model).
+			ColumnExpr("? "+definition, field.SQLName).
+			Exec(ctx)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func columnExist(ctx context.Context, db *bun.DB, table, column string) (bool, error) {
+	query := "SELECT count(*) FROM information_schema.columns WHERE table_schema = current_schema() AND table_name = ? AND column_name = ?"
+	if db.Dialect().Name() == dialect.SQLite {
+		query = "SELECT count(*) FROM pragma_table_info(?) WHERE name = ?"
+	}
+
+	var count int
+	err := db.NewRaw(query, table, column).Scan(ctx, &count)
+	return count > 0, err
+}
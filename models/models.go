@@ -3,6 +3,8 @@ package models
 import (
 	"context"
 	"database/sql"
+	"fmt"
+	"time"
 
 	"github.com/GitDataAI/jiaozifs/config"
 	"github.com/uptrace/bun"
@@ -28,16 +30,49 @@ func SetupDatabase(ctx context.Context, lc fx.Lifecycle, dbConfig *config.Databa
 }
 
 func NewBunDBFromConfig(ctx context.Context, dbConfig *config.DatabaseConfig) (*bun.DB, error) {
-	sqlDB := sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dbConfig.Connection)))
-	_, err := sqlDB.Conn(ctx)
+	var bunDB *bun.DB
+	switch dbConfig.Type {
+	case "", config.DatabaseTypePostgres:
+		sqlDB := sql.OpenDB(pgdriver.NewConnector(pgdriver.WithDSN(dbConfig.Connection), withStatementTimeout(dbConfig.QueryTimeout)))
+		bunDB = bun.NewDB(sqlDB, pgdialect.New(), bun.WithDiscardUnknownColumns())
+	case config.DatabaseTypeSQLite:
+		sqlDB, err := sql.Open(sqliteDriverName, sqliteDSN(dbConfig.Connection))
+		if err != nil {
+			return nil, err
+		}
+		if isSqliteMemory(dbConfig.Connection) {
+			// every connection open a new in memory database
+			sqlDB.SetMaxOpenConns(1)
+		}
+		bunDB = bun.NewDB(sqlDB, newSqliteDialect(), bun.WithDiscardUnknownColumns())
+	default:
+		return nil, fmt.Errorf("unsupported database type %s", dbConfig.Type)
+	}
+
+	err := NewPoolMonitor(bunDB.DB, 0).Ping(ctx)
 	if err != nil {
+		_ = bunDB.Close()
 		return nil, err
 	}
 
-	bunDB := bun.NewDB(sqlDB, pgdialect.New(), bun.WithDiscardUnknownColumns())
-
 	if dbConfig.Debug {
 		bunDB.AddQueryHook(bundebug.NewQueryHook(bundebug.WithVerbose(true)))
 	}
-	return bunDB, err
+	return bunDB, nil
+}
+
+// withStatementTimeout set statement_timeout of postgres sessions unless it is given in dsn, statements exceed timeout
+// are canceled by server so transactions are never left half done
+func withStatementTimeout(timeout time.Duration) pgdriver.Option {
+	return func(cfg *pgdriver.Config) {
+		if timeout <= 0 {
+			return
+		}
+		if cfg.ConnParams == nil {
+			cfg.ConnParams = make(map[string]interface{})
+		}
+		if _, ok := cfg.ConnParams["statement_timeout"]; !ok {
+			cfg.ConnParams["statement_timeout"] = timeout.Milliseconds()
+		}
+	}
 }
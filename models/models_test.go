@@ -4,10 +4,13 @@ import (
 	"context"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/GitDataAI/jiaozifs/config"
 	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
 	embeddedpostgres "github.com/fergusstrange/embedded-postgres"
+	"github.com/google/uuid"
 	"github.com/phayes/freeport"
 	"github.com/stretchr/testify/require"
 	"go.uber.org/fx/fxtest"
@@ -31,3 +34,33 @@ func TestSetupDatabase(t *testing.T) {
 	lc.RequireStop()
 	require.NoError(t, db.PingContext(ctx))
 }
+
+func TestSetupSQLiteDatabase(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupSQLiteDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewUploadSessionRepo(db)
+	session, err := repo.Insert(ctx, &models.UploadSession{
+		RepositoryID: uuid.New(),
+		CreatorID:    uuid.New(),
+		RefName:      "main",
+		Path:         "a/b.txt",
+		UploadID:     "upload",
+		Address:      "uploads/a",
+		LastActiveAt: time.Now(),
+		CreatedAt:    time.Now(),
+	})
+	require.NoError(t, err)
+	require.NotEqual(t, uuid.Nil, session.ID)
+
+	require.NoError(t, repo.AddPart(ctx, session.ID, models.UploadPart{PartNumber: 1, ETag: "a", Size: 3}))
+	require.NoError(t, repo.AddPart(ctx, session.ID, models.UploadPart{PartNumber: 1, ETag: "b", Size: 5}))
+
+	session, err = repo.Get(ctx, models.NewGetUploadSessionParams().SetID(session.ID))
+	require.NoError(t, err)
+	require.Equal(t, []models.UploadPart{{PartNumber: 1, ETag: "b", Size: 5}}, session.Parts)
+
+	_, err = models.SetupDatabase(ctx, fxtest.NewLifecycle(t), &config.DatabaseConfig{Type: "mysql"})
+	require.Error(t, err)
+}
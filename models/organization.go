@@ -0,0 +1,314 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Organization owner of repositories shared by a team, repository whose OwnerID is ID of an organization is owned by
+// it. organizations and users share one namespace of names, so owner in path can be either of them
+type Organization struct {
+	bun.BaseModel `bun:"table:organizations"`
+	ID            uuid.UUID `bun:"id,pk,type:uuid,default:uuid_generate_v4()" json:"id"`
+	Name          string    `bun:"name,unique,notnull" json:"name"`
+	Description   *string   `bun:"description" json:"description,omitempty"`
+	CreatorID     uuid.UUID `bun:"creator_id,type:uuid,notnull" json:"creator_id"`
+
+	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+	UpdatedAt time.Time `bun:"updated_at,type:timestamp,notnull" json:"updated_at"`
+}
+
+// OrgRole role of user in organization
+type OrgRole string
+
+const (
+	// OrgRoleOwner manage organization, its members and repositories
+	OrgRoleOwner OrgRole = "owner"
+	// OrgRoleAdmin manage members and repositories
+	OrgRoleAdmin OrgRole = "admin"
+	// OrgRoleMember access repositories of organization
+	OrgRoleMember OrgRole = "member"
+)
+
+func (role OrgRole) Valid() bool {
+	return role == OrgRoleOwner || role == OrgRoleAdmin || role == OrgRoleMember
+}
+
+// Membership user belong to organization
+type Membership struct {
+	bun.BaseModel `bun:"table:memberships"`
+	OrgID         uuid.UUID `bun:"org_id,pk,type:uuid" json:"org_id"`
+	UserID        uuid.UUID `bun:"user_id,pk,type:uuid" json:"user_id"`
+	Role          OrgRole   `bun:"role,notnull" json:"role"`
+
+	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+	UpdatedAt time.Time `bun:"updated_at,type:timestamp,notnull" json:"updated_at"`
+}
+
+type GetOrgParams struct {
+	id   uuid.UUID
+	name *string
+}
+
+func NewGetOrgParams() *GetOrgParams {
+	return &GetOrgParams{}
+}
+
+func (gop *GetOrgParams) SetID(id uuid.UUID) *GetOrgParams {
+	gop.id = id
+	return gop
+}
+
+func (gop *GetOrgParams) SetName(name string) *GetOrgParams {
+	gop.name = &name
+	return gop
+}
+
+type ListOrgParams struct {
+	memberID uuid.UUID
+	after    *time.Time
+	amount   int
+}
+
+func NewListOrgParams() *ListOrgParams {
+	return &ListOrgParams{}
+}
+
+// SetMemberID list organizations user belong to
+func (lop *ListOrgParams) SetMemberID(userID uuid.UUID) *ListOrgParams {
+	lop.memberID = userID
+	return lop
+}
+
+func (lop *ListOrgParams) SetAfter(after time.Time) *ListOrgParams {
+	lop.after = &after
+	return lop
+}
+
+func (lop *ListOrgParams) SetAmount(amount int) *ListOrgParams {
+	lop.amount = amount
+	return lop
+}
+
+type UpdateOrgParams struct {
+	id          uuid.UUID
+	description *string
+	updateTime  time.Time
+}
+
+func NewUpdateOrgParams(id uuid.UUID) *UpdateOrgParams {
+	return &UpdateOrgParams{
+		id:         id,
+		updateTime: time.Now(),
+	}
+}
+
+func (uop *UpdateOrgParams) SetDescription(description string) *UpdateOrgParams {
+	uop.description = &description
+	return uop
+}
+
+type ListMembershipParams struct {
+	orgID  uuid.UUID
+	userID uuid.UUID
+	role   *OrgRole
+	after  *time.Time
+	amount int
+}
+
+func NewListMembershipParams() *ListMembershipParams {
+	return &ListMembershipParams{}
+}
+
+func (lmp *ListMembershipParams) SetOrgID(orgID uuid.UUID) *ListMembershipParams {
+	lmp.orgID = orgID
+	return lmp
+}
+
+func (lmp *ListMembershipParams) SetUserID(userID uuid.UUID) *ListMembershipParams {
+	lmp.userID = userID
+	return lmp
+}
+
+func (lmp *ListMembershipParams) SetRole(role OrgRole) *ListMembershipParams {
+	lmp.role = &role
+	return lmp
+}
+
+func (lmp *ListMembershipParams) SetAfter(after time.Time) *ListMembershipParams {
+	lmp.after = &after
+	return lmp
+}
+
+// SetAmount limit number of memberships returned, all memberships are returned if not set
+func (lmp *ListMembershipParams) SetAmount(amount int) *ListMembershipParams {
+	lmp.amount = amount
+	return lmp
+}
+
+type IOrgRepo interface {
+	Insert(ctx context.Context, org *Organization) (*Organization, error)
+	Get(ctx context.Context, params *GetOrgParams) (*Organization, error)
+	List(ctx context.Context, params *ListOrgParams) ([]*Organization, bool, error)
+	UpdateByID(ctx context.Context, params *UpdateOrgParams) error
+	// Delete remove organization with its memberships, repositories owned by it must be removed before
+	Delete(ctx context.Context, id uuid.UUID) (int64, error)
+
+	AddMember(ctx context.Context, membership *Membership) (*Membership, error)
+	GetMembership(ctx context.Context, orgID, userID uuid.UUID) (*Membership, error)
+	ListMemberships(ctx context.Context, params *ListMembershipParams) ([]*Membership, bool, error)
+	UpdateRole(ctx context.Context, orgID, userID uuid.UUID, role OrgRole) error
+	RemoveMember(ctx context.Context, orgID, userID uuid.UUID) (int64, error)
+}
+
+var _ IOrgRepo = (*OrgRepo)(nil)
+
+type OrgRepo struct {
+	db bun.IDB
+}
+
+func NewOrgRepo(db bun.IDB) IOrgRepo {
+	return &OrgRepo{db: db}
+}
+
+func (r *OrgRepo) Insert(ctx context.Context, org *Organization) (*Organization, error) {
+	_, err := r.db.NewInsert().Model(org).Exec(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return org, nil
+}
+
+func (r *OrgRepo) Get(ctx context.Context, params *GetOrgParams) (*Organization, error) {
+	org := &Organization{}
+	query := r.db.NewSelect().Model(org)
+
+	if params.id != uuid.Nil {
+		query = query.Where("id = ?", params.id)
+	}
+
+	if params.name != nil {
+		query = query.Where("name = ?", *params.name)
+	}
+
+	return org, toModelError(query.Limit(1).Scan(ctx))
+}
+
+func (r *OrgRepo) List(ctx context.Context, params *ListOrgParams) ([]*Organization, bool, error) {
+	orgs := []*Organization{}
+	query := r.db.NewSelect().Model(&orgs)
+
+	if params.memberID != uuid.Nil {
+		query = query.Where("id IN (SELECT org_id FROM memberships WHERE user_id = ?)", params.memberID)
+	}
+
+	query = query.Order("created_at DESC")
+	if params.after != nil {
+		query = query.Where("created_at < ?", *params.after)
+	}
+
+	err := query.Limit(params.amount).Scan(ctx)
+	return orgs, params.amount > 0 && len(orgs) == params.amount, toModelError(err)
+}
+
+func (r *OrgRepo) UpdateByID(ctx context.Context, params *UpdateOrgParams) error {
+	updateQuery := r.db.NewUpdate().Model((*Organization)(nil)).
+		Where("id = ?", params.id).
+		Set("updated_at = ?", params.updateTime)
+
+	if params.description != nil {
+		updateQuery.Set("description = ?", *params.description)
+	}
+
+	_, err := updateQuery.Exec(ctx)
+	return toModelError(err)
+}
+
+func (r *OrgRepo) Delete(ctx context.Context, id uuid.UUID) (int64, error) {
+	_, err := r.db.NewDelete().Model((*Membership)(nil)).Where("org_id = ?", id).Exec(ctx)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+
+	sqlResult, err := r.db.NewDelete().Model((*Organization)(nil)).Where("id = ?", id).Exec(ctx)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+	return sqlResult.RowsAffected()
+}
+
+func (r *OrgRepo) AddMember(ctx context.Context, membership *Membership) (*Membership, error) {
+	_, err := r.db.NewInsert().Model(membership).Exec(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return membership, nil
+}
+
+func (r *OrgRepo) GetMembership(ctx context.Context, orgID, userID uuid.UUID) (*Membership, error) {
+	membership := &Membership{}
+	return membership, toModelError(r.db.NewSelect().Model(membership).
+		Where("org_id = ?", orgID).
+		Where("user_id = ?", userID).
+		Limit(1).
+		Scan(ctx))
+}
+
+func (r *OrgRepo) ListMemberships(ctx context.Context, params *ListMembershipParams) ([]*Membership, bool, error) {
+	memberships := []*Membership{}
+	query := r.db.NewSelect().Model(&memberships)
+
+	if params.orgID != uuid.Nil {
+		query = query.Where("org_id = ?", params.orgID)
+	}
+
+	if params.userID != uuid.Nil {
+		query = query.Where("user_id = ?", params.userID)
+	}
+
+	if params.role != nil {
+		query = query.Where("role = ?", *params.role)
+	}
+
+	query = query.Order("created_at DESC")
+	if params.after != nil {
+		query = query.Where("created_at < ?", *params.after)
+	}
+
+	err := query.Limit(params.amount).Scan(ctx)
+	return memberships, params.amount > 0 && len(memberships) == params.amount, toModelError(err)
+}
+
+func (r *OrgRepo) UpdateRole(ctx context.Context, orgID, userID uuid.UUID, role OrgRole) error {
+	sqlResult, err := r.db.NewUpdate().Model((*Membership)(nil)).
+		Where("org_id = ?", orgID).
+		Where("user_id = ?", userID).
+		Set("role = ?", role).
+		Set("updated_at = ?", time.Now()).
+		Exec(ctx)
+	if err != nil {
+		return toModelError(err)
+	}
+	affectedRows, err := sqlResult.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affectedRows == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (r *OrgRepo) RemoveMember(ctx context.Context, orgID, userID uuid.UUID) (int64, error) {
+	sqlResult, err := r.db.NewDelete().Model((*Membership)(nil)).
+		Where("org_id = ?", orgID).
+		Where("user_id = ?", userID).
+		Exec(ctx)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+	return sqlResult.RowsAffected()
+}
@@ -0,0 +1,113 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+)
+
+func TestOrgRepo(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	orgRepo := models.NewOrgRepo(db)
+	repoRepo := models.NewRepositoryRepo(db)
+
+	newOrg := func(t *testing.T) *models.Organization {
+		orgModel := &models.Organization{}
+		require.NoError(t, gofakeit.Struct(orgModel))
+		org, err := orgRepo.Insert(ctx, orgModel)
+		require.NoError(t, err)
+		return org
+	}
+
+	t.Run("insert and get", func(t *testing.T) {
+		org := newOrg(t)
+		require.NotEqual(t, uuid.Nil, org.ID)
+
+		actualOrg, err := orgRepo.Get(ctx, models.NewGetOrgParams().SetID(org.ID).SetName(org.Name))
+		require.NoError(t, err)
+		require.True(t, cmp.Equal(org, actualOrg, testhelper.DBTimeCmpOpt))
+
+		_, err = orgRepo.Insert(ctx, &models.Organization{Name: org.Name, CreatorID: uuid.New(), CreatedAt: time.Now(), UpdatedAt: time.Now()})
+		require.ErrorIs(t, err, models.ErrAlreadyExists)
+	})
+
+	t.Run("update", func(t *testing.T) {
+		org := newOrg(t)
+		require.NoError(t, orgRepo.UpdateByID(ctx, models.NewUpdateOrgParams(org.ID).SetDescription("data team")))
+
+		actualOrg, err := orgRepo.Get(ctx, models.NewGetOrgParams().SetID(org.ID))
+		require.NoError(t, err)
+		require.Equal(t, "data team", *actualOrg.Description)
+	})
+
+	t.Run("members and repositories", func(t *testing.T) {
+		userID := uuid.New()
+		orgA, orgB, orgC := newOrg(t), newOrg(t), newOrg(t)
+		for _, org := range []*models.Organization{orgA, orgB} {
+			_, err := orgRepo.AddMember(ctx, &models.Membership{OrgID: org.ID, UserID: userID, Role: models.OrgRoleMember, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+			require.NoError(t, err)
+		}
+		_, err := orgRepo.AddMember(ctx, &models.Membership{OrgID: orgA.ID, UserID: userID, Role: models.OrgRoleAdmin, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+		require.ErrorIs(t, err, models.ErrAlreadyExists)
+
+		orgs, _, err := orgRepo.List(ctx, models.NewListOrgParams().SetMemberID(userID))
+		require.NoError(t, err)
+		require.Len(t, orgs, 2)
+
+		require.NoError(t, orgRepo.UpdateRole(ctx, orgA.ID, userID, models.OrgRoleAdmin))
+		membership, err := orgRepo.GetMembership(ctx, orgA.ID, userID)
+		require.NoError(t, err)
+		require.Equal(t, models.OrgRoleAdmin, membership.Role)
+		require.ErrorIs(t, orgRepo.UpdateRole(ctx, orgC.ID, userID, models.OrgRoleAdmin), models.ErrNotFound)
+
+		admins, _, err := orgRepo.ListMemberships(ctx, models.NewListMembershipParams().SetUserID(userID).SetRole(models.OrgRoleAdmin))
+		require.NoError(t, err)
+		require.Len(t, admins, 1)
+
+		for _, org := range []*models.Organization{orgA, orgB, orgC} {
+			repoModel := &models.Repository{}
+			require.NoError(t, gofakeit.Struct(repoModel))
+			repoModel.OwnerID = org.ID
+			_, err = repoRepo.Insert(ctx, repoModel)
+			require.NoError(t, err)
+		}
+		repos, _, err := repoRepo.List(ctx, models.NewListRepoParams().SetOrgMemberID(userID).SetAmount(10))
+		require.NoError(t, err)
+		require.Len(t, repos, 2)
+
+		affected, err := orgRepo.RemoveMember(ctx, orgB.ID, userID)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), affected)
+		repos, _, err = repoRepo.List(ctx, models.NewListRepoParams().SetOrgMemberID(userID).SetAmount(10))
+		require.NoError(t, err)
+		require.Len(t, repos, 1)
+		require.Equal(t, orgA.ID, repos[0].OwnerID)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		org := newOrg(t)
+		_, err := orgRepo.AddMember(ctx, &models.Membership{OrgID: org.ID, UserID: uuid.New(), Role: models.OrgRoleOwner, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+		require.NoError(t, err)
+
+		affected, err := orgRepo.Delete(ctx, org.ID)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), affected)
+
+		_, err = orgRepo.Get(ctx, models.NewGetOrgParams().SetID(org.ID))
+		require.ErrorIs(t, err, models.ErrNotFound)
+		memberships, _, err := orgRepo.ListMemberships(ctx, models.NewListMembershipParams().SetOrgID(org.ID))
+		require.NoError(t, err)
+		require.Empty(t, memberships)
+	})
+}
@@ -0,0 +1,182 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// Pack container file storing content of many small blobs one after another, it is stored in storage by its checksum
+type Pack struct {
+	bun.BaseModel `bun:"table:packs"`
+	RepositoryID  uuid.UUID `bun:"repository_id,pk,type:uuid,notnull" json:"repository_id"`
+	CheckSum      hash.Hash `bun:"check_sum,pk,type:bytea" json:"check_sum"`
+	Size          int64     `bun:"size,notnull" json:"size"`
+	Objects       int       `bun:"objects,notnull" json:"objects"`
+
+	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+}
+
+// PackedObject index of blob content stored in pack, CheckSum is checksum of blob content the same as Blob.CheckSum
+type PackedObject struct {
+	bun.BaseModel `bun:"table:packed_objects"`
+	RepositoryID  uuid.UUID `bun:"repository_id,pk,type:uuid,notnull" json:"repository_id"`
+	CheckSum      hash.Hash `bun:"check_sum,pk,type:bytea" json:"check_sum"`
+	PackCheckSum  hash.Hash `bun:"pack_check_sum,type:bytea,notnull" json:"pack_check_sum"`
+	Offset        int64     `bun:"pack_offset,notnull" json:"offset"`
+	Size          int64     `bun:"size,notnull" json:"size"`
+
+	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+}
+
+type IPackRepo interface {
+	// Insert save pack with index of objects in it, objects already packed are kept in the pack they are
+	Insert(ctx context.Context, pack *Pack, objects []*PackedObject) error
+	GetObject(ctx context.Context, repositoryID uuid.UUID, checkSum hash.Hash) (*PackedObject, error)
+	List(ctx context.Context, repositoryID uuid.UUID) ([]*Pack, error)
+	ListObjects(ctx context.Context, repositoryID uuid.UUID) ([]*PackedObject, error)
+	// DeleteObjects remove objects from index, content is left in pack until pack is deleted
+	DeleteObjects(ctx context.Context, repositoryID uuid.UUID, checkSums []hash.Hash) (int64, error)
+	// DeletePack remove pack together with index of objects still in it
+	DeletePack(ctx context.Context, repositoryID uuid.UUID, checkSum hash.Hash) (int64, error)
+	// Replace save pack and move index of objects onto it, then remove superseded packs together with index of objects
+	// not moved
+	Replace(ctx context.Context, pack *Pack, objects []*PackedObject, superseded []hash.Hash) error
+	// Delete remove all packs and index of repository
+	Delete(ctx context.Context, repositoryID uuid.UUID) (int64, error)
+}
+
+var _ IPackRepo = (*PackRepo)(nil)
+
+type PackRepo struct {
+	db bun.IDB
+}
+
+func NewPackRepo(db bun.IDB) IPackRepo {
+	return &PackRepo{db: db}
+}
+
+func (p *PackRepo) Insert(ctx context.Context, pack *Pack, objects []*PackedObject) error {
+	_, err := p.db.NewInsert().Model(pack).Exec(ctx)
+	if err != nil {
+		return toModelError(err)
+	}
+	if len(objects) == 0 {
+		return nil
+	}
+	_, err = p.db.NewInsert().Model(&objects).On("CONFLICT (repository_id, check_sum) DO NOTHING").Exec(ctx)
+	return toModelError(err)
+}
+
+func (p *PackRepo) GetObject(ctx context.Context, repositoryID uuid.UUID, checkSum hash.Hash) (*PackedObject, error) {
+	object := &PackedObject{}
+	err := p.db.NewSelect().Model(object).
+		Where("repository_id = ?", repositoryID).
+		Where("check_sum = ?", checkSum).
+		Limit(1).
+		Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return object, nil
+}
+
+func (p *PackRepo) List(ctx context.Context, repositoryID uuid.UUID) ([]*Pack, error) {
+	var packs []*Pack
+	err := p.db.NewSelect().Model(&packs).Where("repository_id = ?", repositoryID).Order("created_at ASC").Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return packs, nil
+}
+
+func (p *PackRepo) ListObjects(ctx context.Context, repositoryID uuid.UUID) ([]*PackedObject, error) {
+	var objects []*PackedObject
+	err := p.db.NewSelect().Model(&objects).Where("repository_id = ?", repositoryID).Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return objects, nil
+}
+
+func (p *PackRepo) DeleteObjects(ctx context.Context, repositoryID uuid.UUID, checkSums []hash.Hash) (int64, error) {
+	var deleted int64
+	for start := 0; start < len(checkSums); start += objectBatchSize {
+		// bun expand element of named slice type as a nested list, pass raw bytes instead
+		batch := make([][]byte, 0, objectBatchSize)
+		for _, checkSum := range checkSums[start:min(start+objectBatchSize, len(checkSums))] {
+			batch = append(batch, checkSum)
+		}
+		sqlResult, err := p.db.NewDelete().Model((*PackedObject)(nil)).
+			Where("repository_id = ?", repositoryID).
+			Where("check_sum IN (?)", bun.In(batch)).
+			Exec(ctx)
+		if err != nil {
+			return 0, toModelError(err)
+		}
+		affectedRows, err := sqlResult.RowsAffected()
+		if err != nil {
+			return 0, err
+		}
+		deleted += affectedRows
+	}
+	return deleted, nil
+}
+
+func (p *PackRepo) DeletePack(ctx context.Context, repositoryID uuid.UUID, checkSum hash.Hash) (int64, error) {
+	_, err := p.db.NewDelete().Model((*PackedObject)(nil)).
+		Where("repository_id = ?", repositoryID).
+		Where("pack_check_sum = ?", checkSum).
+		Exec(ctx)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+	sqlResult, err := p.db.NewDelete().Model((*Pack)(nil)).
+		Where("repository_id = ?", repositoryID).
+		Where("check_sum = ?", checkSum).
+		Exec(ctx)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+	return sqlResult.RowsAffected()
+}
+
+func (p *PackRepo) Replace(ctx context.Context, pack *Pack, objects []*PackedObject, superseded []hash.Hash) error {
+	_, err := p.db.NewInsert().Model(pack).Exec(ctx)
+	if err != nil {
+		return toModelError(err)
+	}
+	if len(objects) > 0 {
+		_, err = p.db.NewInsert().Model(&objects).
+			On("CONFLICT (repository_id, check_sum) DO UPDATE").
+			Set("pack_check_sum = EXCLUDED.pack_check_sum").
+			Set("pack_offset = EXCLUDED.pack_offset").
+			Set("size = EXCLUDED.size").
+			Exec(ctx)
+		if err != nil {
+			return toModelError(err)
+		}
+	}
+	for _, checkSum := range superseded {
+		_, err = p.DeletePack(ctx, pack.RepositoryID, checkSum)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (p *PackRepo) Delete(ctx context.Context, repositoryID uuid.UUID) (int64, error) {
+	_, err := p.db.NewDelete().Model((*PackedObject)(nil)).Where("repository_id = ?", repositoryID).Exec(ctx)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+	sqlResult, err := p.db.NewDelete().Model((*Pack)(nil)).Where("repository_id = ?", repositoryID).Exec(ctx)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+	return sqlResult.RowsAffected()
+}
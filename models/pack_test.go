@@ -0,0 +1,106 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPackRepo(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewPackRepo(db)
+	repositoryID := uuid.New()
+
+	insertPack := func(packCheckSum string, checkSums ...string) {
+		var objects []*models.PackedObject
+		var offset int64
+		for _, checkSum := range checkSums {
+			objects = append(objects, &models.PackedObject{
+				RepositoryID: repositoryID,
+				CheckSum:     hash.Hash(checkSum),
+				PackCheckSum: hash.Hash(packCheckSum),
+				Offset:       offset,
+				Size:         int64(len(checkSum)),
+				CreatedAt:    time.Now(),
+			})
+			offset += int64(len(checkSum))
+		}
+		err := repo.Insert(ctx, &models.Pack{
+			RepositoryID: repositoryID,
+			CheckSum:     hash.Hash(packCheckSum),
+			Size:         offset,
+			Objects:      len(objects),
+			CreatedAt:    time.Now(),
+		}, objects)
+		require.NoError(t, err)
+	}
+	insertPack("pack1", "a", "bb")
+	// object already packed is kept in its pack
+	insertPack("pack2", "bb", "ccc")
+
+	object, err := repo.GetObject(ctx, repositoryID, hash.Hash("bb"))
+	require.NoError(t, err)
+	require.Equal(t, hash.Hash("pack1"), object.PackCheckSum)
+	require.Equal(t, int64(1), object.Offset)
+	require.Equal(t, int64(2), object.Size)
+
+	_, err = repo.GetObject(ctx, uuid.New(), hash.Hash("bb"))
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	packs, err := repo.List(ctx, repositoryID)
+	require.NoError(t, err)
+	require.Len(t, packs, 2)
+	objects, err := repo.ListObjects(ctx, repositoryID)
+	require.NoError(t, err)
+	require.Len(t, objects, 3)
+
+	affectedRows, err := repo.DeleteObjects(ctx, repositoryID, []hash.Hash{hash.Hash("a"), hash.Hash("ccc")})
+	require.NoError(t, err)
+	require.Equal(t, int64(2), affectedRows)
+
+	affectedRows, err = repo.DeletePack(ctx, repositoryID, hash.Hash("pack1"))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), affectedRows)
+	_, err = repo.GetObject(ctx, repositoryID, hash.Hash("bb"))
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	insertPack("pack3", "d", "ee")
+	err = repo.Replace(ctx, &models.Pack{
+		RepositoryID: repositoryID,
+		CheckSum:     hash.Hash("pack4"),
+		Size:         2,
+		Objects:      1,
+		CreatedAt:    time.Now(),
+	}, []*models.PackedObject{{
+		RepositoryID: repositoryID,
+		CheckSum:     hash.Hash("ee"),
+		PackCheckSum: hash.Hash("pack4"),
+		Offset:       0,
+		Size:         2,
+		CreatedAt:    time.Now(),
+	}}, []hash.Hash{hash.Hash("pack2"), hash.Hash("pack3")})
+	require.NoError(t, err)
+	object, err = repo.GetObject(ctx, repositoryID, hash.Hash("ee"))
+	require.NoError(t, err)
+	require.Equal(t, hash.Hash("pack4"), object.PackCheckSum)
+	require.Equal(t, int64(0), object.Offset)
+	// objects left in superseded pack are dropped with it
+	_, err = repo.GetObject(ctx, repositoryID, hash.Hash("d"))
+	require.ErrorIs(t, err, models.ErrNotFound)
+	packs, err = repo.List(ctx, repositoryID)
+	require.NoError(t, err)
+	require.Len(t, packs, 1)
+
+	affectedRows, err = repo.Delete(ctx, repositoryID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), affectedRows)
+}
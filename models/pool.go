@@ -0,0 +1,126 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// DefaultPingTimeout how long a health check wait for database to answer
+const DefaultPingTimeout = 5 * time.Second
+
+// PoolStats connections of database pool
+type PoolStats struct {
+	MaxOpenConnections int
+	OpenConnections    int
+	InUse              int
+	Idle               int
+	// WaitCount total number of connections waited for because pool was exhausted
+	WaitCount    int64
+	WaitDuration time.Duration
+	// MaxIdleClosed total number of connections closed because pool kept too many idle connections
+	MaxIdleClosed     int64
+	MaxLifetimeClosed int64
+}
+
+// IPoolMonitor expose state of database pool for metrics and health checks
+type IPoolMonitor interface {
+	Stats() PoolStats
+	// Ping return error if database can not be reached within timeout
+	Ping(ctx context.Context) error
+}
+
+var _ IPoolMonitor = (*PoolMonitor)(nil)
+
+type PoolMonitor struct {
+	db      *sql.DB
+	timeout time.Duration
+}
+
+// NewPoolMonitor monitor pool of db, ping wait DefaultPingTimeout if timeout is not positive
+func NewPoolMonitor(db *sql.DB, timeout time.Duration) IPoolMonitor {
+	if timeout <= 0 {
+		timeout = DefaultPingTimeout
+	}
+	return &PoolMonitor{db: db, timeout: timeout}
+}
+
+func (monitor *PoolMonitor) Stats() PoolStats {
+	stats := monitor.db.Stats()
+	return PoolStats{
+		MaxOpenConnections: stats.MaxOpenConnections,
+		OpenConnections:    stats.OpenConnections,
+		InUse:              stats.InUse,
+		Idle:               stats.Idle,
+		WaitCount:          stats.WaitCount,
+		WaitDuration:       stats.WaitDuration,
+		MaxIdleClosed:      stats.MaxIdleClosed,
+		MaxLifetimeClosed:  stats.MaxLifetimeClosed,
+	}
+}
+
+func (monitor *PoolMonitor) Ping(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, monitor.timeout)
+	defer cancel()
+	err := monitor.db.PingContext(ctx)
+	if err != nil {
+		return fmt.Errorf("%w %w", ErrDatabaseUnavailable, err)
+	}
+	return nil
+}
+
+var _ prometheus.Collector = (*PoolCollector)(nil)
+
+// PoolCollector report stats of database pool to prometheus when scraped
+type PoolCollector struct {
+	monitor IPoolMonitor
+
+	maxOpen           *prometheus.Desc
+	open              *prometheus.Desc
+	inUse             *prometheus.Desc
+	idle              *prometheus.Desc
+	waitCount         *prometheus.Desc
+	waitDuration      *prometheus.Desc
+	maxIdleClosed     *prometheus.Desc
+	maxLifetimeClosed *prometheus.Desc
+}
+
+func NewPoolCollector(monitor IPoolMonitor) *PoolCollector {
+	return &PoolCollector{
+		monitor:           monitor,
+		maxOpen:           prometheus.NewDesc("db_pool_max_open_connections", "Maximum number of open connections to the database.", nil, nil),
+		open:              prometheus.NewDesc("db_pool_open_connections", "The number of established connections both in use and idle.", nil, nil),
+		inUse:             prometheus.NewDesc("db_pool_in_use_connections", "The number of connections currently in use.", nil, nil),
+		idle:              prometheus.NewDesc("db_pool_idle_connections", "The number of idle connections.", nil, nil),
+		waitCount:         prometheus.NewDesc("db_pool_wait_count_total", "The total number of connections waited for.", nil, nil),
+		waitDuration:      prometheus.NewDesc("db_pool_wait_duration_seconds_total", "The total time blocked waiting for a new connection.", nil, nil),
+		maxIdleClosed:     prometheus.NewDesc("db_pool_max_idle_closed_total", "The total number of connections closed due to too many idle connections.", nil, nil),
+		maxLifetimeClosed: prometheus.NewDesc("db_pool_max_lifetime_closed_total", "The total number of connections closed due to connection max lifetime.", nil, nil),
+	}
+}
+
+func (collector *PoolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- collector.maxOpen
+	ch <- collector.open
+	ch <- collector.inUse
+	ch <- collector.idle
+	ch <- collector.waitCount
+	ch <- collector.waitDuration
+	ch <- collector.maxIdleClosed
+	ch <- collector.maxLifetimeClosed
+}
+
+func (collector *PoolCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := collector.monitor.Stats()
+	ch <- prometheus.MustNewConstMetric(collector.maxOpen, prometheus.GaugeValue, float64(stats.MaxOpenConnections))
+	ch <- prometheus.MustNewConstMetric(collector.open, prometheus.GaugeValue, float64(stats.OpenConnections))
+	ch <- prometheus.MustNewConstMetric(collector.inUse, prometheus.GaugeValue, float64(stats.InUse))
+	ch <- prometheus.MustNewConstMetric(collector.idle, prometheus.GaugeValue, float64(stats.Idle))
+	ch <- prometheus.MustNewConstMetric(collector.waitCount, prometheus.CounterValue, float64(stats.WaitCount))
+	ch <- prometheus.MustNewConstMetric(collector.waitDuration, prometheus.CounterValue, stats.WaitDuration.Seconds())
+	ch <- prometheus.MustNewConstMetric(collector.maxIdleClosed, prometheus.CounterValue, float64(stats.MaxIdleClosed))
+	ch <- prometheus.MustNewConstMetric(collector.maxLifetimeClosed, prometheus.CounterValue, float64(stats.MaxLifetimeClosed))
+}
@@ -0,0 +1,28 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPoolMonitor(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupSQLiteDatabase(ctx, t)
+
+	monitor := models.NewPoolMonitor(db.DB, 0)
+	require.NoError(t, monitor.Ping(ctx))
+
+	stats := monitor.Stats()
+	require.GreaterOrEqual(t, stats.OpenConnections, 1)
+	require.Equal(t, stats.OpenConnections, stats.InUse+stats.Idle)
+
+	require.Equal(t, 8, testutil.CollectAndCount(models.NewPoolCollector(monitor)))
+
+	closeDB()
+	require.ErrorIs(t, monitor.Ping(ctx), models.ErrDatabaseUnavailable)
+}
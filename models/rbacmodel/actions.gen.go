@@ -9,10 +9,14 @@ var Actions = []string{
 	"repo:DeleteRepository",
 	"repo:ListRepositories",
 	"repo:UpdateVisible",
+	"repo:ArchiveRepository",
+	"repo:UpdateLimits",
+	"repo:UsageReport",
 	"repo:ReadObject",
 	"repo:WriteObject",
 	"repo:DeleteObject",
 	"repo:ListObjects",
+	"repo:UnmaskObject",
 	"repo:CreateCommit",
 	"repo:ReadCommit",
 	"repo:ListCommits",
@@ -53,6 +57,10 @@ var Actions = []string{
 	"auth:ListPolicies",
 	"auth:AttachPolicy",
 	"auth:DetachPolicy",
+	"auth:ListAuditLogs",
+	"auth:CheckConsistency",
+	"auth:RollbackRepository",
+	"auth:UnarchiveRepository",
 	"user:UserProfile",
 	"user:ReadUser",
 	"user:ListUsers",
@@ -21,11 +21,18 @@ const (
 	ListRepositoriesAction = "repo:ListRepositories"
 
 	UpdateVisibleAction = "repo:UpdateVisible"
+	// ArchiveRepositoryAction make repository read only, granted to repository admins
+	ArchiveRepositoryAction = "repo:ArchiveRepository"
+	UpdateLimitsAction      = "repo:UpdateLimits"
+	// UsageReportAction generate and download usage report of repository, granted to repository admins
+	UsageReportAction = "repo:UsageReport"
 
 	ReadObjectAction   = "repo:ReadObject"
 	WriteObjectAction  = "repo:WriteObject"
 	DeleteObjectAction = "repo:DeleteObject"
 	ListObjectsAction  = "repo:ListObjects"
+	// UnmaskObjectAction read csv and jsonl files without masking rules of repository applied
+	UnmaskObjectAction = "repo:UnmaskObject"
 
 	CreateCommitAction = "repo:CreateCommit"
 	ReadCommitAction   = "repo:ReadCommit"
@@ -75,6 +82,14 @@ const (
 	AttachPolicyAction = "auth:AttachPolicy"
 	DetachPolicyAction = "auth:DetachPolicy"
 
+	ListAuditLogsAction = "auth:ListAuditLogs"
+	// CheckConsistencyAction compare metadata with storage and repair or quarantine, granted to admins on all resources
+	CheckConsistencyAction = "auth:CheckConsistency"
+	// RollbackRepositoryAction roll all refs of repository back to a past time, granted to admins on all resources
+	RollbackRepositoryAction = "auth:RollbackRepository"
+	// UnarchiveRepositoryAction make archived repository writable again, granted to admins on all resources
+	UnarchiveRepositoryAction = "auth:UnarchiveRepository"
+
 	UserProfileAction       = "user:UserProfile"
 	ReadUserAction          = "user:ReadUser"
 	ListUsersAction         = "user:ListUsers"
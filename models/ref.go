@@ -0,0 +1,254 @@
+package models
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// RefNamespace prefix of full ref name, the same as git so refs can be advertised to git clients as they are
+type RefNamespace string
+
+const (
+	BranchNamespace RefNamespace = "refs/heads/"
+	TagNamespace    RefNamespace = "refs/tags/"
+)
+
+// FullName full ref name of short branch or tag name in namespace
+func (namespace RefNamespace) FullName(name string) string {
+	return string(namespace) + name
+}
+
+// ParseRefName split full ref name into namespace and short name
+func ParseRefName(fullName string) (RefNamespace, string, error) {
+	for _, namespace := range []RefNamespace{BranchNamespace, TagNamespace} {
+		if name, ok := strings.CutPrefix(fullName, string(namespace)); ok && len(name) > 0 {
+			return namespace, name, nil
+		}
+	}
+	return "", "", fmt.Errorf("ref %s not in namespace refs/heads/ or refs/tags/", fullName)
+}
+
+// Ref branch or tag under its full name. refs are not stored twice, branches and tags tables are read together as a
+// single namespace, ID is the id of branch or tag
+type Ref struct {
+	bun.BaseModel `bun:"table:refs,alias:ref"`
+	ID            uuid.UUID `bun:"id,type:uuid" json:"id"`
+	RepositoryID  uuid.UUID `bun:"repository_id,type:uuid" json:"repository_id"`
+	// Name full name of ref like refs/heads/main
+	Name string `bun:"name" json:"name"`
+	// Hash commit of branch or target of tag
+	Hash      hash.Hash `bun:"hash,type:bytea" json:"hash"`
+	CreatorID uuid.UUID `bun:"creator_id,type:uuid" json:"creator_id"`
+
+	CreatedAt time.Time `bun:"created_at,type:timestamp" json:"created_at"`
+	UpdatedAt time.Time `bun:"updated_at,type:timestamp" json:"updated_at"`
+}
+
+// Namespace of ref
+func (ref *Ref) Namespace() RefNamespace {
+	namespace, _, _ := ParseRefName(ref.Name)
+	return namespace
+}
+
+// ShortName name of branch or tag
+func (ref *Ref) ShortName() string {
+	_, name, _ := ParseRefName(ref.Name)
+	return name
+}
+
+type GetRefParams struct {
+	repositoryID uuid.UUID
+	name         string
+}
+
+func NewGetRefParams() *GetRefParams {
+	return &GetRefParams{}
+}
+
+func (gp *GetRefParams) SetRepositoryID(repositoryID uuid.UUID) *GetRefParams {
+	gp.repositoryID = repositoryID
+	return gp
+}
+
+// SetName full name of ref
+func (gp *GetRefParams) SetName(name string) *GetRefParams {
+	gp.name = name
+	return gp
+}
+
+type ListRefParams struct {
+	RepositoryID uuid.UUID
+	Namespaces   []RefNamespace
+	Name         *string
+	NameMatch    MatchMode
+	Sort         ListSort
+	After        *string
+	AfterTime    *time.Time
+	AfterID      uuid.UUID
+	Amount       int
+}
+
+func NewListRefParams() *ListRefParams {
+	return &ListRefParams{}
+}
+
+func (lp *ListRefParams) SetRepositoryID(repositoryID uuid.UUID) *ListRefParams {
+	lp.RepositoryID = repositoryID
+	return lp
+}
+
+// SetNamespaces only list refs in namespaces, refs of all namespaces are listed if not set
+func (lp *ListRefParams) SetNamespaces(namespaces ...RefNamespace) *ListRefParams {
+	lp.Namespaces = namespaces
+	return lp
+}
+
+// SetName filter refs by full name
+func (lp *ListRefParams) SetName(name string, match MatchMode) *ListRefParams {
+	lp.Name = &name
+	lp.NameMatch = match
+	return lp
+}
+
+// SetSort order refs by field, default is full name in ascending order
+func (lp *ListRefParams) SetSort(field SortField, direction SortDirection) *ListRefParams {
+	lp.Sort = ListSort{Field: field, Direction: direction}
+	return lp
+}
+
+// SetAfter list refs whose full name is after after, used when sorted by name
+func (lp *ListRefParams) SetAfter(after string) *ListRefParams {
+	lp.After = &after
+	return lp
+}
+
+// SetAfterTime list refs after this time, used when sorted by time
+func (lp *ListRefParams) SetAfterTime(after time.Time) *ListRefParams {
+	lp.AfterTime = &after
+	return lp
+}
+
+// SetAfterID id of the last ref in previous page, refs with the same sort value are ordered by id
+func (lp *ListRefParams) SetAfterID(id uuid.UUID) *ListRefParams {
+	lp.AfterID = id
+	return lp
+}
+
+func (lp *ListRefParams) cursor() interface{} {
+	if len(lp.Sort.Field) == 0 || lp.Sort.Field == SortByName {
+		if lp.After != nil {
+			return *lp.After
+		}
+		return nil
+	}
+	if lp.AfterTime != nil {
+		return *lp.AfterTime
+	}
+	return nil
+}
+
+func (lp *ListRefParams) SetAmount(amount int) *ListRefParams {
+	lp.Amount = amount
+	return lp
+}
+
+type IRefRepo interface {
+	Get(ctx context.Context, params *GetRefParams) (*Ref, error)
+	// List refs ordered by full name unless sort is set
+	List(ctx context.Context, params *ListRefParams) ([]*Ref, bool, error)
+	// Count number of refs matching params, pagination of params is ignored
+	Count(ctx context.Context, params *ListRefParams) (int, error)
+}
+
+var _ IRefRepo = (*RefRepo)(nil)
+
+type RefRepo struct {
+	db bun.IDB
+}
+
+func NewRefRepo(db bun.IDB) IRefRepo {
+	return &RefRepo{db: db}
+}
+
+func (r RefRepo) Get(ctx context.Context, params *GetRefParams) (*Ref, error) {
+	namespace, name, err := ParseRefName(params.name)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	ref := &Ref{}
+	source := r.namespaceQuery(namespace, params.repositoryID).Where("name = ?", name)
+	err = r.db.NewSelect().Model(ref).ModelTableExpr("(?) AS ref", source).Limit(1).Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return ref, nil
+}
+
+func (r RefRepo) List(ctx context.Context, params *ListRefParams) ([]*Ref, bool, error) {
+	refs := make([]*Ref, 0)
+	defaultSort := ListSort{Field: SortByName, Direction: SortAsc}
+	cursor, err := params.Sort.exactCursor(ctx, r.listQuery(&[]*Ref{}, params), "ref", defaultSort, params.cursor(), params.AfterID)
+	if err != nil {
+		return nil, false, toModelError(err)
+	}
+	query := params.Sort.apply(r.listQuery(&refs, params), "ref", defaultSort, cursor, params.AfterID)
+	if params.Amount > 0 {
+		query = query.Limit(params.Amount)
+	}
+
+	err = query.Scan(ctx)
+	if err != nil {
+		return nil, false, toModelError(err)
+	}
+	return refs, params.Amount > 0 && len(refs) == params.Amount, nil
+}
+
+func (r RefRepo) Count(ctx context.Context, params *ListRefParams) (int, error) {
+	var refs []*Ref
+	return r.listQuery(&refs, params).Count(ctx)
+}
+
+func (r RefRepo) listQuery(refs *[]*Ref, params *ListRefParams) *bun.SelectQuery {
+	namespaces := params.Namespaces
+	if len(namespaces) == 0 {
+		namespaces = []RefNamespace{BranchNamespace, TagNamespace}
+	}
+
+	// compound select is built by hand, sqlite refuses members of union enclosed in parentheses
+	sources := make([]interface{}, len(namespaces))
+	placeholders := make([]string, len(namespaces))
+	for index, namespace := range namespaces {
+		sources[index] = r.namespaceQuery(namespace, params.RepositoryID)
+		placeholders[index] = "?"
+	}
+
+	query := r.db.NewSelect().Model(refs).ModelTableExpr("("+strings.Join(placeholders, " UNION ALL ")+") AS ref", sources...)
+	if params.Name != nil {
+		query = params.NameMatch.apply(query, "ref.name", *params.Name)
+	}
+	return query
+}
+
+// namespaceQuery select refs of one namespace from table of branches or tags, short names are prefixed with namespace
+func (r RefRepo) namespaceQuery(namespace RefNamespace, repositoryID uuid.UUID) *bun.SelectQuery {
+	var query *bun.SelectQuery
+	switch namespace {
+	case TagNamespace:
+		query = r.db.NewSelect().Model((*Tag)(nil)).
+			ColumnExpr("id, repository_id, ? || name AS name, target AS hash, tagger AS creator_id, created_at, updated_at", string(namespace))
+	default:
+		query = r.db.NewSelect().Model((*Branch)(nil)).
+			ColumnExpr("id, repository_id, ? || name AS name, commit_hash AS hash, creator_id, created_at, updated_at", string(namespace))
+	}
+	if uuid.Nil != repositoryID {
+		query = query.Where("repository_id = ?", repositoryID)
+	}
+	return query
+}
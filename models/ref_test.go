@@ -0,0 +1,173 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseRefName(t *testing.T) {
+	namespace, name, err := models.ParseRefName("refs/heads/feat/a")
+	require.NoError(t, err)
+	require.Equal(t, models.BranchNamespace, namespace)
+	require.Equal(t, "feat/a", name)
+
+	namespace, name, err = models.ParseRefName(models.TagNamespace.FullName("v1"))
+	require.NoError(t, err)
+	require.Equal(t, models.TagNamespace, namespace)
+	require.Equal(t, "v1", name)
+
+	_, _, err = models.ParseRefName("refs/heads/")
+	require.Error(t, err)
+	_, _, err = models.ParseRefName("main")
+	require.Error(t, err)
+}
+
+func TestRefRepoNamespaces(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	repositoryID := uuid.New()
+	creatorID := uuid.New()
+	for _, name := range []string{"main", "feat/a"} {
+		_, err := repo.BranchRepo().Insert(ctx, &models.Branch{
+			RepositoryID: repositoryID,
+			CommitHash:   hash.Hash(name),
+			Name:         name,
+			CreatorID:    creatorID,
+			CreatedAt:    time.Now(),
+			UpdatedAt:    time.Now(),
+		})
+		require.NoError(t, err)
+	}
+	tag, err := repo.TagRepo().Insert(ctx, &models.Tag{
+		RepositoryID: repositoryID,
+		Name:         "v1",
+		CreatorID:    creatorID,
+		Target:       hash.Hash("v1"),
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	})
+	require.NoError(t, err)
+	// other repository
+	_, err = repo.BranchRepo().Insert(ctx, &models.Branch{RepositoryID: uuid.New(), Name: "main", CommitHash: hash.Empty, CreatedAt: time.Now(), UpdatedAt: time.Now()})
+	require.NoError(t, err)
+
+	t.Run("get", func(t *testing.T) {
+		ref, err := repo.RefRepo().Get(ctx, models.NewGetRefParams().SetRepositoryID(repositoryID).SetName("refs/tags/v1"))
+		require.NoError(t, err)
+		require.Equal(t, tag.ID, ref.ID)
+		require.Equal(t, hash.Hash("v1"), ref.Hash)
+		require.Equal(t, creatorID, ref.CreatorID)
+		require.Equal(t, models.TagNamespace, ref.Namespace())
+		require.Equal(t, "v1", ref.ShortName())
+
+		_, err = repo.RefRepo().Get(ctx, models.NewGetRefParams().SetRepositoryID(repositoryID).SetName("refs/heads/v1"))
+		require.ErrorIs(t, err, models.ErrNotFound)
+		_, err = repo.RefRepo().Get(ctx, models.NewGetRefParams().SetRepositoryID(repositoryID).SetName("v1"))
+		require.ErrorIs(t, err, models.ErrNotFound)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		refs, hasMore, err := repo.RefRepo().List(ctx, models.NewListRefParams().SetRepositoryID(repositoryID))
+		require.NoError(t, err)
+		require.False(t, hasMore)
+		var names []string
+		for _, ref := range refs {
+			names = append(names, ref.Name)
+		}
+		require.Equal(t, []string{"refs/heads/feat/a", "refs/heads/main", "refs/tags/v1"}, names)
+
+		refs, hasMore, err = repo.RefRepo().List(ctx, models.NewListRefParams().SetRepositoryID(repositoryID).SetAmount(1).SetAfter("refs/heads/feat/a"))
+		require.NoError(t, err)
+		require.True(t, hasMore)
+		require.Equal(t, "refs/heads/main", refs[0].Name)
+		require.Equal(t, hash.Hash("main"), refs[0].Hash)
+
+		refs, _, err = repo.RefRepo().List(ctx, models.NewListRefParams().SetRepositoryID(repositoryID).SetNamespaces(models.TagNamespace))
+		require.NoError(t, err)
+		require.Len(t, refs, 1)
+		require.Equal(t, "refs/tags/v1", refs[0].Name)
+
+		refs, _, err = repo.RefRepo().List(ctx, models.NewListRefParams().SetRepositoryID(repositoryID).SetName("refs/heads/feat/", models.PrefixMatch))
+		require.NoError(t, err)
+		require.Len(t, refs, 1)
+
+		refs, _, err = repo.RefRepo().List(ctx, models.NewListRefParams().SetRepositoryID(repositoryID).SetName("^refs/(heads/main|tags/v[0-9]+)$", models.RegexMatch))
+		require.NoError(t, err)
+		require.Len(t, refs, 2)
+
+		refs, _, err = repo.RefRepo().List(ctx, models.NewListRefParams().SetRepositoryID(repositoryID).SetName("REFS/TAGS/", models.IPrefixMatch))
+		require.NoError(t, err)
+		require.Len(t, refs, 1)
+
+		count, err := repo.RefRepo().Count(ctx, models.NewListRefParams().SetRepositoryID(repositoryID).SetNamespaces(models.BranchNamespace))
+		require.NoError(t, err)
+		require.Equal(t, 2, count)
+	})
+
+	t.Run("sort", func(t *testing.T) {
+		names := func(refs []*models.Ref) []string {
+			var names []string
+			for _, ref := range refs {
+				names = append(names, ref.Name)
+			}
+			return names
+		}
+
+		refs, _, err := repo.RefRepo().List(ctx, models.NewListRefParams().SetRepositoryID(repositoryID).SetSort(models.SortByName, models.SortDesc))
+		require.NoError(t, err)
+		require.Equal(t, []string{"refs/tags/v1", "refs/heads/main", "refs/heads/feat/a"}, names(refs))
+
+		refs, _, err = repo.RefRepo().List(ctx, models.NewListRefParams().SetRepositoryID(repositoryID).SetSort(models.SortByCreatedAt, models.SortAsc))
+		require.NoError(t, err)
+		require.Equal(t, []string{"refs/heads/main", "refs/heads/feat/a", "refs/tags/v1"}, names(refs))
+
+		refs, hasMore, err := repo.RefRepo().List(ctx, models.NewListRefParams().SetRepositoryID(repositoryID).SetSort(models.SortByCreatedAt, models.SortAsc).SetAfterTime(refs[0].CreatedAt).SetAmount(1))
+		require.NoError(t, err)
+		require.True(t, hasMore)
+		require.Equal(t, []string{"refs/heads/feat/a"}, names(refs))
+	})
+
+	t.Run("page through same sort value", func(t *testing.T) {
+		sameTimeRepoID := uuid.New()
+		createdAt := time.UnixMicro(time.Now().UnixMilli()*1000 + 123)
+		for _, name := range []string{"a", "b", "c", "d"} {
+			_, err := repo.BranchRepo().Insert(ctx, &models.Branch{
+				RepositoryID: sameTimeRepoID,
+				CommitHash:   hash.Hash(name),
+				Name:         name,
+				CreatorID:    creatorID,
+				CreatedAt:    createdAt,
+				UpdatedAt:    createdAt,
+			})
+			require.NoError(t, err)
+		}
+
+		for _, direction := range []models.SortDirection{models.SortAsc, models.SortDesc} {
+			params := models.NewListRefParams().SetRepositoryID(sameTimeRepoID).SetSort(models.SortByCreatedAt, direction).SetAmount(1)
+			var listed []string
+			for i := 0; i < 4; i++ {
+				refs, _, err := repo.RefRepo().List(ctx, params)
+				require.NoError(t, err)
+				require.Len(t, refs, 1)
+				listed = append(listed, refs[0].Name)
+				// api send cursor in milliseconds
+				params.SetAfterTime(time.UnixMilli(refs[0].CreatedAt.UnixMilli())).SetAfterID(refs[0].ID)
+			}
+			require.ElementsMatch(t, []string{"refs/heads/a", "refs/heads/b", "refs/heads/c", "refs/heads/d"}, listed, direction)
+
+			refs, _, err := repo.RefRepo().List(ctx, params)
+			require.NoError(t, err)
+			require.Empty(t, refs, direction)
+		}
+	})
+}
@@ -0,0 +1,167 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/GitDataAI/jiaozifs/config"
+	"github.com/uptrace/bun"
+	"go.uber.org/fx"
+)
+
+// ErrInvalidConsistencyToken token presented by client is not returned by server
+var ErrInvalidConsistencyToken = errors.New("invalid consistency token")
+
+// ConsistencyToken position in write ahead log of primary database after a write, replica which has replayed the
+// position see the write. it is formatted like pg_lsn, such as 16/B374D848
+type ConsistencyToken uint64
+
+func (token ConsistencyToken) String() string {
+	return fmt.Sprintf("%X/%X", uint64(token)>>32, uint32(token))
+}
+
+func ParseConsistencyToken(str string) (ConsistencyToken, error) {
+	high, low, found := strings.Cut(str, "/")
+	if !found {
+		return 0, fmt.Errorf("%s %w", str, ErrInvalidConsistencyToken)
+	}
+	highValue, err := strconv.ParseUint(high, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%s %w", str, ErrInvalidConsistencyToken)
+	}
+	lowValue, err := strconv.ParseUint(low, 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("%s %w", str, ErrInvalidConsistencyToken)
+	}
+	return ConsistencyToken(highValue<<32 | lowValue), nil
+}
+
+type replicaReadKey struct{}
+
+// WithReplicaRead allow select statements run with ctx to read from replica, replica is only used if it has replayed
+// token, zero token accept any lag of replica. statements of ctx without it always run on primary, so reads which
+// decide a following write never see stale data
+func WithReplicaRead(ctx context.Context, token ConsistencyToken) context.Context {
+	return context.WithValue(ctx, replicaReadKey{}, token)
+}
+
+func replicaReadFromContext(ctx context.Context) (ConsistencyToken, bool) {
+	token, ok := ctx.Value(replicaReadKey{}).(ConsistencyToken)
+	return token, ok
+}
+
+// ReplicaDB run select statements allowed by WithReplicaRead on read replica of postgres and all others on primary.
+// select on replica fall back to primary if replica is behind the token of ctx or unreachable
+type ReplicaDB struct {
+	*RetryDB
+	replica *bun.DB
+	// replayed latest known replay position of replica, refreshed only when a token is ahead of it
+	replayed atomic.Uint64
+}
+
+var _ bun.IDB = (*ReplicaDB)(nil)
+
+func NewReplicaDB(primary *RetryDB, replica *bun.DB) *ReplicaDB {
+	return &ReplicaDB{RetryDB: primary, replica: replica}
+}
+
+// SetupReplicaDatabase connect replica of database config, nil is returned if no replica is configured
+func SetupReplicaDatabase(ctx context.Context, lc fx.Lifecycle, primary *RetryDB, dbConfig *config.DatabaseConfig) (*ReplicaDB, error) {
+	if len(dbConfig.ReplicaConnection) == 0 {
+		return nil, nil
+	}
+	if dbConfig.Type != "" && dbConfig.Type != config.DatabaseTypePostgres {
+		return nil, fmt.Errorf("read replica is only supported by postgres")
+	}
+
+	replicaConfig := *dbConfig
+	replicaConfig.Connection = dbConfig.ReplicaConnection
+	replica, err := SetupDatabase(ctx, lc, &replicaConfig)
+	if err != nil {
+		return nil, fmt.Errorf("connect replica %w", err)
+	}
+	return NewReplicaDB(primary, replica), nil
+}
+
+// ConsistencyToken current write position of primary, writes committed before it are visible to reads presenting it
+func (db *ReplicaDB) ConsistencyToken(ctx context.Context) (ConsistencyToken, error) {
+	var lsn string
+	err := db.RetryDB.NewRaw("SELECT pg_current_wal_lsn()::text").Scan(ctx, &lsn)
+	if err != nil {
+		return 0, err
+	}
+	return ParseConsistencyToken(lsn)
+}
+
+func (db *ReplicaDB) NewSelect() *bun.SelectQuery {
+	return db.RetryDB.NewSelect().Conn(replicaConn{db: db})
+}
+
+// useReplica check whether select of ctx can run on replica
+func (db *ReplicaDB) useReplica(ctx context.Context) bool {
+	token, ok := replicaReadFromContext(ctx)
+	if !ok {
+		return false
+	}
+	if uint64(token) <= db.replayed.Load() {
+		return true
+	}
+
+	// replica which is not in recovery is not lagging
+	var lsn string
+	err := db.replica.NewRaw("SELECT COALESCE(pg_last_wal_replay_lsn(), pg_current_wal_lsn())::text").Scan(ctx, &lsn)
+	if err != nil {
+		retryLog.Warnf("read replay position of replica %v", err)
+		return false
+	}
+	replayed, err := ParseConsistencyToken(lsn)
+	if err != nil {
+		return false
+	}
+	for {
+		current := db.replayed.Load()
+		if uint64(replayed) <= current || db.replayed.CompareAndSwap(current, uint64(replayed)) {
+			break
+		}
+	}
+	return token <= replayed
+}
+
+// replicaConn run select built by ReplicaDB on replica or through retryConn of primary
+type replicaConn struct {
+	db *ReplicaDB
+}
+
+func (conn replicaConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	if conn.db.useReplica(ctx) {
+		rows, err := conn.db.replica.QueryContext(ctx, query, args...) //nolint
+		if err == nil || !isConnectionError(err) {
+			return rows, err
+		}
+		retryLog.Warnf("read from primary as replica fail %v", err)
+	}
+	return retryConn{db: conn.db.RetryDB}.QueryContext(ctx, query, args...)
+}
+
+func (conn replicaConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	if conn.db.useReplica(ctx) {
+		result, err := conn.db.replica.ExecContext(ctx, query, args...)
+		if err == nil || !isConnectionError(err) {
+			return result, err
+		}
+		retryLog.Warnf("read from primary as replica fail %v", err)
+	}
+	return retryConn{db: conn.db.RetryDB}.ExecContext(ctx, query, args...)
+}
+
+func (conn replicaConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	if conn.db.useReplica(ctx) {
+		return conn.db.replica.QueryRowContext(ctx, query, args...)
+	}
+	return retryConn{db: conn.db.RetryDB}.QueryRowContext(ctx, query, args...)
+}
@@ -0,0 +1,100 @@
+package models_test
+
+import (
+	"context"
+	"strings"
+	"sync/atomic"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/config"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/require"
+	"github.com/uptrace/bun"
+)
+
+func TestConsistencyToken(t *testing.T) {
+	token, err := models.ParseConsistencyToken("16/B374D848")
+	require.NoError(t, err)
+	require.Equal(t, models.ConsistencyToken(0x16B374D848), token)
+	require.Equal(t, "16/B374D848", token.String())
+
+	for _, str := range []string{"", "16", "16/", "G/1", "1/100000000"} {
+		_, err = models.ParseConsistencyToken(str)
+		require.ErrorIs(t, err, models.ErrInvalidConsistencyToken, str)
+	}
+}
+
+// userSelectCounter count select of users table run on db
+type userSelectCounter struct {
+	count atomic.Int32
+}
+
+func (counter *userSelectCounter) BeforeQuery(ctx context.Context, _ *bun.QueryEvent) context.Context {
+	return ctx
+}
+
+func (counter *userSelectCounter) AfterQuery(_ context.Context, event *bun.QueryEvent) {
+	if strings.HasPrefix(event.Query, "SELECT") && strings.Contains(event.Query, `"users"`) {
+		counter.count.Add(1)
+	}
+}
+
+func TestReplicaDB(t *testing.T) {
+	ctx := context.Background()
+	closeDB, connStr, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	// primary serve as replica of itself, queries show which one is used
+	replica, err := models.NewBunDBFromConfig(ctx, &config.DatabaseConfig{Connection: connStr})
+	require.NoError(t, err)
+	defer replica.Close() //nolint
+	counter := &userSelectCounter{}
+	replica.AddQueryHook(counter)
+
+	replicaDB := models.NewReplicaDB(models.NewRetryDB(db, &config.DatabaseConfig{}), replica)
+	repo := models.NewRepo(replicaDB)
+
+	user := &models.User{}
+	require.NoError(t, gofakeit.Struct(user))
+	user, err = repo.UserRepo().Insert(ctx, user)
+	require.NoError(t, err)
+
+	token, err := replicaDB.ConsistencyToken(ctx)
+	require.NoError(t, err)
+
+	t.Run("primary without replica read", func(t *testing.T) {
+		_, err := repo.UserRepo().Get(ctx, models.NewGetUserParams().SetID(user.ID))
+		require.NoError(t, err)
+		require.Equal(t, int32(0), counter.count.Load())
+	})
+
+	t.Run("replica without token", func(t *testing.T) {
+		_, err := repo.UserRepo().Get(models.WithReplicaRead(ctx, 0), models.NewGetUserParams().SetID(user.ID))
+		require.NoError(t, err)
+		require.Equal(t, int32(1), counter.count.Load())
+	})
+
+	t.Run("replica replayed token", func(t *testing.T) {
+		_, err := repo.UserRepo().Get(models.WithReplicaRead(ctx, token), models.NewGetUserParams().SetID(user.ID))
+		require.NoError(t, err)
+		require.Equal(t, int32(2), counter.count.Load())
+	})
+
+	t.Run("primary if replica is behind", func(t *testing.T) {
+		_, err := repo.UserRepo().Get(models.WithReplicaRead(ctx, token+1<<40), models.NewGetUserParams().SetID(user.ID))
+		require.NoError(t, err)
+		require.Equal(t, int32(2), counter.count.Load())
+	})
+
+	t.Run("transaction on primary", func(t *testing.T) {
+		readCtx := models.WithReplicaRead(ctx, 0)
+		err := repo.Transaction(readCtx, func(repo models.IRepo) error {
+			_, err := repo.UserRepo().Get(readCtx, models.NewGetUserParams().SetID(user.ID))
+			return err
+		})
+		require.NoError(t, err)
+		require.Equal(t, int32(2), counter.count.Load())
+	})
+}
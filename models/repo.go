@@ -3,10 +3,13 @@ package models
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
 
 	"github.com/GitDataAI/jiaozifs/models/rbacmodel"
 	"github.com/google/uuid"
 	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
 )
 
 type MatchMode int
@@ -16,8 +19,158 @@ const (
 	PrefixMatch
 	SuffixMatch
 	LikeMatch
+	// IPrefixMatch ISuffixMatch ILikeMatch are case-insensitive variants of prefix, suffix and like match
+	IPrefixMatch
+	ISuffixMatch
+	ILikeMatch
+	// RegexMatch name is a regular expression, syntax supported by both postgres and go regexp should be used as
+	// sqlite evaluates it by go regexp
+	RegexMatch
+	IRegexMatch
 )
 
+// IsRegex whether name is matched as regular expression
+func (mode MatchMode) IsRegex() bool {
+	return mode == RegexMatch || mode == IRegexMatch
+}
+
+// apply filter query by column matched with name. postgres use ILIKE and ~* for case-insensitive variants, sqlite
+// compare lower case values and provide REGEXP by function registered in driver
+func (mode MatchMode) apply(query *bun.SelectQuery, column, name string) *bun.SelectQuery {
+	pg := query.Dialect().Name() == dialect.PG
+	iLike := func(pattern string) *bun.SelectQuery {
+		if pg {
+			return query.Where(column+" ILIKE ?", pattern)
+		}
+		return query.Where("lower("+column+") LIKE lower(?)", pattern)
+	}
+
+	switch mode {
+	case ExactMatch:
+		return query.Where(column+" = ?", name)
+	case PrefixMatch:
+		return query.Where(column+" LIKE ?", name+"%")
+	case SuffixMatch:
+		return query.Where(column+" LIKE ?", "%"+name)
+	case LikeMatch:
+		return query.Where(column+" LIKE ?", "%"+name+"%")
+	case IPrefixMatch:
+		return iLike(name + "%")
+	case ISuffixMatch:
+		return iLike("%" + name)
+	case ILikeMatch:
+		return iLike("%" + name + "%")
+	case RegexMatch:
+		if pg {
+			return query.Where(column+" ~ ?", name)
+		}
+		return query.Where(column+" REGEXP ?", name)
+	case IRegexMatch:
+		if pg {
+			return query.Where(column+" ~* ?", name)
+		}
+		return query.Where(column+" REGEXP ?", "(?i)"+name)
+	}
+	return query
+}
+
+// SortField column results of list queries are ordered by
+type SortField string
+
+const (
+	SortByName      SortField = "name"
+	SortByCreatedAt SortField = "created_at"
+	SortByUpdatedAt SortField = "updated_at"
+)
+
+type SortDirection string
+
+const (
+	SortAsc  SortDirection = "asc"
+	SortDesc SortDirection = "desc"
+)
+
+// ListSort order of list queries, rows with the same value of Field are ordered by id so pages are deterministic.
+// Direction defaults to ascending for name and descending for time
+type ListSort struct {
+	Field     SortField
+	Direction SortDirection
+}
+
+func (sort ListSort) Valid() bool {
+	switch sort.Field {
+	case SortByName, SortByCreatedAt, SortByUpdatedAt:
+	default:
+		return false
+	}
+	switch sort.Direction {
+	case "", SortAsc, SortDesc:
+		return true
+	}
+	return false
+}
+
+// exactCursor return value of sort field stored in row cursorID of query in place of time cursor. time cursor sent by
+// api is in milliseconds while time is stored with higher precision, comparing them would return rows on page
+// boundary again in ascending order and skip rows in descending order. cursor is kept if row is not found
+func (sort ListSort) exactCursor(ctx context.Context, query *bun.SelectQuery, alias string, def ListSort, cursor interface{}, cursorID uuid.UUID) (interface{}, error) {
+	if len(sort.Field) == 0 {
+		sort = def
+	}
+	if cursor == nil || cursorID == uuid.Nil || sort.Field == SortByName {
+		return cursor, nil
+	}
+
+	prefix := ""
+	if len(alias) > 0 {
+		prefix = alias + "."
+	}
+	var value time.Time
+	err := query.ColumnExpr(prefix+string(sort.Field)).Where(prefix+"id = ?", cursorID).Limit(1).Scan(ctx, &value)
+	if errors.Is(err, sql.ErrNoRows) {
+		return cursor, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return value, nil
+}
+
+// apply order query by sort on columns of table alias, default is used if sort is not set. when cursor is not nil only
+// rows after it in sort order are returned, it is value of sort field in the last row of previous page. cursorID is id
+// of that row, rows sharing the sort value are ordered by id so it is compared together with cursor to avoid skipping
+// them on page boundary
+func (sort ListSort) apply(query *bun.SelectQuery, alias string, def ListSort, cursor interface{}, cursorID uuid.UUID) *bun.SelectQuery {
+	if len(sort.Field) == 0 {
+		sort = def
+	}
+	direction := sort.Direction
+	if len(direction) == 0 {
+		direction = SortDesc
+		if sort.Field == SortByName {
+			direction = SortAsc
+		}
+	}
+
+	prefix := ""
+	if len(alias) > 0 {
+		prefix = alias + "."
+	}
+	column := prefix + string(sort.Field)
+	op, order := ">", "ASC"
+	if direction == SortDesc {
+		op, order = "<", "DESC"
+	}
+	if cursor != nil {
+		if cursorID == uuid.Nil {
+			query = query.Where(column+" "+op+" ?", cursor)
+		} else {
+			query = query.Where("("+column+", "+prefix+"id) "+op+" (?, ?)", cursor, cursorID)
+		}
+	}
+	return query.OrderExpr(column + " " + order).OrderExpr(prefix + "id " + order)
+}
+
 type TxOption func(*sql.TxOptions)
 
 func IsolationLevelOption(level sql.IsolationLevel) TxOption {
@@ -31,14 +184,32 @@ type IRepo interface {
 	UserRepo() IUserRepo
 	MergeRequestRepo() IMergeRequestRepo
 	FileTreeRepo(repoID uuid.UUID) IFileTreeRepo
+	BlobRefRepo() IBlobRefRepo
 	CommitRepo(repoID uuid.UUID) ICommitRepo
 	TagRepo() ITagRepo
 	BranchRepo() IBranchRepo
+	// RefRepo branches and tags in a single namespace of full ref names
+	RefRepo() IRefRepo
 	RepositoryRepo() IRepositoryRepo
 	WipRepo() IWipRepo
 	AkskRepo() IAkskRepo
+	ShareRepo() IShareRepo
+	AccessTokenRepo() IAccessTokenRepo
+	UploadSessionRepo() IUploadSessionRepo
+	EventRepo() IEventRepo
+	HashMappingRepo() IHashMappingRepo
+	FileSchemaRepo() IFileSchemaRepo
+	AuditLogRepo() IAuditLogRepo
+	PackRepo() IPackRepo
+	AttestationRepo() IAttestationRepo
+	RepositoryDeletionRepo() IRepositoryDeletionRepo
+	UsageReportRepo() IUsageReportRepo
+	ReviewCommentRepo() IReviewCommentRepo
+	ImportCheckpointRepo() IImportCheckpointRepo
+	CommitTicketRepo() ICommitTicketRepo
 
 	MemberRepo() IMemberRepo
+	OrgRepo() IOrgRepo
 	GroupRepo() rbacmodel.IGroupRepo
 	PolicyRepo() rbacmodel.IPolicyRepo
 	UserGroupRepo() rbacmodel.IUserGroupRepo
@@ -54,6 +225,9 @@ func NewRepo(db bun.IDB) IRepo {
 	}
 }
 
+// Transaction run fn with repo bound to a database transaction, all mutations made through repo are committed together
+// or rolled back if fn return error. Transaction of a repo already in transaction run in a savepoint, so operations of
+// versionmgr can be composed into a larger transaction by callers
 func (repo *PgRepo) Transaction(ctx context.Context, fn func(repo IRepo) error, opts ...TxOption) error {
 	sqlOpt := &sql.TxOptions{}
 	for _, opt := range opts {
@@ -92,6 +266,14 @@ func (repo *PgRepo) BranchRepo() IBranchRepo {
 	return NewBranchRepo(repo.db)
 }
 
+func (repo *PgRepo) RefRepo() IRefRepo {
+	return NewRefRepo(repo.db)
+}
+
+func (repo *PgRepo) BlobRefRepo() IBlobRefRepo {
+	return NewBlobRefRepo(repo.db)
+}
+
 func (repo *PgRepo) RepositoryRepo() IRepositoryRepo {
 	return NewRepositoryRepo(repo.db)
 }
@@ -104,10 +286,70 @@ func (repo *PgRepo) AkskRepo() IAkskRepo {
 	return NewAkskRepo(repo.db)
 }
 
+func (repo *PgRepo) ShareRepo() IShareRepo {
+	return NewShareRepo(repo.db)
+}
+
+func (repo *PgRepo) AccessTokenRepo() IAccessTokenRepo {
+	return NewAccessTokenRepo(repo.db)
+}
+
+func (repo *PgRepo) UploadSessionRepo() IUploadSessionRepo {
+	return NewUploadSessionRepo(repo.db)
+}
+
+func (repo *PgRepo) EventRepo() IEventRepo {
+	return NewEventRepo(repo.db)
+}
+
+func (repo *PgRepo) HashMappingRepo() IHashMappingRepo {
+	return NewHashMappingRepo(repo.db)
+}
+
+func (repo *PgRepo) FileSchemaRepo() IFileSchemaRepo {
+	return NewFileSchemaRepo(repo.db)
+}
+
+func (repo *PgRepo) AuditLogRepo() IAuditLogRepo {
+	return NewAuditLogRepo(repo.db)
+}
+
+func (repo *PgRepo) PackRepo() IPackRepo {
+	return NewPackRepo(repo.db)
+}
+
+func (repo *PgRepo) AttestationRepo() IAttestationRepo {
+	return NewAttestationRepo(repo.db)
+}
+
+func (repo *PgRepo) RepositoryDeletionRepo() IRepositoryDeletionRepo {
+	return NewRepositoryDeletionRepo(repo.db)
+}
+
+func (repo *PgRepo) UsageReportRepo() IUsageReportRepo {
+	return NewUsageReportRepo(repo.db)
+}
+
+func (repo *PgRepo) ReviewCommentRepo() IReviewCommentRepo {
+	return NewReviewCommentRepo(repo.db)
+}
+
+func (repo *PgRepo) ImportCheckpointRepo() IImportCheckpointRepo {
+	return NewImportCheckpointRepo(repo.db)
+}
+
+func (repo *PgRepo) CommitTicketRepo() ICommitTicketRepo {
+	return NewCommitTicketRepo(repo.db)
+}
+
 func (repo *PgRepo) MemberRepo() IMemberRepo {
 	return NewMemberRepo(repo.db)
 }
 
+func (repo *PgRepo) OrgRepo() IOrgRepo {
+	return NewOrgRepo(repo.db)
+}
+
 func (repo *PgRepo) GroupRepo() rbacmodel.IGroupRepo {
 	return rbacmodel.NewGroupRepo(repo.db)
 }
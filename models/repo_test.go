@@ -61,4 +61,33 @@ func TestRepoTransaction(t *testing.T) {
 		_, err = pgRepo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetID(id))
 		require.True(t, errors.Is(err, sql.ErrNoRows))
 	})
+
+	t.Run("nested transaction rollback", func(t *testing.T) {
+		pgRepo := models.NewRepo(db)
+		var outerID, innerID uuid.UUID
+		err := pgRepo.Transaction(ctx, func(repo models.IRepo) error {
+			outerModel := &models.Repository{}
+			require.NoError(t, gofakeit.Struct(outerModel))
+			insertedModel, err := repo.RepositoryRepo().Insert(ctx, outerModel)
+			require.NoError(t, err)
+			outerID = insertedModel.ID
+
+			innerErr := repo.Transaction(ctx, func(repo models.IRepo) error {
+				innerModel := &models.Repository{}
+				require.NoError(t, gofakeit.Struct(innerModel))
+				insertedModel, err := repo.RepositoryRepo().Insert(ctx, innerModel)
+				require.NoError(t, err)
+				innerID = insertedModel.ID
+				return fmt.Errorf("rollback inner")
+			})
+			require.Error(t, innerErr)
+			return nil
+		})
+		require.NoError(t, err)
+
+		_, err = pgRepo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetID(outerID))
+		require.NoError(t, err)
+		_, err = pgRepo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetID(innerID))
+		require.True(t, errors.Is(err, sql.ErrNoRows))
+	})
 }
@@ -2,8 +2,14 @@ package models
 
 import (
 	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
 	"time"
 
+	"github.com/GitDataAI/jiaozifs/utils/hash"
 	"github.com/google/uuid"
 	"github.com/uptrace/bun"
 )
@@ -23,11 +29,247 @@ type Repository struct {
 	StorageAdapterParams *string `bun:"storage_adapter_params" json:"storage_adapter_params,omitempty"`
 
 	Description *string `bun:"description" json:"description,omitempty"`
+	// CommitMessageTemplate go template to render commit message, see versionmgr.CommitMessageVars for variables
+	CommitMessageTemplate *string `bun:"commit_message_template" json:"commit_message_template,omitempty"`
+	// HashType algorithm used to calculate hash of commits and objects, changed by versionmgr.HashMigrator
+	HashType hash.HashType `bun:"hash_type,notnull,default:0" json:"hash_type" fake:"skip"`
+
+	// WriteRateLimit max writes per minute, zero means unlimited
+	WriteRateLimit int `bun:"write_rate_limit,notnull,default:0" json:"write_rate_limit"`
+	// WriteBurst max writes allowed at once above WriteRateLimit
+	WriteBurst int `bun:"write_burst,notnull,default:0" json:"write_burst"`
+	// MaxConcurrentUploads max uploads in flight, zero means unlimited
+	MaxConcurrentUploads int `bun:"max_concurrent_uploads,notnull,default:0" json:"max_concurrent_uploads"`
+	// PrivatePrefixes paths hidden in public repository from users who are not owner or member
+	PrivatePrefixes []string `bun:"private_prefixes,type:jsonb" json:"private_prefixes"`
+	// MaskingRules columns of csv and jsonl files masked on download for users without unmask permission
+	MaskingRules []MaskingRule `bun:"masking_rules,type:jsonb" json:"masking_rules"`
+	// MergeRules reviewers and checklist required by merge requests targeting matched branches
+	MergeRules []MergeRule `bun:"merge_rules,type:jsonb" json:"merge_rules"`
+	// IssueLinks external issue trackers referenced by commit messages and merge requests
+	IssueLinks []IssueLink `bun:"issue_links,type:jsonb" json:"issue_links"`
+	// Hooks checks run before commits and merges to matched branches, operation is rejected if any of them fails
+	Hooks []Hook `bun:"hooks,type:jsonb" json:"hooks"`
+	// Archived repository is read only, commits, ref updates and wip changes are rejected until it is unarchived
+	Archived bool `bun:"archived,notnull,default:false" json:"archived"`
+	// StorageQuota max bytes of blobs of repository, zero means unlimited. writes going over it are rejected
+	StorageQuota int64 `bun:"storage_quota,notnull,default:0" json:"storage_quota"`
+	// QuotaWarning highest warning threshold in percent of StorageQuota the usage has crossed, zero if below all of them
+	QuotaWarning int `bun:"quota_warning,notnull,default:0" json:"quota_warning"`
 
 	CreatorID uuid.UUID `bun:"creator_id,type:uuid,notnull" json:"creator_id"`
+	// ForkedFromID repository this one was forked from, zero if not a fork. fork share storage namespace with upstream
+	ForkedFromID uuid.UUID `bun:"forked_from_id,type:uuid,nullzero" json:"forked_from_id"`
+	// Version increased by every update, given to UpdateByID to reject update if repository changed since it was read
+	Version int64 `bun:"version,notnull,default:0" json:"version"`
 
 	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
 	UpdatedAt time.Time `bun:"updated_at,type:timestamp,notnull" json:"updated_at"`
+	// DeletedAt time repository moved to trash, zero if not deleted. deleted repository is excluded from queries unless
+	// deleted one is asked, its name is kept until purged
+	DeletedAt time.Time `bun:"deleted_at,type:timestamp,soft_delete,nullzero" json:"deleted_at" fake:"skip"`
+}
+
+// CheckWritable return ErrRepositoryArchived if repository is archived
+func (repository *Repository) CheckWritable() error {
+	if repository.Archived {
+		return fmt.Errorf("repository %s %w", repository.Name, ErrRepositoryArchived)
+	}
+	return nil
+}
+
+// IsPrivatePath check whether objPath is one of private prefixes or under one of them
+func (repository *Repository) IsPrivatePath(objPath string) bool {
+	objPath = strings.Trim(path.Clean("/"+objPath), "/")
+	for _, prefix := range repository.PrivatePrefixes {
+		prefix = strings.Trim(prefix, "/")
+		if len(prefix) == 0 {
+			continue
+		}
+		if objPath == prefix || strings.HasPrefix(objPath, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// MergeRule review required by merge requests targeting branches matching Branch, Branch is a glob pattern of branch names
+type MergeRule struct {
+	Branch string `json:"branch"`
+	// DefaultReviewers users added as reviewers of new merge requests, all of them except author must approve before merge
+	DefaultReviewers []uuid.UUID `json:"default_reviewers"`
+	// Checklist items must be checked before merge
+	Checklist []string `json:"checklist"`
+	// RequiredChecks names of attestations must be success on head of source branch before merge
+	RequiredChecks []string `json:"required_checks"`
+}
+
+func (rule MergeRule) Match(branch string) bool {
+	if rule.Branch == branch {
+		return true
+	}
+	matched, _ := path.Match(rule.Branch, branch)
+	return matched
+}
+
+// MergeRulesOf return rules matching branch
+func (repository *Repository) MergeRulesOf(branch string) []MergeRule {
+	var rules []MergeRule
+	for _, rule := range repository.MergeRules {
+		if rule.Match(branch) {
+			rules = append(rules, rule)
+		}
+	}
+	return rules
+}
+
+// HookEvent operation checked by hook
+type HookEvent string
+
+const (
+	// PreCommitHook check commits to branch, including amended commits and commits of path operations
+	PreCommitHook HookEvent = "pre-commit"
+	// PreMergeHook check merges into branch, including fast-forward
+	PreMergeHook HookEvent = "pre-merge"
+)
+
+func (event HookEvent) Valid() bool {
+	return event == PreCommitHook || event == PreMergeHook
+}
+
+// HookType check made by hook
+type HookType string
+
+const (
+	// DenyPathsHook reject operation changing paths matching Paths
+	DenyPathsHook HookType = "deny_paths"
+	// RequireMetadataHook reject operation whose commit miss any of MetadataKeys, head commit of source branch is
+	// checked on merge
+	RequireMetadataHook HookType = "require_metadata"
+)
+
+func (hookType HookType) Valid() bool {
+	return hookType == DenyPathsHook || hookType == RequireMetadataHook
+}
+
+// Hook check run synchronously before commits and merges to branches matching Branch, Branch is a glob pattern of
+// branch names and empty one match all branches
+type Hook struct {
+	Name   string      `json:"name"`
+	Events []HookEvent `json:"events"`
+	Branch string      `json:"branch"`
+	Type   HookType    `json:"type"`
+	// Paths glob patterns or directories of paths denied by DenyPathsHook
+	Paths []string `json:"paths"`
+	// MetadataKeys keys of commit metadata required by RequireMetadataHook
+	MetadataKeys []string `json:"metadata_keys"`
+	// Message reason of rejection, a default one is given if empty
+	Message string `json:"message"`
+}
+
+func (hook Hook) Match(event HookEvent, branch string) bool {
+	if !slices.Contains(hook.Events, event) {
+		return false
+	}
+	if len(hook.Branch) == 0 || hook.Branch == branch {
+		return true
+	}
+	matched, _ := path.Match(hook.Branch, branch)
+	return matched
+}
+
+// DenyPath check whether objPath is matched by Paths of hook
+func (hook Hook) DenyPath(objPath string) bool {
+	return slices.ContainsFunc(hook.Paths, func(pattern string) bool {
+		return MaskingRule{Path: pattern}.Match(objPath)
+	})
+}
+
+// HooksOf return hooks checking event on branch
+func (repository *Repository) HooksOf(event HookEvent, branch string) []Hook {
+	var hooks []Hook
+	for _, hook := range repository.Hooks {
+		if hook.Match(event, branch) {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks
+}
+
+// IssueLink link references of external issues to their tracker, a reference is Prefix followed by number of issue,
+// such as DATA-42 for prefix DATA- or #42 for prefix #
+type IssueLink struct {
+	Prefix string `json:"prefix"`
+	// URLTemplate url of issue, {id} is replaced by number of issue
+	URLTemplate string `json:"url_template"`
+}
+
+// IssueRef reference of an external issue
+type IssueRef struct {
+	// ID reference with prefix, eg. DATA-42
+	ID  string `json:"id"`
+	URL string `json:"url"`
+}
+
+// IssueRefs return distinct issue references in texts in order of appearance
+func (repository *Repository) IssueRefs(texts ...string) []IssueRef {
+	var refs []IssueRef
+	for _, link := range repository.IssueLinks {
+		if len(link.Prefix) == 0 {
+			continue
+		}
+		pattern := regexp.MustCompile(`(?:^|\W)` + regexp.QuoteMeta(link.Prefix) + `(\d+)\b`)
+		for _, text := range texts {
+			for _, match := range pattern.FindAllStringSubmatch(text, -1) {
+				id := link.Prefix + match[1]
+				if slices.ContainsFunc(refs, func(ref IssueRef) bool { return ref.ID == id }) {
+					continue
+				}
+				refs = append(refs, IssueRef{
+					ID:  id,
+					URL: strings.ReplaceAll(link.URLTemplate, "{id}", match[1]),
+				})
+			}
+		}
+	}
+	return refs
+}
+
+// MaskingRule mask columns of files matching Path, Path is a glob pattern or a directory files under it are matched
+type MaskingRule struct {
+	Path    string   `json:"path"`
+	Columns []string `json:"columns"`
+	// Method how values are masked, redact or hash
+	Method string `json:"method"`
+}
+
+func (rule MaskingRule) Match(objPath string) bool {
+	objPath = strings.Trim(path.Clean("/"+objPath), "/")
+	pattern := strings.Trim(rule.Path, "/")
+	if len(pattern) == 0 {
+		return false
+	}
+	if matched, _ := path.Match(pattern, objPath); matched {
+		return true
+	}
+	return strings.HasPrefix(objPath, pattern+"/")
+}
+
+// MaskedColumns return method of each column masked in objPath, columns of later rules take precedence
+func (repository *Repository) MaskedColumns(objPath string) map[string]string {
+	var columns map[string]string
+	for _, rule := range repository.MaskingRules {
+		if !rule.Match(objPath) {
+			continue
+		}
+		if columns == nil {
+			columns = make(map[string]string)
+		}
+		for _, column := range rule.Columns {
+			columns[column] = rule.Method
+		}
+	}
+	return columns
 }
 
 type GetRepoParams struct {
@@ -35,6 +277,7 @@ type GetRepoParams struct {
 	creatorID uuid.UUID
 	ownerID   uuid.UUID
 	name      *string
+	deleted   bool
 }
 
 func NewGetRepoParams() *GetRepoParams {
@@ -61,6 +304,12 @@ func (gup *GetRepoParams) SetName(name string) *GetRepoParams {
 	return gup
 }
 
+// SetDeleted find repository in trash instead
+func (gup *GetRepoParams) SetDeleted() *GetRepoParams {
+	gup.deleted = true
+	return gup
+}
+
 type ListRepoParams struct {
 	id        uuid.UUID
 	creatorID uuid.UUID
@@ -68,9 +317,16 @@ type ListRepoParams struct {
 	name      *string
 	nameMatch MatchMode
 	visible   *bool
+	// forkedFromID list forks of this repository
+	forkedFromID uuid.UUID
+	// orgMemberID list repositories owned by organizations this user belong to
+	orgMemberID uuid.UUID
 
-	after  *time.Time
-	amount int
+	sort      ListSort
+	after     *time.Time
+	afterName *string
+	afterID   uuid.UUID
+	amount    int
 }
 
 func NewListRepoParams() *ListRepoParams {
@@ -102,11 +358,53 @@ func (lrp *ListRepoParams) SetVisible(visible bool) *ListRepoParams {
 	return lrp
 }
 
+func (lrp *ListRepoParams) SetForkedFromID(forkedFromID uuid.UUID) *ListRepoParams {
+	lrp.forkedFromID = forkedFromID
+	return lrp
+}
+
+func (lrp *ListRepoParams) SetOrgMemberID(userID uuid.UUID) *ListRepoParams {
+	lrp.orgMemberID = userID
+	return lrp
+}
+
+// SetSort order repositories by field, default is updated_at from new to old
+func (lrp *ListRepoParams) SetSort(field SortField, direction SortDirection) *ListRepoParams {
+	lrp.sort = ListSort{Field: field, Direction: direction}
+	return lrp
+}
+
+// SetAfter list repositories after this time, used when sorted by time
 func (lrp *ListRepoParams) SetAfter(after time.Time) *ListRepoParams {
 	lrp.after = &after
 	return lrp
 }
 
+// SetAfterName list repositories after this name, used when sorted by name
+func (lrp *ListRepoParams) SetAfterName(name string) *ListRepoParams {
+	lrp.afterName = &name
+	return lrp
+}
+
+// SetAfterID id of the last repository in previous page, repositories with the same sort value are ordered by id
+func (lrp *ListRepoParams) SetAfterID(id uuid.UUID) *ListRepoParams {
+	lrp.afterID = id
+	return lrp
+}
+
+func (lrp *ListRepoParams) cursor() interface{} {
+	if lrp.sort.Field == SortByName {
+		if lrp.afterName != nil {
+			return *lrp.afterName
+		}
+		return nil
+	}
+	if lrp.after != nil {
+		return *lrp.after
+	}
+	return nil
+}
+
 func (lrp *ListRepoParams) SetAmount(amount int) *ListRepoParams {
 	lrp.amount = amount
 	return lrp
@@ -138,10 +436,23 @@ func (drp *DeleteRepoParams) SetName(name string) *DeleteRepoParams {
 }
 
 type UpdateRepoParams struct {
-	id          uuid.UUID
-	description *string
-	visible     *bool
-	head        *string
+	id                    uuid.UUID
+	description           *string
+	visible               *bool
+	head                  *string
+	commitMessageTemplate *string
+	hashType              *hash.HashType
+	writeRateLimit        *int
+	writeBurst            *int
+	maxConcurrentUploads  *int
+	storageQuota          *int64
+	privatePrefixes       *[]string
+	maskingRules          *[]MaskingRule
+	mergeRules            *[]MergeRule
+	issueLinks            *[]IssueLink
+	hooks                 *[]Hook
+	archived              *bool
+	version               *int64
 }
 
 func NewUpdateRepoParams(id uuid.UUID) *UpdateRepoParams {
@@ -165,13 +476,103 @@ func (up *UpdateRepoParams) SetVisible(visible bool) *UpdateRepoParams {
 	return up
 }
 
+func (up *UpdateRepoParams) SetArchived(archived bool) *UpdateRepoParams {
+	up.archived = &archived
+	return up
+}
+
+func (up *UpdateRepoParams) SetCommitMessageTemplate(tmpl string) *UpdateRepoParams {
+	up.commitMessageTemplate = &tmpl
+	return up
+}
+
+func (up *UpdateRepoParams) SetHashType(hashType hash.HashType) *UpdateRepoParams {
+	up.hashType = &hashType
+	return up
+}
+
+func (up *UpdateRepoParams) SetWriteRateLimit(writesPerMinute int) *UpdateRepoParams {
+	up.writeRateLimit = &writesPerMinute
+	return up
+}
+
+func (up *UpdateRepoParams) SetWriteBurst(burst int) *UpdateRepoParams {
+	up.writeBurst = &burst
+	return up
+}
+
+func (up *UpdateRepoParams) SetPrivatePrefixes(prefixes []string) *UpdateRepoParams {
+	up.privatePrefixes = &prefixes
+	return up
+}
+
+func (up *UpdateRepoParams) SetMaskingRules(rules []MaskingRule) *UpdateRepoParams {
+	up.maskingRules = &rules
+	return up
+}
+
+func (up *UpdateRepoParams) SetMergeRules(rules []MergeRule) *UpdateRepoParams {
+	up.mergeRules = &rules
+	return up
+}
+
+func (up *UpdateRepoParams) SetIssueLinks(links []IssueLink) *UpdateRepoParams {
+	up.issueLinks = &links
+	return up
+}
+
+func (up *UpdateRepoParams) SetHooks(hooks []Hook) *UpdateRepoParams {
+	up.hooks = &hooks
+	return up
+}
+
+func (up *UpdateRepoParams) SetMaxConcurrentUploads(maxUploads int) *UpdateRepoParams {
+	up.maxConcurrentUploads = &maxUploads
+	return up
+}
+
+func (up *UpdateRepoParams) SetStorageQuota(quota int64) *UpdateRepoParams {
+	up.storageQuota = &quota
+	return up
+}
+
+// SetVersion only update repository at version, see Repository.Version
+func (up *UpdateRepoParams) SetVersion(version int64) *UpdateRepoParams {
+	up.version = &version
+	return up
+}
+
 type IRepositoryRepo interface {
 	Insert(ctx context.Context, repo *Repository) (*Repository, error)
 	Get(ctx context.Context, params *GetRepoParams) (*Repository, error)
 
 	List(ctx context.Context, params *ListRepoParams) ([]*Repository, bool, error)
+	// Count number of repositories matching params, pagination of params is ignored
+	Count(ctx context.Context, params *ListRepoParams) (int, error)
+	// ListDeleted list repositories in trash, from the latest deleted to the oldest
+	ListDeleted(ctx context.Context, params *ListRepoParams) ([]*Repository, bool, error)
+	// Delete move repositories to trash, data of repository is kept
 	Delete(ctx context.Context, params *DeleteRepoParams) (int64, error)
+	// Restore take repository back from trash
+	Restore(ctx context.Context, id uuid.UUID) (int64, error)
+	// Purge delete repositories permanently, both in trash or not
+	Purge(ctx context.Context, params *DeleteRepoParams) (int64, error)
+	// UpdateByID update repository, return ErrStaleObject if version is set and repository not at the version any more
 	UpdateByID(ctx context.Context, updateModel *UpdateRepoParams) error
+	// UpdateQuotaWarning set warning threshold crossed by usage, it is derived from usage so version is kept
+	UpdateQuotaWarning(ctx context.Context, id uuid.UUID, percent int) error
+	// Rename change name of repository, old name is kept as alias
+	Rename(ctx context.Context, id uuid.UUID, name string) error
+	// GetAlias find repository that used to be named name
+	GetAlias(ctx context.Context, ownerID uuid.UUID, name string) (*RepositoryAlias, error)
+	// ListSharingStorage list other repositories using the same storage namespace as repository, repositories in trash
+	// are included as they still own their storage
+	ListSharingStorage(ctx context.Context, repository *Repository) ([]*Repository, error)
+
+	// GetStats storage usage of repository, stats is calculated if not exist yet
+	GetStats(ctx context.Context, repositoryID uuid.UUID) (*RepositoryStats, error)
+	// RecalculateStats calculate storage usage of repository from commits and objects
+	RecalculateStats(ctx context.Context, repositoryID uuid.UUID) (*RepositoryStats, error)
 }
 
 var _ IRepositoryRepo = (*RepositoryRepo)(nil)
@@ -187,7 +588,16 @@ func NewRepositoryRepo(db bun.IDB) IRepositoryRepo {
 func (r *RepositoryRepo) Insert(ctx context.Context, repo *Repository) (*Repository, error) {
 	_, err := r.db.NewInsert().Model(repo).Exec(ctx)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
+	}
+	_, err = r.db.NewInsert().Model(&RepositoryStats{RepositoryID: repo.ID, UpdatedAt: time.Now()}).Exec(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	// old name of other repository is taken back
+	_, err = r.db.NewDelete().Model((*RepositoryAlias)(nil)).Where("owner_id = ?", repo.OwnerID).Where("name = ?", repo.Name).Exec(ctx)
+	if err != nil {
+		return nil, toModelError(err)
 	}
 	return repo, nil
 }
@@ -212,16 +622,50 @@ func (r *RepositoryRepo) Get(ctx context.Context, params *GetRepoParams) (*Repos
 		query = query.Where("name = ?", *params.name)
 	}
 
+	if params.deleted {
+		query = query.WhereDeleted()
+	}
+
 	err := query.Limit(1).Scan(ctx)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
 	}
 	return repo, nil
 }
 
 func (r *RepositoryRepo) List(ctx context.Context, params *ListRepoParams) ([]*Repository, bool, error) {
 	repos := []*Repository{}
-	query := r.db.NewSelect().Model(&repos)
+	defaultSort := ListSort{Field: SortByUpdatedAt, Direction: SortDesc}
+	cursor, err := params.sort.exactCursor(ctx, r.listQuery(&[]*Repository{}, params), "", defaultSort, params.cursor(), params.afterID)
+	if err != nil {
+		return nil, false, toModelError(err)
+	}
+	query := params.sort.apply(r.listQuery(&repos, params), "", defaultSort, cursor, params.afterID)
+
+	err = query.Limit(params.amount).Scan(ctx)
+	return repos, len(repos) == params.amount, toModelError(err)
+}
+
+func (r *RepositoryRepo) Count(ctx context.Context, params *ListRepoParams) (int, error) {
+	var repos []*Repository
+	return r.listQuery(&repos, params).Count(ctx)
+}
+
+func (r *RepositoryRepo) ListDeleted(ctx context.Context, params *ListRepoParams) ([]*Repository, bool, error) {
+	repos := []*Repository{}
+	query := r.listQuery(&repos, params).WhereDeleted()
+
+	query = query.Order("deleted_at DESC")
+	if params.after != nil {
+		query = query.Where("deleted_at < ?", *params.after)
+	}
+
+	err := query.Limit(params.amount).Scan(ctx)
+	return repos, params.amount > 0 && len(repos) == params.amount, toModelError(err)
+}
+
+func (r *RepositoryRepo) listQuery(repos *[]*Repository, params *ListRepoParams) *bun.SelectQuery {
+	query := r.db.NewSelect().Model(repos)
 
 	if uuid.Nil != params.creatorID {
 		query = query.Where("creator_id = ?", params.creatorID)
@@ -235,29 +679,29 @@ func (r *RepositoryRepo) List(ctx context.Context, params *ListRepoParams) ([]*R
 		query = query.Where("visible = ?", *params.visible)
 	}
 
-	if params.name != nil {
-		switch params.nameMatch {
-		case ExactMatch:
-			query = query.Where("name = ?", *params.name)
-		case PrefixMatch:
-			query = query.Where("name LIKE ?", *params.name+"%")
-		case SuffixMatch:
-			query = query.Where("name LIKE ?", "%"+*params.name)
-		case LikeMatch:
-			query = query.Where("name LIKE ?", "%"+*params.name+"%")
-		}
+	if uuid.Nil != params.forkedFromID {
+		query = query.Where("forked_from_id = ?", params.forkedFromID)
 	}
 
-	query = query.Order("updated_at DESC") // from new to old
-	if params.after != nil {
-		query = query.Where("updated_at < ?", *params.after)
+	if uuid.Nil != params.orgMemberID {
+		query = query.Where("owner_id IN (SELECT org_id FROM memberships WHERE user_id = ?)", params.orgMemberID)
 	}
 
-	err := query.Limit(params.amount).Scan(ctx)
-	return repos, len(repos) == params.amount, err
+	if params.name != nil {
+		query = params.nameMatch.apply(query, "name", *params.name)
+	}
+	return query
 }
 
 func (r *RepositoryRepo) Delete(ctx context.Context, params *DeleteRepoParams) (int64, error) {
+	return r.delete(ctx, params, false)
+}
+
+func (r *RepositoryRepo) Purge(ctx context.Context, params *DeleteRepoParams) (int64, error) {
+	return r.delete(ctx, params, true)
+}
+
+func (r *RepositoryRepo) delete(ctx context.Context, params *DeleteRepoParams, force bool) (int64, error) {
 	query := r.db.NewDelete().Model((*Repository)(nil))
 	if uuid.Nil != params.id {
 		query = query.Where("id = ?", params.id)
@@ -271,15 +715,66 @@ func (r *RepositoryRepo) Delete(ctx context.Context, params *DeleteRepoParams) (
 		query = query.Where("owner_id = ?", params.ownerID)
 	}
 
-	sqlResult, err := query.Exec(ctx)
+	if !force {
+		sqlResult, err := query.Exec(ctx)
+		if err != nil {
+			return 0, toModelError(err)
+		}
+		return sqlResult.RowsAffected()
+	}
+
+	var ids []uuid.UUID
+	_, err := query.ForceDelete().Returning("id").Exec(ctx, &ids)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+	if len(ids) > 0 {
+		_, err = r.db.NewDelete().Model((*RepositoryStats)(nil)).Where("repository_id IN (?)", bun.In(ids)).Exec(ctx)
+		if err != nil {
+			return 0, toModelError(err)
+		}
+		_, err = r.db.NewDelete().Model((*RepositoryAlias)(nil)).Where("repository_id IN (?)", bun.In(ids)).Exec(ctx)
+		if err != nil {
+			return 0, toModelError(err)
+		}
+	}
+	return int64(len(ids)), nil
+}
+
+func (r *RepositoryRepo) ListSharingStorage(ctx context.Context, repository *Repository) ([]*Repository, error) {
+	repos := []*Repository{}
+	if repository.StorageNamespace == nil {
+		return repos, nil
+	}
+	err := r.db.NewSelect().Model(&repos).
+		WhereAllWithDeleted().
+		Where("storage_namespace = ?", *repository.StorageNamespace).
+		Where("id <> ?", repository.ID).
+		Scan(ctx)
 	if err != nil {
-		return 0, err
+		return nil, toModelError(err)
 	}
-	affectedRows, err := sqlResult.RowsAffected()
+	return repos, nil
+}
+
+func (r *RepositoryRepo) Restore(ctx context.Context, id uuid.UUID) (int64, error) {
+	sqlResult, err := r.db.NewUpdate().Model((*Repository)(nil)).
+		WhereDeleted().
+		Where("id = ?", id).
+		Set("deleted_at = NULL").
+		Exec(ctx)
 	if err != nil {
-		return 0, err
+		return 0, toModelError(err)
 	}
-	return affectedRows, err
+	return sqlResult.RowsAffected()
+}
+
+func (r *RepositoryRepo) UpdateQuotaWarning(ctx context.Context, id uuid.UUID, percent int) error {
+	_, err := r.db.NewUpdate().Model((*Repository)(nil)).
+		Set("quota_warning = ?", percent).
+		Where("id = ?", id).
+		Exec(ctx)
+	return toModelError(err)
 }
 
 func (r *RepositoryRepo) UpdateByID(ctx context.Context, updateModel *UpdateRepoParams) error {
@@ -297,6 +792,53 @@ func (r *RepositoryRepo) UpdateByID(ctx context.Context, updateModel *UpdateRepo
 		updateQuery.Set("visible = ?", *updateModel.visible)
 	}
 
-	_, err := updateQuery.Exec(ctx)
-	return err
+	if updateModel.commitMessageTemplate != nil {
+		updateQuery.Set("commit_message_template = ?", *updateModel.commitMessageTemplate)
+	}
+
+	if updateModel.hashType != nil {
+		updateQuery.Set("hash_type = ?", *updateModel.hashType)
+	}
+
+	if updateModel.writeRateLimit != nil {
+		updateQuery.Set("write_rate_limit = ?", *updateModel.writeRateLimit)
+	}
+
+	if updateModel.writeBurst != nil {
+		updateQuery.Set("write_burst = ?", *updateModel.writeBurst)
+	}
+
+	if updateModel.maxConcurrentUploads != nil {
+		updateQuery.Set("max_concurrent_uploads = ?", *updateModel.maxConcurrentUploads)
+	}
+
+	if updateModel.storageQuota != nil {
+		updateQuery.Set("storage_quota = ?", *updateModel.storageQuota)
+	}
+
+	if updateModel.privatePrefixes != nil {
+		updateQuery.Set("private_prefixes = ?", *updateModel.privatePrefixes)
+	}
+
+	if updateModel.maskingRules != nil {
+		updateQuery.Set("masking_rules = ?", *updateModel.maskingRules)
+	}
+
+	if updateModel.mergeRules != nil {
+		updateQuery.Set("merge_rules = ?", *updateModel.mergeRules)
+	}
+
+	if updateModel.issueLinks != nil {
+		updateQuery.Set("issue_links = ?", *updateModel.issueLinks)
+	}
+
+	if updateModel.hooks != nil {
+		updateQuery.Set("hooks = ?", *updateModel.hooks)
+	}
+
+	if updateModel.archived != nil {
+		updateQuery.Set("archived = ?", *updateModel.archived)
+	}
+
+	return execVersionedUpdate(ctx, updateQuery, updateModel.version)
 }
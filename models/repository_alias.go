@@ -0,0 +1,76 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// RepositoryAlias old name of a renamed repository, requests using it are redirected to the current name. an alias is
+// dropped once a repository of the owner takes the name again
+type RepositoryAlias struct {
+	bun.BaseModel `bun:"table:repository_aliases"`
+	OwnerID       uuid.UUID `bun:"owner_id,pk,type:uuid" json:"owner_id"`
+	Name          string    `bun:"name,pk" json:"name"`
+	RepositoryID  uuid.UUID `bun:"repository_id,type:uuid,notnull" json:"repository_id"`
+	CreatedAt     time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+}
+
+// Rename change name of repository, the old name is kept as alias of repository. new name is checked by name_owner_unique
+// constraint, so it can't be taken by repository in trash either
+func (r *RepositoryRepo) Rename(ctx context.Context, id uuid.UUID, name string) error {
+	return r.db.RunInTx(ctx, nil, func(ctx context.Context, tx bun.Tx) error {
+		repository := &Repository{}
+		err := forUpdate(tx, tx.NewSelect().Model(repository).Where("id = ?", id)).Scan(ctx)
+		if err != nil {
+			return toModelError(err)
+		}
+		if repository.Name == name {
+			return nil
+		}
+
+		_, err = tx.NewUpdate().Model((*Repository)(nil)).
+			Set("name = ?", name).
+			Set("updated_at = ?", time.Now()).
+			Set("version = version + 1").
+			Where("id = ?", id).
+			Exec(ctx)
+		if err != nil {
+			return toModelError(err)
+		}
+
+		_, err = tx.NewDelete().Model((*RepositoryAlias)(nil)).
+			Where("owner_id = ?", repository.OwnerID).
+			Where("name = ?", name).
+			Exec(ctx)
+		if err != nil {
+			return toModelError(err)
+		}
+
+		_, err = tx.NewInsert().Model(&RepositoryAlias{
+			OwnerID:      repository.OwnerID,
+			Name:         repository.Name,
+			RepositoryID: id,
+			CreatedAt:    time.Now(),
+		}).
+			On("CONFLICT (owner_id, name) DO UPDATE").
+			Set("repository_id = EXCLUDED.repository_id").
+			Set("created_at = EXCLUDED.created_at").
+			Exec(ctx)
+		return toModelError(err)
+	})
+}
+
+func (r *RepositoryRepo) GetAlias(ctx context.Context, ownerID uuid.UUID, name string) (*RepositoryAlias, error) {
+	alias := &RepositoryAlias{}
+	err := r.db.NewSelect().Model(alias).
+		Where("owner_id = ?", ownerID).
+		Where("name = ?", name).
+		Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return alias, nil
+}
@@ -0,0 +1,229 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// DeletionJobState progress of a repository deletion job
+type DeletionJobState string
+
+const (
+	DeletionJobPending DeletionJobState = "pending"
+	DeletionJobRunning DeletionJobState = "running"
+	DeletionJobDone    DeletionJobState = "done"
+)
+
+// RepositoryDeletionJob cleanup of a purged repository. repository row is removed when job is created, its refs,
+// commits, trees, wips and storage data are removed by background worker in batches
+type RepositoryDeletionJob struct {
+	bun.BaseModel  `bun:"table:repository_deletion_jobs"`
+	ID             uuid.UUID `bun:"id,pk,type:uuid,default:uuid_generate_v4()" json:"id"`
+	RepositoryID   uuid.UUID `bun:"repository_id,type:uuid,notnull" json:"repository_id"`
+	RepositoryName string    `bun:"repository_name,notnull" json:"repository_name"`
+	OwnerID        uuid.UUID `bun:"owner_id,type:uuid,notnull" json:"owner_id"`
+	OperatorID     uuid.UUID `bun:"operator_id,type:uuid,notnull" json:"operator_id"`
+	// storage of repository, kept on job as repository row is gone
+	StorageNamespace     *string `bun:"storage_namespace" json:"storage_namespace"`
+	UsePublicStorage     bool    `bun:"use_public_storage,notnull" json:"use_public_storage"`
+	StorageAdapterParams *string `bun:"storage_adapter_params" json:"-"`
+	// CleanData remove data of custom storage, data of public storage is always removed
+	CleanData bool `bun:"clean_data,notnull" json:"clean_data"`
+
+	State DeletionJobState `bun:"state,notnull" json:"state"`
+	// Step table or storage being cleaned
+	Step string `bun:"step" json:"step"`
+	// Deleted rows removed so far
+	Deleted int64 `bun:"deleted,notnull,default:0" json:"deleted"`
+	// Error of last failed attempt, job is retried by next round
+	Error string `bun:"error" json:"error"`
+
+	CreatedAt  time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+	UpdatedAt  time.Time `bun:"updated_at,type:timestamp,notnull" json:"updated_at"`
+	FinishedAt time.Time `bun:"finished_at,type:timestamp,nullzero" json:"finished_at"`
+}
+
+// Repository rebuild repository whose data is being removed, only fields needed to reach its storage are set
+func (job *RepositoryDeletionJob) Repository() *Repository {
+	return &Repository{
+		ID:                   job.RepositoryID,
+		Name:                 job.RepositoryName,
+		OwnerID:              job.OwnerID,
+		StorageNamespace:     job.StorageNamespace,
+		UsePublicStorage:     job.UsePublicStorage,
+		StorageAdapterParams: job.StorageAdapterParams,
+	}
+}
+
+// NewRepositoryDeletionJob job removing data of repository
+func NewRepositoryDeletionJob(repository *Repository, operatorID uuid.UUID, cleanData bool) *RepositoryDeletionJob {
+	now := time.Now()
+	return &RepositoryDeletionJob{
+		RepositoryID:         repository.ID,
+		RepositoryName:       repository.Name,
+		OwnerID:              repository.OwnerID,
+		OperatorID:           operatorID,
+		StorageNamespace:     repository.StorageNamespace,
+		UsePublicStorage:     repository.UsePublicStorage,
+		StorageAdapterParams: repository.StorageAdapterParams,
+		CleanData:            cleanData,
+		State:                DeletionJobPending,
+		CreatedAt:            now,
+		UpdatedAt:            now,
+	}
+}
+
+// RepositoryDataTable table holding rows of repositories, Column refer to id of repository
+type RepositoryDataTable struct {
+	Name   string
+	Model  interface{}
+	Column string
+}
+
+// RepositoryDataTables tables cleaned by deletion jobs in order, refs go first so repository is unreachable before its
+// commits and trees are removed
+var RepositoryDataTables = []RepositoryDataTable{
+	{Name: "branches", Model: (*Branch)(nil), Column: "repository_id"},
+	{Name: "tags", Model: (*Tag)(nil), Column: "repository_id"},
+	{Name: "wips", Model: (*WorkingInProcess)(nil), Column: "repository_id"},
+	{Name: "members", Model: (*Member)(nil), Column: "repo_id"},
+	{Name: "access_tokens", Model: (*AccessToken)(nil), Column: "repository_id"},
+	{Name: "commits", Model: (*Commit)(nil), Column: "repository_id"},
+	{Name: "commit_stats", Model: (*CommitStats)(nil), Column: "repository_id"},
+	{Name: "trees", Model: (*TreeNode)(nil), Column: "repository_id"},
+	{Name: "blob_refs", Model: (*BlobRef)(nil), Column: "repository_id"},
+	{Name: "packed_objects", Model: (*PackedObject)(nil), Column: "repository_id"},
+	{Name: "packs", Model: (*Pack)(nil), Column: "repository_id"},
+	{Name: "hash_mappings", Model: (*HashMapping)(nil), Column: "repository_id"},
+	{Name: "file_schemas", Model: (*FileSchema)(nil), Column: "repository_id"},
+	{Name: "attestations", Model: (*Attestation)(nil), Column: "repository_id"},
+	{Name: "usage_reports", Model: (*UsageReport)(nil), Column: "repository_id"},
+	{Name: "review_comments", Model: (*ReviewComment)(nil), Column: "repository_id"},
+	{Name: "import_checkpoints", Model: (*ImportCheckpoint)(nil), Column: "repository_id"},
+	{Name: "commit_tickets", Model: (*CommitTicket)(nil), Column: "repository_id"},
+}
+
+type UpdateDeletionJobParams struct {
+	id       uuid.UUID
+	state    *DeletionJobState
+	step     *string
+	deleted  int64
+	errorMsg *string
+}
+
+func NewUpdateDeletionJobParams(id uuid.UUID) *UpdateDeletionJobParams {
+	return &UpdateDeletionJobParams{id: id}
+}
+
+func (up *UpdateDeletionJobParams) SetState(state DeletionJobState) *UpdateDeletionJobParams {
+	up.state = &state
+	return up
+}
+
+func (up *UpdateDeletionJobParams) SetStep(step string) *UpdateDeletionJobParams {
+	up.step = &step
+	return up
+}
+
+// AddDeleted add rows removed by a batch to progress of job
+func (up *UpdateDeletionJobParams) AddDeleted(deleted int64) *UpdateDeletionJobParams {
+	up.deleted += deleted
+	return up
+}
+
+func (up *UpdateDeletionJobParams) SetError(errorMsg string) *UpdateDeletionJobParams {
+	up.errorMsg = &errorMsg
+	return up
+}
+
+type IRepositoryDeletionRepo interface {
+	Insert(ctx context.Context, job *RepositoryDeletionJob) (*RepositoryDeletionJob, error)
+	Get(ctx context.Context, id uuid.UUID) (*RepositoryDeletionJob, error)
+	// ListUnfinished jobs not done yet from the oldest
+	ListUnfinished(ctx context.Context) ([]*RepositoryDeletionJob, error)
+	Update(ctx context.Context, params *UpdateDeletionJobParams) error
+	// DeleteBatch remove at most limit rows of repository from table, return number of rows removed
+	DeleteBatch(ctx context.Context, table RepositoryDataTable, repositoryID uuid.UUID, limit int) (int64, error)
+}
+
+var _ IRepositoryDeletionRepo = (*RepositoryDeletionRepo)(nil)
+
+type RepositoryDeletionRepo struct {
+	db bun.IDB
+}
+
+func NewRepositoryDeletionRepo(db bun.IDB) IRepositoryDeletionRepo {
+	return &RepositoryDeletionRepo{db: db}
+}
+
+func (r *RepositoryDeletionRepo) Insert(ctx context.Context, job *RepositoryDeletionJob) (*RepositoryDeletionJob, error) {
+	_, err := r.db.NewInsert().Model(job).Exec(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return job, nil
+}
+
+func (r *RepositoryDeletionRepo) Get(ctx context.Context, id uuid.UUID) (*RepositoryDeletionJob, error) {
+	job := &RepositoryDeletionJob{}
+	err := r.db.NewSelect().Model(job).Where("id = ?", id).Limit(1).Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return job, nil
+}
+
+func (r *RepositoryDeletionRepo) ListUnfinished(ctx context.Context) ([]*RepositoryDeletionJob, error) {
+	var jobs []*RepositoryDeletionJob
+	err := r.db.NewSelect().Model(&jobs).
+		Where("state <> ?", DeletionJobDone).
+		Order("created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return jobs, nil
+}
+
+func (r *RepositoryDeletionRepo) Update(ctx context.Context, params *UpdateDeletionJobParams) error {
+	query := r.db.NewUpdate().Model((*RepositoryDeletionJob)(nil)).Where("id = ?", params.id)
+	if params.state != nil {
+		query = query.Set("state = ?", *params.state)
+		if *params.state == DeletionJobDone {
+			query = query.Set("finished_at = ?", time.Now())
+		}
+	}
+	if params.step != nil {
+		query = query.Set("step = ?", *params.step)
+	}
+	if params.deleted != 0 {
+		query = query.Set("deleted = deleted + ?", params.deleted)
+	}
+	if params.errorMsg != nil {
+		query = query.Set("error = ?", *params.errorMsg)
+	}
+	_, err := query.Set("updated_at = ?", time.Now()).Exec(ctx)
+	return toModelError(err)
+}
+
+func (r *RepositoryDeletionRepo) DeleteBatch(ctx context.Context, table RepositoryDataTable, repositoryID uuid.UUID, limit int) (int64, error) {
+	// tables are keyed differently, rows are picked by physical row id instead
+	rowID := "rowid"
+	if isPostgres(r.db) {
+		rowID = "ctid"
+	}
+	batch := r.db.NewSelect().Model(table.Model).
+		ColumnExpr(rowID).
+		Where("? = ?", bun.Ident(table.Column), repositoryID).
+		Limit(limit)
+	sqlResult, err := r.db.NewDelete().Model(table.Model).
+		Where("? IN (?)", bun.Safe(rowID), batch).
+		Exec(ctx)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+	return sqlResult.RowsAffected()
+}
@@ -0,0 +1,78 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryDeletionRepo(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepositoryDeletionRepo(db)
+	repository := &models.Repository{
+		ID:               uuid.New(),
+		Name:             "purged",
+		OwnerID:          uuid.New(),
+		StorageNamespace: utils.String("mem://data"),
+	}
+	job, err := repo.Insert(ctx, models.NewRepositoryDeletionJob(repository, uuid.New(), true))
+	require.NoError(t, err)
+
+	t.Run("progress", func(t *testing.T) {
+		require.NoError(t, repo.Update(ctx, models.NewUpdateDeletionJobParams(job.ID).SetState(models.DeletionJobRunning).SetStep("commits").AddDeleted(3)))
+		require.NoError(t, repo.Update(ctx, models.NewUpdateDeletionJobParams(job.ID).AddDeleted(2)))
+
+		got, err := repo.Get(ctx, job.ID)
+		require.NoError(t, err)
+		require.Equal(t, models.DeletionJobRunning, got.State)
+		require.Equal(t, "commits", got.Step)
+		require.Equal(t, int64(5), got.Deleted)
+		require.Equal(t, "mem://data", utils.StringValue(got.Repository().StorageNamespace))
+	})
+
+	t.Run("unfinished", func(t *testing.T) {
+		jobs, err := repo.ListUnfinished(ctx)
+		require.NoError(t, err)
+		require.Len(t, jobs, 1)
+
+		require.NoError(t, repo.Update(ctx, models.NewUpdateDeletionJobParams(job.ID).SetState(models.DeletionJobDone)))
+		jobs, err = repo.ListUnfinished(ctx)
+		require.NoError(t, err)
+		require.Len(t, jobs, 0)
+	})
+
+	t.Run("delete batch", func(t *testing.T) {
+		branchRepo := models.NewBranchRepo(db)
+		for _, name := range []string{"a", "b", "c"} {
+			_, err := branchRepo.Insert(ctx, &models.Branch{
+				RepositoryID: repository.ID,
+				Name:         name,
+				CommitHash:   hash.Hash("commit"),
+				CreatorID:    uuid.New(),
+				CreatedAt:    time.Now(),
+				UpdatedAt:    time.Now(),
+			})
+			require.NoError(t, err)
+		}
+		table := models.RepositoryDataTables[0]
+		deleted, err := repo.DeleteBatch(ctx, table, repository.ID, 2)
+		require.NoError(t, err)
+		require.Equal(t, int64(2), deleted)
+		deleted, err = repo.DeleteBatch(ctx, table, repository.ID, 2)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), deleted)
+		deleted, err = repo.DeleteBatch(ctx, table, repository.ID, 2)
+		require.NoError(t, err)
+		require.Equal(t, int64(0), deleted)
+	})
+}
@@ -0,0 +1,85 @@
+package models
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// RepositoryStats storage usage of repository, counters are updated in the same statement batch as commits and objects
+// are inserted or deleted, so they are consistent with the transaction that changed them
+type RepositoryStats struct {
+	bun.BaseModel `bun:"table:repo_stats"`
+	RepositoryID  uuid.UUID `bun:"repository_id,pk,type:uuid" json:"repository_id"`
+	// BlobBytes total size of blobs, blobs sharing the same content are counted separately
+	BlobBytes int64 `bun:"blob_bytes,notnull,default:0" json:"blob_bytes"`
+	// ObjectCount number of trees and blobs
+	ObjectCount int64 `bun:"object_count,notnull,default:0" json:"object_count"`
+	CommitCount int64 `bun:"commit_count,notnull,default:0" json:"commit_count"`
+
+	UpdatedAt time.Time `bun:"updated_at,type:timestamp,notnull" json:"updated_at"`
+}
+
+// addRepoStats add delta to stats of repository. stats of repository created before stats were introduced does not
+// exist and is left untouched, it is calculated from scratch on first read
+func addRepoStats(ctx context.Context, db bun.IDB, repositoryID uuid.UUID, blobBytes, objectCount, commitCount int64) error {
+	if blobBytes == 0 && objectCount == 0 && commitCount == 0 {
+		return nil
+	}
+	_, err := db.NewUpdate().Model((*RepositoryStats)(nil)).
+		Set("blob_bytes = blob_bytes + ?", blobBytes).
+		Set("object_count = object_count + ?", objectCount).
+		Set("commit_count = commit_count + ?", commitCount).
+		Set("updated_at = ?", time.Now()).
+		Where("repository_id = ?", repositoryID).
+		Exec(ctx)
+	return toModelError(err)
+}
+
+func (r *RepositoryRepo) GetStats(ctx context.Context, repositoryID uuid.UUID) (*RepositoryStats, error) {
+	stats := &RepositoryStats{}
+	err := r.db.NewSelect().Model(stats).Where("repository_id = ?", repositoryID).Scan(ctx)
+	if errors.Is(err, ErrNotFound) {
+		return r.RecalculateStats(ctx, repositoryID)
+	}
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return stats, nil
+}
+
+func (r *RepositoryRepo) RecalculateStats(ctx context.Context, repositoryID uuid.UUID) (*RepositoryStats, error) {
+	stats := &RepositoryStats{
+		RepositoryID: repositoryID,
+		UpdatedAt:    time.Now(),
+	}
+	err := r.db.NewSelect().Model((*FileTree)(nil)).
+		ColumnExpr("COUNT(*)").
+		ColumnExpr("COALESCE(SUM(CASE WHEN type = ? THEN size ELSE 0 END), 0)", BlobObject).
+		Where("repository_id = ?", repositoryID).
+		Scan(ctx, &stats.ObjectCount, &stats.BlobBytes)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+
+	commitCount, err := NewCommitRepo(r.db, repositoryID).Count(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stats.CommitCount = int64(commitCount)
+
+	_, err = r.db.NewInsert().Model(stats).
+		On("CONFLICT (repository_id) DO UPDATE").
+		Set("blob_bytes = EXCLUDED.blob_bytes").
+		Set("object_count = EXCLUDED.object_count").
+		Set("commit_count = EXCLUDED.commit_count").
+		Set("updated_at = EXCLUDED.updated_at").
+		Exec(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return stats, nil
+}
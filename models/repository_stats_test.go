@@ -0,0 +1,106 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/models/filemode"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryStats(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepositoryRepo(db)
+
+	repoModel := &models.Repository{}
+	require.NoError(t, gofakeit.Struct(repoModel))
+	newRepo, err := repo.Insert(ctx, repoModel)
+	require.NoError(t, err)
+
+	stats, err := repo.GetStats(ctx, newRepo.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), stats.BlobBytes)
+	require.Equal(t, int64(0), stats.ObjectCount)
+	require.Equal(t, int64(0), stats.CommitCount)
+
+	treeRepo := models.NewFileTree(db, newRepo.ID)
+	var blobs []*models.FileTree
+	for i := 0; i < 3; i++ {
+		objModel := &models.FileTree{}
+		require.NoError(t, gofakeit.Struct(objModel))
+		objModel.RepositoryID = newRepo.ID
+		objModel.Type = models.BlobObject
+		objModel.Size = 10
+		objModel.Properties.Mode = filemode.Regular
+		newObj, err := treeRepo.Insert(ctx, objModel)
+		require.NoError(t, err)
+		blobs = append(blobs, newObj)
+	}
+	treeModel := &models.FileTree{}
+	require.NoError(t, gofakeit.Struct(treeModel))
+	treeModel.RepositoryID = newRepo.ID
+	treeModel.Type = models.TreeObject
+	treeModel.Size = 100
+	treeModel.Properties.Mode = filemode.Dir
+	_, err = treeRepo.Insert(ctx, treeModel)
+	require.NoError(t, err)
+
+	//insert exist object again is not counted
+	_, err = treeRepo.Insert(ctx, blobs[0])
+	require.NoError(t, err)
+
+	commitRepo := models.NewCommitRepo(db, newRepo.ID)
+	commitModel := &models.Commit{}
+	require.NoError(t, gofakeit.Struct(commitModel))
+	commitModel.RepositoryID = newRepo.ID
+	_, err = commitRepo.Insert(ctx, commitModel)
+	require.NoError(t, err)
+
+	stats, err = repo.GetStats(ctx, newRepo.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(30), stats.BlobBytes)
+	require.Equal(t, int64(4), stats.ObjectCount)
+	require.Equal(t, int64(1), stats.CommitCount)
+
+	t.Run("gc objects", func(t *testing.T) {
+		_, err = treeRepo.Delete(ctx, models.NewDeleteTreeParams().SetHash(blobs[0].Hash))
+		require.NoError(t, err)
+		_, err = commitRepo.Delete(ctx, models.NewDeleteParams())
+		require.NoError(t, err)
+
+		stats, err := repo.GetStats(ctx, newRepo.ID)
+		require.NoError(t, err)
+		require.Equal(t, int64(20), stats.BlobBytes)
+		require.Equal(t, int64(3), stats.ObjectCount)
+		require.Equal(t, int64(0), stats.CommitCount)
+	})
+
+	t.Run("recalculate", func(t *testing.T) {
+		_, err = db.NewDelete().Model((*models.RepositoryStats)(nil)).Where("repository_id = ?", newRepo.ID).Exec(ctx)
+		require.NoError(t, err)
+
+		stats, err := repo.GetStats(ctx, newRepo.ID)
+		require.NoError(t, err)
+		require.Equal(t, int64(20), stats.BlobBytes)
+		require.Equal(t, int64(3), stats.ObjectCount)
+		require.Equal(t, int64(0), stats.CommitCount)
+
+		stats, err = repo.RecalculateStats(ctx, newRepo.ID)
+		require.NoError(t, err)
+		require.Equal(t, int64(20), stats.BlobBytes)
+	})
+
+	t.Run("purge", func(t *testing.T) {
+		_, err = repo.Purge(ctx, models.NewDeleteRepoParams().SetID(newRepo.ID))
+		require.NoError(t, err)
+		count, err := db.NewSelect().Model((*models.RepositoryStats)(nil)).Where("repository_id = ?", newRepo.ID).Count(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 0, count)
+	})
+}
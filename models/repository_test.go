@@ -7,6 +7,7 @@ import (
 
 	"github.com/GitDataAI/jiaozifs/models"
 	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils"
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/uuid"
@@ -45,6 +46,21 @@ func TestRepositoryUpdate(t *testing.T) {
 		require.Equal(t, !newRepo.Visible, user.Visible)
 	})
 
+	t.Run("update archived", func(t *testing.T) {
+		repoModel := &models.Repository{}
+		require.NoError(t, gofakeit.Struct(repoModel))
+		repoModel.Archived = false
+		newRepo, err := repo.Insert(ctx, repoModel)
+		require.NoError(t, err)
+		require.NoError(t, newRepo.CheckWritable())
+		err = repo.UpdateByID(ctx, models.NewUpdateRepoParams(newRepo.ID).SetArchived(true))
+		require.NoError(t, err)
+		archivedRepo, err := repo.Get(ctx, models.NewGetRepoParams().SetID(newRepo.ID))
+		require.NoError(t, err)
+		require.True(t, archivedRepo.Archived)
+		require.ErrorIs(t, archivedRepo.CheckWritable(), models.ErrRepositoryArchived)
+	})
+
 	t.Run("update all fields", func(t *testing.T) {
 		repoModel := &models.Repository{}
 		require.NoError(t, gofakeit.Struct(repoModel))
@@ -57,6 +73,128 @@ func TestRepositoryUpdate(t *testing.T) {
 		require.Equal(t, "description", *user.Description)
 		require.Equal(t, "ggg", user.HEAD)
 	})
+
+	t.Run("update at version", func(t *testing.T) {
+		repoModel := &models.Repository{}
+		require.NoError(t, gofakeit.Struct(repoModel))
+		newRepo, err := repo.Insert(ctx, repoModel)
+		require.NoError(t, err)
+
+		err = repo.UpdateByID(ctx, models.NewUpdateRepoParams(newRepo.ID).SetDescription("first").SetVersion(newRepo.Version))
+		require.NoError(t, err)
+		err = repo.UpdateByID(ctx, models.NewUpdateRepoParams(newRepo.ID).SetDescription("second").SetVersion(newRepo.Version))
+		require.ErrorIs(t, err, models.ErrStaleObject)
+
+		user, err := repo.Get(ctx, models.NewGetRepoParams().SetID(newRepo.ID))
+		require.NoError(t, err)
+		require.Equal(t, "first", *user.Description)
+		require.Equal(t, newRepo.Version+1, user.Version)
+	})
+
+	t.Run("rename", func(t *testing.T) {
+		repoModel := &models.Repository{}
+		require.NoError(t, gofakeit.Struct(repoModel))
+		newRepo, err := repo.Insert(ctx, repoModel)
+		require.NoError(t, err)
+
+		require.NoError(t, repo.Rename(ctx, newRepo.ID, "renamed"))
+		user, err := repo.Get(ctx, models.NewGetRepoParams().SetID(newRepo.ID))
+		require.NoError(t, err)
+		require.Equal(t, "renamed", user.Name)
+		require.Equal(t, newRepo.HEAD, user.HEAD)
+
+		alias, err := repo.GetAlias(ctx, newRepo.OwnerID, newRepo.Name)
+		require.NoError(t, err)
+		require.Equal(t, newRepo.ID, alias.RepositoryID)
+
+		//rename back take the name from alias
+		require.NoError(t, repo.Rename(ctx, newRepo.ID, newRepo.Name))
+		_, err = repo.GetAlias(ctx, newRepo.OwnerID, newRepo.Name)
+		require.ErrorIs(t, err, models.ErrNotFound)
+		alias, err = repo.GetAlias(ctx, newRepo.OwnerID, "renamed")
+		require.NoError(t, err)
+		require.Equal(t, newRepo.ID, alias.RepositoryID)
+
+		//name taken by other repository
+		otherModel := &models.Repository{}
+		require.NoError(t, gofakeit.Struct(otherModel))
+		otherModel.OwnerID = newRepo.OwnerID
+		otherRepo, err := repo.Insert(ctx, otherModel)
+		require.NoError(t, err)
+		require.Error(t, repo.Rename(ctx, otherRepo.ID, newRepo.Name))
+
+		_, err = repo.Purge(ctx, models.NewDeleteRepoParams().SetID(newRepo.ID))
+		require.NoError(t, err)
+		_, err = repo.GetAlias(ctx, newRepo.OwnerID, "renamed")
+		require.ErrorIs(t, err, models.ErrNotFound)
+	})
+
+	t.Run("update private prefixes", func(t *testing.T) {
+		repoModel := &models.Repository{}
+		require.NoError(t, gofakeit.Struct(repoModel))
+		newRepo, err := repo.Insert(ctx, repoModel)
+		require.NoError(t, err)
+		err = repo.UpdateByID(ctx, models.NewUpdateRepoParams(newRepo.ID).SetPrivatePrefixes([]string{"raw-pii", "data/secret"}))
+		require.NoError(t, err)
+		user, err := repo.Get(ctx, models.NewGetRepoParams().SetID(newRepo.ID))
+		require.NoError(t, err)
+		require.Equal(t, []string{"raw-pii", "data/secret"}, user.PrivatePrefixes)
+		require.True(t, user.IsPrivatePath("raw-pii/a.csv"))
+	})
+
+	t.Run("update masking rules", func(t *testing.T) {
+		repoModel := &models.Repository{}
+		require.NoError(t, gofakeit.Struct(repoModel))
+		newRepo, err := repo.Insert(ctx, repoModel)
+		require.NoError(t, err)
+		rules := []models.MaskingRule{{Path: "users/*.csv", Columns: []string{"email"}, Method: "redact"}}
+		err = repo.UpdateByID(ctx, models.NewUpdateRepoParams(newRepo.ID).SetMaskingRules(rules))
+		require.NoError(t, err)
+		user, err := repo.Get(ctx, models.NewGetRepoParams().SetID(newRepo.ID))
+		require.NoError(t, err)
+		require.Equal(t, rules, user.MaskingRules)
+	})
+
+	t.Run("update merge rules", func(t *testing.T) {
+		repoModel := &models.Repository{}
+		require.NoError(t, gofakeit.Struct(repoModel))
+		newRepo, err := repo.Insert(ctx, repoModel)
+		require.NoError(t, err)
+		rules := []models.MergeRule{{Branch: "prod/*", DefaultReviewers: []uuid.UUID{uuid.New()}, Checklist: []string{"schema checked"}}}
+		err = repo.UpdateByID(ctx, models.NewUpdateRepoParams(newRepo.ID).SetMergeRules(rules))
+		require.NoError(t, err)
+		user, err := repo.Get(ctx, models.NewGetRepoParams().SetID(newRepo.ID))
+		require.NoError(t, err)
+		require.Equal(t, rules, user.MergeRules)
+	})
+
+	t.Run("update issue links", func(t *testing.T) {
+		repoModel := &models.Repository{}
+		require.NoError(t, gofakeit.Struct(repoModel))
+		newRepo, err := repo.Insert(ctx, repoModel)
+		require.NoError(t, err)
+		links := []models.IssueLink{{Prefix: "DATA-", URLTemplate: "https://jira.example.com/browse/DATA-{id}"}}
+		err = repo.UpdateByID(ctx, models.NewUpdateRepoParams(newRepo.ID).SetIssueLinks(links))
+		require.NoError(t, err)
+		user, err := repo.Get(ctx, models.NewGetRepoParams().SetID(newRepo.ID))
+		require.NoError(t, err)
+		require.Equal(t, links, user.IssueLinks)
+	})
+
+	t.Run("update limits", func(t *testing.T) {
+		repoModel := &models.Repository{}
+		require.NoError(t, gofakeit.Struct(repoModel))
+		newRepo, err := repo.Insert(ctx, repoModel)
+		require.NoError(t, err)
+		err = repo.UpdateByID(ctx, models.NewUpdateRepoParams(newRepo.ID).SetWriteRateLimit(120).SetWriteBurst(10).SetMaxConcurrentUploads(4))
+		require.NoError(t, err)
+		user, err := repo.Get(ctx, models.NewGetRepoParams().SetID(newRepo.ID))
+		require.NoError(t, err)
+		require.Equal(t, 120, user.WriteRateLimit)
+		require.Equal(t, 10, user.WriteBurst)
+		require.Equal(t, 4, user.MaxConcurrentUploads)
+		require.Equal(t, newRepo.HEAD, user.HEAD)
+	})
 }
 
 func TestRepositoryRepoInsert(t *testing.T) {
@@ -93,6 +231,13 @@ func TestRepositoryRepoInsert(t *testing.T) {
 	require.NoError(t, err)
 	require.Len(t, repos, 2)
 
+	count, err := repo.Count(ctx, models.NewListRepoParams().SetAmount(1))
+	require.NoError(t, err)
+	require.Equal(t, 2, count)
+	count, err = repo.Count(ctx, models.NewListRepoParams().SetName("adabbeb", models.PrefixMatch))
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+
 	{
 		//exact adabbeb
 		repos, _, err := repo.List(ctx, models.NewListRepoParams().SetVisible(true).SetCreatorID(secModel.CreatorID).SetName("adabbeb", models.PrefixMatch))
@@ -124,6 +269,35 @@ func TestRepositoryRepoInsert(t *testing.T) {
 		require.NoError(t, err)
 		require.Len(t, repos, 1)
 	}
+	{
+		//case-insensitive
+		repos, _, err := repo.List(ctx, models.NewListRepoParams().SetVisible(true).SetCreatorID(secModel.CreatorID).SetName("ADA", models.IPrefixMatch))
+		require.NoError(t, err)
+		require.Len(t, repos, 1)
+
+		repos, _, err = repo.List(ctx, models.NewListRepoParams().SetVisible(true).SetCreatorID(secModel.CreatorID).SetName("BB", models.ILikeMatch))
+		require.NoError(t, err)
+		require.Len(t, repos, 2)
+
+		repos, _, err = repo.List(ctx, models.NewListRepoParams().SetVisible(true).SetCreatorID(secModel.CreatorID).SetName("EB", models.ISuffixMatch))
+		require.NoError(t, err)
+		require.Len(t, repos, 1)
+	}
+	{
+		//regex
+		repos, _, err := repo.List(ctx, models.NewListRepoParams().SetVisible(true).SetCreatorID(secModel.CreatorID).SetName("^a+b{4}$", models.RegexMatch))
+		require.NoError(t, err)
+		require.Len(t, repos, 1)
+		require.Equal(t, "aaabbbb", repos[0].Name)
+
+		repos, _, err = repo.List(ctx, models.NewListRepoParams().SetVisible(true).SetCreatorID(secModel.CreatorID).SetName("^A.*B$", models.RegexMatch))
+		require.NoError(t, err)
+		require.Len(t, repos, 0)
+
+		repos, _, err = repo.List(ctx, models.NewListRepoParams().SetVisible(true).SetCreatorID(secModel.CreatorID).SetName("^A.*B$", models.IRegexMatch))
+		require.NoError(t, err)
+		require.Len(t, repos, 2)
+	}
 	{
 		//amount 1
 		repos, hasMore, err := repo.List(ctx, models.NewListRepoParams().SetVisible(true).SetCreatorID(secModel.CreatorID).SetAmount(1))
@@ -167,4 +341,150 @@ func TestRepositoryRepoInsert(t *testing.T) {
 	affectRows, err = repo.Delete(ctx, deleteParams)
 	require.NoError(t, err)
 	require.Equal(t, int64(0), affectRows)
+
+	//trash
+	deletedRepo, err := repo.Get(ctx, models.NewGetRepoParams().SetID(secRepo.ID).SetDeleted())
+	require.NoError(t, err)
+	require.False(t, deletedRepo.DeletedAt.IsZero())
+
+	repos, _, err = repo.List(ctx, models.NewListRepoParams())
+	require.NoError(t, err)
+	require.Len(t, repos, 1)
+
+	repos, hasMore, err := repo.ListDeleted(ctx, models.NewListRepoParams().SetCreatorID(secModel.CreatorID).SetAmount(10))
+	require.NoError(t, err)
+	require.False(t, hasMore)
+	require.Len(t, repos, 1)
+	require.Equal(t, secRepo.ID, repos[0].ID)
+
+	//restore
+	affectRows, err = repo.Restore(ctx, secRepo.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), affectRows)
+
+	restoredRepo, err := repo.Get(ctx, models.NewGetRepoParams().SetID(secRepo.ID))
+	require.NoError(t, err)
+	require.True(t, restoredRepo.DeletedAt.IsZero())
+
+	affectRows, err = repo.Restore(ctx, secRepo.ID)
+	require.NoError(t, err)
+	require.Equal(t, int64(0), affectRows)
+
+	//purge repository in trash
+	_, err = repo.Delete(ctx, deleteParams)
+	require.NoError(t, err)
+	affectRows, err = repo.Purge(ctx, deleteParams)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), affectRows)
+
+	_, err = repo.Get(ctx, models.NewGetRepoParams().SetID(secRepo.ID).SetDeleted())
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	//purge repository not in trash
+	affectRows, err = repo.Purge(ctx, models.NewDeleteRepoParams().SetID(newRepo.ID))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), affectRows)
+}
+
+func TestRepositoryFork(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepositoryRepo(db)
+
+	insert := func(name string, storageNamespace string, forkedFromID uuid.UUID) *models.Repository {
+		repoModel := &models.Repository{}
+		require.NoError(t, gofakeit.Struct(repoModel))
+		repoModel.Name = name
+		repoModel.StorageNamespace = utils.String(storageNamespace)
+		repoModel.ForkedFromID = forkedFromID
+		newRepo, err := repo.Insert(ctx, repoModel)
+		require.NoError(t, err)
+		return newRepo
+	}
+	upstream := insert("upstream", "local://upstream", uuid.Nil)
+	fork := insert("fork", "local://upstream", upstream.ID)
+	other := insert("other", "local://other", uuid.Nil)
+
+	forks, _, err := repo.List(ctx, models.NewListRepoParams().SetForkedFromID(upstream.ID))
+	require.NoError(t, err)
+	require.Len(t, forks, 1)
+	require.Equal(t, fork.ID, forks[0].ID)
+
+	sharers, err := repo.ListSharingStorage(ctx, other)
+	require.NoError(t, err)
+	require.Empty(t, sharers)
+
+	//repository in trash still share storage
+	_, err = repo.Delete(ctx, models.NewDeleteRepoParams().SetID(fork.ID))
+	require.NoError(t, err)
+	sharers, err = repo.ListSharingStorage(ctx, upstream)
+	require.NoError(t, err)
+	require.Len(t, sharers, 1)
+	require.Equal(t, fork.ID, sharers[0].ID)
+
+	_, err = repo.Purge(ctx, models.NewDeleteRepoParams().SetID(fork.ID))
+	require.NoError(t, err)
+	sharers, err = repo.ListSharingStorage(ctx, upstream)
+	require.NoError(t, err)
+	require.Empty(t, sharers)
+}
+
+func TestRepositoryIsPrivatePath(t *testing.T) {
+	repository := &models.Repository{PrivatePrefixes: []string{"raw-pii", "data/secret", ""}}
+	for path, expect := range map[string]bool{
+		"raw-pii":              true,
+		"/raw-pii/a.txt":       true,
+		"raw-pii/b/c.txt":      true,
+		"raw-piiother/a.txt":   false,
+		"data":                 false,
+		"data/secret":          true,
+		"data/secret/x.csv":    true,
+		"data/secrets/x.csv":   false,
+		"data/../raw-pii/a.md": true,
+		"readme.md":            false,
+	} {
+		require.Equal(t, expect, repository.IsPrivatePath(path), path)
+	}
+}
+
+func TestRepositoryMaskedColumns(t *testing.T) {
+	repository := &models.Repository{MaskingRules: []models.MaskingRule{
+		{Path: "users/*.csv", Columns: []string{"email", "phone"}, Method: "redact"},
+		{Path: "events", Columns: []string{"user_id"}, Method: "hash"},
+		{Path: "users/vip.csv", Columns: []string{"email"}, Method: "hash"},
+	}}
+
+	require.Equal(t, map[string]string{"email": "redact", "phone": "redact"}, repository.MaskedColumns("users/a.csv"))
+	require.Equal(t, map[string]string{"email": "hash", "phone": "redact"}, repository.MaskedColumns("/users/vip.csv"))
+	require.Equal(t, map[string]string{"user_id": "hash"}, repository.MaskedColumns("events/2024/01.jsonl"))
+	require.Nil(t, repository.MaskedColumns("users/deep/a.csv"))
+	require.Nil(t, repository.MaskedColumns("eventsx/a.jsonl"))
+}
+
+func TestRepositoryMergeRulesOf(t *testing.T) {
+	repository := &models.Repository{MergeRules: []models.MergeRule{
+		{Branch: "main", Checklist: []string{"a"}},
+		{Branch: "prod/*", Checklist: []string{"b"}},
+	}}
+
+	require.Len(t, repository.MergeRulesOf("main"), 1)
+	require.Equal(t, []string{"b"}, repository.MergeRulesOf("prod/cn")[0].Checklist)
+	require.Nil(t, repository.MergeRulesOf("prod/cn/a"))
+	require.Nil(t, repository.MergeRulesOf("feat"))
+}
+
+func TestRepositoryIssueRefs(t *testing.T) {
+	repository := &models.Repository{IssueLinks: []models.IssueLink{
+		{Prefix: "DATA-", URLTemplate: "https://jira.example.com/browse/DATA-{id}"},
+		{Prefix: "#", URLTemplate: "https://github.com/a/b/issues/{id}"},
+	}}
+
+	require.Equal(t, []models.IssueRef{
+		{ID: "DATA-12", URL: "https://jira.example.com/browse/DATA-12"},
+		{ID: "DATA-3", URL: "https://jira.example.com/browse/DATA-3"},
+		{ID: "#7", URL: "https://github.com/a/b/issues/7"},
+	}, repository.IssueRefs("clean labels DATA-12, fix #7", "(DATA-3) and DATA-12 again"))
+	require.Nil(t, repository.IssueRefs("METADATA-12 a#7 DATA-x DATA-12a"))
 }
@@ -0,0 +1,175 @@
+package models
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"syscall"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/config"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/driver/pgdriver"
+	"modernc.org/sqlite"
+	sqlite3 "modernc.org/sqlite/lib"
+)
+
+var retryLog = logging.Logger("db_retry")
+
+// RetryDB retry statements failed by bad connection, which are never sent to database, and transactions failed by
+// transient errors. statements in transaction are not retried alone, the whole transaction is run again instead.
+// errors of statement timeout or transient errors left after retries are wrapped by ErrDatabaseUnavailable, so raw
+// driver errors are not returned to users
+type RetryDB struct {
+	*bun.DB
+	maxRetries int
+	backoff    time.Duration
+}
+
+var _ bun.IDB = (*RetryDB)(nil)
+
+func NewRetryDB(db *bun.DB, cfg *config.DatabaseConfig) *RetryDB {
+	return &RetryDB{
+		DB:         db,
+		maxRetries: cfg.MaxRetries,
+		backoff:    cfg.RetryBackoff,
+	}
+}
+
+func (db *RetryDB) NewSelect() *bun.SelectQuery {
+	return db.DB.NewSelect().Conn(retryConn{db: db})
+}
+
+func (db *RetryDB) NewInsert() *bun.InsertQuery {
+	return db.DB.NewInsert().Conn(retryConn{db: db})
+}
+
+func (db *RetryDB) NewUpdate() *bun.UpdateQuery {
+	return db.DB.NewUpdate().Conn(retryConn{db: db})
+}
+
+func (db *RetryDB) NewDelete() *bun.DeleteQuery {
+	return db.DB.NewDelete().Conn(retryConn{db: db})
+}
+
+func (db *RetryDB) NewRaw(query string, args ...interface{}) *bun.RawQuery {
+	return db.DB.NewRaw(query, args...).Conn(retryConn{db: db})
+}
+
+func (db *RetryDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := db.retry(ctx, isBadConn, func() (err error) {
+		result, err = db.DB.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+func (db *RetryDB) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := db.retry(ctx, isBadConn, func() (err error) {
+		rows, err = db.DB.QueryContext(ctx, query, args...) //nolint
+		return err
+	})
+	return rows, err
+}
+
+func (db *RetryDB) RunInTx(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context, tx bun.Tx) error) error {
+	return db.retry(ctx, IsTransientError, func() error {
+		return db.DB.RunInTx(ctx, opts, fn)
+	})
+}
+
+func (db *RetryDB) retry(ctx context.Context, retryable func(error) bool, fn func() error) error {
+	backoff := db.backoff
+	for i := 0; ; i++ {
+		err := fn()
+		if err == nil {
+			return nil
+		}
+		if !retryable(err) {
+			if isStatementTimeout(err) {
+				return fmt.Errorf("%w %w", ErrDatabaseUnavailable, err)
+			}
+			return err
+		}
+		if i >= db.maxRetries {
+			return fmt.Errorf("%w %w", ErrDatabaseUnavailable, err)
+		}
+
+		retryLog.Warnf("retry database operation after %s for transient error %v", backoff, err)
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+}
+
+// retryConn run statements built by RetryDB through it
+type retryConn struct {
+	db *RetryDB
+}
+
+func (conn retryConn) QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error) {
+	var rows *sql.Rows
+	err := conn.db.retry(ctx, isBadConn, func() (err error) {
+		rows, err = conn.db.DB.DB.QueryContext(ctx, query, args...) //nolint
+		return err
+	})
+	return rows, err
+}
+
+func (conn retryConn) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var result sql.Result
+	err := conn.db.retry(ctx, isBadConn, func() (err error) {
+		result, err = conn.db.DB.DB.ExecContext(ctx, query, args...)
+		return err
+	})
+	return result, err
+}
+
+// QueryRowContext is not retried, error of row is only known when it is scanned
+func (conn retryConn) QueryRowContext(ctx context.Context, query string, args ...interface{}) *sql.Row {
+	return conn.db.DB.DB.QueryRowContext(ctx, query, args...)
+}
+
+// IsTransientError check whether transaction failed by err may succeed if it is run again, such as serialization
+// failure, deadlock, busy sqlite database and bad connection. errors of connection reset are not transient, as
+// commit may have been applied before connection is lost
+func IsTransientError(err error) bool {
+	var pgErr pgdriver.Error
+	if errors.As(err, &pgErr) {
+		switch pgErr.Field('C') {
+		case "40001", "40P01": // serialization_failure, deadlock_detected
+			return true
+		}
+		return false
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		return sqliteErr.Code()&0xff == sqlite3.SQLITE_BUSY
+	}
+
+	return isBadConn(err)
+}
+
+// isBadConn check whether err is returned by driver before statement is sent, so statement is safe to run again
+func isBadConn(err error) bool {
+	return errors.Is(err, driver.ErrBadConn)
+}
+
+// isConnectionError check whether err is caused by connection to database, only reads are safe to run again
+func isConnectionError(err error) bool {
+	return isBadConn(err) || errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED)
+}
+
+func isStatementTimeout(err error) bool {
+	var pgErr pgdriver.Error
+	return errors.As(err, &pgErr) && pgErr.StatementTimeout()
+}
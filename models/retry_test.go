@@ -0,0 +1,85 @@
+package models_test
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/config"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRetryDB(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(models.NewRetryDB(db, &config.DatabaseConfig{MaxRetries: 2, RetryBackoff: time.Millisecond}))
+
+	t.Run("statements", func(t *testing.T) {
+		user := &models.User{}
+		require.NoError(t, gofakeit.Struct(user))
+		newUser, err := repo.UserRepo().Insert(ctx, user)
+		require.NoError(t, err)
+
+		actualUser, err := repo.UserRepo().Get(ctx, models.NewGetUserParams().SetID(newUser.ID))
+		require.NoError(t, err)
+		require.Equal(t, newUser.Name, actualUser.Name)
+
+		_, err = repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName("not exist"))
+		require.ErrorIs(t, err, models.ErrNotFound)
+	})
+
+	t.Run("retry transaction", func(t *testing.T) {
+		attempts := 0
+		err := repo.Transaction(ctx, func(repo models.IRepo) error {
+			attempts++
+			if attempts < 3 {
+				return fmt.Errorf("lost connection %w", driver.ErrBadConn)
+			}
+			return nil
+		})
+		require.NoError(t, err)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("give up", func(t *testing.T) {
+		attempts := 0
+		err := repo.Transaction(ctx, func(repo models.IRepo) error {
+			attempts++
+			return driver.ErrBadConn
+		})
+		require.ErrorIs(t, err, models.ErrDatabaseUnavailable)
+		require.Equal(t, 3, attempts)
+	})
+
+	t.Run("not retry other errors", func(t *testing.T) {
+		attempts := 0
+		fnErr := errors.New("mock")
+		err := repo.Transaction(ctx, func(repo models.IRepo) error {
+			attempts++
+			return fnErr
+		})
+		require.ErrorIs(t, err, fnErr)
+		require.NotErrorIs(t, err, models.ErrDatabaseUnavailable)
+		require.Equal(t, 1, attempts)
+	})
+
+	t.Run("not retry connection reset", func(t *testing.T) {
+		attempts := 0
+		err := repo.Transaction(ctx, func(repo models.IRepo) error {
+			attempts++
+			return fmt.Errorf("write tcp %w", syscall.ECONNRESET)
+		})
+		require.ErrorIs(t, err, syscall.ECONNRESET)
+		require.NotErrorIs(t, err, models.ErrDatabaseUnavailable)
+		require.Equal(t, 1, attempts)
+	})
+}
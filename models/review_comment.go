@@ -0,0 +1,271 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// ReviewComment comment of merge request, comments replying to the same root comment make a thread. comment anchored to
+// a path is about that file, optionally a line range or byte range of it
+type ReviewComment struct {
+	bun.BaseModel  `bun:"table:review_comments"`
+	ID             uuid.UUID `bun:"id,pk,type:uuid,default:uuid_generate_v4()" json:"id"`
+	RepositoryID   uuid.UUID `bun:"repository_id,type:uuid,notnull" json:"repository_id"`
+	MergeRequestID uuid.UUID `bun:"merge_request_id,type:uuid,notnull" json:"merge_request_id"`
+	// ParentID root comment of thread this comment replies to, empty for root comment
+	ParentID uuid.UUID `bun:"parent_id,type:uuid,nullzero" json:"parent_id"`
+	AuthorID uuid.UUID `bun:"author_id,type:uuid,notnull" json:"author_id"`
+	Body     string    `bun:"body,notnull" json:"body"`
+
+	// Path file comment is anchored to, empty for comment on whole merge request
+	Path string `bun:"path,nullzero" json:"path"`
+	// StartLine first line commented, lines start from 1 and zero means no line range
+	StartLine int `bun:"start_line,notnull,default:0" json:"start_line"`
+	EndLine   int `bun:"end_line,notnull,default:0" json:"end_line"`
+	// StartByte offset of first byte commented, range is [StartByte, EndByte) and empty means no byte range
+	StartByte int64 `bun:"start_byte,notnull,default:0" json:"start_byte"`
+	EndByte   int64 `bun:"end_byte,notnull,default:0" json:"end_byte"`
+	// CommitHash head of source branch when commented, comment is outdated once source branch moves on
+	CommitHash hash.Hash `bun:"commit_hash,type:bytea" json:"commit_hash"`
+
+	// Resolved only root comment is resolved, for its whole thread
+	Resolved   bool      `bun:"resolved,notnull,default:false" json:"resolved"`
+	ResolvedBy uuid.UUID `bun:"resolved_by,type:uuid,nullzero" json:"resolved_by"`
+
+	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+	UpdatedAt time.Time `bun:"updated_at,type:timestamp,notnull" json:"updated_at"`
+}
+
+// IsRoot check whether comment starts a thread
+func (comment *ReviewComment) IsRoot() bool {
+	return comment.ParentID == uuid.Nil
+}
+
+type GetReviewCommentParams struct {
+	id             uuid.UUID
+	mergeRequestID uuid.UUID
+}
+
+func NewGetReviewCommentParams() *GetReviewCommentParams {
+	return &GetReviewCommentParams{}
+}
+
+func (gp *GetReviewCommentParams) SetID(id uuid.UUID) *GetReviewCommentParams {
+	gp.id = id
+	return gp
+}
+
+func (gp *GetReviewCommentParams) SetMergeRequestID(mergeRequestID uuid.UUID) *GetReviewCommentParams {
+	gp.mergeRequestID = mergeRequestID
+	return gp
+}
+
+type ListReviewCommentParams struct {
+	mergeRequestID uuid.UUID
+	path           *string
+	resolved       *bool
+}
+
+func NewListReviewCommentParams() *ListReviewCommentParams {
+	return &ListReviewCommentParams{}
+}
+
+func (lp *ListReviewCommentParams) SetMergeRequestID(mergeRequestID uuid.UUID) *ListReviewCommentParams {
+	lp.mergeRequestID = mergeRequestID
+	return lp
+}
+
+// SetPath only threads anchored to path
+func (lp *ListReviewCommentParams) SetPath(path string) *ListReviewCommentParams {
+	lp.path = &path
+	return lp
+}
+
+// SetResolved only threads resolved or not
+func (lp *ListReviewCommentParams) SetResolved(resolved bool) *ListReviewCommentParams {
+	lp.resolved = &resolved
+	return lp
+}
+
+type UpdateReviewCommentParams struct {
+	id         uuid.UUID
+	body       *string
+	resolved   *bool
+	resolvedBy uuid.UUID
+}
+
+func NewUpdateReviewCommentParams(id uuid.UUID) *UpdateReviewCommentParams {
+	return &UpdateReviewCommentParams{id: id}
+}
+
+func (up *UpdateReviewCommentParams) SetBody(body string) *UpdateReviewCommentParams {
+	up.body = &body
+	return up
+}
+
+// SetResolved resolve thread by user or unresolve it
+func (up *UpdateReviewCommentParams) SetResolved(resolved bool, userID uuid.UUID) *UpdateReviewCommentParams {
+	up.resolved = &resolved
+	if resolved {
+		up.resolvedBy = userID
+	}
+	return up
+}
+
+type DeleteReviewCommentParams struct {
+	id             uuid.UUID
+	mergeRequestID uuid.UUID
+}
+
+func NewDeleteReviewCommentParams() *DeleteReviewCommentParams {
+	return &DeleteReviewCommentParams{}
+}
+
+func (dp *DeleteReviewCommentParams) SetID(id uuid.UUID) *DeleteReviewCommentParams {
+	dp.id = id
+	return dp
+}
+
+func (dp *DeleteReviewCommentParams) SetMergeRequestID(mergeRequestID uuid.UUID) *DeleteReviewCommentParams {
+	dp.mergeRequestID = mergeRequestID
+	return dp
+}
+
+type IReviewCommentRepo interface {
+	Insert(ctx context.Context, comment *ReviewComment) (*ReviewComment, error)
+	Get(ctx context.Context, params *GetReviewCommentParams) (*ReviewComment, error)
+	// List comments of matched threads, every root comment is followed by its replies, threads and replies are in the
+	// order they were created
+	List(ctx context.Context, params *ListReviewCommentParams) ([]*ReviewComment, error)
+	Update(ctx context.Context, params *UpdateReviewCommentParams) error
+	// Delete remove comments with replies of them
+	Delete(ctx context.Context, params *DeleteReviewCommentParams) (int64, error)
+}
+
+var _ IReviewCommentRepo = (*ReviewCommentRepo)(nil)
+
+type ReviewCommentRepo struct {
+	db bun.IDB
+}
+
+func NewReviewCommentRepo(db bun.IDB) IReviewCommentRepo {
+	return &ReviewCommentRepo{db: db}
+}
+
+func (r *ReviewCommentRepo) Insert(ctx context.Context, comment *ReviewComment) (*ReviewComment, error) {
+	_, err := r.db.NewInsert().Model(comment).Exec(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return comment, nil
+}
+
+func (r *ReviewCommentRepo) Get(ctx context.Context, params *GetReviewCommentParams) (*ReviewComment, error) {
+	comment := &ReviewComment{}
+	query := r.db.NewSelect().Model(comment)
+	if params.id != uuid.Nil {
+		query = query.Where("id = ?", params.id)
+	}
+	if params.mergeRequestID != uuid.Nil {
+		query = query.Where("merge_request_id = ?", params.mergeRequestID)
+	}
+	err := query.Limit(1).Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return comment, nil
+}
+
+func (r *ReviewCommentRepo) List(ctx context.Context, params *ListReviewCommentParams) ([]*ReviewComment, error) {
+	var roots []*ReviewComment
+	query := r.db.NewSelect().Model(&roots).Where("parent_id IS NULL")
+	if params.mergeRequestID != uuid.Nil {
+		query = query.Where("merge_request_id = ?", params.mergeRequestID)
+	}
+	if params.path != nil {
+		query = query.Where("path = ?", *params.path)
+	}
+	if params.resolved != nil {
+		query = query.Where("resolved = ?", *params.resolved)
+	}
+	err := query.Order("created_at ASC").Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	if len(roots) == 0 {
+		return roots, nil
+	}
+
+	rootIDs := make([]uuid.UUID, len(roots))
+	for i, root := range roots {
+		rootIDs[i] = root.ID
+	}
+	var replies []*ReviewComment
+	err = r.db.NewSelect().Model(&replies).
+		Where("parent_id IN (?)", bun.In(rootIDs)).
+		Order("created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	repliesOf := make(map[uuid.UUID][]*ReviewComment)
+	for _, reply := range replies {
+		repliesOf[reply.ParentID] = append(repliesOf[reply.ParentID], reply)
+	}
+
+	comments := make([]*ReviewComment, 0, len(roots)+len(replies))
+	for _, root := range roots {
+		comments = append(comments, root)
+		comments = append(comments, repliesOf[root.ID]...)
+	}
+	return comments, nil
+}
+
+func (r *ReviewCommentRepo) Update(ctx context.Context, params *UpdateReviewCommentParams) error {
+	query := r.db.NewUpdate().Model((*ReviewComment)(nil)).Where("id = ?", params.id)
+	if params.body != nil {
+		query = query.Set("body = ?", *params.body)
+	}
+	if params.resolved != nil {
+		query = query.Set("resolved = ?", *params.resolved)
+		if *params.resolved {
+			query = query.Set("resolved_by = ?", params.resolvedBy)
+		} else {
+			query = query.Set("resolved_by = NULL")
+		}
+	}
+	_, err := query.Set("updated_at = ?", time.Now()).Exec(ctx)
+	return toModelError(err)
+}
+
+func (r *ReviewCommentRepo) Delete(ctx context.Context, params *DeleteReviewCommentParams) (int64, error) {
+	var commentIDs []uuid.UUID
+	query := r.db.NewDelete().Model((*ReviewComment)(nil))
+	if params.id != uuid.Nil {
+		query = query.Where("id = ?", params.id)
+	}
+	if params.mergeRequestID != uuid.Nil {
+		query = query.Where("merge_request_id = ?", params.mergeRequestID)
+	}
+	err := query.Returning("id").Scan(ctx, &commentIDs)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+	if len(commentIDs) == 0 {
+		return 0, nil
+	}
+
+	res, err := r.db.NewDelete().Model((*ReviewComment)(nil)).Where("parent_id IN (?)", bun.In(commentIDs)).Exec(ctx)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+	replies, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+	return int64(len(commentIDs)) + replies, nil
+}
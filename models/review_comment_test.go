@@ -0,0 +1,97 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReviewCommentRepo(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewReviewCommentRepo(db)
+	mergeRequestID := uuid.New()
+	now := time.Now()
+	newComment := func(parentID uuid.UUID, path string, offset time.Duration) *models.ReviewComment {
+		comment, err := repo.Insert(ctx, &models.ReviewComment{
+			RepositoryID:   uuid.New(),
+			MergeRequestID: mergeRequestID,
+			ParentID:       parentID,
+			AuthorID:       uuid.New(),
+			Body:           "looks good",
+			Path:           path,
+			StartLine:      1,
+			EndLine:        2,
+			CreatedAt:      now.Add(offset),
+			UpdatedAt:      now.Add(offset),
+		})
+		require.NoError(t, err)
+		return comment
+	}
+
+	first := newComment(uuid.Nil, "a.csv", 0)
+	second := newComment(uuid.Nil, "b.csv", time.Second)
+	reply := newComment(first.ID, "", 2*time.Second)
+
+	t.Run("get", func(t *testing.T) {
+		comment, err := repo.Get(ctx, models.NewGetReviewCommentParams().SetID(reply.ID).SetMergeRequestID(mergeRequestID))
+		require.NoError(t, err)
+		require.Equal(t, first.ID, comment.ParentID)
+		require.False(t, comment.IsRoot())
+
+		_, err = repo.Get(ctx, models.NewGetReviewCommentParams().SetID(first.ID).SetMergeRequestID(uuid.New()))
+		require.ErrorIs(t, err, models.ErrNotFound)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		comments, err := repo.List(ctx, models.NewListReviewCommentParams().SetMergeRequestID(mergeRequestID))
+		require.NoError(t, err)
+		require.Len(t, comments, 3)
+		require.Equal(t, []uuid.UUID{first.ID, reply.ID, second.ID}, []uuid.UUID{comments[0].ID, comments[1].ID, comments[2].ID})
+
+		comments, err = repo.List(ctx, models.NewListReviewCommentParams().SetMergeRequestID(mergeRequestID).SetPath("b.csv"))
+		require.NoError(t, err)
+		require.Len(t, comments, 1)
+		require.Equal(t, second.ID, comments[0].ID)
+	})
+
+	t.Run("resolve", func(t *testing.T) {
+		userID := uuid.New()
+		err := repo.Update(ctx, models.NewUpdateReviewCommentParams(first.ID).SetBody("fixed").SetResolved(true, userID))
+		require.NoError(t, err)
+		comment, err := repo.Get(ctx, models.NewGetReviewCommentParams().SetID(first.ID))
+		require.NoError(t, err)
+		require.Equal(t, "fixed", comment.Body)
+		require.True(t, comment.Resolved)
+		require.Equal(t, userID, comment.ResolvedBy)
+
+		comments, err := repo.List(ctx, models.NewListReviewCommentParams().SetMergeRequestID(mergeRequestID).SetResolved(false))
+		require.NoError(t, err)
+		require.Len(t, comments, 1)
+		require.Equal(t, second.ID, comments[0].ID)
+
+		err = repo.Update(ctx, models.NewUpdateReviewCommentParams(first.ID).SetResolved(false, userID))
+		require.NoError(t, err)
+		comment, err = repo.Get(ctx, models.NewGetReviewCommentParams().SetID(first.ID))
+		require.NoError(t, err)
+		require.False(t, comment.Resolved)
+		require.Equal(t, uuid.Nil, comment.ResolvedBy)
+	})
+
+	t.Run("delete thread", func(t *testing.T) {
+		deleted, err := repo.Delete(ctx, models.NewDeleteReviewCommentParams().SetID(first.ID).SetMergeRequestID(mergeRequestID))
+		require.NoError(t, err)
+		require.Equal(t, int64(2), deleted)
+
+		comments, err := repo.List(ctx, models.NewListReviewCommentParams().SetMergeRequestID(mergeRequestID))
+		require.NoError(t, err)
+		require.Len(t, comments, 1)
+	})
+}
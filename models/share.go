@@ -0,0 +1,347 @@
+package models
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// ShareAction what a share token holder did
+type ShareAction string
+
+const (
+	ShareBrowseAction   ShareAction = "browse"
+	ShareDownloadAction ShareAction = "download"
+	ShareArchiveAction  ShareAction = "archive"
+)
+
+// Share grant read access of a whole ref to anyone holding the token until it expired
+type Share struct {
+	bun.BaseModel `bun:"table:shares"`
+	ID            uuid.UUID `bun:"id,pk,type:uuid,default:uuid_generate_v4()" json:"id"`
+	RepositoryID  uuid.UUID `bun:"repository_id,type:uuid,notnull" json:"repository_id"`
+	// RefType branch or tag
+	RefType string `bun:"ref_type,notnull" json:"ref_type"`
+	RefName string `bun:"ref_name,notnull" json:"ref_name"`
+	// TokenHash sha256 of secret used by receiver to access shared ref, the token itself is only shown once on creation
+	TokenHash   string    `bun:"token_hash,unique,notnull" json:"-"`
+	Description *string   `bun:"description" json:"description,omitempty"`
+	CreatorID   uuid.UUID `bun:"creator_id,type:uuid,notnull" json:"creator_id"`
+	ExpiredAt   time.Time `bun:"expired_at,type:timestamp,notnull" json:"expired_at"`
+
+	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+	UpdatedAt time.Time `bun:"updated_at,type:timestamp,notnull" json:"updated_at"`
+}
+
+// IsExpired check whether share can not be used anymore
+func (share *Share) IsExpired(now time.Time) bool {
+	return !now.Before(share.ExpiredAt)
+}
+
+// ShareAccessLog record every access through share token
+type ShareAccessLog struct {
+	bun.BaseModel `bun:"table:share_access_logs"`
+	ID            uuid.UUID   `bun:"id,pk,type:uuid,default:uuid_generate_v4()" json:"id"`
+	ShareID       uuid.UUID   `bun:"share_id,type:uuid,notnull" json:"share_id"`
+	Action        ShareAction `bun:"action,notnull" json:"action"`
+	Path          string      `bun:"path" json:"path"`
+	RemoteAddr    string      `bun:"remote_addr" json:"remote_addr"`
+	UserAgent     string      `bun:"user_agent" json:"user_agent"`
+
+	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+}
+
+// GenerateShareToken create a random token for share
+func GenerateShareToken() (string, error) {
+	tokenBytes, err := io.ReadAll(io.LimitReader(rand.Reader, 32))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(tokenBytes), nil
+}
+
+// HashShareToken hash stored for share token, shares are looked up by it so a leaked table does not leak usable links
+func HashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+type GetShareParams struct {
+	id           uuid.UUID
+	repositoryID uuid.UUID
+	tokenHash    *string
+}
+
+func NewGetShareParams() *GetShareParams {
+	return &GetShareParams{}
+}
+
+func (gsp *GetShareParams) SetID(id uuid.UUID) *GetShareParams {
+	gsp.id = id
+	return gsp
+}
+
+func (gsp *GetShareParams) SetRepositoryID(repositoryID uuid.UUID) *GetShareParams {
+	gsp.repositoryID = repositoryID
+	return gsp
+}
+
+// SetToken find share by hash of token carried by receiver
+func (gsp *GetShareParams) SetToken(token string) *GetShareParams {
+	tokenHash := HashShareToken(token)
+	gsp.tokenHash = &tokenHash
+	return gsp
+}
+
+type ListShareParams struct {
+	repositoryID uuid.UUID
+	refName      *string
+	refNameMatch MatchMode
+	after        *time.Time
+	afterID      uuid.UUID
+	amount       int
+}
+
+func NewListShareParams() *ListShareParams {
+	return &ListShareParams{}
+}
+
+func (lsp *ListShareParams) SetRepositoryID(repositoryID uuid.UUID) *ListShareParams {
+	lsp.repositoryID = repositoryID
+	return lsp
+}
+
+// SetRefName list shares of refs whose name matched with refName
+func (lsp *ListShareParams) SetRefName(refName string, match MatchMode) *ListShareParams {
+	lsp.refName = &refName
+	lsp.refNameMatch = match
+	return lsp
+}
+
+func (lsp *ListShareParams) SetAfter(after time.Time) *ListShareParams {
+	lsp.after = &after
+	return lsp
+}
+
+// SetAfterID id of the last share in previous page, shares created at the same time are ordered by id
+func (lsp *ListShareParams) SetAfterID(id uuid.UUID) *ListShareParams {
+	lsp.afterID = id
+	return lsp
+}
+
+func (lsp *ListShareParams) cursor() interface{} {
+	if lsp.after != nil {
+		return *lsp.after
+	}
+	return nil
+}
+
+// SetAmount limit number of shares returned, all shares are returned if not set
+func (lsp *ListShareParams) SetAmount(amount int) *ListShareParams {
+	lsp.amount = amount
+	return lsp
+}
+
+type DeleteShareParams struct {
+	id           uuid.UUID
+	repositoryID uuid.UUID
+}
+
+func NewDeleteShareParams() *DeleteShareParams {
+	return &DeleteShareParams{}
+}
+
+func (dsp *DeleteShareParams) SetID(id uuid.UUID) *DeleteShareParams {
+	dsp.id = id
+	return dsp
+}
+
+func (dsp *DeleteShareParams) SetRepositoryID(repositoryID uuid.UUID) *DeleteShareParams {
+	dsp.repositoryID = repositoryID
+	return dsp
+}
+
+type ListShareAccessLogParams struct {
+	shareID uuid.UUID
+	after   *time.Time
+	afterID uuid.UUID
+	amount  int
+}
+
+func NewListShareAccessLogParams() *ListShareAccessLogParams {
+	return &ListShareAccessLogParams{}
+}
+
+func (lsp *ListShareAccessLogParams) SetShareID(shareID uuid.UUID) *ListShareAccessLogParams {
+	lsp.shareID = shareID
+	return lsp
+}
+
+func (lsp *ListShareAccessLogParams) SetAfter(after time.Time) *ListShareAccessLogParams {
+	lsp.after = &after
+	return lsp
+}
+
+// SetAfterID id of the last access log in previous page, logs created at the same time are ordered by id
+func (lsp *ListShareAccessLogParams) SetAfterID(id uuid.UUID) *ListShareAccessLogParams {
+	lsp.afterID = id
+	return lsp
+}
+
+func (lsp *ListShareAccessLogParams) cursor() interface{} {
+	if lsp.after != nil {
+		return *lsp.after
+	}
+	return nil
+}
+
+func (lsp *ListShareAccessLogParams) SetAmount(amount int) *ListShareAccessLogParams {
+	lsp.amount = amount
+	return lsp
+}
+
+type IShareRepo interface {
+	Insert(ctx context.Context, share *Share) (*Share, error)
+	Get(ctx context.Context, params *GetShareParams) (*Share, error)
+	List(ctx context.Context, params *ListShareParams) ([]*Share, bool, error)
+	// Delete remove share and its access logs
+	Delete(ctx context.Context, params *DeleteShareParams) (int64, error)
+
+	InsertAccessLog(ctx context.Context, log *ShareAccessLog) (*ShareAccessLog, error)
+	ListAccessLog(ctx context.Context, params *ListShareAccessLogParams) ([]*ShareAccessLog, bool, error)
+}
+
+var _ IShareRepo = (*ShareRepo)(nil)
+
+type ShareRepo struct {
+	db bun.IDB
+}
+
+func NewShareRepo(db bun.IDB) IShareRepo {
+	return &ShareRepo{db: db}
+}
+
+func (s *ShareRepo) Insert(ctx context.Context, share *Share) (*Share, error) {
+	_, err := s.db.NewInsert().Model(share).Exec(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return share, nil
+}
+
+func (s *ShareRepo) Get(ctx context.Context, params *GetShareParams) (*Share, error) {
+	share := &Share{}
+	query := s.db.NewSelect().Model(share)
+
+	if uuid.Nil != params.id {
+		query = query.Where("id = ?", params.id)
+	}
+
+	if uuid.Nil != params.repositoryID {
+		query = query.Where("repository_id = ?", params.repositoryID)
+	}
+
+	if params.tokenHash != nil {
+		query = query.Where("token_hash = ?", *params.tokenHash)
+	}
+
+	err := query.Limit(1).Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return share, nil
+}
+
+func (s *ShareRepo) List(ctx context.Context, params *ListShareParams) ([]*Share, bool, error) {
+	var shares []*Share
+	sort := ListSort{Field: SortByCreatedAt, Direction: SortDesc}
+	cursor, err := sort.exactCursor(ctx, s.listQuery(&[]*Share{}, params), "", sort, params.cursor(), params.afterID)
+	if err != nil {
+		return nil, false, toModelError(err)
+	}
+	query := sort.apply(s.listQuery(&shares, params), "", sort, cursor, params.afterID)
+
+	err = query.Limit(params.amount).Scan(ctx)
+	if err != nil {
+		return nil, false, toModelError(err)
+	}
+	return shares, params.amount > 0 && len(shares) == params.amount, nil
+}
+
+func (s *ShareRepo) listQuery(shares *[]*Share, params *ListShareParams) *bun.SelectQuery {
+	query := s.db.NewSelect().Model(shares)
+
+	if uuid.Nil != params.repositoryID {
+		query = query.Where("repository_id = ?", params.repositoryID)
+	}
+
+	if params.refName != nil {
+		query = params.refNameMatch.apply(query, "ref_name", *params.refName)
+	}
+	return query
+}
+
+func (s *ShareRepo) Delete(ctx context.Context, params *DeleteShareParams) (int64, error) {
+	var shareIDs []uuid.UUID
+	query := s.db.NewDelete().Model((*Share)(nil))
+
+	if uuid.Nil != params.id {
+		query = query.Where("id = ?", params.id)
+	}
+
+	if uuid.Nil != params.repositoryID {
+		query = query.Where("repository_id = ?", params.repositoryID)
+	}
+
+	err := query.Returning("id").Scan(ctx, &shareIDs)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+
+	if len(shareIDs) > 0 {
+		_, err = s.db.NewDelete().Model((*ShareAccessLog)(nil)).Where("share_id IN (?)", bun.In(shareIDs)).Exec(ctx)
+		if err != nil {
+			return 0, toModelError(err)
+		}
+	}
+	return int64(len(shareIDs)), nil
+}
+
+func (s *ShareRepo) InsertAccessLog(ctx context.Context, log *ShareAccessLog) (*ShareAccessLog, error) {
+	_, err := s.db.NewInsert().Model(log).Exec(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return log, nil
+}
+
+func (s *ShareRepo) ListAccessLog(ctx context.Context, params *ListShareAccessLogParams) ([]*ShareAccessLog, bool, error) {
+	var logs []*ShareAccessLog
+	sort := ListSort{Field: SortByCreatedAt, Direction: SortDesc}
+	cursor, err := sort.exactCursor(ctx, s.accessLogQuery(&[]*ShareAccessLog{}, params), "", sort, params.cursor(), params.afterID)
+	if err != nil {
+		return nil, false, toModelError(err)
+	}
+	query := sort.apply(s.accessLogQuery(&logs, params), "", sort, cursor, params.afterID)
+
+	err = query.Limit(params.amount).Scan(ctx)
+	if err != nil {
+		return nil, false, toModelError(err)
+	}
+	return logs, params.amount > 0 && len(logs) == params.amount, nil
+}
+
+func (s *ShareRepo) accessLogQuery(logs *[]*ShareAccessLog, params *ListShareAccessLogParams) *bun.SelectQuery {
+	query := s.db.NewSelect().Model(logs)
+
+	if uuid.Nil != params.shareID {
+		query = query.Where("share_id = ?", params.shareID)
+	}
+	return query
+}
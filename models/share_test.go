@@ -0,0 +1,125 @@
+package models_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/google/go-cmp/cmp"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShareRepo(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewShareRepo(db)
+	repositoryID := uuid.New()
+
+	shareModel := &models.Share{}
+	require.NoError(t, gofakeit.Struct(shareModel))
+	shareModel.RepositoryID = repositoryID
+	token, err := models.GenerateShareToken()
+	require.NoError(t, err)
+	shareModel.TokenHash = models.HashShareToken(token)
+	share, err := repo.Insert(ctx, shareModel)
+	require.NoError(t, err)
+
+	t.Run("get", func(t *testing.T) {
+		byToken, err := repo.Get(ctx, models.NewGetShareParams().SetToken(token))
+		require.NoError(t, err)
+		require.True(t, cmp.Equal(share, byToken, testhelper.DBTimeCmpOpt))
+
+		// stored hash is not a usable token
+		_, err = repo.Get(ctx, models.NewGetShareParams().SetToken(share.TokenHash))
+		require.ErrorIs(t, err, models.ErrNotFound)
+
+		_, err = repo.Get(ctx, models.NewGetShareParams().SetID(share.ID).SetRepositoryID(uuid.New()))
+		require.ErrorIs(t, err, models.ErrNotFound)
+	})
+
+	t.Run("list", func(t *testing.T) {
+		secModel := &models.Share{}
+		require.NoError(t, gofakeit.Struct(secModel))
+		secModel.RepositoryID = repositoryID
+		secModel.RefName = "release/v1"
+		_, err := repo.Insert(ctx, secModel)
+		require.NoError(t, err)
+
+		shares, hasMore, err := repo.List(ctx, models.NewListShareParams().SetRepositoryID(repositoryID))
+		require.NoError(t, err)
+		require.Len(t, shares, 2)
+		require.False(t, hasMore)
+
+		page, hasMore, err := repo.List(ctx, models.NewListShareParams().SetRepositoryID(repositoryID).SetAmount(1))
+		require.NoError(t, err)
+		require.True(t, hasMore)
+		require.Equal(t, shares[0].ID, page[0].ID)
+
+		page, _, err = repo.List(ctx, models.NewListShareParams().SetRepositoryID(repositoryID).SetAfter(time.UnixMilli(page[0].CreatedAt.UnixMilli())).SetAfterID(page[0].ID).SetAmount(1))
+		require.NoError(t, err)
+		require.Equal(t, shares[1].ID, page[0].ID)
+
+		releases, _, err := repo.List(ctx, models.NewListShareParams().SetRepositoryID(repositoryID).SetRefName("RELEASE/", models.IPrefixMatch))
+		require.NoError(t, err)
+		require.Len(t, releases, 1)
+		require.Equal(t, "release/v1", releases[0].RefName)
+	})
+
+	t.Run("access log", func(t *testing.T) {
+		// logs created at the same time are paged by id
+		createdAt := time.UnixMicro(time.Now().UnixMilli()*1000 + 123)
+		for i := 0; i < 3; i++ {
+			_, err := repo.InsertAccessLog(ctx, &models.ShareAccessLog{
+				ShareID:   share.ID,
+				Action:    models.ShareDownloadAction,
+				Path:      "a.txt",
+				CreatedAt: createdAt,
+			})
+			require.NoError(t, err)
+		}
+
+		logs, hasMore, err := repo.ListAccessLog(ctx, models.NewListShareAccessLogParams().SetShareID(share.ID).SetAmount(2))
+		require.NoError(t, err)
+		require.True(t, hasMore)
+		require.Len(t, logs, 2)
+
+		// api send cursor in milliseconds
+		nextLogs, hasMore, err := repo.ListAccessLog(ctx, models.NewListShareAccessLogParams().SetShareID(share.ID).SetAfter(time.UnixMilli(logs[1].CreatedAt.UnixMilli())).SetAfterID(logs[1].ID).SetAmount(2))
+		require.NoError(t, err)
+		require.False(t, hasMore)
+		require.Len(t, nextLogs, 1)
+		require.NotContains(t, []uuid.UUID{logs[0].ID, logs[1].ID}, nextLogs[0].ID)
+
+		allLogs, hasMore, err := repo.ListAccessLog(ctx, models.NewListShareAccessLogParams().SetShareID(share.ID))
+		require.NoError(t, err)
+		require.False(t, hasMore)
+		require.Len(t, allLogs, 3)
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		affectedRows, err := repo.Delete(ctx, models.NewDeleteShareParams().SetID(share.ID).SetRepositoryID(repositoryID))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), affectedRows)
+
+		_, err = repo.Get(ctx, models.NewGetShareParams().SetID(share.ID))
+		require.ErrorIs(t, err, models.ErrNotFound)
+
+		logs, hasMore, err := repo.ListAccessLog(ctx, models.NewListShareAccessLogParams().SetShareID(share.ID))
+		require.NoError(t, err)
+		require.Len(t, logs, 0)
+		require.False(t, hasMore)
+	})
+}
+
+func TestShareIsExpired(t *testing.T) {
+	now := time.Now()
+	share := &models.Share{ExpiredAt: now.Add(time.Minute)}
+	require.False(t, share.IsExpired(now))
+	require.True(t, share.IsExpired(now.Add(time.Minute)))
+}
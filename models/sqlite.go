@@ -0,0 +1,124 @@
+package models
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/uptrace/bun/dialect/sqlitedialect"
+	"github.com/uptrace/bun/schema"
+	"modernc.org/sqlite"
+)
+
+const sqliteDriverName = "sqlite"
+
+// sqliteUUIDv4 expression generating random uuid in sqlite, take place of uuid_generate_v4() of postgres
+const sqliteUUIDv4 = `(lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' || ` +
+	`substr(lower(hex(randomblob(2))), 2) || '-' || substr('89ab', abs(random()) % 4 + 1, 1) || ` +
+	`substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6))))`
+
+// sqliteColumnTypes postgres column types used in models and their sqlite replacements, values are still encoded the
+// same way, only type in table definition is changed
+var sqliteColumnTypes = map[string]string{
+	"uuid":    "TEXT",
+	"bytea":   "BLOB",
+	"bytea[]": "TEXT",
+	"jsonb":   "TEXT",
+}
+
+func init() {
+	// sqlite parse X REGEXP Y but leave function regexp(Y, X) to application
+	sqlite.MustRegisterDeterministicScalarFunction("regexp", 2, sqliteRegexp)
+}
+
+func sqliteRegexp(_ *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	pattern, ok := args[0].(string)
+	if !ok {
+		return nil, fmt.Errorf("regexp pattern must be text")
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+	switch value := args[1].(type) {
+	case nil:
+		return nil, nil
+	case string:
+		return re.MatchString(value), nil
+	case []byte:
+		return re.Match(value), nil
+	}
+	return re.MatchString(fmt.Sprint(args[1])), nil
+}
+
+// sqliteDialect sqlite dialect which understand postgres column types and defaults declared in models
+type sqliteDialect struct {
+	*sqlitedialect.Dialect
+	tables *schema.Tables
+}
+
+func newSqliteDialect() *sqliteDialect {
+	d := &sqliteDialect{Dialect: sqlitedialect.New()}
+	d.tables = schema.NewTables(d)
+	return d
+}
+
+func (d *sqliteDialect) Tables() *schema.Tables {
+	return d.tables
+}
+
+func (d *sqliteDialect) OnTable(table *schema.Table) {
+	d.Dialect.OnTable(table)
+	for _, field := range table.FieldMap {
+		if sqlType, ok := sqliteColumnTypes[strings.ToLower(field.UserSQLType)]; ok {
+			field.CreateTableSQLType = sqlType
+		}
+		if field.SQLDefault == "uuid_generate_v4()" {
+			field.SQLDefault = sqliteUUIDv4
+		}
+	}
+}
+
+// sqliteDefaultPragmas applied to every connection if connection string doesn't set them. writers wait for each other
+// instead of failing at once, and readers are not blocked by a writer in wal mode
+var sqliteDefaultPragmas = []string{
+	"busy_timeout(10000)",
+	"journal_mode(WAL)",
+	"foreign_keys(1)",
+}
+
+// sqliteTxLock transactions take write lock when begin, a read transaction upgraded to write fails at once if another
+// writer holds the lock
+const sqliteTxLock = "_txlock=immediate"
+
+func isSqliteMemory(connection string) bool {
+	return strings.Contains(connection, ":memory:") || strings.Contains(connection, "mode=memory")
+}
+
+// sqliteDSN append default pragmas and transaction lock to connection string, connection is a file path or uri of file
+func sqliteDSN(connection string) string {
+	var pragmas []string
+	for _, pragma := range sqliteDefaultPragmas {
+		name, _, _ := strings.Cut(pragma, "(")
+		if strings.Contains(connection, "_pragma="+name) {
+			continue
+		}
+		if name == "journal_mode" && isSqliteMemory(connection) {
+			continue
+		}
+		pragmas = append(pragmas, "_pragma="+pragma)
+	}
+	if !strings.Contains(connection, "_txlock=") {
+		pragmas = append(pragmas, sqliteTxLock)
+	}
+	if len(pragmas) == 0 {
+		return connection
+	}
+
+	sep := "?"
+	if strings.Contains(connection, "?") {
+		sep = "&"
+	}
+	return connection + sep + strings.Join(pragmas, "&")
+}
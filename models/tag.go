@@ -9,6 +9,26 @@ import (
 	"github.com/uptrace/bun"
 )
 
+// TagType distinguish lightweight tag and annotated tag
+type TagType int8
+
+const (
+	// LightweightTag only a name point to commit
+	LightweightTag TagType = iota
+	// AnnotatedTag tag with tagger and message
+	AnnotatedTag
+)
+
+func (t TagType) String() string {
+	switch t {
+	case LightweightTag:
+		return "lightweight"
+	case AnnotatedTag:
+		return "annotated"
+	}
+	return "unknown"
+}
+
 type Tag struct {
 	bun.BaseModel `bun:"table:tags"`
 	ID            uuid.UUID `bun:"id,pk,type:uuid,default:uuid_generate_v4()" json:"id"`
@@ -22,6 +42,8 @@ type Tag struct {
 	Target hash.Hash `bun:"target,type:bytea" json:"target"`
 	// Message is the tag message, contains arbitrary text.
 	Message *string `bun:"message" json:"message"`
+	// Type is lightweight or annotated, only annotated tag has message.
+	Type TagType `bun:"type,notnull,default:0" json:"type"`
 
 	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
 	UpdatedAt time.Time `bun:"updated_at,type:timestamp,notnull" json:"updated_at"`
@@ -55,6 +77,7 @@ func (gup *GetTagParams) SetName(name string) *GetTagParams {
 type DeleteTagParams struct {
 	id           uuid.UUID
 	repositoryID uuid.UUID
+	name         *string
 }
 
 func NewDeleteTagParams() *DeleteTagParams {
@@ -70,11 +93,34 @@ func (gup *DeleteTagParams) SetID(id uuid.UUID) *DeleteTagParams {
 	return gup
 }
 
+func (gup *DeleteTagParams) SetName(name string) *DeleteTagParams {
+	gup.name = &name
+	return gup
+}
+
+type UpdateTagParams struct {
+	id     uuid.UUID
+	target hash.Hash
+}
+
+func NewUpdateTagParams(id uuid.UUID) *UpdateTagParams {
+	return &UpdateTagParams{id: id}
+}
+
+func (up *UpdateTagParams) SetTarget(target hash.Hash) *UpdateTagParams {
+	up.target = target
+	return up
+}
+
 type ListTagParams struct {
 	RepositoryID uuid.UUID
 	Name         *string
 	NameMatch    MatchMode
+	Type         *TagType
+	Sort         ListSort
 	After        *time.Time
+	AfterName    *string
+	AfterID      uuid.UUID
 	Amount       int
 }
 
@@ -93,11 +139,48 @@ func (gup *ListTagParams) SetName(name string, match MatchMode) *ListTagParams {
 	return gup
 }
 
+func (gup *ListTagParams) SetType(tagType TagType) *ListTagParams {
+	gup.Type = &tagType
+	return gup
+}
+
+// SetSort order tags by field, default is updated_at from new to old
+func (gup *ListTagParams) SetSort(field SortField, direction SortDirection) *ListTagParams {
+	gup.Sort = ListSort{Field: field, Direction: direction}
+	return gup
+}
+
+// SetAfter list tags after this time, used when sorted by time
 func (gup *ListTagParams) SetAfter(after time.Time) *ListTagParams {
 	gup.After = &after
 	return gup
 }
 
+// SetAfterName list tags after this name, used when sorted by name
+func (gup *ListTagParams) SetAfterName(name string) *ListTagParams {
+	gup.AfterName = &name
+	return gup
+}
+
+// SetAfterID id of the last tag in previous page, tags with the same sort value are ordered by id
+func (gup *ListTagParams) SetAfterID(id uuid.UUID) *ListTagParams {
+	gup.AfterID = id
+	return gup
+}
+
+func (gup *ListTagParams) cursor() interface{} {
+	if gup.Sort.Field == SortByName {
+		if gup.AfterName != nil {
+			return *gup.AfterName
+		}
+		return nil
+	}
+	if gup.After != nil {
+		return *gup.After
+	}
+	return nil
+}
+
 func (gup *ListTagParams) SetAmount(amount int) *ListTagParams {
 	gup.Amount = amount
 	return gup
@@ -108,6 +191,9 @@ type ITagRepo interface {
 	Get(ctx context.Context, params *GetTagParams) (*Tag, error)
 	Delete(ctx context.Context, params *DeleteTagParams) (int64, error)
 	List(ctx context.Context, params *ListTagParams) ([]*Tag, bool, error)
+	// Count number of tags matching params, pagination of params is ignored
+	Count(ctx context.Context, params *ListTagParams) (int, error)
+	UpdateByID(ctx context.Context, params *UpdateTagParams) error
 }
 
 type TagRepo struct {
@@ -122,7 +208,7 @@ func (t *TagRepo) Insert(ctx context.Context, tag *Tag) (*Tag, error) {
 		Model(tag).
 		Exec(ctx)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
 	}
 	return tag, nil
 }
@@ -145,39 +231,44 @@ func (t *TagRepo) Get(ctx context.Context, params *GetTagParams) (*Tag, error) {
 
 	err := query.Limit(1).Scan(ctx)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
 	}
 	return tag, nil
 }
 
 func (t *TagRepo) List(ctx context.Context, params *ListTagParams) ([]*Tag, bool, error) {
 	var tags []*Tag
-	query := t.db.NewSelect().Model(&tags)
+	defaultSort := ListSort{Field: SortByUpdatedAt, Direction: SortDesc}
+	cursor, err := params.Sort.exactCursor(ctx, t.listQuery(&[]*Tag{}, params), "", defaultSort, params.cursor(), params.AfterID)
+	if err != nil {
+		return nil, false, toModelError(err)
+	}
+	query := params.Sort.apply(t.listQuery(&tags, params), "", defaultSort, cursor, params.AfterID)
+
+	err = query.Limit(params.Amount).Scan(ctx)
+	return tags, len(tags) == params.Amount, toModelError(err)
+}
+
+func (t *TagRepo) Count(ctx context.Context, params *ListTagParams) (int, error) {
+	var tags []*Tag
+	return t.listQuery(&tags, params).Count(ctx)
+}
+
+func (t *TagRepo) listQuery(tags *[]*Tag, params *ListTagParams) *bun.SelectQuery {
+	query := t.db.NewSelect().Model(tags)
 
 	if uuid.Nil != params.RepositoryID {
 		query = query.Where("repository_id = ?", params.RepositoryID)
 	}
 
 	if params.Name != nil {
-		switch params.NameMatch {
-		case ExactMatch:
-			query = query.Where("name = ?", *params.Name)
-		case PrefixMatch:
-			query = query.Where("name LIKE ?", *params.Name+"%")
-		case SuffixMatch:
-			query = query.Where("name LIKE ?", "%"+*params.Name)
-		case LikeMatch:
-			query = query.Where("name LIKE ?", "%"+*params.Name+"%")
-		}
+		query = params.NameMatch.apply(query, "name", *params.Name)
 	}
 
-	query = query.Order("updated_at DESC")
-	if params.After != nil {
-		query = query.Where("updated_at > ?", *params.After)
+	if params.Type != nil {
+		query = query.Where("type = ?", *params.Type)
 	}
-
-	err := query.Limit(params.Amount).Scan(ctx)
-	return tags, len(tags) == params.Amount, err
+	return query
 }
 
 func (t *TagRepo) Delete(ctx context.Context, params *DeleteTagParams) (int64, error) {
@@ -191,9 +282,13 @@ func (t *TagRepo) Delete(ctx context.Context, params *DeleteTagParams) (int64, e
 		query = query.Where("repository_id = ?", params.repositoryID)
 	}
 
+	if params.name != nil {
+		query = query.Where("name = ?", *params.name)
+	}
+
 	sqlResult, err := query.Exec(ctx)
 	if err != nil {
-		return 0, err
+		return 0, toModelError(err)
 	}
 	affectedRows, err := sqlResult.RowsAffected()
 	if err != nil {
@@ -201,3 +296,12 @@ func (t *TagRepo) Delete(ctx context.Context, params *DeleteTagParams) (int64, e
 	}
 	return affectedRows, err
 }
+
+func (t *TagRepo) UpdateByID(ctx context.Context, params *UpdateTagParams) error {
+	updateQuery := t.db.NewUpdate().Model((*Tag)(nil)).Where("id = ?", params.id)
+	if params.target != nil {
+		updateQuery.Set("target = ?", params.target)
+	}
+	_, err := updateQuery.Exec(ctx)
+	return toModelError(err)
+}
@@ -7,6 +7,7 @@ import (
 
 	"github.com/GitDataAI/jiaozifs/models"
 	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
 	"github.com/brianvoe/gofakeit/v6"
 	"github.com/google/go-cmp/cmp"
 	"github.com/google/uuid"
@@ -23,6 +24,7 @@ func TestTagRepoInsert(t *testing.T) {
 	tagModel := &models.Tag{}
 	require.NoError(t, gofakeit.Struct(tagModel))
 	tagModel.Name = "atagName"
+	tagModel.Type = models.LightweightTag
 	tagModel.UpdatedAt = time.Now()
 	newTag, err := repo.Insert(ctx, tagModel)
 	require.NoError(t, err)
@@ -46,6 +48,7 @@ func TestTagRepoInsert(t *testing.T) {
 	require.NoError(t, gofakeit.Struct(secModel))
 	secModel.RepositoryID = branch.RepositoryID
 	secModel.Name = "feat_bba_ccc"
+	secModel.Type = models.AnnotatedTag
 	secModel.UpdatedAt = time.Now()
 	secRef, err := repo.Insert(ctx, secModel)
 	require.NoError(t, err)
@@ -66,6 +69,16 @@ func TestTagRepoInsert(t *testing.T) {
 	require.Len(t, list1, 1)
 	require.True(t, hasMore)
 
+	// Count ignore pagination
+	count, err := repo.Count(ctx, models.NewListTagParams().SetRepositoryID(branch.RepositoryID).SetName(secModel.Name, models.ExactMatch).SetAmount(1))
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	all, _, err := repo.List(ctx, models.NewListTagParams().SetRepositoryID(branch.RepositoryID))
+	require.NoError(t, err)
+	count, err = repo.Count(ctx, models.NewListTagParams().SetRepositoryID(branch.RepositoryID).SetAmount(1))
+	require.NoError(t, err)
+	require.Equal(t, len(all), count)
+
 	// PrefixMatch
 	list2, hasMore, err := repo.List(ctx, models.NewListTagParams().SetRepositoryID(branch.RepositoryID).SetName(secModel.Name[:3], models.PrefixMatch).SetAmount(1))
 	require.NoError(t, err)
@@ -84,12 +97,37 @@ func TestTagRepoInsert(t *testing.T) {
 	require.Len(t, list4, 1)
 	require.True(t, hasMore)
 
-	// After
-	list5, hasMore, err := repo.List(ctx, models.NewListTagParams().SetRepositoryID(branch.RepositoryID).SetAfter(tagModel.UpdatedAt))
+	// After, tags are listed from new to old
+	list5, hasMore, err := repo.List(ctx, models.NewListTagParams().SetRepositoryID(branch.RepositoryID).SetAfter(secModel.UpdatedAt))
 	require.NoError(t, err)
 	require.Len(t, list5, 1)
+	require.Equal(t, tagModel.Name, list5[0].Name)
 	require.False(t, hasMore)
 
+	// Sort by name
+	list8, hasMore, err := repo.List(ctx, models.NewListTagParams().SetRepositoryID(branch.RepositoryID).SetSort(models.SortByName, models.SortAsc).SetAmount(1))
+	require.NoError(t, err)
+	require.True(t, hasMore)
+	require.Equal(t, tagModel.Name, list8[0].Name)
+	list8, _, err = repo.List(ctx, models.NewListTagParams().SetRepositoryID(branch.RepositoryID).SetSort(models.SortByName, models.SortAsc).SetAfterName(list8[0].Name))
+	require.NoError(t, err)
+	require.Len(t, list8, 1)
+	require.Equal(t, secModel.Name, list8[0].Name)
+
+	// Type
+	list7, _, err := repo.List(ctx, models.NewListTagParams().SetRepositoryID(branch.RepositoryID).SetType(models.AnnotatedTag))
+	require.NoError(t, err)
+	require.Len(t, list7, 1)
+	require.Equal(t, secModel.Name, list7[0].Name)
+
+	// update target
+	newTarget := hash.Hash("new target")
+	err = repo.UpdateByID(ctx, models.NewUpdateTagParams(secRef.ID).SetTarget(newTarget))
+	require.NoError(t, err)
+	sRef, err = repo.Get(ctx, getSecRefParams)
+	require.NoError(t, err)
+	require.Equal(t, newTarget, sRef.Target)
+
 	affectedRows, err := repo.Delete(ctx, models.NewDeleteTagParams().SetRepositoryID(list[0].RepositoryID).SetID(secModel.ID))
 	require.NoError(t, err)
 	require.Equal(t, int64(1), affectedRows)
@@ -97,4 +135,9 @@ func TestTagRepoInsert(t *testing.T) {
 	list6, _, err := repo.List(ctx, models.NewListTagParams().SetRepositoryID(branch.RepositoryID))
 	require.NoError(t, err)
 	require.Len(t, list6, 1)
+
+	// delete by name
+	affectedRows, err = repo.Delete(ctx, models.NewDeleteTagParams().SetRepositoryID(branch.RepositoryID).SetName(tagModel.Name))
+	require.NoError(t, err)
+	require.Equal(t, int64(1), affectedRows)
 }
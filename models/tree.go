@@ -76,8 +76,8 @@ func (props Property) ToMap() map[string]string {
 
 type Blob struct {
 	bun.BaseModel `bun:"table:trees"`
-	Hash          hash.Hash  `bun:"hash,pk,type:bytea"`
 	RepositoryID  uuid.UUID  `bun:"repository_id,pk,type:uuid,notnull"`
+	Hash          hash.Hash  `bun:"hash,pk,type:bytea"`
 	CheckSum      hash.Hash  `bun:"check_sum,type:bytea"`
 	Type          ObjectType `bun:"type,notnull"`
 	Size          int64      `bun:"size"`
@@ -88,6 +88,11 @@ type Blob struct {
 }
 
 func NewBlob(props Property, repoID uuid.UUID, checkSum hash.Hash, size int64) (*Blob, error) {
+	return NewBlobWithHashType(hash.Md5, props, repoID, checkSum, size)
+}
+
+// NewBlobWithHashType create blob whose hash is calculated by hashType
+func NewBlobWithHashType(hashType hash.HashType, props Property, repoID uuid.UUID, checkSum hash.Hash, size int64) (*Blob, error) {
 	blob := &Blob{
 		CheckSum:     checkSum,
 		RepositoryID: repoID,
@@ -97,7 +102,7 @@ func NewBlob(props Property, repoID uuid.UUID, checkSum hash.Hash, size int64) (
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
-	hash, err := blob.calculateHash()
+	hash, err := blob.calculateHash(hashType)
 	if err != nil {
 		return nil, err
 	}
@@ -105,8 +110,8 @@ func NewBlob(props Property, repoID uuid.UUID, checkSum hash.Hash, size int64) (
 	return blob, err
 }
 
-func (blob *Blob) calculateHash() (hash.Hash, error) {
-	hasher := hash.NewHasher(hash.Md5)
+func (blob *Blob) calculateHash(hashType hash.HashType) (hash.Hash, error) {
+	hasher := hash.NewHasher(hashType)
 	err := hasher.WriteInt8(int8(blob.Type))
 	if err != nil {
 		return nil, err
@@ -129,7 +134,7 @@ func (blob *Blob) calculateHash() (hash.Hash, error) {
 			return nil, err
 		}
 	}
-	return hasher.Md5.Sum(nil), nil
+	return hasher.Sum(hashType), nil
 }
 
 func (blob *Blob) FileTree() *FileTree {
@@ -147,8 +152,8 @@ func (blob *Blob) FileTree() *FileTree {
 
 type TreeNode struct {
 	bun.BaseModel `bun:"table:trees"`
-	Hash          hash.Hash `bun:"hash,pk,type:bytea" json:"hash"`
 	RepositoryID  uuid.UUID `bun:"repository_id,pk,type:uuid,notnull" json:"repository_id"`
+	Hash          hash.Hash `bun:"hash,pk,type:bytea" json:"hash"`
 
 	Type       ObjectType  `bun:"type,notnull" json:"type"`
 	SubObjects []TreeEntry `bun:"sub_objects,type:jsonb" json:"sub_objects"`
@@ -159,6 +164,11 @@ type TreeNode struct {
 }
 
 func NewTreeNode(props Property, repoID uuid.UUID, subObjects ...TreeEntry) (*TreeNode, error) {
+	return NewTreeNodeWithHashType(hash.Md5, props, repoID, subObjects...)
+}
+
+// NewTreeNodeWithHashType create tree node whose hash is calculated by hashType
+func NewTreeNodeWithHashType(hashType hash.HashType, props Property, repoID uuid.UUID, subObjects ...TreeEntry) (*TreeNode, error) {
 	if subObjects == nil {
 		subObjects = make([]TreeEntry, 0) //to ensure tree entry not null
 	}
@@ -170,7 +180,7 @@ func NewTreeNode(props Property, repoID uuid.UUID, subObjects ...TreeEntry) (*Tr
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
-	hash, err := newTree.calculateHash()
+	hash, err := newTree.calculateHash(hashType)
 	if err != nil {
 		return nil, err
 	}
@@ -190,8 +200,8 @@ func (tn *TreeNode) FileTree() *FileTree {
 	}
 }
 
-func (tn *TreeNode) calculateHash() (hash.Hash, error) {
-	hasher := hash.NewHasher(hash.Md5)
+func (tn *TreeNode) calculateHash(hashType hash.HashType) (hash.Hash, error) {
+	hasher := hash.NewHasher(hashType)
 	err := hasher.WriteInt8(int8(tn.Type))
 	if err != nil {
 		return nil, err
@@ -218,13 +228,15 @@ func (tn *TreeNode) calculateHash() (hash.Hash, error) {
 		}
 	}
 
-	return hasher.Md5.Sum(nil), nil
+	return hasher.Sum(hashType), nil
 }
 
+// FileTree row of objects table, repository_id leads the primary key so objects of one repository are found, collected
+// and deleted by index without touching objects of others. table is partitioned by repository_id on postgres
 type FileTree struct {
 	bun.BaseModel `bun:"table:trees"`
-	Hash          hash.Hash  `bun:"hash,pk,type:bytea"`
 	RepositoryID  uuid.UUID  `bun:"repository_id,pk,type:uuid,notnull"`
+	Hash          hash.Hash  `bun:"hash,pk,type:bytea"`
 	CheckSum      hash.Hash  `bun:"check_sum,type:bytea"`
 	Type          ObjectType `bun:"type,notnull"`
 	Size          int64      `bun:"size"`
@@ -290,6 +302,11 @@ func (dtp *DeleteTreeParams) SetHash(hash hash.Hash) *DeleteTreeParams {
 type IFileTreeRepo interface {
 	RepositoryID() uuid.UUID
 	Insert(ctx context.Context, repo *FileTree) (*FileTree, error)
+	// BatchInsert insert objects in as few statements as possible, objects already exist are skipped. return number of
+	// objects inserted
+	BatchInsert(ctx context.Context, objs []FileTree) (int64, error)
+	// ExistsMany return hashes of objects not exist in repository
+	ExistsMany(ctx context.Context, hashes []hash.Hash) ([]hash.Hash, error)
 	Get(ctx context.Context, params *GetObjParams) (*FileTree, error)
 	Count(ctx context.Context) (int, error)
 	List(ctx context.Context) ([]FileTree, error)
@@ -320,13 +337,106 @@ func (o FileTreeRepo) Insert(ctx context.Context, obj *FileTree) (*FileTree, err
 	if obj.RepositoryID != o.repositoryID {
 		return nil, ErrRepoIDMisMatch
 	}
-	_, err := o.db.NewInsert().Model(obj).Ignore().Exec(ctx)
+	sqlResult, err := o.db.NewInsert().Model(obj).Ignore().Exec(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	affectedRows, err := sqlResult.RowsAffected()
 	if err != nil {
 		return nil, err
 	}
+	// object already exist is ignored, count new one only
+	if affectedRows > 0 {
+		var blobBytes int64
+		if obj.Type == BlobObject {
+			blobBytes = obj.Size
+		}
+		err = addRepoStats(ctx, o.db, o.repositoryID, blobBytes, 1, 0)
+		if err != nil {
+			return nil, err
+		}
+		if obj.Type == BlobObject {
+			err = addBlobRefs(ctx, o.db, blobRefDelta{obj.CheckSum.Hex(): {RepositoryID: o.repositoryID, CheckSum: obj.CheckSum, RefCount: 1}})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
 	return obj, nil
 }
 
+// objectBatchSize rows of one statement, keep size of statement bounded for large commits
+const objectBatchSize = 1000
+
+func (o FileTreeRepo) BatchInsert(ctx context.Context, objs []FileTree) (int64, error) {
+	for _, obj := range objs {
+		if obj.RepositoryID != o.repositoryID {
+			return 0, ErrRepoIDMisMatch
+		}
+	}
+
+	var blobBytes, affectedRows int64
+	refs := blobRefDelta{}
+	for start := 0; start < len(objs); start += objectBatchSize {
+		batch := objs[start:min(start+objectBatchSize, len(objs))]
+		var inserted []struct {
+			Type     ObjectType `bun:"type"`
+			Size     int64      `bun:"size"`
+			CheckSum []byte     `bun:"check_sum"`
+		}
+		// conflict rows are not returned, so only new objects are counted
+		_, err := o.db.NewInsert().Model(&batch).Ignore().Returning("type, size, check_sum").Exec(ctx, &inserted)
+		if err != nil {
+			return 0, toModelError(err)
+		}
+		for _, obj := range inserted {
+			if obj.Type == BlobObject {
+				blobBytes += obj.Size
+				refs.add(o.repositoryID, obj.CheckSum, 1)
+			}
+		}
+		affectedRows += int64(len(inserted))
+	}
+	err := addRepoStats(ctx, o.db, o.repositoryID, blobBytes, affectedRows, 0)
+	if err != nil {
+		return 0, err
+	}
+	return affectedRows, addBlobRefs(ctx, o.db, refs)
+}
+
+func (o FileTreeRepo) ExistsMany(ctx context.Context, hashes []hash.Hash) ([]hash.Hash, error) {
+	exists := make(map[string]struct{}, len(hashes))
+	for start := 0; start < len(hashes); start += objectBatchSize {
+		// bun expand element of named slice type as a nested list, pass raw bytes instead
+		batch := make([][]byte, 0, objectBatchSize)
+		for _, h := range hashes[start:min(start+objectBatchSize, len(hashes))] {
+			batch = append(batch, h)
+		}
+		var found [][]byte
+		err := o.db.NewSelect().Model((*FileTree)(nil)).
+			Column("hash").
+			Where("repository_id = ?", o.repositoryID).
+			Where("hash IN (?)", bun.In(batch)).
+			Scan(ctx, &found)
+		if err != nil {
+			return nil, toModelError(err)
+		}
+		for _, h := range found {
+			exists[hash.Hash(h).Hex()] = struct{}{}
+		}
+	}
+
+	var missing []hash.Hash
+	for _, h := range hashes {
+		if _, ok := exists[h.Hex()]; !ok {
+			missing = append(missing, h)
+			//report duplicate hash once
+			exists[h.Hex()] = struct{}{}
+		}
+	}
+	return missing, nil
+}
+
 func (o FileTreeRepo) Get(ctx context.Context, params *GetObjParams) (*FileTree, error) {
 	repo := &FileTree{}
 	query := o.db.NewSelect().Model(repo).Where("repository_id = ?", o.repositoryID)
@@ -337,7 +447,7 @@ func (o FileTreeRepo) Get(ctx context.Context, params *GetObjParams) (*FileTree,
 
 	err := query.Limit(1).Scan(ctx, repo)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
 	}
 	return repo, nil
 }
@@ -350,7 +460,7 @@ func (o FileTreeRepo) Blob(ctx context.Context, hash hash.Hash) (*Blob, error) {
 		Where("hash = ?", hash).
 		Scan(ctx)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
 	}
 	return blob, nil
 }
@@ -363,7 +473,7 @@ func (o FileTreeRepo) TreeNode(ctx context.Context, hash hash.Hash) (*TreeNode,
 		Where("hash = ?", hash).
 		Scan(ctx)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
 	}
 	return tree, nil
 }
@@ -381,7 +491,7 @@ func (o FileTreeRepo) List(ctx context.Context) ([]FileTree, error) {
 		Where("repository_id = ?", o.repositoryID).
 		Scan(ctx)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
 	}
 	return obj, nil
 }
@@ -392,13 +502,28 @@ func (o FileTreeRepo) Delete(ctx context.Context, params *DeleteTreeParams) (int
 		query = query.Where("hash = ?", params.hash)
 	}
 
-	sqlResult, err := query.Exec(ctx)
+	var deleted []struct {
+		Type     ObjectType `bun:"type"`
+		Size     int64      `bun:"size"`
+		CheckSum []byte     `bun:"check_sum"`
+	}
+	_, err := query.Returning("type, size, check_sum").Exec(ctx, &deleted)
 	if err != nil {
-		return 0, err
+		return 0, toModelError(err)
 	}
-	affectedRows, err := sqlResult.RowsAffected()
+
+	var blobBytes int64
+	refs := blobRefDelta{}
+	for _, obj := range deleted {
+		if obj.Type == BlobObject {
+			blobBytes += obj.Size
+			refs.add(o.repositoryID, obj.CheckSum, -1)
+		}
+	}
+	affectedRows := int64(len(deleted))
+	err = addRepoStats(ctx, o.db, o.repositoryID, -blobBytes, -affectedRows, 0)
 	if err != nil {
 		return 0, err
 	}
-	return affectedRows, err
+	return affectedRows, addBlobRefs(ctx, o.db, refs)
 }
@@ -75,6 +75,53 @@ func TestObjectRepo_Insert(t *testing.T) {
 	})
 }
 
+func TestObjectRepo_BatchInsert(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repoID := uuid.New()
+	repo := models.NewFileTree(db, repoID)
+
+	var objs []models.FileTree
+	var hashes []hash.Hash
+	for i := 0; i < 5; i++ {
+		objModel := models.FileTree{}
+		require.NoError(t, gofakeit.Struct(&objModel))
+		objModel.RepositoryID = repoID
+		objModel.Properties.Mode = filemode.Regular
+		objs = append(objs, objModel)
+		hashes = append(hashes, objModel.Hash)
+	}
+
+	_, err := repo.Insert(ctx, &objs[0])
+	require.NoError(t, err)
+
+	missing, err := repo.ExistsMany(ctx, append(hashes, hashes[1]))
+	require.NoError(t, err)
+	require.Len(t, missing, 4)
+	require.Equal(t, hashes[1], missing[0])
+
+	inserted, err := repo.BatchInsert(ctx, objs)
+	require.NoError(t, err)
+	require.Equal(t, int64(4), inserted)
+
+	missing, err = repo.ExistsMany(ctx, hashes)
+	require.NoError(t, err)
+	require.Len(t, missing, 0)
+
+	count, err := repo.Count(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 5, count)
+
+	t.Run("mis match repo id", func(t *testing.T) {
+		mistMatchModel := models.FileTree{}
+		require.NoError(t, gofakeit.Struct(&mistMatchModel))
+		_, err := repo.BatchInsert(ctx, []models.FileTree{mistMatchModel})
+		require.ErrorIs(t, err, models.ErrRepoIDMisMatch)
+	})
+}
+
 func TestNewTreeNode(t *testing.T) {
 	id, err := uuid.Parse("a91ef678-1980-4b26-9bb9-eadc9f366429")
 	require.NoError(t, err)
@@ -116,6 +163,14 @@ func TestFileTreeRepo_Delete(t *testing.T) {
 		treeModels = append(treeModels, newModel)
 	}
 
+	//same object in another repository
+	otherRepoID := uuid.New()
+	otherRepo := models.NewFileTree(db, otherRepoID)
+	otherModel := *treeModels[0]
+	otherModel.RepositoryID = otherRepoID
+	_, err := otherRepo.Insert(ctx, &otherModel)
+	require.NoError(t, err)
+
 	//delete one
 	affectRows, err := repo.Delete(ctx, models.NewDeleteTreeParams().SetHash(treeModels[0].Hash))
 	require.NoError(t, err)
@@ -125,4 +180,10 @@ func TestFileTreeRepo_Delete(t *testing.T) {
 	affectRows, err = repo.Delete(ctx, models.NewDeleteTreeParams())
 	require.NoError(t, err)
 	require.Equal(t, int64(4), affectRows)
+
+	count, err := otherRepo.Count(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, count)
+	_, err = otherRepo.Get(ctx, models.NewGetObjParams().SetHash(treeModels[0].Hash))
+	require.NoError(t, err)
 }
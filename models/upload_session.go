@@ -0,0 +1,254 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// UploadPart part uploaded to backend multipart upload
+type UploadPart struct {
+	PartNumber int    `json:"part_number"`
+	ETag       string `json:"etag"`
+	Size       int64  `json:"size"`
+}
+
+// UploadSession multipart upload in progress, parts are staged at Address of storage namespace until session completed
+// or aborted
+type UploadSession struct {
+	bun.BaseModel `bun:"table:upload_sessions"`
+	ID            uuid.UUID `bun:"id,pk,type:uuid,default:uuid_generate_v4()" json:"id"`
+	RepositoryID  uuid.UUID `bun:"repository_id,type:uuid,notnull" json:"repository_id"`
+	CreatorID     uuid.UUID `bun:"creator_id,type:uuid,notnull" json:"creator_id"`
+	RefName       string    `bun:"ref_name,notnull" json:"ref_name"`
+	Path          string    `bun:"path,notnull" json:"path"`
+	// UploadID identifier of multipart upload in backend storage
+	UploadID string `bun:"upload_id,notnull" json:"upload_id"`
+	// Address staging key of upload relative to storage namespace
+	Address string       `bun:"address,notnull" json:"address"`
+	Parts   []UploadPart `bun:"parts,type:jsonb" json:"parts"`
+	// LastActiveAt time of last part uploaded, session idle for long is aborted
+	LastActiveAt time.Time `bun:"last_active_at,type:timestamp,notnull" json:"last_active_at"`
+
+	CreatedAt time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+}
+
+type GetUploadSessionParams struct {
+	id           uuid.UUID
+	repositoryID uuid.UUID
+	creatorID    uuid.UUID
+}
+
+func NewGetUploadSessionParams() *GetUploadSessionParams {
+	return &GetUploadSessionParams{}
+}
+
+func (gup *GetUploadSessionParams) SetID(id uuid.UUID) *GetUploadSessionParams {
+	gup.id = id
+	return gup
+}
+
+func (gup *GetUploadSessionParams) SetRepositoryID(repositoryID uuid.UUID) *GetUploadSessionParams {
+	gup.repositoryID = repositoryID
+	return gup
+}
+
+func (gup *GetUploadSessionParams) SetCreatorID(creatorID uuid.UUID) *GetUploadSessionParams {
+	gup.creatorID = creatorID
+	return gup
+}
+
+type ListUploadSessionParams struct {
+	repositoryID uuid.UUID
+	creatorID    uuid.UUID
+	path         *string
+	pathMatch    MatchMode
+	idleBefore   *time.Time
+	after        *time.Time
+	amount       int
+}
+
+func NewListUploadSessionParams() *ListUploadSessionParams {
+	return &ListUploadSessionParams{}
+}
+
+func (lup *ListUploadSessionParams) SetRepositoryID(repositoryID uuid.UUID) *ListUploadSessionParams {
+	lup.repositoryID = repositoryID
+	return lup
+}
+
+func (lup *ListUploadSessionParams) SetCreatorID(creatorID uuid.UUID) *ListUploadSessionParams {
+	lup.creatorID = creatorID
+	return lup
+}
+
+// SetPath list sessions uploading to paths matched with path
+func (lup *ListUploadSessionParams) SetPath(path string, match MatchMode) *ListUploadSessionParams {
+	lup.path = &path
+	lup.pathMatch = match
+	return lup
+}
+
+// SetIdleBefore list sessions without activity since idleBefore
+func (lup *ListUploadSessionParams) SetIdleBefore(idleBefore time.Time) *ListUploadSessionParams {
+	lup.idleBefore = &idleBefore
+	return lup
+}
+
+func (lup *ListUploadSessionParams) SetAfter(after time.Time) *ListUploadSessionParams {
+	lup.after = &after
+	return lup
+}
+
+// SetAmount limit number of sessions returned, all sessions are returned if not set
+func (lup *ListUploadSessionParams) SetAmount(amount int) *ListUploadSessionParams {
+	lup.amount = amount
+	return lup
+}
+
+type DeleteUploadSessionParams struct {
+	id           uuid.UUID
+	repositoryID uuid.UUID
+}
+
+func NewDeleteUploadSessionParams() *DeleteUploadSessionParams {
+	return &DeleteUploadSessionParams{}
+}
+
+func (dup *DeleteUploadSessionParams) SetID(id uuid.UUID) *DeleteUploadSessionParams {
+	dup.id = id
+	return dup
+}
+
+func (dup *DeleteUploadSessionParams) SetRepositoryID(repositoryID uuid.UUID) *DeleteUploadSessionParams {
+	dup.repositoryID = repositoryID
+	return dup
+}
+
+type IUploadSessionRepo interface {
+	Insert(ctx context.Context, session *UploadSession) (*UploadSession, error)
+	Get(ctx context.Context, params *GetUploadSessionParams) (*UploadSession, error)
+	List(ctx context.Context, params *ListUploadSessionParams) ([]*UploadSession, bool, error)
+	// CountByCreator number of sessions in progress of user among all repositories
+	CountByCreator(ctx context.Context, creatorID uuid.UUID) (int, error)
+	// AddPart record uploaded part and refresh activity of session, part with the same number is replaced
+	AddPart(ctx context.Context, id uuid.UUID, part UploadPart) error
+	Delete(ctx context.Context, params *DeleteUploadSessionParams) (int64, error)
+}
+
+var _ IUploadSessionRepo = (*UploadSessionRepo)(nil)
+
+type UploadSessionRepo struct {
+	db bun.IDB
+}
+
+func NewUploadSessionRepo(db bun.IDB) IUploadSessionRepo {
+	return &UploadSessionRepo{db: db}
+}
+
+func (u *UploadSessionRepo) Insert(ctx context.Context, session *UploadSession) (*UploadSession, error) {
+	_, err := u.db.NewInsert().Model(session).Exec(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return session, nil
+}
+
+func (u *UploadSessionRepo) Get(ctx context.Context, params *GetUploadSessionParams) (*UploadSession, error) {
+	session := &UploadSession{}
+	query := u.db.NewSelect().Model(session)
+
+	if uuid.Nil != params.id {
+		query = query.Where("id = ?", params.id)
+	}
+
+	if uuid.Nil != params.repositoryID {
+		query = query.Where("repository_id = ?", params.repositoryID)
+	}
+
+	if uuid.Nil != params.creatorID {
+		query = query.Where("creator_id = ?", params.creatorID)
+	}
+
+	err := query.Limit(1).Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return session, nil
+}
+
+func (u *UploadSessionRepo) List(ctx context.Context, params *ListUploadSessionParams) ([]*UploadSession, bool, error) {
+	var sessions []*UploadSession
+	query := u.db.NewSelect().Model(&sessions)
+
+	if uuid.Nil != params.repositoryID {
+		query = query.Where("repository_id = ?", params.repositoryID)
+	}
+
+	if uuid.Nil != params.creatorID {
+		query = query.Where("creator_id = ?", params.creatorID)
+	}
+
+	if params.path != nil {
+		query = params.pathMatch.apply(query, "path", *params.path)
+	}
+
+	if params.idleBefore != nil {
+		query = query.Where("last_active_at < ?", *params.idleBefore)
+	}
+
+	query = query.Order("created_at DESC")
+	if params.after != nil {
+		query = query.Where("created_at < ?", *params.after)
+	}
+
+	err := query.Limit(params.amount).Scan(ctx)
+	if err != nil {
+		return nil, false, toModelError(err)
+	}
+	return sessions, params.amount > 0 && len(sessions) == params.amount, nil
+}
+
+func (u *UploadSessionRepo) CountByCreator(ctx context.Context, creatorID uuid.UUID) (int, error) {
+	return u.db.NewSelect().Model((*UploadSession)(nil)).Where("creator_id = ?", creatorID).Count(ctx)
+}
+
+func (u *UploadSessionRepo) AddPart(ctx context.Context, id uuid.UUID, part UploadPart) error {
+	session := &UploadSession{}
+	err := forUpdate(u.db, u.db.NewSelect().Model(session).Where("id = ?", id)).Scan(ctx)
+	if err != nil {
+		return toModelError(err)
+	}
+
+	parts := make([]UploadPart, 0, len(session.Parts)+1)
+	for _, p := range session.Parts {
+		if p.PartNumber != part.PartNumber {
+			parts = append(parts, p)
+		}
+	}
+	session.Parts = append(parts, part)
+	session.LastActiveAt = time.Now()
+
+	_, err = u.db.NewUpdate().Model(session).Column("parts", "last_active_at").WherePK().Exec(ctx)
+	return toModelError(err)
+}
+
+func (u *UploadSessionRepo) Delete(ctx context.Context, params *DeleteUploadSessionParams) (int64, error) {
+	query := u.db.NewDelete().Model((*UploadSession)(nil))
+
+	if uuid.Nil != params.id {
+		query = query.Where("id = ?", params.id)
+	}
+
+	if uuid.Nil != params.repositoryID {
+		query = query.Where("repository_id = ?", params.repositoryID)
+	}
+
+	sqlResult, err := query.Exec(ctx)
+	if err != nil {
+		return 0, toModelError(err)
+	}
+	return sqlResult.RowsAffected()
+}
@@ -0,0 +1,95 @@
+package models_test
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadSessionRepo(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewUploadSessionRepo(db)
+	repoID := uuid.New()
+	creatorID := uuid.New()
+
+	var sessions []*models.UploadSession
+	for i := 0; i < 3; i++ {
+		sessionModel := &models.UploadSession{}
+		require.NoError(t, gofakeit.Struct(sessionModel))
+		sessionModel.RepositoryID = repoID
+		sessionModel.CreatorID = creatorID
+		sessionModel.Parts = nil
+		sessionModel.Path = fmt.Sprintf("data/%d.csv", i)
+		sessionModel.LastActiveAt = time.Now().Add(-time.Duration(i) * time.Hour)
+		sessionModel.CreatedAt = time.Now().Add(time.Duration(i) * time.Second)
+		newSession, err := repo.Insert(ctx, sessionModel)
+		require.NoError(t, err)
+		sessions = append(sessions, newSession)
+	}
+
+	t.Run("get", func(t *testing.T) {
+		session, err := repo.Get(ctx, models.NewGetUploadSessionParams().SetID(sessions[0].ID).SetCreatorID(creatorID))
+		require.NoError(t, err)
+		require.Equal(t, sessions[0].UploadID, session.UploadID)
+
+		_, err = repo.Get(ctx, models.NewGetUploadSessionParams().SetID(sessions[0].ID).SetCreatorID(uuid.New()))
+		require.ErrorIs(t, err, models.ErrNotFound)
+	})
+
+	t.Run("count and list", func(t *testing.T) {
+		count, err := repo.CountByCreator(ctx, creatorID)
+		require.NoError(t, err)
+		require.Equal(t, 3, count)
+
+		list, hasMore, err := repo.List(ctx, models.NewListUploadSessionParams().SetRepositoryID(repoID).SetAmount(2))
+		require.NoError(t, err)
+		require.True(t, hasMore)
+		require.Len(t, list, 2)
+		require.Equal(t, sessions[2].ID, list[0].ID)
+
+		matched, _, err := repo.List(ctx, models.NewListUploadSessionParams().SetRepositoryID(repoID).SetPath("data/1", models.PrefixMatch))
+		require.NoError(t, err)
+		require.Len(t, matched, 1)
+		require.Equal(t, sessions[1].ID, matched[0].ID)
+
+		idle, _, err := repo.List(ctx, models.NewListUploadSessionParams().SetIdleBefore(time.Now().Add(-30*time.Minute)))
+		require.NoError(t, err)
+		require.Len(t, idle, 2)
+	})
+
+	t.Run("add part", func(t *testing.T) {
+		require.NoError(t, repo.AddPart(ctx, sessions[0].ID, models.UploadPart{PartNumber: 1, ETag: "a", Size: 1}))
+		require.NoError(t, repo.AddPart(ctx, sessions[0].ID, models.UploadPart{PartNumber: 2, ETag: "b", Size: 2}))
+		require.NoError(t, repo.AddPart(ctx, sessions[0].ID, models.UploadPart{PartNumber: 1, ETag: "c", Size: 3}))
+
+		session, err := repo.Get(ctx, models.NewGetUploadSessionParams().SetID(sessions[0].ID))
+		require.NoError(t, err)
+		require.Len(t, session.Parts, 2)
+		require.True(t, session.LastActiveAt.After(sessions[0].LastActiveAt))
+		for _, part := range session.Parts {
+			if part.PartNumber == 1 {
+				require.Equal(t, "c", part.ETag)
+			}
+		}
+	})
+
+	t.Run("delete", func(t *testing.T) {
+		affectedRows, err := repo.Delete(ctx, models.NewDeleteUploadSessionParams().SetID(sessions[0].ID))
+		require.NoError(t, err)
+		require.Equal(t, int64(1), affectedRows)
+
+		affectedRows, err = repo.Delete(ctx, models.NewDeleteUploadSessionParams().SetRepositoryID(repoID))
+		require.NoError(t, err)
+		require.Equal(t, int64(2), affectedRows)
+	})
+}
@@ -0,0 +1,166 @@
+package models
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/uptrace/bun"
+)
+
+// UsageReportState progress of usage report generation
+type UsageReportState string
+
+const (
+	UsageReportPending UsageReportState = "pending"
+	UsageReportRunning UsageReportState = "running"
+	UsageReportDone    UsageReportState = "done"
+	UsageReportFailed  UsageReportState = "failed"
+)
+
+// UsageReport usage report of repository requested by its admin, content is csv generated by background worker
+type UsageReport struct {
+	bun.BaseModel `bun:"table:usage_reports"`
+	ID            uuid.UUID        `bun:"id,pk,type:uuid,default:uuid_generate_v4()" json:"id"`
+	RepositoryID  uuid.UUID        `bun:"repository_id,type:uuid,notnull" json:"repository_id"`
+	CreatorID     uuid.UUID        `bun:"creator_id,type:uuid,notnull" json:"creator_id"`
+	State         UsageReportState `bun:"state,notnull" json:"state"`
+	// Content csv of report, set when report is done
+	Content []byte `bun:"content,type:bytea" json:"-"`
+	// Error why generation failed
+	Error string `bun:"error" json:"error"`
+
+	CreatedAt  time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
+	UpdatedAt  time.Time `bun:"updated_at,type:timestamp,notnull" json:"updated_at"`
+	FinishedAt time.Time `bun:"finished_at,type:timestamp,nullzero" json:"finished_at"`
+}
+
+// NewUsageReport pending report of repository
+func NewUsageReport(repositoryID, creatorID uuid.UUID) *UsageReport {
+	now := time.Now()
+	return &UsageReport{
+		RepositoryID: repositoryID,
+		CreatorID:    creatorID,
+		State:        UsageReportPending,
+		CreatedAt:    now,
+		UpdatedAt:    now,
+	}
+}
+
+type GetUsageReportParams struct {
+	id           uuid.UUID
+	repositoryID uuid.UUID
+}
+
+func NewGetUsageReportParams() *GetUsageReportParams {
+	return &GetUsageReportParams{}
+}
+
+func (gp *GetUsageReportParams) SetID(id uuid.UUID) *GetUsageReportParams {
+	gp.id = id
+	return gp
+}
+
+func (gp *GetUsageReportParams) SetRepositoryID(repositoryID uuid.UUID) *GetUsageReportParams {
+	gp.repositoryID = repositoryID
+	return gp
+}
+
+type UpdateUsageReportParams struct {
+	id       uuid.UUID
+	state    *UsageReportState
+	content  []byte
+	errorMsg *string
+}
+
+func NewUpdateUsageReportParams(id uuid.UUID) *UpdateUsageReportParams {
+	return &UpdateUsageReportParams{id: id}
+}
+
+func (up *UpdateUsageReportParams) SetState(state UsageReportState) *UpdateUsageReportParams {
+	up.state = &state
+	return up
+}
+
+func (up *UpdateUsageReportParams) SetContent(content []byte) *UpdateUsageReportParams {
+	up.content = content
+	return up
+}
+
+func (up *UpdateUsageReportParams) SetError(errorMsg string) *UpdateUsageReportParams {
+	up.errorMsg = &errorMsg
+	return up
+}
+
+type IUsageReportRepo interface {
+	Insert(ctx context.Context, report *UsageReport) (*UsageReport, error)
+	Get(ctx context.Context, params *GetUsageReportParams) (*UsageReport, error)
+	// ListUnfinished reports pending or interrupted while running from the oldest, content is not loaded
+	ListUnfinished(ctx context.Context) ([]*UsageReport, error)
+	Update(ctx context.Context, params *UpdateUsageReportParams) error
+}
+
+var _ IUsageReportRepo = (*UsageReportRepo)(nil)
+
+type UsageReportRepo struct {
+	db bun.IDB
+}
+
+func NewUsageReportRepo(db bun.IDB) IUsageReportRepo {
+	return &UsageReportRepo{db: db}
+}
+
+func (r *UsageReportRepo) Insert(ctx context.Context, report *UsageReport) (*UsageReport, error) {
+	_, err := r.db.NewInsert().Model(report).Exec(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return report, nil
+}
+
+func (r *UsageReportRepo) Get(ctx context.Context, params *GetUsageReportParams) (*UsageReport, error) {
+	report := &UsageReport{}
+	query := r.db.NewSelect().Model(report)
+	if params.id != uuid.Nil {
+		query = query.Where("id = ?", params.id)
+	}
+	if params.repositoryID != uuid.Nil {
+		query = query.Where("repository_id = ?", params.repositoryID)
+	}
+	err := query.Limit(1).Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return report, nil
+}
+
+func (r *UsageReportRepo) ListUnfinished(ctx context.Context) ([]*UsageReport, error) {
+	var reports []*UsageReport
+	err := r.db.NewSelect().Model(&reports).
+		ExcludeColumn("content").
+		Where("state IN (?)", bun.In([]UsageReportState{UsageReportPending, UsageReportRunning})).
+		Order("created_at ASC").
+		Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return reports, nil
+}
+
+func (r *UsageReportRepo) Update(ctx context.Context, params *UpdateUsageReportParams) error {
+	query := r.db.NewUpdate().Model((*UsageReport)(nil)).Where("id = ?", params.id)
+	if params.state != nil {
+		query = query.Set("state = ?", *params.state)
+		if *params.state == UsageReportDone || *params.state == UsageReportFailed {
+			query = query.Set("finished_at = ?", time.Now())
+		}
+	}
+	if params.content != nil {
+		query = query.Set("content = ?", params.content)
+	}
+	if params.errorMsg != nil {
+		query = query.Set("error = ?", *params.errorMsg)
+	}
+	_, err := query.Set("updated_at = ?", time.Now()).Exec(ctx)
+	return toModelError(err)
+}
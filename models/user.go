@@ -104,7 +104,7 @@ func (userRepo *UserRepo) Get(ctx context.Context, params *GetUserParams) (*User
 
 	err := query.Limit(1).Scan(ctx)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
 	}
 	return user, nil
 }
@@ -126,15 +126,15 @@ func (userRepo *UserRepo) Count(ctx context.Context, params *CountUserParams) (i
 func (userRepo *UserRepo) Insert(ctx context.Context, user *User) (*User, error) {
 	_, err := userRepo.db.NewInsert().Model(user).Exec(ctx)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
 	}
 	return user, nil
 }
 
 func (userRepo *UserRepo) GetEPByName(ctx context.Context, name string) (string, error) {
 	var ep string
-	return ep, userRepo.db.NewSelect().
+	return ep, toModelError(userRepo.db.NewSelect().
 		Model((*User)(nil)).Column("encrypted_password").
 		Where("name = ?", name).
-		Scan(ctx, &ep)
+		Scan(ctx, &ep))
 }
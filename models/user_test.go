@@ -41,6 +41,12 @@ func TestNewUserRepo(t *testing.T) {
 	userByName, err := repo.Get(ctx, models.NewGetUserParams().SetName(newUser.Name))
 	require.NoError(t, err)
 	require.True(t, cmp.Equal(userModel, userByName, testhelper.DBTimeCmpOpt))
+
+	duplicateUser := &models.User{}
+	require.NoError(t, gofakeit.Struct(duplicateUser))
+	duplicateUser.Name = newUser.Name
+	_, err = repo.Insert(ctx, duplicateUser)
+	require.ErrorIs(t, err, models.ErrAlreadyExists)
 }
 
 func TestCount(t *testing.T) {
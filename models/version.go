@@ -0,0 +1,32 @@
+package models
+
+import (
+	"context"
+
+	"github.com/uptrace/bun"
+)
+
+// execVersionedUpdate bump version of rows updated by query. if version is not nil, only row at this version is
+// updated and ErrStaleObject is returned if it was updated by others since it was read
+func execVersionedUpdate(ctx context.Context, query *bun.UpdateQuery, version *int64) error {
+	query.Set("version = version + 1")
+	if version != nil {
+		query.Where("version = ?", *version)
+	}
+
+	sqlResult, err := query.Exec(ctx)
+	if err != nil {
+		return toModelError(err)
+	}
+	if version == nil {
+		return nil
+	}
+	affectedRows, err := sqlResult.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if affectedRows == 0 {
+		return ErrStaleObject
+	}
+	return nil
+}
@@ -27,6 +27,11 @@ type WorkingInProcess struct {
 	CreatorID     uuid.UUID `bun:"creator_id,unique:creator_id_repository_id_ref_id_unique,type:uuid,notnull" json:"creator_id"`
 	CreatedAt     time.Time `bun:"created_at,type:timestamp,notnull" json:"created_at"`
 	UpdatedAt     time.Time `bun:"updated_at,type:timestamp,notnull" json:"updated_at"`
+	// Version increased by every update, given to UpdateByID to reject update if wip changed since it was read
+	Version int64 `bun:"version,notnull,default:0" json:"version"`
+
+	// StagedTree tree of staged changes, like index in git. nil mean stage is not used, all changes in CurrentTree will be committed
+	StagedTree hash.Hash `bun:"staged_tree,type:bytea" json:"staged_tree,omitempty"`
 }
 
 type GetWipParams struct {
@@ -64,6 +69,8 @@ type ListWipParams struct {
 	creatorID    uuid.UUID
 	repositoryID uuid.UUID
 	refID        uuid.UUID
+	after        *time.Time
+	amount       int
 }
 
 func NewListWipParams() *ListWipParams {
@@ -84,6 +91,17 @@ func (lwp *ListWipParams) SetRefID(refID uuid.UUID) *ListWipParams {
 	return lwp
 }
 
+func (lwp *ListWipParams) SetAfter(after time.Time) *ListWipParams {
+	lwp.after = &after
+	return lwp
+}
+
+// SetAmount limit number of wips returned, all wips are returned if not set
+func (lwp *ListWipParams) SetAmount(amount int) *ListWipParams {
+	lwp.amount = amount
+	return lwp
+}
+
 type DeleteWipParams struct {
 	id           uuid.UUID
 	creatorID    uuid.UUID
@@ -119,7 +137,10 @@ type UpdateWipParams struct {
 	id          uuid.UUID
 	currentTree hash.Hash
 	baseCommit  hash.Hash
+	stagedTree  hash.Hash
+	clearStaged bool
 	state       *WipState
+	version     *int64
 	updatedAt   time.Time
 }
 
@@ -137,17 +158,43 @@ func (up *UpdateWipParams) SetBaseCommit(commitHash hash.Hash) *UpdateWipParams
 	return up
 }
 
+func (up *UpdateWipParams) SetStagedTree(stagedTree hash.Hash) *UpdateWipParams {
+	up.stagedTree = stagedTree
+	up.clearStaged = false
+	return up
+}
+
+// ClearStagedTree drop staged tree, wip go back to commit all changes
+func (up *UpdateWipParams) ClearStagedTree() *UpdateWipParams {
+	up.stagedTree = nil
+	up.clearStaged = true
+	return up
+}
+
 func (up *UpdateWipParams) SetState(state WipState) *UpdateWipParams {
 	up.state = &state
 	return up
 }
 
+// SetVersion only update wip at version, see WorkingInProcess.Version
+func (up *UpdateWipParams) SetVersion(version int64) *UpdateWipParams {
+	up.version = &version
+	return up
+}
+
 type IWipRepo interface {
 	Insert(ctx context.Context, repo *WorkingInProcess) (*WorkingInProcess, error)
 	Get(ctx context.Context, params *GetWipParams) (*WorkingInProcess, error)
-	List(ctx context.Context, params *ListWipParams) ([]*WorkingInProcess, error)
+	List(ctx context.Context, params *ListWipParams) ([]*WorkingInProcess, bool, error)
 	Delete(ctx context.Context, params *DeleteWipParams) (int64, error)
+	// DeleteByRef remove wips of all users on ref, return number of wips removed
+	DeleteByRef(ctx context.Context, repositoryID, refID uuid.UUID) (int64, error)
+	// UpdateByID update wip, return ErrStaleObject if version is set and wip not at the version any more
 	UpdateByID(ctx context.Context, params *UpdateWipParams) error
+	// UpdateCurrentTree point wip to currentTree after changes were written to worktree
+	UpdateCurrentTree(ctx context.Context, id uuid.UUID, currentTree hash.Hash) error
+	// LockByID get wip and lock it until transaction end, so concurrent changes to its tree are applied one by one
+	LockByID(ctx context.Context, id uuid.UUID) (*WorkingInProcess, error)
 }
 
 var _ IWipRepo = (*WipRepo)(nil)
@@ -163,7 +210,7 @@ func NewWipRepo(db bun.IDB) IWipRepo {
 func (s *WipRepo) Insert(ctx context.Context, repo *WorkingInProcess) (*WorkingInProcess, error) {
 	_, err := s.db.NewInsert().Model(repo).Exec(ctx)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
 	}
 	return repo, nil
 }
@@ -191,12 +238,12 @@ func (s *WipRepo) Get(ctx context.Context, params *GetWipParams) (*WorkingInProc
 
 	err := query.Limit(1).Scan(ctx)
 	if err != nil {
-		return nil, err
+		return nil, toModelError(err)
 	}
 	return wips, nil
 }
 
-func (s *WipRepo) List(ctx context.Context, params *ListWipParams) ([]*WorkingInProcess, error) {
+func (s *WipRepo) List(ctx context.Context, params *ListWipParams) ([]*WorkingInProcess, bool, error) {
 	var resp []*WorkingInProcess
 	query := s.db.NewSelect().Model(&resp)
 
@@ -212,11 +259,16 @@ func (s *WipRepo) List(ctx context.Context, params *ListWipParams) ([]*WorkingIn
 		query = query.Where("ref_id = ?", params.refID)
 	}
 
-	err := query.Scan(ctx)
+	query = query.Order("updated_at DESC")
+	if params.after != nil {
+		query = query.Where("updated_at < ?", *params.after)
+	}
+
+	err := query.Limit(params.amount).Scan(ctx)
 	if err != nil {
-		return nil, err
+		return nil, false, toModelError(err)
 	}
-	return resp, nil
+	return resp, params.amount > 0 && len(resp) == params.amount, nil
 }
 
 // Delete remove wip in table by id
@@ -240,7 +292,7 @@ func (s *WipRepo) Delete(ctx context.Context, params *DeleteWipParams) (int64, e
 	}
 	r, err := query.Exec(ctx)
 	if err != nil {
-		return 0, err
+		return 0, toModelError(err)
 	}
 	row, err := r.RowsAffected()
 	if err != nil {
@@ -249,6 +301,10 @@ func (s *WipRepo) Delete(ctx context.Context, params *DeleteWipParams) (int64, e
 	return row, nil
 }
 
+func (s *WipRepo) DeleteByRef(ctx context.Context, repositoryID, refID uuid.UUID) (int64, error) {
+	return s.Delete(ctx, NewDeleteWipParams().SetRepositoryID(repositoryID).SetRefID(refID))
+}
+
 func (s *WipRepo) UpdateByID(ctx context.Context, updateModel *UpdateWipParams) error {
 	updateQuery := s.db.NewUpdate().
 		Model((*WorkingInProcess)(nil)).
@@ -266,6 +322,24 @@ func (s *WipRepo) UpdateByID(ctx context.Context, updateModel *UpdateWipParams)
 	if updateModel.baseCommit != nil {
 		updateQuery.Set("base_commit = ?", updateModel.baseCommit)
 	}
-	_, err := updateQuery.Exec(ctx)
-	return err
+
+	if updateModel.stagedTree != nil {
+		updateQuery.Set("staged_tree = ?", updateModel.stagedTree)
+	} else if updateModel.clearStaged {
+		updateQuery.Set("staged_tree = NULL")
+	}
+	return execVersionedUpdate(ctx, updateQuery, updateModel.version)
+}
+
+func (s *WipRepo) UpdateCurrentTree(ctx context.Context, id uuid.UUID, currentTree hash.Hash) error {
+	return s.UpdateByID(ctx, NewUpdateWipParams(id).SetCurrentTree(currentTree))
+}
+
+func (s *WipRepo) LockByID(ctx context.Context, id uuid.UUID) (*WorkingInProcess, error) {
+	wip := &WorkingInProcess{}
+	err := forUpdate(s.db, s.db.NewSelect().Model(wip).Where("id = ?", id)).Scan(ctx)
+	if err != nil {
+		return nil, toModelError(err)
+	}
+	return wip, nil
 }
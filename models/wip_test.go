@@ -56,16 +56,30 @@ func TestWipRepo(t *testing.T) {
 			SetCreatorID(secNewWipModel.CreatorID).
 			SetRepositoryID(secNewWipModel.RepositoryID)
 
-		list, err := repo.List(ctx, listParams)
+		list, hasMore, err := repo.List(ctx, listParams)
 		require.NoError(t, err)
 		require.Len(t, list, 2)
+		require.False(t, hasMore)
+
+		{
+			page, hasMore, err := repo.List(ctx, listParams.SetAmount(1))
+			require.NoError(t, err)
+			require.Len(t, page, 1)
+			require.True(t, hasMore)
+			require.Equal(t, list[0].ID, page[0].ID)
+
+			page, _, err = repo.List(ctx, listParams.SetAfter(page[0].UpdatedAt))
+			require.NoError(t, err)
+			require.Len(t, page, 1)
+			require.Equal(t, list[1].ID, page[0].ID)
+		}
 
 		{
 			listParams := models.NewListWipParams().
 				SetRepositoryID(newWipModel.RepositoryID).
 				SetRefID(newWipModel.RefID)
 
-			list, err := repo.List(ctx, listParams)
+			list, _, err := repo.List(ctx, listParams)
 			require.NoError(t, err)
 			require.Len(t, list, 2)
 		}
@@ -86,6 +100,17 @@ func TestWipRepo(t *testing.T) {
 		_, err = repo.Get(ctx, models.NewGetWipParams().SetID(secWipModel.ID))
 		require.ErrorIs(t, err, models.ErrNotFound)
 	})
+
+	t.Run("delete by ref", func(t *testing.T) {
+		//wips of all users on ref are removed
+		affectedRow, err := repo.DeleteByRef(ctx, newWipModel.RepositoryID, newWipModel.RefID)
+		require.NoError(t, err)
+		require.Equal(t, int64(2), affectedRow)
+
+		list, _, err := repo.List(ctx, models.NewListWipParams().SetRepositoryID(newWipModel.RepositoryID).SetRefID(newWipModel.RefID))
+		require.NoError(t, err)
+		require.Empty(t, list)
+	})
 }
 
 func TestWipRepoUpdateByID(t *testing.T) {
@@ -114,6 +139,20 @@ func TestWipRepoUpdateByID(t *testing.T) {
 		require.Equal(t, "mock hash", string(updatedUser.CurrentTree))
 	})
 
+	t.Run("update current tree", func(t *testing.T) {
+		wipModel := &models.WorkingInProcess{}
+		require.NoError(t, gofakeit.Struct(wipModel))
+		newWipModel, err := repo.Insert(ctx, wipModel)
+		require.NoError(t, err)
+
+		err = repo.UpdateCurrentTree(ctx, newWipModel.ID, hash.Hash("current tree"))
+		require.NoError(t, err)
+		updated, err := repo.Get(ctx, models.NewGetWipParams().SetID(newWipModel.ID))
+		require.NoError(t, err)
+		require.Equal(t, "current tree", string(updated.CurrentTree))
+		require.Equal(t, newWipModel.Version+1, updated.Version)
+	})
+
 	t.Run("update both", func(t *testing.T) {
 		wipModel := &models.WorkingInProcess{}
 		require.NoError(t, gofakeit.Struct(wipModel))
@@ -134,4 +173,57 @@ func TestWipRepoUpdateByID(t *testing.T) {
 		require.Equal(t, "mock base hash", string(updatedUser.BaseCommit))
 		require.Equal(t, "mock hash", string(updatedUser.CurrentTree))
 	})
+
+	t.Run("set and clear staged tree", func(t *testing.T) {
+		wipModel := &models.WorkingInProcess{}
+		require.NoError(t, gofakeit.Struct(wipModel))
+		newWipModel, err := repo.Insert(ctx, wipModel)
+		require.NoError(t, err)
+
+		err = repo.UpdateByID(ctx, models.NewUpdateWipParams(newWipModel.ID).SetStagedTree(hash.Hash("mock staged hash")))
+		require.NoError(t, err)
+		updatedWip, err := repo.Get(ctx, models.NewGetWipParams().SetID(newWipModel.ID))
+		require.NoError(t, err)
+		require.Equal(t, "mock staged hash", string(updatedWip.StagedTree))
+
+		err = repo.UpdateByID(ctx, models.NewUpdateWipParams(newWipModel.ID).ClearStagedTree())
+		require.NoError(t, err)
+		updatedWip, err = repo.Get(ctx, models.NewGetWipParams().SetID(newWipModel.ID))
+		require.NoError(t, err)
+		require.Nil(t, updatedWip.StagedTree)
+	})
+
+	t.Run("update at version", func(t *testing.T) {
+		wipModel := &models.WorkingInProcess{}
+		require.NoError(t, gofakeit.Struct(wipModel))
+		newWipModel, err := repo.Insert(ctx, wipModel)
+		require.NoError(t, err)
+
+		err = repo.UpdateByID(ctx, models.NewUpdateWipParams(newWipModel.ID).SetCurrentTree(hash.Hash("first hash")).SetVersion(newWipModel.Version))
+		require.NoError(t, err)
+		err = repo.UpdateByID(ctx, models.NewUpdateWipParams(newWipModel.ID).SetCurrentTree(hash.Hash("second hash")).SetVersion(newWipModel.Version))
+		require.ErrorIs(t, err, models.ErrStaleObject)
+
+		updatedWip, err := repo.Get(ctx, models.NewGetWipParams().SetID(newWipModel.ID))
+		require.NoError(t, err)
+		require.Equal(t, "first hash", string(updatedWip.CurrentTree))
+		require.Equal(t, newWipModel.Version+1, updatedWip.Version)
+	})
+	t.Run("lock by id", func(t *testing.T) {
+		wipModel := &models.WorkingInProcess{}
+		require.NoError(t, gofakeit.Struct(wipModel))
+		newWipModel, err := repo.Insert(ctx, wipModel)
+		require.NoError(t, err)
+
+		err = models.NewRepo(db).Transaction(ctx, func(txRepo models.IRepo) error {
+			locked, err := txRepo.WipRepo().LockByID(ctx, newWipModel.ID)
+			require.NoError(t, err)
+			require.Equal(t, newWipModel.CurrentTree, locked.CurrentTree)
+			return txRepo.WipRepo().UpdateCurrentTree(ctx, locked.ID, hash.Hash("locked tree"))
+		})
+		require.NoError(t, err)
+
+		_, err = repo.LockByID(ctx, uuid.New())
+		require.ErrorIs(t, err, models.ErrNotFound)
+	})
 }
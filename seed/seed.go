@@ -0,0 +1,442 @@
+// Package seed set up users, organizations, repositories, branches and their protection of instance from a yaml
+// manifest. there is no webhook in jiaozifs, subscribe events of repositories instead
+package seed
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/auth"
+	"github.com/GitDataAI/jiaozifs/auth/rbac"
+	"github.com/GitDataAI/jiaozifs/controller/validator"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/models/rbacmodel"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/versionmgr"
+	"github.com/google/uuid"
+	logging "github.com/ipfs/go-log/v2"
+	"gopkg.in/yaml.v2"
+)
+
+var log = logging.Logger("seed")
+
+// defaultBranchName HEAD of repositories created by seed, the same as repositories created by api
+const defaultBranchName = "main"
+
+var ErrInvalidManifest = errors.New("invalid seed manifest")
+
+// Manifest desired users, organizations and repositories of instance. applying it creates what is missing, sets roles of
+// organization members and protection of branches, anything else already existing is left as it is, so the same
+// manifest can be applied again and again
+type Manifest struct {
+	Users         []User         `yaml:"users"`
+	Organizations []Organization `yaml:"organizations"`
+	Repositories  []Repository   `yaml:"repositories"`
+}
+
+type User struct {
+	Name  string `yaml:"name"`
+	Email string `yaml:"email"`
+	// Password of user when created, password of existing user is not changed
+	Password string `yaml:"password"`
+	// PasswordEnv environment variable to read password from, keep password out of manifest
+	PasswordEnv string `yaml:"password_env"`
+}
+
+type Organization struct {
+	Name        string      `yaml:"name"`
+	Description *string     `yaml:"description"`
+	Members     []OrgMember `yaml:"members"`
+}
+
+type OrgMember struct {
+	User string         `yaml:"user"`
+	Role models.OrgRole `yaml:"role"`
+}
+
+type Repository struct {
+	// Owner name of user or organization
+	Owner string `yaml:"owner"`
+	Name  string `yaml:"name"`
+	// Creator user creating repository, default to owner, required when owner is organization
+	Creator     string   `yaml:"creator"`
+	Description *string  `yaml:"description"`
+	Visible     bool     `yaml:"visible"`
+	Branches    []Branch `yaml:"branches"`
+}
+
+type Branch struct {
+	Name string `yaml:"name"`
+	// Source branch new branch is created from, default to HEAD of repository
+	Source string `yaml:"source"`
+	// Protection protection set on branch, nil keeps protection of existing branch
+	Protection *Protection `yaml:"protection"`
+}
+
+type Protection struct {
+	IsProtected       bool `yaml:"protected"`
+	AllowForcePush    bool `yaml:"allow_force_push"`
+	RequiredApprovals int  `yaml:"required_approvals"`
+}
+
+// Result what applying manifest changed, every item is like "user alice" or "branch alice/data dev"
+type Result struct {
+	Created []string
+	Updated []string
+}
+
+// Load read manifest from yaml file, unknown fields are rejected so typos are not silently ignored
+func Load(path string) (*Manifest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	err = yaml.UnmarshalStrict(data, manifest)
+	if err != nil {
+		return nil, fmt.Errorf("parse %s %v %w", path, err, ErrInvalidManifest)
+	}
+	return manifest, manifest.Validate()
+}
+
+// Validate check names of manifest the same way as api does
+func (manifest *Manifest) Validate() error {
+	for _, user := range manifest.Users {
+		if err := validator.ValidateUsername(user.Name); err != nil {
+			return fmt.Errorf("user %s %v %w", user.Name, err, ErrInvalidManifest)
+		}
+	}
+	for _, org := range manifest.Organizations {
+		if err := validator.ValidateUsername(org.Name); err != nil {
+			return fmt.Errorf("organization %s %v %w", org.Name, err, ErrInvalidManifest)
+		}
+		for _, member := range org.Members {
+			if !member.Role.Valid() {
+				return fmt.Errorf("organization %s member %s role %s %w", org.Name, member.User, member.Role, ErrInvalidManifest)
+			}
+		}
+	}
+	for _, repository := range manifest.Repositories {
+		if err := validator.ValidateRepoName(repository.Name); err != nil {
+			return fmt.Errorf("repository %s/%s %v %w", repository.Owner, repository.Name, err, ErrInvalidManifest)
+		}
+		for _, branch := range repository.Branches {
+			if err := validator.ValidateBranchName(branch.Name); err != nil {
+				return fmt.Errorf("repository %s/%s branch %s %v %w", repository.Owner, repository.Name, branch.Name, err, ErrInvalidManifest)
+			}
+			if branch.Protection != nil && branch.Protection.RequiredApprovals < 0 {
+				return fmt.Errorf("repository %s/%s branch %s required approvals must not be negative %w", repository.Owner, repository.Name, branch.Name, ErrInvalidManifest)
+			}
+		}
+	}
+	return nil
+}
+
+// Apply make instance match manifest in a single transaction, nothing is changed if any item fails. storageType is type
+// of public blockstore repositories are created in. rbac must be initialized by `init` before
+func Apply(ctx context.Context, repo models.IRepo, manifest *Manifest, storageType string) (*Result, error) {
+	err := manifest.Validate()
+	if err != nil {
+		return nil, err
+	}
+
+	var result *Result
+	err = repo.Transaction(ctx, func(repo models.IRepo) error {
+		applier := &applier{repo: repo, storageType: storageType, result: &Result{}}
+		for _, user := range manifest.Users {
+			if err := applier.applyUser(ctx, user); err != nil {
+				return fmt.Errorf("user %s %w", user.Name, err)
+			}
+		}
+		for _, org := range manifest.Organizations {
+			if err := applier.applyOrganization(ctx, org); err != nil {
+				return fmt.Errorf("organization %s %w", org.Name, err)
+			}
+		}
+		for _, repository := range manifest.Repositories {
+			if err := applier.applyRepository(ctx, repository); err != nil {
+				return fmt.Errorf("repository %s/%s %w", repository.Owner, repository.Name, err)
+			}
+		}
+		result = applier.result
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+type applier struct {
+	repo        models.IRepo
+	storageType string
+	result      *Result
+}
+
+func (applier *applier) created(format string, args ...any) {
+	item := fmt.Sprintf(format, args...)
+	log.Infof("create %s", item)
+	applier.result.Created = append(applier.result.Created, item)
+}
+
+func (applier *applier) updated(format string, args ...any) {
+	item := fmt.Sprintf(format, args...)
+	log.Infof("update %s", item)
+	applier.result.Updated = append(applier.result.Updated, item)
+}
+
+func (applier *applier) applyUser(ctx context.Context, user User) error {
+	_, err := applier.repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(user.Name))
+	if err == nil {
+		return nil
+	}
+	if !errors.Is(err, models.ErrNotFound) {
+		return err
+	}
+
+	password := user.Password
+	if len(user.PasswordEnv) > 0 {
+		password = os.Getenv(user.PasswordEnv)
+	}
+	if len(password) == 0 {
+		return fmt.Errorf("password of new user is empty %w", ErrInvalidManifest)
+	}
+	passwordHash, err := auth.HashPassword(password)
+	if err != nil {
+		return err
+	}
+
+	// the same as users registered by api
+	insertUser, err := applier.repo.UserRepo().Insert(ctx, &models.User{
+		Name:              user.Name,
+		Email:             user.Email,
+		EncryptedPassword: string(passwordHash),
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	userOwnGroup, err := applier.repo.GroupRepo().Get(ctx, rbacmodel.NewGetGroupParams().SetName(rbac.UserOwnAccess))
+	if err != nil {
+		return fmt.Errorf("get group %s, run init first %w", rbac.UserOwnAccess, err)
+	}
+	_, err = applier.repo.UserGroupRepo().Insert(ctx, &rbacmodel.UserGroup{
+		UserID:    insertUser.ID,
+		GroupID:   userOwnGroup.ID,
+		CreatedAt: time.Now(),
+		UpdatedAt: time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	applier.created("user %s", user.Name)
+	return nil
+}
+
+func (applier *applier) applyOrganization(ctx context.Context, org Organization) error {
+	orgModel, err := applier.repo.OrgRepo().Get(ctx, models.NewGetOrgParams().SetName(org.Name))
+	if errors.Is(err, models.ErrNotFound) {
+		if len(org.Members) == 0 {
+			return fmt.Errorf("new organization has no member to be its creator %w", ErrInvalidManifest)
+		}
+		creator, err := applier.repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(org.Members[0].User))
+		if err != nil {
+			return err
+		}
+		orgModel, err = applier.repo.OrgRepo().Insert(ctx, &models.Organization{
+			Name:        org.Name,
+			Description: org.Description,
+			CreatorID:   creator.ID,
+			CreatedAt:   time.Now(),
+			UpdatedAt:   time.Now(),
+		})
+		if err != nil {
+			return err
+		}
+		applier.created("organization %s", org.Name)
+	} else if err != nil {
+		return err
+	}
+
+	for _, member := range org.Members {
+		user, err := applier.repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(member.User))
+		if err != nil {
+			return fmt.Errorf("member %s %w", member.User, err)
+		}
+		membership, err := applier.repo.OrgRepo().GetMembership(ctx, orgModel.ID, user.ID)
+		if errors.Is(err, models.ErrNotFound) {
+			_, err = applier.repo.OrgRepo().AddMember(ctx, &models.Membership{
+				OrgID:     orgModel.ID,
+				UserID:    user.ID,
+				Role:      member.Role,
+				CreatedAt: time.Now(),
+				UpdatedAt: time.Now(),
+			})
+			if err != nil {
+				return err
+			}
+			applier.created("member %s of %s", member.User, org.Name)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if membership.Role != member.Role {
+			err = applier.repo.OrgRepo().UpdateRole(ctx, orgModel.ID, user.ID, member.Role)
+			if err != nil {
+				return err
+			}
+			applier.updated("member %s of %s", member.User, org.Name)
+		}
+	}
+	return nil
+}
+
+// ownerID find user or organization named name, they share one namespace of names
+func (applier *applier) ownerID(ctx context.Context, name string) (uuid.UUID, bool, error) {
+	user, err := applier.repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(name))
+	if err == nil {
+		return user.ID, false, nil
+	}
+	if !errors.Is(err, models.ErrNotFound) {
+		return uuid.Nil, false, err
+	}
+	org, err := applier.repo.OrgRepo().Get(ctx, models.NewGetOrgParams().SetName(name))
+	if err != nil {
+		return uuid.Nil, false, fmt.Errorf("owner %s %w", name, err)
+	}
+	return org.ID, true, nil
+}
+
+func (applier *applier) applyRepository(ctx context.Context, repository Repository) error {
+	ownerID, isOrg, err := applier.ownerID(ctx, repository.Owner)
+	if err != nil {
+		return err
+	}
+	creatorName := repository.Creator
+	if len(creatorName) == 0 {
+		if isOrg {
+			return fmt.Errorf("creator is required for repository of organization %w", ErrInvalidManifest)
+		}
+		creatorName = repository.Owner
+	}
+	creator, err := applier.repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName(creatorName))
+	if err != nil {
+		return fmt.Errorf("creator %s %w", creatorName, err)
+	}
+
+	repoModel, err := applier.repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetOwnerID(ownerID).SetName(repository.Name))
+	if errors.Is(err, models.ErrNotFound) {
+		repoModel, err = applier.createRepository(ctx, repository, ownerID, creator)
+	}
+	if err != nil {
+		return err
+	}
+
+	fullName := repository.Owner + "/" + repository.Name
+	for _, branch := range repository.Branches {
+		branchModel, err := applier.repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(repoModel.ID).SetName(branch.Name))
+		if errors.Is(err, models.ErrNotFound) {
+			source := branch.Source
+			if len(source) == 0 {
+				source = repoModel.HEAD
+			}
+			workRepo := versionmgr.NewWorkRepositoryFromAdapter(ctx, creator, repoModel, applier.repo, nil)
+			err = workRepo.CheckOut(ctx, versionmgr.InBranch, source)
+			if err != nil {
+				return fmt.Errorf("source %s of branch %s %w", source, branch.Name, err)
+			}
+			branchModel, err = workRepo.CreateBranch(ctx, branch.Name)
+			if err != nil {
+				return err
+			}
+			applier.created("branch %s %s", fullName, branch.Name)
+		} else if err != nil {
+			return err
+		}
+
+		if branch.Protection == nil {
+			continue
+		}
+		protection := models.BranchProtection{
+			IsProtected:       branch.Protection.IsProtected,
+			AllowForcePush:    branch.Protection.AllowForcePush,
+			RequiredApprovals: branch.Protection.RequiredApprovals,
+		}
+		if branchModel.IsProtected == protection.IsProtected && branchModel.AllowForcePush == protection.AllowForcePush &&
+			branchModel.RequiredApprovals == protection.RequiredApprovals {
+			continue
+		}
+		err = applier.repo.BranchRepo().UpdateProtection(ctx, branchModel.ID, protection)
+		if err != nil {
+			return err
+		}
+		applier.updated("protection of branch %s %s", fullName, branch.Name)
+	}
+	return nil
+}
+
+// createRepository create repository in public storage with its default branch, the same as repositories created by api
+func (applier *applier) createRepository(ctx context.Context, repository Repository, ownerID uuid.UUID, creator *models.User) (*models.Repository, error) {
+	// name of repository in trash is kept until purged
+	_, err := applier.repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetOwnerID(ownerID).SetName(repository.Name).SetDeleted())
+	if err == nil {
+		return nil, fmt.Errorf("repository is in trash, restore or purge it first %w", models.ErrAlreadyExists)
+	}
+	if !errors.Is(err, models.ErrNotFound) {
+		return nil, err
+	}
+
+	repoID := uuid.New()
+	storageNamespace := fmt.Sprintf("%s://%s", applier.storageType, repoID.String())
+	storageAdapterParams := ""
+	repoModel, err := applier.repo.RepositoryRepo().Insert(ctx, &models.Repository{
+		ID:                   repoID,
+		Name:                 repository.Name,
+		Visible:              repository.Visible,
+		UsePublicStorage:     true,
+		StorageAdapterParams: &storageAdapterParams,
+		StorageNamespace:     &storageNamespace,
+		Description:          repository.Description,
+		HEAD:                 defaultBranchName,
+		OwnerID:              ownerID,
+		CreatorID:            creator.ID,
+		CreatedAt:            time.Now(),
+		UpdatedAt:            time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	_, err = applier.repo.BranchRepo().Insert(ctx, &models.Branch{
+		RepositoryID: repoID,
+		CommitHash:   hash.Hash{},
+		Name:         defaultBranchName,
+		CreatorID:    creator.ID,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = applier.repo.EventRepo().Insert(ctx, models.NewEvent(models.RepositoryCreatedEvent, repoID, creator.ID, map[string]string{
+		"owner":      repository.Owner,
+		"repository": repository.Name,
+	}))
+	if err != nil {
+		return nil, err
+	}
+	_, err = applier.repo.EventRepo().Insert(ctx, models.NewEvent(models.BranchCreatedEvent, repoID, creator.ID, map[string]string{
+		"branch": defaultBranchName,
+		"commit": hash.Hash{}.Hex(),
+	}))
+	if err != nil {
+		return nil, err
+	}
+	applier.created("repository %s/%s", repository.Owner, repository.Name)
+	return repoModel, nil
+}
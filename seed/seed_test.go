@@ -0,0 +1,126 @@
+package seed
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/auth"
+	"github.com/GitDataAI/jiaozifs/auth/rbac"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+const testManifest = `
+users:
+  - name: alice
+    email: alice@example.com
+    password: alice123456
+  - name: bob
+    email: bob@example.com
+    password_env: SEED_TEST_BOB_PASSWORD
+organizations:
+  - name: lab
+    members:
+      - user: alice
+        role: owner
+      - user: bob
+        role: member
+repositories:
+  - owner: alice
+    name: data
+    branches:
+      - name: dev
+      - name: main
+        protection:
+          protected: true
+          required_approvals: 1
+  - owner: lab
+    name: models
+    creator: bob
+    visible: true
+`
+
+func TestApply(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+	t.Setenv("SEED_TEST_BOB_PASSWORD", "bob123456")
+
+	repo := models.NewRepo(db)
+	password, err := auth.HashPassword("123456789")
+	require.NoError(t, err)
+	require.NoError(t, rbac.NewRbacAuth(repo).InitRbac(ctx, &models.User{
+		Name:              "admin",
+		EncryptedPassword: string(password),
+		CreatedAt:         time.Now(),
+		UpdatedAt:         time.Now(),
+	}))
+
+	path := filepath.Join(t.TempDir(), "seed.yaml")
+	require.NoError(t, os.WriteFile(path, []byte(testManifest), 0644))
+	manifest, err := Load(path)
+	require.NoError(t, err)
+
+	result, err := Apply(ctx, repo, manifest, "mem")
+	require.NoError(t, err)
+	require.Equal(t, []string{
+		"user alice",
+		"user bob",
+		"organization lab",
+		"member alice of lab",
+		"member bob of lab",
+		"repository alice/data",
+		"branch alice/data dev",
+		"repository lab/models",
+	}, result.Created)
+	require.Equal(t, []string{"protection of branch alice/data main"}, result.Updated)
+
+	alice, err := repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName("alice"))
+	require.NoError(t, err)
+	data, err := repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetOwnerID(alice.ID).SetName("data"))
+	require.NoError(t, err)
+	main, err := repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(data.ID).SetName("main"))
+	require.NoError(t, err)
+	require.True(t, main.IsProtected)
+	require.Equal(t, 1, main.RequiredApprovals)
+
+	t.Run("apply again", func(t *testing.T) {
+		result, err := Apply(ctx, repo, manifest, "mem")
+		require.NoError(t, err)
+		require.Empty(t, result.Created)
+		require.Empty(t, result.Updated)
+	})
+
+	t.Run("update role", func(t *testing.T) {
+		manifest.Organizations[0].Members[1].Role = models.OrgRoleAdmin
+		result, err := Apply(ctx, repo, manifest, "mem")
+		require.NoError(t, err)
+		require.Empty(t, result.Created)
+		require.Equal(t, []string{"member bob of lab"}, result.Updated)
+	})
+
+	t.Run("failure changes nothing", func(t *testing.T) {
+		_, err := Apply(ctx, repo, &Manifest{
+			Users:        []User{{Name: "carol", Email: "carol@example.com", Password: "carol123456"}},
+			Repositories: []Repository{{Owner: "lab", Name: "orphan"}},
+		}, "mem")
+		require.ErrorIs(t, err, ErrInvalidManifest)
+		_, err = repo.UserRepo().Get(ctx, models.NewGetUserParams().SetName("carol"))
+		require.ErrorIs(t, err, models.ErrNotFound)
+	})
+}
+
+func TestLoad(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "seed.yaml")
+	require.NoError(t, os.WriteFile(path, []byte("webhooks:\n  - url: http://example.com\n"), 0644))
+	_, err := Load(path)
+	require.ErrorIs(t, err, ErrInvalidManifest)
+
+	require.NoError(t, os.WriteFile(path, []byte("organizations:\n  - name: lab\n    members:\n      - user: alice\n        role: boss\n"), 0644))
+	_, err = Load(path)
+	require.ErrorIs(t, err, ErrInvalidManifest)
+}
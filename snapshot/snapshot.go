@@ -0,0 +1,379 @@
+package snapshot
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/models/rbacmodel"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/utils/pathutil"
+	"github.com/google/uuid"
+	logging "github.com/ipfs/go-log/v2"
+	"github.com/uptrace/bun"
+	"github.com/uptrace/bun/dialect"
+	"github.com/uptrace/bun/driver/pgdriver"
+)
+
+var log = logging.Logger("snapshot")
+
+const (
+	// ManifestFileName manifest file in snapshot directory
+	ManifestFileName = "manifest.json"
+	// FormatVersion increase when layout of snapshot changed
+	FormatVersion = 2
+
+	tableDir = "tables"
+)
+
+var (
+	ErrTargetNotEmpty   = errors.New("target database is not empty")
+	ErrSnapshotCorrupt  = errors.New("snapshot file corrupt")
+	ErrUnsupportVersion = errors.New("unsupported snapshot format version")
+	// ErrUnsupportDialect tables are dumped with COPY of postgres
+	ErrUnsupportDialect = errors.New("snapshot only support postgres database")
+)
+
+// tableModels all tables of metadata, keep in sync with migrations
+var tableModels = []any{
+	(*models.User)(nil),
+	(*models.Repository)(nil),
+	(*models.Branch)(nil),
+	(*models.WorkingInProcess)(nil),
+	(*models.MergeRequest)(nil),
+	(*models.Commit)(nil),
+	(*models.Tag)(nil),
+	(*models.FileTree)(nil),
+	(*models.AkSk)(nil),
+	(*models.Share)(nil),
+	(*models.ShareAccessLog)(nil),
+	(*models.Event)(nil),
+	(*models.HashMapping)(nil),
+	(*models.FileSchema)(nil),
+	(*models.Pack)(nil),
+	(*models.PackedObject)(nil),
+	(*models.AuditLog)(nil),
+	(*models.Member)(nil),
+	(*models.Organization)(nil),
+	(*models.Membership)(nil),
+	(*rbacmodel.Group)(nil),
+	(*rbacmodel.Policy)(nil),
+	(*rbacmodel.UserGroup)(nil),
+}
+
+// Manifest describe content of a snapshot
+type Manifest struct {
+	Version   int       `json:"version"`
+	CreatedAt time.Time `json:"created_at"`
+	Tables    []Table   `json:"tables"`
+	// Objects data in storage referenced by metadata, must be copied along with snapshot
+	Objects []StorageObject `json:"objects"`
+}
+
+// Table dump file of a table
+type Table struct {
+	Name   string `json:"name"`
+	File   string `json:"file"`
+	Rows   int64  `json:"rows"`
+	Sha256 string `json:"sha256"`
+}
+
+// StorageObject data required by blobs of a repository
+type StorageObject struct {
+	RepositoryID     uuid.UUID `json:"repository_id"`
+	StorageNamespace string    `json:"storage_namespace"`
+	Path             string    `json:"path"`
+	Size             int64     `json:"size"`
+}
+
+// Export dump all metadata into dir in a single repeatable read transaction, so tables are consistent with each other
+// at the time of transaction start, and write a manifest listing the storage objects the metadata depends on
+func Export(ctx context.Context, db *bun.DB, dir string) (*Manifest, error) {
+	if db.Dialect().Name() != dialect.PG {
+		return nil, ErrUnsupportDialect
+	}
+
+	err := os.MkdirAll(filepath.Join(dir, tableDir), 0755)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close() //nolint
+
+	_, err = conn.ExecContext(ctx, "BEGIN ISOLATION LEVEL REPEATABLE READ READ ONLY")
+	if err != nil {
+		return nil, err
+	}
+	defer conn.ExecContext(context.Background(), "ROLLBACK") //nolint
+
+	manifest := &Manifest{
+		Version:   FormatVersion,
+		CreatedAt: time.Now(),
+	}
+	for _, model := range tableModels {
+		name := tableName(db, model)
+		table, err := exportTable(ctx, conn, dir, name)
+		if err != nil {
+			return nil, fmt.Errorf("export table %s %w", name, err)
+		}
+		manifest.Tables = append(manifest.Tables, *table)
+	}
+
+	manifest.Objects, err = storageObjects(ctx, conn)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	return manifest, os.WriteFile(filepath.Join(dir, ManifestFileName), data, 0644)
+}
+
+func exportTable(ctx context.Context, conn bun.Conn, dir, name string) (*Table, error) {
+	file := filepath.Join(tableDir, name+".copy")
+	fs, err := os.Create(filepath.Join(dir, file))
+	if err != nil {
+		return nil, err
+	}
+	defer fs.Close() //nolint
+
+	hasher := sha256.New()
+	// partitioned tables can not be copied directly, copy rows of a query instead
+	result, err := pgdriver.CopyTo(ctx, conn, io.MultiWriter(fs, hasher), fmt.Sprintf("COPY (SELECT * FROM %s) TO STDOUT", name))
+	if err != nil {
+		return nil, err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return nil, err
+	}
+	return &Table{
+		Name:   name,
+		File:   file,
+		Rows:   rows,
+		Sha256: hex.EncodeToString(hasher.Sum(nil)),
+	}, fs.Sync()
+}
+
+func storageObjects(ctx context.Context, conn bun.Conn) ([]StorageObject, error) {
+	objects, err := scanStorageObjects(ctx, conn.NewSelect().
+		ColumnExpr("DISTINCT t.repository_id, r.storage_namespace, t.check_sum, t.size").
+		TableExpr("trees AS t").
+		Join("JOIN repositories AS r ON r.id = t.repository_id").
+		Where("t.type = ?", models.BlobObject).
+		Where("NOT EXISTS (SELECT 1 FROM packed_objects AS p WHERE p.repository_id = t.repository_id AND p.check_sum = t.check_sum)"),
+		pathutil.PathOfHash)
+	if err != nil {
+		return nil, err
+	}
+
+	// content of packed blobs is stored in their packs
+	packs, err := scanStorageObjects(ctx, conn.NewSelect().
+		ColumnExpr("p.repository_id, r.storage_namespace, p.check_sum, p.size").
+		TableExpr("packs AS p").
+		Join("JOIN repositories AS r ON r.id = p.repository_id"),
+		pathutil.PathOfPack)
+	if err != nil {
+		return nil, err
+	}
+	return append(objects, packs...), nil
+}
+
+// scanStorageObjects read repository id, storage namespace, checksum and size selected by query
+func scanStorageObjects(ctx context.Context, query *bun.SelectQuery, pathOf func(hash.Hash) string) ([]StorageObject, error) {
+	rows, err := query.Rows(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close() //nolint
+
+	objects := make([]StorageObject, 0)
+	for rows.Next() {
+		var obj StorageObject
+		var namespace sql.NullString
+		var checkSum []byte
+		err = rows.Scan(&obj.RepositoryID, &namespace, &checkSum, &obj.Size)
+		if err != nil {
+			return nil, err
+		}
+		obj.StorageNamespace = namespace.String
+		obj.Path = pathOf(hash.Hash(checkSum))
+		objects = append(objects, obj)
+	}
+	return objects, rows.Err()
+}
+
+// ReadManifest load manifest of snapshot in dir and check every table file against its checksum
+func ReadManifest(dir string) (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(dir, ManifestFileName))
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{}
+	err = json.Unmarshal(data, manifest)
+	if err != nil {
+		return nil, err
+	}
+	if manifest.Version != FormatVersion {
+		return nil, fmt.Errorf("%w %d", ErrUnsupportVersion, manifest.Version)
+	}
+
+	for _, table := range manifest.Tables {
+		sum, err := fileSha256(filepath.Join(dir, table.File))
+		if err != nil {
+			return nil, err
+		}
+		if sum != table.Sha256 {
+			return nil, fmt.Errorf("table %s checksum mismatch %w", table.Name, ErrSnapshotCorrupt)
+		}
+	}
+	return manifest, nil
+}
+
+func fileSha256(path string) (string, error) {
+	fs, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer fs.Close() //nolint
+
+	hasher := sha256.New()
+	_, err = io.Copy(hasher, fs)
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// Import load snapshot in dir into db in a single transaction, db must be migrated and contain no data
+func Import(ctx context.Context, db *bun.DB, dir string) (*Manifest, error) {
+	if db.Dialect().Name() != dialect.PG {
+		return nil, ErrUnsupportDialect
+	}
+
+	manifest, err := ReadManifest(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close() //nolint
+
+	_, err = conn.ExecContext(ctx, "BEGIN")
+	if err != nil {
+		return nil, err
+	}
+	committed := false
+	defer func() {
+		if !committed {
+			_, _ = conn.ExecContext(context.Background(), "ROLLBACK")
+		}
+	}()
+
+	for _, model := range tableModels {
+		exists, err := conn.NewSelect().Model(model).Exists(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return nil, fmt.Errorf("table %s has data %w", tableName(db, model), ErrTargetNotEmpty)
+		}
+	}
+
+	for _, table := range manifest.Tables {
+		err = importTable(ctx, conn, dir, table)
+		if err != nil {
+			return nil, fmt.Errorf("import table %s %w", table.Name, err)
+		}
+	}
+
+	// continue event sequence after imported events
+	_, err = conn.ExecContext(ctx, "SELECT setval(pg_get_serial_sequence('events', 'seq'), COALESCE(MAX(seq), 0) + 1, false) FROM events")
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = conn.ExecContext(ctx, "COMMIT")
+	if err != nil {
+		return nil, err
+	}
+	committed = true
+	return manifest, nil
+}
+
+func importTable(ctx context.Context, conn bun.Conn, dir string, table Table) error {
+	fs, err := os.Open(filepath.Join(dir, table.File))
+	if err != nil {
+		return err
+	}
+	defer fs.Close() //nolint
+
+	result, err := pgdriver.CopyFrom(ctx, conn, fs, fmt.Sprintf("COPY %s FROM STDIN", table.Name))
+	if err != nil {
+		return err
+	}
+	rows, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rows != table.Rows {
+		return fmt.Errorf("expect %d rows but got %d %w", table.Rows, rows, ErrSnapshotCorrupt)
+	}
+	return nil
+}
+
+// VerifyStorage check every object in manifest exist in storage, adapterOf return the adapter of repository. missing objects
+// are returned
+func VerifyStorage(ctx context.Context, manifest *Manifest, adapterOf func(ctx context.Context, repositoryID uuid.UUID) (block.Adapter, error)) ([]StorageObject, error) {
+	adapters := make(map[uuid.UUID]block.Adapter)
+	var missing []StorageObject
+	for _, obj := range manifest.Objects {
+		adapter, ok := adapters[obj.RepositoryID]
+		if !ok {
+			var err error
+			adapter, err = adapterOf(ctx, obj.RepositoryID)
+			if err != nil {
+				return nil, err
+			}
+			adapters[obj.RepositoryID] = adapter
+		}
+
+		exists, err := adapter.Exists(ctx, block.ObjectPointer{
+			StorageNamespace: obj.StorageNamespace,
+			IdentifierType:   block.IdentifierTypeRelative,
+			Identifier:       obj.Path,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			log.Warnf("object %s of repository %s is missing", obj.Path, obj.RepositoryID)
+			missing = append(missing, obj)
+		}
+	}
+	return missing, nil
+}
+
+func tableName(db *bun.DB, model any) string {
+	return db.Dialect().Tables().Get(reflect.TypeOf(model).Elem()).Name
+}
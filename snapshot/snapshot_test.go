@@ -0,0 +1,73 @@
+package snapshot
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/utils/pathutil"
+	"github.com/brianvoe/gofakeit/v6"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	closeSrc, _, srcDB := testhelper.SetupDatabase(ctx, t)
+	defer closeSrc()
+
+	srcRepo := models.NewRepo(srcDB)
+	repoModel := &models.Repository{}
+	require.NoError(t, gofakeit.Struct(repoModel))
+	repoModel.ID = uuid.Nil
+	repository, err := srcRepo.RepositoryRepo().Insert(ctx, repoModel)
+	require.NoError(t, err)
+
+	checkSum := hash.Hash("0123456789abcdef")
+	blob, err := models.NewBlob(models.DefaultLeafProperty(), repository.ID, checkSum, 16)
+	require.NoError(t, err)
+	_, err = srcRepo.FileTreeRepo(repository.ID).Insert(ctx, blob.FileTree())
+	require.NoError(t, err)
+
+	for i := 0; i < 3; i++ {
+		_, err = srcRepo.EventRepo().Insert(ctx, models.NewEvent(models.CommitCreatedEvent, repository.ID, uuid.New(), nil))
+		require.NoError(t, err)
+	}
+
+	dir := t.TempDir()
+	manifest, err := Export(ctx, srcDB, dir)
+	require.NoError(t, err)
+	require.Len(t, manifest.Tables, len(tableModels))
+	require.Len(t, manifest.Objects, 1)
+	require.Equal(t, pathutil.PathOfHash(checkSum), manifest.Objects[0].Path)
+	require.Equal(t, repository.StorageNamespace, &manifest.Objects[0].StorageNamespace)
+
+	t.Run("import", func(t *testing.T) {
+		closeDst, _, dstDB := testhelper.SetupDatabase(ctx, t)
+		defer closeDst()
+
+		_, err := Import(ctx, dstDB, dir)
+		require.NoError(t, err)
+
+		dstRepo := models.NewRepo(dstDB)
+		_, err = dstRepo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetID(repository.ID))
+		require.NoError(t, err)
+
+		event, err := dstRepo.EventRepo().Insert(ctx, models.NewEvent(models.CommitCreatedEvent, repository.ID, uuid.New(), nil))
+		require.NoError(t, err)
+		require.Equal(t, int64(4), event.Seq)
+
+		_, err = Import(ctx, dstDB, dir)
+		require.ErrorIs(t, err, ErrTargetNotEmpty)
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, manifest.Tables[0].File), []byte("broken"), 0644))
+		_, err := ReadManifest(dir)
+		require.ErrorIs(t, err, ErrSnapshotCorrupt)
+	})
+}
@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/GitDataAI/jiaozifs/config"
@@ -36,13 +37,32 @@ func SetupDatabase(ctx context.Context, t *testing.T) (CloseFunc, string, *bun.D
 	require.NoError(t, err)
 
 	connStr := fmt.Sprintf(TestConnTmpl, port)
-	db, err := models.SetupDatabase(ctx, fxtest.NewLifecycle(t), &config.DatabaseConfig{Debug: true, Connection: connStr})
+	dbCfg := &config.DatabaseConfig{Debug: true, Connection: connStr, ObjectPartitions: 4}
+	db, err := models.SetupDatabase(ctx, fxtest.NewLifecycle(t), dbCfg)
 	require.NoError(t, err)
 
-	err = migrations.MigrateDatabase(ctx, db)
+	err = migrations.MigrateDatabase(ctx, db, dbCfg)
 	require.NoError(t, err)
 	return func() {
 		require.NoError(t, postgres.Stop())
 		require.NoError(t, os.RemoveAll(tmpDir))
 	}, connStr, db
 }
+
+// SetupSQLiteDatabase create a migrated sqlite database in temp dir
+func SetupSQLiteDatabase(ctx context.Context, t *testing.T) (CloseFunc, string, *bun.DB) {
+	tmpDir, err := os.MkdirTemp(os.TempDir(), "*")
+	require.NoError(t, err)
+
+	connStr := filepath.Join(tmpDir, "jiaozifs.db")
+	dbCfg := &config.DatabaseConfig{Type: config.DatabaseTypeSQLite, Debug: true, Connection: connStr}
+	db, err := models.SetupDatabase(ctx, fxtest.NewLifecycle(t), dbCfg)
+	require.NoError(t, err)
+
+	err = migrations.MigrateDatabase(ctx, db, dbCfg)
+	require.NoError(t, err)
+	return func() {
+		require.NoError(t, db.Close())
+		require.NoError(t, os.RemoveAll(tmpDir))
+	}, connStr, db
+}
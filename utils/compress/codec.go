@@ -0,0 +1,169 @@
+package compress
+
+import (
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Codec content coding of http bodies, Name is the token used in Content-Encoding and Accept-Encoding
+type Codec interface {
+	Name() string
+	// Priority preference of codec when client accepts several with same quality, higher first
+	Priority() int
+	NewReader(r io.Reader) (io.ReadCloser, error)
+	NewWriter(w io.Writer) (io.WriteCloser, error)
+}
+
+var (
+	codecsLk sync.RWMutex
+	codecs   = map[string]Codec{}
+)
+
+// Register make codec available to negotiation, codec of same name is replaced
+func Register(codec Codec) {
+	codecsLk.Lock()
+	defer codecsLk.Unlock()
+	codecs[strings.ToLower(codec.Name())] = codec
+}
+
+// Lookup return codec of content coding name
+func Lookup(name string) (Codec, bool) {
+	codecsLk.RLock()
+	defer codecsLk.RUnlock()
+	codec, ok := codecs[strings.ToLower(strings.TrimSpace(name))]
+	return codec, ok
+}
+
+// Names of registered codecs ordered by priority, used to build Accept-Encoding
+func Names() []string {
+	codecsLk.RLock()
+	defer codecsLk.RUnlock()
+	all := make([]Codec, 0, len(codecs))
+	for _, codec := range codecs {
+		all = append(all, codec)
+	}
+	sort.Slice(all, func(i, j int) bool {
+		if all[i].Priority() != all[j].Priority() {
+			return all[i].Priority() > all[j].Priority()
+		}
+		return all[i].Name() < all[j].Name()
+	})
+	names := make([]string, len(all))
+	for i, codec := range all {
+		names[i] = codec.Name()
+	}
+	return names
+}
+
+// Negotiate pick codec for response from Accept-Encoding header, nil means response is sent as is. codings with
+// q=0 are refused, "*" stands for any registered codec
+func Negotiate(acceptEncoding string) Codec {
+	if len(acceptEncoding) == 0 {
+		return nil
+	}
+
+	var (
+		best        Codec
+		bestQuality float64
+		refused     = map[string]bool{}
+		wildcard    = -1.0
+	)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		name, quality := parseCoding(part)
+		if name == "*" {
+			wildcard = quality
+			continue
+		}
+		if quality <= 0 {
+			refused[name] = true
+			continue
+		}
+		codec, ok := Lookup(name)
+		if !ok {
+			continue
+		}
+		if better(codec, quality, best, bestQuality) {
+			best, bestQuality = codec, quality
+		}
+	}
+
+	if wildcard > 0 {
+		for _, name := range Names() {
+			if refused[name] {
+				continue
+			}
+			codec, _ := Lookup(name)
+			if better(codec, wildcard, best, bestQuality) {
+				best, bestQuality = codec, wildcard
+			}
+		}
+	}
+	return best
+}
+
+func better(codec Codec, quality float64, best Codec, bestQuality float64) bool {
+	if best == nil || quality > bestQuality {
+		return true
+	}
+	return quality == bestQuality && codec.Priority() > best.Priority()
+}
+
+func parseCoding(part string) (string, float64) {
+	name, params, _ := strings.Cut(part, ";")
+	quality := 1.0
+	for _, param := range strings.Split(params, ";") {
+		key, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+		if !ok || strings.TrimSpace(key) != "q" {
+			continue
+		}
+		q, err := strconv.ParseFloat(strings.TrimSpace(value), 64)
+		if err == nil {
+			quality = q
+		}
+	}
+	return strings.ToLower(strings.TrimSpace(name)), quality
+}
+
+type gzipCodec struct{}
+
+func (gzipCodec) Name() string { return "gzip" }
+
+func (gzipCodec) Priority() int { return 1 }
+
+func (gzipCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	return gzip.NewReader(r)
+}
+
+func (gzipCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return gzip.NewWriterLevel(w, gzip.DefaultCompression)
+}
+
+// zstdCodec compress better and faster than gzip, preferred if client support it
+type zstdCodec struct{}
+
+func (zstdCodec) Name() string { return "zstd" }
+
+func (zstdCodec) Priority() int { return 2 }
+
+func (zstdCodec) NewReader(r io.Reader) (io.ReadCloser, error) {
+	decoder, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+	if err != nil {
+		return nil, err
+	}
+	return decoder.IOReadCloser(), nil
+}
+
+func (zstdCodec) NewWriter(w io.Writer) (io.WriteCloser, error) {
+	return zstd.NewWriter(w, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedDefault))
+}
+
+func init() {
+	Register(gzipCodec{})
+	Register(zstdCodec{})
+}
@@ -0,0 +1,155 @@
+package compress
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	cases := []struct {
+		accept string
+		expect string
+	}{
+		{accept: "", expect: ""},
+		{accept: "gzip", expect: "gzip"},
+		{accept: "gzip, zstd", expect: "zstd"},
+		{accept: "gzip;q=1.0, zstd;q=0.5", expect: "gzip"},
+		{accept: "zstd;q=0, gzip", expect: "gzip"},
+		{accept: "br, deflate", expect: ""},
+		{accept: "*", expect: "zstd"},
+		{accept: "*, zstd;q=0", expect: "gzip"},
+		{accept: "identity", expect: ""},
+	}
+	for _, c := range cases {
+		codec := Negotiate(c.accept)
+		if len(c.expect) == 0 {
+			require.Nil(t, codec, c.accept)
+			continue
+		}
+		require.NotNil(t, codec, c.accept)
+		require.Equal(t, c.expect, codec.Name(), c.accept)
+	}
+	require.Equal(t, []string{"zstd", "gzip"}, Names())
+}
+
+func encode(t *testing.T, codec Codec, data []byte) []byte {
+	buf := &bytes.Buffer{}
+	writer, err := codec.NewWriter(buf)
+	require.NoError(t, err)
+	_, err = writer.Write(data)
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	return buf.Bytes()
+}
+
+func TestMiddleware(t *testing.T) {
+	payload := `{"items":[` + strings.Repeat(`{"name":"a.txt","size":1},`, 200) + `{}]}`
+	handler := Middleware(int64(len(payload)))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			w.Header().Set("Content-Type", "application/json")
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(body) > 0 {
+			require.Equal(t, payload, string(body))
+		}
+		switch r.URL.Path {
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(payload))
+		case "/binary":
+			w.Header().Set("Content-Type", "application/octet-stream")
+			_, _ = w.Write([]byte(payload))
+		case "/empty":
+			w.WriteHeader(http.StatusNoContent)
+		}
+	}))
+	server := httptest.NewServer(handler)
+	defer server.Close()
+
+	request := func(method, path, accept, contentEncoding string, body []byte) *http.Response {
+		req, err := http.NewRequest(method, server.URL+path, bytes.NewReader(body))
+		require.NoError(t, err)
+		req.Header.Set("Accept-Encoding", accept)
+		if len(contentEncoding) > 0 {
+			req.Header.Set("Content-Encoding", contentEncoding)
+		}
+		resp, err := http.DefaultTransport.RoundTrip(req)
+		require.NoError(t, err)
+		return resp
+	}
+
+	for _, name := range []string{"gzip", "zstd"} {
+		t.Run("compress json "+name, func(t *testing.T) {
+			codec, _ := Lookup(name)
+			resp := request(http.MethodGet, "/json", name, "", nil)
+			defer resp.Body.Close() //nolint
+			require.Equal(t, name, resp.Header.Get("Content-Encoding"))
+			require.Contains(t, resp.Header.Values("Vary"), "Accept-Encoding")
+
+			reader, err := codec.NewReader(resp.Body)
+			require.NoError(t, err)
+			data, err := io.ReadAll(reader)
+			require.NoError(t, err)
+			require.Equal(t, payload, string(data))
+		})
+
+		t.Run("decode upload "+name, func(t *testing.T) {
+			codec, _ := Lookup(name)
+			resp := request(http.MethodPost, "/json", "", name, encode(t, codec, []byte(payload)))
+			defer resp.Body.Close() //nolint
+			require.Equal(t, http.StatusOK, resp.StatusCode)
+			require.Empty(t, resp.Header.Get("Content-Encoding"))
+		})
+	}
+
+	t.Run("decoded body too large", func(t *testing.T) {
+		codec, _ := Lookup("zstd")
+		resp := request(http.MethodPost, "/json", "", "zstd", encode(t, codec, []byte(payload+" ")))
+		defer resp.Body.Close() //nolint
+		require.Equal(t, http.StatusRequestEntityTooLarge, resp.StatusCode)
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Contains(t, string(data), ErrBodyTooLarge.Error())
+	})
+
+	t.Run("binary not compressed", func(t *testing.T) {
+		resp := request(http.MethodGet, "/binary", "zstd", "", nil)
+		defer resp.Body.Close() //nolint
+		require.Empty(t, resp.Header.Get("Content-Encoding"))
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, payload, string(data))
+	})
+
+	t.Run("no content", func(t *testing.T) {
+		resp := request(http.MethodGet, "/empty", "gzip", "", nil)
+		defer resp.Body.Close() //nolint
+		require.Equal(t, http.StatusNoContent, resp.StatusCode)
+		require.Empty(t, resp.Header.Get("Content-Encoding"))
+	})
+
+	t.Run("unknown encoding", func(t *testing.T) {
+		resp := request(http.MethodPost, "/json", "", "br", []byte(payload))
+		defer resp.Body.Close() //nolint
+		require.Equal(t, http.StatusUnsupportedMediaType, resp.StatusCode)
+	})
+
+	t.Run("transport", func(t *testing.T) {
+		client := &http.Client{Transport: NewTransport(nil)}
+		resp, err := client.Get(server.URL + "/json")
+		require.NoError(t, err)
+		defer resp.Body.Close() //nolint
+		require.True(t, resp.Uncompressed)
+		data, err := io.ReadAll(resp.Body)
+		require.NoError(t, err)
+		require.Equal(t, payload, string(data))
+	})
+}
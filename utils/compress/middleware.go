@@ -0,0 +1,235 @@
+package compress
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// compressibleTypes media types worth compressing, binary object content is usually compressed already
+var compressibleTypes = []string{
+	"application/json",
+	"application/x-ndjson",
+	"application/xml",
+	"application/yaml",
+	"application/x-yaml",
+	"text/",
+}
+
+func compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	for _, t := range compressibleTypes {
+		if mediaType == t || (strings.HasSuffix(t, "/") && strings.HasPrefix(mediaType, t)) || strings.HasSuffix(mediaType, "+json") {
+			return true
+		}
+	}
+	return false
+}
+
+// ErrBodyTooLarge decoded request body is larger than limit of Middleware
+var ErrBodyTooLarge = errors.New("decoded request body too large")
+
+// Middleware decode request body of registered Content-Encoding and compress text responses with codec negotiated
+// from Accept-Encoding. request of unknown encoding is rejected with 415, request decoded to more than maxDecodedSize
+// bytes is rejected with 413 whatever the handler responds, zero maxDecodedSize means unlimited
+func Middleware(maxDecodedSize int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var body *limitedBody
+			if encoding := r.Header.Get("Content-Encoding"); len(encoding) > 0 && !strings.EqualFold(encoding, "identity") {
+				codec, ok := Lookup(encoding)
+				if !ok {
+					http.Error(w, fmt.Sprintf("unsupported content encoding %s", encoding), http.StatusUnsupportedMediaType)
+					return
+				}
+				reader, err := codec.NewReader(r.Body)
+				if err != nil {
+					http.Error(w, fmt.Sprintf("invalid %s body %v", encoding, err), http.StatusBadRequest)
+					return
+				}
+				defer reader.Close() //nolint
+				r.Body = reader
+				if maxDecodedSize > 0 {
+					body = &limitedBody{ReadCloser: reader, remaining: maxDecodedSize}
+					r.Body = body
+				}
+				r.Header.Del("Content-Encoding")
+				r.Header.Del("Content-Length")
+				// size of decoded body is unknown until read
+				r.ContentLength = -1
+			}
+
+			w.Header().Add("Vary", "Accept-Encoding")
+			codec := Negotiate(r.Header.Get("Accept-Encoding"))
+			if codec != nil && r.Method != http.MethodHead {
+				cw := &compressWriter{ResponseWriter: w, codec: codec}
+				defer cw.Close() //nolint
+				w = cw
+			}
+			if body != nil {
+				w = &tooLargeWriter{ResponseWriter: w, body: body}
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// limitedBody fail reads with ErrBodyTooLarge once more than remaining bytes are decoded
+type limitedBody struct {
+	io.ReadCloser
+	remaining int64
+	exceeded  bool
+}
+
+func (body *limitedBody) Read(p []byte) (int, error) {
+	if body.exceeded {
+		return 0, ErrBodyTooLarge
+	}
+	// read one byte more than remaining to tell body ending at limit from body going over it
+	if int64(len(p)) > body.remaining+1 {
+		p = p[:body.remaining+1]
+	}
+	n, err := body.ReadCloser.Read(p)
+	if int64(n) > body.remaining {
+		body.exceeded = true
+		return int(body.remaining), ErrBodyTooLarge
+	}
+	body.remaining -= int64(n)
+	return n, err
+}
+
+// tooLargeWriter replace response of handler with 413 when handler read past limit of body, handlers report body
+// errors in their own way, mostly as 400
+type tooLargeWriter struct {
+	http.ResponseWriter
+	body *limitedBody
+
+	wroteHeader bool
+	discard     bool
+}
+
+func (tw *tooLargeWriter) WriteHeader(statusCode int) {
+	if tw.wroteHeader {
+		return
+	}
+	tw.wroteHeader = true
+	if !tw.body.exceeded {
+		tw.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+
+	tw.discard = true
+	tw.Header().Del("Content-Length")
+	http.Error(tw.ResponseWriter, ErrBodyTooLarge.Error(), http.StatusRequestEntityTooLarge)
+}
+
+func (tw *tooLargeWriter) Write(p []byte) (int, error) {
+	if !tw.wroteHeader {
+		tw.WriteHeader(http.StatusOK)
+	}
+	if tw.discard {
+		return len(p), nil
+	}
+	return tw.ResponseWriter.Write(p)
+}
+
+func (tw *tooLargeWriter) Flush() {
+	if flusher, ok := tw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (tw *tooLargeWriter) Unwrap() http.ResponseWriter {
+	return tw.ResponseWriter
+}
+
+// compressWriter decide on first write whether response is compressed, as content type and status are known then
+type compressWriter struct {
+	http.ResponseWriter
+	codec Codec
+
+	wroteHeader bool
+	encoder     io.WriteCloser
+}
+
+func (cw *compressWriter) WriteHeader(statusCode int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	header := cw.Header()
+	if cw.shouldCompress(statusCode, header) {
+		encoder, err := cw.codec.NewWriter(cw.ResponseWriter)
+		if err == nil {
+			cw.encoder = encoder
+			header.Set("Content-Encoding", cw.codec.Name())
+			header.Del("Content-Length")
+		}
+	}
+	cw.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (cw *compressWriter) shouldCompress(statusCode int, header http.Header) bool {
+	switch statusCode {
+	case http.StatusNoContent, http.StatusPartialContent, http.StatusNotModified:
+		return false
+	}
+	if statusCode < http.StatusOK {
+		return false
+	}
+	if len(header.Get("Content-Encoding")) > 0 || len(header.Get("Content-Range")) > 0 {
+		return false
+	}
+	return compressible(header.Get("Content-Type"))
+}
+
+func (cw *compressWriter) Write(p []byte) (int, error) {
+	if !cw.wroteHeader {
+		if len(cw.Header().Get("Content-Type")) == 0 {
+			cw.Header().Set("Content-Type", http.DetectContentType(p))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.encoder != nil {
+		return cw.encoder.Write(p)
+	}
+	return cw.ResponseWriter.Write(p)
+}
+
+// Flush push compressed data written so far to client, streamed responses keep working
+func (cw *compressWriter) Flush() {
+	if flusher, ok := cw.encoder.(interface{ Flush() error }); ok {
+		_ = flusher.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (cw *compressWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hijacker, ok := cw.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, fmt.Errorf("response writer can not be hijacked")
+	}
+	return hijacker.Hijack()
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+func (cw *compressWriter) Close() error {
+	if cw.encoder == nil {
+		return nil
+	}
+	return cw.encoder.Close()
+}
@@ -0,0 +1,64 @@
+package compress
+
+import (
+	"io"
+	"net/http"
+	"strings"
+)
+
+// Transport advertise registered codecs in Accept-Encoding and decode compressed responses, so callers always read
+// plain bodies. request whose Accept-Encoding is set by caller is left alone
+type Transport struct {
+	Base http.RoundTripper
+}
+
+func NewTransport(base http.RoundTripper) *Transport {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &Transport{Base: base}
+}
+
+func (t *Transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if len(req.Header.Get("Accept-Encoding")) > 0 || req.Method == http.MethodHead {
+		return t.Base.RoundTrip(req)
+	}
+
+	req = req.Clone(req.Context())
+	req.Header.Set("Accept-Encoding", strings.Join(Names(), ", "))
+	resp, err := t.Base.RoundTrip(req)
+	if err != nil {
+		return nil, err
+	}
+
+	encoding := resp.Header.Get("Content-Encoding")
+	if len(encoding) == 0 {
+		return resp, nil
+	}
+	codec, ok := Lookup(encoding)
+	if !ok {
+		return resp, nil
+	}
+	reader, err := codec.NewReader(resp.Body)
+	if err != nil {
+		_ = resp.Body.Close()
+		return nil, err
+	}
+	resp.Body = &decodedBody{ReadCloser: reader, raw: resp.Body}
+	resp.Header.Del("Content-Encoding")
+	resp.Header.Del("Content-Length")
+	resp.ContentLength = -1
+	resp.Uncompressed = true
+	return resp, nil
+}
+
+// decodedBody close decoder and the compressed body under it
+type decodedBody struct {
+	io.ReadCloser
+	raw io.ReadCloser
+}
+
+func (body *decodedBody) Close() error {
+	_ = body.ReadCloser.Close()
+	return body.raw.Close()
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/md5" //nolint:gosec
 	"crypto/sha256"
 	"encoding/binary"
+	"fmt"
 	"hash"
 	"io"
 	"strconv"
@@ -16,6 +17,27 @@ const (
 	SHA256
 )
 
+func (hashType HashType) String() string {
+	switch hashType {
+	case Md5:
+		return "md5"
+	case SHA256:
+		return "sha256"
+	}
+	return "unknown"
+}
+
+// ParseHashType convert name of hash algorithm to HashType
+func ParseHashType(name string) (HashType, error) {
+	switch name {
+	case "md5":
+		return Md5, nil
+	case "sha256":
+		return SHA256, nil
+	}
+	return Md5, fmt.Errorf("unsupported hash type %s", name)
+}
+
 type Hasher struct {
 	Md5    hash.Hash
 	Sha256 hash.Hash
@@ -40,6 +62,18 @@ func NewHasher(hashTypes ...HashType) *Hasher {
 	return s
 }
 
+// Sum return digest of hashType, hasher must be created with this hash type
+func (hasher *Hasher) Sum(hashType HashType) Hash {
+	switch hashType {
+	case Md5:
+		return hasher.Md5.Sum(nil)
+	case SHA256:
+		return hasher.Sha256.Sum(nil)
+	default:
+		panic("wrong hash type number " + strconv.Itoa(int(hashType)))
+	}
+}
+
 func (hasher *Hasher) Write(data []byte) (int, error) {
 	if hasher.Md5 != nil {
 		if _, err := hasher.Md5.Write(data); err != nil {
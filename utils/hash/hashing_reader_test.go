@@ -66,3 +66,23 @@ func TestHashingReader_Read(t *testing.T) {
 	sha256Hash = hashReader.Sha256.Sum(nil)
 	require.Equal(t, "32bbe378a25091502b2baf9f7258c19444e7a43ee4593b08030acd790bd66e6a", hex.EncodeToString(sha256Hash))
 }
+
+func TestParseHashType(t *testing.T) {
+	for _, hashType := range []HashType{Md5, SHA256} {
+		parsed, err := ParseHashType(hashType.String())
+		require.NoError(t, err)
+		require.Equal(t, hashType, parsed)
+	}
+
+	_, err := ParseHashType("sha1")
+	require.Error(t, err)
+}
+
+func TestHasher_Sum(t *testing.T) {
+	hasher := NewHasher(Md5, SHA256)
+	_, err := hasher.Write([]byte{1, 2, 3, 4, 5})
+	require.NoError(t, err)
+
+	require.Equal(t, "7cfdd07889b3295d6a550914ab35e068", hasher.Sum(Md5).Hex())
+	require.Equal(t, "74f81fe167d99b4cb41d6d0ccda82278caee9f3e2f25d5e5a3936ff3dcec60d0", hasher.Sum(SHA256).Hex())
+}
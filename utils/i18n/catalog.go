@@ -0,0 +1,144 @@
+package i18n
+
+// catalogs translations of messages, key is the english message or format used in code.
+// messages not in catalog are returned as is
+var catalogs = map[Lang]map[string]string{
+	English: enCatalog,
+	Chinese: zhCatalog,
+}
+
+// enCatalog messages of database drivers which are not meaningful to users
+var enCatalog = map[string]string{
+	"sql: no rows in result set": "not found",
+}
+
+var zhCatalog = map[string]string{
+	// api errors
+	"sql: no rows in result set":                                      "资源不存在",
+	"ref has been updated by others, please retry":                    "引用已被他人更新，请重试",
+	"object has been updated by others, please reload and retry":      "对象已被他人更新，请重新获取后重试",
+	"base commit not equal with branch, please update wip":            "工作区基准提交与分支不一致，请先更新工作区",
+	"working repo not in wip state":                                   "当前不在工作区状态",
+	"working repo not in branch state":                                "当前不在分支状态",
+	"must commit changes on branch":                                   "只能在分支上提交变更",
+	"must merge on branch":                                            "只能在分支上合并",
+	"only create wip from branch":                                     "只能基于分支创建工作区",
+	"no commit to create tag":                                         "没有可用于创建标签的提交",
+	"empty commit to create tag":                                      "不能基于空提交创建标签",
+	"not support":                                                     "不支持",
+	"invalid basehead must be base...head":                            "basehead 格式错误，应为 base...head",
+	"can not delete HEAD branch":                                      "不能删除默认分支",
+	"not need to invite self":                                         "无需邀请自己",
+	"storage config not json format":                                  "存储配置不是合法的 JSON",
+	"expires in must be positive":                                     "有效期必须为正数",
+	"credentials must be valid for at least %d seconds":               "凭证有效期至少为 %d 秒",
+	"storage credentials only supported for s3 storage":               "仅 s3 存储支持获取存储凭证",
+	"assume role of s3 storage not configured":                        "s3 存储未配置 assume role",
+	"repository %s is in trash, restore or purge it first":            "仓库 %s 在回收站中，请先恢复或彻底删除",
+	"storage operation %s not support":                                "不支持存储操作 %s",
+	"prefix %s out of storage namespace":                              "前缀 %s 超出存储命名空间",
+	"upload session has no part":                                      "上传会话中没有已上传的分片",
+	"private prefix must not be empty":                                "私有路径前缀不能为空",
+	"masking rule path must not be empty":                             "脱敏规则路径不能为空",
+	"invalid masking rule path %s":                                    "无效的脱敏规则路径 %s",
+	"masking rule of %s has no column":                                "%s 的脱敏规则没有指定列",
+	"unsupported masking method %s":                                   "不支持的脱敏方式 %s",
+	"schema history ref type (%s) only allow branch, tag and commit":  "结构历史引用类型 (%s) 只能是分支、标签或提交",
+	"path %s is not a csv or jsonl file":                              "路径 %s 不是 csv 或 jsonl 文件",
+	"no merge driver registered for path %s":                          "路径 %s 没有注册合并驱动",
+	"path %s is too large to compare content":                         "路径 %s 文件过大，无法比较内容",
+	"pack threshold must be positive":                                 "打包阈值必须为正数",
+	"fork of private repository must be private":                      "私有仓库的分叉必须是私有的",
+	"unknown orphan action %s":                                        "未知的孤立文件处理方式 %s",
+	"invalid ref prefix: only numbers, letters or underscores":        "无效的引用前缀：只能包含数字、字母或下划线",
+	"can not roll back to a time before repository was created":       "不能回滚到仓库创建之前的时间",
+	"merge rule branch must not be empty":                             "合并规则的分支不能为空",
+	"invalid merge rule branch %s":                                    "无效的合并规则分支 %s",
+	"hook name must be unique and not empty":                          "钩子名称不能为空且必须唯一",
+	"invalid branch %s of hook %s":                                    "钩子 %[2]s 的分支 %[1]s 无效",
+	"hook %s has no event":                                            "钩子 %s 没有事件",
+	"unsupported event %s of hook %s":                                 "钩子 %[2]s 不支持事件 %[1]s",
+	"hook %s has no path":                                             "钩子 %s 没有路径",
+	"invalid path %s of hook %s":                                      "钩子 %[2]s 的路径 %[1]s 无效",
+	"hook %s has no metadata key":                                     "钩子 %s 没有元数据键",
+	"unsupported hook type %s":                                        "不支持的钩子类型 %s",
+	"no commit to amend in branch %s":                                 "分支 %s 上没有可修改的提交",
+	"author can not approve own merge request":                        "作者不能批准自己的合并请求",
+	"checklist item %s not found":                                     "检查项 %s 不存在",
+	"size must be between 0 and %d":                                   "大小必须介于 0 到 %d 之间",
+	"merge request %d is not open":                                    "合并请求 %d 未处于打开状态",
+	"comment body must not be empty":                                  "评论内容不能为空",
+	"only root comment of thread can be resolved":                     "只有讨论的首条评论可以被解决",
+	"merge rule of target branch is not met":                          "未满足目标分支的合并规则",
+	"rejected by hook of repository":                                  "被仓库钩子拒绝",
+	"invalid issue prefix %s":                                         "无效的问题前缀 %s",
+	"invalid issue url template %s":                                   "无效的问题链接模板 %s",
+	"issue %s does not match any issue link":                          "问题 %s 不匹配任何问题链接",
+	"database is temporarily unavailable, please retry later":         "数据库暂时不可用，请稍后重试",
+	"object already exists":                                           "对象已存在",
+	"object refer to missing object or is referred by others":         "对象引用了不存在的对象或正被其他对象引用",
+	"invalid value of object":                                         "对象的值无效",
+	"operation %d is invalid, %v":                                     "第 %d 个操作无效，%v",
+	"required approvals must not be negative":                         "所需批准数不能为负数",
+	"branch is protected":                                             "分支受保护",
+	"repository is archived":                                          "仓库已归档",
+	"access denied by access token":                                   "访问令牌拒绝访问",
+	"attestation name must not be empty":                              "证明名称不能为空",
+	"invalid attestation status %s":                                   "无效的证明状态 %s",
+	"invalid commit hash %s":                                          "无效的提交哈希 %s",
+	"invalid sort %s %s":                                              "无效的排序 %s %s",
+	"grace period must not be negative":                               "保留时间不能为负数",
+	"unsupported tag type %s":                                         "不支持的标签类型 %s",
+	"path %s not found":                                               "路径 %s 不存在",
+	"username %s or email %s not found ":                              "用户名 %s 或邮箱 %s 不存在",
+	"invalid commit message template %v":                              "提交信息模板无效 %v",
+	"share ref type (%s) only allow branch and tag":                   "分享引用类型 (%s) 只能是分支或标签",
+	"archive ref type (%s) only allow branch, tag and commit":         "归档引用类型 (%s) 只能是分支、标签或提交",
+	"archive path not support car files":                              "car 格式归档不支持指定路径",
+	"file log ref type (%s) only allow branch, tag and commit":        "文件历史引用类型 (%s) 只能是分支、标签或提交",
+	"commits ref type (%s) only allow branch, tag and commit":         "提交历史引用类型 (%s) 只能是分支、标签或提交",
+	"partitions ref type (%s) only allow branch, tag and commit":      "分区引用类型 (%s) 只能是分支、标签或提交",
+	"partition filter %s must be like year>=2023 or region=us|eu":     "分区过滤条件 %s 格式应类似 year>=2023 或 region=us|eu",
+	"path %s must be a directory":                                     "路径 %s 必须是目录",
+	"repository %s limit %d exceeded, retry after %s":                 "仓库 %s 限制 %d 已超出，请在 %s 后重试",
+	"limits must not be negative":                                     "限制不能为负数",
+	"metadata %s must be in key=value format":                         "元数据 %s 格式应为 key=value",
+	"source branch name %s and target branch name %s can not be same": "源分支 %s 与目标分支 %s 不能相同",
+	"repo %s merge request between %s and %s already exists":          "仓库 %s 中 %s 与 %s 之间的合并请求已存在",
+
+	"commit is too large, split changes into smaller commits or use bulk import":    "提交过大，请拆分为多个较小的提交或使用批量导入",
+	"storage quota of repository exceeded, remove data or ask admin to raise quota": "仓库存储配额已用尽，请删除数据或联系管理员提高配额",
+
+	// cli
+	"Files dected, %d files need to be uploaded\n":          "检测到 %d 个文件需要上传\n",
+	"Sync plan: %d to upload, %d to delete, %d unchanged\n": "同步计划：上传 %d 个，删除 %d 个，未变化 %d 个\n",
+	"upload %s\n":         "上传 %s\n",
+	"delete %s\n":         "删除 %s\n",
+	"Delete success %s\n": "删除成功 %s\n",
+	"\nTransfer finished, %d files (%d skipped), %s in %s, average %s/s\n": "\n传输完成，共 %d 个文件（跳过 %d 个），%s，耗时 %s，平均 %s/s\n",
+	"%w, rerun with --resume to continue":                                  "%w，使用 --resume 重新运行以继续",
+	"Export success, %d tables, %d storage objects required\n":             "导出成功，共 %d 张表，依赖 %d 个存储对象\n",
+	"Import success, %d tables\n":                                          "导入成功，共 %d 张表\n",
+	"%d storage objects missing":                                           "缺失 %d 个存储对象",
+	"All %d storage objects exist\n":                                       "全部 %d 个存储对象均存在\n",
+	"Seed applied, %d created, %d updated\n":                               "种子数据已应用，新建 %d 项，更新 %d 项\n",
+	"one and only one of path and uri must be specified":                   "必须且只能指定 path 和 uri 中的一个",
+	"inventory can only be used with uri":                                  "inventory 只能与 uri 一起使用",
+	"Import %d files (%s) in commit %s\n":                                  "已导入 %d 个文件（%s），提交 %s\n",
+	"Resume interrupted import, %d files imported before\n":                "继续中断的导入，此前已导入 %d 个文件\n",
+	"Server unreachable, read from local cache\n":                          "无法连接服务器，从本地缓存读取\n",
+	"ref %s not in local cache":                                            "本地缓存中没有引用 %s",
+	"wip of %s not in local cache":                                         "本地缓存中没有 %s 的工作区",
+	"Changes fetched at %s\n":                                              "变更获取于 %s\n",
+	"diff of %s...%s not in local cache":                                   "本地缓存中没有 %s...%s 的差异",
+	"%w, diff of %s...%s not in local cache":                               "%w，本地缓存中没有 %s...%s 的差异",
+
+	// doctor
+	"Latency min %s, avg %s, max %s of %d pings\n":                           "%[4]d 次 ping 延迟最小 %[1]s，平均 %[2]s，最大 %[3]s\n",
+	"API version %s compatible with server %s\n":                             "API 版本 %s 与服务器 %s 兼容\n",
+	"API version %s incompatible with server %s, upgrade client or server\n": "API 版本 %s 与服务器 %s 不兼容，请升级客户端或服务器\n",
+	"API download %s in %s, %s/s\n":                                          "API 下载 %s 耗时 %s，%s/s\n",
+	"API upload %s in %s, %s/s\n":                                            "API 上传 %s 耗时 %s，%s/s\n",
+	"Storage %s write %s in %s, %s/s\n":                                      "存储 %s 写入 %s 耗时 %s，%s/s\n",
+	"Storage %s read %s in %s, %s/s\n":                                       "存储 %s 读取 %s 耗时 %s，%s/s\n",
+}
@@ -0,0 +1,115 @@
+package i18n
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"golang.org/x/text/language"
+)
+
+// Lang language of message
+type Lang string
+
+const (
+	English Lang = "en"
+	Chinese Lang = "zh"
+)
+
+// DefaultLang is used when no supported language found
+const DefaultLang = English
+
+var supported = []language.Tag{language.English, language.Chinese}
+
+var matcher = language.NewMatcher(supported)
+
+// Negotiate choose the best supported language from value of Accept-Language header
+func Negotiate(acceptLanguage string) Lang {
+	if len(strings.TrimSpace(acceptLanguage)) == 0 {
+		return DefaultLang
+	}
+	tags, _, err := language.ParseAcceptLanguage(acceptLanguage)
+	if err != nil || len(tags) == 0 {
+		return DefaultLang
+	}
+	_, idx, confidence := matcher.Match(tags...)
+	if confidence == language.No {
+		return DefaultLang
+	}
+	return fromTag(supported[idx])
+}
+
+// Parse parse language like zh_CN.UTF-8, zh-CN, en, return default language if not supported
+func Parse(lang string) Lang {
+	lang = strings.SplitN(lang, ".", 2)[0]
+	lang = strings.ReplaceAll(lang, "_", "-")
+	return Negotiate(lang)
+}
+
+// FromEnv detect language from LC_ALL, LC_MESSAGES and LANG
+func FromEnv() Lang {
+	for _, key := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if value := os.Getenv(key); len(value) > 0 && value != "C" && value != "POSIX" {
+			return Parse(value)
+		}
+	}
+	return DefaultLang
+}
+
+func fromTag(tag language.Tag) Lang {
+	base, _ := tag.Base()
+	switch base.String() {
+	case "zh":
+		return Chinese
+	default:
+		return English
+	}
+}
+
+// T translate format into lang and format it with args, format itself is used if no translation found
+func T(lang Lang, format string, args ...any) string {
+	if catalog, ok := catalogs[lang]; ok {
+		if translated, ok := catalog[format]; ok {
+			format = translated
+		}
+	}
+	if len(args) == 0 {
+		return format
+	}
+	return fmt.Sprintf(format, args...)
+}
+
+type langKey struct{}
+
+// WithLang save language in context
+func WithLang(ctx context.Context, lang Lang) context.Context {
+	return context.WithValue(ctx, langKey{}, lang)
+}
+
+// FromContext get language from context, return default language if not set
+func FromContext(ctx context.Context) Lang {
+	lang, ok := ctx.Value(langKey{}).(Lang)
+	if !ok {
+		return DefaultLang
+	}
+	return lang
+}
+
+// TError translate message of err, the innermost error in the wrap chain which has translation is replaced,
+// so wrapped context is kept as is
+func TError(lang Lang, err error) string {
+	msg := err.Error()
+	catalog, ok := catalogs[lang]
+	if !ok {
+		return msg
+	}
+	for e := err; e != nil; e = errors.Unwrap(e) {
+		inner := e.Error()
+		if translated, ok := catalog[inner]; ok && strings.HasSuffix(msg, inner) {
+			return strings.TrimSuffix(msg, inner) + translated
+		}
+	}
+	return msg
+}
@@ -0,0 +1,39 @@
+package i18n
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestNegotiate(t *testing.T) {
+	require.Equal(t, English, Negotiate(""))
+	require.Equal(t, English, Negotiate("fr-FR"))
+	require.Equal(t, Chinese, Negotiate("zh-CN,zh;q=0.9,en;q=0.8"))
+	require.Equal(t, English, Negotiate("en-US,zh;q=0.5"))
+	require.Equal(t, Chinese, Parse("zh_CN.UTF-8"))
+	require.Equal(t, English, Parse("en_US.UTF-8"))
+}
+
+func TestT(t *testing.T) {
+	require.Equal(t, "path a.txt not found", T(English, "path %s not found", "a.txt"))
+	require.Equal(t, "路径 a.txt 不存在", T(Chinese, "path %s not found", "a.txt"))
+	require.Equal(t, "no translation 1", T(Chinese, "no translation %d", 1))
+	require.Equal(t, "100%", T(Chinese, "100%"))
+}
+
+func TestTError(t *testing.T) {
+	err := fmt.Errorf("get branch main: %w", errors.New("sql: no rows in result set"))
+	require.Equal(t, "get branch main: not found", TError(English, err))
+	require.Equal(t, "get branch main: 资源不存在", TError(Chinese, err))
+	require.Equal(t, "mock", TError(Chinese, errors.New("mock")))
+}
+
+func TestContext(t *testing.T) {
+	ctx := context.Background()
+	require.Equal(t, DefaultLang, FromContext(ctx))
+	require.Equal(t, Chinese, FromContext(WithLang(ctx, Chinese)))
+}
@@ -0,0 +1,13 @@
+package i18n
+
+import "net/http"
+
+// Middleware negotiate language from Accept-Language header, save it in request context
+// and announce it by Content-Language response header
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		lang := Negotiate(r.Header.Get("Accept-Language"))
+		w.Header().Set("Content-Language", string(lang))
+		next.ServeHTTP(w, r.WithContext(WithLang(r.Context(), lang)))
+	})
+}
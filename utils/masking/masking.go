@@ -0,0 +1,213 @@
+package masking
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+)
+
+// Redacted replacement of value masked by MethodRedact
+const Redacted = "****"
+
+type Method string
+
+const (
+	// MethodRedact replace value with Redacted
+	MethodRedact Method = "redact"
+	// MethodHash replace value with hex sha256 of it, equal values are still equal after masking so columns can be joined
+	MethodHash Method = "hash"
+)
+
+func (method Method) Valid() bool {
+	return method == MethodRedact || method == MethodHash
+}
+
+func (method Method) mask(value string) string {
+	if method == MethodHash {
+		sum := sha256.Sum256([]byte(value))
+		return hex.EncodeToString(sum[:])
+	}
+	return Redacted
+}
+
+type Format string
+
+const (
+	CSV   Format = "csv"
+	JSONL Format = "jsonl"
+)
+
+var ErrNotObject = errors.New("line is not a json object")
+
+// FormatFromPath detect format by file extension, ok is false if file can not be masked
+func FormatFromPath(objPath string) (Format, bool) {
+	switch strings.ToLower(path.Ext(objPath)) {
+	case ".csv":
+		return CSV, true
+	case ".jsonl", ".ndjson":
+		return JSONL, true
+	}
+	return "", false
+}
+
+// NewReader return reader of content in r with columns masked, columns are header names in csv and top level keys in
+// jsonl. content is transformed while being read, error in content is returned from Read of the result
+func NewReader(r io.Reader, format Format, columns map[string]Method) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		var err error
+		switch format {
+		case CSV:
+			err = maskCSV(pw, r, columns)
+		case JSONL:
+			err = maskJSONL(pw, r, columns)
+		default:
+			err = fmt.Errorf("unsupported masking format %s", format)
+		}
+		_ = pw.CloseWithError(err)
+	}()
+	return pr
+}
+
+func maskCSV(w io.Writer, r io.Reader, columns map[string]Method) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	writer := csv.NewWriter(w)
+
+	header, err := reader.Read()
+	if err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		return err
+	}
+	methods := make([]Method, len(header))
+	for i, name := range header {
+		methods[i] = columns[name]
+	}
+	err = writer.Write(header)
+	if err != nil {
+		return err
+	}
+
+	for {
+		record, err := reader.Read()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return err
+		}
+		for i := range record {
+			if i < len(methods) && len(methods[i]) > 0 {
+				record[i] = methods[i].mask(record[i])
+			}
+		}
+		err = writer.Write(record)
+		if err != nil {
+			return err
+		}
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+func maskJSONL(w io.Writer, r io.Reader, columns map[string]Method) error {
+	reader := bufio.NewReader(r)
+	for {
+		line, err := reader.ReadBytes('\n')
+		if len(line) > 0 {
+			masked, maskErr := maskJSONLine(line, columns)
+			if maskErr != nil {
+				return maskErr
+			}
+			_, writeErr := w.Write(masked)
+			if writeErr != nil {
+				return writeErr
+			}
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// maskJSONLine mask values of keys in a json object line, order of keys and line ending are kept
+func maskJSONLine(line []byte, columns map[string]Method) ([]byte, error) {
+	content := bytes.TrimRight(line, "\r\n")
+	ending := line[len(content):]
+	if len(bytes.TrimSpace(content)) == 0 {
+		return line, nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	if delim, ok := token.(json.Delim); !ok || delim != '{' {
+		return nil, ErrNotObject
+	}
+
+	buf := bytes.NewBufferString("{")
+	for decoder.More() {
+		token, err = decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil, ErrNotObject
+		}
+		var value json.RawMessage
+		err = decoder.Decode(&value)
+		if err != nil {
+			return nil, err
+		}
+
+		if buf.Len() > 1 {
+			buf.WriteByte(',')
+		}
+		keyData, _ := json.Marshal(key)
+		buf.Write(keyData)
+		buf.WriteByte(':')
+		if method, ok := columns[key]; ok && !bytes.Equal(value, []byte("null")) {
+			value, err = maskJSONValue(value, method)
+			if err != nil {
+				return nil, err
+			}
+		}
+		buf.Write(value)
+	}
+	_, err = decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	buf.WriteByte('}')
+	buf.Write(ending)
+	return buf.Bytes(), nil
+}
+
+// maskJSONValue mask string value by its content and other values by their json text
+func maskJSONValue(value json.RawMessage, method Method) (json.RawMessage, error) {
+	text := string(value)
+	if len(value) > 0 && value[0] == '"' {
+		err := json.Unmarshal(value, &text)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return json.Marshal(method.mask(text))
+}
@@ -0,0 +1,81 @@
+package masking
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func hashOf(value string) string {
+	sum := sha256.Sum256([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+func readAll(format Format, content string, columns map[string]Method) (string, error) {
+	reader := NewReader(strings.NewReader(content), format, columns)
+	defer reader.Close() //nolint
+	data, err := io.ReadAll(reader)
+	return string(data), err
+}
+
+func TestFormatFromPath(t *testing.T) {
+	format, ok := FormatFromPath("a/b.CSV")
+	require.True(t, ok)
+	require.Equal(t, CSV, format)
+
+	format, ok = FormatFromPath("a/b.ndjson")
+	require.True(t, ok)
+	require.Equal(t, JSONL, format)
+
+	_, ok = FormatFromPath("a/b.parquet")
+	require.False(t, ok)
+}
+
+func TestMaskCSV(t *testing.T) {
+	content := "name,email,age\nalice,alice@example.com,20\nbob,\"bob,jr@example.com\",30\nshort\n"
+	data, err := readAll(CSV, content, map[string]Method{
+		"name":  MethodHash,
+		"email": MethodRedact,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "name,email,age\n"+
+		hashOf("alice")+",****,20\n"+
+		hashOf("bob")+",****,30\n"+
+		hashOf("short")+"\n", data)
+
+	t.Run("empty", func(t *testing.T) {
+		data, err := readAll(CSV, "", map[string]Method{"name": MethodRedact})
+		require.NoError(t, err)
+		require.Empty(t, data)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := readAll(CSV, "name\n\"alice\n", map[string]Method{"name": MethodRedact})
+		require.Error(t, err)
+	})
+}
+
+func TestMaskJSONL(t *testing.T) {
+	content := `{"name":"alice","email":"alice@example.com","age":20}` + "\n" +
+		"\n" +
+		`{"age": 30, "name": null, "email": {"work":"bob@example.com"}}` + "\r\n" +
+		`{"name":"carol"}`
+	data, err := readAll(JSONL, content, map[string]Method{
+		"name":  MethodHash,
+		"email": MethodRedact,
+	})
+	require.NoError(t, err)
+	require.Equal(t, `{"name":"`+hashOf("alice")+`","email":"****","age":20}`+"\n"+
+		"\n"+
+		`{"age":30,"name":null,"email":"****"}`+"\r\n"+
+		`{"name":"`+hashOf("carol")+`"}`, data)
+
+	t.Run("not object", func(t *testing.T) {
+		_, err := readAll(JSONL, "[1,2]\n", map[string]Method{"name": MethodRedact})
+		require.ErrorIs(t, err, ErrNotObject)
+	})
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"fmt"
 	"reflect"
 	"strconv"
 	"time"
@@ -12,7 +13,9 @@ type PageManage struct {
 	HasMore    bool
 	MaxPerPage int
 	NextOffset string
-	Results    int
+	// NextOffsetID id of the last result, sent with NextOffset so results sharing the same offset value are not skipped
+	NextOffsetID *string
+	Results      int
 }
 
 func PaginationFor(hasMore bool, results interface{}, fieldName string) PageManage {
@@ -27,7 +30,16 @@ func PaginationFor(hasMore bool, results interface{}, fieldName string) PageMana
 		return pagination
 	}
 	v := s.Index(pagination.Results - 1)
+	if id := v.FieldByName("Id"); id.IsValid() {
+		if stringer, ok := id.Interface().(fmt.Stringer); ok {
+			nextOffsetID := stringer.String()
+			pagination.NextOffsetID = &nextOffsetID
+		}
+	}
 	token := v.FieldByName(fieldName)
+	if token.Kind() == reflect.Pointer && !token.IsNil() {
+		token = token.Elem()
+	}
 	switch token.Kind() {
 	case reflect.Struct:
 		if token.Type() == reflect.TypeOf(time.Time{}) {
@@ -3,6 +3,8 @@ package utils
 import (
 	"testing"
 	"time"
+
+	"github.com/google/uuid"
 )
 
 func TestPaginationFor(t *testing.T) {
@@ -40,4 +42,33 @@ func TestPaginationFor(t *testing.T) {
 	if !pagination3.HasMore || pagination3.Results != 2 || pagination3.NextOffset == "" {
 		t.Errorf("Test case 3 failed: Expected more results with valid NextOffset")
 	}
+
+	// Test case 4: With more results and pointer field
+	deletedAt := int64(1704207845000)
+	results4 := []struct {
+		DeletedAt *int64
+	}{
+		{&deletedAt},
+	}
+	pagination4 := PaginationFor(true, results4, "DeletedAt")
+	if pagination4.NextOffset != "1704207845000" {
+		t.Errorf("Test case 4 failed: Expected NextOffset from pointer field")
+	}
+	if pagination4.NextOffsetID != nil {
+		t.Errorf("Test case 4 failed: Expected no NextOffsetID without id field")
+	}
+
+	// Test case 5: With more results and id of the last result
+	id := uuid.New()
+	results5 := []struct {
+		Id   uuid.UUID
+		Name string
+	}{
+		{uuid.New(), "Item1"},
+		{id, "Item1"},
+	}
+	pagination5 := PaginationFor(true, results5, "Name")
+	if pagination5.NextOffset != "Item1" || pagination5.NextOffsetID == nil || *pagination5.NextOffsetID != id.String() {
+		t.Errorf("Test case 5 failed: Expected NextOffsetID of the last result")
+	}
 }
@@ -2,11 +2,59 @@ package pathutil
 
 import (
 	"path"
+	"strings"
 
 	"github.com/GitDataAI/jiaozifs/utils/hash"
 )
 
+const (
+	packsDir      = "packs"
+	quarantineDir = "quarantine"
+	probeDir      = "diagnostics"
+)
+
 func PathOfHash(hash hash.Hash) string {
 	hex := hash.Hex()
 	return path.Join(hex[:2], hex[2:])
 }
+
+// PathOfPack path of pack file storing many small blobs, packs are kept apart from paths of blobs
+func PathOfPack(hash hash.Hash) string {
+	return path.Join(packsDir, hash.Hex())
+}
+
+// PathOfQuarantine path where file of storage is moved aside instead of being deleted
+func PathOfQuarantine(p string) string {
+	return path.Join(quarantineDir, p)
+}
+
+// PathOfProbe path of temporary object written when diagnosing storage
+func PathOfProbe(name string) string {
+	return path.Join(probeDir, name)
+}
+
+// HashOfPath parse hash from path of blob content, false if p is not a path made by PathOfHash
+func HashOfPath(p string) (hash.Hash, bool) {
+	dir, name, found := strings.Cut(p, "/")
+	if !found || len(dir) != 2 || len(name) == 0 || strings.Contains(name, "/") {
+		return nil, false
+	}
+	h, err := hash.FromHex(dir + name)
+	if err != nil {
+		return nil, false
+	}
+	return h, true
+}
+
+// HashOfPackPath parse hash from path of pack, false if p is not a path made by PathOfPack
+func HashOfPackPath(p string) (hash.Hash, bool) {
+	name, found := strings.CutPrefix(p, packsDir+"/")
+	if !found || len(name) == 0 || strings.Contains(name, "/") {
+		return nil, false
+	}
+	h, err := hash.FromHex(name)
+	if err != nil {
+		return nil, false
+	}
+	return h, true
+}
@@ -12,4 +12,23 @@ import (
 func TestPathOfHash(t *testing.T) {
 	hashBytes, _ := hex.DecodeString("7cfdd07889b3295d6a550914ab35e068")
 	require.Equal(t, "7c/fdd07889b3295d6a550914ab35e068", PathOfHash(hash.Hash(hashBytes)))
+	require.Equal(t, "packs/7cfdd07889b3295d6a550914ab35e068", PathOfPack(hash.Hash(hashBytes)))
+	require.Equal(t, "quarantine/packs/7cfdd07889b3295d6a550914ab35e068", PathOfQuarantine(PathOfPack(hash.Hash(hashBytes))))
+}
+
+func TestHashOfPath(t *testing.T) {
+	hashBytes, _ := hex.DecodeString("7cfdd07889b3295d6a550914ab35e068")
+	h, ok := HashOfPath(PathOfHash(hash.Hash(hashBytes)))
+	require.True(t, ok)
+	require.Equal(t, hash.Hash(hashBytes), h)
+	h, ok = HashOfPackPath(PathOfPack(hash.Hash(hashBytes)))
+	require.True(t, ok)
+	require.Equal(t, hash.Hash(hashBytes), h)
+
+	for _, p := range []string{"7cfdd07889b3295d6a550914ab35e068", "7cf/dd07889b3295d6a550914ab35e068", "7c/zz", "7c/fd/dd", "packs/7c/fd", "quarantine/7c/fd"} {
+		_, ok = HashOfPath(p)
+		require.False(t, ok, p)
+	}
+	_, ok = HashOfPackPath(PathOfHash(hash.Hash(hashBytes)))
+	require.False(t, ok)
 }
@@ -0,0 +1,53 @@
+package pathutil
+
+import (
+	"errors"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	gitignore "github.com/crackcomm/go-gitignore"
+)
+
+// IgnoreFileName name of ignore file in the root of directory, use gitignore syntax
+const IgnoreFileName = ".jzfsignore"
+
+// IgnoreMatcher check whether a path should be ignored by rules of ignore file
+type IgnoreMatcher struct {
+	ignore *gitignore.GitIgnore
+}
+
+// NewIgnoreMatcher compile ignore rules from lines of ignore file
+func NewIgnoreMatcher(lines ...string) (*IgnoreMatcher, error) {
+	ignore, err := gitignore.CompileIgnoreLines(lines...)
+	if err != nil {
+		return nil, err
+	}
+	return &IgnoreMatcher{ignore: ignore}, nil
+}
+
+// LoadIgnoreMatcher read ignore file in dir, return a matcher match nothing if ignore file not exist
+func LoadIgnoreMatcher(dir string, extraLines ...string) (*IgnoreMatcher, error) {
+	data, err := os.ReadFile(filepath.Join(dir, IgnoreFileName))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+	lines := strings.Split(string(data), "\n")
+	return NewIgnoreMatcher(append(lines, extraLines...)...)
+}
+
+// Match check whether path relative to root of ignore file is ignored, directory pattern like "cache/" only match directory
+func (matcher *IgnoreMatcher) Match(relativePath string, isDir bool) bool {
+	if matcher == nil || matcher.ignore == nil {
+		return false
+	}
+	relativePath = strings.TrimPrefix(path.Clean("/"+filepath.ToSlash(relativePath)), "/")
+	if len(relativePath) == 0 {
+		return false
+	}
+	if isDir {
+		relativePath += "/"
+	}
+	return matcher.ignore.MatchesPath(relativePath)
+}
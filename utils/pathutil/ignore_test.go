@@ -0,0 +1,54 @@
+package pathutil
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	matcher, err := NewIgnoreMatcher("# comment", "*.tmp", "cache/", "/build", "!keep.tmp")
+	require.NoError(t, err)
+
+	require.True(t, matcher.Match("a.tmp", false))
+	require.True(t, matcher.Match("a/b/c.tmp", false))
+	require.False(t, matcher.Match("keep.tmp", false))
+	require.False(t, matcher.Match("a.txt", false))
+
+	require.True(t, matcher.Match("cache", true))
+	require.True(t, matcher.Match("a/cache/b.txt", false))
+	require.False(t, matcher.Match("cache", false))
+
+	require.True(t, matcher.Match("build", true))
+	require.True(t, matcher.Match("/build/a.txt", false))
+	require.False(t, matcher.Match("a/build", true))
+
+	require.False(t, matcher.Match("", true))
+}
+
+func TestLoadIgnoreMatcher(t *testing.T) {
+	t.Run("not exist", func(t *testing.T) {
+		matcher, err := LoadIgnoreMatcher(t.TempDir())
+		require.NoError(t, err)
+		require.False(t, matcher.Match("a.tmp", false))
+	})
+
+	t.Run("load file", func(t *testing.T) {
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, IgnoreFileName), []byte("*.tmp\r\n.cache/\n"), 0644))
+
+		matcher, err := LoadIgnoreMatcher(dir, "*.log")
+		require.NoError(t, err)
+		require.True(t, matcher.Match("a.tmp", false))
+		require.True(t, matcher.Match(".cache", true))
+		require.True(t, matcher.Match("b/c.log", false))
+		require.False(t, matcher.Match("a.txt", false))
+	})
+
+	t.Run("nil matcher", func(t *testing.T) {
+		var matcher *IgnoreMatcher
+		require.False(t, matcher.Match("a.tmp", false))
+	})
+}
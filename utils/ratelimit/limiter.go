@@ -0,0 +1,133 @@
+package ratelimit
+
+import (
+	"fmt"
+	"math"
+	"sync"
+	"time"
+)
+
+// ThrottleMessage format of throttle error message, args are kind, limit and retry after
+const ThrottleMessage = "repository %s limit %d exceeded, retry after %s"
+
+type Kind string
+
+const (
+	// KindWriteRate writes per minute exceeded
+	KindWriteRate Kind = "write_rate"
+	// KindConcurrentUploads uploads in flight exceeded
+	KindConcurrentUploads Kind = "concurrent_uploads"
+)
+
+// uploadRetryAfter suggested wait when no upload slot is free, slots are released when uploads finish so there is no
+// exact time to wait
+const uploadRetryAfter = time.Second
+
+// Limits of a key, zero means unlimited
+type Limits struct {
+	// WritesPerMinute steady rate of writes
+	WritesPerMinute int
+	// Burst max writes allowed at once after being idle, zero means 1
+	Burst int
+	// MaxConcurrentUploads uploads in flight at the same time
+	MaxConcurrentUploads int
+}
+
+func (limits Limits) burst() float64 {
+	if limits.Burst <= 0 {
+		return 1
+	}
+	return float64(limits.Burst)
+}
+
+// ThrottleError returned when limit of key exceeded
+type ThrottleError struct {
+	Kind       Kind
+	Limit      int
+	RetryAfter time.Duration
+}
+
+func (err *ThrottleError) Error() string {
+	return fmt.Sprintf(ThrottleMessage, err.Kind, err.Limit, err.RetryAfter)
+}
+
+type state struct {
+	tokens  float64
+	last    time.Time
+	uploads int
+}
+
+// Limiter token bucket of writes and counter of uploads for each key, limits are passed on every call so changes of
+// limits take effect immediately
+type Limiter struct {
+	lk     sync.Mutex
+	states map[string]*state
+	now    func() time.Time
+}
+
+func NewLimiter() *Limiter {
+	return &Limiter{
+		states: make(map[string]*state),
+		now:    time.Now,
+	}
+}
+
+// Allow take a write of key
+func (l *Limiter) Allow(key string, limits Limits) error {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+	return l.takeToken(l.state(key, limits), limits)
+}
+
+// AcquireUpload take a write and an upload slot of key, release must be called when upload finish
+func (l *Limiter) AcquireUpload(key string, limits Limits) (func(), error) {
+	l.lk.Lock()
+	defer l.lk.Unlock()
+
+	st := l.state(key, limits)
+	// check slot first, rejected uploads should not consume writes
+	if limits.MaxConcurrentUploads > 0 && st.uploads >= limits.MaxConcurrentUploads {
+		return nil, &ThrottleError{Kind: KindConcurrentUploads, Limit: limits.MaxConcurrentUploads, RetryAfter: uploadRetryAfter}
+	}
+	err := l.takeToken(st, limits)
+	if err != nil {
+		return nil, err
+	}
+
+	st.uploads++
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			l.lk.Lock()
+			defer l.lk.Unlock()
+			st.uploads--
+		})
+	}, nil
+}
+
+func (l *Limiter) state(key string, limits Limits) *state {
+	st, ok := l.states[key]
+	if !ok {
+		st = &state{tokens: limits.burst(), last: l.now()}
+		l.states[key] = st
+	}
+	return st
+}
+
+func (l *Limiter) takeToken(st *state, limits Limits) error {
+	now := l.now()
+	if limits.WritesPerMinute <= 0 {
+		st.last = now
+		return nil
+	}
+
+	rate := float64(limits.WritesPerMinute) / 60 // tokens per second
+	st.tokens = math.Min(limits.burst(), st.tokens+now.Sub(st.last).Seconds()*rate)
+	st.last = now
+	if st.tokens < 1 {
+		retryAfter := time.Duration(math.Ceil((1 - st.tokens) / rate * float64(time.Second)))
+		return &ThrottleError{Kind: KindWriteRate, Limit: limits.WritesPerMinute, RetryAfter: retryAfter}
+	}
+	st.tokens--
+	return nil
+}
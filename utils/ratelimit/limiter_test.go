@@ -0,0 +1,82 @@
+package ratelimit
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLimiterAllow(t *testing.T) {
+	now := time.Now()
+	l := NewLimiter()
+	l.now = func() time.Time { return now }
+
+	limits := Limits{WritesPerMinute: 60, Burst: 2}
+	require.NoError(t, l.Allow("a", limits))
+	require.NoError(t, l.Allow("a", limits))
+
+	err := l.Allow("a", limits)
+	var throttleErr *ThrottleError
+	require.True(t, errors.As(err, &throttleErr))
+	require.Equal(t, KindWriteRate, throttleErr.Kind)
+	require.Equal(t, 60, throttleErr.Limit)
+	require.Equal(t, time.Second, throttleErr.RetryAfter)
+
+	// other keys are not affected
+	require.NoError(t, l.Allow("b", limits))
+
+	now = now.Add(time.Second)
+	require.NoError(t, l.Allow("a", limits))
+	require.Error(t, l.Allow("a", limits))
+
+	// unlimited
+	for i := 0; i < 100; i++ {
+		require.NoError(t, l.Allow("c", Limits{}))
+	}
+}
+
+func TestLimiterAcquireUpload(t *testing.T) {
+	l := NewLimiter()
+	limits := Limits{MaxConcurrentUploads: 2}
+
+	release1, err := l.AcquireUpload("a", limits)
+	require.NoError(t, err)
+	release2, err := l.AcquireUpload("a", limits)
+	require.NoError(t, err)
+
+	_, err = l.AcquireUpload("a", limits)
+	var throttleErr *ThrottleError
+	require.True(t, errors.As(err, &throttleErr))
+	require.Equal(t, KindConcurrentUploads, throttleErr.Kind)
+	require.Equal(t, 2, throttleErr.Limit)
+
+	release1()
+	release1() // release twice take no effect
+	release3, err := l.AcquireUpload("a", limits)
+	require.NoError(t, err)
+	_, err = l.AcquireUpload("a", limits)
+	require.Error(t, err)
+
+	release2()
+	release3()
+}
+
+func TestLimiterRejectedUploadKeepWrites(t *testing.T) {
+	now := time.Now()
+	l := NewLimiter()
+	l.now = func() time.Time { return now }
+
+	limits := Limits{WritesPerMinute: 60, MaxConcurrentUploads: 1}
+	release, err := l.AcquireUpload("a", limits)
+	require.NoError(t, err)
+
+	now = now.Add(time.Second)
+	_, err = l.AcquireUpload("a", limits)
+	require.Error(t, err)
+	release()
+
+	_, err = l.AcquireUpload("a", limits)
+	require.NoError(t, err)
+}
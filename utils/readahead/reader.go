@@ -0,0 +1,217 @@
+package readahead
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+)
+
+const (
+	DefaultChunkSize int64 = 8 << 20
+	DefaultWindow          = 4
+)
+
+// FetchFunc read length bytes of object starting at offset, usually a ranged request to object storage or server
+type FetchFunc func(ctx context.Context, offset, length int64) (io.ReadCloser, error)
+
+type Options struct {
+	// ChunkSize bytes of each fetch
+	ChunkSize int64
+	// Window max chunks fetched ahead of a sequential reader, zero disable prefetch
+	Window int
+}
+
+type chunk struct {
+	done chan struct{}
+	data []byte
+	err  error
+}
+
+// Reader read object in chunks, once reads are found to be sequential the following chunks are fetched in background.
+// the number of chunks ahead start from one and double on every sequential read until window reached, a random read
+// drop prefetched chunks and reset it. Reader is safe for concurrent ReadAt
+type Reader struct {
+	ctx       context.Context
+	cancel    context.CancelFunc
+	fetch     FetchFunc
+	size      int64
+	chunkSize int64
+	maxWindow int
+
+	lk      sync.Mutex
+	window  int
+	lastEnd int64
+	offset  int64
+	chunks  map[int64]*chunk
+}
+
+var _ io.ReaderAt = (*Reader)(nil)
+var _ io.ReadCloser = (*Reader)(nil)
+
+// NewReader create reader of object which has size bytes
+func NewReader(ctx context.Context, size int64, fetch FetchFunc, opts Options) *Reader {
+	if opts.ChunkSize <= 0 {
+		opts.ChunkSize = DefaultChunkSize
+	}
+	if opts.Window < 0 {
+		opts.Window = 0
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	return &Reader{
+		ctx:       ctx,
+		cancel:    cancel,
+		fetch:     fetch,
+		size:      size,
+		chunkSize: opts.ChunkSize,
+		maxWindow: opts.Window,
+		chunks:    make(map[int64]*chunk),
+	}
+}
+
+// Read read from current offset, offset is moved forward by bytes read
+func (r *Reader) Read(p []byte) (int, error) {
+	r.lk.Lock()
+	offset := r.offset
+	r.lk.Unlock()
+
+	n, err := r.ReadAt(p, offset)
+
+	r.lk.Lock()
+	r.offset = offset + int64(n)
+	r.lk.Unlock()
+	if n > 0 && errors.Is(err, io.EOF) {
+		return n, nil
+	}
+	return n, err
+}
+
+func (r *Reader) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative offset %d", off)
+	}
+	if off >= r.size {
+		return 0, io.EOF
+	}
+	end := off + int64(len(p))
+	if end > r.size {
+		end = r.size
+	}
+	if end == off {
+		return 0, nil
+	}
+
+	first, last := off/r.chunkSize, (end-1)/r.chunkSize
+	chunks := r.schedule(off, end, first, last)
+
+	n := 0
+	for idx := first; idx <= last; idx++ {
+		c := chunks[idx-first]
+		select {
+		case <-c.done:
+		case <-r.ctx.Done():
+			return n, r.ctx.Err()
+		}
+		if c.err != nil {
+			r.drop(idx, c)
+			return n, c.err
+		}
+		chunkStart := idx * r.chunkSize
+		from := off + int64(n) - chunkStart
+		to := end - chunkStart
+		if to > int64(len(c.data)) {
+			to = int64(len(c.data))
+		}
+		if from >= to {
+			return n, io.ErrUnexpectedEOF
+		}
+		n += copy(p[n:], c.data[from:to])
+	}
+
+	if end == r.size {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// schedule start fetches of chunks needed by read [off, end) and chunks ahead of it, return chunks of the read
+func (r *Reader) schedule(off, end, first, last int64) []*chunk {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+
+	if off == r.lastEnd {
+		switch {
+		case r.window == 0:
+			r.window = 1
+		case r.window < r.maxWindow:
+			r.window *= 2
+		}
+		if r.window > r.maxWindow {
+			r.window = r.maxWindow
+		}
+	} else {
+		r.window = 0
+	}
+	r.lastEnd = end
+
+	// keep only chunks of this read and ahead, sequential reader never look back
+	for idx := range r.chunks {
+		if idx < first || idx > last+int64(r.window) {
+			delete(r.chunks, idx)
+		}
+	}
+
+	chunks := make([]*chunk, 0, last-first+1)
+	for idx := first; idx <= last; idx++ {
+		chunks = append(chunks, r.chunkOf(idx))
+	}
+	for idx := last + 1; idx <= last+int64(r.window) && idx*r.chunkSize < r.size; idx++ {
+		r.chunkOf(idx)
+	}
+	return chunks
+}
+
+// chunkOf return chunk of idx and start to fetch it if not fetched yet, lk must be held
+func (r *Reader) chunkOf(idx int64) *chunk {
+	if c, ok := r.chunks[idx]; ok {
+		return c
+	}
+	c := &chunk{done: make(chan struct{})}
+	r.chunks[idx] = c
+
+	offset := idx * r.chunkSize
+	length := r.chunkSize
+	if offset+length > r.size {
+		length = r.size - offset
+	}
+	go func() {
+		defer close(c.done)
+		body, err := r.fetch(r.ctx, offset, length)
+		if err != nil {
+			c.err = err
+			return
+		}
+		defer body.Close() //nolint
+		c.data, c.err = io.ReadAll(io.LimitReader(body, length))
+	}()
+	return c
+}
+
+// drop remove failed chunk so it is fetched again on next read
+func (r *Reader) drop(idx int64, c *chunk) {
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	if r.chunks[idx] == c {
+		delete(r.chunks, idx)
+	}
+}
+
+// Close cancel fetches in progress
+func (r *Reader) Close() error {
+	r.cancel()
+	r.lk.Lock()
+	defer r.lk.Unlock()
+	r.chunks = make(map[int64]*chunk)
+	return nil
+}
@@ -0,0 +1,129 @@
+package readahead
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type fakeObject struct {
+	lk      sync.Mutex
+	data    []byte
+	fetched []int64
+	failAt  int64
+}
+
+func (o *fakeObject) fetch(_ context.Context, offset, length int64) (io.ReadCloser, error) {
+	o.lk.Lock()
+	defer o.lk.Unlock()
+	if offset == o.failAt {
+		o.failAt = -1
+		return nil, errors.New("fetch failed")
+	}
+	o.fetched = append(o.fetched, offset)
+	return io.NopCloser(bytes.NewReader(o.data[offset : offset+length])), nil
+}
+
+func (o *fakeObject) fetchedOffsets() []int64 {
+	o.lk.Lock()
+	defer o.lk.Unlock()
+	return append([]int64{}, o.fetched...)
+}
+
+func newFakeObject(size int) *fakeObject {
+	data := make([]byte, size)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	return &fakeObject{data: data, failAt: -1}
+}
+
+func TestReaderSequential(t *testing.T) {
+	obj := newFakeObject(1000)
+	reader := NewReader(context.Background(), int64(len(obj.data)), obj.fetch, Options{ChunkSize: 100, Window: 4})
+	defer reader.Close() //nolint
+
+	buf := make([]byte, 100)
+	n, err := io.ReadFull(reader, buf)
+	require.NoError(t, err)
+	require.Equal(t, 100, n)
+	require.Equal(t, obj.data[:100], buf)
+
+	content, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.Equal(t, obj.data[100:], content)
+
+	// every chunk fetched exactly once
+	offsets := obj.fetchedOffsets()
+	require.Len(t, offsets, 10)
+	require.ElementsMatch(t, []int64{0, 100, 200, 300, 400, 500, 600, 700, 800, 900}, offsets)
+}
+
+func TestReaderPrefetch(t *testing.T) {
+	obj := newFakeObject(1000)
+	reader := NewReader(context.Background(), int64(len(obj.data)), obj.fetch, Options{ChunkSize: 100, Window: 2})
+	defer reader.Close() //nolint
+
+	buf := make([]byte, 100)
+	_, err := reader.ReadAt(buf, 0)
+	require.NoError(t, err)
+	_, err = reader.ReadAt(buf, 100)
+	require.NoError(t, err)
+
+	// second sequential read grows window to 2, chunks 2 and 3 are fetched ahead
+	require.Eventually(t, func() bool {
+		return len(obj.fetchedOffsets()) == 4
+	}, time.Second, time.Millisecond*10)
+	require.ElementsMatch(t, []int64{0, 100, 200, 300}, obj.fetchedOffsets())
+}
+
+func TestReaderRandom(t *testing.T) {
+	obj := newFakeObject(1000)
+	reader := NewReader(context.Background(), int64(len(obj.data)), obj.fetch, Options{ChunkSize: 100, Window: 4})
+	defer reader.Close() //nolint
+
+	buf := make([]byte, 50)
+	n, err := reader.ReadAt(buf, 730)
+	require.NoError(t, err)
+	require.Equal(t, 50, n)
+	require.Equal(t, obj.data[730:780], buf)
+
+	n, err = reader.ReadAt(buf, 180)
+	require.NoError(t, err)
+	require.Equal(t, 50, n)
+	require.Equal(t, obj.data[180:230], buf)
+
+	// reads not following each other are not prefetched
+	require.ElementsMatch(t, []int64{100, 200, 700}, obj.fetchedOffsets())
+
+	n, err = reader.ReadAt(buf, 980)
+	require.ErrorIs(t, err, io.EOF)
+	require.Equal(t, 20, n)
+	require.Equal(t, obj.data[980:], buf[:n])
+
+	_, err = reader.ReadAt(buf, 1000)
+	require.ErrorIs(t, err, io.EOF)
+}
+
+func TestReaderFetchError(t *testing.T) {
+	obj := newFakeObject(300)
+	obj.failAt = 100
+	reader := NewReader(context.Background(), int64(len(obj.data)), obj.fetch, Options{ChunkSize: 100, Window: 0})
+	defer reader.Close() //nolint
+
+	buf := make([]byte, 100)
+	_, err := reader.ReadAt(buf, 100)
+	require.Error(t, err)
+
+	// failed chunk is fetched again
+	n, err := reader.ReadAt(buf, 100)
+	require.NoError(t, err)
+	require.Equal(t, 100, n)
+	require.Equal(t, obj.data[100:200], buf)
+}
@@ -0,0 +1,240 @@
+package tabular
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/GitDataAI/jiaozifs/utils/masking"
+)
+
+// SampleRows max number of rows read to infer schema, columns only appear after sample are not detected
+const SampleRows = 1000
+
+type Type string
+
+const (
+	TypeNull    Type = "null"
+	TypeBoolean Type = "boolean"
+	TypeInteger Type = "integer"
+	TypeNumber  Type = "number"
+	TypeString  Type = "string"
+	TypeObject  Type = "object"
+	TypeArray   Type = "array"
+)
+
+// Column name and type of column, name is header name in csv and top level key in jsonl
+type Column struct {
+	Name string `json:"name"`
+	Type Type   `json:"type"`
+}
+
+var ErrNotObject = errors.New("line is not a json object")
+
+// InferSchema detect columns of content in r from first SampleRows rows
+func InferSchema(r io.Reader, format masking.Format) ([]Column, error) {
+	switch format {
+	case masking.CSV:
+		return inferCSV(r)
+	case masking.JSONL:
+		return inferJSONL(r)
+	}
+	return nil, fmt.Errorf("unsupported tabular format %s", format)
+}
+
+func inferCSV(r io.Reader) ([]Column, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	header, err := reader.Read()
+	if err == io.EOF {
+		return []Column{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	types := make([]Type, len(header))
+	for i := 0; i < SampleRows; i++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		for index, value := range record {
+			if index < len(types) && len(value) > 0 {
+				types[index] = mergeType(types[index], csvValueType(value))
+			}
+		}
+	}
+
+	columns := make([]Column, len(header))
+	for index, name := range header {
+		columnType := types[index]
+		if len(columnType) == 0 {
+			// every value is empty, csv is text after all
+			columnType = TypeString
+		}
+		columns[index] = Column{Name: name, Type: columnType}
+	}
+	return columns, nil
+}
+
+func csvValueType(value string) Type {
+	if _, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return TypeInteger
+	}
+	if _, err := strconv.ParseFloat(value, 64); err == nil {
+		return TypeNumber
+	}
+	if strings.EqualFold(value, "true") || strings.EqualFold(value, "false") {
+		return TypeBoolean
+	}
+	return TypeString
+}
+
+func inferJSONL(r io.Reader) ([]Column, error) {
+	reader := bufio.NewReader(r)
+	columns := make([]Column, 0)
+	indexes := make(map[string]int)
+	for rows := 0; rows < SampleRows; {
+		line, err := reader.ReadBytes('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if trimmed := bytes.TrimSpace(line); len(trimmed) > 0 {
+			var row map[string]json.RawMessage
+			if bytes.HasPrefix(trimmed, []byte("{")) {
+				if jsonErr := json.Unmarshal(trimmed, &row); jsonErr != nil {
+					return nil, jsonErr
+				}
+			}
+			if row == nil {
+				return nil, ErrNotObject
+			}
+			// keep keys in order of first appearance, map drops order of line
+			for _, key := range objectKeys(trimmed) {
+				valueType := jsonValueType(row[key])
+				index, ok := indexes[key]
+				if !ok {
+					indexes[key] = len(columns)
+					columns = append(columns, Column{Name: key, Type: valueType})
+					continue
+				}
+				columns[index].Type = mergeType(columns[index].Type, valueType)
+			}
+			rows++
+		}
+		if err == io.EOF {
+			break
+		}
+	}
+	return columns, nil
+}
+
+// objectKeys top level keys of a valid json object in order
+func objectKeys(data []byte) []string {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	_, _ = decoder.Token() // {
+	var keys []string
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return keys
+		}
+		keys = append(keys, token.(string))
+		var value json.RawMessage
+		if err = decoder.Decode(&value); err != nil {
+			return keys
+		}
+	}
+	return keys
+}
+
+func jsonValueType(value json.RawMessage) Type {
+	value = bytes.TrimSpace(value)
+	if len(value) == 0 {
+		return TypeNull
+	}
+	switch value[0] {
+	case 'n':
+		return TypeNull
+	case 't', 'f':
+		return TypeBoolean
+	case '"':
+		return TypeString
+	case '{':
+		return TypeObject
+	case '[':
+		return TypeArray
+	}
+	if bytes.ContainsAny(value, ".eE") {
+		return TypeNumber
+	}
+	return TypeInteger
+}
+
+// mergeType type of column holding values of both types, integer widens to number and other mixes fall back to string
+func mergeType(a, b Type) Type {
+	switch {
+	case len(a) == 0 || a == TypeNull:
+		return b
+	case b == TypeNull || a == b:
+		return a
+	case (a == TypeInteger && b == TypeNumber) || (a == TypeNumber && b == TypeInteger):
+		return TypeNumber
+	}
+	return TypeString
+}
+
+type ChangeKind string
+
+const (
+	ColumnAdded       ChangeKind = "added"
+	ColumnRemoved     ChangeKind = "removed"
+	ColumnTypeChanged ChangeKind = "type_changed"
+)
+
+// Change difference of a column between two schemas, OldType is empty for added column and NewType for removed one
+type Change struct {
+	Column  string     `json:"column"`
+	Kind    ChangeKind `json:"kind"`
+	OldType Type       `json:"old_type,omitempty"`
+	NewType Type       `json:"new_type,omitempty"`
+}
+
+// Diff compare columns by name, removed and retyped columns come first in order of old schema followed by added columns
+// in order of new schema. reordering columns is not a change
+func Diff(oldColumns, newColumns []Column) []Change {
+	newTypes := make(map[string]Type, len(newColumns))
+	for _, column := range newColumns {
+		newTypes[column.Name] = column.Type
+	}
+	oldTypes := make(map[string]Type, len(oldColumns))
+
+	changes := make([]Change, 0)
+	for _, column := range oldColumns {
+		oldTypes[column.Name] = column.Type
+		newType, ok := newTypes[column.Name]
+		if !ok {
+			changes = append(changes, Change{Column: column.Name, Kind: ColumnRemoved, OldType: column.Type})
+			continue
+		}
+		if newType != column.Type {
+			changes = append(changes, Change{Column: column.Name, Kind: ColumnTypeChanged, OldType: column.Type, NewType: newType})
+		}
+	}
+	for _, column := range newColumns {
+		if _, ok := oldTypes[column.Name]; !ok {
+			changes = append(changes, Change{Column: column.Name, Kind: ColumnAdded, NewType: column.Type})
+		}
+	}
+	return changes
+}
@@ -0,0 +1,66 @@
+package tabular
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/utils/masking"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInferCSV(t *testing.T) {
+	content := "id,price,active,name,note\n1,2,true,alice,\n2,2.5,False,3,\n"
+	columns, err := InferSchema(strings.NewReader(content), masking.CSV)
+	require.NoError(t, err)
+	require.Equal(t, []Column{
+		{Name: "id", Type: TypeInteger},
+		{Name: "price", Type: TypeNumber},
+		{Name: "active", Type: TypeBoolean},
+		{Name: "name", Type: TypeString},
+		{Name: "note", Type: TypeString},
+	}, columns)
+
+	t.Run("empty", func(t *testing.T) {
+		columns, err := InferSchema(strings.NewReader(""), masking.CSV)
+		require.NoError(t, err)
+		require.Empty(t, columns)
+	})
+
+	t.Run("malformed", func(t *testing.T) {
+		_, err := InferSchema(strings.NewReader("id\n\"1\n"), masking.CSV)
+		require.Error(t, err)
+	})
+}
+
+func TestInferJSONL(t *testing.T) {
+	content := `{"id":1,"name":null,"tags":["a"]}` + "\n" +
+		"\n" +
+		`{"name":"bob","id":1.5,"extra":{"a":1},"ok":true}` + "\r\n" +
+		`{"id":"x"}`
+	columns, err := InferSchema(strings.NewReader(content), masking.JSONL)
+	require.NoError(t, err)
+	require.Equal(t, []Column{
+		{Name: "id", Type: TypeString},
+		{Name: "name", Type: TypeString},
+		{Name: "tags", Type: TypeArray},
+		{Name: "extra", Type: TypeObject},
+		{Name: "ok", Type: TypeBoolean},
+	}, columns)
+
+	t.Run("not object", func(t *testing.T) {
+		_, err := InferSchema(strings.NewReader("[1,2]\n"), masking.JSONL)
+		require.ErrorIs(t, err, ErrNotObject)
+	})
+}
+
+func TestDiff(t *testing.T) {
+	oldColumns := []Column{{Name: "id", Type: TypeInteger}, {Name: "name", Type: TypeString}, {Name: "age", Type: TypeInteger}}
+	newColumns := []Column{{Name: "email", Type: TypeString}, {Name: "age", Type: TypeNumber}, {Name: "id", Type: TypeInteger}}
+	require.Equal(t, []Change{
+		{Column: "name", Kind: ColumnRemoved, OldType: TypeString},
+		{Column: "age", Kind: ColumnTypeChanged, OldType: TypeInteger, NewType: TypeNumber},
+		{Column: "email", Kind: ColumnAdded, NewType: TypeString},
+	}, Diff(oldColumns, newColumns))
+
+	require.Empty(t, Diff(oldColumns, oldColumns))
+}
@@ -6,15 +6,32 @@ import (
 
 	"github.com/GitDataAI/jiaozifs/block"
 	"github.com/GitDataAI/jiaozifs/block/factory"
+	"github.com/GitDataAI/jiaozifs/block/params"
 	"github.com/GitDataAI/jiaozifs/config"
+	"github.com/GitDataAI/jiaozifs/models"
 )
 
+// AdapterConfigOf return storage config of repository, public config is returned if repository use public storage
+func AdapterConfigOf(repoModel *models.Repository, publicAdapterConfig params.AdapterConfig) (params.AdapterConfig, error) {
+	if repoModel.UsePublicStorage {
+		return publicAdapterConfig, nil
+	}
+	var cfg = config.BlockStoreConfig{}
+	err := json.Unmarshal([]byte(*repoModel.StorageAdapterParams), &cfg)
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
 func AdapterFromConfig(ctx context.Context, jsonParams string) (block.Adapter, error) {
 	var cfg = config.BlockStoreConfig{}
 	err := json.Unmarshal([]byte(jsonParams), &cfg)
 	if err != nil {
 		return nil, err
 	}
+	// spool is a local directory of server, only allowed for public storage
+	cfg.Spool = nil
 	adapter, err := factory.BuildBlockAdapter(ctx, &cfg)
 	if err != nil {
 		return nil, err
@@ -4,6 +4,7 @@ import (
 	"context"
 	"testing"
 
+	"github.com/GitDataAI/jiaozifs/block/spool"
 	"github.com/stretchr/testify/require"
 )
 
@@ -16,6 +17,15 @@ func TestAdapterFromConfig(t *testing.T) {
 		require.Equal(t, "local", adapter.BlockstoreType())
 	})
 
+	t.Run("spool ignored", func(t *testing.T) {
+		data := `{"Type":"local","Local":{"Path":"~/.jiaozifs/blockstore"},"Spool":{"Path":"/tmp/spool"}}`
+		adapter, err := AdapterFromConfig(ctx, data)
+		require.NoError(t, err)
+		require.Equal(t, "local", adapter.BlockstoreType())
+		_, isSpool := adapter.(*spool.Adapter)
+		require.False(t, isSpool)
+	})
+
 	t.Run("marshal fail", func(t *testing.T) {
 		data := `{"Type":"local",DefaultNamespacePrefix":null,"Local":{"Path":"~/.jiaozifs/blockstore","ImportEnabled":false,"ImportHidden":false,"AllowedExternalPrefixes":null},"S3":null,"Azure":null,"GS":null}`
 		_, err := AdapterFromConfig(ctx, data)
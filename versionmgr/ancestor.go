@@ -0,0 +1,65 @@
+package versionmgr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+)
+
+// DefaultAncestorSearchLimit max commits loaded by IsAncestor before giving up
+var DefaultAncestorSearchLimit = 100000
+
+// ErrAncestorSearchLimit returned when ancestor can not be decided within the search limit
+var ErrAncestorSearchLimit = errors.New("too many commits visited to decide ancestor")
+
+// IsAncestor check whether ancestorHash is reachable from descendantHash by following parents, a commit is ancestor of itself.
+// commit graph is walked breadth first from descendant and stop as soon as the ancestor is met, only hashes of visited commits
+// are kept in memory
+func IsAncestor(ctx context.Context, commitRepo models.ICommitRepo, ancestorHash, descendantHash hash.Hash) (bool, error) {
+	return IsAncestorWithLimit(ctx, commitRepo, ancestorHash, descendantHash, DefaultAncestorSearchLimit)
+}
+
+// IsAncestorWithLimit same as IsAncestor, return ErrAncestorSearchLimit if more than limit commits need to be visited
+func IsAncestorWithLimit(ctx context.Context, commitRepo models.ICommitRepo, ancestorHash, descendantHash hash.Hash, limit int) (bool, error) {
+	if ancestorHash.IsEmpty() || descendantHash.IsEmpty() {
+		return false, nil
+	}
+	if bytes.Equal(ancestorHash, descendantHash) {
+		return true, nil
+	}
+
+	visited := map[string]struct{}{descendantHash.Hex(): {}}
+	queue := []hash.Hash{descendantHash}
+	for len(queue) > 0 {
+		if len(visited) > limit {
+			return false, ErrAncestorSearchLimit
+		}
+
+		select {
+		case <-ctx.Done():
+			return false, ctx.Err()
+		default:
+		}
+
+		commit, err := commitRepo.Commit(ctx, queue[0])
+		if err != nil {
+			return false, err
+		}
+		queue = queue[1:]
+
+		for _, parentHash := range commit.ParentHashes {
+			if bytes.Equal(parentHash, ancestorHash) {
+				return true, nil
+			}
+			if _, ok := visited[parentHash.Hex()]; ok {
+				continue
+			}
+			visited[parentHash.Hex()] = struct{}{}
+			queue = append(queue, parentHash)
+		}
+	}
+	return false, nil
+}
@@ -0,0 +1,72 @@
+package versionmgr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsAncestor(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repoID := uuid.New()
+	commitRepo := models.NewCommitRepo(db, repoID)
+	//     | -> c -------
+	//     |             |
+	//a ------> b ------d--f1-f2---f
+	//          |
+	//          | ----------------->e
+	testData := `
+a|
+b|a
+c|a
+d|b,c
+f1|d
+f2|f1
+f|f2
+e|b
+`
+	_, err := loadCommitTestData(ctx, commitRepo, testData)
+	require.NoError(t, err)
+
+	testCases := []struct {
+		ancestor   string
+		descendant string
+		expect     bool
+	}{
+		{"a", "a", true},
+		{"a", "f", true},
+		{"c", "f", true},
+		{"b", "e", true},
+		{"f", "a", false},
+		{"c", "e", false},
+		{"e", "f", false},
+	}
+	for _, tc := range testCases {
+		isAncestor, err := IsAncestor(ctx, commitRepo, hash.Hash(tc.ancestor), hash.Hash(tc.descendant))
+		require.NoError(t, err)
+		require.Equal(t, tc.expect, isAncestor, "%s is ancestor of %s", tc.ancestor, tc.descendant)
+	}
+
+	t.Run("empty hash", func(t *testing.T) {
+		isAncestor, err := IsAncestor(ctx, commitRepo, hash.Empty, hash.Hash("f"))
+		require.NoError(t, err)
+		require.False(t, isAncestor)
+	})
+
+	t.Run("exceed limit", func(t *testing.T) {
+		_, err := IsAncestorWithLimit(ctx, commitRepo, hash.Hash("a"), hash.Hash("f"), 2)
+		require.ErrorIs(t, err, ErrAncestorSearchLimit)
+
+		isAncestor, err := IsAncestorWithLimit(ctx, commitRepo, hash.Hash("f2"), hash.Hash("f"), 2)
+		require.NoError(t, err)
+		require.True(t, isAncestor)
+	})
+}
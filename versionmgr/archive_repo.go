@@ -1,7 +1,9 @@
 package versionmgr
 
 import (
+	"archive/tar"
 	"archive/zip"
+	"compress/gzip"
 	"context"
 	"errors"
 	"fmt"
@@ -46,17 +48,21 @@ func (repo *RepoArchiver) ArchiveZip(ctx context.Context, dest string) error {
 	}
 	defer zipFile.Close() //nolint:errcheck
 
-	zipWriter := zip.NewWriter(zipFile)
-	defer zipWriter.Close() //nolint:errcheck
+	return repo.WriteZip(ctx, zipFile)
+}
+
+// WriteZip stream zip archive to w, blob data is read one by one while walking the tree
+func (repo *RepoArchiver) WriteZip(ctx context.Context, w io.Writer) error {
+	zipWriter := zip.NewWriter(w)
 
-	_, err = zipWriter.CreateHeader(&zip.FileHeader{
+	_, err := zipWriter.CreateHeader(&zip.FileHeader{
 		Name: repo.rootPath + "/",
 	})
 	if err != nil {
 		return err
 	}
 
-	return repo.walker.Walk(ctx, func(entry *models.TreeEntry, blob *models.Blob, path string) error {
+	err = repo.walker.Walk(ctx, func(entry *models.TreeEntry, blob *models.Blob, path string) error {
 		if entry.IsDir {
 			path = fmt.Sprintf("%s%c", path, os.PathSeparator)
 			_, err = zipWriter.CreateHeader(&zip.FileHeader{
@@ -79,6 +85,74 @@ func (repo *RepoArchiver) ArchiveZip(ctx context.Context, dest string) error {
 		_, err = io.Copy(f, reader)
 		return err
 	})
+	if err != nil {
+		return err
+	}
+	return zipWriter.Close()
+}
+
+func (repo *RepoArchiver) ArchiveTarGz(ctx context.Context, dest string) error {
+	tarFile, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	defer tarFile.Close() //nolint:errcheck
+
+	return repo.WriteTarGz(ctx, tarFile)
+}
+
+// WriteTarGz stream gzip compressed tar archive to w, blob data is read one by one while walking the tree
+func (repo *RepoArchiver) WriteTarGz(ctx context.Context, w io.Writer) error {
+	gzWriter := gzip.NewWriter(w)
+	tarWriter := tar.NewWriter(gzWriter)
+
+	err := tarWriter.WriteHeader(&tar.Header{
+		Typeflag: tar.TypeDir,
+		Name:     repo.rootPath + "/",
+		Mode:     0755,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = repo.walker.Walk(ctx, func(entry *models.TreeEntry, blob *models.Blob, path string) error {
+		if entry.IsDir {
+			return tarWriter.WriteHeader(&tar.Header{
+				Typeflag: tar.TypeDir,
+				Name:     path2.Join(repo.rootPath, path) + "/",
+				Mode:     0755,
+			})
+		}
+
+		reader, err := repo.getReader(ctx, blob, path)
+		if err != nil {
+			return err
+		}
+		defer reader.Close() //nolint
+
+		err = tarWriter.WriteHeader(&tar.Header{
+			Typeflag: tar.TypeReg,
+			Name:     path2.Join(repo.rootPath, path),
+			Mode:     0644,
+			Size:     blob.Size,
+			ModTime:  blob.UpdatedAt,
+		})
+		if err != nil {
+			return err
+		}
+
+		_, err = io.Copy(tarWriter, reader)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	err = tarWriter.Close()
+	if err != nil {
+		return err
+	}
+	return gzWriter.Close()
 }
 
 func (repo *RepoArchiver) ArchiveCar(ctx context.Context, dest string) error {
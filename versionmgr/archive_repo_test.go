@@ -1,7 +1,9 @@
 package versionmgr
 
 import (
+	"archive/tar"
 	"bytes"
+	"compress/gzip"
 	"context"
 	"fmt"
 	"io"
@@ -91,6 +93,63 @@ func TestRepoArchiver_ArchiveZip(t *testing.T) {
 	fmt.Println(tmpFile)
 }
 
+func TestRepoArchiver_WriteTarGz(t *testing.T) {
+	ctx := context.Background()
+	wk := &mockWalker{
+		dirs: []string{
+			"a",
+			"a/b",
+		},
+		files: map[string][]byte{
+			"1.txt":     []byte("111111111111111111111111"),
+			"a/2.txt":   []byte("222222222222222222222222"),
+			"a/b/4.txt": []byte("4444444444444444444444444444"),
+		},
+	}
+	archiver := NewRepoArchiver(
+		"testdir",
+		wk,
+		func(ctx context.Context, _ *models.Blob, s string) (io.ReadCloser, error) {
+			data, ok := wk.files[s]
+			if !ok {
+				return nil, fmt.Errorf("data not found %s", s)
+			}
+			return utils.CloserWraper{Reader: bytes.NewReader(data)}, nil
+		},
+	)
+
+	buf := bytes.NewBuffer(nil)
+	err := archiver.WriteTarGz(ctx, buf)
+	require.NoError(t, err)
+
+	gzReader, err := gzip.NewReader(buf)
+	require.NoError(t, err)
+	tarReader := tar.NewReader(gzReader)
+
+	var dirs []string
+	files := map[string][]byte{}
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		if header.Typeflag == tar.TypeDir {
+			dirs = append(dirs, header.Name)
+			continue
+		}
+		data, err := io.ReadAll(tarReader)
+		require.NoError(t, err)
+		files[header.Name] = data
+	}
+
+	require.Equal(t, []string{"testdir/", "testdir/a/", "testdir/a/b/"}, dirs)
+	require.Len(t, files, len(wk.files))
+	for file, data := range wk.files {
+		require.Equal(t, data, files[path.Join(archiver.rootPath, file)])
+	}
+}
+
 func TestRepoArchiver_ArchiveCar(t *testing.T) {
 	ctx := context.Background()
 	wk := &mockWalker{
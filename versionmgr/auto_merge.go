@@ -0,0 +1,213 @@
+package versionmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/block/params"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/google/uuid"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var autoMergeLog = logging.Logger("auto_merge")
+
+// DefaultAutoMergeInterval how often merge requests queued for auto merge are retried
+const DefaultAutoMergeInterval = time.Minute
+
+// CheckMergeRequest return ErrMergeRuleUnmet if reviewers, checklist, approvals or required checks of merge rules and
+// protection of target branch are not satisfied by merge request
+func CheckMergeRequest(ctx context.Context, repo models.IRepo, repoModel *models.Repository, mergeRequest *models.MergeRequest, sourceBranch, targetBranch *models.Branch, secretKey []byte) error {
+	rules := repoModel.MergeRulesOf(targetBranch.Name)
+	err := mergeRequest.CheckMergeRules(rules)
+	if err != nil {
+		return err
+	}
+	err = mergeRequest.CheckApprovals(targetBranch.RequiredApprovals)
+	if err != nil {
+		return err
+	}
+
+	if !slices.ContainsFunc(rules, func(rule models.MergeRule) bool {
+		return len(rule.RequiredChecks) > 0
+	}) {
+		return nil
+	}
+	attestations, err := repo.AttestationRepo().List(ctx, models.NewListAttestationParams().SetRepositoryID(mergeRequest.SourceRepoID).SetCommitHash(sourceBranch.CommitHash))
+	if err != nil {
+		return err
+	}
+	return models.CheckRequiredChecks(rules, attestations, secretKey)
+}
+
+// AutoMerger merge requests queued for auto merge once they can be merged. merges are made one at a time in the order
+// auto merge was enabled, so merge requests into a busy branch are merged in turn on top of each other
+type AutoMerger struct {
+	repo                models.IRepo
+	publicAdapterConfig params.AdapterConfig
+	secretKey           []byte
+	interval            time.Duration
+	buildAdapter        func(ctx context.Context, repoModel *models.Repository) (block.Adapter, error)
+
+	trigger chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func NewAutoMerger(repo models.IRepo, publicAdapterConfig params.AdapterConfig, secretKey []byte, interval time.Duration) *AutoMerger {
+	if interval <= 0 {
+		interval = DefaultAutoMergeInterval
+	}
+	merger := &AutoMerger{
+		repo:                repo,
+		publicAdapterConfig: publicAdapterConfig,
+		secretKey:           secretKey,
+		interval:            interval,
+		trigger:             make(chan struct{}, 1),
+	}
+	merger.buildAdapter = func(ctx context.Context, repoModel *models.Repository) (block.Adapter, error) {
+		return adapterOf(ctx, repoModel, merger.publicAdapterConfig)
+	}
+	return merger
+}
+
+// Start merge queued merge requests every interval or when triggered in background until Stop called
+func (merger *AutoMerger) Start(ctx context.Context) {
+	ctx, merger.cancel = context.WithCancel(ctx)
+	merger.done = make(chan struct{})
+	go func() {
+		defer close(merger.done)
+		ticker := time.NewTicker(merger.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-merger.trigger:
+			}
+			merged, err := merger.MergeQueued(ctx)
+			if err != nil {
+				autoMergeLog.Errorf("auto merge %v", err)
+			}
+			if len(merged) > 0 {
+				autoMergeLog.Infof("auto merge %d merge requests", len(merged))
+			}
+		}
+	}()
+}
+
+// Stop background merging and wait for the running round
+func (merger *AutoMerger) Stop() {
+	if merger.cancel == nil {
+		return
+	}
+	merger.cancel()
+	<-merger.done
+}
+
+// Trigger ask for a round soon without waiting for interval, called when a queued merge request may become mergeable
+func (merger *AutoMerger) Trigger() {
+	select {
+	case merger.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// MergeQueued try queued merge requests one by one, return merge requests merged. merge request not mergeable yet, that
+// is rules unmet or conflict with target branch, stays in queue without blocking later ones
+func (merger *AutoMerger) MergeQueued(ctx context.Context) ([]*models.MergeRequest, error) {
+	mergeRequests, err := merger.repo.MergeRequestRepo().ListAutoMerge(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	adapters := make(map[uuid.UUID]block.Adapter)
+	var merged []*models.MergeRequest
+	for index := range mergeRequests {
+		if ctx.Err() != nil {
+			return merged, ctx.Err()
+		}
+		mergeRequest := &mergeRequests[index]
+		ok, err := merger.tryMerge(ctx, mergeRequest, adapters)
+		if err != nil {
+			autoMergeLog.Warnf("auto merge request %d of repository %s %v", mergeRequest.Sequence, mergeRequest.TargetRepoID, err)
+			continue
+		}
+		if ok {
+			merged = append(merged, mergeRequest)
+		}
+	}
+	return merged, nil
+}
+
+func (merger *AutoMerger) tryMerge(ctx context.Context, mergeRequest *models.MergeRequest, adapters map[uuid.UUID]block.Adapter) (bool, error) {
+	repoModel, err := merger.repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetID(mergeRequest.TargetRepoID))
+	if err != nil {
+		return false, err
+	}
+	if repoModel.Archived {
+		return false, nil
+	}
+
+	operator, err := merger.repo.UserRepo().Get(ctx, models.NewGetUserParams().SetID(mergeRequest.AutoMergeBy))
+	if err != nil {
+		return false, err
+	}
+	sourceBranch, err := merger.repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetID(mergeRequest.SourceBranchID))
+	if err != nil {
+		return false, err
+	}
+	targetBranch, err := merger.repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetID(mergeRequest.TargetBranchID))
+	if err != nil {
+		return false, err
+	}
+
+	err = CheckMergeRequest(ctx, merger.repo, repoModel, mergeRequest, sourceBranch, targetBranch, merger.secretKey)
+	if errors.Is(err, models.ErrMergeRuleUnmet) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	adapter, ok := adapters[repoModel.ID]
+	if !ok {
+		adapter, err = merger.buildAdapter(ctx, repoModel)
+		if err != nil {
+			return false, err
+		}
+		adapters[repoModel.ID] = adapter
+	}
+
+	msg := mergeRequest.AutoMergeMsg
+	if len(msg) == 0 {
+		msg = fmt.Sprintf("Merge request #%d %s", mergeRequest.Sequence, mergeRequest.Title)
+	}
+	err = merger.repo.Transaction(ctx, func(repo models.IRepo) error {
+		workRepo := NewWorkRepositoryFromAdapter(ctx, operator, repoModel, repo, adapter)
+		err := workRepo.CheckOut(ctx, InBranch, targetBranch.Name)
+		if err != nil {
+			return err
+		}
+		// only conflicts merged by drivers are resolved, others wait for the source branch to be updated
+		_, err = workRepo.Merge(ctx, sourceBranch.CommitHash, msg, workRepo.StrategyResolver(ctx, WithMergeDrivers(recursiveMergeStrategy{})))
+		if err != nil {
+			return err
+		}
+		return repo.MergeRequestRepo().UpdateByID(ctx, models.NewUpdateMergeRequestParams(mergeRequest.TargetRepoID, mergeRequest.Sequence).
+			SetState(models.MergeStateMerged).
+			ClearAutoMerge())
+	})
+	if errors.Is(err, ErrConflict) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,102 @@
+package versionmgr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAutoMerger(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	project, err := makeRepository(ctx, repo, user, "testAutoMerge")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	_, err = addChangesToWip(ctx, workRepo, "main", "base commit", `
+1|a.txt	|aaa
+`)
+	require.NoError(t, err)
+
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+	mainBranch := workRepo.CurBranch()
+	featA, err := workRepo.CreateBranch(ctx, "featA")
+	require.NoError(t, err)
+	featB, err := workRepo.CreateBranch(ctx, "featB")
+	require.NoError(t, err)
+	_, err = addChangesToWip(ctx, workRepo, "featA", "featA commit", `
+1|b.txt	|bbb
+`)
+	require.NoError(t, err)
+	_, err = addChangesToWip(ctx, workRepo, "featB", "featB commit", `
+1|c.txt	|ccc
+`)
+	require.NoError(t, err)
+
+	queue := func(source *models.Branch) *models.MergeRequest {
+		mergeRequest, err := repo.MergeRequestRepo().Insert(ctx, &models.MergeRequest{
+			TargetBranchID: mainBranch.ID,
+			SourceBranchID: source.ID,
+			SourceRepoID:   project.ID,
+			TargetRepoID:   project.ID,
+			Title:          "merge " + source.Name,
+			MergeState:     models.MergeStateInit,
+			AuthorID:       user.ID,
+			CreatedAt:      time.Now(),
+			UpdatedAt:      time.Now(),
+		})
+		require.NoError(t, err)
+		require.NoError(t, repo.MergeRequestRepo().UpdateByID(ctx, models.NewUpdateMergeRequestParams(project.ID, mergeRequest.Sequence).SetAutoMerge(user.ID, "")))
+		return mergeRequest
+	}
+	mrA := queue(featA)
+	mrB := queue(featB)
+
+	merger := NewAutoMerger(repo, nil, nil, time.Minute)
+	merger.buildAdapter = func(_ context.Context, _ *models.Repository) (block.Adapter, error) {
+		return adapter, nil
+	}
+
+	// approval required by protection of main is missing
+	require.NoError(t, repo.BranchRepo().UpdateProtection(ctx, mainBranch.ID, models.BranchProtection{RequiredApprovals: 1}))
+	merged, err := merger.MergeQueued(ctx)
+	require.NoError(t, err)
+	require.Len(t, merged, 0)
+
+	require.NoError(t, repo.BranchRepo().UpdateProtection(ctx, mainBranch.ID, models.BranchProtection{}))
+	merged, err = merger.MergeQueued(ctx)
+	require.NoError(t, err)
+	require.Len(t, merged, 2)
+	require.Equal(t, mrA.Sequence, merged[0].Sequence)
+	require.Equal(t, mrB.Sequence, merged[1].Sequence)
+
+	queued, err := repo.MergeRequestRepo().ListAutoMerge(ctx)
+	require.NoError(t, err)
+	require.Len(t, queued, 0)
+
+	mergeRequest, err := repo.MergeRequestRepo().Get(ctx, models.NewGetMergeRequestParams().SetTargetRepo(project.ID).SetNumber(mrB.Sequence))
+	require.NoError(t, err)
+	require.Equal(t, models.MergeStateMerged, mergeRequest.MergeState)
+	require.False(t, mergeRequest.AutoMerge)
+
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+	workTree, err := workRepo.RootTree(ctx)
+	require.NoError(t, err)
+	for _, path := range []string{"a.txt", "b.txt", "c.txt"} {
+		_, _, err = workTree.FindBlob(ctx, path)
+		require.NoError(t, err)
+	}
+}
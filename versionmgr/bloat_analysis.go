@@ -0,0 +1,187 @@
+package versionmgr
+
+import (
+	"context"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+)
+
+const (
+	// DefaultBloatAmount number of largest files and growing prefixes returned by default
+	DefaultBloatAmount = 20
+	// DefaultBloatCommits number of recent commits counted for growth by default
+	DefaultBloatCommits = 100
+	// DefaultBloatDepth number of path components a prefix is made of by default
+	DefaultBloatDepth = 1
+)
+
+type BloatAnalysisParams struct {
+	amount  int
+	commits int
+	depth   int
+}
+
+func NewBloatAnalysisParams() *BloatAnalysisParams {
+	return &BloatAnalysisParams{
+		amount:  DefaultBloatAmount,
+		commits: DefaultBloatCommits,
+		depth:   DefaultBloatDepth,
+	}
+}
+
+// SetAmount max number of largest files and growing prefixes to return, zero or negative keeps default
+func (params *BloatAnalysisParams) SetAmount(amount int) *BloatAnalysisParams {
+	if amount > 0 {
+		params.amount = amount
+	}
+	return params
+}
+
+// SetCommits number of most recent commits whose changes are counted for growth, zero or negative keeps default
+func (params *BloatAnalysisParams) SetCommits(commits int) *BloatAnalysisParams {
+	if commits > 0 {
+		params.commits = commits
+	}
+	return params
+}
+
+// SetDepth number of leading path components grouped into a prefix, zero or negative keeps default
+func (params *BloatAnalysisParams) SetDepth(depth int) *BloatAnalysisParams {
+	if depth > 0 {
+		params.depth = depth
+	}
+	return params
+}
+
+// LargeFile file in tree of analysed commit
+type LargeFile struct {
+	Path string
+	Hash hash.Hash
+	Size int64
+}
+
+// PrefixGrowth bytes added and removed under prefix by recent commits, files in root are under "/"
+type PrefixGrowth struct {
+	Prefix       string
+	Commits      int64
+	BytesAdded   int64
+	BytesRemoved int64
+}
+
+// NetBytes bytes prefix grown by, negative when prefix shrunk
+func (growth PrefixGrowth) NetBytes() int64 {
+	return growth.BytesAdded - growth.BytesRemoved
+}
+
+// BloatAnalysis what takes most space in a commit and where space was added recently
+type BloatAnalysis struct {
+	// Commits number of commits counted for growth, less than requested when history is shorter
+	Commits         int
+	LargestFiles    []LargeFile
+	GrowingPrefixes []PrefixGrowth
+}
+
+// AnalyzeBloat list largest files in tree of commit and prefixes growing fastest over recent commits reachable from it.
+// growth is diffed against first parent of each commit like commit stats, prefixes shrinking are left out
+func (repository *WorkRepository) AnalyzeBloat(ctx context.Context, commitHash hash.Hash, params *BloatAnalysisParams) (*BloatAnalysis, error) {
+	commitRepo := repository.repo.CommitRepo(repository.repoModel.ID)
+	commit, err := commitRepo.Commit(ctx, commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	largest, err := repository.largestFiles(ctx, commit, params.amount)
+	if err != nil {
+		return nil, err
+	}
+
+	analysis := &BloatAnalysis{LargestFiles: largest}
+	prefixes := make(map[string]*PrefixGrowth)
+	iter := NewCommitIterCTime(ctx, NewWrapCommitNode(commitRepo, commit), nil, nil)
+	for analysis.Commits < params.commits {
+		node, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		analysis.Commits++
+
+		touched := make(map[string]bool)
+		err = repository.forEachCommitChange(ctx, repository.repo, node.Commit(), func(change commitChange) error {
+			prefix := pathPrefix(change.path, params.depth)
+			growth, ok := prefixes[prefix]
+			if !ok {
+				growth = &PrefixGrowth{Prefix: prefix}
+				prefixes[prefix] = growth
+			}
+			if !touched[prefix] {
+				touched[prefix] = true
+				growth.Commits++
+			}
+			growth.BytesAdded += change.toSize
+			growth.BytesRemoved += change.fromSize
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	analysis.GrowingPrefixes = make([]PrefixGrowth, 0, len(prefixes))
+	for _, growth := range prefixes {
+		if growth.NetBytes() > 0 {
+			analysis.GrowingPrefixes = append(analysis.GrowingPrefixes, *growth)
+		}
+	}
+	sort.Slice(analysis.GrowingPrefixes, func(i, j int) bool {
+		a, b := analysis.GrowingPrefixes[i], analysis.GrowingPrefixes[j]
+		if a.NetBytes() != b.NetBytes() {
+			return a.NetBytes() > b.NetBytes()
+		}
+		return a.Prefix < b.Prefix
+	})
+	analysis.GrowingPrefixes = analysis.GrowingPrefixes[:min(len(analysis.GrowingPrefixes), params.amount)]
+	return analysis, nil
+}
+
+func (repository *WorkRepository) largestFiles(ctx context.Context, commit *models.Commit, amount int) ([]LargeFile, error) {
+	fileTreeRepo := repository.repo.FileTreeRepo(repository.repoModel.ID)
+	workTree, err := newWorkTree(ctx, fileTreeRepo, repository.repoModel, commit.TreeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]LargeFile, 0)
+	err = NewFileWalk(workTree.object, workTree.root).Walk(ctx, func(_ *models.TreeEntry, blob *models.Blob, path string) error {
+		if blob == nil {
+			return nil
+		}
+		files = append(files, LargeFile{Path: path, Hash: blob.Hash, Size: blob.Size})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if files[i].Size != files[j].Size {
+			return files[i].Size > files[j].Size
+		}
+		return files[i].Path < files[j].Path
+	})
+	return files[:min(len(files), amount)], nil
+}
+
+// pathPrefix first depth directories of path, "/" for files in root
+func pathPrefix(path string, depth int) string {
+	components := strings.Split(path, "/")
+	if len(components) == 1 {
+		return "/"
+	}
+	return strings.Join(components[:min(len(components)-1, depth)], "/")
+}
@@ -0,0 +1,73 @@
+package versionmgr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAnalyzeBloat(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	project, err := makeRepository(ctx, repo, user, "bloat")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	_, err = addChangesToWip(ctx, workRepo, "main", "first", `
+1|a.txt	|aaaa
+1|data/raw/b.bin	|bbbbbbbbbb
+1|docs/c.md	|cc
+`)
+	require.NoError(t, err)
+	_, err = addChangesToWip(ctx, workRepo, "main", "second", `
+1|data/raw/d.bin	|dddddd
+2|docs/c.md	|
+`)
+	require.NoError(t, err)
+	last, err := addChangesToWip(ctx, workRepo, "main", "third", `
+1|data/tmp/e.bin	|eee
+3|a.txt	|a
+`)
+	require.NoError(t, err)
+
+	t.Run("all commits", func(t *testing.T) {
+		analysis, err := workRepo.AnalyzeBloat(ctx, last.Hash, NewBloatAnalysisParams())
+		require.NoError(t, err)
+		require.Equal(t, 3, analysis.Commits)
+
+		require.Len(t, analysis.LargestFiles, 4)
+		require.Equal(t, "data/raw/b.bin", analysis.LargestFiles[0].Path)
+		require.Equal(t, int64(10), analysis.LargestFiles[0].Size)
+		require.Equal(t, "a.txt", analysis.LargestFiles[3].Path)
+
+		// docs shrunk back to nothing and is left out
+		require.Len(t, analysis.GrowingPrefixes, 2)
+		require.Equal(t, "data", analysis.GrowingPrefixes[0].Prefix)
+		require.Equal(t, int64(3), analysis.GrowingPrefixes[0].Commits)
+		require.Equal(t, int64(19), analysis.GrowingPrefixes[0].NetBytes())
+		require.Equal(t, "/", analysis.GrowingPrefixes[1].Prefix)
+		require.Equal(t, int64(1), analysis.GrowingPrefixes[1].NetBytes())
+	})
+
+	t.Run("recent commits by depth", func(t *testing.T) {
+		analysis, err := workRepo.AnalyzeBloat(ctx, last.Hash, NewBloatAnalysisParams().SetCommits(2).SetDepth(2).SetAmount(1))
+		require.NoError(t, err)
+		require.Equal(t, 2, analysis.Commits)
+		require.Len(t, analysis.LargestFiles, 1)
+
+		require.Len(t, analysis.GrowingPrefixes, 1)
+		require.Equal(t, "data/raw", analysis.GrowingPrefixes[0].Prefix)
+		require.Equal(t, int64(6), analysis.GrowingPrefixes[0].NetBytes())
+	})
+}
@@ -129,6 +129,10 @@ type ChangePair struct {
 	Left       IChange
 	Right      IChange
 	IsConflict bool
+	// Driver name of merge driver which merges both sides without conflict or locates ConflictKeys
+	Driver string
+	// ConflictKeys parts of file changed differently on both sides found by Driver
+	ConflictKeys []string
 }
 
 func (changePair ChangePair) Path() string {
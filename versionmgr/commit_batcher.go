@@ -0,0 +1,227 @@
+package versionmgr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var batchLog = logging.Logger("commit_batcher")
+
+var (
+	DefaultBatchWindow    = 200 * time.Millisecond
+	DefaultBatchMaxWrites = 1000
+)
+
+// ErrObjectExist write without replace to a path which already has different content
+var ErrObjectExist = errors.New("object exist")
+
+// BatchWrite a small write on branch, writes submitted within a window are coalesced into one commit. blob nil means to
+// delete the path
+type BatchWrite struct {
+	Path      string
+	Blob      *models.Blob
+	IsReplace bool
+}
+
+// CommitReceipt deliver result of a batched write, the write is contained in commit CommitHash
+type CommitReceipt struct {
+	Path       string
+	CommitHash hash.Hash
+	// Index position of this write in batch
+	Index int
+	// BatchSize number of writes coalesced in the commit
+	BatchSize int
+}
+
+// CommitBatcher coalesce writes of the same operator to the same branch within window into one commit authored by that
+// operator, this keep commit graph and ref updates small for high-frequency writers. a batch is committed early when it
+// reaches maxWrites, batches of different operators on one branch are committed one after another
+type CommitBatcher struct {
+	window    time.Duration
+	maxWrites int
+
+	lk          sync.Mutex
+	pending     map[string]*commitBatch
+	branchLocks map[string]*sync.Mutex
+}
+
+type commitBatch struct {
+	workRepo   *WorkRepository
+	branch     string
+	branchLock *sync.Mutex
+	writes     []*pendingWrite
+	timer      *time.Timer
+}
+
+type pendingWrite struct {
+	write   BatchWrite
+	receipt chan batchResult
+}
+
+type batchResult struct {
+	receipt *CommitReceipt
+	err     error
+}
+
+func NewCommitBatcher(window time.Duration, maxWrites int) *CommitBatcher {
+	if window <= 0 {
+		window = DefaultBatchWindow
+	}
+	if maxWrites <= 0 {
+		maxWrites = DefaultBatchMaxWrites
+	}
+	return &CommitBatcher{
+		window:      window,
+		maxWrites:   maxWrites,
+		pending:     make(map[string]*commitBatch),
+		branchLocks: make(map[string]*sync.Mutex),
+	}
+}
+
+// Submit queue write of operator of workRepo to branch and wait for its commit. the batch is committed by the work
+// repository of its first write, all writes in it are of the same operator. returning because of ctx done does not
+// withdraw the write, it may still be committed
+func (batcher *CommitBatcher) Submit(ctx context.Context, workRepo *WorkRepository, branch string, write BatchWrite) (*CommitReceipt, error) {
+	write.Path = CleanPath(write.Path)
+	pw := &pendingWrite{
+		write:   write,
+		receipt: make(chan batchResult, 1),
+	}
+
+	branchKey := fmt.Sprintf("%s/%s", workRepo.repoModel.ID, branch)
+	key := fmt.Sprintf("%s/%s", branchKey, workRepo.operator.ID)
+	batcher.lk.Lock()
+	batch, ok := batcher.pending[key]
+	if !ok {
+		branchLock, ok := batcher.branchLocks[branchKey]
+		if !ok {
+			branchLock = &sync.Mutex{}
+			batcher.branchLocks[branchKey] = branchLock
+		}
+		batch = &commitBatch{
+			workRepo:   workRepo,
+			branch:     branch,
+			branchLock: branchLock,
+		}
+		batcher.pending[key] = batch
+		batch.timer = time.AfterFunc(batcher.window, func() {
+			batcher.flush(key, batch)
+		})
+	}
+	batch.writes = append(batch.writes, pw)
+	if len(batch.writes) >= batcher.maxWrites && batch.timer.Stop() {
+		go batcher.flush(key, batch)
+	}
+	batcher.lk.Unlock()
+
+	select {
+	case result := <-pw.receipt:
+		return result.receipt, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (batcher *CommitBatcher) flush(key string, batch *commitBatch) {
+	batcher.lk.Lock()
+	if batcher.pending[key] == batch {
+		delete(batcher.pending, key)
+	}
+	writes := batch.writes
+	batcher.lk.Unlock()
+
+	// branch head moves by compare and swap, batches of other operators would conflict if committed at the same time
+	batch.branchLock.Lock()
+	errs, commitHash, err := batcher.commit(context.Background(), batch.workRepo, batch.branch, writes)
+	batch.branchLock.Unlock()
+	for index, pw := range writes {
+		if err != nil {
+			pw.receipt <- batchResult{err: err}
+			continue
+		}
+		if errs[index] != nil {
+			pw.receipt <- batchResult{err: errs[index]}
+			continue
+		}
+		pw.receipt <- batchResult{receipt: &CommitReceipt{
+			Path:       pw.write.Path,
+			CommitHash: commitHash,
+			Index:      index,
+			BatchSize:  len(writes),
+		}}
+	}
+}
+
+// commit apply writes in order, a write which fails is reported alone and does not fail the others
+func (batcher *CommitBatcher) commit(ctx context.Context, workRepo *WorkRepository, branch string, writes []*pendingWrite) ([]error, hash.Hash, error) {
+	err := workRepo.CheckOut(ctx, InBranch, branch)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	errs := make([]error, len(writes))
+	changed := 0
+	msg := fmt.Sprintf("batch commit of %d writes", len(writes))
+	commit, err := workRepo.CommitOnBranch(ctx, msg, func(root *WorkTree) error {
+		changed = 0
+		for index, pw := range writes {
+			applied, writeErr, err := applyBatchWrite(ctx, root, pw.write)
+			if err != nil {
+				return err
+			}
+			errs[index] = writeErr
+			if applied {
+				changed++
+			}
+		}
+		if changed == 0 {
+			return errNothingToBatch
+		}
+		return nil
+	})
+	if errors.Is(err, errNothingToBatch) {
+		// all writes failed or already exist, nothing to commit
+		return errs, workRepo.CurBranch().CommitHash, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	batchLog.Debugf("commit %s on branch %s with %d writes, %d changed", commit.Hash, branch, len(writes), changed)
+	return errs, commit.Hash, nil
+}
+
+var errNothingToBatch = errors.New("nothing to commit in batch")
+
+// applyBatchWrite return whether tree changed, error of this single write and error which should abort the batch
+func applyBatchWrite(ctx context.Context, root *WorkTree, write BatchWrite) (bool, error, error) {
+	oldBlob, _, err := root.FindBlob(ctx, write.Path)
+	if err != nil && !errors.Is(err, ErrPathNotFound) {
+		return false, nil, err
+	}
+
+	if write.Blob == nil {
+		if oldBlob == nil {
+			return false, fmt.Errorf("path %s %w", write.Path, ErrPathNotFound), nil
+		}
+		return true, nil, root.RemoveEntry(ctx, write.Path)
+	}
+
+	if oldBlob == nil {
+		return true, nil, root.AddLeaf(ctx, write.Path, write.Blob)
+	}
+	if bytes.Equal(oldBlob.CheckSum, write.Blob.CheckSum) {
+		return false, nil, nil
+	}
+	if !write.IsReplace {
+		return false, fmt.Errorf("path %s %w", write.Path, ErrObjectExist), nil
+	}
+	return true, nil, root.ReplaceLeaf(ctx, write.Path, write.Blob)
+}
@@ -0,0 +1,150 @@
+package versionmgr
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitBatcherSubmit(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+
+	project, err := makeRepository(ctx, repo, user, "testBatchCommit")
+	require.NoError(t, err)
+
+	writeBlob := func(workRepo *WorkRepository, content string) *models.Blob {
+		blob, err := workRepo.WriteBlob(ctx, bytes.NewReader([]byte(content)), int64(len(content)), models.DefaultLeafProperty())
+		require.NoError(t, err)
+		return blob
+	}
+
+	batcher := NewCommitBatcher(time.Second, 3)
+
+	t.Run("coalesce writes", func(t *testing.T) {
+		receipts := make([]*CommitReceipt, 3)
+		wg := sync.WaitGroup{}
+		for i := 0; i < 3; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+				receipt, err := batcher.Submit(ctx, workRepo, "main", BatchWrite{
+					Path: fmt.Sprintf("data/%d.txt", i),
+					Blob: writeBlob(workRepo, fmt.Sprintf("%d", i)),
+				})
+				require.NoError(t, err)
+				receipts[i] = receipt
+			}(i)
+		}
+		wg.Wait()
+
+		for _, receipt := range receipts {
+			require.Equal(t, receipts[0].CommitHash, receipt.CommitHash)
+			require.Equal(t, 3, receipt.BatchSize)
+		}
+
+		branch, err := repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(project.ID).SetName("main"))
+		require.NoError(t, err)
+		require.Equal(t, receipts[0].CommitHash, branch.CommitHash)
+
+		workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+		workTree, err := workRepo.RootTree(ctx)
+		require.NoError(t, err)
+		entries, err := workTree.Ls(ctx, "data")
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+	})
+
+	t.Run("single write fail", func(t *testing.T) {
+		batcher := NewCommitBatcher(10*time.Millisecond, 10)
+		workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+		_, err := batcher.Submit(ctx, workRepo, "main", BatchWrite{
+			Path: "data/0.txt",
+			Blob: writeBlob(workRepo, "changed"),
+		})
+		require.ErrorIs(t, err, ErrObjectExist)
+
+		_, err = batcher.Submit(ctx, workRepo, "main", BatchWrite{
+			Path: "data/not_exist.txt",
+		})
+		require.ErrorIs(t, err, ErrPathNotFound)
+
+		receipt, err := batcher.Submit(ctx, workRepo, "main", BatchWrite{
+			Path:      "data/0.txt",
+			Blob:      writeBlob(workRepo, "changed"),
+			IsReplace: true,
+		})
+		require.NoError(t, err)
+		require.Equal(t, 1, receipt.BatchSize)
+
+		receipt, err = batcher.Submit(ctx, workRepo, "main", BatchWrite{
+			Path: "data/1.txt",
+		})
+		require.NoError(t, err)
+
+		branch, err := repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(project.ID).SetName("main"))
+		require.NoError(t, err)
+		require.Equal(t, receipt.CommitHash, branch.CommitHash)
+	})
+
+	t.Run("batch per operator", func(t *testing.T) {
+		other, err := repo.UserRepo().Insert(ctx, &models.User{
+			Name:              "bob",
+			Email:             "bob@gg.com",
+			EncryptedPassword: "123",
+		})
+		require.NoError(t, err)
+
+		batcher := NewCommitBatcher(50*time.Millisecond, 10)
+		receipts := make([]*CommitReceipt, 2)
+		wg := sync.WaitGroup{}
+		for i, operator := range []*models.User{user, other} {
+			wg.Add(1)
+			go func(i int, operator *models.User) {
+				defer wg.Done()
+				workRepo := NewWorkRepositoryFromAdapter(ctx, operator, project, repo, adapter)
+				receipt, err := batcher.Submit(ctx, workRepo, "main", BatchWrite{
+					Path: fmt.Sprintf("authors/%s.txt", operator.Name),
+					Blob: writeBlob(workRepo, operator.Name),
+				})
+				require.NoError(t, err)
+				receipts[i] = receipt
+			}(i, operator)
+		}
+		wg.Wait()
+
+		for i, operator := range []*models.User{user, other} {
+			require.Equal(t, 1, receipts[i].BatchSize)
+			commit, err := repo.CommitRepo(project.ID).Commit(ctx, receipts[i].CommitHash)
+			require.NoError(t, err)
+			require.Equal(t, operator.Name, commit.Author.Name)
+		}
+		require.NotEqual(t, receipts[0].CommitHash, receipts[1].CommitHash)
+	})
+
+	t.Run("branch not found", func(t *testing.T) {
+		workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+		_, err := batcher.Submit(ctx, workRepo, "not_exist", BatchWrite{
+			Path: "a.txt",
+			Blob: writeBlob(workRepo, "a"),
+		})
+		require.ErrorIs(t, err, models.ErrNotFound)
+	})
+}
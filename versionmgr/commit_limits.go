@@ -0,0 +1,66 @@
+package versionmgr
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+)
+
+// CommitLimits bound changes of a single commit, zero means unlimited. commits exceeding them are rejected with
+// models.ErrCommitTooLarge, large data sets should be split into several commits or brought in by bulk import
+type CommitLimits struct {
+	// MaxEntries files added, modified or removed
+	MaxEntries int
+	// MaxBytes total size of files added or modified
+	MaxBytes int64
+}
+
+func (limits CommitLimits) unlimited() bool {
+	return limits.MaxEntries <= 0 && limits.MaxBytes <= 0
+}
+
+// SetCommitLimits limit changes of commits made by this work repository, merges are not limited as their changes were
+// committed already
+func (repository *WorkRepository) SetCommitLimits(limits CommitLimits) *WorkRepository {
+	repository.commitLimits = limits
+	return repository
+}
+
+// checkCommitLimits diff tree of parent with tree to commit and reject it if it changes too much
+func (repository *WorkRepository) checkCommitLimits(ctx context.Context, repo models.IRepo, parentHash, treeHash hash.Hash) error {
+	limits := repository.commitLimits
+	if limits.unlimited() {
+		return nil
+	}
+
+	changes, err := repository.changesFrom(ctx, repo, parentHash, treeHash)
+	if err != nil {
+		return err
+	}
+
+	if limits.MaxEntries > 0 && changes.Num() > limits.MaxEntries {
+		return fmt.Errorf("commit changes %d files over limit %d %w", changes.Num(), limits.MaxEntries, models.ErrCommitTooLarge)
+	}
+	if limits.MaxBytes <= 0 {
+		return nil
+	}
+
+	fileTreeRepo := repo.FileTreeRepo(repository.repoModel.ID)
+	var size int64
+	return changes.ForEach(func(change IChange) error {
+		if change.To() == nil {
+			return nil
+		}
+		blob, err := fileTreeRepo.Blob(ctx, change.To().Hash())
+		if err != nil {
+			return err
+		}
+		size += blob.Size
+		if size > limits.MaxBytes {
+			return fmt.Errorf("commit writes more than %d bytes %w", limits.MaxBytes, models.ErrCommitTooLarge)
+		}
+		return nil
+	})
+}
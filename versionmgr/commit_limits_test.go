@@ -0,0 +1,58 @@
+package versionmgr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitLimits(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	project, err := makeRepository(ctx, repo, user, "limits")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	_, err = addChangesToWip(ctx, workRepo, "main", "base commit", `
+1|a.txt	|a
+`)
+	require.NoError(t, err)
+
+	t.Run("too many entries", func(t *testing.T) {
+		workRepo.SetCommitLimits(CommitLimits{MaxEntries: 2})
+		_, err := addChangesToWip(ctx, workRepo, "main", "too many", `
+1|b.txt	|b
+1|c.txt	|c
+1|d.txt	|d
+`)
+		require.ErrorIs(t, err, models.ErrCommitTooLarge)
+	})
+
+	t.Run("too many bytes", func(t *testing.T) {
+		workRepo.SetCommitLimits(CommitLimits{MaxBytes: 5})
+		_, err := addChangesToWip(ctx, workRepo, "main", "too large", `
+1|e.txt	|eeeeeeee
+`)
+		require.ErrorIs(t, err, models.ErrCommitTooLarge)
+	})
+
+	t.Run("within limits", func(t *testing.T) {
+		workRepo.SetCommitLimits(CommitLimits{MaxEntries: 2, MaxBytes: 5})
+		_, err := addChangesToWip(ctx, workRepo, "main", "small", `
+1|f.txt	|ff
+3|a.txt	|aaa
+`)
+		require.NoError(t, err)
+	})
+}
@@ -43,18 +43,7 @@ func (c *WrapCommitNode) Hash() hash.Hash {
 
 // Parents return a CommitIter to the parent Commits.
 func (c *WrapCommitNode) Parents(ctx context.Context) ([]*WrapCommitNode, error) {
-	parentNodes := make([]*WrapCommitNode, len(c.commit.ParentHashes))
-	for index, hash := range c.commit.ParentHashes {
-		commit, err := c.commitRepo.Commit(ctx, hash)
-		if err != nil {
-			return nil, err
-		}
-		parentNodes[index] = &WrapCommitNode{
-			commit:     commit,
-			commitRepo: c.commitRepo,
-		}
-	}
-	return parentNodes, nil
+	return c.GetCommits(ctx, c.commit.ParentHashes)
 }
 
 func (c *WrapCommitNode) GetCommit(ctx context.Context, hash hash.Hash) (*WrapCommitNode, error) {
@@ -69,18 +58,21 @@ func (c *WrapCommitNode) GetCommit(ctx context.Context, hash hash.Hash) (*WrapCo
 }
 
 func (c *WrapCommitNode) GetCommits(ctx context.Context, hashes []hash.Hash) ([]*WrapCommitNode, error) {
-	commits := make([]*WrapCommitNode, len(hashes))
-	for i, hash := range hashes {
-		commit, err := c.commitRepo.Commit(ctx, hash)
-		if err != nil {
-			return nil, err
-		}
-		commits[i] = &WrapCommitNode{
+	if len(hashes) == 0 {
+		return []*WrapCommitNode{}, nil
+	}
+	commits, err := c.commitRepo.ListByHashes(ctx, hashes)
+	if err != nil {
+		return nil, err
+	}
+	nodes := make([]*WrapCommitNode, len(commits))
+	for i, commit := range commits {
+		nodes[i] = &WrapCommitNode{
 			commit:     commit,
 			commitRepo: c.commitRepo,
 		}
 	}
-	return commits, nil
+	return nodes, nil
 }
 
 // CommitIter is a generic closable interface for iterating over commits.
@@ -0,0 +1,225 @@
+package versionmgr
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/block/params"
+	"github.com/GitDataAI/jiaozifs/models"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var commitQueueLog = logging.Logger("commit_queue")
+
+const (
+	// DefaultCommitQueueInterval how often pending commit tickets are looked for if no trigger arrives
+	DefaultCommitQueueInterval = time.Minute
+	// DefaultCommitWorkers branches committed concurrently
+	DefaultCommitWorkers = 4
+)
+
+// ErrCommitInterrupted ticket was running when server stopped, whether its commit was made is unknown
+var ErrCommitInterrupted = errors.New("commit was interrupted by shutdown, check head of branch and commit again")
+
+// CommitQueue commit wips of async commit tickets in background. tickets of different branches are committed by
+// concurrent workers, tickets of the same branch one by one in order they were accepted
+type CommitQueue struct {
+	repo                models.IRepo
+	publicAdapterConfig params.AdapterConfig
+	interval            time.Duration
+	workers             int
+	limits              CommitLimits
+	quotaThresholds     []int
+	buildAdapter        func(ctx context.Context, repoModel *models.Repository) (block.Adapter, error)
+
+	trigger chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func NewCommitQueue(repo models.IRepo, publicAdapterConfig params.AdapterConfig, interval time.Duration, workers int) *CommitQueue {
+	if interval <= 0 {
+		interval = DefaultCommitQueueInterval
+	}
+	if workers <= 0 {
+		workers = DefaultCommitWorkers
+	}
+	queue := &CommitQueue{
+		repo:                repo,
+		publicAdapterConfig: publicAdapterConfig,
+		interval:            interval,
+		workers:             workers,
+		trigger:             make(chan struct{}, 1),
+	}
+	queue.buildAdapter = func(ctx context.Context, repoModel *models.Repository) (block.Adapter, error) {
+		return adapterOf(ctx, repoModel, queue.publicAdapterConfig)
+	}
+	return queue
+}
+
+// SetCommitLimits limits applied to commits of tickets like to commits made by api
+func (queue *CommitQueue) SetCommitLimits(limits CommitLimits) *CommitQueue {
+	queue.limits = limits
+	return queue
+}
+
+// SetQuotaWarningThresholds thresholds of storage quota warned by commits of tickets
+func (queue *CommitQueue) SetQuotaWarningThresholds(thresholds []int) *CommitQueue {
+	queue.quotaThresholds = thresholds
+	return queue
+}
+
+// Start commit pending tickets every interval or when triggered in background until Stop called
+func (queue *CommitQueue) Start(ctx context.Context) {
+	ctx, queue.cancel = context.WithCancel(ctx)
+	queue.done = make(chan struct{})
+	go func() {
+		defer close(queue.done)
+		ticker := time.NewTicker(queue.interval)
+		defer ticker.Stop()
+		queue.Trigger()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-queue.trigger:
+			}
+			_, err := queue.RunPending(ctx)
+			if err != nil {
+				commitQueueLog.Errorf("commit tickets %v", err)
+			}
+		}
+	}()
+}
+
+// Stop background commits and wait for the running round
+func (queue *CommitQueue) Stop() {
+	if queue.cancel == nil {
+		return
+	}
+	queue.cancel()
+	<-queue.done
+}
+
+// Trigger ask for a round soon without waiting for interval, called when a ticket is accepted
+func (queue *CommitQueue) Trigger() {
+	select {
+	case queue.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// RunPending commit unfinished tickets, return number of tickets finished. rounds never overlap, so a ticket found running
+// was interrupted by last shutdown and is failed with ErrCommitInterrupted instead of committed twice
+func (queue *CommitQueue) RunPending(ctx context.Context) (int, error) {
+	tickets, err := queue.repo.CommitTicketRepo().ListUnfinished(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	type branchKey struct {
+		repositoryID string
+		refName      string
+	}
+	var order []branchKey
+	branches := make(map[branchKey][]*models.CommitTicket)
+	for _, ticket := range tickets {
+		if ticket.State == models.CommitTicketRunning {
+			err = queue.repo.CommitTicketRepo().Update(ctx, models.NewUpdateCommitTicketParams(ticket.ID).SetState(models.CommitTicketFailed).SetError(ErrCommitInterrupted.Error()))
+			if err != nil {
+				return 0, err
+			}
+			continue
+		}
+		key := branchKey{repositoryID: ticket.RepositoryID.String(), refName: ticket.RefName}
+		if _, ok := branches[key]; !ok {
+			order = append(order, key)
+		}
+		branches[key] = append(branches[key], ticket)
+	}
+
+	var (
+		mu       sync.Mutex
+		done     int
+		firstErr error
+		wg       sync.WaitGroup
+	)
+	slots := make(chan struct{}, queue.workers)
+	for _, key := range order {
+		wg.Add(1)
+		slots <- struct{}{}
+		go func(tickets []*models.CommitTicket) {
+			defer wg.Done()
+			defer func() { <-slots }()
+			for _, ticket := range tickets {
+				if ctx.Err() != nil {
+					return
+				}
+				err := queue.run(ctx, ticket)
+				mu.Lock()
+				if err != nil && firstErr == nil {
+					firstErr = err
+				}
+				if err == nil {
+					done++
+				}
+				mu.Unlock()
+			}
+		}(branches[key])
+	}
+	wg.Wait()
+	if firstErr == nil {
+		firstErr = ctx.Err()
+	}
+	return done, firstErr
+}
+
+// run commit ticket, failure of commit fail the ticket with its error and only failure to record state is returned
+func (queue *CommitQueue) run(ctx context.Context, ticket *models.CommitTicket) error {
+	ticketRepo := queue.repo.CommitTicketRepo()
+	err := ticketRepo.Update(ctx, models.NewUpdateCommitTicketParams(ticket.ID).SetState(models.CommitTicketRunning))
+	if err != nil {
+		return err
+	}
+
+	commit, err := queue.commit(ctx, ticket)
+	if err != nil {
+		commitQueueLog.Warnf("commit ticket %s of branch %s %v", ticket.ID, ticket.RefName, err)
+		return ticketRepo.Update(ctx, models.NewUpdateCommitTicketParams(ticket.ID).SetState(models.CommitTicketFailed).SetError(err.Error()))
+	}
+	return ticketRepo.Update(ctx, models.NewUpdateCommitTicketParams(ticket.ID).SetState(models.CommitTicketDone).SetCommitHash(commit.Hash))
+}
+
+func (queue *CommitQueue) commit(ctx context.Context, ticket *models.CommitTicket) (*models.Commit, error) {
+	repoModel, err := queue.repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetID(ticket.RepositoryID))
+	if err != nil {
+		return nil, err
+	}
+	creator, err := queue.repo.UserRepo().Get(ctx, models.NewGetUserParams().SetID(ticket.CreatorID))
+	if err != nil {
+		return nil, err
+	}
+
+	adapter, err := queue.buildAdapter(ctx, repoModel)
+	if err != nil {
+		return nil, err
+	}
+	workRepo := NewWorkRepositoryFromAdapter(ctx, creator, repoModel, queue.repo, adapter)
+	err = workRepo.CheckOut(ctx, InWip, ticket.RefName)
+	if err != nil {
+		return nil, err
+	}
+
+	workRepo.SetCommitMessageVars(ticket.MessageVars)
+	workRepo.SetCommitMetadata(ticket.Metadata)
+	workRepo.SetCommitLimits(queue.limits)
+	workRepo.SetQuotaWarningThresholds(queue.quotaThresholds)
+	if ticket.Amend {
+		return workRepo.AmendCommit(ctx, ticket.Message)
+	}
+	return workRepo.CommitChanges(ctx, ticket.Message)
+}
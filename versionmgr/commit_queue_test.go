@@ -0,0 +1,123 @@
+package versionmgr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitQueue(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	project, err := makeRepository(ctx, repo, user, "asyncCommit")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	_, err = addChangesToWip(ctx, workRepo, "main", "base commit", `
+1|a.txt	|a
+`)
+	require.NoError(t, err)
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+	_, err = workRepo.CreateBranch(ctx, "feat")
+	require.NoError(t, err)
+
+	changeWip := func(branch, testData string) {
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, branch))
+		_, _, err := workRepo.GetOrCreateWip(ctx)
+		require.NoError(t, err)
+		require.NoError(t, workRepo.CheckOut(ctx, InWip, branch))
+		require.NoError(t, workRepo.ChangeInWip(ctx, func(workTree *WorkTree) error {
+			return appendChangeToWorkTree(ctx, workRepo, workTree, testData)
+		}))
+	}
+	changeWip("main", `
+1|b.txt	|b
+`)
+	changeWip("feat", `
+1|c.txt	|c
+`)
+
+	mainTicket := models.NewCommitTicket(project.ID, user.ID, "main", "add b")
+	mainTicket.Metadata = map[string]string{"run_id": "42"}
+	mainTicket, err = repo.CommitTicketRepo().Insert(ctx, mainTicket)
+	require.NoError(t, err)
+	featTicket, err := repo.CommitTicketRepo().Insert(ctx, models.NewCommitTicket(project.ID, user.ID, "feat", "add c"))
+	require.NoError(t, err)
+	missingTicket, err := repo.CommitTicketRepo().Insert(ctx, models.NewCommitTicket(project.ID, user.ID, "missing", "no wip"))
+	require.NoError(t, err)
+	interruptedTicket := models.NewCommitTicket(project.ID, user.ID, "main", "interrupted")
+	interruptedTicket.State = models.CommitTicketRunning
+	interruptedTicket.CreatedAt = time.Now().Add(-time.Minute)
+	interruptedTicket, err = repo.CommitTicketRepo().Insert(ctx, interruptedTicket)
+	require.NoError(t, err)
+
+	queue := NewCommitQueue(repo, nil, time.Minute, 2)
+	queue.buildAdapter = func(_ context.Context, _ *models.Repository) (block.Adapter, error) {
+		return adapter, nil
+	}
+	finished, err := queue.RunPending(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 3, finished)
+
+	for _, branchTicket := range []struct {
+		branch string
+		ticket *models.CommitTicket
+		path   string
+	}{
+		{"main", mainTicket, "b.txt"},
+		{"feat", featTicket, "c.txt"},
+	} {
+		ticket, err := repo.CommitTicketRepo().Get(ctx, models.NewGetCommitTicketParams().SetID(branchTicket.ticket.ID))
+		require.NoError(t, err)
+		require.Equal(t, models.CommitTicketDone, ticket.State)
+		require.False(t, ticket.FinishedAt.IsZero())
+
+		branch, err := repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(project.ID).SetName(branchTicket.branch))
+		require.NoError(t, err)
+		require.Equal(t, branch.CommitHash.Hex(), ticket.CommitHash.Hex())
+
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, branchTicket.branch))
+		workTree, err := workRepo.RootTree(ctx)
+		require.NoError(t, err)
+		_, _, err = workTree.FindBlob(ctx, branchTicket.path)
+		require.NoError(t, err)
+	}
+
+	commit, err := repo.CommitRepo(project.ID).Commit(ctx, workRepo.CurBranch().CommitHash)
+	require.NoError(t, err)
+	require.Equal(t, "add c", commit.Message)
+
+	mainBranch, err := repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(project.ID).SetName("main"))
+	require.NoError(t, err)
+	commit, err = repo.CommitRepo(project.ID).Commit(ctx, mainBranch.CommitHash)
+	require.NoError(t, err)
+	require.Equal(t, "42", commit.Metadata["run_id"])
+
+	missing, err := repo.CommitTicketRepo().Get(ctx, models.NewGetCommitTicketParams().SetID(missingTicket.ID))
+	require.NoError(t, err)
+	require.Equal(t, models.CommitTicketFailed, missing.State)
+	require.NotEmpty(t, missing.Error)
+
+	interrupted, err := repo.CommitTicketRepo().Get(ctx, models.NewGetCommitTicketParams().SetID(interruptedTicket.ID))
+	require.NoError(t, err)
+	require.Equal(t, models.CommitTicketFailed, interrupted.State)
+	require.Equal(t, ErrCommitInterrupted.Error(), interrupted.Error)
+
+	// finished tickets are not run again
+	finished, err = queue.RunPending(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, finished)
+}
@@ -0,0 +1,114 @@
+package versionmgr
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/versionmgr/merkletrie"
+)
+
+// CommitStats get changes summary of commit, stats of commits created before stats were saved, forked or rewritten by
+// hash migration are computed and saved on first read
+func (repository *WorkRepository) CommitStats(ctx context.Context, commit *models.Commit) (*models.CommitStats, error) {
+	stats, err := repository.repo.CommitRepo(repository.repoModel.ID).Stats(ctx, commit.Hash)
+	if err == nil {
+		return stats, nil
+	}
+	if !errors.Is(err, models.ErrNotFound) {
+		return nil, err
+	}
+	return repository.recordCommitStats(ctx, repository.repo, commit)
+}
+
+// recordCommitStats diff tree of commit with tree of its first parent and save the summary
+func (repository *WorkRepository) recordCommitStats(ctx context.Context, repo models.IRepo, commit *models.Commit) (*models.CommitStats, error) {
+	stats := &models.CommitStats{
+		RepositoryID: repository.repoModel.ID,
+		CommitHash:   commit.Hash,
+		CreatedAt:    time.Now(),
+	}
+
+	err := repository.forEachCommitChange(ctx, repo, commit, func(change commitChange) error {
+		switch change.action {
+		case merkletrie.Insert:
+			stats.FilesAdded++
+		case merkletrie.Delete:
+			stats.FilesRemoved++
+		case merkletrie.Modify:
+			stats.FilesModified++
+		}
+		stats.BytesRemoved += change.fromSize
+		stats.BytesAdded += change.toSize
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = repo.CommitRepo(repository.repoModel.ID).InsertStats(ctx, stats)
+	if err != nil {
+		return nil, err
+	}
+	return stats, nil
+}
+
+// commitChange file changed by commit with size of its old and new content, size is zero on the missing side
+type commitChange struct {
+	path     string
+	action   merkletrie.Action
+	fromSize int64
+	toSize   int64
+}
+
+// forEachCommitChange diff tree of commit with tree of its first parent and call fn with every changed file
+func (repository *WorkRepository) forEachCommitChange(ctx context.Context, repo models.IRepo, commit *models.Commit, fn func(change commitChange) error) error {
+	parentTree := hash.Empty
+	if len(commit.ParentHashes) > 0 {
+		parent, err := repo.CommitRepo(repository.repoModel.ID).Commit(ctx, commit.ParentHashes[0])
+		if err != nil {
+			return err
+		}
+		parentTree = parent.TreeHash
+	}
+
+	fileTreeRepo := repo.FileTreeRepo(repository.repoModel.ID)
+	workTree, err := newWorkTree(ctx, fileTreeRepo, repository.repoModel, parentTree)
+	if err != nil {
+		return err
+	}
+	changes, err := workTree.Diff(ctx, commit.TreeHash, "")
+	if err != nil {
+		return err
+	}
+
+	blobSize := func(blobHash hash.Hash) (int64, error) {
+		blob, err := fileTreeRepo.Blob(ctx, blobHash)
+		if err != nil {
+			return 0, err
+		}
+		return blob.Size, nil
+	}
+	return changes.ForEach(func(change IChange) error {
+		action, err := change.Action()
+		if err != nil {
+			return err
+		}
+		sized := commitChange{path: change.Path(), action: action}
+		if change.From() != nil {
+			sized.fromSize, err = blobSize(change.From().Hash())
+			if err != nil {
+				return err
+			}
+		}
+		if change.To() != nil {
+			sized.toSize, err = blobSize(change.To().Hash())
+			if err != nil {
+				return err
+			}
+		}
+		return fn(sized)
+	})
+}
@@ -0,0 +1,66 @@
+package versionmgr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitStats(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	project, err := makeRepository(ctx, repo, user, "stats")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	first, err := addChangesToWip(ctx, workRepo, "main", "first", `
+1|a.txt	|aaaa
+1|b.txt	|bb
+1|c/d.txt	|d
+`)
+	require.NoError(t, err)
+
+	stats, err := repo.CommitRepo(project.ID).Stats(ctx, first.Hash)
+	require.NoError(t, err)
+	require.Equal(t, int64(3), stats.FilesAdded)
+	require.Equal(t, int64(7), stats.BytesAdded)
+	require.Equal(t, int64(0), stats.BytesRemoved)
+
+	second, err := addChangesToWip(ctx, workRepo, "main", "second", `
+3|a.txt	|aaaaaa
+2|b.txt	|
+1|e.txt	|e
+`)
+	require.NoError(t, err)
+
+	stats, err = repo.CommitRepo(project.ID).Stats(ctx, second.Hash)
+	require.NoError(t, err)
+	require.Equal(t, int64(1), stats.FilesAdded)
+	require.Equal(t, int64(1), stats.FilesRemoved)
+	require.Equal(t, int64(1), stats.FilesModified)
+	require.Equal(t, int64(7), stats.BytesAdded)
+	require.Equal(t, int64(6), stats.BytesRemoved)
+
+	t.Run("computed on first read", func(t *testing.T) {
+		_, err := db.NewDelete().Model((*models.CommitStats)(nil)).Where("commit_hash = ?", second.Hash).Exec(ctx)
+		require.NoError(t, err)
+
+		stats, err := workRepo.CommitStats(ctx, second)
+		require.NoError(t, err)
+		require.Equal(t, int64(1), stats.FilesModified)
+
+		_, err = repo.CommitRepo(project.ID).Stats(ctx, second.Hash)
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,48 @@
+package versionmgr
+
+import (
+	"strings"
+	"text/template"
+	"time"
+)
+
+// CommitMessageVars variables can be used in commit message template of repository, eg.
+//
+//	{{.Message}}
+//
+//	branch: {{.Branch}} committer: {{.User}} date: {{.Date}} job: {{.Vars.job_id}}
+type CommitMessageVars struct {
+	// Message is the message given by user
+	Message    string
+	Repository string
+	Branch     string
+	User       string
+	Date       string
+	Time       time.Time
+	// Vars extra variables injected by automation, like job id
+	Vars map[string]string
+}
+
+// ParseCommitMessageTemplate check template syntax
+func ParseCommitMessageTemplate(tmpl string) (*template.Template, error) {
+	return template.New("commit_message").Option("missingkey=zero").Parse(tmpl)
+}
+
+// ExpandCommitMessage render commit message with template, return message itself if template is empty
+func ExpandCommitMessage(tmpl string, vars CommitMessageVars) (string, error) {
+	if len(strings.TrimSpace(tmpl)) == 0 {
+		return vars.Message, nil
+	}
+
+	t, err := ParseCommitMessageTemplate(tmpl)
+	if err != nil {
+		return "", err
+	}
+
+	buf := strings.Builder{}
+	err = t.Execute(&buf, vars)
+	if err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
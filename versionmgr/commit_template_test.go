@@ -0,0 +1,41 @@
+package versionmgr
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestExpandCommitMessage(t *testing.T) {
+	vars := CommitMessageVars{
+		Message:    "update data",
+		Repository: "repo",
+		Branch:     "main",
+		User:       "admin",
+		Date:       "2024-01-02",
+		Vars:       map[string]string{"job_id": "job-1"},
+	}
+
+	t.Run("empty template", func(t *testing.T) {
+		msg, err := ExpandCommitMessage("", vars)
+		require.NoError(t, err)
+		require.Equal(t, "update data", msg)
+	})
+
+	t.Run("expand", func(t *testing.T) {
+		msg, err := ExpandCommitMessage("[{{.Repository}}/{{.Branch}}] {{.Message}} by {{.User}} at {{.Date}} job {{.Vars.job_id}}", vars)
+		require.NoError(t, err)
+		require.Equal(t, "[repo/main] update data by admin at 2024-01-02 job job-1", msg)
+	})
+
+	t.Run("missing var", func(t *testing.T) {
+		msg, err := ExpandCommitMessage("{{.Message}}{{.Vars.not_exist}}", CommitMessageVars{Message: "msg"})
+		require.NoError(t, err)
+		require.Equal(t, "msg", msg)
+	})
+
+	t.Run("bad template", func(t *testing.T) {
+		_, err := ExpandCommitMessage("{{.Message", vars)
+		require.Error(t, err)
+	})
+}
@@ -68,3 +68,16 @@ func ResolveFromSelector(resolveMsg map[string]string) ConflictResolver {
 		return right, nil
 	}
 }
+
+// ResolveFromSelectorOr resolve paths selected in resolveMsg by selection and other paths by fallback
+func ResolveFromSelectorOr(resolveMsg map[string]string, fallback ConflictResolver) ConflictResolver {
+	return func(left IChange, right IChange) (IChange, error) {
+		switch resolveMsg[left.Path()] {
+		case "left":
+			return left, nil
+		case "right":
+			return right, nil
+		}
+		return fallback(left, right)
+	}
+}
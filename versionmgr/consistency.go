@@ -0,0 +1,274 @@
+package versionmgr
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"sort"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/utils/pathutil"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var consistencyLog = logging.Logger("consistency")
+
+// OrphanAction what to do with files in storage not referenced by metadata
+type OrphanAction int
+
+const (
+	// OrphanReport only report orphan files
+	OrphanReport OrphanAction = iota
+	// OrphanQuarantine move orphan files under quarantine, operator can inspect and move them back
+	OrphanQuarantine
+	// OrphanRemove remove orphan files from storage
+	OrphanRemove
+)
+
+// ConsistencyOption options of checking metadata against storage
+type ConsistencyOption struct {
+	// Repair drop index of packed blobs whose pack is missing while loose file of blob still exists
+	Repair bool
+	// Orphans what to do with files in storage not referenced by metadata
+	Orphans OrphanAction
+	// GracePeriod files modified within this period are not taken as orphans, they may belong to an upload in progress
+	GracePeriod time.Duration
+}
+
+// MissingBlob blob referenced by metadata whose content is not in storage
+type MissingBlob struct {
+	Hash     hash.Hash
+	CheckSum hash.Hash
+	Size     int64
+	// Pack pack the blob is indexed in, empty if blob is stored as loose file
+	Pack hash.Hash
+}
+
+// OrphanFile file in storage not referenced by metadata
+type OrphanFile struct {
+	Path       string
+	Size       int64
+	ModifiedAt time.Time
+}
+
+// ConsistencyReport report of checking metadata against storage
+type ConsistencyReport struct {
+	CheckedBlobs int
+	Missing      []MissingBlob
+	// DanglingPacked blobs indexed in missing pack but still stored as loose file, index is dropped by repair
+	DanglingPacked int
+	// OrphansScanned false if storage can not be listed, orphans are not looked up then
+	OrphansScanned bool
+	Orphans        []OrphanFile
+	Repaired       int
+	Quarantined    int
+	Removed        int
+}
+
+// ConsistencyChecker compare blobs referenced by metadata of repository with files in its storage, for operators
+// recovering from partial failures
+type ConsistencyChecker struct {
+	repo      models.IRepo
+	repoModel *models.Repository
+	adapter   block.Adapter
+}
+
+func NewConsistencyChecker(repo models.IRepo, repoModel *models.Repository, adapter block.Adapter) *ConsistencyChecker {
+	return &ConsistencyChecker{
+		repo:      repo,
+		repoModel: repoModel,
+		adapter:   adapter,
+	}
+}
+
+// Check report blobs of repository missing from storage and files of storage referenced by none of repositories sharing
+// it. content of a blob is present if its loose file exists or the pack it is indexed in exists
+func (checker *ConsistencyChecker) Check(ctx context.Context, opt ConsistencyOption) (*ConsistencyReport, error) {
+	deadline := time.Now().Add(-opt.GracePeriod)
+
+	// files referenced by any repository sharing storage are not orphans
+	sharers, err := checker.repo.RepositoryRepo().ListSharingStorage(ctx, checker.repoModel)
+	if err != nil {
+		return nil, err
+	}
+	referencedBlobs := make(map[string]struct{})
+	referencedPacks := make(map[string]struct{})
+	for _, repoModel := range append(sharers, checker.repoModel) {
+		objects, err := checker.repo.FileTreeRepo(repoModel.ID).List(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, obj := range objects {
+			if obj.Type == models.BlobObject && !obj.CheckSum.IsEmpty() {
+				referencedBlobs[obj.CheckSum.Hex()] = struct{}{}
+			}
+		}
+		packs, err := checker.repo.PackRepo().List(ctx, repoModel.ID)
+		if err != nil {
+			return nil, err
+		}
+		for _, pack := range packs {
+			referencedPacks[pack.CheckSum.Hex()] = struct{}{}
+		}
+	}
+
+	files, err := checker.listStorage(ctx)
+	if err != nil {
+		return nil, err
+	}
+	exists := func(address string) (bool, error) {
+		if files != nil {
+			_, ok := files[address]
+			return ok, nil
+		}
+		return checker.adapter.Exists(ctx, checker.pointer(address))
+	}
+
+	packedObjects, err := checker.repo.PackRepo().ListObjects(ctx, checker.repoModel.ID)
+	if err != nil {
+		return nil, err
+	}
+	packed := make(map[string]*models.PackedObject, len(packedObjects))
+	for _, object := range packedObjects {
+		packed[object.CheckSum.Hex()] = object
+	}
+
+	report := &ConsistencyReport{OrphansScanned: files != nil}
+	objects, err := checker.repo.FileTreeRepo(checker.repoModel.ID).List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	checked := make(map[string]struct{})
+	var dangling []hash.Hash
+	for _, obj := range objects {
+		if obj.Type != models.BlobObject || obj.CheckSum.IsEmpty() {
+			continue
+		}
+		if _, ok := checked[obj.CheckSum.Hex()]; ok {
+			continue
+		}
+		checked[obj.CheckSum.Hex()] = struct{}{}
+		report.CheckedBlobs++
+
+		missing := MissingBlob{Hash: obj.Hash, CheckSum: obj.CheckSum, Size: obj.Size}
+		if object, ok := packed[obj.CheckSum.Hex()]; ok {
+			packExist, err := exists(pathutil.PathOfPack(object.PackCheckSum))
+			if err != nil {
+				return nil, err
+			}
+			if packExist {
+				continue
+			}
+			missing.Pack = object.PackCheckSum
+		}
+		looseExist, err := exists(pathutil.PathOfHash(obj.CheckSum))
+		if err != nil {
+			return nil, err
+		}
+		if !looseExist {
+			report.Missing = append(report.Missing, missing)
+			continue
+		}
+		if !missing.Pack.IsEmpty() {
+			report.DanglingPacked++
+			dangling = append(dangling, obj.CheckSum)
+		}
+	}
+
+	for address, entry := range files {
+		if checkSum, ok := pathutil.HashOfPath(address); ok {
+			if _, referenced := referencedBlobs[checkSum.Hex()]; referenced {
+				continue
+			}
+		} else if checkSum, ok := pathutil.HashOfPackPath(address); ok {
+			if _, referenced := referencedPacks[checkSum.Hex()]; referenced {
+				continue
+			}
+		} else {
+			// quarantined files, upload parts and files not written by repository are left alone
+			continue
+		}
+		if !entry.Mtime.IsZero() && entry.Mtime.After(deadline) {
+			continue
+		}
+		report.Orphans = append(report.Orphans, OrphanFile{Path: address, Size: entry.Size, ModifiedAt: entry.Mtime})
+	}
+	sort.Slice(report.Orphans, func(i, j int) bool {
+		return report.Orphans[i].Path < report.Orphans[j].Path
+	})
+
+	if opt.Repair && len(dangling) > 0 {
+		// reads fall back to loose file once index is dropped
+		deleted, err := checker.repo.PackRepo().DeleteObjects(ctx, checker.repoModel.ID, dangling)
+		if err != nil {
+			return nil, err
+		}
+		report.Repaired = int(deleted)
+	}
+
+	for _, orphan := range report.Orphans {
+		switch opt.Orphans {
+		case OrphanQuarantine:
+			err = checker.adapter.Copy(ctx, checker.pointer(orphan.Path), checker.pointer(pathutil.PathOfQuarantine(orphan.Path)))
+			if err != nil {
+				consistencyLog.Errorf("quarantine %s of repo %s failed %v", orphan.Path, checker.repoModel.ID, err)
+				continue
+			}
+			err = checker.adapter.Remove(ctx, checker.pointer(orphan.Path))
+			if err != nil {
+				consistencyLog.Errorf("remove quarantined %s of repo %s failed %v", orphan.Path, checker.repoModel.ID, err)
+				continue
+			}
+			report.Quarantined++
+		case OrphanRemove:
+			err = checker.adapter.Remove(ctx, checker.pointer(orphan.Path))
+			if err != nil {
+				consistencyLog.Errorf("remove orphan %s of repo %s failed %v", orphan.Path, checker.repoModel.ID, err)
+				continue
+			}
+			report.Removed++
+		}
+	}
+	return report, nil
+}
+
+// listStorage list files of storage namespace by path relative to namespace, nil if adapter can not list storage
+func (checker *ConsistencyChecker) listStorage(ctx context.Context) (map[string]block.ObjectStoreEntry, error) {
+	qk, err := checker.adapter.ResolveNamespace(utils.StringValue(checker.repoModel.StorageNamespace), "", block.IdentifierTypeRelative)
+	if err != nil {
+		return nil, err
+	}
+	uri, err := url.Parse(qk.Format())
+	if err != nil {
+		return nil, err
+	}
+	walker, err := checker.adapter.GetWalker(uri)
+	if errors.Is(err, block.ErrOperationNotSupported) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	files := make(map[string]block.ObjectStoreEntry)
+	err = walker.Walk(ctx, uri, block.WalkOptions{}, func(e block.ObjectStoreEntry) error {
+		files[e.RelativeKey] = e
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return files, nil
+}
+
+func (checker *ConsistencyChecker) pointer(address string) block.ObjectPointer {
+	return block.ObjectPointer{
+		StorageNamespace: utils.StringValue(checker.repoModel.StorageNamespace),
+		IdentifierType:   block.IdentifierTypeRelative,
+		Identifier:       address,
+	}
+}
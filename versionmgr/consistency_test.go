@@ -0,0 +1,132 @@
+package versionmgr
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/utils/pathutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsistencyChecker(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	project, err := makeRepository(ctx, repo, user, "testConsistency")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	_, err = addChangesToWip(ctx, workRepo, "main", "base commit", `
+1|a.txt	|aaa
+1|b.txt	|bbbbb
+1|large.txt	|`+strings.Repeat("l", 20)+`
+`)
+	require.NoError(t, err)
+	_, err = workRepo.Packer().Pack(ctx, PackOption{Threshold: 10})
+	require.NoError(t, err)
+
+	pointer := func(address string) block.ObjectPointer {
+		return block.ObjectPointer{
+			StorageNamespace: utils.StringValue(project.StorageNamespace),
+			IdentifierType:   block.IdentifierTypeRelative,
+			Identifier:       address,
+		}
+	}
+	blobOf := func(path string) *models.Blob {
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+		workTree, err := workRepo.RootTree(ctx)
+		require.NoError(t, err)
+		blob, _, err := workTree.FindBlob(ctx, path)
+		require.NoError(t, err)
+		return blob
+	}
+
+	report, err := workRepo.ConsistencyChecker().Check(ctx, ConsistencyOption{})
+	require.NoError(t, err)
+	require.Equal(t, &ConsistencyReport{CheckedBlobs: 3, OrphansScanned: true}, report)
+
+	orphanBlob, err := hash.FromHex("0123456789abcdef0123456789abcdef")
+	require.NoError(t, err)
+	orphanPack, err := hash.FromHex("fedcba9876543210fedcba9876543210")
+	require.NoError(t, err)
+	require.NoError(t, adapter.Put(ctx, pointer(pathutil.PathOfHash(orphanBlob)), 6, strings.NewReader("orphan"), block.PutOpts{}))
+	require.NoError(t, adapter.Put(ctx, pointer(pathutil.PathOfPack(orphanPack)), 4, strings.NewReader("pack"), block.PutOpts{}))
+
+	t.Run("report orphans", func(t *testing.T) {
+		report, err := workRepo.ConsistencyChecker().Check(ctx, ConsistencyOption{})
+		require.NoError(t, err)
+		require.Equal(t, 3, report.CheckedBlobs)
+		require.Empty(t, report.Missing)
+		require.Equal(t, []OrphanFile{
+			{Path: pathutil.PathOfHash(orphanBlob), Size: 6},
+			{Path: pathutil.PathOfPack(orphanPack), Size: 4},
+		}, report.Orphans)
+		require.Zero(t, report.Quarantined)
+		require.Zero(t, report.Removed)
+	})
+
+	t.Run("quarantine and remove orphans", func(t *testing.T) {
+		report, err := workRepo.ConsistencyChecker().Check(ctx, ConsistencyOption{Orphans: OrphanQuarantine})
+		require.NoError(t, err)
+		require.Equal(t, 2, report.Quarantined)
+		exist, err := adapter.Exists(ctx, pointer(pathutil.PathOfQuarantine(pathutil.PathOfHash(orphanBlob))))
+		require.NoError(t, err)
+		require.True(t, exist)
+		exist, err = adapter.Exists(ctx, pointer(pathutil.PathOfHash(orphanBlob)))
+		require.NoError(t, err)
+		require.False(t, exist)
+
+		require.NoError(t, adapter.Put(ctx, pointer(pathutil.PathOfHash(orphanBlob)), 6, strings.NewReader("orphan"), block.PutOpts{}))
+		report, err = workRepo.ConsistencyChecker().Check(ctx, ConsistencyOption{Orphans: OrphanRemove})
+		require.NoError(t, err)
+		require.Equal(t, 1, report.Removed)
+
+		report, err = workRepo.ConsistencyChecker().Check(ctx, ConsistencyOption{})
+		require.NoError(t, err)
+		require.Empty(t, report.Orphans)
+	})
+
+	t.Run("report missing and repair dangling", func(t *testing.T) {
+		large := blobOf("large.txt")
+		a := blobOf("a.txt")
+		b := blobOf("b.txt")
+		packedA, err := repo.PackRepo().GetObject(ctx, project.ID, a.CheckSum)
+		require.NoError(t, err)
+
+		require.NoError(t, adapter.Remove(ctx, pointer(pathutil.PathOfHash(large.CheckSum))))
+		// a keeps a loose copy while its pack is lost
+		require.NoError(t, adapter.Put(ctx, pointer(pathutil.PathOfHash(a.CheckSum)), 3, strings.NewReader("aaa"), block.PutOpts{}))
+		require.NoError(t, adapter.Remove(ctx, pointer(pathutil.PathOfPack(packedA.PackCheckSum))))
+
+		report, err := workRepo.ConsistencyChecker().Check(ctx, ConsistencyOption{Repair: true})
+		require.NoError(t, err)
+		require.Equal(t, 3, report.CheckedBlobs)
+		require.ElementsMatch(t, []MissingBlob{
+			{Hash: large.Hash, CheckSum: large.CheckSum, Size: large.Size},
+			{Hash: b.Hash, CheckSum: b.CheckSum, Size: b.Size, Pack: packedA.PackCheckSum},
+		}, report.Missing)
+		require.Equal(t, 1, report.DanglingPacked)
+		require.Equal(t, 1, report.Repaired)
+
+		reader, err := workRepo.ReadBlob(ctx, a, nil)
+		require.NoError(t, err)
+		defer reader.Close() //nolint
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, "aaa", string(data))
+	})
+}
@@ -0,0 +1,126 @@
+package versionmgr
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/utils/pathutil"
+	"github.com/GitDataAI/jiaozifs/versionmgr/merkletrie"
+)
+
+// DeltaObject object added, changed or removed since the commit a consumer synced last
+type DeltaObject struct {
+	Path   string
+	Action merkletrie.Action
+	// Blob content at head, nil if object was removed
+	Blob *models.Blob
+	// URL presigned url reading content from storage directly, empty if object was removed, is packed, is excluded by
+	// option or storage can not presign
+	URL       string
+	ExpiresAt time.Time
+}
+
+// DeltaExportOption options of exporting delta
+type DeltaExportOption struct {
+	// PathPrefix only objects under prefix are exported
+	PathPrefix string
+	// Hidden paths left out of export, nil export all paths
+	Hidden func(string) bool
+	// NoPresign paths must be read through api instead of storage, e.g. content is masked for operator
+	NoPresign func(string) bool
+}
+
+// ExportDelta list objects changed from sinceCommit to head of checked out ref. every object of head is added when
+// sinceCommit is empty, so consumer not synced yet start with a full export. sinceCommit need not be an ancestor of head,
+// delta is always what turns tree of sinceCommit into tree of head
+func (repository *WorkRepository) ExportDelta(ctx context.Context, sinceCommit hash.Hash, opt DeltaExportOption) ([]*DeltaObject, error) {
+	if repository.state == InWip {
+		return nil, errors.New("delta export must be on branch, tag or commit")
+	}
+
+	fileTreeRepo := repository.repo.FileTreeRepo(repository.repoModel.ID)
+	sinceTree := hash.Empty
+	if !sinceCommit.IsEmpty() {
+		resolved, err := ResolveHash(ctx, repository.repo, repository.repoModel, sinceCommit)
+		if err != nil {
+			return nil, err
+		}
+		commit, err := repository.repo.CommitRepo(repository.repoModel.ID).Commit(ctx, resolved)
+		if err != nil {
+			return nil, err
+		}
+		sinceTree = commit.TreeHash
+	}
+
+	headTree, err := repository.RootTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	baseTree, err := newWorkTree(ctx, fileTreeRepo, repository.repoModel, sinceTree)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := baseTree.Diff(ctx, headTree.Root().Hash(), opt.PathPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	objects := make([]*DeltaObject, 0, changes.Num())
+	for _, change := range changes.Changes() {
+		if opt.Hidden != nil && opt.Hidden(change.Path()) {
+			continue
+		}
+		action, err := change.Action()
+		if err != nil {
+			return nil, err
+		}
+		object := &DeltaObject{
+			Path:   change.Path(),
+			Action: action,
+		}
+		if action != merkletrie.Delete {
+			object.Blob, err = fileTreeRepo.Blob(ctx, hash.Hash(change.To().Hash()))
+			if err != nil {
+				return nil, err
+			}
+			if opt.NoPresign == nil || !opt.NoPresign(object.Path) {
+				object.URL, object.ExpiresAt, err = repository.presignBlob(ctx, object.Blob)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// presignBlob return presigned url reading loose blob from storage, empty if blob is packed or storage can not presign
+func (repository *WorkRepository) presignBlob(ctx context.Context, blob *models.Blob) (string, time.Time, error) {
+	packed, err := findPacked(ctx, repository.repo, repository.repoModel.ID, blob.CheckSum)
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	if packed != nil {
+		return "", time.Time{}, nil
+	}
+
+	pointer := block.ObjectPointer{
+		StorageNamespace: utils.StringValue(repository.repoModel.StorageNamespace),
+		IdentifierType:   block.IdentifierTypeRelative,
+		Identifier:       pathutil.PathOfHash(blob.CheckSum),
+	}
+	url, expiresAt, err := repository.adapter.GetPreSignedURL(ctx, pointer, block.PreSignModeRead)
+	if errors.Is(err, block.ErrOperationNotSupported) {
+		return "", time.Time{}, nil
+	}
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	return url, expiresAt, nil
+}
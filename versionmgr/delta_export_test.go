@@ -0,0 +1,103 @@
+package versionmgr
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/versionmgr/merkletrie"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportDelta(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	project, err := makeRepository(ctx, repo, user, "testDelta")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	synced, err := addChangesToWip(ctx, workRepo, "main", "base commit", `
+1|a.txt	|aaa
+1|b.txt	|bbb
+1|private/c.txt	|ccc
+`)
+	require.NoError(t, err)
+	_, err = addChangesToWip(ctx, workRepo, "main", "second commit", `
+3|a.txt	|aaaa
+2|b.txt	|
+1|d.txt	|ddd
+1|private/e.txt	|eee
+`)
+	require.NoError(t, err)
+
+	actionsOf := func(objects []*DeltaObject) map[string]merkletrie.Action {
+		actions := make(map[string]merkletrie.Action, len(objects))
+		for _, object := range objects {
+			actions[object.Path] = object.Action
+			if object.Action == merkletrie.Delete {
+				require.Nil(t, object.Blob)
+			} else {
+				require.NotNil(t, object.Blob)
+			}
+			// mem storage can not presign
+			require.Empty(t, object.URL)
+		}
+		return actions
+	}
+
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+	t.Run("full export", func(t *testing.T) {
+		objects, err := workRepo.ExportDelta(ctx, hash.Empty, DeltaExportOption{})
+		require.NoError(t, err)
+		require.Equal(t, map[string]merkletrie.Action{
+			"a.txt":         merkletrie.Insert,
+			"d.txt":         merkletrie.Insert,
+			"private/c.txt": merkletrie.Insert,
+			"private/e.txt": merkletrie.Insert,
+		}, actionsOf(objects))
+	})
+
+	t.Run("since synced commit", func(t *testing.T) {
+		objects, err := workRepo.ExportDelta(ctx, synced.Hash, DeltaExportOption{
+			Hidden: func(path string) bool {
+				return strings.HasPrefix(path, "private/")
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, map[string]merkletrie.Action{
+			"a.txt": merkletrie.Modify,
+			"b.txt": merkletrie.Delete,
+			"d.txt": merkletrie.Insert,
+		}, actionsOf(objects))
+	})
+
+	t.Run("under path", func(t *testing.T) {
+		objects, err := workRepo.ExportDelta(ctx, synced.Hash, DeltaExportOption{PathPrefix: "private"})
+		require.NoError(t, err)
+		require.Equal(t, map[string]merkletrie.Action{
+			"private/e.txt": merkletrie.Insert,
+		}, actionsOf(objects))
+	})
+
+	t.Run("head synced", func(t *testing.T) {
+		objects, err := workRepo.ExportDelta(ctx, workRepo.CurCommit().Hash, DeltaExportOption{})
+		require.NoError(t, err)
+		require.Len(t, objects, 0)
+	})
+
+	t.Run("unknown commit", func(t *testing.T) {
+		_, err := workRepo.ExportDelta(ctx, hash.Hash("0123456789abcdef"), DeltaExportOption{})
+		require.ErrorIs(t, err, models.ErrNotFound)
+	})
+}
@@ -0,0 +1,75 @@
+package versionmgr
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/pathutil"
+	"github.com/google/uuid"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var diagnosticsLog = logging.Logger("diagnostics")
+
+// StorageProbe time spent writing and reading back a probe object in storage namespace of repository
+type StorageProbe struct {
+	BlockstoreType string
+	Bytes          int64
+	Write          time.Duration
+	Read           time.Duration
+}
+
+// ProbeStorage write size random bytes to storage namespace, read them back and remove the probe object, so users can
+// tell slow transfers caused by storage from those caused by network between client and server
+func (repository *WorkRepository) ProbeStorage(ctx context.Context, size int64) (*StorageProbe, error) {
+	content := make([]byte, size)
+	_, err := rand.Read(content)
+	if err != nil {
+		return nil, err
+	}
+
+	pointer := block.ObjectPointer{
+		StorageNamespace: utils.StringValue(repository.repoModel.StorageNamespace),
+		IdentifierType:   block.IdentifierTypeRelative,
+		Identifier:       pathutil.PathOfProbe(uuid.NewString()),
+	}
+	probe := &StorageProbe{
+		BlockstoreType: repository.adapter.BlockstoreType(),
+		Bytes:          size,
+	}
+
+	start := time.Now()
+	err = repository.adapter.Put(ctx, pointer, size, bytes.NewReader(content), block.PutOpts{})
+	if err != nil {
+		return nil, err
+	}
+	probe.Write = time.Since(start)
+	defer func() {
+		if err := repository.adapter.Remove(context.Background(), pointer); err != nil {
+			diagnosticsLog.Warnf("remove storage probe %s %v", pointer.Identifier, err)
+		}
+	}()
+
+	start = time.Now()
+	reader, err := repository.adapter.Get(ctx, pointer, size)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close() //nolint
+	readBack, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	probe.Read = time.Since(start)
+
+	if !bytes.Equal(content, readBack) {
+		return nil, fmt.Errorf("probe object read back from storage differ from written, %d bytes written, %d bytes read", size, len(readBack))
+	}
+	return probe, nil
+}
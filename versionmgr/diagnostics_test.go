@@ -0,0 +1,48 @@
+package versionmgr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbeStorage(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	project, err := makeRepository(ctx, repo, user, "testProbe")
+	require.NoError(t, err)
+
+	removed := &removeRecorder{Adapter: adapter}
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, removed)
+	probe, err := workRepo.ProbeStorage(ctx, 4096)
+	require.NoError(t, err)
+	require.Equal(t, int64(4096), probe.Bytes)
+	require.Equal(t, adapter.BlockstoreType(), probe.BlockstoreType)
+
+	// probe object is removed
+	exist, err := adapter.Exists(ctx, removed.removed)
+	require.NoError(t, err)
+	require.False(t, exist)
+}
+
+type removeRecorder struct {
+	block.Adapter
+	removed block.ObjectPointer
+}
+
+func (recorder *removeRecorder) Remove(ctx context.Context, obj block.ObjectPointer) error {
+	recorder.removed = obj
+	return recorder.Adapter.Remove(ctx, obj)
+}
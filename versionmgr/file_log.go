@@ -0,0 +1,179 @@
+package versionmgr
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+)
+
+type FileLogParams struct {
+	after  *time.Time
+	amount int
+}
+
+func NewFileLogParams() *FileLogParams {
+	return &FileLogParams{}
+}
+
+// SetAfter only return commits committed before after
+func (params *FileLogParams) SetAfter(after time.Time) *FileLogParams {
+	params.after = &after
+	return params
+}
+
+// SetAmount max number of commits to return, zero means no limit
+func (params *FileLogParams) SetAmount(amount int) *FileLogParams {
+	params.amount = amount
+	return params
+}
+
+// FileLog return commits reachable from refHash which touched path, newest first. a commit touched path when entry of path
+// differs from all of its parents, merge commit which take path from one of parents is skipped like `git log -- path`.
+// trees are compared from root along path and comparing stops at the first unchanged directory, so commits not changing
+// the containing directory cost a single hash comparison
+func (repository *WorkRepository) FileLog(ctx context.Context, refHash hash.Hash, path string, params *FileLogParams) ([]*models.Commit, error) {
+	commitRepo := repository.repo.CommitRepo(repository.repoModel.ID)
+	commit, err := commitRepo.Commit(ctx, refHash)
+	if err != nil {
+		return nil, err
+	}
+
+	differ := &pathDiffer{
+		fileTreeRepo: repository.repo.FileTreeRepo(repository.repoModel.ID),
+		components:   splitPath(CleanPath(path)),
+		trees:        make(map[string]*models.TreeNode),
+	}
+
+	commits := make([]*models.Commit, 0)
+	iter := NewCommitIterCTime(ctx, NewWrapCommitNode(commitRepo, commit), nil, nil)
+	for {
+		node, err := iter.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if params.after != nil && !node.Commit().Committer.When.Before(*params.after) {
+			continue
+		}
+
+		touched, err := differ.touched(ctx, node)
+		if err != nil {
+			return nil, err
+		}
+		if !touched {
+			continue
+		}
+
+		commits = append(commits, node.Commit())
+		if params.amount > 0 && len(commits) == params.amount {
+			break
+		}
+	}
+	return commits, nil
+}
+
+// pathDiffer compare entry of path between commits, tree nodes are cached because each tree is visited for commit itself
+// and again for its children
+type pathDiffer struct {
+	fileTreeRepo models.IFileTreeRepo
+	components   []string
+	trees        map[string]*models.TreeNode
+}
+
+func (differ *pathDiffer) touched(ctx context.Context, node *WrapCommitNode) (bool, error) {
+	parents, err := node.Parents(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if len(parents) == 0 {
+		entryHash, err := differ.entryHash(ctx, node.TreeHash())
+		if err != nil {
+			return false, err
+		}
+		return !entryHash.IsEmpty(), nil
+	}
+
+	for _, parent := range parents {
+		changed, err := differ.changed(ctx, node.TreeHash(), parent.TreeHash())
+		if err != nil {
+			return false, err
+		}
+		if !changed {
+			return false, nil
+		}
+	}
+	return true, nil
+}
+
+// changed walk two trees along path together, stop as soon as directories on path are the same
+func (differ *pathDiffer) changed(ctx context.Context, treeA, treeB hash.Hash) (bool, error) {
+	a, b := treeA, treeB
+	for _, name := range differ.components {
+		if bytes.Equal(a, b) {
+			return false, nil
+		}
+
+		var err error
+		a, err = differ.lookup(ctx, a, name)
+		if err != nil {
+			return false, err
+		}
+		b, err = differ.lookup(ctx, b, name)
+		if err != nil {
+			return false, err
+		}
+	}
+	return !bytes.Equal(a, b), nil
+}
+
+func (differ *pathDiffer) entryHash(ctx context.Context, treeHash hash.Hash) (hash.Hash, error) {
+	h := treeHash
+	for _, name := range differ.components {
+		var err error
+		h, err = differ.lookup(ctx, h, name)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return h, nil
+}
+
+// lookup return hash of entry name in tree, empty hash if tree is empty or entry not exist
+func (differ *pathDiffer) lookup(ctx context.Context, treeHash hash.Hash, name string) (hash.Hash, error) {
+	if treeHash.IsEmpty() {
+		return hash.Empty, nil
+	}
+
+	tree, ok := differ.trees[treeHash.Hex()]
+	if !ok {
+		var err error
+		tree, err = differ.fileTreeRepo.TreeNode(ctx, treeHash)
+		if err != nil {
+			return nil, err
+		}
+		differ.trees[treeHash.Hex()] = tree
+	}
+
+	for _, entry := range tree.SubObjects {
+		if entry.Name == name {
+			return entry.Hash, nil
+		}
+	}
+	return hash.Empty, nil
+}
+
+func splitPath(path string) []string {
+	if len(path) == 0 {
+		return nil
+	}
+	return strings.Split(path, "/")
+}
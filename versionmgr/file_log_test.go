@@ -0,0 +1,88 @@
+package versionmgr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWorkRepositoryFileLog(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+
+	project, err := makeRepository(ctx, repo, user, "testFileLog")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	c1, err := addChangesToWip(ctx, workRepo, "main", "c1", `
+1|a.txt	|a
+1|b/c.txt	|c
+`)
+	require.NoError(t, err)
+	c2, err := addChangesToWip(ctx, workRepo, "main", "c2", `
+1|d.txt	|d
+`)
+	require.NoError(t, err)
+	c3, err := addChangesToWip(ctx, workRepo, "main", "c3", `
+3|b/c.txt	|c2
+`)
+	require.NoError(t, err)
+	c4, err := addChangesToWip(ctx, workRepo, "main", "c4", `
+2|b/c.txt	|
+3|a.txt	|a2
+`)
+	require.NoError(t, err)
+
+	hashesOf := func(commits []*models.Commit) []string {
+		var hashes []string
+		for _, commit := range commits {
+			hashes = append(hashes, commit.Hash.Hex())
+		}
+		return hashes
+	}
+
+	t.Run("file", func(t *testing.T) {
+		commits, err := workRepo.FileLog(ctx, c4.Hash, "b/c.txt", NewFileLogParams())
+		require.NoError(t, err)
+		require.Equal(t, []string{c4.Hash.Hex(), c3.Hash.Hex(), c1.Hash.Hex()}, hashesOf(commits))
+	})
+
+	t.Run("directory", func(t *testing.T) {
+		commits, err := workRepo.FileLog(ctx, c4.Hash, "b", NewFileLogParams())
+		require.NoError(t, err)
+		require.Equal(t, []string{c4.Hash.Hex(), c3.Hash.Hex(), c1.Hash.Hex()}, hashesOf(commits))
+	})
+
+	t.Run("root", func(t *testing.T) {
+		commits, err := workRepo.FileLog(ctx, c4.Hash, "", NewFileLogParams())
+		require.NoError(t, err)
+		require.Len(t, commits, 4)
+	})
+
+	t.Run("pagination", func(t *testing.T) {
+		commits, err := workRepo.FileLog(ctx, c4.Hash, "b/c.txt", NewFileLogParams().SetAmount(1))
+		require.NoError(t, err)
+		require.Equal(t, []string{c4.Hash.Hex()}, hashesOf(commits))
+
+		commits, err = workRepo.FileLog(ctx, c4.Hash, "b/c.txt", NewFileLogParams().SetAfter(commits[0].Committer.When).SetAmount(1))
+		require.NoError(t, err)
+		require.Equal(t, []string{c3.Hash.Hex()}, hashesOf(commits))
+	})
+
+	t.Run("not exist", func(t *testing.T) {
+		commits, err := workRepo.FileLog(ctx, c2.Hash, "b/c.txt/x", NewFileLogParams())
+		require.NoError(t, err)
+		require.Len(t, commits, 0)
+	})
+}
@@ -73,3 +73,23 @@ func (wk FileWalk) Walk(ctx context.Context, fn func(entry *models.TreeEntry, bl
 
 	return nil
 }
+
+// FilterWalk walk entries of underlying walk except the hidden ones, prefix is joined before path to check
+type FilterWalk struct {
+	walk   IWalk
+	prefix string
+	hidden func(string) bool
+}
+
+func NewFilterWalk(walk IWalk, prefix string, hidden func(string) bool) *FilterWalk {
+	return &FilterWalk{walk: walk, prefix: prefix, hidden: hidden}
+}
+
+func (wk FilterWalk) Walk(ctx context.Context, fn func(entry *models.TreeEntry, blob *models.Blob, path string) error) error {
+	return wk.walk.Walk(ctx, func(entry *models.TreeEntry, blob *models.Blob, entryPath string) error {
+		if wk.hidden(path.Join(wk.prefix, entryPath)) {
+			return nil
+		}
+		return fn(entry, blob, entryPath)
+	})
+}
@@ -0,0 +1,119 @@
+package versionmgr
+
+import (
+	"context"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+)
+
+// ForkRepository save fork as a new repository copying commits, objects, branches and tags of upstream. fork point at
+// the storage namespace of upstream, so data is shared instead of copied. content written later is content addressed, the
+// same content is stored once no matter which repository writes it. should be called in a transaction
+func ForkRepository(ctx context.Context, repo models.IRepo, upstream, fork *models.Repository) (*models.Repository, error) {
+	fork.ForkedFromID = upstream.ID
+	fork.UsePublicStorage = upstream.UsePublicStorage
+	fork.StorageNamespace = upstream.StorageNamespace
+	fork.StorageAdapterParams = upstream.StorageAdapterParams
+	fork.HashType = upstream.HashType
+	forked, err := repo.RepositoryRepo().Insert(ctx, fork)
+	if err != nil {
+		return nil, err
+	}
+
+	commits, err := repo.CommitRepo(upstream.ID).List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, commit := range commits {
+		commit.RepositoryID = forked.ID
+		_, err = repo.CommitRepo(forked.ID).Insert(ctx, commit)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	objects, err := repo.FileTreeRepo(upstream.ID).List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for i := range objects {
+		objects[i].RepositoryID = forked.ID
+	}
+	_, err = repo.FileTreeRepo(forked.ID).BatchInsert(ctx, objects)
+	if err != nil {
+		return nil, err
+	}
+
+	// index of packs is copied, pack files are shared like loose files
+	packs, err := repo.PackRepo().List(ctx, upstream.ID)
+	if err != nil {
+		return nil, err
+	}
+	packedObjects, err := repo.PackRepo().ListObjects(ctx, upstream.ID)
+	if err != nil {
+		return nil, err
+	}
+	objectsOfPack := make(map[string][]*models.PackedObject, len(packs))
+	for _, object := range packedObjects {
+		object.RepositoryID = forked.ID
+		objectsOfPack[object.PackCheckSum.Hex()] = append(objectsOfPack[object.PackCheckSum.Hex()], object)
+	}
+	for _, pack := range packs {
+		pack.RepositoryID = forked.ID
+		err = repo.PackRepo().Insert(ctx, pack, objectsOfPack[pack.CheckSum.Hex()])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	branches, _, err := repo.BranchRepo().List(ctx, models.NewListBranchParams().SetRepositoryID(upstream.ID))
+	if err != nil {
+		return nil, err
+	}
+	for _, branch := range branches {
+		_, err = repo.BranchRepo().Insert(ctx, &models.Branch{
+			RepositoryID: forked.ID,
+			CommitHash:   branch.CommitHash,
+			Name:         branch.Name,
+			Description:  branch.Description,
+			CreatorID:    fork.CreatorID,
+			CreatedAt:    time.Now(),
+			UpdatedAt:    time.Now(),
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	tags, _, err := repo.TagRepo().List(ctx, models.NewListTagParams().SetRepositoryID(upstream.ID))
+	if err != nil {
+		return nil, err
+	}
+	for _, tag := range tags {
+		_, err = repo.TagRepo().Insert(ctx, &models.Tag{
+			RepositoryID: forked.ID,
+			Name:         tag.Name,
+			CreatorID:    tag.CreatorID,
+			Target:       tag.Target,
+			Message:      tag.Message,
+			Type:         tag.Type,
+			CreatedAt:    tag.CreatedAt,
+			UpdatedAt:    tag.UpdatedAt,
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return forked, nil
+}
+
+// sharesStorage check whether storage namespace of repository is used by other repositories, like its forks or upstream.
+// data in shared storage may be referenced by others, so it is never removed on behalf of one repository
+func sharesStorage(ctx context.Context, repo models.IRepo, repoModel *models.Repository) (bool, error) {
+	sharers, err := repo.RepositoryRepo().ListSharingStorage(ctx, repoModel)
+	if err != nil {
+		return false, err
+	}
+	return len(sharers) > 0, nil
+}
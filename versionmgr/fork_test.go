@@ -0,0 +1,125 @@
+package versionmgr
+
+import (
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+func TestForkRepository(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	upstream, err := makeRepository(ctx, repo, user, "upstream")
+	require.NoError(t, err)
+
+	upstreamRepo := NewWorkRepositoryFromAdapter(ctx, user, upstream, repo, adapter)
+	_, err = addChangesToWip(ctx, upstreamRepo, "main", "base commit", `
+1|a.txt	|aaa
+1|b.txt	|bbbbb
+1|large.txt	|`+strings.Repeat("l", 20)+`
+`)
+	require.NoError(t, err)
+	_, err = upstreamRepo.Packer().Pack(ctx, PackOption{Threshold: 10})
+	require.NoError(t, err)
+	require.NoError(t, upstreamRepo.CheckOut(ctx, InBranch, "main"))
+	_, err = upstreamRepo.CreateTag(ctx, "v1", nil)
+	require.NoError(t, err)
+
+	var fork *models.Repository
+	err = repo.Transaction(ctx, func(repo models.IRepo) error {
+		fork, err = ForkRepository(ctx, repo, upstream, &models.Repository{
+			ID:        uuid.New(),
+			Name:      "fork",
+			HEAD:      upstream.HEAD,
+			OwnerID:   user.ID,
+			CreatorID: user.ID,
+			CreatedAt: time.Now(),
+			UpdatedAt: time.Now(),
+		})
+		return err
+	})
+	require.NoError(t, err)
+	require.Equal(t, upstream.ID, fork.ForkedFromID)
+	require.Equal(t, upstream.StorageNamespace, fork.StorageNamespace)
+
+	upstreamBranch, err := repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(upstream.ID).SetName("main"))
+	require.NoError(t, err)
+	forkBranch, err := repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(fork.ID).SetName("main"))
+	require.NoError(t, err)
+	require.Equal(t, upstreamBranch.CommitHash, forkBranch.CommitHash)
+	_, err = repo.TagRepo().Get(ctx, models.NewGetTagParams().SetRepositoryID(fork.ID).SetName("v1"))
+	require.NoError(t, err)
+
+	forkRepo := NewWorkRepositoryFromAdapter(ctx, user, fork, repo, adapter)
+	readFile := func(workRepo *WorkRepository, path string) string {
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+		workTree, err := workRepo.RootTree(ctx)
+		require.NoError(t, err)
+		blob, _, err := workTree.FindBlob(ctx, path)
+		require.NoError(t, err)
+		reader, err := workRepo.ReadBlob(ctx, blob, nil)
+		require.NoError(t, err)
+		defer reader.Close() //nolint
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		return string(data)
+	}
+	require.Equal(t, "aaa", readFile(forkRepo, "a.txt"))
+	require.Equal(t, strings.Repeat("l", 20), readFile(forkRepo, "large.txt"))
+
+	t.Run("shared storage is kept", func(t *testing.T) {
+		_, err := repo.BranchRepo().Delete(ctx, models.NewDeleteBranchParams().SetRepositoryID(upstream.ID).SetName("main"))
+		require.NoError(t, err)
+		_, err = repo.TagRepo().Delete(ctx, models.NewDeleteTagParams().SetRepositoryID(upstream.ID))
+		require.NoError(t, err)
+		_, err = repo.WipRepo().Delete(ctx, models.NewDeleteWipParams().SetRepositoryID(upstream.ID))
+		require.NoError(t, err)
+
+		result, err := upstreamRepo.GarbageCollector().GC(ctx, GcOption{})
+		require.NoError(t, err)
+		require.Equal(t, 3, result.UnreachableBlobs)
+		require.Equal(t, 0, result.ReclaimableFiles)
+
+		require.Equal(t, "aaa", readFile(forkRepo, "a.txt"))
+		require.Equal(t, "bbbbb", readFile(forkRepo, "b.txt"))
+		require.Equal(t, strings.Repeat("l", 20), readFile(forkRepo, "large.txt"))
+
+		packResult, err := forkRepo.Packer().Pack(ctx, PackOption{Threshold: 100})
+		require.NoError(t, err)
+		require.Equal(t, &PackResult{}, packResult)
+	})
+
+	t.Run("content no longer referenced is removed from shared storage", func(t *testing.T) {
+		require.NoError(t, forkRepo.CheckOut(ctx, InBranch, "main"))
+		_, err := forkRepo.CreateBranch(ctx, "feat")
+		require.NoError(t, err)
+		_, err = addChangesToWip(ctx, forkRepo, "feat", "feat commit", `
+1|c.txt	|only in fork
+3|a.txt	|aaa
+`)
+		require.NoError(t, err)
+		require.NoError(t, forkRepo.CheckOut(ctx, InBranch, "feat"))
+		require.NoError(t, forkRepo.DeleteBranch(ctx))
+
+		result, err := forkRepo.GarbageCollector().GC(ctx, GcOption{})
+		require.NoError(t, err)
+		require.Equal(t, 1, result.ReclaimableFiles)
+		require.Equal(t, int64(len("only in fork")), result.ReclaimableBytes)
+		require.Equal(t, "aaa", readFile(forkRepo, "a.txt"))
+	})
+}
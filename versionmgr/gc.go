@@ -0,0 +1,364 @@
+package versionmgr
+
+import (
+	"context"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/utils/pathutil"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var gcLog = logging.Logger("gc")
+
+// DefaultGcGracePeriod objects created recently should not be swept, they may belong to an upload which not reach wip yet
+var DefaultGcGracePeriod = time.Hour
+
+// GcOption options of garbage collection
+type GcOption struct {
+	// DryRun only report what can be reclaimed, nothing is deleted
+	DryRun bool
+	// GracePeriod objects created within this period are kept even they are unreachable
+	GracePeriod time.Duration
+}
+
+// GcResult report of garbage collection
+type GcResult struct {
+	DryRun             bool
+	ReachableCommits   int
+	ReachableObjects   int
+	UnreachableCommits int
+	UnreachableTrees   int
+	UnreachableBlobs   int
+	// ReclaimableFiles number of files in storage no longer referenced by any reachable blob, packs count when none of
+	// their blobs is referenced. files referenced by blobs of forks sharing the storage are kept, so are packs in it
+	ReclaimableFiles int
+	// ReclaimableBytes bytes of storage no longer referenced by any reachable blob
+	ReclaimableBytes int64
+	// UnreachablePackedBlobs blobs in packs no longer referenced, their bytes are reclaimed when the whole pack is removed
+	UnreachablePackedBlobs int
+}
+
+// GarbageCollector mark objects reachable from branches, tags and wips, and sweep the others
+type GarbageCollector struct {
+	repo      models.IRepo
+	repoModel *models.Repository
+	adapter   block.Adapter
+}
+
+func NewGarbageCollector(repo models.IRepo, repoModel *models.Repository, adapter block.Adapter) *GarbageCollector {
+	return &GarbageCollector{
+		repo:      repo,
+		repoModel: repoModel,
+		adapter:   adapter,
+	}
+}
+
+// GC run a mark and sweep on repository
+func (gc *GarbageCollector) GC(ctx context.Context, opt GcOption) (*GcResult, error) {
+	deadline := time.Now().Add(-opt.GracePeriod)
+
+	commitRepo := gc.repo.CommitRepo(gc.repoModel.ID)
+	fileTreeRepo := gc.repo.FileTreeRepo(gc.repoModel.ID)
+
+	commits, err := commitRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	allCommits := make(map[string]*models.Commit, len(commits))
+	for _, commit := range commits {
+		allCommits[commit.Hash.Hex()] = commit
+	}
+
+	objects, err := fileTreeRepo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	allObjects := make(map[string]*models.FileTree, len(objects))
+	for i := range objects {
+		allObjects[objects[i].Hash.Hex()] = &objects[i]
+	}
+
+	rootCommits, rootTrees, err := gc.roots(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// mark
+	markedCommits := make(map[string]struct{})
+	for len(rootCommits) > 0 {
+		commitHash := rootCommits[len(rootCommits)-1]
+		rootCommits = rootCommits[:len(rootCommits)-1]
+		if _, ok := markedCommits[commitHash.Hex()]; ok {
+			continue
+		}
+		markedCommits[commitHash.Hex()] = struct{}{}
+		commit, ok := allCommits[commitHash.Hex()]
+		if !ok {
+			gcLog.Warnf("commit %s of repo %s is missing", commitHash.Hex(), gc.repoModel.ID)
+			continue
+		}
+		rootTrees = append(rootTrees, commit.TreeHash)
+		rootCommits = append(rootCommits, commit.ParentHashes...)
+	}
+
+	markedObjects := make(map[string]struct{})
+	keptCheckSums := make(map[string]struct{})
+	for len(rootTrees) > 0 {
+		objHash := rootTrees[len(rootTrees)-1]
+		rootTrees = rootTrees[:len(rootTrees)-1]
+		if objHash.IsEmpty() {
+			continue
+		}
+		if _, ok := markedObjects[objHash.Hex()]; ok {
+			continue
+		}
+		markedObjects[objHash.Hex()] = struct{}{}
+		obj, ok := allObjects[objHash.Hex()]
+		if !ok {
+			gcLog.Warnf("object %s of repo %s is missing", objHash.Hex(), gc.repoModel.ID)
+			continue
+		}
+		if obj.Type == models.BlobObject {
+			keptCheckSums[obj.CheckSum.Hex()] = struct{}{}
+			continue
+		}
+		for _, entry := range obj.SubObjects {
+			rootTrees = append(rootTrees, entry.Hash)
+		}
+	}
+
+	// sweep
+	result := &GcResult{
+		DryRun:           opt.DryRun,
+		ReachableCommits: len(markedCommits),
+		ReachableObjects: len(markedObjects),
+	}
+
+	var sweepCommits []hash.Hash
+	for hex, commit := range allCommits {
+		if _, ok := markedCommits[hex]; ok || commit.CreatedAt.After(deadline) {
+			continue
+		}
+		sweepCommits = append(sweepCommits, commit.Hash)
+	}
+	result.UnreachableCommits = len(sweepCommits)
+
+	var sweepObjects []hash.Hash
+	sweepFiles := make(map[string]int64)
+	for hex, obj := range allObjects {
+		if _, ok := markedObjects[hex]; ok || obj.CreatedAt.After(deadline) {
+			if obj.Type == models.BlobObject {
+				// recent unreachable blobs keep their content too
+				keptCheckSums[obj.CheckSum.Hex()] = struct{}{}
+			}
+			continue
+		}
+		sweepObjects = append(sweepObjects, obj.Hash)
+		if obj.Type != models.BlobObject {
+			result.UnreachableTrees++
+			continue
+		}
+		result.UnreachableBlobs++
+		sweepFiles[obj.CheckSum.Hex()] = obj.Size
+	}
+	for checkSumHex := range keptCheckSums {
+		delete(sweepFiles, checkSumHex)
+	}
+
+	// content of packed blobs is dropped from index, pack is removed once none of its blobs is left
+	packs, err := gc.repo.PackRepo().List(ctx, gc.repoModel.ID)
+	if err != nil {
+		return nil, err
+	}
+	packedObjects, err := gc.repo.PackRepo().ListObjects(ctx, gc.repoModel.ID)
+	if err != nil {
+		return nil, err
+	}
+	livePackObjects := make(map[string]int, len(packs))
+	var sweepPackedObjects []hash.Hash
+	for _, object := range packedObjects {
+		if _, ok := keptCheckSums[object.CheckSum.Hex()]; ok {
+			livePackObjects[object.PackCheckSum.Hex()]++
+			continue
+		}
+		sweepPackedObjects = append(sweepPackedObjects, object.CheckSum)
+		delete(sweepFiles, object.CheckSum.Hex())
+	}
+	result.UnreachablePackedBlobs = len(sweepPackedObjects)
+	var sweepPacks []*models.Pack
+	for _, pack := range packs {
+		if livePackObjects[pack.CheckSum.Hex()] == 0 && pack.CreatedAt.Before(deadline) {
+			sweepPacks = append(sweepPacks, pack)
+		}
+	}
+
+	// forks share storage, content no longer referenced here may still be referenced by blobs of them
+	otherRefs, err := gc.countRefs(ctx, sweepFiles, true)
+	if err != nil {
+		return nil, err
+	}
+	for checkSumHex := range otherRefs {
+		delete(sweepFiles, checkSumHex)
+	}
+	result.ReclaimableFiles = len(sweepFiles)
+	for _, size := range sweepFiles {
+		result.ReclaimableBytes += size
+	}
+
+	// packs are not ref counted, packs in storage shared with forks are kept
+	shared, err := sharesStorage(ctx, gc.repo, gc.repoModel)
+	if err != nil {
+		return nil, err
+	}
+	if !shared {
+		result.ReclaimableFiles += len(sweepPacks)
+		for _, pack := range sweepPacks {
+			result.ReclaimableBytes += pack.Size
+		}
+	}
+
+	if opt.DryRun {
+		return result, nil
+	}
+
+	err = gc.repo.Transaction(ctx, func(repo models.IRepo) error {
+		for _, commitHash := range sweepCommits {
+			_, err := repo.CommitRepo(gc.repoModel.ID).Delete(ctx, models.NewDeleteParams().SetHash(commitHash))
+			if err != nil {
+				return err
+			}
+		}
+		for _, objHash := range sweepObjects {
+			_, err := repo.FileTreeRepo(gc.repoModel.ID).Delete(ctx, models.NewDeleteTreeParams().SetHash(objHash))
+			if err != nil {
+				return err
+			}
+		}
+		_, err := repo.PackRepo().DeleteObjects(ctx, gc.repoModel.ID, sweepPackedObjects)
+		if err != nil {
+			return err
+		}
+		for _, pack := range sweepPacks {
+			_, err = repo.PackRepo().DeletePack(ctx, gc.repoModel.ID, pack.CheckSum)
+			if err != nil {
+				return err
+			}
+		}
+		// refs of repository created before refs were tracked are repaired here
+		return repo.BlobRefRepo().Recalculate(ctx, gc.repoModel.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// content may be referenced again by a write racing with gc, check refs once more before removing it
+	refs, err := gc.countRefs(ctx, sweepFiles, false)
+	if err != nil {
+		return nil, err
+	}
+	for checkSumHex := range refs {
+		delete(sweepFiles, checkSumHex)
+	}
+
+	// remove data after metadata was deleted, a failure here only leave some garbage in storage
+	for checkSumHex := range sweepFiles {
+		checkSum, err := hash.FromHex(checkSumHex)
+		if err != nil {
+			return nil, err
+		}
+		err = gc.adapter.Remove(ctx, block.ObjectPointer{
+			StorageNamespace: utils.StringValue(gc.repoModel.StorageNamespace),
+			IdentifierType:   block.IdentifierTypeRelative,
+			Identifier:       pathutil.PathOfHash(checkSum),
+		})
+		if err != nil {
+			gcLog.Errorf("remove data %s of repo %s failed %v", checkSumHex, gc.repoModel.ID, err)
+		}
+	}
+	for _, pack := range sweepPacks {
+		if shared {
+			break
+		}
+		err = gc.adapter.Remove(ctx, block.ObjectPointer{
+			StorageNamespace: utils.StringValue(gc.repoModel.StorageNamespace),
+			IdentifierType:   block.IdentifierTypeRelative,
+			Identifier:       pathutil.PathOfPack(pack.CheckSum),
+		})
+		if err != nil {
+			gcLog.Errorf("remove pack %s of repo %s failed %v", pack.CheckSum.Hex(), gc.repoModel.ID, err)
+		}
+	}
+	return result, nil
+}
+
+// countRefs return refs of content held by repositories using the same storage, refs of this repository are skipped if
+// excludeSelf set
+func (gc *GarbageCollector) countRefs(ctx context.Context, files map[string]int64, excludeSelf bool) (map[string]int64, error) {
+	if len(files) == 0 {
+		return nil, nil
+	}
+	checkSums := make([]hash.Hash, 0, len(files))
+	for checkSumHex := range files {
+		checkSum, err := hash.FromHex(checkSumHex)
+		if err != nil {
+			return nil, err
+		}
+		checkSums = append(checkSums, checkSum)
+	}
+
+	params := models.NewCountBlobRefsParams().SetCheckSums(checkSums...)
+	if gc.repoModel.StorageNamespace != nil {
+		params = params.SetStorageNamespace(*gc.repoModel.StorageNamespace)
+	} else {
+		params = params.SetRepositoryID(gc.repoModel.ID)
+	}
+	if excludeSelf {
+		params = params.SetExcludeRepositoryID(gc.repoModel.ID)
+	}
+	return gc.repo.BlobRefRepo().Count(ctx, params)
+}
+
+// roots return commits and trees which are referenced directly by branches, tags and wips
+func (gc *GarbageCollector) roots(ctx context.Context) ([]hash.Hash, []hash.Hash, error) {
+	var commits []hash.Hash
+	var trees []hash.Hash
+
+	branches, _, err := gc.repo.BranchRepo().List(ctx, models.NewListBranchParams().SetRepositoryID(gc.repoModel.ID))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, branch := range branches {
+		if !branch.CommitHash.IsEmpty() {
+			commits = append(commits, branch.CommitHash)
+		}
+	}
+
+	tags, _, err := gc.repo.TagRepo().List(ctx, models.NewListTagParams().SetRepositoryID(gc.repoModel.ID))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, tag := range tags {
+		if !tag.Target.IsEmpty() {
+			commits = append(commits, tag.Target)
+		}
+	}
+
+	wips, _, err := gc.repo.WipRepo().List(ctx, models.NewListWipParams().SetRepositoryID(gc.repoModel.ID))
+	if err != nil {
+		return nil, nil, err
+	}
+	for _, wip := range wips {
+		if !wip.BaseCommit.IsEmpty() {
+			commits = append(commits, wip.BaseCommit)
+		}
+		trees = append(trees, wip.CurrentTree)
+		if wip.StagedTree != nil {
+			trees = append(trees, wip.StagedTree)
+		}
+	}
+	return commits, trees, nil
+}
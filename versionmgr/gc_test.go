@@ -0,0 +1,98 @@
+package versionmgr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGarbageCollector(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+
+	project, err := makeRepository(ctx, repo, user, "testGC")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	_, err = addChangesToWip(ctx, workRepo, "main", "base commit", `
+1|a.txt	|a
+`)
+	require.NoError(t, err)
+
+	err = workRepo.CheckOut(ctx, InBranch, "main")
+	require.NoError(t, err)
+	_, err = workRepo.CreateBranch(ctx, "feat")
+	require.NoError(t, err)
+	_, err = addChangesToWip(ctx, workRepo, "feat", "feat commit", `
+1|b/c.txt	|bbb
+`)
+	require.NoError(t, err)
+
+	gc := workRepo.GarbageCollector()
+	t.Run("nothing to collect", func(t *testing.T) {
+		result, err := gc.GC(ctx, GcOption{DryRun: true})
+		require.NoError(t, err)
+		require.Equal(t, 0, result.UnreachableCommits)
+		require.Equal(t, 0, result.UnreachableTrees)
+		require.Equal(t, 0, result.UnreachableBlobs)
+	})
+
+	err = workRepo.CheckOut(ctx, InBranch, "feat")
+	require.NoError(t, err)
+	err = workRepo.DeleteBranch(ctx)
+	require.NoError(t, err)
+
+	t.Run("keep objects in grace period", func(t *testing.T) {
+		result, err := gc.GC(ctx, GcOption{DryRun: true, GracePeriod: time.Hour})
+		require.NoError(t, err)
+		require.Equal(t, 0, result.UnreachableCommits)
+		require.Equal(t, 0, result.UnreachableBlobs)
+	})
+
+	t.Run("dry run", func(t *testing.T) {
+		result, err := gc.GC(ctx, GcOption{DryRun: true})
+		require.NoError(t, err)
+		require.True(t, result.DryRun)
+		require.Equal(t, 1, result.UnreachableCommits)
+		require.GreaterOrEqual(t, result.UnreachableTrees, 2) // root and b
+		require.Equal(t, 1, result.UnreachableBlobs)
+		require.Equal(t, 1, result.ReclaimableFiles)
+		require.Equal(t, int64(3), result.ReclaimableBytes)
+	})
+
+	t.Run("collect", func(t *testing.T) {
+		result, err := gc.GC(ctx, GcOption{})
+		require.NoError(t, err)
+		require.Equal(t, 1, result.UnreachableCommits)
+		require.Equal(t, 1, result.UnreachableBlobs)
+
+		result, err = gc.GC(ctx, GcOption{DryRun: true})
+		require.NoError(t, err)
+		require.Equal(t, 0, result.UnreachableCommits)
+		require.Equal(t, 0, result.UnreachableTrees)
+		require.Equal(t, 0, result.UnreachableBlobs)
+
+		//main still readable
+		err = workRepo.CheckOut(ctx, InBranch, "main")
+		require.NoError(t, err)
+		workTree, err := workRepo.RootTree(ctx)
+		require.NoError(t, err)
+		blob, _, err := workTree.FindBlob(ctx, "a.txt")
+		require.NoError(t, err)
+		reader, err := workRepo.ReadBlob(ctx, blob, nil)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+	})
+}
@@ -0,0 +1,336 @@
+package versionmgr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var hashMigrationLog = logging.Logger("hash_migration")
+
+// HashMigrationResult report of hash migration
+type HashMigrationResult struct {
+	From hash.HashType
+	To   hash.HashType
+	// Commits number of commits rewritten
+	Commits int
+	// Objects number of trees and blobs rewritten
+	Objects int
+}
+
+// HashMigrator rewrite all commits, trees and blobs of repository with another hash algorithm. content in storage is not
+// touched because blob data is addressed by checksum. branches, tags and wips are moved to rewritten hashes and every
+// old hash is recorded in hash mapping, so references held by clients can be resolved by ResolveHash.
+// old objects become unreachable and are removed by next gc
+type HashMigrator struct {
+	repo      models.IRepo
+	repoModel *models.Repository
+}
+
+func NewHashMigrator(repo models.IRepo, repoModel *models.Repository) *HashMigrator {
+	return &HashMigrator{
+		repo:      repo,
+		repoModel: repoModel,
+	}
+}
+
+// HashMigrator return hash migrator of this repository
+func (repository *WorkRepository) HashMigrator() *HashMigrator {
+	return NewHashMigrator(repository.repo, repository.repoModel)
+}
+
+// Migrate rewrite history with hash type to in a single transaction, refs are moved with compare and swap, so migration fails
+// with models.ErrRefConflict if any ref changed concurrently and can be retried
+func (migrator *HashMigrator) Migrate(ctx context.Context, to hash.HashType) (*HashMigrationResult, error) {
+	result := &HashMigrationResult{From: migrator.repoModel.HashType, To: to}
+	if migrator.repoModel.HashType == to {
+		return result, nil
+	}
+
+	repositoryID := migrator.repoModel.ID
+	err := migrator.repo.Transaction(ctx, func(repo models.IRepo) error {
+		commitRepo := repo.CommitRepo(repositoryID)
+		fileTreeRepo := repo.FileTreeRepo(repositoryID)
+
+		commits, err := commitRepo.List(ctx)
+		if err != nil {
+			return err
+		}
+		allCommits := make(map[string]*models.Commit, len(commits))
+		for _, commit := range commits {
+			allCommits[commit.Hash.Hex()] = commit
+		}
+
+		objects, err := fileTreeRepo.List(ctx)
+		if err != nil {
+			return err
+		}
+		allObjects := make(map[string]*models.FileTree, len(objects))
+		for i := range objects {
+			allObjects[objects[i].Hash.Hex()] = &objects[i]
+		}
+
+		rewriter := &hashRewriter{
+			to:           to,
+			commitRepo:   commitRepo,
+			fileTreeRepo: fileTreeRepo,
+			allCommits:   allCommits,
+			allObjects:   allObjects,
+			newHashes:    make(map[string]hash.Hash),
+		}
+
+		for _, commit := range commits {
+			_, err = rewriter.rewriteCommit(ctx, commit.Hash)
+			if err != nil {
+				return err
+			}
+		}
+
+		err = migrator.moveRefs(ctx, repo, rewriter)
+		if err != nil {
+			return err
+		}
+
+		// mappings written by former migration point to hashes rewritten now
+		formerMappings, err := repo.HashMappingRepo().List(ctx, repositoryID)
+		if err != nil {
+			return err
+		}
+		for _, mapping := range formerMappings {
+			if newHash, ok := rewriter.newHashes[mapping.NewHash.Hex()]; ok {
+				mapping.NewHash = newHash
+				rewriter.mappings = append(rewriter.mappings, mapping)
+			}
+		}
+
+		err = repo.HashMappingRepo().Upsert(ctx, rewriter.mappings)
+		if err != nil {
+			return err
+		}
+
+		result.Commits = rewriter.commits
+		result.Objects = rewriter.objects
+		return repo.RepositoryRepo().UpdateByID(ctx, models.NewUpdateRepoParams(repositoryID).SetHashType(to))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	hashMigrationLog.Infof("repository %s migrate hash from %s to %s, %d commits %d objects rewritten", repositoryID, result.From, to, result.Commits, result.Objects)
+	migrator.repoModel.HashType = to
+	return result, nil
+}
+
+func (migrator *HashMigrator) moveRefs(ctx context.Context, repo models.IRepo, rewriter *hashRewriter) error {
+	repositoryID := migrator.repoModel.ID
+	branches, _, err := repo.BranchRepo().List(ctx, models.NewListBranchParams().SetRepositoryID(repositoryID))
+	if err != nil {
+		return err
+	}
+	for _, branch := range branches {
+		if branch.CommitHash.IsEmpty() {
+			continue
+		}
+		newHash, err := rewriter.rewriteCommit(ctx, branch.CommitHash)
+		if err != nil {
+			return err
+		}
+		err = repo.BranchRepo().UpdateCommitHash(ctx, branch.ID, branch.CommitHash, newHash)
+		if err != nil {
+			return err
+		}
+	}
+
+	tags, _, err := repo.TagRepo().List(ctx, models.NewListTagParams().SetRepositoryID(repositoryID))
+	if err != nil {
+		return err
+	}
+	for _, tag := range tags {
+		newHash, err := rewriter.rewriteCommit(ctx, tag.Target)
+		if err != nil {
+			return err
+		}
+		err = repo.TagRepo().UpdateByID(ctx, models.NewUpdateTagParams(tag.ID).SetTarget(newHash))
+		if err != nil {
+			return err
+		}
+	}
+
+	wips, _, err := repo.WipRepo().List(ctx, models.NewListWipParams().SetRepositoryID(repositoryID))
+	if err != nil {
+		return err
+	}
+	for _, wip := range wips {
+		updateParams := models.NewUpdateWipParams(wip.ID)
+		baseCommit, err := rewriter.rewriteCommit(ctx, wip.BaseCommit)
+		if err != nil {
+			return err
+		}
+		currentTree, err := rewriter.rewriteObject(ctx, wip.CurrentTree)
+		if err != nil {
+			return err
+		}
+		updateParams.SetBaseCommit(baseCommit).SetCurrentTree(currentTree)
+		if !wip.StagedTree.IsEmpty() {
+			stagedTree, err := rewriter.rewriteObject(ctx, wip.StagedTree)
+			if err != nil {
+				return err
+			}
+			updateParams.SetStagedTree(stagedTree)
+		}
+		err = repo.WipRepo().UpdateByID(ctx, updateParams)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// hashRewriter rewrite commit and objects depth first, children are always rewritten before the node refer to them
+type hashRewriter struct {
+	to           hash.HashType
+	commitRepo   models.ICommitRepo
+	fileTreeRepo models.IFileTreeRepo
+	allCommits   map[string]*models.Commit
+	allObjects   map[string]*models.FileTree
+
+	newHashes map[string]hash.Hash
+	mappings  []*models.HashMapping
+	commits   int
+	objects   int
+}
+
+func (rewriter *hashRewriter) record(oldHash, newHash hash.Hash, objectType models.ObjectType) {
+	rewriter.newHashes[oldHash.Hex()] = newHash
+	if !bytes.Equal(oldHash, newHash) {
+		rewriter.mappings = append(rewriter.mappings, &models.HashMapping{
+			RepositoryID: rewriter.fileTreeRepo.RepositoryID(),
+			OldHash:      oldHash,
+			NewHash:      newHash,
+			Type:         objectType,
+			CreatedAt:    time.Now(),
+		})
+	}
+}
+
+func (rewriter *hashRewriter) rewriteCommit(ctx context.Context, commitHash hash.Hash) (hash.Hash, error) {
+	if commitHash.IsEmpty() {
+		return commitHash, nil
+	}
+	if newHash, ok := rewriter.newHashes[commitHash.Hex()]; ok {
+		return newHash, nil
+	}
+
+	commit, ok := rewriter.allCommits[commitHash.Hex()]
+	if !ok {
+		return nil, fmt.Errorf("commit %s %w", commitHash.Hex(), models.ErrNotFound)
+	}
+
+	newCommit := *commit
+	newCommit.ParentHashes = make([]hash.Hash, len(commit.ParentHashes))
+	for i, parentHash := range commit.ParentHashes {
+		newParentHash, err := rewriter.rewriteCommit(ctx, parentHash)
+		if err != nil {
+			return nil, err
+		}
+		newCommit.ParentHashes[i] = newParentHash
+	}
+
+	var err error
+	newCommit.TreeHash, err = rewriter.rewriteObject(ctx, commit.TreeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	newCommit.Hash, err = newCommit.GetHashWithType(rewriter.to)
+	if err != nil {
+		return nil, err
+	}
+
+	// commit may already exist if repository was migrated back to a former hash type and not gc yet
+	if _, exist := rewriter.allCommits[newCommit.Hash.Hex()]; !exist {
+		_, err = rewriter.commitRepo.Insert(ctx, &newCommit)
+		if err != nil {
+			return nil, err
+		}
+		rewriter.allCommits[newCommit.Hash.Hex()] = &newCommit
+		rewriter.commits++
+	}
+	rewriter.record(commitHash, newCommit.Hash, models.CommitObject)
+	return newCommit.Hash, nil
+}
+
+func (rewriter *hashRewriter) rewriteObject(ctx context.Context, objHash hash.Hash) (hash.Hash, error) {
+	if objHash.IsEmpty() {
+		return objHash, nil
+	}
+	if newHash, ok := rewriter.newHashes[objHash.Hex()]; ok {
+		return newHash, nil
+	}
+
+	obj, ok := rewriter.allObjects[objHash.Hex()]
+	if !ok {
+		return nil, fmt.Errorf("object %s %w", objHash.Hex(), models.ErrNotFound)
+	}
+
+	var newObj *models.FileTree
+	switch obj.Type {
+	case models.BlobObject:
+		blob, err := models.NewBlobWithHashType(rewriter.to, obj.Properties, obj.RepositoryID, obj.CheckSum, obj.Size)
+		if err != nil {
+			return nil, err
+		}
+		newObj = blob.FileTree()
+	case models.TreeObject:
+		subObjects := make([]models.TreeEntry, len(obj.SubObjects))
+		for i, sub := range obj.SubObjects {
+			newSubHash, err := rewriter.rewriteObject(ctx, sub.Hash)
+			if err != nil {
+				return nil, err
+			}
+			sub.Hash = newSubHash
+			subObjects[i] = sub
+		}
+		treeNode, err := models.NewTreeNodeWithHashType(rewriter.to, obj.Properties, obj.RepositoryID, subObjects...)
+		if err != nil {
+			return nil, err
+		}
+		newObj = treeNode.FileTree()
+	default:
+		return nil, errors.New("unexpect object type")
+	}
+
+	newObj.CreatedAt = obj.CreatedAt
+	newObj.UpdatedAt = obj.UpdatedAt
+	if _, exist := rewriter.allObjects[newObj.Hash.Hex()]; !exist {
+		_, err := rewriter.fileTreeRepo.Insert(ctx, newObj)
+		if err != nil {
+			return nil, err
+		}
+		rewriter.allObjects[newObj.Hash.Hex()] = newObj
+		rewriter.objects++
+	}
+	rewriter.record(objHash, newObj.Hash, obj.Type)
+	return newObj.Hash, nil
+}
+
+// ResolveHash return hash which commitHash or object hash was rewritten to by hash migration, hash not migrated is returned as is
+func ResolveHash(ctx context.Context, repo models.IRepo, repoModel *models.Repository, h hash.Hash) (hash.Hash, error) {
+	if h.IsEmpty() {
+		return h, nil
+	}
+	mapping, err := repo.HashMappingRepo().Get(ctx, models.NewGetHashMappingParams().SetRepositoryID(repoModel.ID).SetOldHash(h))
+	if errors.Is(err, models.ErrNotFound) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return mapping.NewHash, nil
+}
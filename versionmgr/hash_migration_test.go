@@ -0,0 +1,107 @@
+package versionmgr
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHashMigratorMigrate(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+
+	project, err := makeRepository(ctx, repo, user, "testHashMigration")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	_, err = addChangesToWip(ctx, workRepo, "main", "first commit", `
+1|a.txt	|a
+1|b/c.txt	|c
+`)
+	require.NoError(t, err)
+	oldCommit, err := addChangesToWip(ctx, workRepo, "main", "second commit", `
+1|d.txt	|d
+`)
+	require.NoError(t, err)
+	require.Len(t, oldCommit.Hash, 16)
+
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+	oldTag, err := workRepo.CreateTag(ctx, "v1", nil)
+	require.NoError(t, err)
+
+	result, err := workRepo.HashMigrator().Migrate(ctx, hash.SHA256)
+	require.NoError(t, err)
+	require.Equal(t, hash.Md5, result.From)
+	require.Equal(t, 2, result.Commits)
+	require.Greater(t, result.Objects, 0)
+
+	repoModel, err := repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetID(project.ID))
+	require.NoError(t, err)
+	require.Equal(t, hash.SHA256, repoModel.HashType)
+
+	branch, err := repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(project.ID).SetName("main"))
+	require.NoError(t, err)
+	require.Len(t, branch.CommitHash, 32)
+
+	newHash, err := ResolveHash(ctx, repo, project, oldCommit.Hash)
+	require.NoError(t, err)
+	require.Equal(t, branch.CommitHash, newHash)
+
+	tag, err := repo.TagRepo().Get(ctx, models.NewGetTagParams().SetID(oldTag.ID))
+	require.NoError(t, err)
+	require.Equal(t, branch.CommitHash, tag.Target)
+
+	newCommit, err := repo.CommitRepo(project.ID).Commit(ctx, branch.CommitHash)
+	require.NoError(t, err)
+	require.Len(t, newCommit.ParentHashes, 1)
+	require.Len(t, newCommit.ParentHashes[0], 32)
+
+	t.Run("resolve old hash", func(t *testing.T) {
+		err = workRepo.CheckOut(ctx, InCommit, oldCommit.Hash.Hex())
+		require.NoError(t, err)
+
+		workTree, err := workRepo.RootTree(ctx)
+		require.NoError(t, err)
+		entries, err := workTree.Ls(ctx, "")
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+
+		blob, _, err := workTree.FindBlob(ctx, "b/c.txt")
+		require.NoError(t, err)
+		reader, err := workRepo.ReadBlob(ctx, blob, nil)
+		require.NoError(t, err)
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		require.Equal(t, "c", string(data))
+	})
+
+	t.Run("commit after migration", func(t *testing.T) {
+		commit, err := addChangesToWip(ctx, workRepo, "main", "third commit", `
+1|e.txt	|e
+`)
+		require.NoError(t, err)
+		require.Len(t, commit.Hash, 32)
+		require.Equal(t, branch.CommitHash, commit.ParentHashes[0])
+	})
+
+	t.Run("same hash type", func(t *testing.T) {
+		result, err := workRepo.HashMigrator().Migrate(ctx, hash.SHA256)
+		require.NoError(t, err)
+		require.Equal(t, 0, result.Commits)
+		require.Equal(t, 0, result.Objects)
+	})
+}
@@ -0,0 +1,78 @@
+package versionmgr
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+)
+
+// runHooks run hooks of repository checking event on current branch, tree is the tree branch will point to after the
+// operation and baseHash is the commit its changes are counted from. the first failed hook reject operation with
+// models.ErrHookRejected
+func (repository *WorkRepository) runHooks(ctx context.Context, repo models.IRepo, event models.HookEvent, baseHash, treeHash hash.Hash, metadata map[string]string) error {
+	hooks := repository.repoModel.HooksOf(event, repository.branch.Name)
+	if len(hooks) == 0 {
+		return nil
+	}
+
+	var changes *Changes
+	for _, hook := range hooks {
+		var reason string
+		switch hook.Type {
+		case models.DenyPathsHook:
+			if changes == nil {
+				var err error
+				changes, err = repository.changesFrom(ctx, repo, baseHash, treeHash)
+				if err != nil {
+					return err
+				}
+			}
+			for _, change := range changes.Changes() {
+				if hook.DenyPath(change.Path()) {
+					reason = fmt.Sprintf("path %s must not be changed", change.Path())
+					break
+				}
+			}
+		case models.RequireMetadataHook:
+			var missing []string
+			for _, key := range hook.MetadataKeys {
+				if len(metadata[key]) == 0 {
+					missing = append(missing, key)
+				}
+			}
+			if len(missing) > 0 {
+				reason = fmt.Sprintf("commit metadata %s is required", strings.Join(missing, ", "))
+			}
+		}
+
+		if len(reason) == 0 {
+			continue
+		}
+		if len(hook.Message) > 0 {
+			reason = hook.Message
+		}
+		return fmt.Errorf("%s of branch %s, hook %s: %s %w", event, repository.branch.Name, hook.Name, reason, models.ErrHookRejected)
+	}
+	return nil
+}
+
+// changesFrom diff tree of commit parentHash with treeHash, all files of tree are added if parentHash is empty
+func (repository *WorkRepository) changesFrom(ctx context.Context, repo models.IRepo, parentHash, treeHash hash.Hash) (*Changes, error) {
+	parentTree := hash.Empty
+	if !parentHash.IsEmpty() {
+		parent, err := repo.CommitRepo(repository.repoModel.ID).Commit(ctx, parentHash)
+		if err != nil {
+			return nil, err
+		}
+		parentTree = parent.TreeHash
+	}
+
+	workTree, err := newWorkTree(ctx, repo.FileTreeRepo(repository.repoModel.ID), repository.repoModel, parentTree)
+	if err != nil {
+		return nil, err
+	}
+	return workTree.Diff(ctx, treeHash, "")
+}
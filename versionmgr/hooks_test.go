@@ -0,0 +1,99 @@
+package versionmgr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHooks(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	project, err := makeRepository(ctx, repo, user, "hooks")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	_, err = addChangesToWip(ctx, workRepo, "main", "base commit", `
+1|a.txt	|a
+`)
+	require.NoError(t, err)
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+	_, err = workRepo.CreateBranch(ctx, "feat")
+	require.NoError(t, err)
+
+	project.Hooks = []models.Hook{
+		{
+			Name:   "protect-raw",
+			Events: []models.HookEvent{models.PreCommitHook},
+			Branch: "main",
+			Type:   models.DenyPathsHook,
+			Paths:  []string{"raw", "*.tmp"},
+		},
+		{
+			Name:         "require-owner",
+			Events:       []models.HookEvent{models.PreMergeHook},
+			Type:         models.RequireMetadataHook,
+			MetadataKeys: []string{"owner"},
+			Message:      "set owner of data before merge",
+		},
+	}
+
+	t.Run("deny paths", func(t *testing.T) {
+		_, err := addChangesToWip(ctx, workRepo, "main", "touch raw", `
+1|b.txt	|b
+1|raw/x.csv	|x
+`)
+		require.ErrorIs(t, err, models.ErrHookRejected)
+		require.Contains(t, err.Error(), "raw/x.csv")
+
+		_, err = addChangesToWip(ctx, workRepo, "main", "add tmp", `
+1|c.tmp	|c
+`)
+		require.ErrorIs(t, err, models.ErrHookRejected)
+
+		_, err = addChangesToWip(ctx, workRepo, "main", "add b", `
+1|b.txt	|b
+1|rawdata.csv	|r
+`)
+		require.NoError(t, err)
+	})
+
+	t.Run("other branch", func(t *testing.T) {
+		_, err := addChangesToWip(ctx, workRepo, "feat", "touch raw", `
+1|raw/y.csv	|y
+`)
+		require.NoError(t, err)
+	})
+
+	t.Run("require metadata", func(t *testing.T) {
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, "feat"))
+		feat := workRepo.CurBranch()
+
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+		_, err := workRepo.Merge(ctx, feat.CommitHash, "merge feat", nil)
+		require.ErrorIs(t, err, models.ErrHookRejected)
+		require.Contains(t, err.Error(), "set owner of data before merge")
+
+		workRepo.SetCommitMetadata(map[string]string{"owner": "data-team"})
+		featHead, err := addChangesToWip(ctx, workRepo, "feat", "set owner", `
+1|d.txt	|d
+`)
+		require.NoError(t, err)
+		workRepo.SetCommitMetadata(nil)
+
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+		_, err = workRepo.Merge(ctx, featHead.Hash, "merge feat", nil)
+		require.NoError(t, err)
+	})
+}
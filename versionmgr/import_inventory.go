@@ -0,0 +1,258 @@
+package versionmgr
+
+import (
+	"compress/gzip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/GitDataAI/jiaozifs/block"
+)
+
+// ErrInvalidInventory inventory can not be parsed
+var ErrInvalidInventory = errors.New("invalid inventory")
+
+// columns of inventory read by import, names are matched case-insensitively
+const (
+	inventoryColumnBucket       = "bucket"
+	inventoryColumnKey          = "key"
+	inventoryColumnSize         = "size"
+	inventoryColumnIsLatest     = "islatest"
+	inventoryColumnDeleteMarker = "isdeletemarker"
+)
+
+// s3InventoryManifest manifest.json of S3 Inventory, only CSV format is supported
+type s3InventoryManifest struct {
+	DestinationBucket string `json:"destinationBucket"`
+	FileFormat        string `json:"fileFormat"`
+	FileSchema        string `json:"fileSchema"`
+	Files             []struct {
+		Key string `json:"key"`
+	} `json:"files"`
+}
+
+type inventoryFile struct {
+	location string
+	columns  []string
+	// keyEscaped keys are url encoded like keys in S3 Inventory
+	keyEscaped bool
+}
+
+var _ ImportSource = (*InventoryImportSource)(nil)
+
+// InventoryImportSource import objects under prefix of object store listed in an inventory instead of listing the
+// bucket live, which takes hours for buckets of hundreds of millions of keys. inventory is the manifest.json of an S3
+// Inventory in CSV format, or a csv file with a header naming its columns. Key and Size columns are required, Bucket
+// defaults to bucket of uri, versions not latest and delete markers are skipped. inventory is read from local file or
+// object store through adapter, files ending with .gz are decompressed
+type InventoryImportSource struct {
+	*ObjectStoreImportSource
+	inventory string
+}
+
+func NewInventoryImportSource(adapter block.Adapter, uri string, inventory string) (*InventoryImportSource, error) {
+	source, err := NewObjectStoreImportSource(adapter, uri)
+	if err != nil {
+		return nil, err
+	}
+	return &InventoryImportSource{ObjectStoreImportSource: source, inventory: inventory}, nil
+}
+
+func (source *InventoryImportSource) String() string {
+	return fmt.Sprintf("%s?inventory=%s", source.uri.String(), url.QueryEscape(source.inventory))
+}
+
+// Walk list objects under uri in the order of inventory
+func (source *InventoryImportSource) Walk(ctx context.Context, walkFn func(entry ImportEntry) error) error {
+	files, err := source.inventoryFiles(ctx)
+	if err != nil {
+		return err
+	}
+
+	bucket := source.uri.Host
+	prefix := strings.TrimPrefix(source.uri.Path, "/")
+	for _, file := range files {
+		err = source.walkFile(ctx, file, func(objectBucket, key string, size int64) error {
+			if objectBucket != bucket || !strings.HasPrefix(key, prefix) || strings.HasSuffix(key, "/") {
+				return nil
+			}
+			return walkFn(ImportEntry{
+				Path:    strings.TrimPrefix(strings.TrimPrefix(key, prefix), "/"),
+				Size:    size,
+				address: fmt.Sprintf("%s://%s/%s", source.uri.Scheme, objectBucket, key),
+			})
+		})
+		if err != nil {
+			return fmt.Errorf("inventory file %s %w", file.location, err)
+		}
+	}
+	return nil
+}
+
+func (source *InventoryImportSource) inventoryFiles(ctx context.Context) ([]inventoryFile, error) {
+	if !strings.HasSuffix(source.inventory, ".json") {
+		// columns are named by header of csv
+		return []inventoryFile{{location: source.inventory}}, nil
+	}
+
+	reader, err := source.open(ctx, source.inventory)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close() //nolint
+
+	manifest := &s3InventoryManifest{}
+	err = json.NewDecoder(reader).Decode(manifest)
+	if err != nil {
+		return nil, fmt.Errorf("decode manifest %v %w", err, ErrInvalidInventory)
+	}
+	if !strings.EqualFold(manifest.FileFormat, "csv") {
+		return nil, fmt.Errorf("file format %s is not supported, only CSV %w", manifest.FileFormat, ErrInvalidInventory)
+	}
+
+	columns := strings.Split(manifest.FileSchema, ",")
+	for i, column := range columns {
+		columns[i] = strings.TrimSpace(column)
+	}
+	// data files are in destination bucket, referred by the same scheme as manifest
+	scheme := source.uri.Scheme
+	if inventoryURI, err := url.Parse(source.inventory); err == nil && len(inventoryURI.Scheme) > 0 {
+		scheme = inventoryURI.Scheme
+	}
+	destinationBucket := strings.TrimPrefix(manifest.DestinationBucket, "arn:aws:s3:::")
+	files := make([]inventoryFile, len(manifest.Files))
+	for i, file := range manifest.Files {
+		files[i] = inventoryFile{
+			location:   fmt.Sprintf("%s://%s/%s", scheme, destinationBucket, file.Key),
+			columns:    columns,
+			keyEscaped: true,
+		}
+	}
+	return files, nil
+}
+
+func (source *InventoryImportSource) walkFile(ctx context.Context, file inventoryFile, walkFn func(bucket, key string, size int64) error) error {
+	reader, err := source.open(ctx, file.location)
+	if err != nil {
+		return err
+	}
+	defer reader.Close() //nolint
+
+	csvReader := csv.NewReader(reader)
+	csvReader.FieldsPerRecord = -1
+	columns := file.columns
+	if len(columns) == 0 {
+		columns, err = csvReader.Read()
+		if err != nil {
+			return fmt.Errorf("read header %v %w", err, ErrInvalidInventory)
+		}
+	}
+	index := make(map[string]int)
+	for i, column := range columns {
+		index[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+	keyIndex, hasKey := index[inventoryColumnKey]
+	sizeIndex, hasSize := index[inventoryColumnSize]
+	if !hasKey || !hasSize {
+		return fmt.Errorf("key and size columns are required %w", ErrInvalidInventory)
+	}
+	field := func(record []string, column string) string {
+		i, ok := index[column]
+		if !ok || i >= len(record) {
+			return ""
+		}
+		return record[i]
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		record, err := csvReader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%v %w", err, ErrInvalidInventory)
+		}
+		if keyIndex >= len(record) || sizeIndex >= len(record) {
+			return fmt.Errorf("line %d has %d columns %w", lineOf(csvReader), len(record), ErrInvalidInventory)
+		}
+		if strings.EqualFold(field(record, inventoryColumnIsLatest), "false") || strings.EqualFold(field(record, inventoryColumnDeleteMarker), "true") {
+			continue
+		}
+
+		key := record[keyIndex]
+		if file.keyEscaped {
+			key, err = url.QueryUnescape(key)
+			if err != nil {
+				return fmt.Errorf("line %d key %s %v %w", lineOf(csvReader), record[keyIndex], err, ErrInvalidInventory)
+			}
+		}
+		size, err := strconv.ParseInt(record[sizeIndex], 10, 64)
+		if err != nil {
+			return fmt.Errorf("line %d size %s %w", lineOf(csvReader), record[sizeIndex], ErrInvalidInventory)
+		}
+		bucket := field(record, inventoryColumnBucket)
+		if len(bucket) == 0 {
+			bucket = source.uri.Host
+		}
+		err = walkFn(bucket, key, size)
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// open read inventory file from object store when location is an uri, or from local file
+func (source *InventoryImportSource) open(ctx context.Context, location string) (io.ReadCloser, error) {
+	var reader io.ReadCloser
+	var err error
+	if locationURI, parseErr := url.Parse(location); parseErr == nil && len(locationURI.Scheme) > 1 {
+		reader, err = source.adapter.Get(ctx, block.ObjectPointer{
+			Identifier:     location,
+			IdentifierType: block.IdentifierTypeFull,
+		}, -1)
+	} else {
+		reader, err = os.Open(location)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if path.Ext(location) != ".gz" {
+		return reader, nil
+	}
+
+	gzipReader, err := gzip.NewReader(reader)
+	if err != nil {
+		_ = reader.Close()
+		return nil, fmt.Errorf("decompress %s %v %w", location, err, ErrInvalidInventory)
+	}
+	return &gzipReadCloser{Reader: gzipReader, underlying: reader}, nil
+}
+
+type gzipReadCloser struct {
+	*gzip.Reader
+	underlying io.Closer
+}
+
+func (reader *gzipReadCloser) Close() error {
+	err := reader.Reader.Close()
+	if closeErr := reader.underlying.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func lineOf(reader *csv.Reader) int {
+	line, _ := reader.FieldPos(0)
+	return line
+}
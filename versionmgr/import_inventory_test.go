@@ -0,0 +1,130 @@
+package versionmgr
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func putObject(ctx context.Context, t *testing.T, adapter block.Adapter, uri string, data []byte) {
+	err := adapter.Put(ctx, block.ObjectPointer{Identifier: uri, IdentifierType: block.IdentifierTypeFull}, int64(len(data)), bytes.NewReader(data), block.PutOpts{})
+	require.NoError(t, err)
+}
+
+func walkPaths(ctx context.Context, t *testing.T, source ImportSource) []string {
+	var paths []string
+	err := source.Walk(ctx, func(entry ImportEntry) error {
+		paths = append(paths, entry.Path)
+		return nil
+	})
+	require.NoError(t, err)
+	return paths
+}
+
+func TestInventoryImportSource(t *testing.T) {
+	ctx := context.Background()
+	adapter := mem.New(ctx)
+	putObject(ctx, t, adapter, "mem://bucket/data/a.txt", []byte("a"))
+	putObject(ctx, t, adapter, "mem://bucket/data/b/c d.txt", []byte("cd"))
+	putObject(ctx, t, adapter, "mem://bucket/other/x.txt", []byte("x"))
+
+	t.Run("csv", func(t *testing.T) {
+		inventory := filepath.Join(t.TempDir(), "inventory.csv")
+		err := os.WriteFile(inventory, []byte("Key,Size\ndata/b/c d.txt,2\ndata/,0\nother/x.txt,1\ndata/a.txt,1\n"), 0644)
+		require.NoError(t, err)
+
+		source, err := NewInventoryImportSource(adapter, "mem://bucket/data/", inventory)
+		require.NoError(t, err)
+		require.Equal(t, []string{"b/c d.txt", "a.txt"}, walkPaths(ctx, t, source))
+	})
+
+	t.Run("s3 inventory", func(t *testing.T) {
+		var data bytes.Buffer
+		gzipWriter := gzip.NewWriter(&data)
+		_, err := gzipWriter.Write([]byte(`"bucket","data/a.txt","1","true","false"
+"bucket","data/a.txt","3","false","false"
+"bucket","data/b/c+d.txt","2","true","false"
+"bucket","data/deleted.txt","0","true","true"
+"other","data/y.txt","1","true","false"
+`))
+		require.NoError(t, err)
+		require.NoError(t, gzipWriter.Close())
+		putObject(ctx, t, adapter, "mem://inventory/bucket/data/1.csv.gz", data.Bytes())
+		putObject(ctx, t, adapter, "mem://inventory/bucket/manifest.json", []byte(`{
+  "sourceBucket": "bucket",
+  "destinationBucket": "arn:aws:s3:::inventory",
+  "fileFormat": "CSV",
+  "fileSchema": "Bucket, Key, Size, IsLatest, IsDeleteMarker",
+  "files": [{"key": "bucket/data/1.csv.gz"}]
+}`))
+
+		source, err := NewInventoryImportSource(adapter, "mem://bucket/data", "mem://inventory/bucket/manifest.json")
+		require.NoError(t, err)
+		require.Equal(t, []string{"a.txt", "b/c d.txt"}, walkPaths(ctx, t, source))
+	})
+
+	t.Run("missing size column", func(t *testing.T) {
+		inventory := filepath.Join(t.TempDir(), "inventory.csv")
+		err := os.WriteFile(inventory, []byte("Bucket,Key\nbucket,data/a.txt\n"), 0644)
+		require.NoError(t, err)
+
+		source, err := NewInventoryImportSource(adapter, "mem://bucket/data/", inventory)
+		require.NoError(t, err)
+		err = source.Walk(ctx, func(ImportEntry) error { return nil })
+		require.ErrorIs(t, err, ErrInvalidInventory)
+	})
+
+	t.Run("parquet", func(t *testing.T) {
+		putObject(ctx, t, adapter, "mem://inventory/parquet/manifest.json", []byte(`{"fileFormat": "Parquet", "files": []}`))
+		source, err := NewInventoryImportSource(adapter, "mem://bucket/data/", "mem://inventory/parquet/manifest.json")
+		require.NoError(t, err)
+		err = source.Walk(ctx, func(ImportEntry) error { return nil })
+		require.ErrorIs(t, err, ErrInvalidInventory)
+	})
+}
+
+func TestImporterImportInventory(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+	putObject(ctx, t, adapter, "mem://bucket/data/a.txt", []byte("a"))
+	putObject(ctx, t, adapter, "mem://bucket/data/b/c.txt", []byte("cd"))
+
+	inventory := filepath.Join(t.TempDir(), "inventory.csv")
+	err := os.WriteFile(inventory, []byte(strings.Join([]string{"key,size", "data/a.txt,1", "data/b/c.txt,2", ""}, "\n")), 0644)
+	require.NoError(t, err)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+
+	project, err := makeRepository(ctx, repo, user, "testImportInventory")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	source, err := NewInventoryImportSource(adapter, "mem://bucket/data/", inventory)
+	require.NoError(t, err)
+	result, err := NewImporter(workRepo, 1).Import(ctx, source, "main", "import dataset")
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Files)
+	require.Equal(t, int64(3), result.Bytes)
+
+	workTree, err := workRepo.RootTree(ctx)
+	require.NoError(t, err)
+	for _, path := range []string{"a.txt", "b/c.txt"} {
+		_, _, err = workTree.FindBlob(ctx, path)
+		require.NoError(t, err, path)
+	}
+}
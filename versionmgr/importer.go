@@ -0,0 +1,331 @@
+package versionmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/utils/pathutil"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var importLog = logging.Logger("importer")
+
+var DefaultImportBatchSize = 1000
+
+// ErrBranchNotEmpty import only create the initial commit of branch
+var ErrBranchNotEmpty = errors.New("branch already has commits")
+
+// ErrImportSourceChanged files of source are not the ones checkpoint of unfinished import was saved for
+var ErrImportSourceChanged = errors.New("import source changed since checkpoint")
+
+// ImportEntry a file in import source
+type ImportEntry struct {
+	// Path relative to root of source, separated by slash
+	Path string
+	Size int64
+	// address of file in source
+	address string
+}
+
+// ImportSource external dataset to import, files must be walked in the same order every time so an interrupted import
+// can be resumed
+type ImportSource interface {
+	// String identify source, import is only resumed from checkpoint of the same source
+	String() string
+	Walk(ctx context.Context, walkFn func(entry ImportEntry) error) error
+	Open(ctx context.Context, entry ImportEntry) (io.ReadCloser, error)
+}
+
+var _ ImportSource = (*DirImportSource)(nil)
+
+// DirImportSource import files in local directory, files matched by ignore matcher are skipped
+type DirImportSource struct {
+	dir           string
+	ignoreMatcher *pathutil.IgnoreMatcher
+}
+
+func NewDirImportSource(dir string, ignoreMatcher *pathutil.IgnoreMatcher) *DirImportSource {
+	return &DirImportSource{dir: dir, ignoreMatcher: ignoreMatcher}
+}
+
+func (source *DirImportSource) String() string {
+	dir, err := filepath.Abs(source.dir)
+	if err != nil {
+		dir = source.dir
+	}
+	return "file://" + filepath.ToSlash(dir)
+}
+
+func (source *DirImportSource) Walk(ctx context.Context, walkFn func(entry ImportEntry) error) error {
+	return filepath.WalkDir(source.dir, func(file string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		relativePath, err := filepath.Rel(source.dir, file)
+		if err != nil {
+			return err
+		}
+		if relativePath == "." {
+			return nil
+		}
+		if source.ignoreMatcher.Match(relativePath, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		return walkFn(ImportEntry{
+			Path:    filepath.ToSlash(relativePath),
+			Size:    info.Size(),
+			address: file,
+		})
+	})
+}
+
+func (source *DirImportSource) Open(_ context.Context, entry ImportEntry) (io.ReadCloser, error) {
+	return os.Open(entry.address)
+}
+
+var _ ImportSource = (*ObjectStoreImportSource)(nil)
+
+// ObjectStoreImportSource import objects under prefix of object store, like s3://bucket/dataset/
+type ObjectStoreImportSource struct {
+	adapter block.Adapter
+	uri     *url.URL
+}
+
+func NewObjectStoreImportSource(adapter block.Adapter, uri string) (*ObjectStoreImportSource, error) {
+	storageURI, err := url.Parse(uri)
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectStoreImportSource{adapter: adapter, uri: storageURI}, nil
+}
+
+func (source *ObjectStoreImportSource) String() string {
+	return source.uri.String()
+}
+
+func (source *ObjectStoreImportSource) Walk(ctx context.Context, walkFn func(entry ImportEntry) error) error {
+	walker, err := source.adapter.GetWalker(source.uri)
+	if err != nil {
+		return err
+	}
+	return block.NewWrapper(walker, source.uri).Walk(ctx, block.WalkOptions{}, func(e block.ObjectStoreEntry) error {
+		return walkFn(ImportEntry{
+			Path:    e.RelativeKey,
+			Size:    e.Size,
+			address: e.Address,
+		})
+	})
+}
+
+func (source *ObjectStoreImportSource) Open(ctx context.Context, entry ImportEntry) (io.ReadCloser, error) {
+	return source.adapter.Get(ctx, block.ObjectPointer{
+		Identifier:     entry.address,
+		IdentifierType: block.IdentifierTypeFull,
+	}, entry.Size)
+}
+
+// ImportResult report of import
+type ImportResult struct {
+	Files int
+	Bytes int64
+	// Resumed files imported by interrupted runs before, counted in Files
+	Resumed int
+	Commit  *models.Commit
+}
+
+// Importer bulk ingest existing dataset into an empty branch. file content is uploaded through storage adapter of
+// repository, leaves are added to tree in batches, each batch in a transaction, so memory and transaction size don't
+// grow with the dataset. the initial commit of branch is created when all files were added.
+//
+// a checkpoint is saved with every batch, running import of the same source into the same branch again continues
+// after the last saved batch, files uploaded after it are uploaded again which is harmless as blobs are content addressed
+type Importer struct {
+	repository *WorkRepository
+	batchSize  int
+	restart    bool
+}
+
+func NewImporter(repository *WorkRepository, batchSize int) *Importer {
+	if batchSize <= 0 {
+		batchSize = DefaultImportBatchSize
+	}
+	return &Importer{repository: repository, batchSize: batchSize}
+}
+
+// SetRestart discard checkpoint of unfinished import and import from the first file
+func (importer *Importer) SetRestart(restart bool) *Importer {
+	importer.restart = restart
+	return importer
+}
+
+type importedLeaf struct {
+	key  string
+	path string
+	blob *models.Blob
+}
+
+// Import walk source and commit all files to branch, branch must have no commit. unfinished import of the same source
+// into branch is resumed, import of another source fails with ErrImportSourceChanged unless restarted
+func (importer *Importer) Import(ctx context.Context, source ImportSource, branchName string, msg string) (*ImportResult, error) {
+	repository := importer.repository
+	err := repository.CheckOut(ctx, InBranch, branchName)
+	if err != nil {
+		return nil, err
+	}
+	if !repository.branch.CommitHash.IsEmpty() {
+		return nil, fmt.Errorf("branch %s %w", branchName, ErrBranchNotEmpty)
+	}
+
+	checkpointRepo := repository.repo.ImportCheckpointRepo()
+	checkpoint, err := checkpointRepo.Get(ctx, repository.repoModel.ID, branchName)
+	if err != nil && !errors.Is(err, models.ErrNotFound) {
+		return nil, err
+	}
+	if checkpoint != nil && importer.restart {
+		_, err = checkpointRepo.Delete(ctx, repository.repoModel.ID, branchName)
+		if err != nil {
+			return nil, err
+		}
+		checkpoint = nil
+	}
+	if checkpoint != nil && checkpoint.Source != source.String() {
+		return nil, fmt.Errorf("branch %s has unfinished import from %s %w", branchName, checkpoint.Source, ErrImportSourceChanged)
+	}
+
+	result := &ImportResult{}
+	rootHash := hash.Empty
+	var skip int
+	if checkpoint != nil {
+		rootHash = checkpoint.RootHash
+		skip = int(checkpoint.Files)
+		result.Files = skip
+		result.Bytes = checkpoint.Bytes
+		result.Resumed = skip
+		importLog.Infof("resume import to branch %s of repository %s after %d files", branchName, repository.repoModel.Name, skip)
+	}
+
+	batch := make([]importedLeaf, 0, importer.batchSize)
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		err := repository.repo.Transaction(ctx, func(repo models.IRepo) error {
+			workTree, err := newWorkTree(ctx, repo.FileTreeRepo(repository.repoModel.ID), repository.repoModel, rootHash)
+			if err != nil {
+				return err
+			}
+			for _, leaf := range batch {
+				err = workTree.AddLeaf(ctx, leaf.path, leaf.blob)
+				if err != nil {
+					return fmt.Errorf("add %s %w", leaf.path, err)
+				}
+			}
+			rootHash = workTree.Root().Hash()
+			return repo.ImportCheckpointRepo().Save(ctx, &models.ImportCheckpoint{
+				RepositoryID: repository.repoModel.ID,
+				BranchName:   branchName,
+				Source:       source.String(),
+				RootHash:     rootHash,
+				LastKey:      batch[len(batch)-1].key,
+				Files:        int64(result.Files),
+				Bytes:        result.Bytes,
+				CreatedAt:    time.Now(),
+				UpdatedAt:    time.Now(),
+			})
+		})
+		if err != nil {
+			return err
+		}
+		importLog.Debugf("import %d files to repository %s", result.Files, repository.repoModel.Name)
+		batch = batch[:0]
+		return nil
+	}
+
+	walked := 0
+	err = source.Walk(ctx, func(entry ImportEntry) error {
+		walked++
+		if walked <= skip {
+			if walked == skip && entry.Path != checkpoint.LastKey {
+				return fmt.Errorf("file %d is %s but %s in checkpoint %w", walked, entry.Path, checkpoint.LastKey, ErrImportSourceChanged)
+			}
+			return nil
+		}
+
+		reader, err := source.Open(ctx, entry)
+		if err != nil {
+			return err
+		}
+		defer reader.Close() //nolint
+
+		blob, err := repository.WriteBlob(ctx, reader, entry.Size, models.DefaultLeafProperty())
+		if err != nil {
+			return fmt.Errorf("upload %s %w", entry.Path, err)
+		}
+
+		batch = append(batch, importedLeaf{key: entry.Path, path: CleanPath(entry.Path), blob: blob})
+		result.Files++
+		result.Bytes += entry.Size
+		if len(batch) >= importer.batchSize {
+			return flush()
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if walked < skip {
+		return nil, fmt.Errorf("source has %d files but %d in checkpoint %w", walked, skip, ErrImportSourceChanged)
+	}
+	err = flush()
+	if err != nil {
+		return nil, err
+	}
+
+	author := models.Signature{
+		Name:  repository.operator.Name,
+		Email: repository.operator.Email,
+		When:  time.Now(),
+	}
+	err = repository.repo.Transaction(ctx, func(repo models.IRepo) error {
+		result.Commit, err = repository.commitChangeRoot(ctx, repo, author, rootHash, msg)
+		if err != nil {
+			return err
+		}
+		_, err = repo.ImportCheckpointRepo().Delete(ctx, repository.repoModel.ID, branchName)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	repository.branch.CommitHash = result.Commit.Hash
+	repository.headTree = &result.Commit.TreeHash
+	repository.commit = result.Commit
+	importLog.Infof("import %d files %d bytes to branch %s of repository %s", result.Files, result.Bytes, branchName, repository.repoModel.Name)
+	return result, nil
+}
@@ -0,0 +1,175 @@
+package versionmgr
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils/pathutil"
+	"github.com/stretchr/testify/require"
+)
+
+func makeImportDir(t *testing.T) string {
+	dir := t.TempDir()
+	files := map[string]string{
+		"a.txt":         "a",
+		"b/c.txt":       "c",
+		"b/d/e.txt":     "e",
+		"tmp/x.log":     "x",
+		"f.tmp":         "f",
+		"b/d/keep.data": "keep",
+	}
+	for name, content := range files {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		require.NoError(t, os.MkdirAll(filepath.Dir(p), 0755))
+		require.NoError(t, os.WriteFile(p, []byte(content), 0644))
+	}
+	return dir
+}
+
+func TestDirImportSourceWalk(t *testing.T) {
+	ctx := context.Background()
+	dir := makeImportDir(t)
+
+	ignoreMatcher, err := pathutil.NewIgnoreMatcher("*.tmp", "tmp/")
+	require.NoError(t, err)
+	source := NewDirImportSource(dir, ignoreMatcher)
+
+	var paths []string
+	err = source.Walk(ctx, func(entry ImportEntry) error {
+		paths = append(paths, entry.Path)
+		reader, err := source.Open(ctx, entry)
+		require.NoError(t, err)
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.NoError(t, reader.Close())
+		require.Equal(t, entry.Size, int64(len(data)))
+		return nil
+	})
+	require.NoError(t, err)
+	sort.Strings(paths)
+	require.Equal(t, []string{"a.txt", "b/c.txt", "b/d/e.txt", "b/d/keep.data"}, paths)
+}
+
+func TestImporterImport(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+
+	project, err := makeRepository(ctx, repo, user, "testImport")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	result, err := NewImporter(workRepo, 2).Import(ctx, NewDirImportSource(makeImportDir(t), nil), "main", "import dataset")
+	require.NoError(t, err)
+	require.Equal(t, 6, result.Files)
+	require.Len(t, result.Commit.ParentHashes, 0)
+
+	branch, err := repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(project.ID).SetName("main"))
+	require.NoError(t, err)
+	require.Equal(t, result.Commit.Hash, branch.CommitHash)
+
+	workTree, err := workRepo.RootTree(ctx)
+	require.NoError(t, err)
+	blob, _, err := workTree.FindBlob(ctx, "b/d/e.txt")
+	require.NoError(t, err)
+	reader, err := workRepo.ReadBlob(ctx, blob, nil)
+	require.NoError(t, err)
+	data, err := io.ReadAll(reader)
+	require.NoError(t, err)
+	require.NoError(t, reader.Close())
+	require.Equal(t, "e", string(data))
+
+	_, err = NewImporter(workRepo, 2).Import(ctx, NewDirImportSource(makeImportDir(t), nil), "main", "import again")
+	require.ErrorIs(t, err, ErrBranchNotEmpty)
+}
+
+var errInterrupted = errors.New("interrupted")
+
+// interruptedImportSource stop walking after some files, like a crashed import
+type interruptedImportSource struct {
+	ImportSource
+	stopAfter int
+}
+
+func (source *interruptedImportSource) Walk(ctx context.Context, walkFn func(entry ImportEntry) error) error {
+	walked := 0
+	return source.ImportSource.Walk(ctx, func(entry ImportEntry) error {
+		if walked == source.stopAfter {
+			return errInterrupted
+		}
+		walked++
+		return walkFn(entry)
+	})
+}
+
+func TestImporterResume(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+
+	project, err := makeRepository(ctx, repo, user, "testImportResume")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	source := NewDirImportSource(makeImportDir(t), nil)
+	_, err = NewImporter(workRepo, 2).Import(ctx, &interruptedImportSource{ImportSource: source, stopAfter: 3}, "main", "import dataset")
+	require.ErrorIs(t, err, errInterrupted)
+
+	checkpoint, err := repo.ImportCheckpointRepo().Get(ctx, project.ID, "main")
+	require.NoError(t, err)
+	require.Equal(t, int64(2), checkpoint.Files)
+	require.Equal(t, source.String(), checkpoint.Source)
+
+	t.Run("other source", func(t *testing.T) {
+		_, err := NewImporter(workRepo, 2).Import(ctx, NewDirImportSource(makeImportDir(t), nil), "main", "import dataset")
+		require.ErrorIs(t, err, ErrImportSourceChanged)
+	})
+
+	result, err := NewImporter(workRepo, 2).Import(ctx, source, "main", "import dataset")
+	require.NoError(t, err)
+	require.Equal(t, 2, result.Resumed)
+	require.Equal(t, 6, result.Files)
+
+	_, err = repo.ImportCheckpointRepo().Get(ctx, project.ID, "main")
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	workTree, err := workRepo.RootTree(ctx)
+	require.NoError(t, err)
+	for _, path := range []string{"a.txt", "b/c.txt", "b/d/e.txt", "b/d/keep.data", "f.tmp", "tmp/x.log"} {
+		_, _, err = workTree.FindBlob(ctx, path)
+		require.NoError(t, err, path)
+	}
+
+	t.Run("restart", func(t *testing.T) {
+		project, err := makeRepository(ctx, repo, user, "testImportRestart")
+		require.NoError(t, err)
+		workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+		_, err = NewImporter(workRepo, 2).Import(ctx, &interruptedImportSource{ImportSource: source, stopAfter: 3}, "main", "import dataset")
+		require.ErrorIs(t, err, errInterrupted)
+
+		result, err := NewImporter(workRepo, 2).SetRestart(true).Import(ctx, NewDirImportSource(makeImportDir(t), nil), "main", "import dataset")
+		require.NoError(t, err)
+		require.Equal(t, 0, result.Resumed)
+		require.Equal(t, 6, result.Files)
+	})
+}
@@ -44,18 +44,7 @@ func (c *WrapCommitNode) MergeBase(ctx context.Context, other *WrapCommitNode) (
 // It returns an error if the history is not transversable
 // It mimics the behavior of `git merge --is-ancestor actual other`
 func (c *WrapCommitNode) IsAncestor(ctx context.Context, other *WrapCommitNode) (bool, error) {
-	found := false
-	iter := NewCommitPreorderIter(ctx, other, nil, nil)
-	err := iter.ForEach(func(comm *WrapCommitNode) error {
-		if !bytes.Equal(comm.Commit().Hash, c.Commit().Hash) {
-			return nil
-		}
-
-		found = true
-		return ErrStop
-	})
-
-	return found, err
+	return IsAncestor(ctx, c.commitRepo, c.Commit().Hash, other.Commit().Hash)
 }
 
 // ancestorsIndex returns a map with the ancestors of the starting commit if the
@@ -0,0 +1,362 @@
+package versionmgr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/utils/httputil"
+	"github.com/GitDataAI/jiaozifs/utils/masking"
+	"github.com/GitDataAI/jiaozifs/versionmgr/merkletrie/noder"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var driverLog = logging.Logger("merge_driver")
+
+// maxDriverSize files larger than this are not diffed or merged by driver
+const maxDriverSize = 16 << 20
+
+var (
+	ErrMergeDriverNotFound = errors.New("merge driver not found")
+	ErrFileTooLarge        = errors.New("file too large")
+)
+
+type ContentChangeKind string
+
+const (
+	ContentAdded    ContentChangeKind = "added"
+	ContentRemoved  ContentChangeKind = "removed"
+	ContentModified ContentChangeKind = "modified"
+)
+
+// ContentChange change of a part of file found by driver, Key locate the part in file such as row key or json pointer,
+// Old and New are the part before and after change encoded as text
+type ContentChange struct {
+	Kind ContentChangeKind
+	Key  string
+	Old  string
+	New  string
+}
+
+// ContentDiff changes inside a file between two versions
+type ContentDiff struct {
+	Path    string
+	Driver  string
+	Changes []ContentChange
+}
+
+// ContentConflictError parts of file changed differently on both sides, Keys locate the parts the same way as
+// ContentChange.Key
+type ContentConflictError struct {
+	Keys []string
+}
+
+func (err ContentConflictError) Error() string {
+	return fmt.Sprintf("conflict at %s", strings.Join(err.Keys, ", "))
+}
+
+func (err ContentConflictError) Unwrap() error {
+	return ErrConflict
+}
+
+// MergeDriver diff and merge content of files it is registered for by understanding their structure, so that sides
+// changing different parts of a file are not conflict. content of version not exist is nil. Merge return error
+// wrapping ErrConflict if both sides changed the same part differently, drivers able to locate all conflicting parts
+// return ContentConflictError
+type MergeDriver interface {
+	Name() string
+	Diff(from, to []byte) ([]ContentChange, error)
+	Merge(base, ours, theirs []byte) ([]byte, error)
+}
+
+// DriverRule select files handled by driver. ContentType match content type detected from file extension,
+// PathPattern match full path or file name if pattern has no slash, syntax of pattern is the same as path.Match
+type DriverRule struct {
+	ContentType string
+	PathPattern string
+}
+
+func (rule DriverRule) match(filePath, contentType string) bool {
+	if len(rule.ContentType) > 0 && rule.ContentType == contentType {
+		return true
+	}
+	if len(rule.PathPattern) == 0 {
+		return false
+	}
+	name := filePath
+	if !strings.Contains(rule.PathPattern, "/") {
+		name = path.Base(filePath)
+	}
+	matched, _ := path.Match(rule.PathPattern, name)
+	return matched
+}
+
+type driverRegistration struct {
+	rules  []DriverRule
+	driver MergeDriver
+}
+
+var (
+	mergeDriversLk sync.RWMutex
+	mergeDrivers   []driverRegistration
+)
+
+func init() {
+	RegisterMergeDriver(tabularMergeDriver{}, DriverRule{ContentType: "text/csv"}, DriverRule{PathPattern: "*.csv"})
+	RegisterMergeDriver(jsonMergeDriver{}, DriverRule{ContentType: "application/json"}, DriverRule{PathPattern: "*.json"})
+	RegisterMergeDriver(geoJSONMergeDriver{}, DriverRule{ContentType: "application/geo+json"}, DriverRule{PathPattern: "*.geojson"})
+}
+
+// RegisterMergeDriver use driver for files matching any of rules. drivers registered later take precedence, so builtin
+// drivers can be overridden for some files
+func RegisterMergeDriver(driver MergeDriver, rules ...DriverRule) {
+	mergeDriversLk.Lock()
+	defer mergeDriversLk.Unlock()
+	mergeDrivers = append(mergeDrivers, driverRegistration{rules: rules, driver: driver})
+}
+
+// FindMergeDriver find driver for file at filePath
+func FindMergeDriver(filePath string) (MergeDriver, bool) {
+	contentType := httputil.ExtensionsByType(filePath)
+	mergeDriversLk.RLock()
+	defer mergeDriversLk.RUnlock()
+	for i := len(mergeDrivers) - 1; i >= 0; i-- {
+		for _, rule := range mergeDrivers[i].rules {
+			if rule.match(filePath, contentType) {
+				return mergeDrivers[i].driver, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// MergeDrivers names of registered drivers
+func MergeDrivers() []string {
+	mergeDriversLk.RLock()
+	defer mergeDriversLk.RUnlock()
+	exists := make(map[string]bool)
+	names := make([]string, 0, len(mergeDrivers))
+	for _, registration := range mergeDrivers {
+		if name := registration.driver.Name(); !exists[name] {
+			exists[name] = true
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithMergeDrivers merge paths changed on both sides with driver registered for them before falling back to strategy,
+// paths without driver, deleted on one side or both sides changed the same part go to strategy
+func WithMergeDrivers(strategy MergeStrategy) MergeStrategy {
+	return driverMergeStrategy{fallback: strategy}
+}
+
+type driverMergeStrategy struct {
+	fallback MergeStrategy
+}
+
+func (s driverMergeStrategy) Name() string {
+	return s.fallback.Name()
+}
+
+func (s driverMergeStrategy) Resolve(ctx context.Context, objects MergeObjects, path string, base, ours, theirs *models.Blob) (*models.Blob, error) {
+	result, err := mergeByDriver(ctx, objects, path, base, ours, theirs)
+	if err != nil {
+		return nil, err
+	}
+	if !result.Merged {
+		return s.fallback.Resolve(ctx, objects, path, base, ours, theirs)
+	}
+	return objects.WriteBlob(ctx, bytes.NewReader(result.Content), int64(len(result.Content)), ours.Properties)
+}
+
+// driverMergeResult result of merging a path by driver, Driver is empty if path has no driver or driver is not used.
+// ConflictKeys are parts conflicting if driver located them
+type driverMergeResult struct {
+	Driver       string
+	Merged       bool
+	Content      []byte
+	ConflictKeys []string
+}
+
+// mergeByDriver merge content of path with its driver, result is not merged if path has no driver or driver can not
+// merge it
+func mergeByDriver(ctx context.Context, objects MergeObjects, path string, base, ours, theirs *models.Blob) (*driverMergeResult, error) {
+	driver, ok := FindMergeDriver(path)
+	if !ok || ours == nil || theirs == nil {
+		return &driverMergeResult{}, nil
+	}
+
+	contents := make([][]byte, 3)
+	for index, blob := range []*models.Blob{base, ours, theirs} {
+		content, err := readDriverContent(ctx, objects, path, blob, nil)
+		if errors.Is(err, ErrFileTooLarge) {
+			return &driverMergeResult{}, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		contents[index] = content
+	}
+
+	// content driver can not parse is left to strategy the same as conflict found by driver
+	result := &driverMergeResult{Driver: driver.Name()}
+	content, err := driver.Merge(contents[0], contents[1], contents[2])
+	if err != nil {
+		driverLog.Debugf("path %s not merged by driver %s %v", path, driver.Name(), err)
+		var conflictErr ContentConflictError
+		if errors.As(err, &conflictErr) {
+			result.ConflictKeys = conflictErr.Keys
+		}
+		return result, nil
+	}
+	result.Merged = true
+	result.Content = content
+	return result, nil
+}
+
+// mergeThreeWay merge a part of file, part not exist is nil. ok is false if both sides changed it differently
+func mergeThreeWay[T any](base, ours, theirs *T, equal func(a, b T) bool) (*T, bool) {
+	same := func(a, b *T) bool {
+		if a == nil || b == nil {
+			return a == nil && b == nil
+		}
+		return equal(*a, *b)
+	}
+	switch {
+	case same(ours, theirs):
+		return ours, true
+	case same(base, ours):
+		return theirs, true
+	case same(base, theirs):
+		return ours, true
+	}
+	return nil, false
+}
+
+func contentChange(key string, oldExist, newExist bool, old, new string) ContentChange {
+	switch {
+	case !oldExist:
+		return ContentChange{Kind: ContentAdded, Key: key, New: new}
+	case !newExist:
+		return ContentChange{Kind: ContentRemoved, Key: key, Old: old}
+	}
+	return ContentChange{Kind: ContentModified, Key: key, Old: old, New: new}
+}
+
+// readDriverContent read whole content of blob, nil blob has nil content. content is masked if masks is not empty
+func readDriverContent(ctx context.Context, objects MergeObjects, path string, blob *models.Blob, masks map[string]masking.Method) ([]byte, error) {
+	if blob == nil {
+		return nil, nil
+	}
+	if blob.Size > maxDriverSize {
+		return nil, fmt.Errorf("file larger than %d bytes %w", maxDriverSize, ErrFileTooLarge)
+	}
+	reader, err := objects.ReadBlob(ctx, blob, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close() //nolint
+
+	if format, ok := masking.FormatFromPath(path); ok && len(masks) > 0 {
+		return io.ReadAll(masking.NewReader(reader, format, masks))
+	}
+	return io.ReadAll(reader)
+}
+
+// resolveByDrivers clear conflict of pairs which registered driver merges cleanly and record conflicting parts of the
+// others, pairs are merge state returned by GetMergeState and merged content is not saved
+func (repository *WorkRepository) resolveByDrivers(ctx context.Context, pairs []*ChangePair) error {
+	fileTreeRepo := repository.repo.FileTreeRepo(repository.repoModel.ID)
+	blobOf := func(path noder.Path) (*models.Blob, error) {
+		if path == nil {
+			return nil, nil
+		}
+		return fileTreeRepo.Blob(ctx, path.Hash())
+	}
+
+	for _, pair := range pairs {
+		if !pair.IsConflict {
+			continue
+		}
+		if _, ok := FindMergeDriver(pair.Right.Path()); !ok || pair.Left.To() == nil || pair.Right.To() == nil {
+			continue
+		}
+
+		base, err := blobOf(pair.Right.From())
+		if err != nil {
+			return err
+		}
+		ours, err := blobOf(pair.Right.To())
+		if err != nil {
+			return err
+		}
+		theirs, err := blobOf(pair.Left.To())
+		if err != nil {
+			return err
+		}
+
+		result, err := mergeByDriver(ctx, repository, pair.Right.Path(), base, ours, theirs)
+		if err != nil {
+			return err
+		}
+		if result.Merged {
+			pair.IsConflict = false
+			pair.Driver = result.Driver
+		} else if len(result.ConflictKeys) > 0 {
+			pair.Driver = result.Driver
+			pair.ConflictKeys = result.ConflictKeys
+		}
+	}
+	return nil
+}
+
+// DiffContent compare content of file at path in current commit with it in toCommitHash by driver registered for the
+// path. masks are applied to both versions before compare. return ErrMergeDriverNotFound if path has no driver
+func (repository *WorkRepository) DiffContent(ctx context.Context, toCommitHash hash.Hash, path string, masks map[string]masking.Method) (*ContentDiff, error) {
+	path = CleanPath(path)
+	driver, ok := FindMergeDriver(path)
+	if !ok {
+		return nil, fmt.Errorf("path %s %w", path, ErrMergeDriverNotFound)
+	}
+
+	fromTree, err := repository.RootTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	toCommit, err := repository.repo.CommitRepo(repository.repoModel.ID).Commit(ctx, toCommitHash)
+	if err != nil {
+		return nil, err
+	}
+	toTree, err := newWorkTree(ctx, repository.repo.FileTreeRepo(repository.repoModel.ID), repository.repoModel, toCommit.TreeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	contents := make([][]byte, 2)
+	for index, workTree := range []*WorkTree{fromTree, toTree} {
+		blob, _, err := workTree.FindBlob(ctx, path)
+		if err != nil && !errors.Is(err, ErrPathNotFound) {
+			return nil, err
+		}
+		contents[index], err = readDriverContent(ctx, repository, path, blob, masks)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	changes, err := driver.Diff(contents[0], contents[1])
+	if err != nil {
+		return nil, fmt.Errorf("path %s diff by driver %s %w", path, driver.Name(), err)
+	}
+	return &ContentDiff{Path: path, Driver: driver.Name(), Changes: changes}, nil
+}
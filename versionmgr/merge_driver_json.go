@@ -0,0 +1,539 @@
+package versionmgr
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// jsonMergeDriver diff and merge json documents member by member of objects, arrays and other values are compared as a
+// whole. members changed on one side or changed the same way on both sides are merged, only members changed differently
+// on both sides are conflict. merged document keeps member order and indentation of ours, members only theirs added
+// are appended to the object
+type jsonMergeDriver struct{}
+
+func (jsonMergeDriver) Name() string {
+	return "json"
+}
+
+func (jsonMergeDriver) Diff(from, to []byte) ([]ContentChange, error) {
+	fromValue, err := decodeJSON(from)
+	if err != nil {
+		return nil, err
+	}
+	toValue, err := decodeJSON(to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]ContentChange, 0)
+	diffJSON("", fromValue, toValue, &changes)
+	return changes, nil
+}
+
+func (jsonMergeDriver) Merge(base, ours, theirs []byte) ([]byte, error) {
+	values, err := decodeJSONs(base, ours, theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	var conflicts []string
+	result := mergeJSON("", values[0], values[1], values[2], &conflicts)
+	if len(conflicts) > 0 {
+		return nil, ContentConflictError{Keys: conflicts}
+	}
+	return encodeJSON(result, detectJSONLayout(ours))
+}
+
+// geoJSONMergeDriver diff and merge features of geojson FeatureCollection one by one, features are identified by their
+// id member or by position if they have no id. other members and documents of other types are handled as json
+type geoJSONMergeDriver struct{}
+
+func (geoJSONMergeDriver) Name() string {
+	return "geojson"
+}
+
+func (geoJSONMergeDriver) Diff(from, to []byte) ([]ContentChange, error) {
+	fromValue, err := decodeJSON(from)
+	if err != nil {
+		return nil, err
+	}
+	toValue, err := decodeJSON(to)
+	if err != nil {
+		return nil, err
+	}
+
+	fromCollection, fromOk := splitFeatureCollection(fromValue)
+	toCollection, toOk := splitFeatureCollection(toValue)
+	if (fromValue != nil && !fromOk) || (toValue != nil && !toOk) {
+		return jsonMergeDriver{}.Diff(from, to)
+	}
+
+	changes := make([]ContentChange, 0)
+	diffJSON("", fromCollection.members, toCollection.members, &changes)
+	for _, key := range fromCollection.keys {
+		diffJSON(featurePointer(key), fromCollection.feature(key), toCollection.feature(key), &changes)
+	}
+	for _, key := range toCollection.keys {
+		if fromCollection.feature(key) == nil {
+			diffJSON(featurePointer(key), nil, toCollection.feature(key), &changes)
+		}
+	}
+	return changes, nil
+}
+
+func (geoJSONMergeDriver) Merge(base, ours, theirs []byte) ([]byte, error) {
+	values, err := decodeJSONs(base, ours, theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	collections := make([]*featureCollection, len(values))
+	for index, value := range values {
+		collection, ok := splitFeatureCollection(value)
+		if value != nil && !ok {
+			return jsonMergeDriver{}.Merge(base, ours, theirs)
+		}
+		collections[index] = collection
+	}
+	baseCollection, oursCollection, theirsCollection := collections[0], collections[1], collections[2]
+
+	var conflicts []string
+	result := mergeJSON("", baseCollection.members, oursCollection.members, theirsCollection.members, &conflicts)
+
+	keys := append([]string{}, oursCollection.keys...)
+	for _, key := range theirsCollection.keys {
+		if oursCollection.feature(key) == nil {
+			keys = append(keys, key)
+		}
+	}
+	features := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		feature, ok := mergeThreeWay(baseCollection.feature(key), oursCollection.feature(key), theirsCollection.feature(key), jsonEqual)
+		if !ok {
+			conflicts = append(conflicts, featurePointer(key))
+			continue
+		}
+		if feature != nil {
+			features = append(features, *feature)
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, ContentConflictError{Keys: conflicts}
+	}
+
+	document, ok := asJSONObject(result)
+	if !ok {
+		return nil, ContentConflictError{Keys: []string{displayJSONPointer("")}}
+	}
+	document.insert(oursCollection.featuresIndex, "features", features)
+	return encodeJSON(result, detectJSONLayout(ours))
+}
+
+// featureCollection features of FeatureCollection by key and members other than features, featuresIndex is the
+// position of features member in document
+type featureCollection struct {
+	members       *interface{}
+	featuresIndex int
+	keys          []string
+	features      map[string]interface{}
+}
+
+func (collection *featureCollection) feature(key string) *interface{} {
+	feature, ok := collection.features[key]
+	if !ok {
+		return nil
+	}
+	return &feature
+}
+
+// splitFeatureCollection split geojson FeatureCollection, ok is false if value is not a FeatureCollection. collection of
+// value not exist has no members or features
+func splitFeatureCollection(value *interface{}) (*featureCollection, bool) {
+	collection := &featureCollection{features: make(map[string]interface{})}
+	document, ok := asJSONObject(value)
+	if !ok || document.members["type"] != "FeatureCollection" {
+		return collection, false
+	}
+	features, ok := document.members["features"].([]interface{})
+	if !ok {
+		return collection, false
+	}
+
+	members := newJSONObject()
+	for index, key := range document.keys {
+		if key == "features" {
+			collection.featuresIndex = index
+			continue
+		}
+		members.set(key, document.members[key])
+	}
+	var membersValue interface{} = members
+	collection.members = &membersValue
+
+	for index, feature := range features {
+		key := "#" + strconv.Itoa(index)
+		if featureObject, ok := asJSONObject(&feature); ok {
+			if id, ok := featureObject.members["id"]; ok && id != nil {
+				key = fmt.Sprint(id)
+			}
+		}
+		if _, ok := collection.features[key]; ok {
+			key = key + "#" + strconv.Itoa(index)
+		}
+		collection.keys = append(collection.keys, key)
+		collection.features[key] = feature
+	}
+	return collection, true
+}
+
+func featurePointer(key string) string {
+	return "/features/" + escapeJSONPointer(key)
+}
+
+// mergeJSON merge json values, value not exist is nil. objects changed on both sides are merged member by member,
+// pointers of values changed differently on both sides are appended to conflicts
+func mergeJSON(pointer string, base, ours, theirs *interface{}, conflicts *[]string) *interface{} {
+	if result, ok := mergeThreeWay(base, ours, theirs, jsonEqual); ok {
+		return result
+	}
+
+	baseObject, baseOk := asJSONObject(base)
+	oursObject, oursOk := asJSONObject(ours)
+	theirsObject, theirsOk := asJSONObject(theirs)
+	if !oursOk || !theirsOk || (base != nil && !baseOk) {
+		*conflicts = append(*conflicts, displayJSONPointer(pointer))
+		return ours
+	}
+
+	merged := newJSONObject()
+	for _, key := range unionKeys(oursObject, theirsObject, baseObject) {
+		result := mergeJSON(pointer+"/"+escapeJSONPointer(key), baseObject.member(key), oursObject.member(key), theirsObject.member(key), conflicts)
+		if result != nil {
+			merged.set(key, *result)
+		}
+	}
+	var mergedValue interface{} = merged
+	return &mergedValue
+}
+
+// diffJSON append changes between json values, objects existing in both are compared member by member
+func diffJSON(pointer string, from, to *interface{}, changes *[]ContentChange) {
+	if from == nil && to == nil {
+		return
+	}
+	if from != nil && to != nil && jsonEqual(*from, *to) {
+		return
+	}
+
+	fromObject, fromOk := asJSONObject(from)
+	toObject, toOk := asJSONObject(to)
+	if fromOk && toOk {
+		for _, key := range unionKeys(fromObject, toObject) {
+			diffJSON(pointer+"/"+escapeJSONPointer(key), fromObject.member(key), toObject.member(key), changes)
+		}
+		return
+	}
+
+	var oldText, newText string
+	if from != nil {
+		data, _ := encodeJSON(from, jsonLayout{})
+		oldText = string(data)
+	}
+	if to != nil {
+		data, _ := encodeJSON(to, jsonLayout{})
+		newText = string(data)
+	}
+	*changes = append(*changes, contentChange(pointer, from != nil, to != nil, oldText, newText))
+}
+
+// jsonObject members of json object in the order they are written
+type jsonObject struct {
+	keys    []string
+	members map[string]interface{}
+}
+
+func newJSONObject() *jsonObject {
+	return &jsonObject{members: make(map[string]interface{})}
+}
+
+// member return nil if member not exist, object may be nil
+func (object *jsonObject) member(key string) *interface{} {
+	if object == nil {
+		return nil
+	}
+	member, ok := object.members[key]
+	if !ok {
+		return nil
+	}
+	return &member
+}
+
+func (object *jsonObject) set(key string, value interface{}) {
+	if _, ok := object.members[key]; !ok {
+		object.keys = append(object.keys, key)
+	}
+	object.members[key] = value
+}
+
+// insert add member at index, index out of range append member
+func (object *jsonObject) insert(index int, key string, value interface{}) {
+	if _, ok := object.members[key]; ok || index < 0 || index >= len(object.keys) {
+		object.set(key, value)
+		return
+	}
+	object.keys = append(object.keys[:index], append([]string{key}, object.keys[index:]...)...)
+	object.members[key] = value
+}
+
+func asJSONObject(value *interface{}) (*jsonObject, bool) {
+	if value == nil {
+		return nil, false
+	}
+	object, ok := (*value).(*jsonObject)
+	return object, ok
+}
+
+// unionKeys keys of objects in the order of the first object having them, objects may be nil
+func unionKeys(objects ...*jsonObject) []string {
+	exists := make(map[string]bool)
+	var keys []string
+	for _, object := range objects {
+		if object == nil {
+			continue
+		}
+		for _, key := range object.keys {
+			if !exists[key] {
+				exists[key] = true
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// jsonEqual compare decoded json values, member order of objects is ignored
+func jsonEqual(a, b interface{}) bool {
+	switch aValue := a.(type) {
+	case *jsonObject:
+		bValue, ok := b.(*jsonObject)
+		if !ok || len(aValue.members) != len(bValue.members) {
+			return false
+		}
+		for key, member := range aValue.members {
+			bMember, ok := bValue.members[key]
+			if !ok || !jsonEqual(member, bMember) {
+				return false
+			}
+		}
+		return true
+	case []interface{}:
+		bValue, ok := b.([]interface{})
+		if !ok || len(aValue) != len(bValue) {
+			return false
+		}
+		for index := range aValue {
+			if !jsonEqual(aValue[index], bValue[index]) {
+				return false
+			}
+		}
+		return true
+	}
+	return a == b
+}
+
+// decodeJSON decode json document keeping member order and numbers as they are written, nil content is decoded as nil
+func decodeJSON(content []byte) (*interface{}, error) {
+	if content == nil {
+		return nil, nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.UseNumber()
+	value, err := decodeJSONValue(decoder)
+	if err != nil {
+		return nil, err
+	}
+	if _, err = decoder.Token(); !errors.Is(err, io.EOF) {
+		return nil, errors.New("invalid json, data after top-level value")
+	}
+	return &value, nil
+}
+
+func decodeJSONValue(decoder *json.Decoder) (interface{}, error) {
+	token, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return token, nil
+	}
+
+	switch delim {
+	case '{':
+		object := newJSONObject()
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			member, err := decodeJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			object.set(keyToken.(string), member)
+		}
+		_, err = decoder.Token()
+		return object, err
+	case '[':
+		array := make([]interface{}, 0)
+		for decoder.More() {
+			element, err := decodeJSONValue(decoder)
+			if err != nil {
+				return nil, err
+			}
+			array = append(array, element)
+		}
+		_, err = decoder.Token()
+		return array, err
+	}
+	return nil, fmt.Errorf("unexpected json delimiter %s", delim)
+}
+
+func decodeJSONs(contents ...[]byte) ([]*interface{}, error) {
+	values := make([]*interface{}, len(contents))
+	for index, content := range contents {
+		value, err := decodeJSON(content)
+		if err != nil {
+			return nil, err
+		}
+		values[index] = value
+	}
+	return values, nil
+}
+
+// jsonLayout how document is written, empty indent means compact
+type jsonLayout struct {
+	indent  string
+	newline bool
+}
+
+// detectJSONLayout find indentation of the first indented line and whether document end with newline
+func detectJSONLayout(content []byte) jsonLayout {
+	layout := jsonLayout{newline: bytes.HasSuffix(content, []byte("\n"))}
+	trimmed := bytes.TrimSpace(content)
+	index := bytes.IndexByte(trimmed, '\n')
+	if index < 0 {
+		return layout
+	}
+	line := trimmed[index+1:]
+	layout.indent = string(line[:len(line)-len(bytes.TrimLeft(line, " \t"))])
+	if len(layout.indent) == 0 {
+		layout.indent = "  "
+	}
+	return layout
+}
+
+func encodeJSON(value *interface{}, layout jsonLayout) ([]byte, error) {
+	buf := bytes.NewBuffer(nil)
+	var document interface{}
+	if value != nil {
+		document = *value
+	}
+	err := writeJSON(buf, document, layout.indent, "")
+	if err != nil {
+		return nil, err
+	}
+	if layout.newline {
+		buf.WriteByte('\n')
+	}
+	return buf.Bytes(), nil
+}
+
+func writeJSON(buf *bytes.Buffer, value interface{}, indent, prefix string) error {
+	switch jsonValue := value.(type) {
+	case *jsonObject:
+		if len(jsonValue.keys) == 0 {
+			buf.WriteString("{}")
+			return nil
+		}
+		buf.WriteByte('{')
+		for index, key := range jsonValue.keys {
+			if index > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONNewline(buf, indent, prefix+indent)
+			err := writeJSON(buf, key, indent, prefix+indent)
+			if err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+			if len(indent) > 0 {
+				buf.WriteByte(' ')
+			}
+			err = writeJSON(buf, jsonValue.members[key], indent, prefix+indent)
+			if err != nil {
+				return err
+			}
+		}
+		writeJSONNewline(buf, indent, prefix)
+		buf.WriteByte('}')
+		return nil
+	case []interface{}:
+		if len(jsonValue) == 0 {
+			buf.WriteString("[]")
+			return nil
+		}
+		buf.WriteByte('[')
+		for index, element := range jsonValue {
+			if index > 0 {
+				buf.WriteByte(',')
+			}
+			writeJSONNewline(buf, indent, prefix+indent)
+			err := writeJSON(buf, element, indent, prefix+indent)
+			if err != nil {
+				return err
+			}
+		}
+		writeJSONNewline(buf, indent, prefix)
+		buf.WriteByte(']')
+		return nil
+	case json.Number:
+		buf.WriteString(jsonValue.String())
+		return nil
+	}
+
+	encoded := bytes.NewBuffer(nil)
+	encoder := json.NewEncoder(encoded)
+	encoder.SetEscapeHTML(false)
+	err := encoder.Encode(value)
+	if err != nil {
+		return err
+	}
+	buf.Write(bytes.TrimSuffix(encoded.Bytes(), []byte("\n")))
+	return nil
+}
+
+func writeJSONNewline(buf *bytes.Buffer, indent, prefix string) {
+	if len(indent) == 0 {
+		return
+	}
+	buf.WriteByte('\n')
+	buf.WriteString(prefix)
+}
+
+// escapeJSONPointer escape reference token of json pointer as RFC 6901
+func escapeJSONPointer(key string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(key, "~", "~0"), "/", "~1")
+}
+
+func displayJSONPointer(pointer string) string {
+	if len(pointer) == 0 {
+		return "document"
+	}
+	return pointer
+}
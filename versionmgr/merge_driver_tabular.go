@@ -0,0 +1,161 @@
+package versionmgr
+
+import (
+	"bytes"
+	"encoding/csv"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// tabularMergeDriver diff and merge csv files row by row, rows are identified by value of the first column. header must
+// be the same on both sides to merge. merged rows keep the order of ours, rows only theirs added are appended
+type tabularMergeDriver struct{}
+
+func (tabularMergeDriver) Name() string {
+	return "tabular"
+}
+
+func (tabularMergeDriver) Diff(from, to []byte) ([]ContentChange, error) {
+	fromTable, err := parseCSVTable(from)
+	if err != nil {
+		return nil, err
+	}
+	toTable, err := parseCSVTable(to)
+	if err != nil {
+		return nil, err
+	}
+
+	changes := make([]ContentChange, 0)
+	if !slices.Equal(fromTable.header, toTable.header) {
+		changes = append(changes, contentChange("header", fromTable.header != nil, toTable.header != nil, encodeCSVRecord(fromTable.header), encodeCSVRecord(toTable.header)))
+	}
+	for _, key := range fromTable.keys {
+		toRow, ok := toTable.rows[key]
+		switch {
+		case !ok:
+			changes = append(changes, ContentChange{Kind: ContentRemoved, Key: key, Old: encodeCSVRecord(fromTable.rows[key])})
+		case !slices.Equal(fromTable.rows[key], toRow):
+			changes = append(changes, ContentChange{Kind: ContentModified, Key: key, Old: encodeCSVRecord(fromTable.rows[key]), New: encodeCSVRecord(toRow)})
+		}
+	}
+	for _, key := range toTable.keys {
+		if _, ok := fromTable.rows[key]; !ok {
+			changes = append(changes, ContentChange{Kind: ContentAdded, Key: key, New: encodeCSVRecord(toTable.rows[key])})
+		}
+	}
+	return changes, nil
+}
+
+func (tabularMergeDriver) Merge(base, ours, theirs []byte) ([]byte, error) {
+	baseTable, err := parseCSVTable(base)
+	if err != nil {
+		return nil, err
+	}
+	oursTable, err := parseCSVTable(ours)
+	if err != nil {
+		return nil, err
+	}
+	theirsTable, err := parseCSVTable(theirs)
+	if err != nil {
+		return nil, err
+	}
+
+	if !slices.Equal(oursTable.header, theirsTable.header) || (base != nil && !slices.Equal(baseTable.header, oursTable.header)) {
+		return nil, ContentConflictError{Keys: []string{"header"}}
+	}
+
+	keys := slices.Clone(oursTable.keys)
+	for _, key := range theirsTable.keys {
+		if _, ok := oursTable.rows[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+
+	buf := bytes.NewBuffer(nil)
+	writer := csv.NewWriter(buf)
+	if oursTable.header != nil {
+		err = writer.Write(oursTable.header)
+		if err != nil {
+			return nil, err
+		}
+	}
+	var conflicts []string
+	for _, key := range keys {
+		row, ok := mergeThreeWay(baseTable.row(key), oursTable.row(key), theirsTable.row(key), slices.Equal[[]string])
+		if !ok {
+			conflicts = append(conflicts, key)
+			continue
+		}
+		if row == nil {
+			continue
+		}
+		err = writer.Write(*row)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(conflicts) > 0 {
+		return nil, ContentConflictError{Keys: conflicts}
+	}
+	writer.Flush()
+	if err = writer.Error(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// csvTable rows of csv file by key, the n-th row with a repeated key is keyed as key#n
+type csvTable struct {
+	header []string
+	keys   []string
+	rows   map[string][]string
+}
+
+func (table *csvTable) row(key string) *[]string {
+	row, ok := table.rows[key]
+	if !ok {
+		return nil
+	}
+	return &row
+}
+
+func parseCSVTable(content []byte) (*csvTable, error) {
+	table := &csvTable{rows: make(map[string][]string)}
+	reader := csv.NewReader(bytes.NewReader(content))
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return table, nil
+	}
+
+	table.header = records[0]
+	occurrences := make(map[string]int)
+	for _, record := range records[1:] {
+		key := ""
+		if len(record) > 0 {
+			key = record[0]
+		}
+		occurrences[key]++
+		if occurrences[key] > 1 {
+			key = key + "#" + strconv.Itoa(occurrences[key])
+		}
+		table.keys = append(table.keys, key)
+		table.rows[key] = record
+	}
+	return table, nil
+}
+
+func encodeCSVRecord(record []string) string {
+	if record == nil {
+		return ""
+	}
+	buf := bytes.NewBuffer(nil)
+	writer := csv.NewWriter(buf)
+	_ = writer.Write(record)
+	writer.Flush()
+	return strings.TrimSuffix(buf.String(), "\n")
+}
@@ -0,0 +1,263 @@
+package versionmgr
+
+import (
+	"context"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTabularMergeDriver(t *testing.T) {
+	driver := tabularMergeDriver{}
+	base := []byte("id,name\n1,a\n2,b\n3,c\n")
+	ours := []byte("id,name\n1,aa\n2,b\n3,c\n4,d\n")
+	theirs := []byte("id,name\n1,a\n3,c\n5,e\n")
+
+	t.Run("merge", func(t *testing.T) {
+		result, err := driver.Merge(base, ours, theirs)
+		require.NoError(t, err)
+		require.Equal(t, "id,name\n1,aa\n3,c\n4,d\n5,e\n", string(result))
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		_, err := driver.Merge(base, ours, []byte("id,name\n1,ab\n2,b\n3,c\n"))
+		var conflictErr ContentConflictError
+		require.ErrorAs(t, err, &conflictErr)
+		require.Equal(t, []string{"1"}, conflictErr.Keys)
+
+		_, err = driver.Merge(base, ours, []byte("id,name,age\n1,a,1\n"))
+		require.ErrorIs(t, err, ErrConflict)
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		changes, err := driver.Diff(base, ours)
+		require.NoError(t, err)
+		require.Equal(t, []ContentChange{
+			{Kind: ContentModified, Key: "1", Old: "1,a", New: "1,aa"},
+			{Kind: ContentAdded, Key: "4", New: "4,d"},
+		}, changes)
+
+		changes, err = driver.Diff(nil, []byte("id\n1\n1\n"))
+		require.NoError(t, err)
+		require.Equal(t, []ContentChange{
+			{Kind: ContentAdded, Key: "header", New: "id"},
+			{Kind: ContentAdded, Key: "1", New: "1"},
+			{Kind: ContentAdded, Key: "1#2", New: "1"},
+		}, changes)
+	})
+}
+
+func TestJSONMergeDriver(t *testing.T) {
+	driver := jsonMergeDriver{}
+	base := []byte(`{"name":"a","config":{"lr":0.1,"epochs":10},"tags":["x"]}`)
+	ours := []byte(`{"name":"a","config":{"lr":0.01,"epochs":10},"tags":["x"]}`)
+	theirs := []byte(`{"name":"a","config":{"lr":0.1,"epochs":20},"tags":["x","y"],"url":"a&b"}`)
+
+	t.Run("merge", func(t *testing.T) {
+		result, err := driver.Merge(base, ours, theirs)
+		require.NoError(t, err)
+		require.Equal(t, `{"name":"a","config":{"lr":0.01,"epochs":20},"tags":["x","y"],"url":"a&b"}`, string(result))
+
+		result, err = driver.Merge([]byte("{\n    \"b\": 1,\n    \"a\": {\"c\": 1}\n}\n"), []byte("{\n    \"b\": 2,\n    \"a\": {\"c\": 1}\n}\n"), []byte(`{"b":1,"a":{"c":1,"d":[]}}`))
+		require.NoError(t, err)
+		require.Equal(t, "{\n    \"b\": 2,\n    \"a\": {\n        \"c\": 1,\n        \"d\": []\n    }\n}\n", string(result))
+
+		result, err = driver.Merge(nil, []byte(`{"a":1}`), []byte(`{"b":2}`))
+		require.NoError(t, err)
+		require.JSONEq(t, `{"a":1,"b":2}`, string(result))
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		_, err := driver.Merge(base, ours, []byte(`{"name":"b","config":{"lr":0.2,"epochs":10},"tags":["x"]}`))
+		require.ErrorIs(t, err, ErrConflict)
+
+		_, err = driver.Merge(base, []byte(`{"name":"c","config":{"lr":0.01,"epochs":10},"tags":["z"]}`), []byte(`{"name":"b","config":{"lr":0.2,"epochs":10}}`))
+		var conflictErr ContentConflictError
+		require.ErrorAs(t, err, &conflictErr)
+		require.Equal(t, []string{"/name", "/config/lr", "/tags"}, conflictErr.Keys)
+
+		_, err = driver.Merge(base, ours, []byte(`[1]`))
+		require.ErrorAs(t, err, &conflictErr)
+		require.Equal(t, []string{"document"}, conflictErr.Keys)
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		changes, err := driver.Diff(base, theirs)
+		require.NoError(t, err)
+		require.Equal(t, []ContentChange{
+			{Kind: ContentModified, Key: "/config/epochs", Old: "10", New: "20"},
+			{Kind: ContentModified, Key: "/tags", Old: `["x"]`, New: `["x","y"]`},
+			{Kind: ContentAdded, Key: "/url", New: `"a&b"`},
+		}, changes)
+	})
+}
+
+func TestGeoJSONMergeDriver(t *testing.T) {
+	driver := geoJSONMergeDriver{}
+	base := []byte(`{"type":"FeatureCollection","features":[
+		{"type":"Feature","id":1,"geometry":{"type":"Point","coordinates":[0,0]},"properties":{"name":"a"}},
+		{"type":"Feature","id":2,"geometry":{"type":"Point","coordinates":[1,1]},"properties":{"name":"b"}}]}`)
+	ours := []byte(`{"type":"FeatureCollection","features":[
+		{"type":"Feature","id":1,"geometry":{"type":"Point","coordinates":[0,0]},"properties":{"name":"aa"}},
+		{"type":"Feature","id":2,"geometry":{"type":"Point","coordinates":[1,1]},"properties":{"name":"b"}}]}`)
+	theirs := []byte(`{"type":"FeatureCollection","name":"places","features":[
+		{"type":"Feature","id":1,"geometry":{"type":"Point","coordinates":[0,0]},"properties":{"name":"a"}},
+		{"type":"Feature","id":3,"geometry":{"type":"Point","coordinates":[2,2]},"properties":{"name":"c"}}]}`)
+
+	t.Run("merge", func(t *testing.T) {
+		result, err := driver.Merge(base, ours, theirs)
+		require.NoError(t, err)
+		require.JSONEq(t, `{"type":"FeatureCollection","name":"places","features":[
+			{"type":"Feature","id":1,"geometry":{"type":"Point","coordinates":[0,0]},"properties":{"name":"aa"}},
+			{"type":"Feature","id":3,"geometry":{"type":"Point","coordinates":[2,2]},"properties":{"name":"c"}}]}`, string(result))
+	})
+
+	t.Run("conflict", func(t *testing.T) {
+		_, err := driver.Merge(base, ours, []byte(`{"type":"FeatureCollection","features":[
+			{"type":"Feature","id":1,"geometry":{"type":"Point","coordinates":[5,5]},"properties":{"name":"a"}}]}`))
+		var conflictErr ContentConflictError
+		require.ErrorAs(t, err, &conflictErr)
+		require.Equal(t, []string{"/features/1"}, conflictErr.Keys)
+	})
+
+	t.Run("diff", func(t *testing.T) {
+		changes, err := driver.Diff(base, theirs)
+		require.NoError(t, err)
+		require.Len(t, changes, 3)
+		require.Equal(t, ContentChange{Kind: ContentAdded, Key: "/name", New: `"places"`}, changes[0])
+		require.Equal(t, ContentRemoved, changes[1].Kind)
+		require.Equal(t, "/features/2", changes[1].Key)
+		require.Equal(t, ContentAdded, changes[2].Kind)
+		require.Equal(t, "/features/3", changes[2].Key)
+	})
+}
+
+func TestMergeDriverRegistry(t *testing.T) {
+	ctx := context.Background()
+
+	for path, name := range map[string]string{"a/b.csv": "tabular", "c.json": "json", "d.geojson": "geojson"} {
+		driver, ok := FindMergeDriver(path)
+		require.True(t, ok)
+		require.Equal(t, name, driver.Name())
+	}
+	_, ok := FindMergeDriver("a.txt")
+	require.False(t, ok)
+
+	RegisterMergeDriver(jsonMergeDriver{}, DriverRule{PathPattern: "labels/*.txt"})
+	driver, ok := FindMergeDriver("labels/a.txt")
+	require.True(t, ok)
+	require.Equal(t, "json", driver.Name())
+	_, ok = FindMergeDriver("b/labels/a.txt")
+	require.False(t, ok)
+	require.Equal(t, []string{"geojson", "json", "tabular"}, MergeDrivers())
+
+	t.Run("strategy fallback", func(t *testing.T) {
+		objects := &memMergeObjects{contents: map[string][]byte{}}
+		base := objects.blob(t, "id\n1\n")
+		ours := objects.blob(t, "id\n1\n2\n")
+		theirs := objects.blob(t, "id\n1\n3\n")
+
+		strategy := WithMergeDrivers(mustMergeStrategy(t, RecursiveStrategy))
+		result, err := strategy.Resolve(ctx, objects, "a.csv", base, ours, theirs)
+		require.NoError(t, err)
+		require.Equal(t, "id\n1\n2\n3\n", string(objects.contents[result.Hash.Hex()]))
+
+		_, err = strategy.Resolve(ctx, objects, "a.txt", base, ours, theirs)
+		require.ErrorIs(t, err, ErrConflict)
+
+		_, err = strategy.Resolve(ctx, objects, "a.csv", base, ours, objects.blob(t, "id,name\n1,a\n"))
+		require.ErrorIs(t, err, ErrConflict)
+	})
+}
+
+func TestWorkRepositoryMergeWithDriver(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	project, err := makeRepository(ctx, repo, user, "testMergeDriver")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	commitFile := func(branch, path, content string) *models.Commit {
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, branch))
+		commit, err := workRepo.CommitOnBranch(ctx, "update "+path, func(root *WorkTree) error {
+			blob, err := workRepo.WriteBlob(ctx, strings.NewReader(content), int64(len(content)), models.DefaultLeafProperty())
+			if err != nil {
+				return err
+			}
+			err = root.ReplaceLeaf(ctx, path, blob)
+			if errors.Is(err, ErrPathNotFound) {
+				return root.AddLeaf(ctx, path, blob)
+			}
+			return err
+		})
+		require.NoError(t, err)
+		return commit
+	}
+	readFile := func(commit *models.Commit, path string) string {
+		workTree, err := NewWorkTree(ctx, repo.FileTreeRepo(project.ID), models.NewRootTreeEntry(commit.TreeHash))
+		require.NoError(t, err)
+		blob, _, err := workTree.FindBlob(ctx, path)
+		require.NoError(t, err)
+		reader, err := workRepo.ReadBlob(ctx, blob, nil)
+		require.NoError(t, err)
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	base := commitFile("main", "data.csv", "id,name\n1,a\n2,b\n")
+	commitFile("main", "a.txt", "a")
+	require.NoError(t, workRepo.CheckOut(ctx, InCommit, base.Hash.Hex()))
+	_, err = workRepo.CreateBranch(ctx, "feat")
+	require.NoError(t, err)
+	feat := commitFile("feat", "data.csv", "id,name\n1,a\n2,b\n3,c\n")
+	commitFile("feat", "a.txt", "b")
+	main := commitFile("main", "data.csv", "id,name\n1,aa\n2,b\n")
+
+	t.Run("merge state", func(t *testing.T) {
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, "feat"))
+		pairs, err := workRepo.GetMergeState(ctx, main.Hash)
+		require.NoError(t, err)
+		require.Len(t, pairs, 2)
+		require.Equal(t, "a.txt", pairs[0].Left.Path())
+		require.True(t, pairs[0].IsConflict)
+		require.Equal(t, "data.csv", pairs[1].Left.Path())
+		require.False(t, pairs[1].IsConflict)
+		require.Equal(t, "tabular", pairs[1].Driver)
+	})
+
+	t.Run("diff content", func(t *testing.T) {
+		require.NoError(t, workRepo.CheckOut(ctx, InCommit, base.Hash.Hex()))
+		contentDiff, err := workRepo.DiffContent(ctx, feat.Hash, "data.csv", nil)
+		require.NoError(t, err)
+		require.Equal(t, &ContentDiff{
+			Path:    "data.csv",
+			Driver:  "tabular",
+			Changes: []ContentChange{{Kind: ContentAdded, Key: "3", New: "3,c"}},
+		}, contentDiff)
+
+		_, err = workRepo.DiffContent(ctx, feat.Hash, "a.txt", nil)
+		require.ErrorIs(t, err, ErrMergeDriverNotFound)
+	})
+
+	t.Run("merge", func(t *testing.T) {
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+		commit, err := workRepo.Merge(ctx, feat.Hash, "merge feat", workRepo.StrategyResolver(ctx, WithMergeDrivers(mustMergeStrategy(t, OursStrategy))))
+		require.NoError(t, err)
+		require.Equal(t, "id,name\n1,aa\n2,b\n3,c\n", readFile(commit, "data.csv"))
+		require.Equal(t, "a", readFile(commit, "a.txt"))
+	})
+}
@@ -0,0 +1,273 @@
+package versionmgr
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"sync"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/versionmgr/merkletrie"
+	"github.com/GitDataAI/jiaozifs/versionmgr/merkletrie/noder"
+)
+
+const (
+	RecursiveStrategy = "recursive"
+	OursStrategy      = "ours"
+	TheirsStrategy    = "theirs"
+	UnionStrategy     = "union"
+
+	// maxUnionSize files larger than this are not merged by union strategy
+	maxUnionSize = 16 << 20
+	// binaryProbeSize bytes checked for NUL to detect binary file like git does
+	binaryProbeSize = 8000
+)
+
+var ErrMergeStrategyNotFound = errors.New("merge strategy not found")
+
+// MergeObjects read and write blob content for merge strategy, implemented by WorkRepository
+type MergeObjects interface {
+	ReadBlob(ctx context.Context, blob *models.Blob, rangeSpec *string) (io.ReadCloser, error)
+	WriteBlob(ctx context.Context, body io.Reader, contentLength int64, properties models.Property) (*models.Blob, error)
+}
+
+// MergeStrategy resolve a path changed on both sides of merge. base is the version in merge base, ours the version in
+// branch merged into and theirs the version being merged, nil if path not exist in that version. returning nil blob
+// delete path from merge result
+type MergeStrategy interface {
+	Name() string
+	Resolve(ctx context.Context, objects MergeObjects, path string, base, ours, theirs *models.Blob) (*models.Blob, error)
+}
+
+var (
+	mergeStrategiesLk sync.RWMutex
+	mergeStrategies   = map[string]MergeStrategy{}
+)
+
+func init() {
+	RegisterMergeStrategy(recursiveMergeStrategy{})
+	RegisterMergeStrategy(oneSideMergeStrategy{useOurs: true})
+	RegisterMergeStrategy(oneSideMergeStrategy{useOurs: false})
+	RegisterMergeStrategy(unionMergeStrategy{})
+}
+
+// RegisterMergeStrategy make strategy available by its name, strategy registered before with the same name is replaced
+func RegisterMergeStrategy(strategy MergeStrategy) {
+	mergeStrategiesLk.Lock()
+	defer mergeStrategiesLk.Unlock()
+	mergeStrategies[strategy.Name()] = strategy
+}
+
+// GetMergeStrategy find registered strategy by name
+func GetMergeStrategy(name string) (MergeStrategy, error) {
+	mergeStrategiesLk.RLock()
+	defer mergeStrategiesLk.RUnlock()
+	strategy, ok := mergeStrategies[name]
+	if !ok {
+		return nil, fmt.Errorf("%s %w", name, ErrMergeStrategyNotFound)
+	}
+	return strategy, nil
+}
+
+// MergeStrategies names of registered strategies
+func MergeStrategies() []string {
+	mergeStrategiesLk.RLock()
+	defer mergeStrategiesLk.RUnlock()
+	names := make([]string, 0, len(mergeStrategies))
+	for name := range mergeStrategies {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// recursiveMergeStrategy default strategy, merge base is found recursively over criss-cross ancestors and paths changed
+// differently on both sides are reported as conflict
+type recursiveMergeStrategy struct{}
+
+func (recursiveMergeStrategy) Name() string {
+	return RecursiveStrategy
+}
+
+func (recursiveMergeStrategy) Resolve(_ context.Context, _ MergeObjects, path string, _, _, _ *models.Blob) (*models.Blob, error) {
+	return nil, fmt.Errorf("path %s confilict %w", path, ErrConflict)
+}
+
+// oneSideMergeStrategy always take one side of conflict
+type oneSideMergeStrategy struct {
+	useOurs bool
+}
+
+func (s oneSideMergeStrategy) Name() string {
+	if s.useOurs {
+		return OursStrategy
+	}
+	return TheirsStrategy
+}
+
+func (s oneSideMergeStrategy) Resolve(_ context.Context, _ MergeObjects, _ string, _, ours, theirs *models.Blob) (*models.Blob, error) {
+	if s.useOurs {
+		return ours, nil
+	}
+	return theirs, nil
+}
+
+// unionMergeStrategy merge text files by keeping lines of ours and appending lines added by theirs, like union merge
+// driver of git. binary files, large files and paths deleted on one side are still conflict
+type unionMergeStrategy struct{}
+
+func (unionMergeStrategy) Name() string {
+	return UnionStrategy
+}
+
+func (unionMergeStrategy) Resolve(ctx context.Context, objects MergeObjects, path string, base, ours, theirs *models.Blob) (*models.Blob, error) {
+	if ours == nil || theirs == nil {
+		return nil, fmt.Errorf("path %s deleted on one side, union merge not apply %w", path, ErrConflict)
+	}
+
+	oursLines, err := readTextLines(ctx, objects, ours)
+	if err != nil {
+		return nil, fmt.Errorf("path %s %w", path, err)
+	}
+	theirsLines, err := readTextLines(ctx, objects, theirs)
+	if err != nil {
+		return nil, fmt.Errorf("path %s %w", path, err)
+	}
+	var baseLines []string
+	if base != nil {
+		baseLines, err = readTextLines(ctx, objects, base)
+		if err != nil {
+			return nil, fmt.Errorf("path %s %w", path, err)
+		}
+	}
+
+	exists := make(map[string]bool, len(baseLines)+len(oursLines))
+	for _, line := range baseLines {
+		exists[line] = true
+	}
+	buf := bytes.NewBuffer(nil)
+	for _, line := range oursLines {
+		exists[line] = true
+		buf.WriteString(line)
+	}
+	for _, line := range theirsLines {
+		if exists[line] {
+			continue
+		}
+		exists[line] = true
+		if buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+		buf.WriteString(line)
+	}
+
+	return objects.WriteBlob(ctx, bytes.NewReader(buf.Bytes()), int64(buf.Len()), ours.Properties)
+}
+
+// readTextLines read lines of blob with line endings kept, return conflict error if blob is binary or too large
+func readTextLines(ctx context.Context, objects MergeObjects, blob *models.Blob) ([]string, error) {
+	if blob.Size > maxUnionSize {
+		return nil, fmt.Errorf("file larger than %d bytes %w", maxUnionSize, ErrConflict)
+	}
+	reader, err := objects.ReadBlob(ctx, blob, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close() //nolint
+
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+	if bytes.IndexByte(data[:min(len(data), binaryProbeSize)], 0) >= 0 {
+		return nil, fmt.Errorf("binary file %w", ErrConflict)
+	}
+
+	var lines []string
+	bufReader := bufio.NewReader(bytes.NewReader(data))
+	for {
+		line, err := bufReader.ReadString('\n')
+		if len(line) > 0 {
+			lines = append(lines, line)
+		}
+		if err == io.EOF {
+			return lines, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+}
+
+var _ IChange = (*MergedChange)(nil)
+
+// MergedChange change whose result is produced by merge strategy, Blob is written to path instead of the content of
+// embedded change, nil Blob delete path
+type MergedChange struct {
+	IChange
+	Blob *models.Blob
+}
+
+// Action of merged change, path exist in merge base is modified or deleted otherwise inserted
+func (c *MergedChange) Action() (merkletrie.Action, error) {
+	switch {
+	case c.Blob == nil:
+		return merkletrie.Delete, nil
+	case c.IChange.From() == nil:
+		return merkletrie.Insert, nil
+	}
+	return merkletrie.Modify, nil
+}
+
+// StrategyResolver resolve conflicts of merge with strategy
+func (repository *WorkRepository) StrategyResolver(ctx context.Context, strategy MergeStrategy) ConflictResolver {
+	fileTreeRepo := repository.repo.FileTreeRepo(repository.repoModel.ID)
+	blobOf := func(path noder.Path) (*models.Blob, error) {
+		if path == nil {
+			return nil, nil
+		}
+		return fileTreeRepo.Blob(ctx, path.Hash())
+	}
+
+	// left change come from the commit being merged and right change from the branch merged into
+	return func(left IChange, right IChange) (IChange, error) {
+		base, err := blobOf(right.From())
+		if err != nil {
+			return nil, err
+		}
+		ours, err := blobOf(right.To())
+		if err != nil {
+			return nil, err
+		}
+		theirs, err := blobOf(left.To())
+		if err != nil {
+			return nil, err
+		}
+
+		result, err := strategy.Resolve(ctx, repository, right.Path(), base, ours, theirs)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case result == nil && ours == nil:
+			return right, nil
+		case result == nil && theirs == nil:
+			return left, nil
+		case result != nil && ours != nil && bytes.Equal(result.Hash, ours.Hash):
+			return right, nil
+		case result != nil && theirs != nil && bytes.Equal(result.Hash, theirs.Hash):
+			return left, nil
+		}
+
+		embedded := right
+		if ours == nil {
+			embedded = left
+		}
+		return &MergedChange{IChange: embedded, Blob: result}, nil
+	}
+}
@@ -0,0 +1,221 @@
+package versionmgr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/require"
+)
+
+// memMergeObjects keep blob content in memory
+type memMergeObjects struct {
+	contents map[string][]byte
+}
+
+func (objects *memMergeObjects) ReadBlob(_ context.Context, blob *models.Blob, _ *string) (io.ReadCloser, error) {
+	return io.NopCloser(bytes.NewReader(objects.contents[blob.Hash.Hex()])), nil
+}
+
+func (objects *memMergeObjects) WriteBlob(_ context.Context, body io.Reader, _ int64, properties models.Property) (*models.Blob, error) {
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return nil, err
+	}
+	hasher := hash.NewHasher(hash.Md5)
+	_, err = hasher.Write(data)
+	if err != nil {
+		return nil, err
+	}
+	blob, err := models.NewBlob(properties, uuid.Nil, hasher.Sum(hash.Md5), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+	objects.contents[blob.Hash.Hex()] = data
+	return blob, nil
+}
+
+func (objects *memMergeObjects) blob(t *testing.T, content string) *models.Blob {
+	blob, err := objects.WriteBlob(context.Background(), bytes.NewReader([]byte(content)), int64(len(content)), models.DefaultLeafProperty())
+	require.NoError(t, err)
+	return blob
+}
+
+func TestMergeStrategyResolve(t *testing.T) {
+	ctx := context.Background()
+	objects := &memMergeObjects{contents: map[string][]byte{}}
+	base := objects.blob(t, "a\nb\n")
+	ours := objects.blob(t, "a\nb\nc\n")
+	theirs := objects.blob(t, "b\nd")
+
+	t.Run("registered", func(t *testing.T) {
+		require.Equal(t, []string{RecursiveStrategy, OursStrategy, TheirsStrategy, UnionStrategy}, []string{
+			mustMergeStrategy(t, RecursiveStrategy).Name(),
+			mustMergeStrategy(t, OursStrategy).Name(),
+			mustMergeStrategy(t, TheirsStrategy).Name(),
+			mustMergeStrategy(t, UnionStrategy).Name(),
+		})
+		_, err := GetMergeStrategy("csv")
+		require.ErrorIs(t, err, ErrMergeStrategyNotFound)
+	})
+
+	t.Run("recursive", func(t *testing.T) {
+		_, err := mustMergeStrategy(t, RecursiveStrategy).Resolve(ctx, objects, "a.txt", base, ours, theirs)
+		require.ErrorIs(t, err, ErrConflict)
+	})
+
+	t.Run("ours and theirs", func(t *testing.T) {
+		result, err := mustMergeStrategy(t, OursStrategy).Resolve(ctx, objects, "a.txt", base, ours, theirs)
+		require.NoError(t, err)
+		require.Equal(t, ours, result)
+
+		result, err = mustMergeStrategy(t, TheirsStrategy).Resolve(ctx, objects, "a.txt", base, nil, theirs)
+		require.NoError(t, err)
+		require.Equal(t, theirs, result)
+
+		result, err = mustMergeStrategy(t, TheirsStrategy).Resolve(ctx, objects, "a.txt", base, ours, nil)
+		require.NoError(t, err)
+		require.Nil(t, result)
+	})
+
+	t.Run("union", func(t *testing.T) {
+		result, err := mustMergeStrategy(t, UnionStrategy).Resolve(ctx, objects, "a.txt", base, ours, theirs)
+		require.NoError(t, err)
+		require.Equal(t, "a\nb\nc\nd", string(objects.contents[result.Hash.Hex()]))
+
+		result, err = mustMergeStrategy(t, UnionStrategy).Resolve(ctx, objects, "a.txt", nil, ours, theirs)
+		require.NoError(t, err)
+		require.Equal(t, "a\nb\nc\nd", string(objects.contents[result.Hash.Hex()]))
+	})
+
+	t.Run("union conflict", func(t *testing.T) {
+		_, err := mustMergeStrategy(t, UnionStrategy).Resolve(ctx, objects, "a.txt", base, nil, theirs)
+		require.ErrorIs(t, err, ErrConflict)
+
+		binary := objects.blob(t, "a\x00b")
+		_, err = mustMergeStrategy(t, UnionStrategy).Resolve(ctx, objects, "a.bin", base, ours, binary)
+		require.ErrorIs(t, err, ErrConflict)
+	})
+
+	t.Run("custom", func(t *testing.T) {
+		RegisterMergeStrategy(concatMergeStrategy{})
+		strategy := mustMergeStrategy(t, "concat")
+		result, err := strategy.Resolve(ctx, objects, "a.csv", base, ours, theirs)
+		require.NoError(t, err)
+		require.Equal(t, "a\nb\nc\nb\nd", string(objects.contents[result.Hash.Hex()]))
+		require.Contains(t, MergeStrategies(), "concat")
+	})
+}
+
+func mustMergeStrategy(t *testing.T, name string) MergeStrategy {
+	strategy, err := GetMergeStrategy(name)
+	require.NoError(t, err)
+	return strategy
+}
+
+// concatMergeStrategy append theirs after ours
+type concatMergeStrategy struct{}
+
+func (concatMergeStrategy) Name() string {
+	return "concat"
+}
+
+func (concatMergeStrategy) Resolve(ctx context.Context, objects MergeObjects, _ string, _, ours, theirs *models.Blob) (*models.Blob, error) {
+	if ours == nil || theirs == nil {
+		return nil, errors.New("deleted")
+	}
+	buf := bytes.NewBuffer(nil)
+	for _, blob := range []*models.Blob{ours, theirs} {
+		reader, err := objects.ReadBlob(ctx, blob, nil)
+		if err != nil {
+			return nil, err
+		}
+		_, err = io.Copy(buf, reader)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return objects.WriteBlob(ctx, buf, int64(buf.Len()), ours.Properties)
+}
+
+func TestWorkRepositoryMergeWithStrategy(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	adapter := mem.New(ctx)
+	repo := models.NewRepo(db)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+
+	project, err := makeRepository(ctx, repo, user, "testMergeStrategy")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	writeFile := func(branch, content string) *models.Commit {
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, branch))
+		_, _, err := workRepo.GetOrCreateWip(ctx)
+		require.NoError(t, err)
+		require.NoError(t, workRepo.CheckOut(ctx, InWip, branch))
+		commit, err := workRepo.ChangeAndCommit(ctx, "change a.txt "+branch, func(workTree *WorkTree) error {
+			blob, err := workRepo.WriteBlob(ctx, bytes.NewReader([]byte(content)), int64(len(content)), models.DefaultLeafProperty())
+			if err != nil {
+				return err
+			}
+			_, _, err = workTree.FindBlob(ctx, "a.txt")
+			if errors.Is(err, ErrPathNotFound) {
+				return workTree.AddLeaf(ctx, "a.txt", blob)
+			}
+			return workTree.ReplaceLeaf(ctx, "a.txt", blob)
+		})
+		require.NoError(t, err)
+		return commit
+	}
+
+	readFile := func(commit *models.Commit) string {
+		workTree, err := NewWorkTree(ctx, repo.FileTreeRepo(project.ID), models.NewRootTreeEntry(commit.TreeHash))
+		require.NoError(t, err)
+		blob, _, err := workTree.FindBlob(ctx, "a.txt")
+		require.NoError(t, err)
+		reader, err := workRepo.ReadBlob(ctx, blob, nil)
+		require.NoError(t, err)
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	require.NoError(t, workRepo.CheckOut(ctx, InCommit, hash.Empty.Hex()))
+	_, err = workRepo.CreateBranch(ctx, "feat/base")
+	require.NoError(t, err)
+	base := writeFile("feat/base", "a\nb\n")
+
+	for _, branch := range []string{"feat/ours", "feat/theirs", "feat/conflict"} {
+		require.NoError(t, workRepo.CheckOut(ctx, InCommit, base.Hash.Hex()))
+		_, err = workRepo.CreateBranch(ctx, branch)
+		require.NoError(t, err)
+	}
+	writeFile("feat/ours", "a\nb\nc\n")
+	theirs := writeFile("feat/theirs", "a\nb\nd\n")
+	writeFile("feat/conflict", "a\nb\ne\n")
+
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "feat/ours"))
+	merged, err := workRepo.Merge(ctx, theirs.Hash, "union merge", workRepo.StrategyResolver(ctx, mustMergeStrategy(t, UnionStrategy)))
+	require.NoError(t, err)
+	require.Equal(t, "a\nb\nc\nd\n", readFile(merged))
+
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "feat/conflict"))
+	_, err = workRepo.Merge(ctx, theirs.Hash, "recursive merge", workRepo.StrategyResolver(ctx, mustMergeStrategy(t, RecursiveStrategy)))
+	require.ErrorIs(t, err, ErrConflict)
+
+	merged, err = workRepo.Merge(ctx, theirs.Hash, "theirs merge", workRepo.StrategyResolver(ctx, mustMergeStrategy(t, TheirsStrategy)))
+	require.NoError(t, err)
+	require.Equal(t, "a\nb\nd\n", readFile(merged))
+}
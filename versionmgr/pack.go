@@ -0,0 +1,232 @@
+package versionmgr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/utils/pathutil"
+	"github.com/google/uuid"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var packLog = logging.Logger("pack")
+
+const (
+	// DefaultPackThreshold blobs not larger than this are packed
+	DefaultPackThreshold = 64 << 10
+	// DefaultMaxPackSize a new pack is started once pack reach this size
+	DefaultMaxPackSize = 64 << 20
+)
+
+// PackOption options of packing small blobs
+type PackOption struct {
+	// Threshold blobs not larger than this are packed, DefaultPackThreshold if zero
+	Threshold int64
+	// MaxPackSize a new pack is started once pack reach this size, DefaultMaxPackSize if zero
+	MaxPackSize int64
+}
+
+// PackResult report of packing
+type PackResult struct {
+	Packs   int
+	Objects int
+	Bytes   int64
+}
+
+// Packer move content of small loose blobs into packs, so storage holds a few large files instead of millions of tiny
+// ones. packing is transparent to readers, ReadBlob find content in pack by index of packed objects
+type Packer struct {
+	repo      models.IRepo
+	repoModel *models.Repository
+	adapter   block.Adapter
+}
+
+func NewPacker(repo models.IRepo, repoModel *models.Repository, adapter block.Adapter) *Packer {
+	return &Packer{
+		repo:      repo,
+		repoModel: repoModel,
+		adapter:   adapter,
+	}
+}
+
+// Pack pack loose blobs not larger than threshold, a pack must hold at least two blobs. loose files are removed after
+// index of pack was saved, a failure to remove only leave some garbage in storage. storage shared with forks is not
+// packed, forks still read the loose files
+func (packer *Packer) Pack(ctx context.Context, opt PackOption) (*PackResult, error) {
+	shared, err := sharesStorage(ctx, packer.repo, packer.repoModel)
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		return &PackResult{}, nil
+	}
+	if opt.Threshold <= 0 {
+		opt.Threshold = DefaultPackThreshold
+	}
+	if opt.MaxPackSize <= 0 {
+		opt.MaxPackSize = DefaultMaxPackSize
+	}
+
+	objects, err := packer.repo.FileTreeRepo(packer.repoModel.ID).List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	packedObjects, err := packer.repo.PackRepo().ListObjects(ctx, packer.repoModel.ID)
+	if err != nil {
+		return nil, err
+	}
+	packed := make(map[string]struct{}, len(packedObjects))
+	for _, object := range packedObjects {
+		packed[object.CheckSum.Hex()] = struct{}{}
+	}
+
+	loose := make(map[string]*models.FileTree)
+	for i := range objects {
+		obj := &objects[i]
+		if obj.Type != models.BlobObject || obj.Size > opt.Threshold || obj.CheckSum.IsEmpty() {
+			continue
+		}
+		if _, ok := packed[obj.CheckSum.Hex()]; !ok {
+			loose[obj.CheckSum.Hex()] = obj
+		}
+	}
+	candidates := make([]*models.FileTree, 0, len(loose))
+	for _, obj := range loose {
+		candidates = append(candidates, obj)
+	}
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].CheckSum.Hex() < candidates[j].CheckSum.Hex()
+	})
+
+	result := &PackResult{}
+	for len(candidates) > 1 {
+		var count int
+		count, err = packer.writePack(ctx, candidates, opt.MaxPackSize, result)
+		if err != nil {
+			return nil, err
+		}
+		candidates = candidates[count:]
+	}
+	return result, nil
+}
+
+// writePack write blobs from the start of candidates into a pack until it reach maxPackSize, return number of
+// candidates consumed. blobs whose loose file is missing are skipped
+func (packer *Packer) writePack(ctx context.Context, candidates []*models.FileTree, maxPackSize int64, result *PackResult) (int, error) {
+	tempf, err := os.CreateTemp("", "pack-*")
+	if err != nil {
+		return 0, err
+	}
+	defer func() {
+		name := tempf.Name()
+		_ = tempf.Close()
+		_ = os.RemoveAll(name)
+	}()
+
+	hasher := hash.NewHasher(hash.Md5)
+	writer := io.MultiWriter(tempf, hasher.Md5)
+	var entries []*models.PackedObject
+	var offset int64
+	consumed := 0
+	for _, obj := range candidates {
+		if offset >= maxPackSize {
+			break
+		}
+		consumed++
+
+		reader, err := packer.adapter.Get(ctx, packer.pointer(pathutil.PathOfHash(obj.CheckSum)), obj.Size)
+		if err != nil {
+			packLog.Warnf("skip blob %s of repo %s, loose file not readable %v", obj.CheckSum.Hex(), packer.repoModel.ID, err)
+			continue
+		}
+		written, err := io.Copy(writer, reader)
+		_ = reader.Close()
+		if err != nil {
+			return 0, err
+		}
+		entries = append(entries, &models.PackedObject{
+			RepositoryID: packer.repoModel.ID,
+			CheckSum:     obj.CheckSum,
+			Offset:       offset,
+			Size:         written,
+			CreatedAt:    time.Now(),
+		})
+		offset += written
+	}
+	if len(entries) < 2 {
+		return consumed, nil
+	}
+
+	packCheckSum := hash.Hash(hasher.Md5.Sum(nil))
+	_, err = tempf.Seek(0, io.SeekStart)
+	if err != nil {
+		return 0, err
+	}
+	err = packer.adapter.Put(ctx, packer.pointer(pathutil.PathOfPack(packCheckSum)), offset, tempf, block.PutOpts{})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		entry.PackCheckSum = packCheckSum
+	}
+	err = packer.repo.PackRepo().Insert(ctx, &models.Pack{
+		RepositoryID: packer.repoModel.ID,
+		CheckSum:     packCheckSum,
+		Size:         offset,
+		Objects:      len(entries),
+		CreatedAt:    time.Now(),
+	}, entries)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, entry := range entries {
+		err = packer.adapter.Remove(ctx, packer.pointer(pathutil.PathOfHash(entry.CheckSum)))
+		if err != nil {
+			packLog.Errorf("remove loose blob %s of repo %s failed %v", entry.CheckSum.Hex(), packer.repoModel.ID, err)
+		}
+	}
+	result.Packs++
+	result.Objects += len(entries)
+	result.Bytes += offset
+	return consumed, nil
+}
+
+func (packer *Packer) pointer(address string) block.ObjectPointer {
+	return block.ObjectPointer{
+		StorageNamespace: utils.StringValue(packer.repoModel.StorageNamespace),
+		IdentifierType:   block.IdentifierTypeRelative,
+		Identifier:       address,
+	}
+}
+
+// findPacked return index of blob content in pack, nil if content is stored as loose file
+func findPacked(ctx context.Context, repo models.IRepo, repositoryID uuid.UUID, checkSum hash.Hash) (*models.PackedObject, error) {
+	object, err := repo.PackRepo().GetObject(ctx, repositoryID, checkSum)
+	if errors.Is(err, models.ErrNotFound) {
+		return nil, nil
+	}
+	return object, err
+}
+
+// readPacked read bytes from start to end of packed object, end is inclusive
+func readPacked(ctx context.Context, adapter block.Adapter, repoModel *models.Repository, object *models.PackedObject, start, end int64) (io.ReadCloser, error) {
+	if end < start {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+	return adapter.GetRange(ctx, block.ObjectPointer{
+		StorageNamespace: utils.StringValue(repoModel.StorageNamespace),
+		IdentifierType:   block.IdentifierTypeRelative,
+		Identifier:       pathutil.PathOfPack(object.PackCheckSum),
+	}, object.Offset+start, object.Offset+end)
+}
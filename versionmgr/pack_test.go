@@ -0,0 +1,130 @@
+package versionmgr
+
+import (
+	"context"
+	"crypto/md5" //nolint:gosec
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/utils/pathutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPacker(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	project, err := makeRepository(ctx, repo, user, "testPack")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	_, err = addChangesToWip(ctx, workRepo, "main", "base commit", `
+1|a.txt	|aaa
+1|b/c.txt	|bbbbb
+1|large.txt	|`+strings.Repeat("l", 20)+`
+`)
+	require.NoError(t, err)
+
+	err = workRepo.CheckOut(ctx, InBranch, "main")
+	require.NoError(t, err)
+	_, err = workRepo.CreateBranch(ctx, "feat")
+	require.NoError(t, err)
+	_, err = addChangesToWip(ctx, workRepo, "feat", "feat commit", `
+1|d.txt	|dd
+`)
+	require.NoError(t, err)
+
+	readFile := func(branch, path string, rangeSpec *string) string {
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, branch))
+		workTree, err := workRepo.RootTree(ctx)
+		require.NoError(t, err)
+		blob, _, err := workTree.FindBlob(ctx, path)
+		require.NoError(t, err)
+		reader, err := workRepo.ReadBlob(ctx, blob, rangeSpec)
+		require.NoError(t, err)
+		defer reader.Close() //nolint
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		return string(data)
+	}
+	looseExist := func(content string) bool {
+		checkSum := md5.Sum([]byte(content)) //nolint:gosec
+		_, err := adapter.Get(ctx, block.ObjectPointer{
+			StorageNamespace: utils.StringValue(project.StorageNamespace),
+			IdentifierType:   block.IdentifierTypeRelative,
+			Identifier:       pathutil.PathOfHash(hash.Hash(checkSum[:])),
+		}, 0)
+		return err == nil
+	}
+
+	t.Run("pack", func(t *testing.T) {
+		result, err := workRepo.Packer().Pack(ctx, PackOption{Threshold: 10})
+		require.NoError(t, err)
+		require.Equal(t, &PackResult{Packs: 1, Objects: 3, Bytes: 10}, result)
+		require.False(t, looseExist("aaa"))
+		require.True(t, looseExist(strings.Repeat("l", 20)))
+
+		result, err = workRepo.Packer().Pack(ctx, PackOption{Threshold: 10})
+		require.NoError(t, err)
+		require.Equal(t, &PackResult{}, result)
+	})
+
+	t.Run("read packed", func(t *testing.T) {
+		require.Equal(t, "aaa", readFile("main", "a.txt", nil))
+		require.Equal(t, "bbbbb", readFile("main", "b/c.txt", nil))
+		require.Equal(t, "bbb", readFile("main", "b/c.txt", utils.String("bytes=1-3")))
+		require.Equal(t, "dd", readFile("feat", "d.txt", nil))
+		require.Equal(t, strings.Repeat("l", 20), readFile("main", "large.txt", nil))
+	})
+
+	t.Run("write packed content", func(t *testing.T) {
+		_, err := workRepo.WriteBlob(ctx, strings.NewReader("aaa"), 3, models.DefaultLeafProperty())
+		require.NoError(t, err)
+		require.False(t, looseExist("aaa"))
+	})
+
+	t.Run("gc packed", func(t *testing.T) {
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, "feat"))
+		require.NoError(t, workRepo.DeleteBranch(ctx))
+
+		gc := workRepo.GarbageCollector()
+		result, err := gc.GC(ctx, GcOption{})
+		require.NoError(t, err)
+		require.Equal(t, 1, result.UnreachableBlobs)
+		require.Equal(t, 1, result.UnreachablePackedBlobs)
+		require.Equal(t, 0, result.ReclaimableFiles)
+
+		packs, err := repo.PackRepo().List(ctx, project.ID)
+		require.NoError(t, err)
+		require.Len(t, packs, 1)
+		require.Equal(t, "aaa", readFile("main", "a.txt", nil))
+
+		//pack is removed once none of its blobs is left
+		_, err = repo.BranchRepo().Delete(ctx, models.NewDeleteBranchParams().SetRepositoryID(project.ID).SetName("main"))
+		require.NoError(t, err)
+		_, err = repo.WipRepo().Delete(ctx, models.NewDeleteWipParams().SetRepositoryID(project.ID))
+		require.NoError(t, err)
+		result, err = gc.GC(ctx, GcOption{})
+		require.NoError(t, err)
+		require.Equal(t, 2, result.UnreachablePackedBlobs)
+		require.Equal(t, 2, result.ReclaimableFiles)
+		require.Equal(t, int64(30), result.ReclaimableBytes)
+
+		packs, err = repo.PackRepo().List(ctx, project.ID)
+		require.NoError(t, err)
+		require.Empty(t, packs)
+	})
+}
@@ -0,0 +1,200 @@
+package versionmgr
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+)
+
+// PartitionOp comparison of partition predicate
+type PartitionOp string
+
+const (
+	PartitionEq PartitionOp = "="
+	PartitionNe PartitionOp = "!="
+	PartitionGt PartitionOp = ">"
+	PartitionGe PartitionOp = ">="
+	PartitionLt PartitionOp = "<"
+	PartitionLe PartitionOp = "<="
+)
+
+// partitionOps longest first, so >= is not parsed as > followed by =
+var partitionOps = []PartitionOp{PartitionNe, PartitionGe, PartitionLe, PartitionEq, PartitionGt, PartitionLt}
+
+// PartitionPredicate condition on value of partition key. = matches any of values and != none of them, other ops
+// compare with the first value
+type PartitionPredicate struct {
+	Key    string
+	Op     PartitionOp
+	Values []string
+}
+
+// ParsePartitionPredicate parse predicate like year>=2023 or region=us|eu
+func ParsePartitionPredicate(expr string) (PartitionPredicate, error) {
+	for _, op := range partitionOps {
+		index := strings.Index(expr, string(op))
+		if index <= 0 {
+			continue
+		}
+		key, value := strings.TrimSpace(expr[:index]), strings.TrimSpace(expr[index+len(op):])
+		if len(key) == 0 || strings.ContainsAny(key, "!=<>") || len(value) == 0 {
+			break
+		}
+		values := []string{value}
+		if op == PartitionEq || op == PartitionNe {
+			values = strings.Split(value, "|")
+		}
+		return PartitionPredicate{Key: key, Op: op, Values: values}, nil
+	}
+	return PartitionPredicate{}, fmt.Errorf("invalid partition predicate %s", expr)
+}
+
+// Match whether partition value satisfies predicate, values are compared as numbers when both are numbers and as
+// strings otherwise, so zero padded dates like 2024-01-02 compare in order too
+func (predicate PartitionPredicate) Match(value string) bool {
+	switch predicate.Op {
+	case PartitionEq, PartitionNe:
+		for _, expect := range predicate.Values {
+			if comparePartitionValue(value, expect) == 0 {
+				return predicate.Op == PartitionEq
+			}
+		}
+		return predicate.Op == PartitionNe
+	case PartitionGt:
+		return comparePartitionValue(value, predicate.Values[0]) > 0
+	case PartitionGe:
+		return comparePartitionValue(value, predicate.Values[0]) >= 0
+	case PartitionLt:
+		return comparePartitionValue(value, predicate.Values[0]) < 0
+	case PartitionLe:
+		return comparePartitionValue(value, predicate.Values[0]) <= 0
+	}
+	return false
+}
+
+func comparePartitionValue(a, b string) int {
+	numA, errA := strconv.ParseFloat(a, 64)
+	numB, errB := strconv.ParseFloat(b, 64)
+	if errA == nil && errB == nil {
+		switch {
+		case numA < numB:
+			return -1
+		case numA > numB:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// Partition directory of hive partitioned data whose path segments are key=value
+type Partition struct {
+	Path   string
+	Values map[string]string
+}
+
+// PartitionListing partitions matching predicates under a path
+type PartitionListing struct {
+	// Keys partition keys found in order of depth, including keys of partitions pruned
+	Keys       []string
+	Partitions []Partition
+}
+
+// ListPartitions walk key=value directories under path in tree of commit and return partitions which satisfy all
+// predicates. directories failing a predicate are pruned without reading their subtree, predicates on keys missing
+// from a partition path can not prune it. a partition is a directory holding files or without deeper partitions
+func (repository *WorkRepository) ListPartitions(ctx context.Context, commitHash hash.Hash, basePath string, predicates []PartitionPredicate) (*PartitionListing, error) {
+	commit, err := repository.repo.CommitRepo(repository.repoModel.ID).Commit(ctx, commitHash)
+	if err != nil {
+		return nil, err
+	}
+	workTree, err := newWorkTree(ctx, repository.repo.FileTreeRepo(repository.repoModel.ID), repository.repoModel, commit.TreeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	basePath = CleanPath(basePath)
+	root, err := workTree.FindTree(ctx, basePath)
+	if err != nil {
+		return nil, err
+	}
+
+	keyPredicates := make(map[string][]PartitionPredicate)
+	for _, predicate := range predicates {
+		keyPredicates[predicate.Key] = append(keyPredicates[predicate.Key], predicate)
+	}
+
+	listing := &PartitionListing{Keys: make([]string, 0), Partitions: make([]Partition, 0)}
+	seenKeys := make(map[string]bool)
+	var walk func(node *TreeNode, dirPath string, values map[string]string) error
+	walk = func(node *TreeNode, dirPath string, values map[string]string) error {
+		hasFile, hasPartition := false, false
+		for _, entry := range node.SubObjects() {
+			if !entry.IsDir {
+				hasFile = true
+				continue
+			}
+			key, value, ok := parsePartitionSegment(entry.Name)
+			if !ok {
+				continue
+			}
+			hasPartition = true
+			if !seenKeys[key] {
+				seenKeys[key] = true
+				listing.Keys = append(listing.Keys, key)
+			}
+			if !matchPartitionPredicates(keyPredicates[key], value) {
+				continue
+			}
+
+			subNode, err := NewTreeNode(ctx, entry, workTree.object)
+			if err != nil {
+				return err
+			}
+			subValues := make(map[string]string, len(values)+1)
+			for k, v := range values {
+				subValues[k] = v
+			}
+			subValues[key] = value
+			err = walk(subNode, path.Join(dirPath, entry.Name), subValues)
+			if err != nil {
+				return err
+			}
+		}
+		if len(values) > 0 && (hasFile || !hasPartition) {
+			listing.Partitions = append(listing.Partitions, Partition{Path: dirPath, Values: values})
+		}
+		return nil
+	}
+	err = walk(root, basePath, map[string]string{})
+	if err != nil {
+		return nil, err
+	}
+	return listing, nil
+}
+
+// parsePartitionSegment split key=value directory name, value is unescaped like hive escapes special characters
+func parsePartitionSegment(name string) (string, string, bool) {
+	key, value, ok := strings.Cut(name, "=")
+	if !ok || len(key) == 0 {
+		return "", "", false
+	}
+	if unescaped, err := url.PathUnescape(value); err == nil {
+		value = unescaped
+	}
+	return key, value, true
+}
+
+func matchPartitionPredicates(predicates []PartitionPredicate, value string) bool {
+	for _, predicate := range predicates {
+		if !predicate.Match(value) {
+			return false
+		}
+	}
+	return true
+}
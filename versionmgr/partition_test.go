@@ -0,0 +1,114 @@
+package versionmgr
+
+import (
+	"context"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParsePartitionPredicate(t *testing.T) {
+	predicate, err := ParsePartitionPredicate("year>=2023")
+	require.NoError(t, err)
+	require.Equal(t, PartitionPredicate{Key: "year", Op: PartitionGe, Values: []string{"2023"}}, predicate)
+
+	predicate, err = ParsePartitionPredicate("region!=us|eu")
+	require.NoError(t, err)
+	require.Equal(t, PartitionPredicate{Key: "region", Op: PartitionNe, Values: []string{"us", "eu"}}, predicate)
+
+	predicate, err = ParsePartitionPredicate("month<3")
+	require.NoError(t, err)
+	require.True(t, predicate.Match("02"))
+	require.False(t, predicate.Match("10"))
+
+	for _, expr := range []string{"year", "=2023", "year=", "a<b=c"} {
+		_, err = ParsePartitionPredicate(expr)
+		require.Error(t, err, expr)
+	}
+}
+
+func TestListPartitions(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	project, err := makeRepository(ctx, repo, user, "partitions")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	commit, err := addChangesToWip(ctx, workRepo, "main", "partitioned", `
+1|events/year=2022/month=12/a.parquet	|a
+1|events/year=2023/month=1/b.parquet	|b
+1|events/year=2023/month=10/c.parquet	|c
+1|events/year=2024/month=2/region=us%2Feast/d.parquet	|d
+1|events/_SUCCESS	|
+1|readme.md	|readme
+`)
+	require.NoError(t, err)
+
+	parse := func(exprs ...string) []PartitionPredicate {
+		predicates := make([]PartitionPredicate, 0, len(exprs))
+		for _, expr := range exprs {
+			predicate, err := ParsePartitionPredicate(expr)
+			require.NoError(t, err)
+			predicates = append(predicates, predicate)
+		}
+		return predicates
+	}
+	paths := func(listing *PartitionListing) []string {
+		result := make([]string, 0, len(listing.Partitions))
+		for _, partition := range listing.Partitions {
+			result = append(result, partition.Path)
+		}
+		return result
+	}
+
+	t.Run("all partitions", func(t *testing.T) {
+		listing, err := workRepo.ListPartitions(ctx, commit.Hash, "events", nil)
+		require.NoError(t, err)
+		require.Equal(t, []string{"year", "month", "region"}, listing.Keys)
+		require.Equal(t, []string{
+			"events/year=2022/month=12",
+			"events/year=2023/month=1",
+			"events/year=2023/month=10",
+			"events/year=2024/month=2/region=us%2Feast",
+		}, paths(listing))
+		require.Equal(t, map[string]string{"year": "2024", "month": "2", "region": "us/east"}, listing.Partitions[3].Values)
+	})
+
+	t.Run("prune by predicates", func(t *testing.T) {
+		// month is compared as number, so 10 is not less than 2
+		listing, err := workRepo.ListPartitions(ctx, commit.Hash, "events", parse("year>=2023", "month<=2"))
+		require.NoError(t, err)
+		require.Equal(t, []string{"events/year=2023/month=1", "events/year=2024/month=2/region=us%2Feast"}, paths(listing))
+
+		// keys missing from partition path can not prune it
+		listing, err = workRepo.ListPartitions(ctx, commit.Hash, "events", parse("region=eu"))
+		require.NoError(t, err)
+		require.Len(t, listing.Partitions, 3)
+
+		listing, err = workRepo.ListPartitions(ctx, commit.Hash, "events", parse("year=2021|2025"))
+		require.NoError(t, err)
+		require.Empty(t, listing.Partitions)
+		require.Equal(t, []string{"year"}, listing.Keys)
+	})
+
+	t.Run("not partitioned", func(t *testing.T) {
+		listing, err := workRepo.ListPartitions(ctx, commit.Hash, "", nil)
+		require.NoError(t, err)
+		require.Empty(t, listing.Partitions)
+
+		_, err = workRepo.ListPartitions(ctx, commit.Hash, "missing", nil)
+		require.ErrorIs(t, err, ErrPathNotFound)
+		_, err = workRepo.ListPartitions(ctx, commit.Hash, "readme.md", nil)
+		require.ErrorIs(t, err, ErrNotDirectory)
+	})
+}
@@ -0,0 +1,181 @@
+package versionmgr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"github.com/gobwas/glob"
+
+	"github.com/GitDataAI/jiaozifs/models"
+)
+
+// PathOpType kind of operation on files matched by glob pattern
+type PathOpType string
+
+const (
+	PathOpDelete PathOpType = "delete"
+	PathOpCopy   PathOpType = "copy"
+	PathOpMove   PathOpType = "move"
+)
+
+// PathOp delete, copy or move files matched by Pattern. on copy and move, the static prefix directory of pattern is
+// replaced by Destination, for example pattern staging/** with destination prod copy staging/a/b.csv to prod/a/b.csv
+type PathOp struct {
+	Type        PathOpType
+	Pattern     string
+	Destination string
+	// IsReplace overwrite destination file which has different content, ErrObjectExist is returned otherwise
+	IsReplace bool
+}
+
+func (op PathOp) Validate() error {
+	switch op.Type {
+	case PathOpDelete:
+	case PathOpCopy, PathOpMove:
+		if len(CleanPath(op.Destination)) == 0 && len(patternBase(CleanPath(op.Pattern))) == 0 {
+			return errors.New("destination must be set")
+		}
+	default:
+		return fmt.Errorf("unknown operation type %s", op.Type)
+	}
+	if len(CleanPath(op.Pattern)) == 0 {
+		return errors.New("pattern must be set")
+	}
+	_, err := glob.Compile(CleanPath(op.Pattern))
+	return err
+}
+
+// ApplyPathOps apply operations in order on root, later operations see changes of former ones. number of files changed
+// by each operation is returned, files already same as destination are not counted
+func ApplyPathOps(ctx context.Context, root *WorkTree, ops []PathOp) ([]int, error) {
+	affected := make([]int, len(ops))
+	for index, op := range ops {
+		err := op.Validate()
+		if err != nil {
+			return nil, err
+		}
+
+		matched, err := matchBlobs(ctx, root, CleanPath(op.Pattern))
+		if err != nil {
+			return nil, err
+		}
+
+		base := patternBase(CleanPath(op.Pattern))
+		for _, file := range matched {
+			if op.Type == PathOpDelete {
+				err = root.RemoveEntry(ctx, file.path)
+				if err != nil {
+					return nil, err
+				}
+				affected[index]++
+				continue
+			}
+
+			destPath := CleanPath(path.Join(op.Destination, strings.TrimPrefix(file.path, base)))
+			if destPath == file.path {
+				continue
+			}
+			changed, err := copyBlob(ctx, root, destPath, file.blob, op.IsReplace)
+			if err != nil {
+				return nil, err
+			}
+			if op.Type == PathOpMove {
+				err = root.RemoveEntry(ctx, file.path)
+				if err != nil {
+					return nil, err
+				}
+				changed = true
+			}
+			if changed {
+				affected[index]++
+			}
+		}
+	}
+	return affected, nil
+}
+
+var errNothingChanged = errors.New("nothing changed by operations")
+
+// CommitPathOps apply operations on head of checked out branch and commit them at once, nil commit is returned if no file
+// changed
+func (repository *WorkRepository) CommitPathOps(ctx context.Context, msg string, ops []PathOp) (*models.Commit, []int, error) {
+	var affected []int
+	commit, err := repository.CommitOnBranch(ctx, msg, func(root *WorkTree) error {
+		var err error
+		affected, err = ApplyPathOps(ctx, root, ops)
+		if err != nil {
+			return err
+		}
+		for _, count := range affected {
+			if count > 0 {
+				return nil
+			}
+		}
+		return errNothingChanged
+	})
+	if errors.Is(err, errNothingChanged) {
+		return nil, affected, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	return commit, affected, nil
+}
+
+type matchedBlob struct {
+	path string
+	blob *models.Blob
+}
+
+// matchBlobs collect files before changing tree, so the walk is not affected by the operation
+func matchBlobs(ctx context.Context, root *WorkTree, pattern string) ([]matchedBlob, error) {
+	g, err := glob.Compile(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	var matched []matchedBlob
+	wk := FileWalk{curNode: root.root, object: root.object}
+	err = wk.Walk(ctx, func(entry *models.TreeEntry, blob *models.Blob, path string) error {
+		if !entry.IsDir && g.Match(path) {
+			matched = append(matched, matchedBlob{path: path, blob: blob})
+		}
+		return nil
+	})
+	return matched, err
+}
+
+func copyBlob(ctx context.Context, root *WorkTree, destPath string, blob *models.Blob, isReplace bool) (bool, error) {
+	oldBlob, _, err := root.FindBlob(ctx, destPath)
+	if err != nil && !errors.Is(err, ErrPathNotFound) {
+		return false, err
+	}
+	if oldBlob == nil {
+		return true, root.AddLeaf(ctx, destPath, blob)
+	}
+	if bytes.Equal(oldBlob.CheckSum, blob.CheckSum) {
+		return false, nil
+	}
+	if !isReplace {
+		return false, fmt.Errorf("path %s %w", destPath, ErrObjectExist)
+	}
+	return true, root.ReplaceLeaf(ctx, destPath, blob)
+}
+
+// patternBase return directory part of pattern before the first glob meta character
+func patternBase(pattern string) string {
+	index := strings.IndexAny(pattern, "*?[{\\")
+	if index < 0 {
+		// pattern without meta character match the file itself, its directory is base
+		index = len(pattern)
+	}
+	static := pattern[:index]
+	if index := strings.LastIndex(static, "/"); index >= 0 {
+		return static[:index]
+	}
+	return ""
+}
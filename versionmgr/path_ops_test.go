@@ -0,0 +1,93 @@
+package versionmgr
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitPathOps(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+
+	project, err := makeRepository(ctx, repo, user, "testPathOps")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+	_, err = workRepo.CommitOnBranch(ctx, "init", func(root *WorkTree) error {
+		for _, file := range []string{"staging/a.csv", "staging/sub/b.csv", "tmp/1.tmp", "tmp/2.tmp", "prod/a.csv"} {
+			blob, err := workRepo.WriteBlob(ctx, bytes.NewReader([]byte(file)), int64(len(file)), models.DefaultLeafProperty())
+			if err != nil {
+				return err
+			}
+			err = root.AddLeaf(ctx, file, blob)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	require.NoError(t, err)
+
+	lsFiles := func(t *testing.T, pattern string) []string {
+		workTree, err := workRepo.RootTree(ctx)
+		require.NoError(t, err)
+		manifest, err := workTree.GetTreeManifest(ctx, pattern)
+		require.NoError(t, err)
+		return manifest.FileList
+	}
+
+	t.Run("destination exist", func(t *testing.T) {
+		_, _, err := workRepo.CommitPathOps(ctx, "promote", []PathOp{
+			{Type: PathOpDelete, Pattern: "tmp/*"},
+			{Type: PathOpCopy, Pattern: "staging/**", Destination: "prod"},
+		})
+		require.ErrorIs(t, err, ErrObjectExist)
+		require.Len(t, lsFiles(t, "tmp/*"), 2)
+	})
+
+	t.Run("delete and move", func(t *testing.T) {
+		commit, affected, err := workRepo.CommitPathOps(ctx, "promote", []PathOp{
+			{Type: PathOpDelete, Pattern: "tmp/*"},
+			{Type: PathOpMove, Pattern: "staging/**", Destination: "prod", IsReplace: true},
+		})
+		require.NoError(t, err)
+		require.NotNil(t, commit)
+		require.Equal(t, []int{2, 2}, affected)
+		require.Equal(t, commit.Hash, workRepo.CurBranch().CommitHash)
+
+		require.Empty(t, lsFiles(t, "tmp/*"))
+		require.Empty(t, lsFiles(t, "staging/**"))
+		require.ElementsMatch(t, []string{"prod/a.csv", "prod/sub/b.csv"}, lsFiles(t, "prod/**"))
+	})
+
+	t.Run("nothing changed", func(t *testing.T) {
+		head := workRepo.CurBranch().CommitHash
+		commit, affected, err := workRepo.CommitPathOps(ctx, "purge", []PathOp{{Type: PathOpDelete, Pattern: "tmp/*"}})
+		require.NoError(t, err)
+		require.Nil(t, commit)
+		require.Equal(t, []int{0}, affected)
+		require.Equal(t, head, workRepo.CurBranch().CommitHash)
+	})
+
+	t.Run("invalid op", func(t *testing.T) {
+		require.Error(t, PathOp{Type: PathOpCopy, Pattern: "*.csv"}.Validate())
+		require.Error(t, PathOp{Type: "rename", Pattern: "*.csv"}.Validate())
+		require.Error(t, PathOp{Type: PathOpDelete, Pattern: "a/[b"}.Validate())
+		require.NoError(t, PathOp{Type: PathOpCopy, Pattern: "a/*.csv", Destination: "b"}.Validate())
+		require.Equal(t, "staging/sub", patternBase("staging/sub/b.csv"))
+	})
+}
@@ -0,0 +1,93 @@
+package versionmgr
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/GitDataAI/jiaozifs/models"
+)
+
+// DefaultQuotaWarningThresholds percentages of storage quota at which a warning is recorded when none is set
+var DefaultQuotaWarningThresholds = []int{80, 90}
+
+// SetQuotaWarningThresholds percentages of storage quota at which a warning is recorded, thresholds out of (0, 100]
+// are ignored
+func (repository *WorkRepository) SetQuotaWarningThresholds(thresholds []int) *WorkRepository {
+	repository.quotaThresholds = thresholds
+	return repository
+}
+
+// checkQuota reject writing size more bytes when repository would go over its storage quota
+func (repository *WorkRepository) checkQuota(ctx context.Context, size int64) error {
+	quota := repository.repoModel.StorageQuota
+	if quota <= 0 {
+		return nil
+	}
+	stats, err := repository.repo.RepositoryRepo().GetStats(ctx, repository.repoModel.ID)
+	if err != nil {
+		return err
+	}
+	if stats.BlobBytes+size > quota {
+		return fmt.Errorf("write %d bytes with %d of %d bytes used %w", size, stats.BlobBytes, quota, models.ErrQuotaExceeded)
+	}
+	return nil
+}
+
+// updateQuotaWarning record models.RepositoryQuotaWarningEvent when usage crossed a threshold higher than the one
+// warned last, so consumers of events can notify admins before writes start failing. warning is lowered silently when
+// usage dropped, a later crossing is warned again
+func (repository *WorkRepository) updateQuotaWarning(ctx context.Context, repo models.IRepo) error {
+	quota := repository.repoModel.StorageQuota
+	if quota <= 0 && repository.repoModel.QuotaWarning == 0 {
+		return nil
+	}
+
+	percent := 0
+	stats, err := repo.RepositoryRepo().GetStats(ctx, repository.repoModel.ID)
+	if err != nil {
+		return err
+	}
+	if quota > 0 {
+		used := stats.BlobBytes * 100 / quota
+		for _, threshold := range repository.warningThresholds() {
+			if int64(threshold) <= used {
+				percent = threshold
+			}
+		}
+	}
+	if percent == repository.repoModel.QuotaWarning {
+		return nil
+	}
+
+	err = repo.RepositoryRepo().UpdateQuotaWarning(ctx, repository.repoModel.ID, percent)
+	if err != nil {
+		return err
+	}
+	raised := percent > repository.repoModel.QuotaWarning
+	repository.repoModel.QuotaWarning = percent
+	if !raised {
+		return nil
+	}
+	return repository.recordEvent(ctx, repo, models.RepositoryQuotaWarningEvent, map[string]string{
+		"threshold": strconv.Itoa(percent),
+		"used":      strconv.FormatInt(stats.BlobBytes, 10),
+		"quota":     strconv.FormatInt(quota, 10),
+	})
+}
+
+func (repository *WorkRepository) warningThresholds() []int {
+	thresholds := repository.quotaThresholds
+	if len(thresholds) == 0 {
+		thresholds = DefaultQuotaWarningThresholds
+	}
+	valid := make([]int, 0, len(thresholds))
+	for _, threshold := range thresholds {
+		if threshold > 0 && threshold <= 100 {
+			valid = append(valid, threshold)
+		}
+	}
+	sort.Ints(valid)
+	return valid
+}
@@ -0,0 +1,87 @@
+package versionmgr
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaWarning(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	project, err := makeRepository(ctx, repo, user, "quota")
+	require.NoError(t, err)
+	require.NoError(t, repo.RepositoryRepo().UpdateByID(ctx, models.NewUpdateRepoParams(project.ID).SetStorageQuota(10)))
+	project, err = repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetID(project.ID))
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter).SetQuotaWarningThresholds([]int{90, 50})
+	quotaWarnings := func() []*models.Event {
+		events, _, err := repo.EventRepo().List(ctx, models.NewListEventParams().SetRepositoryID(project.ID))
+		require.NoError(t, err)
+		var warnings []*models.Event
+		for _, event := range events {
+			if event.Type == models.RepositoryQuotaWarningEvent {
+				warnings = append(warnings, event)
+			}
+		}
+		return warnings
+	}
+	quotaWarning := func() int {
+		repository, err := repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetID(project.ID))
+		require.NoError(t, err)
+		return repository.QuotaWarning
+	}
+
+	t.Run("below thresholds", func(t *testing.T) {
+		_, err := addChangesToWip(ctx, workRepo, "main", "first", `
+1|a.txt	|aaa
+`)
+		require.NoError(t, err)
+		require.Equal(t, 0, quotaWarning())
+		require.Empty(t, quotaWarnings())
+	})
+
+	t.Run("cross thresholds", func(t *testing.T) {
+		_, err := addChangesToWip(ctx, workRepo, "main", "second", `
+1|b.txt	|bb
+`)
+		require.NoError(t, err)
+		require.Equal(t, 50, quotaWarning())
+		require.Len(t, quotaWarnings(), 1)
+
+		// warned once per crossing
+		_, err = addChangesToWip(ctx, workRepo, "main", "third", `
+1|c.txt	|c
+`)
+		require.NoError(t, err)
+		require.Len(t, quotaWarnings(), 1)
+
+		_, err = addChangesToWip(ctx, workRepo, "main", "fourth", `
+1|d.txt	|ddd
+`)
+		require.NoError(t, err)
+		require.Equal(t, 90, quotaWarning())
+		warnings := quotaWarnings()
+		require.Len(t, warnings, 2)
+		require.Equal(t, "90", warnings[1].Payload["threshold"])
+		require.Equal(t, "10", warnings[1].Payload["quota"])
+	})
+
+	t.Run("reject writes over quota", func(t *testing.T) {
+		_, err := workRepo.WriteBlob(ctx, strings.NewReader("eeee"), 4, models.DefaultLeafProperty())
+		require.ErrorIs(t, err, models.ErrQuotaExceeded)
+	})
+}
@@ -0,0 +1,397 @@
+package versionmgr
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/block/params"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/utils/pathutil"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var repackLog = logging.Logger("repack")
+
+const (
+	// DefaultRepackLiveRatio packs whose live content is below this ratio of pack size are rewritten
+	DefaultRepackLiveRatio = 0.5
+	// DefaultSmallPackSize packs smaller than this are merged together
+	DefaultSmallPackSize = 4 << 20
+	// DefaultRepackInterval how often packs of all repositories are compacted
+	DefaultRepackInterval = 6 * time.Hour
+)
+
+// RepackOption options of consolidating packs
+type RepackOption struct {
+	// MinLiveRatio packs whose live content is below this ratio of pack size are rewritten, DefaultRepackLiveRatio if zero
+	MinLiveRatio float64
+	// SmallPackSize packs smaller than this are merged together, DefaultSmallPackSize if zero
+	SmallPackSize int64
+	// MaxPackSize packs are merged until new pack reach this size, DefaultMaxPackSize if zero
+	MaxPackSize int64
+	// BytesPerSecond limit of bytes read from storage, zero means unlimited
+	BytesPerSecond int64
+	// Progress called after each new pack was saved
+	Progress func(RepackProgress)
+}
+
+// RepackProgress progress of a running repack
+type RepackProgress struct {
+	// Packs number of packs selected to rewrite
+	Packs     int
+	DonePacks int
+	// Bytes live bytes of selected packs to copy
+	Bytes     int64
+	DoneBytes int64
+}
+
+// RepackResult report of repacking
+type RepackResult struct {
+	// SupersededPacks packs rewritten and removed
+	SupersededPacks int
+	// Packs new packs written
+	Packs   int
+	Objects int
+	Bytes   int64
+	// ReclaimedBytes size of superseded packs minus size of new packs
+	ReclaimedBytes int64
+}
+
+// Repack consolidate fragmented packs. packs left mostly dead by gc and small packs are rewritten into new packs holding
+// only live content, index is moved to the new pack in one transaction and superseded packs are removed afterwards.
+// content is stored as is, there is no delta encoding between blobs to rewrite. storage shared with forks is not
+// repacked, forks still read the superseded packs
+func (packer *Packer) Repack(ctx context.Context, opt RepackOption) (*RepackResult, error) {
+	shared, err := sharesStorage(ctx, packer.repo, packer.repoModel)
+	if err != nil {
+		return nil, err
+	}
+	if shared {
+		return &RepackResult{}, nil
+	}
+	if opt.MinLiveRatio <= 0 {
+		opt.MinLiveRatio = DefaultRepackLiveRatio
+	}
+	if opt.SmallPackSize <= 0 {
+		opt.SmallPackSize = DefaultSmallPackSize
+	}
+	if opt.MaxPackSize <= 0 {
+		opt.MaxPackSize = DefaultMaxPackSize
+	}
+
+	packs, err := packer.repo.PackRepo().List(ctx, packer.repoModel.ID)
+	if err != nil {
+		return nil, err
+	}
+	packedObjects, err := packer.repo.PackRepo().ListObjects(ctx, packer.repoModel.ID)
+	if err != nil {
+		return nil, err
+	}
+	liveObjects := make(map[string][]*models.PackedObject, len(packs))
+	liveBytes := make(map[string]int64, len(packs))
+	for _, object := range packedObjects {
+		liveObjects[object.PackCheckSum.Hex()] = append(liveObjects[object.PackCheckSum.Hex()], object)
+		liveBytes[object.PackCheckSum.Hex()] += object.Size
+	}
+
+	progress := RepackProgress{}
+	fragmented := make(map[string]bool)
+	var selected []*models.Pack
+	for _, pack := range packs {
+		packHex := pack.CheckSum.Hex()
+		if len(liveObjects[packHex]) == 0 {
+			// pack without live content is removed by gc once grace period passed
+			continue
+		}
+		if float64(liveBytes[packHex]) < opt.MinLiveRatio*float64(pack.Size) {
+			fragmented[packHex] = true
+		} else if pack.Size >= opt.SmallPackSize {
+			continue
+		}
+		selected = append(selected, pack)
+		progress.Packs++
+		progress.Bytes += liveBytes[packHex]
+	}
+
+	result := &RepackResult{}
+	throttle := newByteThrottle(opt.BytesPerSecond)
+	for len(selected) > 0 {
+		count, size := 1, liveBytes[selected[0].CheckSum.Hex()]
+		for count < len(selected) && size+liveBytes[selected[count].CheckSum.Hex()] <= opt.MaxPackSize {
+			size += liveBytes[selected[count].CheckSum.Hex()]
+			count++
+		}
+		batch := selected[:count]
+		selected = selected[count:]
+
+		// a lone small pack has nothing to be merged with
+		if len(batch) > 1 || fragmented[batch[0].CheckSum.Hex()] {
+			err = packer.rewritePacks(ctx, batch, liveObjects, throttle, result)
+			if err != nil {
+				return nil, err
+			}
+		}
+		progress.DonePacks += len(batch)
+		progress.DoneBytes += size
+		if opt.Progress != nil {
+			opt.Progress(progress)
+		}
+	}
+	return result, nil
+}
+
+// rewritePacks copy live objects of packs into one new pack, then move index onto it and remove the old packs
+func (packer *Packer) rewritePacks(ctx context.Context, batch []*models.Pack, liveObjects map[string][]*models.PackedObject, throttle *byteThrottle, result *RepackResult) error {
+	tempf, err := os.CreateTemp("", "repack-*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		name := tempf.Name()
+		_ = tempf.Close()
+		_ = os.RemoveAll(name)
+	}()
+
+	hasher := hash.NewHasher(hash.Md5)
+	writer := io.MultiWriter(tempf, hasher.Md5)
+	var entries []*models.PackedObject
+	var offset, supersededBytes int64
+	superseded := make([]hash.Hash, 0, len(batch))
+	for _, pack := range batch {
+		objects := liveObjects[pack.CheckSum.Hex()]
+		sort.Slice(objects, func(i, j int) bool {
+			return objects[i].Offset < objects[j].Offset
+		})
+
+		reader, err := packer.adapter.Get(ctx, packer.pointer(pathutil.PathOfPack(pack.CheckSum)), pack.Size)
+		if err != nil {
+			return err
+		}
+		throttled := &throttledReader{ctx: ctx, reader: reader, throttle: throttle}
+		var position int64
+		for _, object := range objects {
+			_, err = io.CopyN(io.Discard, throttled, object.Offset-position)
+			if err != nil {
+				break
+			}
+			_, err = io.CopyN(writer, throttled, object.Size)
+			if err != nil {
+				break
+			}
+			position = object.Offset + object.Size
+			entries = append(entries, &models.PackedObject{
+				RepositoryID: packer.repoModel.ID,
+				CheckSum:     object.CheckSum,
+				Offset:       offset,
+				Size:         object.Size,
+				CreatedAt:    object.CreatedAt,
+			})
+			offset += object.Size
+		}
+		_ = reader.Close()
+		if err != nil {
+			return err
+		}
+		superseded = append(superseded, pack.CheckSum)
+		supersededBytes += pack.Size
+	}
+
+	packCheckSum := hash.Hash(hasher.Md5.Sum(nil))
+	for _, checkSum := range superseded {
+		if bytes.Equal(checkSum, packCheckSum) {
+			// content is unchanged, nothing to rewrite
+			return nil
+		}
+	}
+	_, err = tempf.Seek(0, io.SeekStart)
+	if err != nil {
+		return err
+	}
+	err = packer.adapter.Put(ctx, packer.pointer(pathutil.PathOfPack(packCheckSum)), offset, tempf, block.PutOpts{})
+	if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		entry.PackCheckSum = packCheckSum
+	}
+	err = packer.repo.Transaction(ctx, func(repo models.IRepo) error {
+		return repo.PackRepo().Replace(ctx, &models.Pack{
+			RepositoryID: packer.repoModel.ID,
+			CheckSum:     packCheckSum,
+			Size:         offset,
+			Objects:      len(entries),
+			CreatedAt:    time.Now(),
+		}, entries, superseded)
+	})
+	if err != nil {
+		return err
+	}
+
+	// remove data after index was moved, a failure here only leave some garbage in storage
+	for _, checkSum := range superseded {
+		err = packer.adapter.Remove(ctx, packer.pointer(pathutil.PathOfPack(checkSum)))
+		if err != nil {
+			repackLog.Errorf("remove pack %s of repo %s failed %v", checkSum.Hex(), packer.repoModel.ID, err)
+		}
+	}
+	result.SupersededPacks += len(superseded)
+	result.Packs++
+	result.Objects += len(entries)
+	result.Bytes += offset
+	result.ReclaimedBytes += supersededBytes - offset
+	return nil
+}
+
+// byteThrottle keep bytes read at a steady rate of bytes per second, zero rate means unlimited
+type byteThrottle struct {
+	rate  int64
+	start time.Time
+	bytes int64
+}
+
+func newByteThrottle(rate int64) *byteThrottle {
+	return &byteThrottle{rate: rate, start: time.Now()}
+}
+
+// wait account n bytes read and sleep until reading them is within rate
+func (throttle *byteThrottle) wait(ctx context.Context, n int) error {
+	if throttle.rate <= 0 {
+		return nil
+	}
+	throttle.bytes += int64(n)
+	expect := time.Duration(float64(throttle.bytes) / float64(throttle.rate) * float64(time.Second))
+	delay := expect - time.Since(throttle.start)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
+
+type throttledReader struct {
+	ctx      context.Context
+	reader   io.Reader
+	throttle *byteThrottle
+}
+
+func (reader *throttledReader) Read(p []byte) (int, error) {
+	n, err := reader.reader.Read(p)
+	if n > 0 {
+		if waitErr := reader.throttle.wait(reader.ctx, n); waitErr != nil {
+			return n, waitErr
+		}
+	}
+	return n, err
+}
+
+// Repacker consolidate fragmented packs of all repositories periodically
+type Repacker struct {
+	repo                models.IRepo
+	publicAdapterConfig params.AdapterConfig
+	interval            time.Duration
+	bytesPerSecond      int64
+	buildAdapter        func(ctx context.Context, repoModel *models.Repository) (block.Adapter, error)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewRepacker(repo models.IRepo, publicAdapterConfig params.AdapterConfig, interval time.Duration, bytesPerSecond int64) *Repacker {
+	if interval <= 0 {
+		interval = DefaultRepackInterval
+	}
+	repacker := &Repacker{
+		repo:                repo,
+		publicAdapterConfig: publicAdapterConfig,
+		interval:            interval,
+		bytesPerSecond:      bytesPerSecond,
+	}
+	repacker.buildAdapter = func(ctx context.Context, repoModel *models.Repository) (block.Adapter, error) {
+		return adapterOf(ctx, repoModel, repacker.publicAdapterConfig)
+	}
+	return repacker
+}
+
+// Start repack all repositories every interval in background until Stop called
+func (repacker *Repacker) Start(ctx context.Context) {
+	ctx, repacker.cancel = context.WithCancel(ctx)
+	repacker.done = make(chan struct{})
+	go func() {
+		defer close(repacker.done)
+		ticker := time.NewTicker(repacker.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				result, err := repacker.RepackAll(ctx)
+				if err != nil {
+					repackLog.Errorf("repack repositories %v", err)
+				}
+				if result != nil && result.SupersededPacks > 0 {
+					repackLog.Infof("repack %d packs into %d, reclaim %d bytes", result.SupersededPacks, result.Packs, result.ReclaimedBytes)
+				}
+			}
+		}
+	}()
+}
+
+// Stop background repacking and wait for the running round
+func (repacker *Repacker) Stop() {
+	if repacker.cancel == nil {
+		return
+	}
+	repacker.cancel()
+	<-repacker.done
+}
+
+// RepackAll repack every repository once, repository failed to repack is logged and retried in next round
+func (repacker *Repacker) RepackAll(ctx context.Context) (*RepackResult, error) {
+	repositories, _, err := repacker.repo.RepositoryRepo().List(ctx, models.NewListRepoParams())
+	if err != nil {
+		return nil, err
+	}
+
+	total := &RepackResult{}
+	for _, repoModel := range repositories {
+		if ctx.Err() != nil {
+			return total, ctx.Err()
+		}
+		adapter, err := repacker.buildAdapter(ctx, repoModel)
+		if err != nil {
+			repackLog.Warnf("build adapter of repository %s %v", repoModel.Name, err)
+			continue
+		}
+
+		result, err := NewPacker(repacker.repo, repoModel, adapter).Repack(ctx, RepackOption{
+			BytesPerSecond: repacker.bytesPerSecond,
+			Progress: func(progress RepackProgress) {
+				repackLog.Infof("repack repository %s packs %d/%d bytes %d/%d", repoModel.Name, progress.DonePacks, progress.Packs, progress.DoneBytes, progress.Bytes)
+			},
+		})
+		if err != nil {
+			repackLog.Warnf("repack repository %s %v", repoModel.Name, err)
+			continue
+		}
+		total.SupersededPacks += result.SupersededPacks
+		total.Packs += result.Packs
+		total.Objects += result.Objects
+		total.Bytes += result.Bytes
+		total.ReclaimedBytes += result.ReclaimedBytes
+	}
+	return total, nil
+}
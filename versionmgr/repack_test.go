@@ -0,0 +1,99 @@
+package versionmgr
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepack(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	project, err := makeRepository(ctx, repo, user, "testRepack")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	_, err = addChangesToWip(ctx, workRepo, "main", "base commit", `
+1|a.txt	|aaa
+1|b.txt	|bbbbb
+`)
+	require.NoError(t, err)
+	_, err = workRepo.Packer().Pack(ctx, PackOption{})
+	require.NoError(t, err)
+
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+	_, err = workRepo.CreateBranch(ctx, "feat")
+	require.NoError(t, err)
+	_, err = addChangesToWip(ctx, workRepo, "feat", "feat commit", `
+1|c.txt	|cc
+1|d.txt	|dddddddddddddddddddd
+`)
+	require.NoError(t, err)
+	_, err = workRepo.Packer().Pack(ctx, PackOption{})
+	require.NoError(t, err)
+
+	readFile := func(branch, path string) string {
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, branch))
+		workTree, err := workRepo.RootTree(ctx)
+		require.NoError(t, err)
+		blob, _, err := workTree.FindBlob(ctx, path)
+		require.NoError(t, err)
+		reader, err := workRepo.ReadBlob(ctx, blob, nil)
+		require.NoError(t, err)
+		defer reader.Close() //nolint
+		data, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		return string(data)
+	}
+
+	t.Run("merge small packs", func(t *testing.T) {
+		var progress []RepackProgress
+		result, err := workRepo.Packer().Repack(ctx, RepackOption{
+			BytesPerSecond: 1 << 20,
+			Progress: func(p RepackProgress) {
+				progress = append(progress, p)
+			},
+		})
+		require.NoError(t, err)
+		require.Equal(t, &RepackResult{SupersededPacks: 2, Packs: 1, Objects: 4, Bytes: 30}, result)
+		require.Equal(t, []RepackProgress{{Packs: 2, DonePacks: 2, Bytes: 30, DoneBytes: 30}}, progress)
+
+		packs, err := repo.PackRepo().List(ctx, project.ID)
+		require.NoError(t, err)
+		require.Len(t, packs, 1)
+		require.Equal(t, "aaa", readFile("main", "a.txt"))
+		require.Equal(t, "bbbbb", readFile("main", "b.txt"))
+		require.Equal(t, "cc", readFile("feat", "c.txt"))
+		require.Equal(t, "dddddddddddddddddddd", readFile("feat", "d.txt"))
+
+		//a lone pack which is not fragmented is kept
+		result, err = workRepo.Packer().Repack(ctx, RepackOption{})
+		require.NoError(t, err)
+		require.Equal(t, &RepackResult{}, result)
+	})
+
+	t.Run("rewrite fragmented pack", func(t *testing.T) {
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, "feat"))
+		require.NoError(t, workRepo.DeleteBranch(ctx))
+		_, err := workRepo.GarbageCollector().GC(ctx, GcOption{})
+		require.NoError(t, err)
+
+		result, err := workRepo.Packer().Repack(ctx, RepackOption{SmallPackSize: 1})
+		require.NoError(t, err)
+		require.Equal(t, &RepackResult{SupersededPacks: 1, Packs: 1, Objects: 2, Bytes: 8, ReclaimedBytes: 22}, result)
+		require.Equal(t, "aaa", readFile("main", "a.txt"))
+		require.Equal(t, "bbbbb", readFile("main", "b.txt"))
+	})
+}
@@ -0,0 +1,196 @@
+package versionmgr
+
+import (
+	"context"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/block/params"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var cleanerLog = logging.Logger("repository_cleaner")
+
+const (
+	// DefaultCleanInterval how often unfinished deletion jobs are retried
+	DefaultCleanInterval = time.Minute
+	// DefaultCleanBatchSize rows removed by a single statement, keep statements short so other writes are not blocked
+	DefaultCleanBatchSize = 1000
+)
+
+// storageStep step of deletion job removing data from storage
+const storageStep = "storage"
+
+// RepositoryCleaner run deletion jobs of purged repositories in background, removing their rows table by table in
+// batches and their storage data at last. steps are idempotent so an interrupted job is simply run again
+type RepositoryCleaner struct {
+	repo                models.IRepo
+	publicAdapterConfig params.AdapterConfig
+	interval            time.Duration
+	batchSize           int
+	buildAdapter        func(ctx context.Context, repoModel *models.Repository) (block.Adapter, error)
+
+	trigger chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func NewRepositoryCleaner(repo models.IRepo, publicAdapterConfig params.AdapterConfig, interval time.Duration, batchSize int) *RepositoryCleaner {
+	if interval <= 0 {
+		interval = DefaultCleanInterval
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultCleanBatchSize
+	}
+	cleaner := &RepositoryCleaner{
+		repo:                repo,
+		publicAdapterConfig: publicAdapterConfig,
+		interval:            interval,
+		batchSize:           batchSize,
+		trigger:             make(chan struct{}, 1),
+	}
+	cleaner.buildAdapter = func(ctx context.Context, repoModel *models.Repository) (block.Adapter, error) {
+		return adapterOf(ctx, repoModel, cleaner.publicAdapterConfig)
+	}
+	return cleaner
+}
+
+// Start run unfinished jobs every interval or when triggered in background until Stop called
+func (cleaner *RepositoryCleaner) Start(ctx context.Context) {
+	ctx, cleaner.cancel = context.WithCancel(ctx)
+	cleaner.done = make(chan struct{})
+	go func() {
+		defer close(cleaner.done)
+		ticker := time.NewTicker(cleaner.interval)
+		defer ticker.Stop()
+		// jobs interrupted by last shutdown are resumed at once
+		cleaner.Trigger()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-cleaner.trigger:
+			}
+			finished, err := cleaner.RunPending(ctx)
+			if err != nil {
+				cleanerLog.Errorf("clean deleted repositories %v", err)
+			}
+			if finished > 0 {
+				cleanerLog.Infof("clean %d deleted repositories", finished)
+			}
+		}
+	}()
+}
+
+// Stop background cleaning and wait for the running round
+func (cleaner *RepositoryCleaner) Stop() {
+	if cleaner.cancel == nil {
+		return
+	}
+	cleaner.cancel()
+	<-cleaner.done
+}
+
+// Trigger ask for a round soon without waiting for interval, called when a job is created
+func (cleaner *RepositoryCleaner) Trigger() {
+	select {
+	case cleaner.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// RunPending run unfinished jobs one by one, return number of jobs finished. failed job records its error and is
+// retried by next round without blocking later ones
+func (cleaner *RepositoryCleaner) RunPending(ctx context.Context) (int, error) {
+	jobs, err := cleaner.repo.RepositoryDeletionRepo().ListUnfinished(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	finished := 0
+	for _, job := range jobs {
+		if ctx.Err() != nil {
+			return finished, ctx.Err()
+		}
+		err = cleaner.run(ctx, job)
+		if err != nil {
+			cleanerLog.Warnf("clean repository %s %v", job.RepositoryID, err)
+			updateErr := cleaner.repo.RepositoryDeletionRepo().Update(ctx, models.NewUpdateDeletionJobParams(job.ID).SetError(err.Error()))
+			if updateErr != nil {
+				return finished, updateErr
+			}
+			continue
+		}
+		finished++
+	}
+	return finished, nil
+}
+
+func (cleaner *RepositoryCleaner) run(ctx context.Context, job *models.RepositoryDeletionJob) error {
+	deletionRepo := cleaner.repo.RepositoryDeletionRepo()
+	err := deletionRepo.Update(ctx, models.NewUpdateDeletionJobParams(job.ID).SetState(models.DeletionJobRunning))
+	if err != nil {
+		return err
+	}
+
+	for _, table := range models.RepositoryDataTables {
+		err = deletionRepo.Update(ctx, models.NewUpdateDeletionJobParams(job.ID).SetStep(table.Name))
+		if err != nil {
+			return err
+		}
+		for {
+			deleted, err := deletionRepo.DeleteBatch(ctx, table, job.RepositoryID, cleaner.batchSize)
+			if err != nil {
+				return err
+			}
+			if deleted == 0 {
+				break
+			}
+			err = deletionRepo.Update(ctx, models.NewUpdateDeletionJobParams(job.ID).AddDeleted(deleted))
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// shares are few, remove them with their access logs at once
+	_, err = cleaner.repo.ShareRepo().Delete(ctx, models.NewDeleteShareParams().SetRepositoryID(job.RepositoryID))
+	if err != nil {
+		return err
+	}
+
+	err = deletionRepo.Update(ctx, models.NewUpdateDeletionJobParams(job.ID).SetStep(storageStep))
+	if err != nil {
+		return err
+	}
+	err = cleaner.cleanStorage(ctx, job)
+	if err != nil {
+		return err
+	}
+	return deletionRepo.Update(ctx, models.NewUpdateDeletionJobParams(job.ID).SetState(models.DeletionJobDone).SetError(""))
+}
+
+// cleanStorage remove storage namespace of repository, storage shared with forks is kept until the last of them is
+// purged, data of custom storage is removed only if asked for
+func (cleaner *RepositoryCleaner) cleanStorage(ctx context.Context, job *models.RepositoryDeletionJob) error {
+	repoModel := job.Repository()
+	if repoModel.StorageNamespace == nil || (!repoModel.UsePublicStorage && !job.CleanData) {
+		return nil
+	}
+	sharers, err := cleaner.repo.RepositoryRepo().ListSharingStorage(ctx, repoModel)
+	if err != nil {
+		return err
+	}
+	if len(sharers) > 0 {
+		return nil
+	}
+
+	adapter, err := cleaner.buildAdapter(ctx, repoModel)
+	if err != nil {
+		return err
+	}
+	return adapter.RemoveNameSpace(ctx, utils.StringValue(repoModel.StorageNamespace))
+}
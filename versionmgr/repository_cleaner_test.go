@@ -0,0 +1,89 @@
+package versionmgr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/GitDataAI/jiaozifs/utils/pathutil"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRepositoryCleaner(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	project, err := makeRepository(ctx, repo, user, "testClean")
+	require.NoError(t, err)
+	project.UsePublicStorage = true
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	commit, err := addChangesToWip(ctx, workRepo, "main", "base commit", `
+1|a.txt	|aaa
+1|b/c.txt	|ccc
+`)
+	require.NoError(t, err)
+	_, err = addChangesToWip(ctx, workRepo, "main", "second commit", `
+1|d.txt	|ddd
+`)
+	require.NoError(t, err)
+
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+	workTree, err := workRepo.RootTree(ctx)
+	require.NoError(t, err)
+	blob, _, err := workTree.FindBlob(ctx, "a.txt")
+	require.NoError(t, err)
+	blobPointer := block.ObjectPointer{
+		StorageNamespace: utils.StringValue(project.StorageNamespace),
+		IdentifierType:   block.IdentifierTypeRelative,
+		Identifier:       pathutil.PathOfHash(blob.CheckSum),
+	}
+	exist, err := adapter.Exists(ctx, blobPointer)
+	require.NoError(t, err)
+	require.True(t, exist)
+
+	_, err = repo.RepositoryRepo().Purge(ctx, models.NewDeleteRepoParams().SetID(project.ID))
+	require.NoError(t, err)
+	job, err := repo.RepositoryDeletionRepo().Insert(ctx, models.NewRepositoryDeletionJob(project, user.ID, false))
+	require.NoError(t, err)
+
+	cleaner := NewRepositoryCleaner(repo, nil, time.Minute, 2)
+	cleaner.buildAdapter = func(_ context.Context, _ *models.Repository) (block.Adapter, error) {
+		return adapter, nil
+	}
+	finished, err := cleaner.RunPending(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, finished)
+
+	job, err = repo.RepositoryDeletionRepo().Get(ctx, job.ID)
+	require.NoError(t, err)
+	require.Equal(t, models.DeletionJobDone, job.State)
+	require.False(t, job.FinishedAt.IsZero())
+	require.Positive(t, job.Deleted)
+
+	_, err = repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(project.ID).SetName("main"))
+	require.ErrorIs(t, err, models.ErrNotFound)
+	_, err = repo.CommitRepo(project.ID).Commit(ctx, commit.Hash)
+	require.ErrorIs(t, err, models.ErrNotFound)
+	_, err = repo.FileTreeRepo(project.ID).Get(ctx, models.NewGetObjParams().SetHash(commit.TreeHash))
+	require.ErrorIs(t, err, models.ErrNotFound)
+	exist, err = adapter.Exists(ctx, blobPointer)
+	require.NoError(t, err)
+	require.False(t, exist)
+
+	// done job is not run again
+	finished, err = cleaner.RunPending(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 0, finished)
+}
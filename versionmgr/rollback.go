@@ -0,0 +1,371 @@
+package versionmgr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+)
+
+// rollbackEventPageSize events read at a time when rolling back
+const rollbackEventPageSize = 1000
+
+// ErrRollbackBeforeCreation there is no state of repository before it was created
+var ErrRollbackBeforeCreation = errors.New("can not roll back to a time before repository was created")
+
+// RefRollback a ref changed by rollback. Old is empty if ref is recreated, New is empty if ref is removed
+type RefRollback struct {
+	Name string
+	Old  hash.Hash
+	New  hash.Hash
+	// Backup name of ref keeping Old, empty if nothing is discarded
+	Backup string
+}
+
+// RollbackResult refs changed by rollback
+type RollbackResult struct {
+	Branches []RefRollback
+	Tags     []RefRollback
+}
+
+type tagState struct {
+	target  hash.Hash
+	tagType models.TagType
+	message *string
+}
+
+// RollbackTo roll all branches and tags of repository back to their state at time at, by undoing ref changes recorded in
+// event log since then in reverse order. refs created or moved since then are kept under names prefixed by backupPrefix so
+// nothing is lost, branches created since then are renamed to keep their wips. commits of the past state must be retained,
+// that is not collected by gc
+func (repository *WorkRepository) RollbackTo(ctx context.Context, at time.Time, backupPrefix string) (*RollbackResult, error) {
+	err := repository.repoModel.CheckWritable()
+	if err != nil {
+		return nil, err
+	}
+	if at.Before(repository.repoModel.CreatedAt) {
+		return nil, ErrRollbackBeforeCreation
+	}
+	if len(backupPrefix) == 0 {
+		backupPrefix = fmt.Sprintf("backup_%d", time.Now().Unix())
+	}
+
+	branches, _, err := repository.repo.BranchRepo().List(ctx, models.NewListBranchParams().SetRepositoryID(repository.repoModel.ID))
+	if err != nil {
+		return nil, err
+	}
+	tags, _, err := repository.repo.TagRepo().List(ctx, models.NewListTagParams().SetRepositoryID(repository.repoModel.ID))
+	if err != nil {
+		return nil, err
+	}
+
+	pastBranches := make(map[string]hash.Hash, len(branches))
+	for _, branch := range branches {
+		pastBranches[branch.Name] = branch.CommitHash
+	}
+	pastTags := make(map[string]tagState, len(tags))
+	for _, tag := range tags {
+		pastTags[tag.Name] = tagState{target: tag.Target, tagType: tag.Type, message: tag.Message}
+	}
+
+	events, err := repository.eventsAfter(ctx, at)
+	if err != nil {
+		return nil, err
+	}
+	for i := len(events) - 1; i >= 0; i-- {
+		payload := events[i].Payload
+		switch events[i].Type {
+		case models.BranchCreatedEvent:
+			delete(pastBranches, payload["branch"])
+		case models.BranchUpdatedEvent:
+			pastBranches[payload["branch"]], err = hash.FromHex(payload["old"])
+		case models.BranchDeletedEvent:
+			pastBranches[payload["branch"]], err = hash.FromHex(payload["commit"])
+		case models.TagCreatedEvent:
+			delete(pastTags, payload["tag"])
+		case models.TagDeletedEvent:
+			state := tagState{tagType: models.LightweightTag}
+			if payload["type"] == models.AnnotatedTag.String() {
+				state.tagType = models.AnnotatedTag
+			}
+			if message, ok := payload["message"]; ok {
+				state.message = &message
+			}
+			state.target, err = hash.FromHex(payload["target"])
+			pastTags[payload["tag"]] = state
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse event %d %w", events[i].Seq, err)
+		}
+	}
+
+	for name, commitHash := range pastBranches {
+		err = repository.checkRetained(ctx, "branch "+name, commitHash)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for name, state := range pastTags {
+		err = repository.checkRetained(ctx, "tag "+name, state.target)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	result := &RollbackResult{}
+	err = repository.repo.Transaction(ctx, func(repo models.IRepo) error {
+		result.Branches, err = repository.rollbackBranches(ctx, repo, branches, pastBranches, backupPrefix)
+		if err != nil {
+			return err
+		}
+		result.Tags, err = repository.rollbackTags(ctx, repo, tags, pastTags, backupPrefix)
+		if err != nil {
+			return err
+		}
+		return repository.recordEvent(ctx, repo, models.RepositoryRolledBackEvent, map[string]string{
+			"time":   at.Format(time.RFC3339Nano),
+			"backup": backupPrefix,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// eventsAfter events of repository recorded after at, in seq order
+func (repository *WorkRepository) eventsAfter(ctx context.Context, at time.Time) ([]*models.Event, error) {
+	var events []*models.Event
+	params := models.NewListEventParams().
+		SetRepositoryID(repository.repoModel.ID).
+		SetCreatedAfter(at).
+		SetAmount(rollbackEventPageSize)
+	for {
+		page, hasMore, err := repository.repo.EventRepo().List(ctx, params)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, page...)
+		if !hasMore {
+			return events, nil
+		}
+		params.SetAfter(page[len(page)-1].Seq)
+	}
+}
+
+func (repository *WorkRepository) checkRetained(ctx context.Context, ref string, commitHash hash.Hash) error {
+	if commitHash.IsEmpty() {
+		return nil
+	}
+	_, err := repository.repo.CommitRepo(repository.repoModel.ID).Commit(ctx, commitHash)
+	if err != nil {
+		return fmt.Errorf("commit %s of %s is not retained %w", commitHash.Hex(), ref, err)
+	}
+	return nil
+}
+
+func (repository *WorkRepository) rollbackBranches(ctx context.Context, repo models.IRepo, branches []*models.Branch, past map[string]hash.Hash, backupPrefix string) ([]RefRollback, error) {
+	var changes []RefRollback
+	current := make(map[string]struct{}, len(branches))
+	for _, branch := range branches {
+		current[branch.Name] = struct{}{}
+		commitHash, ok := past[branch.Name]
+		if ok && bytes.Equal(commitHash, branch.CommitHash) {
+			continue
+		}
+		err := branch.CheckForcePush()
+		if err != nil {
+			return nil, err
+		}
+
+		backup := backupPrefix + "/" + strings.ReplaceAll(branch.Name, "/", "_")
+		_, err = repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(repository.repoModel.ID).SetName(backup))
+		err = backupAbsent(backup, err)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			err = repository.moveBranch(ctx, repo, branch, backup, commitHash)
+		} else {
+			err = repository.renameBranch(ctx, repo, branch, backup)
+		}
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, RefRollback{Name: branch.Name, Old: branch.CommitHash, New: commitHash, Backup: backup})
+	}
+
+	// branches deleted since then
+	for name, commitHash := range past {
+		if _, ok := current[name]; ok {
+			continue
+		}
+		err := repository.insertBranch(ctx, repo, name, commitHash)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, RefRollback{Name: name, New: commitHash})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes, nil
+}
+
+// moveBranch keep head of branch as backup then move branch to commitHash
+func (repository *WorkRepository) moveBranch(ctx context.Context, repo models.IRepo, branch *models.Branch, backup string, commitHash hash.Hash) error {
+	err := repository.insertBranch(ctx, repo, backup, branch.CommitHash)
+	if err != nil {
+		return err
+	}
+	err = repo.BranchRepo().UpdateCommitHash(ctx, branch.ID, branch.CommitHash, commitHash)
+	if err != nil {
+		return err
+	}
+	return repository.recordEvent(ctx, repo, models.BranchUpdatedEvent, map[string]string{
+		"branch": branch.Name,
+		"old":    branch.CommitHash.Hex(),
+		"new":    commitHash.Hex(),
+	})
+}
+
+// renameBranch rename branch to backup, wips go along with branch
+func (repository *WorkRepository) renameBranch(ctx context.Context, repo models.IRepo, branch *models.Branch, backup string) error {
+	err := repo.BranchRepo().UpdateByID(ctx, models.NewUpdateBranchParams(branch.ID).SetName(backup).SetVersion(branch.Version))
+	if err != nil {
+		return err
+	}
+	err = repository.recordEvent(ctx, repo, models.BranchDeletedEvent, map[string]string{
+		"branch": branch.Name,
+		"commit": branch.CommitHash.Hex(),
+	})
+	if err != nil {
+		return err
+	}
+	return repository.recordEvent(ctx, repo, models.BranchCreatedEvent, map[string]string{
+		"branch": backup,
+		"commit": branch.CommitHash.Hex(),
+	})
+}
+
+func (repository *WorkRepository) insertBranch(ctx context.Context, repo models.IRepo, name string, commitHash hash.Hash) error {
+	_, err := repo.BranchRepo().Insert(ctx, &models.Branch{
+		RepositoryID: repository.repoModel.ID,
+		CommitHash:   commitHash,
+		Name:         name,
+		CreatorID:    repository.operator.ID,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return repository.recordEvent(ctx, repo, models.BranchCreatedEvent, map[string]string{
+		"branch": name,
+		"commit": commitHash.Hex(),
+	})
+}
+
+func (repository *WorkRepository) rollbackTags(ctx context.Context, repo models.IRepo, tags []*models.Tag, past map[string]tagState, backupPrefix string) ([]RefRollback, error) {
+	var changes []RefRollback
+	current := make(map[string]struct{}, len(tags))
+	for _, tag := range tags {
+		current[tag.Name] = struct{}{}
+		state, ok := past[tag.Name]
+		if ok && bytes.Equal(state.target, tag.Target) {
+			continue
+		}
+
+		backup := backupPrefix + "-" + tag.Name
+		_, err := repo.TagRepo().Get(ctx, models.NewGetTagParams().SetRepositoryID(repository.repoModel.ID).SetName(backup))
+		err = backupAbsent(backup, err)
+		if err != nil {
+			return nil, err
+		}
+		err = repository.insertTag(ctx, repo, backup, tagState{target: tag.Target, tagType: tag.Type, message: tag.Message})
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			err = repo.TagRepo().UpdateByID(ctx, models.NewUpdateTagParams(tag.ID).SetTarget(state.target))
+		} else {
+			_, err = repo.TagRepo().Delete(ctx, models.NewDeleteTagParams().SetRepositoryID(repository.repoModel.ID).SetID(tag.ID))
+		}
+		if err != nil {
+			return nil, err
+		}
+		err = repository.recordEvent(ctx, repo, models.TagDeletedEvent, tagPayload(tag.Name, tagState{target: tag.Target, tagType: tag.Type, message: tag.Message}))
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			err = repository.recordEvent(ctx, repo, models.TagCreatedEvent, tagPayload(tag.Name, state))
+			if err != nil {
+				return nil, err
+			}
+		}
+		changes = append(changes, RefRollback{Name: tag.Name, Old: tag.Target, New: state.target, Backup: backup})
+	}
+
+	// tags deleted since then
+	for name, state := range past {
+		if _, ok := current[name]; ok {
+			continue
+		}
+		err := repository.insertTag(ctx, repo, name, state)
+		if err != nil {
+			return nil, err
+		}
+		changes = append(changes, RefRollback{Name: name, New: state.target})
+	}
+	sort.Slice(changes, func(i, j int) bool {
+		return changes[i].Name < changes[j].Name
+	})
+	return changes, nil
+}
+
+func (repository *WorkRepository) insertTag(ctx context.Context, repo models.IRepo, name string, state tagState) error {
+	_, err := repo.TagRepo().Insert(ctx, &models.Tag{
+		RepositoryID: repository.repoModel.ID,
+		Name:         name,
+		CreatorID:    repository.operator.ID,
+		Target:       state.target,
+		Message:      state.message,
+		Type:         state.tagType,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	})
+	if err != nil {
+		return err
+	}
+	return repository.recordEvent(ctx, repo, models.TagCreatedEvent, tagPayload(name, state))
+}
+
+func tagPayload(name string, state tagState) map[string]string {
+	payload := map[string]string{
+		"tag":    name,
+		"target": state.target.Hex(),
+		"type":   state.tagType.String(),
+	}
+	if state.message != nil {
+		payload["message"] = *state.message
+	}
+	return payload
+}
+
+// backupAbsent turn result of looking up backup ref into error if the ref exists
+func backupAbsent(backup string, err error) error {
+	if err == nil {
+		return fmt.Errorf("backup ref %s already exists %w", backup, models.ErrRefConflict)
+	}
+	if errors.Is(err, models.ErrNotFound) {
+		return nil
+	}
+	return err
+}
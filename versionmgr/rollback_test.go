@@ -0,0 +1,115 @@
+package versionmgr
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRollbackTo(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	project, err := makeRepository(ctx, repo, user, "testRollback")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	base, err := addChangesToWip(ctx, workRepo, "main", "base commit", `
+1|a.txt	|aaa
+`)
+	require.NoError(t, err)
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+	_, err = workRepo.CreateBranch(ctx, "feat1")
+	require.NoError(t, err)
+	_, err = workRepo.CreateTag(ctx, "v1", utils.String("release v1"))
+	require.NoError(t, err)
+
+	time.Sleep(10 * time.Millisecond)
+	at := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	second, err := addChangesToWip(ctx, workRepo, "main", "second commit", `
+1|b.txt	|bbb
+`)
+	require.NoError(t, err)
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+	_, err = workRepo.CreateBranch(ctx, "feat2")
+	require.NoError(t, err)
+	_, err = workRepo.CreateTag(ctx, "v2", nil)
+	require.NoError(t, err)
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "feat1"))
+	require.NoError(t, workRepo.DeleteBranch(ctx))
+	require.NoError(t, workRepo.CheckOut(ctx, InTag, "v1"))
+	require.NoError(t, workRepo.DeleteTag(ctx))
+
+	_, err = workRepo.RollbackTo(ctx, project.CreatedAt.Add(-time.Hour), "bk")
+	require.ErrorIs(t, err, ErrRollbackBeforeCreation)
+
+	time.Sleep(10 * time.Millisecond)
+	beforeRollback := time.Now()
+	time.Sleep(10 * time.Millisecond)
+
+	result, err := workRepo.RollbackTo(ctx, at, "bk")
+	require.NoError(t, err)
+	require.Equal(t, []RefRollback{
+		{Name: "feat1", New: base.Hash},
+		{Name: "feat2", Old: second.Hash, Backup: "bk/feat2"},
+		{Name: "main", Old: second.Hash, New: base.Hash, Backup: "bk/main"},
+	}, result.Branches)
+	require.Equal(t, []RefRollback{
+		{Name: "v1", New: base.Hash},
+		{Name: "v2", Old: second.Hash, Backup: "bk-v2"},
+	}, result.Tags)
+
+	branchHead := func(name string) string {
+		branch, err := repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(project.ID).SetName(name))
+		require.NoError(t, err)
+		return branch.CommitHash.Hex()
+	}
+	require.Equal(t, base.Hash.Hex(), branchHead("main"))
+	require.Equal(t, base.Hash.Hex(), branchHead("feat1"))
+	require.Equal(t, second.Hash.Hex(), branchHead("bk/main"))
+	require.Equal(t, second.Hash.Hex(), branchHead("bk/feat2"))
+	_, err = repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(project.ID).SetName("feat2"))
+	require.ErrorIs(t, err, models.ErrNotFound)
+
+	v1, err := repo.TagRepo().Get(ctx, models.NewGetTagParams().SetRepositoryID(project.ID).SetName("v1"))
+	require.NoError(t, err)
+	require.Equal(t, models.AnnotatedTag, v1.Type)
+	require.Equal(t, "release v1", utils.StringValue(v1.Message))
+	_, err = repo.TagRepo().Get(ctx, models.NewGetTagParams().SetRepositoryID(project.ID).SetName("v2"))
+	require.ErrorIs(t, err, models.ErrNotFound)
+	_, err = repo.TagRepo().Get(ctx, models.NewGetTagParams().SetRepositoryID(project.ID).SetName("bk-v2"))
+	require.NoError(t, err)
+
+	t.Run("rollback is recorded and can be undone", func(t *testing.T) {
+		result, err := workRepo.RollbackTo(ctx, beforeRollback, "bk2")
+		require.NoError(t, err)
+		require.Equal(t, []RefRollback{
+			{Name: "bk/feat2", Old: second.Hash, Backup: "bk2/bk_feat2"},
+			{Name: "bk/main", Old: second.Hash, Backup: "bk2/bk_main"},
+			{Name: "feat1", Old: base.Hash, Backup: "bk2/feat1"},
+			{Name: "feat2", New: second.Hash},
+			{Name: "main", Old: base.Hash, New: second.Hash, Backup: "bk2/main"},
+		}, result.Branches)
+		require.Equal(t, []RefRollback{
+			{Name: "bk-v2", Old: second.Hash, Backup: "bk2-bk-v2"},
+			{Name: "v1", Old: base.Hash, Backup: "bk2-v1"},
+			{Name: "v2", New: second.Hash},
+		}, result.Tags)
+		require.Equal(t, second.Hash.Hex(), branchHead("main"))
+		require.Equal(t, second.Hash.Hex(), branchHead("feat2"))
+	})
+}
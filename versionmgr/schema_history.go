@@ -0,0 +1,200 @@
+package versionmgr
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils/hash"
+	"github.com/GitDataAI/jiaozifs/utils/masking"
+	"github.com/GitDataAI/jiaozifs/utils/tabular"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var schemaLog = logging.Logger("schema")
+
+var ErrNotTabular = errors.New("path is not a tabular file")
+
+// SchemaVersion columns of tabular file after a commit which changed it, Columns is nil if file is removed by commit or
+// content can not be parsed. Changes compare with the file in first parent of commit
+type SchemaVersion struct {
+	Commit  *models.Commit
+	Columns []tabular.Column
+	Changes []tabular.Change
+}
+
+// FileSchemaChange schema changes of a tabular file
+type FileSchemaChange struct {
+	Path    string
+	Changes []tabular.Change
+}
+
+// SchemaHistory return schema of path at each commit reachable from refHash which touched it, newest first, commits are
+// selected the same way as FileLog
+func (repository *WorkRepository) SchemaHistory(ctx context.Context, refHash hash.Hash, path string, params *FileLogParams) ([]*SchemaVersion, error) {
+	path = CleanPath(path)
+	format, ok := masking.FormatFromPath(path)
+	if !ok {
+		return nil, ErrNotTabular
+	}
+
+	commits, err := repository.FileLog(ctx, refHash, path, params)
+	if err != nil {
+		return nil, err
+	}
+
+	commitRepo := repository.repo.CommitRepo(repository.repoModel.ID)
+	differ := &pathDiffer{
+		fileTreeRepo: repository.repo.FileTreeRepo(repository.repoModel.ID),
+		components:   splitPath(path),
+		trees:        make(map[string]*models.TreeNode),
+	}
+	versions := make([]*SchemaVersion, 0, len(commits))
+	for _, commit := range commits {
+		columns, err := repository.columnsAt(ctx, differ, commit.TreeHash, format)
+		if err != nil {
+			return nil, err
+		}
+
+		var parentColumns []tabular.Column
+		if len(commit.ParentHashes) > 0 {
+			parent, err := commitRepo.Commit(ctx, commit.ParentHashes[0])
+			if err != nil {
+				return nil, err
+			}
+			parentColumns, err = repository.columnsAt(ctx, differ, parent.TreeHash, format)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		versions = append(versions, &SchemaVersion{
+			Commit:  commit,
+			Columns: columns,
+			Changes: tabular.Diff(parentColumns, columns),
+		})
+	}
+	return versions, nil
+}
+
+// MergeSchemaChanges schema changes current branch brings to tabular files it modified since best ancestor, pairs are
+// merge state returned by GetMergeState. files added or removed and files can not be parsed are skipped
+func (repository *WorkRepository) MergeSchemaChanges(ctx context.Context, pairs []*ChangePair) ([]*FileSchemaChange, error) {
+	schemaChanges := make([]*FileSchemaChange, 0)
+	for _, pair := range pairs {
+		if pair.Left == nil || pair.Left.From() == nil || pair.Left.To() == nil {
+			continue
+		}
+		format, ok := masking.FormatFromPath(pair.Left.Path())
+		if !ok {
+			continue
+		}
+
+		oldColumns, err := repository.fileSchema(ctx, repository.repo, pair.Left.From().Hash(), format)
+		if err != nil {
+			return nil, err
+		}
+		newColumns, err := repository.fileSchema(ctx, repository.repo, pair.Left.To().Hash(), format)
+		if err != nil {
+			return nil, err
+		}
+		if oldColumns == nil || newColumns == nil {
+			continue
+		}
+
+		changes := tabular.Diff(oldColumns, newColumns)
+		if len(changes) > 0 {
+			schemaChanges = append(schemaChanges, &FileSchemaChange{Path: pair.Left.Path(), Changes: changes})
+		}
+	}
+	return schemaChanges, nil
+}
+
+// recordSchemas save schemas of tabular files added or modified by commit since oldHash, so reading schema history
+// later doesn't need to read content of every version
+func (repository *WorkRepository) recordSchemas(ctx context.Context, repo models.IRepo, oldHash hash.Hash, commit *models.Commit) error {
+	oldTree := hash.Empty
+	if !oldHash.IsEmpty() {
+		oldCommit, err := repo.CommitRepo(repository.repoModel.ID).Commit(ctx, oldHash)
+		if err != nil {
+			return err
+		}
+		oldTree = oldCommit.TreeHash
+	}
+	if bytes.Equal(oldTree, commit.TreeHash) {
+		return nil
+	}
+
+	workTree, err := newWorkTree(ctx, repo.FileTreeRepo(repository.repoModel.ID), repository.repoModel, oldTree)
+	if err != nil {
+		return err
+	}
+	changes, err := workTree.Diff(ctx, commit.TreeHash, "")
+	if err != nil {
+		return err
+	}
+	return changes.ForEach(func(change IChange) error {
+		format, ok := masking.FormatFromPath(change.Path())
+		if !ok || change.To() == nil {
+			return nil
+		}
+		_, err := repository.fileSchema(ctx, repo, change.To().Hash(), format)
+		return err
+	})
+}
+
+func (repository *WorkRepository) columnsAt(ctx context.Context, differ *pathDiffer, treeHash hash.Hash, format masking.Format) ([]tabular.Column, error) {
+	entryHash, err := differ.entryHash(ctx, treeHash)
+	if err != nil {
+		return nil, err
+	}
+	if entryHash.IsEmpty() {
+		return nil, nil
+	}
+	return repository.fileSchema(ctx, repository.repo, entryHash, format)
+}
+
+// fileSchema return columns of blob read as format, schema is inferred from content and saved on first use. nil is
+// returned if content can not be read or parsed
+func (repository *WorkRepository) fileSchema(ctx context.Context, repo models.IRepo, blobHash hash.Hash, format masking.Format) ([]tabular.Column, error) {
+	blob, err := repo.FileTreeRepo(repository.repoModel.ID).Blob(ctx, blobHash)
+	if err != nil {
+		return nil, err
+	}
+
+	params := models.NewGetFileSchemaParams().SetRepositoryID(repository.repoModel.ID).SetCheckSum(blob.CheckSum).SetFormat(string(format))
+	schema, err := repo.FileSchemaRepo().Get(ctx, params)
+	if err == nil {
+		return schema.Columns, nil
+	}
+	if !errors.Is(err, models.ErrNotFound) {
+		return nil, err
+	}
+
+	reader, err := repository.ReadBlob(ctx, blob, nil)
+	if err != nil {
+		schemaLog.Warnf("read blob %s of repo %s %v", blobHash.Hex(), repository.repoModel.ID, err)
+		return nil, nil
+	}
+	defer reader.Close() //nolint
+
+	columns, err := tabular.InferSchema(reader, format)
+	if err != nil {
+		schemaLog.Warnf("infer schema of blob %s of repo %s %v", blobHash.Hex(), repository.repoModel.ID, err)
+		return nil, nil
+	}
+
+	err = repo.FileSchemaRepo().Insert(ctx, &models.FileSchema{
+		RepositoryID: repository.repoModel.ID,
+		CheckSum:     blob.CheckSum,
+		Format:       string(format),
+		Columns:      columns,
+		CreatedAt:    time.Now(),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return columns, nil
+}
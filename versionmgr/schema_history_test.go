@@ -0,0 +1,96 @@
+package versionmgr
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/GitDataAI/jiaozifs/utils/tabular"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchemaHistory(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	project, err := makeRepository(ctx, repo, user, "testSchema")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	commitFile := func(branch, path, content string) *models.Commit {
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, branch))
+		commit, err := workRepo.CommitOnBranch(ctx, "update "+path, func(root *WorkTree) error {
+			blob, err := workRepo.WriteBlob(ctx, strings.NewReader(content), int64(len(content)), models.DefaultLeafProperty())
+			if err != nil {
+				return err
+			}
+			err = root.ReplaceLeaf(ctx, path, blob)
+			if errors.Is(err, ErrPathNotFound) {
+				return root.AddLeaf(ctx, path, blob)
+			}
+			return err
+		})
+		require.NoError(t, err)
+		return commit
+	}
+
+	commitFile("main", "data.csv", "id,name\n1,a\n")
+	commitFile("main", "a.txt", "a")
+	head := commitFile("main", "data.csv", "id,age\n1.5,3\n")
+
+	t.Run("history", func(t *testing.T) {
+		versions, err := workRepo.SchemaHistory(ctx, head.Hash, "data.csv", NewFileLogParams())
+		require.NoError(t, err)
+		require.Len(t, versions, 2)
+		require.Equal(t, head.Hash, versions[0].Commit.Hash)
+		require.Equal(t, []tabular.Column{{Name: "id", Type: tabular.TypeNumber}, {Name: "age", Type: tabular.TypeInteger}}, versions[0].Columns)
+		require.Equal(t, []tabular.Change{
+			{Column: "id", Kind: tabular.ColumnTypeChanged, OldType: tabular.TypeInteger, NewType: tabular.TypeNumber},
+			{Column: "name", Kind: tabular.ColumnRemoved, OldType: tabular.TypeString},
+			{Column: "age", Kind: tabular.ColumnAdded, NewType: tabular.TypeInteger},
+		}, versions[0].Changes)
+		require.Len(t, versions[1].Changes, 2)
+
+		_, err = workRepo.SchemaHistory(ctx, head.Hash, "a.txt", NewFileLogParams())
+		require.ErrorIs(t, err, ErrNotTabular)
+	})
+
+	t.Run("recorded at commit", func(t *testing.T) {
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+		root, err := workRepo.RootTree(ctx)
+		require.NoError(t, err)
+		blob, _, err := root.FindBlob(ctx, "data.csv")
+		require.NoError(t, err)
+
+		schema, err := repo.FileSchemaRepo().Get(ctx, models.NewGetFileSchemaParams().SetRepositoryID(project.ID).SetCheckSum(blob.CheckSum).SetFormat("csv"))
+		require.NoError(t, err)
+		require.Len(t, schema.Columns, 2)
+	})
+
+	t.Run("merge schema changes", func(t *testing.T) {
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+		_, err := workRepo.CreateBranch(ctx, "feat")
+		require.NoError(t, err)
+		commitFile("feat", "data.csv", "id,age,email\n1.5,3,a@b.c\n")
+		commitFile("feat", "b.txt", "b")
+
+		pairs, err := workRepo.GetMergeState(ctx, head.Hash)
+		require.NoError(t, err)
+		schemaChanges, err := workRepo.MergeSchemaChanges(ctx, pairs)
+		require.NoError(t, err)
+		require.Equal(t, []*FileSchemaChange{{
+			Path:    "data.csv",
+			Changes: []tabular.Change{{Column: "email", Kind: tabular.ColumnAdded, NewType: tabular.TypeString}},
+		}}, schemaChanges)
+	})
+}
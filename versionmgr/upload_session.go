@@ -0,0 +1,333 @@
+package versionmgr
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/block/params"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/utils"
+	"github.com/google/uuid"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var uploadLog = logging.Logger("upload_session")
+
+var (
+	DefaultUploadSessionTTL     = 24 * time.Hour
+	DefaultUploadReapInterval   = 10 * time.Minute
+	ErrUploadSessionNoPart      = errors.New("upload session has no part")
+	ErrUploadSessionPartMissing = errors.New("part not uploaded")
+)
+
+// uploadSessionPrefix staging prefix of multipart uploads in storage namespace, it never collides with blob address
+const uploadSessionPrefix = "_uploads"
+
+func (repository *WorkRepository) uploadPointer(session *models.UploadSession) block.ObjectPointer {
+	return block.ObjectPointer{
+		StorageNamespace: utils.StringValue(repository.repoModel.StorageNamespace),
+		IdentifierType:   block.IdentifierTypeRelative,
+		Identifier:       session.Address,
+	}
+}
+
+// CreateUploadSession start multipart upload of objPath on branch refName
+func (repository *WorkRepository) CreateUploadSession(ctx context.Context, refName, objPath string) (*models.UploadSession, error) {
+	err := repository.repoModel.CheckWritable()
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	session := &models.UploadSession{
+		ID:           uuid.New(),
+		RepositoryID: repository.repoModel.ID,
+		CreatorID:    repository.operator.ID,
+		RefName:      refName,
+		Path:         objPath,
+		Parts:        []models.UploadPart{},
+		LastActiveAt: now,
+		CreatedAt:    now,
+	}
+	session.Address = path.Join(uploadSessionPrefix, session.ID.String())
+
+	resp, err := repository.adapter.CreateMultiPartUpload(ctx, repository.uploadPointer(session), nil, block.CreateMultiPartUploadOpts{})
+	if err != nil {
+		return nil, err
+	}
+	session.UploadID = resp.UploadID
+
+	_, err = repository.repo.UploadSessionRepo().Insert(ctx, session)
+	if err != nil {
+		_ = repository.adapter.AbortMultiPartUpload(ctx, repository.uploadPointer(session), resp.UploadID)
+		return nil, err
+	}
+	return session, nil
+}
+
+// UploadPart upload one part of session, uploading the same part number again replace the old one
+func (repository *WorkRepository) UploadPart(ctx context.Context, session *models.UploadSession, partNumber int, body io.Reader, contentLength int64) (*models.UploadPart, error) {
+	if contentLength < 0 {
+		// length of decoded body is unknown, storages need it to upload a part
+		spooled, size, err := spoolToTemp(body)
+		if err != nil {
+			return nil, err
+		}
+		defer spooled.Close() //nolint
+		body, contentLength = spooled, size
+	}
+	counter := &countingReader{reader: body}
+	resp, err := repository.adapter.UploadPart(ctx, repository.uploadPointer(session), contentLength, counter, session.UploadID, partNumber)
+	if err != nil {
+		return nil, err
+	}
+
+	part := models.UploadPart{
+		PartNumber: partNumber,
+		ETag:       resp.ETag,
+		Size:       counter.size,
+	}
+	err = repository.repo.UploadSessionRepo().AddPart(ctx, session.ID, part)
+	if err != nil {
+		return nil, err
+	}
+	return &part, nil
+}
+
+// CompleteUploadSession assemble parts of session into a blob, partNumbers select parts in order, all uploaded parts are
+// used if it is empty. session is removed once blob written
+func (repository *WorkRepository) CompleteUploadSession(ctx context.Context, session *models.UploadSession, partNumbers []int, properties models.Property) (*models.Blob, error) {
+	uploaded := make(map[int]models.UploadPart, len(session.Parts))
+	for _, part := range session.Parts {
+		uploaded[part.PartNumber] = part
+	}
+	if len(partNumbers) == 0 {
+		for number := range uploaded {
+			partNumbers = append(partNumbers, number)
+		}
+		sort.Ints(partNumbers)
+	}
+	if len(partNumbers) == 0 {
+		return nil, ErrUploadSessionNoPart
+	}
+
+	completion := &block.MultipartUploadCompletion{}
+	var size int64
+	for _, number := range partNumbers {
+		part, ok := uploaded[number]
+		if !ok {
+			return nil, fmt.Errorf("%d %w", number, ErrUploadSessionPartMissing)
+		}
+		completion.Part = append(completion.Part, block.MultipartPart{PartNumber: part.PartNumber, ETag: part.ETag})
+		size += part.Size
+	}
+
+	pointer := repository.uploadPointer(session)
+	_, err := repository.adapter.CompleteMultiPartUpload(ctx, pointer, session.UploadID, completion)
+	if err != nil {
+		return nil, err
+	}
+
+	// blob is addressed by checksum of content, rewrite assembled object to its address
+	reader, err := repository.adapter.Get(ctx, pointer, size)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close() //nolint
+
+	blob, err := repository.WriteBlob(ctx, reader, size, properties)
+	if err != nil {
+		return nil, err
+	}
+
+	err = repository.adapter.Remove(ctx, pointer)
+	if err != nil {
+		uploadLog.Warnf("remove staging object of upload session %s %v", session.ID, err)
+	}
+	_, err = repository.repo.UploadSessionRepo().Delete(ctx, models.NewDeleteUploadSessionParams().SetID(session.ID))
+	if err != nil {
+		return nil, err
+	}
+	return blob, nil
+}
+
+// AbortUploadSession drop uploaded parts in storage and remove session
+func (repository *WorkRepository) AbortUploadSession(ctx context.Context, session *models.UploadSession) error {
+	return abortUploadSession(ctx, repository.repo, repository.adapter, repository.repoModel, session)
+}
+
+func abortUploadSession(ctx context.Context, repo models.IRepo, adapter block.Adapter, repoModel *models.Repository, session *models.UploadSession) error {
+	err := adapter.AbortMultiPartUpload(ctx, block.ObjectPointer{
+		StorageNamespace: utils.StringValue(repoModel.StorageNamespace),
+		IdentifierType:   block.IdentifierTypeRelative,
+		Identifier:       session.Address,
+	}, session.UploadID)
+	if err != nil {
+		return fmt.Errorf("abort multipart upload of session %s %w", session.ID, err)
+	}
+	_, err = repo.UploadSessionRepo().Delete(ctx, models.NewDeleteUploadSessionParams().SetID(session.ID))
+	return err
+}
+
+type countingReader struct {
+	reader io.Reader
+	size   int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+	c.size += int64(n)
+	return n, err
+}
+
+// UploadSessionReaper abort upload sessions idle beyond ttl periodically, so parts of abandoned uploads do not stay in
+// storage forever
+type UploadSessionReaper struct {
+	repo                models.IRepo
+	publicAdapterConfig params.AdapterConfig
+	ttl                 time.Duration
+	interval            time.Duration
+	buildAdapter        func(ctx context.Context, repoModel *models.Repository) (block.Adapter, error)
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewUploadSessionReaper(repo models.IRepo, publicAdapterConfig params.AdapterConfig, ttl, interval time.Duration) *UploadSessionReaper {
+	if ttl <= 0 {
+		ttl = DefaultUploadSessionTTL
+	}
+	if interval <= 0 {
+		interval = DefaultUploadReapInterval
+	}
+	reaper := &UploadSessionReaper{
+		repo:                repo,
+		publicAdapterConfig: publicAdapterConfig,
+		ttl:                 ttl,
+		interval:            interval,
+	}
+	reaper.buildAdapter = func(ctx context.Context, repoModel *models.Repository) (block.Adapter, error) {
+		return adapterOf(ctx, repoModel, reaper.publicAdapterConfig)
+	}
+	return reaper
+}
+
+// Start reap stale sessions every interval in background until Stop called
+func (reaper *UploadSessionReaper) Start(ctx context.Context) {
+	ctx, reaper.cancel = context.WithCancel(ctx)
+	reaper.done = make(chan struct{})
+	go func() {
+		defer close(reaper.done)
+		ticker := time.NewTicker(reaper.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				count, err := reaper.Reap(ctx)
+				if err != nil {
+					uploadLog.Errorf("reap upload sessions %v", err)
+				}
+				if count > 0 {
+					uploadLog.Infof("abort %d stale upload sessions", count)
+				}
+			}
+		}
+	}()
+}
+
+// Stop background reaping and wait for the running round
+func (reaper *UploadSessionReaper) Stop() {
+	if reaper.cancel == nil {
+		return
+	}
+	reaper.cancel()
+	<-reaper.done
+}
+
+// Reap abort sessions idle longer than ttl, return number of sessions aborted. session failed to abort is kept and retried
+// in next round
+func (reaper *UploadSessionReaper) Reap(ctx context.Context) (int, error) {
+	sessions, _, err := reaper.repo.UploadSessionRepo().List(ctx, models.NewListUploadSessionParams().SetIdleBefore(time.Now().Add(-reaper.ttl)))
+	if err != nil {
+		return 0, err
+	}
+
+	adapters := make(map[uuid.UUID]block.Adapter)
+	count := 0
+	for _, session := range sessions {
+		repoModel, err := reaper.repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetID(session.RepositoryID))
+		if errors.Is(err, models.ErrNotFound) {
+			// repository in trash still own its storage
+			repoModel, err = reaper.repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetID(session.RepositoryID).SetDeleted())
+		}
+		if errors.Is(err, models.ErrNotFound) {
+			// storage of repository is gone with repository
+			_, err = reaper.repo.UploadSessionRepo().Delete(ctx, models.NewDeleteUploadSessionParams().SetID(session.ID))
+			if err != nil {
+				return count, err
+			}
+			count++
+			continue
+		}
+		if err != nil {
+			return count, err
+		}
+
+		adapter, ok := adapters[repoModel.ID]
+		if !ok {
+			adapter, err = reaper.buildAdapter(ctx, repoModel)
+			if err != nil {
+				uploadLog.Warnf("build adapter of repository %s %v", repoModel.Name, err)
+				continue
+			}
+			adapters[repoModel.ID] = adapter
+		}
+
+		err = abortUploadSession(ctx, reaper.repo, adapter, repoModel, session)
+		if err != nil {
+			uploadLog.Warnf("abort stale upload session %s %v", session.ID, err)
+			continue
+		}
+		count++
+	}
+	return count, nil
+}
+
+// spooledFile temp file removed when closed
+type spooledFile struct {
+	*os.File
+}
+
+func (f spooledFile) Close() error {
+	name := f.Name()
+	_ = f.File.Close()
+	return os.RemoveAll(name)
+}
+
+// spoolToTemp copy body to a temp file to learn its size, file is positioned at start
+func spoolToTemp(body io.Reader) (io.ReadCloser, int64, error) {
+	tempf, err := os.CreateTemp("", "*")
+	if err != nil {
+		return nil, 0, err
+	}
+	spooled := spooledFile{File: tempf}
+	size, err := io.Copy(tempf, body)
+	if err != nil {
+		_ = spooled.Close()
+		return nil, 0, err
+	}
+	_, err = tempf.Seek(0, io.SeekStart)
+	if err != nil {
+		_ = spooled.Close()
+		return nil, 0, err
+	}
+	return spooled, size, nil
+}
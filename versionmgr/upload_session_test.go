@@ -0,0 +1,109 @@
+package versionmgr
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block"
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUploadSession(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	adapter := mem.New(ctx)
+	repo := models.NewRepo(db)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	repoModel, err := makeRepository(ctx, repo, user, "upload")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, repoModel, repo, adapter)
+
+	t.Run("complete", func(t *testing.T) {
+		session, err := workRepo.CreateUploadSession(ctx, "main", "a/b.txt")
+		require.NoError(t, err)
+
+		_, err = workRepo.CompleteUploadSession(ctx, session, nil, models.DefaultLeafProperty())
+		require.ErrorIs(t, err, ErrUploadSessionNoPart)
+
+		//length of decoded body is unknown
+		part, err := workRepo.UploadPart(ctx, session, 2, bytes.NewReader([]byte("world")), -1)
+		require.NoError(t, err)
+		require.Equal(t, int64(5), part.Size)
+		_, err = workRepo.UploadPart(ctx, session, 1, bytes.NewReader([]byte("hi ")), 3)
+		require.NoError(t, err)
+		//replace part 1
+		part, err = workRepo.UploadPart(ctx, session, 1, bytes.NewReader([]byte("hello ")), 6)
+		require.NoError(t, err)
+		require.Equal(t, int64(6), part.Size)
+
+		session, err = repo.UploadSessionRepo().Get(ctx, models.NewGetUploadSessionParams().SetID(session.ID))
+		require.NoError(t, err)
+		require.Len(t, session.Parts, 2)
+
+		_, err = workRepo.CompleteUploadSession(ctx, session, []int{1, 3}, models.DefaultLeafProperty())
+		require.ErrorIs(t, err, ErrUploadSessionPartMissing)
+
+		blob, err := workRepo.CompleteUploadSession(ctx, session, nil, models.DefaultLeafProperty())
+		require.NoError(t, err)
+		require.Equal(t, int64(11), blob.Size)
+
+		reader, err := workRepo.ReadBlob(ctx, blob, nil)
+		require.NoError(t, err)
+		content, err := io.ReadAll(reader)
+		require.NoError(t, err)
+		require.Equal(t, "hello world", string(content))
+
+		_, err = repo.UploadSessionRepo().Get(ctx, models.NewGetUploadSessionParams().SetID(session.ID))
+		require.ErrorIs(t, err, models.ErrNotFound)
+	})
+
+	t.Run("abort", func(t *testing.T) {
+		session, err := workRepo.CreateUploadSession(ctx, "main", "c.txt")
+		require.NoError(t, err)
+		_, err = workRepo.UploadPart(ctx, session, 1, bytes.NewReader([]byte("abc")), 3)
+		require.NoError(t, err)
+
+		require.NoError(t, workRepo.AbortUploadSession(ctx, session))
+		_, err = workRepo.UploadPart(ctx, session, 2, bytes.NewReader([]byte("abc")), 3)
+		require.ErrorIs(t, err, mem.ErrMultiPartNotFound)
+
+		count, err := repo.UploadSessionRepo().CountByCreator(ctx, user.ID)
+		require.NoError(t, err)
+		require.Equal(t, 0, count)
+	})
+
+	t.Run("reap stale sessions", func(t *testing.T) {
+		stale, err := workRepo.CreateUploadSession(ctx, "main", "stale.txt")
+		require.NoError(t, err)
+		time.Sleep(time.Millisecond * 100)
+		active, err := workRepo.CreateUploadSession(ctx, "main", "active.txt")
+		require.NoError(t, err)
+
+		reaper := NewUploadSessionReaper(repo, nil, time.Millisecond*50, 0)
+		reaper.buildAdapter = func(_ context.Context, _ *models.Repository) (block.Adapter, error) {
+			return adapter, nil
+		}
+		count, err := reaper.Reap(ctx)
+		require.NoError(t, err)
+		require.Equal(t, 1, count)
+
+		_, err = repo.UploadSessionRepo().Get(ctx, models.NewGetUploadSessionParams().SetID(stale.ID))
+		require.ErrorIs(t, err, models.ErrNotFound)
+		_, err = workRepo.UploadPart(ctx, stale, 1, bytes.NewReader([]byte("abc")), 3)
+		require.ErrorIs(t, err, mem.ErrMultiPartNotFound)
+
+		_, err = repo.UploadSessionRepo().Get(ctx, models.NewGetUploadSessionParams().SetID(active.ID))
+		require.NoError(t, err)
+	})
+}
@@ -0,0 +1,293 @@
+package versionmgr
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/models"
+	logging "github.com/ipfs/go-log/v2"
+)
+
+var reporterLog = logging.Logger("usage_report")
+
+const (
+	// DefaultUsageReportInterval how often pending usage reports are looked for
+	DefaultUsageReportInterval = time.Minute
+	// usageReportTopN rows of contributors and largest files kept in report
+	usageReportTopN = 20
+)
+
+// sections of usage report, every row is section,name,count,bytes
+const (
+	// UsageSectionPrefix files and bytes under each top level entry of default branch, files in root are under "/"
+	UsageSectionPrefix = "prefix"
+	// UsageSectionGrowth commits and net bytes added of each month
+	UsageSectionGrowth = "growth"
+	// UsageSectionContributor commits and bytes added of each author
+	UsageSectionContributor = "contributor"
+	// UsageSectionLargestFile size of largest files of default branch
+	UsageSectionLargestFile = "largest_file"
+)
+
+type usageRow struct {
+	name  string
+	count int64
+	bytes int64
+}
+
+// UsageReport write usage report of repository as csv, storage is counted on default branch, growth and contributors
+// are counted on all commits of repository from their stats
+func (repository *WorkRepository) UsageReport(ctx context.Context) ([]byte, error) {
+	prefixes, largest, err := repository.usageOfTree(ctx)
+	if err != nil {
+		return nil, err
+	}
+	growth, contributors, err := repository.usageOfCommits(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	writer := csv.NewWriter(buf)
+	err = writer.Write([]string{"section", "name", "count", "bytes"})
+	if err != nil {
+		return nil, err
+	}
+	for _, section := range []struct {
+		name string
+		rows []usageRow
+	}{
+		{UsageSectionPrefix, prefixes},
+		{UsageSectionGrowth, growth},
+		{UsageSectionContributor, contributors},
+		{UsageSectionLargestFile, largest},
+	} {
+		for _, row := range section.rows {
+			err = writer.Write([]string{section.name, row.name, strconv.FormatInt(row.count, 10), strconv.FormatInt(row.bytes, 10)})
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+	writer.Flush()
+	return buf.Bytes(), writer.Error()
+}
+
+func (repository *WorkRepository) usageOfTree(ctx context.Context) ([]usageRow, []usageRow, error) {
+	rootTree, err := repository.RootTree(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prefixes := make(map[string]*usageRow)
+	var files []usageRow
+	err = NewFileWalk(rootTree.object, rootTree.root).Walk(ctx, func(_ *models.TreeEntry, blob *models.Blob, path string) error {
+		if blob == nil {
+			return nil
+		}
+		prefix := "/"
+		if first, _, found := strings.Cut(path, "/"); found {
+			prefix = first
+		}
+		row, ok := prefixes[prefix]
+		if !ok {
+			row = &usageRow{name: prefix}
+			prefixes[prefix] = row
+		}
+		row.count++
+		row.bytes += blob.Size
+		files = append(files, usageRow{name: path, count: 1, bytes: blob.Size})
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	prefixRows := make([]usageRow, 0, len(prefixes))
+	for _, row := range prefixes {
+		prefixRows = append(prefixRows, *row)
+	}
+	bySize := func(rows []usageRow) {
+		sort.Slice(rows, func(i, j int) bool {
+			if rows[i].bytes != rows[j].bytes {
+				return rows[i].bytes > rows[j].bytes
+			}
+			return rows[i].name < rows[j].name
+		})
+	}
+	bySize(prefixRows)
+	bySize(files)
+	return prefixRows, files[:min(len(files), usageReportTopN)], nil
+}
+
+func (repository *WorkRepository) usageOfCommits(ctx context.Context) ([]usageRow, []usageRow, error) {
+	commits, err := repository.repo.CommitRepo(repository.repoModel.ID).List(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	months := make(map[string]*usageRow)
+	authors := make(map[string]*usageRow)
+	for _, commit := range commits {
+		stats, err := repository.CommitStats(ctx, commit)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		month := commit.Committer.When.UTC().Format("2006-01")
+		row, ok := months[month]
+		if !ok {
+			row = &usageRow{name: month}
+			months[month] = row
+		}
+		row.count++
+		row.bytes += stats.BytesAdded - stats.BytesRemoved
+
+		author := fmt.Sprintf("%s <%s>", commit.Author.Name, commit.Author.Email)
+		row, ok = authors[author]
+		if !ok {
+			row = &usageRow{name: author}
+			authors[author] = row
+		}
+		row.count++
+		row.bytes += stats.BytesAdded
+	}
+
+	growth := make([]usageRow, 0, len(months))
+	for _, row := range months {
+		growth = append(growth, *row)
+	}
+	sort.Slice(growth, func(i, j int) bool {
+		return growth[i].name < growth[j].name
+	})
+
+	contributors := make([]usageRow, 0, len(authors))
+	for _, row := range authors {
+		contributors = append(contributors, *row)
+	}
+	sort.Slice(contributors, func(i, j int) bool {
+		if contributors[i].count != contributors[j].count {
+			return contributors[i].count > contributors[j].count
+		}
+		return contributors[i].name < contributors[j].name
+	})
+	return growth, contributors[:min(len(contributors), usageReportTopN)], nil
+}
+
+// UsageReporter generate usage reports requested by repository admins in background, a report interrupted by shutdown
+// is generated again on next start
+type UsageReporter struct {
+	repo     models.IRepo
+	interval time.Duration
+
+	trigger chan struct{}
+	cancel  context.CancelFunc
+	done    chan struct{}
+}
+
+func NewUsageReporter(repo models.IRepo, interval time.Duration) *UsageReporter {
+	if interval <= 0 {
+		interval = DefaultUsageReportInterval
+	}
+	return &UsageReporter{
+		repo:     repo,
+		interval: interval,
+		trigger:  make(chan struct{}, 1),
+	}
+}
+
+// Start generate pending reports every interval or when triggered in background until Stop called
+func (reporter *UsageReporter) Start(ctx context.Context) {
+	ctx, reporter.cancel = context.WithCancel(ctx)
+	reporter.done = make(chan struct{})
+	go func() {
+		defer close(reporter.done)
+		ticker := time.NewTicker(reporter.interval)
+		defer ticker.Stop()
+		reporter.Trigger()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-reporter.trigger:
+			}
+			_, err := reporter.RunPending(ctx)
+			if err != nil {
+				reporterLog.Errorf("generate usage reports %v", err)
+			}
+		}
+	}()
+}
+
+// Stop background generation and wait for the running round
+func (reporter *UsageReporter) Stop() {
+	if reporter.cancel == nil {
+		return
+	}
+	reporter.cancel()
+	<-reporter.done
+}
+
+// Trigger ask for a round soon without waiting for interval, called when a report is requested
+func (reporter *UsageReporter) Trigger() {
+	select {
+	case reporter.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// RunPending generate unfinished reports one by one, return number of reports done. report failed to generate is
+// marked failed with its error and should be requested again
+func (reporter *UsageReporter) RunPending(ctx context.Context) (int, error) {
+	reports, err := reporter.repo.UsageReportRepo().ListUnfinished(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	done := 0
+	for _, report := range reports {
+		if ctx.Err() != nil {
+			return done, ctx.Err()
+		}
+		content, err := reporter.generate(ctx, report)
+		if err != nil {
+			reporterLog.Warnf("generate usage report %s of repository %s %v", report.ID, report.RepositoryID, err)
+			err = reporter.repo.UsageReportRepo().Update(ctx, models.NewUpdateUsageReportParams(report.ID).SetState(models.UsageReportFailed).SetError(err.Error()))
+			if err != nil {
+				return done, err
+			}
+			continue
+		}
+		err = reporter.repo.UsageReportRepo().Update(ctx, models.NewUpdateUsageReportParams(report.ID).SetState(models.UsageReportDone).SetContent(content))
+		if err != nil {
+			return done, err
+		}
+		done++
+	}
+	return done, nil
+}
+
+func (reporter *UsageReporter) generate(ctx context.Context, report *models.UsageReport) ([]byte, error) {
+	err := reporter.repo.UsageReportRepo().Update(ctx, models.NewUpdateUsageReportParams(report.ID).SetState(models.UsageReportRunning))
+	if err != nil {
+		return nil, err
+	}
+
+	repoModel, err := reporter.repo.RepositoryRepo().Get(ctx, models.NewGetRepoParams().SetID(report.RepositoryID))
+	if err != nil {
+		return nil, err
+	}
+	creator, err := reporter.repo.UserRepo().Get(ctx, models.NewGetUserParams().SetID(report.CreatorID))
+	if err != nil {
+		return nil, err
+	}
+	// report is made of metadata only, storage is not touched
+	return NewWorkRepositoryFromAdapter(ctx, creator, repoModel, reporter.repo, nil).UsageReport(ctx)
+}
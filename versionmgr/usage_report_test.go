@@ -0,0 +1,69 @@
+package versionmgr
+
+import (
+	"context"
+	"encoding/csv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/GitDataAI/jiaozifs/block/mem"
+	"github.com/GitDataAI/jiaozifs/models"
+	"github.com/GitDataAI/jiaozifs/testhelper"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUsageReporter(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+	project, err := makeRepository(ctx, repo, user, "usage")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	_, err = addChangesToWip(ctx, workRepo, "main", "first", `
+1|a.txt	|aaaa
+1|data/b.bin	|bbbbbbbb
+1|data/c.bin	|cc
+`)
+	require.NoError(t, err)
+	_, err = addChangesToWip(ctx, workRepo, "main", "second", `
+2|data/c.bin	|
+`)
+	require.NoError(t, err)
+
+	report, err := repo.UsageReportRepo().Insert(ctx, models.NewUsageReport(project.ID, user.ID))
+	require.NoError(t, err)
+
+	done, err := NewUsageReporter(repo, time.Minute).RunPending(ctx)
+	require.NoError(t, err)
+	require.Equal(t, 1, done)
+
+	report, err = repo.UsageReportRepo().Get(ctx, models.NewGetUsageReportParams().SetID(report.ID).SetRepositoryID(project.ID))
+	require.NoError(t, err)
+	require.Equal(t, models.UsageReportDone, report.State)
+	require.False(t, report.FinishedAt.IsZero())
+
+	records, err := csv.NewReader(strings.NewReader(string(report.Content))).ReadAll()
+	require.NoError(t, err)
+	month := time.Now().UTC().Format("2006-01")
+	require.Equal(t, [][]string{
+		{"section", "name", "count", "bytes"},
+		{UsageSectionPrefix, "data", "1", "8"},
+		{UsageSectionPrefix, "/", "1", "4"},
+		{UsageSectionGrowth, month, "2", "12"},
+		{UsageSectionContributor, "admin <xxx@gg.com>", "2", "14"},
+		{UsageSectionLargestFile, "data/b.bin", "1", "8"},
+		{UsageSectionLargestFile, "a.txt", "1", "4"},
+	}, records)
+
+	unfinished, err := repo.UsageReportRepo().ListUnfinished(ctx)
+	require.NoError(t, err)
+	require.Empty(t, unfinished)
+}
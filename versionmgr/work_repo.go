@@ -47,22 +47,30 @@ type WorkRepository struct {
 	branch   *models.Branch
 	tag      *models.Tag
 	commit   *models.Commit
+	// extra variables of commit message template
+	messageVars  map[string]string
+	metadata     map[string]string
+	commitLimits CommitLimits
+	// percentages of storage quota warned at, see SetQuotaWarningThresholds
+	quotaThresholds []int
 }
 
 func NewWorkRepositoryFromConfig(ctx context.Context, operator *models.User, repoModel *models.Repository, repo models.IRepo, publicAdapterConfig params.AdapterConfig) (*WorkRepository, error) {
-	var adapter block.Adapter
-	var err error
-	if repoModel.UsePublicStorage {
-		adapter, err = factory.BuildBlockAdapter(ctx, publicAdapterConfig)
-	} else {
-		adapter, err = AdapterFromConfig(ctx, *repoModel.StorageAdapterParams)
-	}
+	adapter, err := adapterOf(ctx, repoModel, publicAdapterConfig)
 	if err != nil {
 		return nil, err
 	}
 	return NewWorkRepositoryFromAdapter(ctx, operator, repoModel, repo, adapter), nil
 }
 
+// adapterOf build storage adapter of repository
+func adapterOf(ctx context.Context, repoModel *models.Repository, publicAdapterConfig params.AdapterConfig) (block.Adapter, error) {
+	if repoModel.UsePublicStorage {
+		return factory.BuildBlockAdapter(ctx, publicAdapterConfig)
+	}
+	return AdapterFromConfig(ctx, *repoModel.StorageAdapterParams)
+}
+
 func NewWorkRepositoryFromAdapter(_ context.Context, operator *models.User, repoModel *models.Repository, repo models.IRepo, adapter block.Adapter) *WorkRepository {
 	return &WorkRepository{
 		operator:  operator,
@@ -71,8 +79,9 @@ func NewWorkRepositoryFromAdapter(_ context.Context, operator *models.User, repo
 	}
 }
 
-// WriteBlob write blob content to storage
-func (repository *WorkRepository) WriteBlob(ctx context.Context, body io.Reader, contentLength int64, properties models.Property) (*models.Blob, error) {
+// WriteBlob write blob content to storage, size is counted while content is spooled as length of compressed request
+// body is unknown once decoded
+func (repository *WorkRepository) WriteBlob(ctx context.Context, body io.Reader, _ int64, properties models.Property) (*models.Blob, error) {
 	// handle the upload itself
 	hashReader := hash.NewHashingReader(body, hash.Md5)
 	tempf, err := os.CreateTemp("", "*")
@@ -96,21 +105,91 @@ func (repository *WorkRepository) WriteBlob(ctx context.Context, body io.Reader,
 		_ = os.RemoveAll(name)
 	}()
 
+	err = repository.checkQuota(ctx, hashReader.CopiedSize)
+	if err != nil {
+		return nil, err
+	}
+
+	// content already in pack is not stored again
+	packed, err := findPacked(ctx, repository.repo, repository.repoModel.ID, checkSum)
+	if err != nil {
+		return nil, err
+	}
+	if packed != nil {
+		return models.NewBlobWithHashType(repository.repoModel.HashType, properties, repository.repoModel.ID, checkSum, hashReader.CopiedSize)
+	}
+
 	address := pathutil.PathOfHash(checkSum)
 	err = repository.adapter.Put(ctx, block.ObjectPointer{
 		StorageNamespace: utils.StringValue(repository.repoModel.StorageNamespace),
 		IdentifierType:   block.IdentifierTypeRelative,
 		Identifier:       address,
-	}, contentLength, tempf, block.PutOpts{})
+	}, hashReader.CopiedSize, tempf, block.PutOpts{})
 	if err != nil {
 		return nil, err
 	}
 
-	return models.NewBlob(properties, repository.repoModel.ID, checkSum, hashReader.CopiedSize)
+	return models.NewBlobWithHashType(repository.repoModel.HashType, properties, repository.repoModel.ID, checkSum, hashReader.CopiedSize)
+}
+
+// GarbageCollector return gc of this repository
+func (repository *WorkRepository) GarbageCollector() *GarbageCollector {
+	return NewGarbageCollector(repository.repo, repository.repoModel, repository.adapter)
+}
+
+// ConsistencyChecker return checker comparing metadata of this repository with its storage
+func (repository *WorkRepository) ConsistencyChecker() *ConsistencyChecker {
+	return NewConsistencyChecker(repository.repo, repository.repoModel, repository.adapter)
+}
+
+// Packer return packer of small blobs of this repository
+func (repository *WorkRepository) Packer() *Packer {
+	return NewPacker(repository.repo, repository.repoModel, repository.adapter)
 }
 
-// ReadBlob read blob content with range
+// ReadBlob read blob content with range, content is read from pack if blob was packed
 func (repository *WorkRepository) ReadBlob(ctx context.Context, blob *models.Blob, rangeSpec *string) (io.ReadCloser, error) {
+	packed, err := findPacked(ctx, repository.repo, repository.repoModel.ID, blob.CheckSum)
+	if err != nil {
+		return nil, err
+	}
+	if packed != nil {
+		reader, err := repository.readPackedBlob(ctx, packed, rangeSpec)
+		if err != nil {
+			// pack may be superseded by repacking since index was looked up
+			moved, _ := findPacked(ctx, repository.repo, repository.repoModel.ID, blob.CheckSum)
+			if moved != nil && !bytes.Equal(moved.PackCheckSum, packed.PackCheckSum) {
+				return repository.readPackedBlob(ctx, moved, rangeSpec)
+			}
+			return nil, err
+		}
+		return reader, nil
+	}
+
+	reader, err := repository.readLooseBlob(ctx, blob, rangeSpec)
+	if err != nil {
+		// loose file may be removed by packing since index was looked up
+		if packed, _ = findPacked(ctx, repository.repo, repository.repoModel.ID, blob.CheckSum); packed != nil {
+			return repository.readPackedBlob(ctx, packed, rangeSpec)
+		}
+		return nil, err
+	}
+	return reader, nil
+}
+
+func (repository *WorkRepository) readPackedBlob(ctx context.Context, packed *models.PackedObject, rangeSpec *string) (io.ReadCloser, error) {
+	start, end := int64(0), packed.Size-1
+	if rangeSpec != nil {
+		rng, err := httputil.ParseRange(*rangeSpec, packed.Size)
+		if err != nil {
+			return nil, err
+		}
+		start, end = rng.StartOffset, rng.EndOffset
+	}
+	return readPacked(ctx, repository.adapter, repository.repoModel, packed, start, end)
+}
+
+func (repository *WorkRepository) readLooseBlob(ctx context.Context, blob *models.Blob, rangeSpec *string) (io.ReadCloser, error) {
 	address := pathutil.PathOfHash(blob.CheckSum)
 	pointer := block.ObjectPointer{
 		StorageNamespace: utils.StringValue(repository.repoModel.StorageNamespace),
@@ -167,7 +246,16 @@ func (repository *WorkRepository) rootTree(ctx context.Context, repo models.IRep
 		repository.setCurState(InBranch, nil, ref, nil, commit)
 		repository.headTree = &treeHash
 	}
-	return NewWorkTree(ctx, repo.FileTreeRepo(repository.repoModel.ID), models.NewRootTreeEntry(*repository.headTree))
+	return newWorkTree(ctx, repo.FileTreeRepo(repository.repoModel.ID), repository.repoModel, *repository.headTree)
+}
+
+// newWorkTree create worktree at treeHash, new tree nodes are hashed with hash type of repository
+func newWorkTree(ctx context.Context, fileTreeRepo models.IFileTreeRepo, repoModel *models.Repository, treeHash hash.Hash) (*WorkTree, error) {
+	workTree, err := NewWorkTree(ctx, fileTreeRepo, models.NewRootTreeEntry(treeHash))
+	if err != nil {
+		return nil, err
+	}
+	return workTree.SetHashType(repoModel.HashType), nil
 }
 
 func (repository *WorkRepository) CheckOut(ctx context.Context, refType WorkRepoState, refName string) error {
@@ -203,6 +291,11 @@ func (repository *WorkRepository) CheckOut(ctx context.Context, refType WorkRepo
 			return err
 		}
 
+		commitHash, err = ResolveHash(ctx, repository.repo, repository.repoModel, commitHash)
+		if err != nil {
+			return err
+		}
+
 		if !commitHash.IsEmpty() {
 			commit, err := repository.repo.CommitRepo(repository.repoModel.ID).Commit(ctx, commitHash)
 			if err != nil {
@@ -231,6 +324,10 @@ func (repository *WorkRepository) CheckOut(ctx context.Context, refType WorkRepo
 
 // Revert changes in wip, not a good algo, but maybe enough
 func (repository *WorkRepository) Revert(ctx context.Context, prefixPath string) error {
+	err := repository.repoModel.CheckWritable()
+	if err != nil {
+		return err
+	}
 	if repository.state != InWip {
 		return fmt.Errorf("working repo not in wip state")
 	}
@@ -255,12 +352,12 @@ func (repository *WorkRepository) Revert(ctx context.Context, prefixPath string)
 		return nil
 	}
 
-	err := repository.repo.Transaction(ctx, func(repo models.IRepo) error {
-		baseTree, err := NewWorkTree(ctx, repo.FileTreeRepo(repository.repoModel.ID), models.NewRootTreeEntry(baseTreeHash))
+	err = repository.repo.Transaction(ctx, func(repo models.IRepo) error {
+		baseTree, err := newWorkTree(ctx, repo.FileTreeRepo(repository.repoModel.ID), repository.repoModel, baseTreeHash)
 		if err != nil {
 			return err
 		}
-		curTree, err := NewWorkTree(ctx, repo.FileTreeRepo(repository.repoModel.ID), models.NewRootTreeEntry(repository.wip.CurrentTree))
+		curTree, err := newWorkTree(ctx, repo.FileTreeRepo(repository.repoModel.ID), repository.repoModel, repository.wip.CurrentTree)
 		if err != nil {
 			return err
 		}
@@ -312,7 +409,7 @@ func (repository *WorkRepository) Revert(ctx context.Context, prefixPath string)
 			return err
 		}
 
-		err = repository.repo.WipRepo().UpdateByID(ctx, models.NewUpdateWipParams(repository.wip.ID).SetCurrentTree(curTree.Root().Hash()))
+		err = repo.WipRepo().UpdateCurrentTree(ctx, repository.wip.ID, curTree.Root().Hash())
 		if err != nil {
 			return err
 		}
@@ -322,8 +419,189 @@ func (repository *WorkRepository) Revert(ctx context.Context, prefixPath string)
 	return err
 }
 
+// Stage add changes of paths in wip current tree to staged tree, like git add. empty path will stage all changes
+func (repository *WorkRepository) Stage(ctx context.Context, paths ...string) error {
+	err := repository.repoModel.CheckWritable()
+	if err != nil {
+		return err
+	}
+	if repository.state != InWip {
+		return fmt.Errorf("working repo not in wip state")
+	}
+
+	return repository.repo.Transaction(ctx, func(repo models.IRepo) error {
+		stagedTreeHash, err := repository.stagedTreeHash(ctx, repo)
+		if err != nil {
+			return err
+		}
+
+		stagedTree, err := repository.applyPaths(ctx, repo, stagedTreeHash, repository.wip.CurrentTree, paths)
+		if err != nil {
+			return err
+		}
+
+		err = repo.WipRepo().UpdateByID(ctx, models.NewUpdateWipParams(repository.wip.ID).SetStagedTree(stagedTree))
+		if err != nil {
+			return err
+		}
+		repository.wip.StagedTree = stagedTree
+		return nil
+	})
+}
+
+// Unstage remove changes of paths from staged tree, changes still kept in wip current tree, like git reset <paths>. empty path will unstage all changes
+func (repository *WorkRepository) Unstage(ctx context.Context, paths ...string) error {
+	err := repository.repoModel.CheckWritable()
+	if err != nil {
+		return err
+	}
+	if repository.state != InWip {
+		return fmt.Errorf("working repo not in wip state")
+	}
+
+	return repository.repo.Transaction(ctx, func(repo models.IRepo) error {
+		baseTreeHash, err := repository.baseTreeHash(ctx, repo)
+		if err != nil {
+			return err
+		}
+
+		stagedTreeHash, err := repository.stagedTreeHash(ctx, repo)
+		if err != nil {
+			return err
+		}
+
+		stagedTree, err := repository.applyPaths(ctx, repo, stagedTreeHash, baseTreeHash, paths)
+		if err != nil {
+			return err
+		}
+
+		err = repo.WipRepo().UpdateByID(ctx, models.NewUpdateWipParams(repository.wip.ID).SetStagedTree(stagedTree))
+		if err != nil {
+			return err
+		}
+		repository.wip.StagedTree = stagedTree
+		return nil
+	})
+}
+
+// ResetWip drop all staged and unstaged changes, reset wip to the head of branch, like git reset --hard
+func (repository *WorkRepository) ResetWip(ctx context.Context) error {
+	err := repository.repoModel.CheckWritable()
+	if err != nil {
+		return err
+	}
+	if repository.state != InWip {
+		return fmt.Errorf("working repo not in wip state")
+	}
+
+	return repository.repo.Transaction(ctx, func(repo models.IRepo) error {
+		branch, err := repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetID(repository.branch.ID))
+		if err != nil {
+			return err
+		}
+
+		treeHash := hash.Empty
+		if !branch.CommitHash.IsEmpty() {
+			commit, err := repo.CommitRepo(repository.repoModel.ID).Commit(ctx, branch.CommitHash)
+			if err != nil {
+				return err
+			}
+			treeHash = commit.TreeHash
+		}
+
+		updateParams := models.NewUpdateWipParams(repository.wip.ID).
+			SetCurrentTree(treeHash).
+			SetBaseCommit(branch.CommitHash).
+			ClearStagedTree()
+		err = repo.WipRepo().UpdateByID(ctx, updateParams)
+		if err != nil {
+			return err
+		}
+
+		repository.branch = branch
+		repository.wip.CurrentTree = treeHash
+		repository.wip.BaseCommit = branch.CommitHash
+		repository.wip.StagedTree = nil
+		repository.headTree = &repository.wip.CurrentTree
+		return nil
+	})
+}
+
+// GetStagedChanges return changes between base commit and staged tree
+func (repository *WorkRepository) GetStagedChanges(ctx context.Context, pathPrefix string) (*Changes, error) {
+	if repository.state != InWip {
+		return nil, fmt.Errorf("working repo not in wip state")
+	}
+
+	baseTreeHash, err := repository.baseTreeHash(ctx, repository.repo)
+	if err != nil {
+		return nil, err
+	}
+
+	stagedTreeHash, err := repository.stagedTreeHash(ctx, repository.repo)
+	if err != nil {
+		return nil, err
+	}
+
+	baseTree, err := newWorkTree(ctx, repository.repo.FileTreeRepo(repository.repoModel.ID), repository.repoModel, baseTreeHash)
+	if err != nil {
+		return nil, err
+	}
+	return baseTree.Diff(ctx, stagedTreeHash, pathPrefix)
+}
+
+// applyPaths make paths in tree same as paths in sourceTree, return the new tree hash
+func (repository *WorkRepository) applyPaths(ctx context.Context, repo models.IRepo, treeHash hash.Hash, sourceTree hash.Hash, paths []string) (hash.Hash, error) {
+	if len(paths) == 0 {
+		paths = []string{""}
+	}
+
+	workTree, err := newWorkTree(ctx, repo.FileTreeRepo(repository.repoModel.ID), repository.repoModel, treeHash)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range paths {
+		changes, err := workTree.Diff(ctx, sourceTree, p)
+		if err != nil {
+			return nil, err
+		}
+
+		err = changes.ForEach(func(change IChange) error {
+			return workTree.ApplyOneChange(ctx, change)
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return workTree.Root().Hash(), nil
+}
+
+// stagedTreeHash return staged tree, start from tree of base commit if nothing staged before
+func (repository *WorkRepository) stagedTreeHash(ctx context.Context, repo models.IRepo) (hash.Hash, error) {
+	if repository.wip.StagedTree != nil {
+		return repository.wip.StagedTree, nil
+	}
+	return repository.baseTreeHash(ctx, repo)
+}
+
+func (repository *WorkRepository) baseTreeHash(ctx context.Context, repo models.IRepo) (hash.Hash, error) {
+	if repository.wip.BaseCommit.IsEmpty() {
+		return hash.Empty, nil
+	}
+	commit, err := repo.CommitRepo(repository.repoModel.ID).Commit(ctx, repository.wip.BaseCommit)
+	if err != nil {
+		return nil, err
+	}
+	return commit.TreeHash, nil
+}
+
 // DeleteWip remove wip  todo remove files
 func (repository *WorkRepository) DeleteWip(ctx context.Context) error {
+	err := repository.repoModel.CheckWritable()
+	if err != nil {
+		return err
+	}
 	if repository.state != InBranch {
 		return fmt.Errorf("working repo not in branch state")
 	}
@@ -361,15 +639,21 @@ func (repository *WorkRepository) CommitChanges(ctx context.Context, msg string)
 		When:  repository.wip.UpdatedAt,
 	}
 
+	// commit staged changes only if stage was used
+	treeToCommit := repository.wip.CurrentTree
+	if repository.wip.StagedTree != nil {
+		treeToCommit = repository.wip.StagedTree
+	}
+
 	var commit *models.Commit
 	err = repository.repo.Transaction(ctx, func(repo models.IRepo) error {
 		var err error
-		commit, err = repository.commitChangeRoot(ctx, repo, author, repository.wip.CurrentTree, msg)
+		commit, err = repository.commitChangeRoot(ctx, repo, author, treeToCommit, msg)
 		if err != nil {
 			return err
 		}
 
-		return repo.WipRepo().UpdateByID(ctx, models.NewUpdateWipParams(repository.wip.ID).SetBaseCommit(commit.Hash))
+		return repo.WipRepo().UpdateByID(ctx, models.NewUpdateWipParams(repository.wip.ID).SetBaseCommit(commit.Hash).ClearStagedTree())
 	})
 	if err != nil {
 		return nil, err
@@ -377,13 +661,137 @@ func (repository *WorkRepository) CommitChanges(ctx context.Context, msg string)
 
 	repository.branch.CommitHash = commit.Hash
 	repository.wip.BaseCommit = commit.Hash
+	repository.wip.StagedTree = nil
 	repository.headTree = &repository.wip.CurrentTree
 	return commit, err
 }
 
-// ChangeInWip apply change to wip
+// AmendCommit replace the head commit of branch with a new commit which has the same parents, tree of the new commit is the tree in wip.
+// message of the head commit is kept when msg is empty
+func (repository *WorkRepository) AmendCommit(ctx context.Context, msg string) (*models.Commit, error) {
+	err := repository.repoModel.CheckWritable()
+	if err != nil {
+		return nil, err
+	}
+	if !(repository.state == InWip) {
+		return nil, errors.New("must amend commit on wip")
+	}
+
+	if repository.branch.CommitHash.IsEmpty() {
+		return nil, fmt.Errorf("no commit to amend in branch %s", repository.branch.Name)
+	}
+
+	if !bytes.Equal(repository.branch.CommitHash, repository.wip.BaseCommit) {
+		return nil, fmt.Errorf("base commit not equal with branch, please update wip")
+	}
+
+	// amend commit directly to branch, AllowForcePush of protected branch only apply to moving ref
+	if repository.branch.IsProtected {
+		return nil, fmt.Errorf("amend commit on branch %s directly %w", repository.branch.Name, models.ErrBranchProtected)
+	}
+
+	headCommit, err := repository.repo.CommitRepo(repository.repoModel.ID).Commit(ctx, repository.branch.CommitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(msg) == 0 {
+		msg = headCommit.Message
+	} else {
+		msg, err = repository.expandCommitMessage(msg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	treeToCommit := repository.wip.CurrentTree
+	if repository.wip.StagedTree != nil {
+		treeToCommit = repository.wip.StagedTree
+	}
+
+	metadata := headCommit.Metadata
+	if len(repository.metadata) > 0 {
+		metadata = repository.metadata
+	}
+
+	commit := &models.Commit{
+		RepositoryID: repository.repoModel.ID,
+		Author:       headCommit.Author,
+		Committer: models.Signature{
+			Name:  repository.operator.Name,
+			Email: repository.operator.Email,
+			When:  time.Now(),
+		},
+		MergeTag:     headCommit.MergeTag,
+		Message:      msg,
+		TreeHash:     treeToCommit,
+		ParentHashes: headCommit.ParentHashes,
+		Metadata:     metadata,
+		CreatedAt:    time.Now(),
+		UpdatedAt:    time.Now(),
+	}
+	commit.Hash, err = commit.GetHashWithType(repository.repoModel.HashType)
+	if err != nil {
+		return nil, err
+	}
+
+	// amended commit replace head, its changes are counted from parent of head
+	var parentHash hash.Hash
+	if len(headCommit.ParentHashes) > 0 {
+		parentHash = headCommit.ParentHashes[0]
+	}
+	err = repository.checkCommitLimits(ctx, repository.repo, parentHash, treeToCommit)
+	if err != nil {
+		return nil, err
+	}
+	err = repository.runHooks(ctx, repository.repo, models.PreCommitHook, parentHash, treeToCommit, commit.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	err = repository.repo.Transaction(ctx, func(repo models.IRepo) error {
+		_, err := repo.CommitRepo(repository.repoModel.ID).Insert(ctx, commit)
+		if err != nil {
+			return err
+		}
+
+		err = repo.BranchRepo().UpdateCommitHash(ctx, repository.branch.ID, headCommit.Hash, commit.Hash)
+		if err != nil {
+			return err
+		}
+
+		err = repository.recordCommit(ctx, repo, headCommit.Hash, commit, map[string]string{"amended": headCommit.Hash.Hex()})
+		if err != nil {
+			return err
+		}
+
+		return repo.WipRepo().UpdateByID(ctx, models.NewUpdateWipParams(repository.wip.ID).SetBaseCommit(commit.Hash).ClearStagedTree())
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	repository.branch.CommitHash = commit.Hash
+	repository.wip.BaseCommit = commit.Hash
+	repository.wip.StagedTree = nil
+	repository.headTree = &repository.wip.CurrentTree
+	return commit, nil
+}
+
+// ChangeInWip apply change to wip, change is made on tree of wip locked in transaction so changes of concurrent requests
+// to the same wip are not lost
 func (repository *WorkRepository) ChangeInWip(ctx context.Context, changFn func(root *WorkTree) error) error {
+	if repository.wip == nil {
+		return errors.New("must commit changes on branch")
+	}
 	return repository.repo.Transaction(ctx, func(repo models.IRepo) error {
+		wip, err := repo.WipRepo().LockByID(ctx, repository.wip.ID)
+		if err != nil {
+			return err
+		}
+		repository.wip = wip
+		repository.headTree = &wip.CurrentTree
+
 		workTree, err := repository.changeInWip(ctx, repo, changFn)
 		if err != nil {
 			return err
@@ -391,7 +799,7 @@ func (repository *WorkRepository) ChangeInWip(ctx context.Context, changFn func(
 
 		repository.wip.CurrentTree = workTree.Root().Hash()
 		repository.headTree = &repository.wip.CurrentTree
-		return repo.WipRepo().UpdateByID(ctx, models.NewUpdateWipParams(repository.wip.ID).SetCurrentTree(workTree.Root().Hash()))
+		return repo.WipRepo().UpdateCurrentTree(ctx, repository.wip.ID, workTree.Root().Hash())
 	})
 }
 
@@ -440,7 +848,49 @@ func (repository *WorkRepository) ChangeAndCommit(ctx context.Context, msg strin
 	return commit, err
 }
 
+// CommitOnBranch apply changes to tree of branch head and commit directly without wip, operator is the author of commit.
+// branch move is compare and swap, models.ErrRefConflict is returned if branch changed after checkout
+func (repository *WorkRepository) CommitOnBranch(ctx context.Context, msg string, changFn func(root *WorkTree) error) (*models.Commit, error) {
+	if !(repository.state == InBranch) {
+		return nil, errors.New("must checkout branch before commit on branch")
+	}
+
+	author := models.Signature{
+		Name:  repository.operator.Name,
+		Email: repository.operator.Email,
+		When:  time.Now(),
+	}
+
+	var commit *models.Commit
+	err := repository.repo.Transaction(ctx, func(repo models.IRepo) error {
+		workTree, err := repository.rootTree(ctx, repo)
+		if err != nil {
+			return err
+		}
+
+		err = changFn(workTree)
+		if err != nil {
+			return err
+		}
+
+		commit, err = repository.commitChangeRoot(ctx, repo, author, workTree.Root().Hash(), msg)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	repository.branch.CommitHash = commit.Hash
+	repository.headTree = &commit.TreeHash
+	repository.commit = commit
+	return commit, nil
+}
+
 func (repository *WorkRepository) changeInWip(ctx context.Context, repo models.IRepo, changFn func(root *WorkTree) error) (*WorkTree, error) {
+	err := repository.repoModel.CheckWritable()
+	if err != nil {
+		return nil, err
+	}
 	if !(repository.state == InWip) {
 		return nil, errors.New("must commit changes on branch")
 	}
@@ -454,6 +904,19 @@ func (repository *WorkRepository) changeInWip(ctx context.Context, repo models.I
 }
 
 func (repository *WorkRepository) commitChangeRoot(ctx context.Context, repo models.IRepo, author models.Signature, root hash.Hash, msg string) (*models.Commit, error) {
+	err := repository.repoModel.CheckWritable()
+	if err != nil {
+		return nil, err
+	}
+	if repository.branch.IsProtected {
+		return nil, fmt.Errorf("commit to branch %s directly %w", repository.branch.Name, models.ErrBranchProtected)
+	}
+
+	msg, err = repository.expandCommitMessage(msg)
+	if err != nil {
+		return nil, err
+	}
+
 	parentHash := make([]hash.Hash, 0) //avoid nil parent
 	if !repository.branch.CommitHash.IsEmpty() {
 		parentHash = []hash.Hash{repository.branch.CommitHash}
@@ -472,10 +935,20 @@ func (repository *WorkRepository) commitChangeRoot(ctx context.Context, repo mod
 		Message:      msg,
 		TreeHash:     root,
 		ParentHashes: parentHash,
+		Metadata:     repository.metadata,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
-	commitHash, err := commit.GetHash()
+	err = repository.checkCommitLimits(ctx, repo, repository.branch.CommitHash, root)
+	if err != nil {
+		return nil, err
+	}
+	err = repository.runHooks(ctx, repo, models.PreCommitHook, repository.branch.CommitHash, root, commit.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	commitHash, err := commit.GetHashWithType(repository.repoModel.HashType)
 	if err != nil {
 		return nil, err
 	}
@@ -486,20 +959,106 @@ func (repository *WorkRepository) commitChangeRoot(ctx context.Context, repo mod
 		return nil, err
 	}
 
-	// Update branch
-	err = repo.BranchRepo().UpdateByID(ctx, models.NewUpdateBranchParams(repository.branch.ID).SetCommitHash(commitHash))
+	// Update branch, fail if branch was moved by others
+	err = repo.BranchRepo().UpdateCommitHash(ctx, repository.branch.ID, repository.branch.CommitHash, commitHash)
+	if err != nil {
+		return nil, err
+	}
+
+	err = repository.recordCommit(ctx, repo, repository.branch.CommitHash, commit, nil)
 	if err != nil {
 		return nil, err
 	}
 	return commit, err
 }
 
+// recordCommit append events of a new commit and the move of branch to it, save schemas of tabular files it changed
+// and its changes summary, extra is added to payload of commit event
+func (repository *WorkRepository) recordCommit(ctx context.Context, repo models.IRepo, oldHash hash.Hash, commit *models.Commit, extra map[string]string) error {
+	commitPayload := map[string]string{
+		"branch": repository.branch.Name,
+		"commit": commit.Hash.Hex(),
+	}
+	for k, v := range extra {
+		commitPayload[k] = v
+	}
+	err := repository.recordEvent(ctx, repo, models.CommitCreatedEvent, commitPayload)
+	if err != nil {
+		return err
+	}
+
+	err = repository.recordSchemas(ctx, repo, oldHash, commit)
+	if err != nil {
+		return err
+	}
+
+	_, err = repository.recordCommitStats(ctx, repo, commit)
+	if err != nil {
+		return err
+	}
+
+	err = repository.updateQuotaWarning(ctx, repo)
+	if err != nil {
+		return err
+	}
+
+	return repository.recordEvent(ctx, repo, models.BranchUpdatedEvent, map[string]string{
+		"branch": repository.branch.Name,
+		"old":    oldHash.Hex(),
+		"new":    commit.Hash.Hex(),
+	})
+}
+
+// recordEvent append event of this repository to event log, repo should be the transaction which made the change
+func (repository *WorkRepository) recordEvent(ctx context.Context, repo models.IRepo, eventType models.EventType, payload map[string]string) error {
+	_, err := repo.EventRepo().Insert(ctx, models.NewEvent(eventType, repository.repoModel.ID, repository.operator.ID, payload))
+	return err
+}
+
+// SetCommitMessageVars set extra variables used by commit message template of repository, like job id of automation
+func (repository *WorkRepository) SetCommitMessageVars(vars map[string]string) *WorkRepository {
+	repository.messageVars = vars
+	return repository
+}
+
+// SetCommitMetadata set metadata of commits created afterward, metadata of head commit is kept on amend if not set
+func (repository *WorkRepository) SetCommitMetadata(metadata map[string]string) *WorkRepository {
+	repository.metadata = metadata
+	return repository
+}
+
+// expandCommitMessage render msg with commit message template of repository
+func (repository *WorkRepository) expandCommitMessage(msg string) (string, error) {
+	tmpl := utils.StringValue(repository.repoModel.CommitMessageTemplate)
+	if len(tmpl) == 0 {
+		return msg, nil
+	}
+
+	now := time.Now()
+	vars := CommitMessageVars{
+		Message:    msg,
+		Repository: repository.repoModel.Name,
+		User:       repository.operator.Name,
+		Date:       now.Format(time.DateOnly),
+		Time:       now,
+		Vars:       repository.messageVars,
+	}
+	if repository.branch != nil {
+		vars.Branch = repository.branch.Name
+	}
+	return ExpandCommitMessage(tmpl, vars)
+}
+
 // CreateBranch create branch base on current head
 func (repository *WorkRepository) CreateBranch(ctx context.Context, branchName string) (*models.Branch, error) {
+	err := repository.repoModel.CheckWritable()
+	if err != nil {
+		return nil, err
+	}
 	//check exit
-	_, err := repository.repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetName(branchName).SetRepositoryID(repository.repoModel.ID))
+	_, err = repository.repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetName(branchName).SetRepositoryID(repository.repoModel.ID))
 	if err == nil {
-		return nil, fmt.Errorf("%s already exit", branchName)
+		return nil, fmt.Errorf("branch %s %w", branchName, models.ErrAlreadyExists)
 	}
 	if err != nil && !errors.Is(err, models.ErrNotFound) {
 		return nil, err
@@ -518,11 +1077,34 @@ func (repository *WorkRepository) CreateBranch(ctx context.Context, branchName s
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
-	return repository.repo.BranchRepo().Insert(ctx, newBranch)
+	err = repository.repo.Transaction(ctx, func(repo models.IRepo) error {
+		_, err := repo.BranchRepo().Insert(ctx, newBranch)
+		if err != nil {
+			return err
+		}
+		return repository.recordEvent(ctx, repo, models.BranchCreatedEvent, map[string]string{
+			"branch": branchName,
+			"commit": commitHash.Hex(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newBranch, nil
 }
 
 // DeleteBranch delete branch also delete wip belong this branch
 func (repository *WorkRepository) DeleteBranch(ctx context.Context) error {
+	err := repository.repoModel.CheckWritable()
+	if err != nil {
+		return err
+	}
+	if repository.branch.IsProtected {
+		return fmt.Errorf("delete branch %s %w", repository.branch.Name, models.ErrBranchProtected)
+	}
+	if repository.branch.Name == repository.repoModel.HEAD {
+		return fmt.Errorf("delete HEAD branch %s %w", repository.branch.Name, models.ErrBranchProtected)
+	}
 	return repository.repo.Transaction(ctx, func(repo models.IRepo) error {
 		deleteBranchParams := models.NewDeleteBranchParams().
 			SetRepositoryID(repository.repoModel.ID).
@@ -532,22 +1114,28 @@ func (repository *WorkRepository) DeleteBranch(ctx context.Context) error {
 			return err
 		}
 
-		deleteWipParams := models.NewDeleteWipParams().SetRepositoryID(repository.repoModel.ID).SetRefID(repository.branch.ID)
-		_, err = repo.WipRepo().Delete(ctx, deleteWipParams)
+		_, err = repo.WipRepo().DeleteByRef(ctx, repository.repoModel.ID, repository.branch.ID)
 		if err != nil {
 			return err
 		}
 
-		return err
+		return repository.recordEvent(ctx, repo, models.BranchDeletedEvent, map[string]string{
+			"branch": repository.branch.Name,
+			"commit": repository.branch.CommitHash.Hex(),
+		})
 	})
 }
 
 // CreateTag create tag base on current head
 func (repository *WorkRepository) CreateTag(ctx context.Context, tagName string, msg *string) (*models.Tag, error) {
+	err := repository.repoModel.CheckWritable()
+	if err != nil {
+		return nil, err
+	}
 	//check exit
-	_, err := repository.repo.TagRepo().Get(ctx, models.NewGetTagParams().SetName(tagName).SetRepositoryID(repository.repoModel.ID))
+	_, err = repository.repo.TagRepo().Get(ctx, models.NewGetTagParams().SetName(tagName).SetRepositoryID(repository.repoModel.ID))
 	if err == nil {
-		return nil, fmt.Errorf("%s already exit", tagName)
+		return nil, fmt.Errorf("tag %s %w", tagName, models.ErrAlreadyExists)
 	}
 
 	if err != nil && !errors.Is(err, models.ErrNotFound) {
@@ -563,27 +1151,61 @@ func (repository *WorkRepository) CreateTag(ctx context.Context, tagName string,
 		return nil, fmt.Errorf("empty commit to create tag")
 	}
 
-	// Create branch
+	// tag with message is annotated tag, otherwise lightweight tag
+	tagType := models.LightweightTag
+	if msg != nil && len(*msg) > 0 {
+		tagType = models.AnnotatedTag
+	} else {
+		msg = nil
+	}
+
 	newTag := &models.Tag{
 		CreatorID:    repository.operator.ID,
 		Target:       commitHash,
 		Message:      msg,
+		Type:         tagType,
 		RepositoryID: repository.repoModel.ID,
 		Name:         tagName,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
-	return repository.repo.TagRepo().Insert(ctx, newTag)
+	err = repository.repo.Transaction(ctx, func(repo models.IRepo) error {
+		_, err := repo.TagRepo().Insert(ctx, newTag)
+		if err != nil {
+			return err
+		}
+		return repository.recordEvent(ctx, repo, models.TagCreatedEvent, map[string]string{
+			"tag":    tagName,
+			"target": commitHash.Hex(),
+			"type":   tagType.String(),
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newTag, nil
 }
 
 // DeleteTag delete tag
 func (repository *WorkRepository) DeleteTag(ctx context.Context) error {
+	err := repository.repoModel.CheckWritable()
+	if err != nil {
+		return err
+	}
 	return repository.repo.Transaction(ctx, func(repo models.IRepo) error {
 		delTagParams := models.NewDeleteTagParams().
 			SetRepositoryID(repository.repoModel.ID).
 			SetID(repository.tag.ID)
 		_, err := repo.TagRepo().Delete(ctx, delTagParams)
-		return err
+		if err != nil {
+			return err
+		}
+		// type and message are kept so tag can be recreated by rollback
+		return repository.recordEvent(ctx, repo, models.TagDeletedEvent, tagPayload(repository.tag.Name, tagState{
+			target:  repository.tag.Target,
+			tagType: repository.tag.Type,
+			message: repository.tag.Message,
+		}))
 	})
 }
 
@@ -604,6 +1226,10 @@ func (repository *WorkRepository) GetOrCreateWip(ctx context.Context) (*models.W
 	}
 
 	// if not found create a wip
+	err = repository.repoModel.CheckWritable()
+	if err != nil {
+		return nil, false, err
+	}
 	currentTreeHash := hash.Empty
 	if !repository.branch.CommitHash.IsEmpty() {
 		baseCommit, err := repository.repo.CommitRepo(repository.repoModel.ID).Commit(ctx, repository.branch.CommitHash)
@@ -663,7 +1289,7 @@ func (repository *WorkRepository) GetCommitChanges(ctx context.Context, pathPref
 		treeHash = commit.TreeHash
 	}
 
-	workTree, err := NewWorkTree(ctx, repository.repo.FileTreeRepo(repository.repoModel.ID), models.NewRootTreeEntry(treeHash))
+	workTree, err := newWorkTree(ctx, repository.repo.FileTreeRepo(repository.repoModel.ID), repository.repoModel, treeHash)
 	if err != nil {
 		return nil, err
 	}
@@ -707,7 +1333,7 @@ func (repository *WorkRepository) GetMergeState(ctx context.Context, toMergeComm
 		return nil, err
 	}
 
-	ancestorWorkTree, err := NewWorkTree(ctx, repository.repo.FileTreeRepo(repository.repoModel.ID), models.NewRootTreeEntry(bestAncestor.TreeHash))
+	ancestorWorkTree, err := newWorkTree(ctx, repository.repo.FileTreeRepo(repository.repoModel.ID), repository.repoModel, bestAncestor.TreeHash)
 	if err != nil {
 		return nil, err
 	}
@@ -731,16 +1357,24 @@ func (repository *WorkRepository) GetMergeState(ctx context.Context, toMergeComm
 		}
 		changePairs = append(changePairs, changePair)
 	}
+
+	err = repository.resolveByDrivers(ctx, changePairs)
+	if err != nil {
+		return nil, err
+	}
 	return changePairs, nil
 }
 
 // Merge implement merge like git, docs https://en.wikipedia.org/wiki/Merge_(version_control)
 func (repository *WorkRepository) Merge(ctx context.Context, toMergeCommitHash hash.Hash, msg string, resolver ConflictResolver) (*models.Commit, error) {
+	err := repository.repoModel.CheckWritable()
+	if err != nil {
+		return nil, err
+	}
 	if repository.state != InBranch {
 		return nil, errors.New("must merge on branch")
 	}
 	var targetCommit *models.Commit
-	var err error
 	if !repository.branch.CommitHash.IsEmpty() {
 		//get branch commit
 		targetCommit, err = repository.repo.CommitRepo(repository.repoModel.ID).Commit(ctx, repository.branch.CommitHash)
@@ -770,9 +1404,106 @@ func (repository *WorkRepository) Merge(ctx context.Context, toMergeCommitHash h
 		if err != nil {
 			return err
 		}
+		if !bytes.Equal(newCommit.Hash, repository.branch.CommitHash) {
+			var metadata map[string]string
+			if sourceCommit != nil {
+				metadata = sourceCommit.Metadata
+			}
+			err = repository.runHooks(ctx, repo, models.PreMergeHook, repository.branch.CommitHash, newCommit.TreeHash, metadata)
+			if err != nil {
+				return err
+			}
+		}
 
-		updateParams := models.NewUpdateBranchParams(repository.branch.ID).SetCommitHash(newCommit.Hash)
-		return repo.BranchRepo().UpdateByID(ctx, updateParams)
+		err = repo.BranchRepo().UpdateCommitHash(ctx, repository.branch.ID, repository.branch.CommitHash, newCommit.Hash)
+		if err != nil {
+			return err
+		}
+		switch {
+		case bytes.Equal(newCommit.Hash, repository.branch.CommitHash):
+			// already merged, branch not moved
+			return nil
+		case bytes.Equal(newCommit.Hash, toMergeCommitHash):
+			// fast-forward, no new commit
+			return repository.recordEvent(ctx, repo, models.BranchUpdatedEvent, map[string]string{
+				"branch": repository.branch.Name,
+				"old":    repository.branch.CommitHash.Hex(),
+				"new":    newCommit.Hash.Hex(),
+			})
+		}
+		return repository.recordCommit(ctx, repo, repository.branch.CommitHash, newCommit, map[string]string{"merged": toMergeCommitHash.Hex()})
+	})
+	if err != nil {
+		return nil, err
+	}
+	return newCommit, nil
+}
+
+// NotFastForwardError returned by FastForward when branch head is not an ancestor of the commit to merge
+type NotFastForwardError struct {
+	Branch string
+	Head   hash.Hash
+	Target hash.Hash
+}
+
+func (err NotFastForwardError) Error() string {
+	return fmt.Sprintf("branch %s at %s can not fast-forward to %s", err.Branch, err.Head.Hex(), err.Target.Hex())
+}
+
+// FastForward merge toMergeCommitHash in ff-only mode, branch ref is moved to toMergeCommitHash and no merge commit is created.
+// return NotFastForwardError if branch head is not an ancestor of toMergeCommitHash
+func (repository *WorkRepository) FastForward(ctx context.Context, toMergeCommitHash hash.Hash) (*models.Commit, error) {
+	err := repository.repoModel.CheckWritable()
+	if err != nil {
+		return nil, err
+	}
+	if repository.state != InBranch {
+		return nil, errors.New("must merge on branch")
+	}
+
+	var newCommit *models.Commit
+	err = repository.repo.Transaction(ctx, func(repo models.IRepo) error {
+		commitRepo := repo.CommitRepo(repository.repoModel.ID)
+		headHash := repository.branch.CommitHash
+		if !headHash.IsEmpty() {
+			// source already contained in branch, nothing to do
+			merged, err := IsAncestor(ctx, commitRepo, toMergeCommitHash, headHash)
+			if err != nil {
+				return err
+			}
+			if merged || toMergeCommitHash.IsEmpty() {
+				newCommit, err = commitRepo.Commit(ctx, headHash)
+				return err
+			}
+
+			canFastForward, err := IsAncestor(ctx, commitRepo, headHash, toMergeCommitHash)
+			if err != nil {
+				return err
+			}
+			if !canFastForward {
+				return NotFastForwardError{Branch: repository.branch.Name, Head: headHash, Target: toMergeCommitHash}
+			}
+		}
+
+		var err error
+		newCommit, err = commitRepo.Commit(ctx, toMergeCommitHash)
+		if err != nil {
+			return err
+		}
+		err = repository.runHooks(ctx, repo, models.PreMergeHook, headHash, newCommit.TreeHash, newCommit.Metadata)
+		if err != nil {
+			return err
+		}
+
+		err = repo.BranchRepo().UpdateCommitHash(ctx, repository.branch.ID, headHash, newCommit.Hash)
+		if err != nil {
+			return err
+		}
+		return repository.recordEvent(ctx, repo, models.BranchUpdatedEvent, map[string]string{
+			"branch": repository.branch.Name,
+			"old":    headHash.Hex(),
+			"new":    newCommit.Hash.Hex(),
+		})
 	})
 	if err != nil {
 		return nil, err
@@ -783,8 +1514,9 @@ func (repository *WorkRepository) Merge(ctx context.Context, toMergeCommitHash h
 type ArchiveType string
 
 const (
-	ZipArchiveType ArchiveType = "zip"
-	CarArchiveType ArchiveType = "car"
+	ZipArchiveType   ArchiveType = "zip"
+	CarArchiveType   ArchiveType = "car"
+	TarGzArchiveType ArchiveType = "tar.gz"
 )
 
 func (repository *WorkRepository) Archive(ctx context.Context, archiveType ArchiveType) (io.ReadCloser, int64, error) {
@@ -811,6 +1543,9 @@ func (repository *WorkRepository) Archive(ctx context.Context, archiveType Archi
 	case CarArchiveType:
 		tmpFile = path.Join(tmpDir, hash.Hash(rootTree.root.Hash()).Hex()+".car")
 		err = archiver.ArchiveZip(ctx, tmpFile)
+	case TarGzArchiveType:
+		tmpFile = path.Join(tmpDir, hash.Hash(rootTree.root.Hash()).Hex()+".tar.gz")
+		err = archiver.ArchiveTarGz(ctx, tmpFile)
 	default:
 		return nil, 0, fmt.Errorf("unexpect archive type %s", archiveType)
 	}
@@ -828,6 +1563,38 @@ func (repository *WorkRepository) Archive(ctx context.Context, archiveType Archi
 	return fs, st.Size(), nil
 }
 
+// WriteArchive stream content of subPath in current ref to w as zip or tar.gz archive, empty subPath means whole tree.
+// files are placed under a directory named after repository, paths reported by hidden are left out of archive
+func (repository *WorkRepository) WriteArchive(ctx context.Context, w io.Writer, archiveType ArchiveType, subPath string, hidden func(string) bool) error {
+	rootTree, err := repository.RootTree(ctx)
+	if err != nil {
+		return err
+	}
+
+	subTree, err := rootTree.FindTree(ctx, subPath)
+	if err != nil {
+		return err
+	}
+
+	var wk IWalk = NewFileWalk(rootTree.object, subTree)
+	if hidden != nil {
+		wk = NewFilterWalk(wk, subPath, hidden)
+	}
+	reader := func(ctx context.Context, blob *models.Blob, s string) (io.ReadCloser, error) {
+		return repository.ReadBlob(ctx, blob, nil)
+	}
+
+	archiver := NewRepoArchiver(repository.repoModel.Name, wk, reader)
+	switch archiveType {
+	case ZipArchiveType:
+		return archiver.WriteZip(ctx, w)
+	case TarGzArchiveType:
+		return archiver.WriteTarGz(ctx, w)
+	default:
+		return fmt.Errorf("unexpect archive type %s", archiveType)
+	}
+}
+
 func (repository *WorkRepository) setCurState(state WorkRepoState, wip *models.WorkingInProcess, branch *models.Branch, tag *models.Tag, commit *models.Commit) {
 	repository.state = state
 	repository.wip = wip
@@ -836,6 +1603,11 @@ func (repository *WorkRepository) setCurState(state WorkRepoState, wip *models.W
 	repository.commit = commit
 }
 
+// Operator return user operating the repository
+func (repository *WorkRepository) Operator() *models.User {
+	return repository.operator
+}
+
 // CurWip return current wip if in wip, else return nil
 func (repository *WorkRepository) CurWip() *models.WorkingInProcess {
 	return repository.wip
@@ -851,6 +1623,11 @@ func (repository *WorkRepository) CurTag() *models.Branch {
 	return repository.branch
 }
 
+// CurCommit return commit checked out, nil if branch has no commit or state is wip
+func (repository *WorkRepository) CurCommit() *models.Commit {
+	return repository.commit
+}
+
 func (repository *WorkRepository) Reset() {
 	repository.headTree = nil
 	repository.setCurState("", nil, nil, nil, nil)
@@ -961,7 +1738,7 @@ func merge(ctx context.Context,
 		}
 	}
 
-	ancestorWorkTree, err := NewWorkTree(ctx, fileTreeRepo, models.NewRootTreeEntry(bestAncestor.TreeHash))
+	ancestorWorkTree, err := newWorkTree(ctx, fileTreeRepo, repoModel, bestAncestor.TreeHash)
 	if err != nil {
 		return nil, err
 	}
@@ -977,7 +1754,7 @@ func merge(ctx context.Context,
 	}
 
 	//merge diff
-	baseWorkTree, err := NewWorkTree(ctx, fileTreeRepo, models.NewRootTreeEntry(bestAncestor.TreeHash))
+	baseWorkTree, err := newWorkTree(ctx, fileTreeRepo, repoModel, bestAncestor.TreeHash)
 	if err != nil {
 		return nil, err
 	}
@@ -1012,7 +1789,7 @@ func merge(ctx context.Context,
 		CreatedAt:    time.Now(),
 		UpdatedAt:    time.Now(),
 	}
-	hash, err := mergeCommit.GetHash()
+	hash, err := mergeCommit.GetHashWithType(repoModel.HashType)
 	if err != nil {
 		return nil, err
 	}
@@ -352,6 +352,401 @@ func TestWorkRepositoryRevert(t *testing.T) {
 	})
 }
 
+func TestWorkRepositoryStage(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+
+	project, err := makeRepository(ctx, repo, user, "testStage")
+	require.NoError(t, err)
+	testData1 := `
+1|a.txt	|a
+1|b/c.txt	|c
+1|b/e.txt |e1
+`
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	initCommit, err := addChangesToWip(ctx, workRepo, "main", "base commit", testData1)
+	require.NoError(t, err)
+
+	testData2 := `
+3|a.txt	|a1
+2|b/c.txt	|d
+1|b/g.txt |g1
+`
+	err = workRepo.CheckOut(ctx, InWip, "main")
+	require.NoError(t, err)
+	err = workRepo.ChangeInWip(ctx, func(workTree *WorkTree) error {
+		return appendChangeToWorkTree(ctx, workRepo, workTree, testData2)
+	})
+	require.NoError(t, err)
+
+	t.Run("stage", func(t *testing.T) {
+		err = workRepo.Stage(ctx, "a.txt", "b/g.txt")
+		require.NoError(t, err)
+
+		changes, err := workRepo.GetStagedChanges(ctx, "")
+		require.NoError(t, err)
+		require.Equal(t, 2, changes.Num())
+	})
+
+	t.Run("unstage", func(t *testing.T) {
+		err = workRepo.Unstage(ctx, "b")
+		require.NoError(t, err)
+
+		changes, err := workRepo.GetStagedChanges(ctx, "")
+		require.NoError(t, err)
+		require.Equal(t, 1, changes.Num())
+		require.Equal(t, "a.txt", changes.Index(0).Path())
+	})
+
+	t.Run("commit staged", func(t *testing.T) {
+		commit, err := workRepo.CommitChanges(ctx, "commit staged")
+		require.NoError(t, err)
+
+		commitTree, err := NewWorkTree(ctx, repo.FileTreeRepo(project.ID), models.NewRootTreeEntry(initCommit.TreeHash))
+		require.NoError(t, err)
+		changes, err := commitTree.Diff(ctx, commit.TreeHash, "")
+		require.NoError(t, err)
+		require.Equal(t, 1, changes.Num())
+
+		//unstaged changes still in wip
+		wipTree, err := workRepo.RootTree(ctx)
+		require.NoError(t, err)
+		changes, err = wipTree.Diff(ctx, commit.TreeHash, "")
+		require.NoError(t, err)
+		require.Equal(t, 2, changes.Num())
+	})
+
+	t.Run("reset", func(t *testing.T) {
+		err = workRepo.ResetWip(ctx)
+		require.NoError(t, err)
+
+		wip, err := repo.WipRepo().Get(ctx, models.NewGetWipParams().SetID(workRepo.CurWip().ID))
+		require.NoError(t, err)
+		require.Nil(t, wip.StagedTree)
+
+		branch, err := repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(project.ID).SetName("main"))
+		require.NoError(t, err)
+		commit, err := repo.CommitRepo(project.ID).Commit(ctx, branch.CommitHash)
+		require.NoError(t, err)
+		require.Equal(t, commit.TreeHash.Hex(), wip.CurrentTree.Hex())
+	})
+}
+
+func TestWorkRepositoryAmendCommit(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+
+	project, err := makeRepository(ctx, repo, user, "testAmend")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	t.Run("no commit to amend", func(t *testing.T) {
+		err = workRepo.CheckOut(ctx, InBranch, "main")
+		require.NoError(t, err)
+		_, _, err = workRepo.GetOrCreateWip(ctx)
+		require.NoError(t, err)
+		err = workRepo.CheckOut(ctx, InWip, "main")
+		require.NoError(t, err)
+		_, err = workRepo.AmendCommit(ctx, "amend")
+		require.Error(t, err)
+	})
+
+	baseCommit, err := addChangesToWip(ctx, workRepo, "main", "base commit", `
+1|a.txt	|a
+`)
+	require.NoError(t, err)
+	headCommit, err := addChangesToWip(ctx, workRepo, "main", "wrong message", `
+1|b.txt	|b
+`)
+	require.NoError(t, err)
+
+	t.Run("amend message", func(t *testing.T) {
+		err = workRepo.CheckOut(ctx, InWip, "main")
+		require.NoError(t, err)
+
+		commit, err := workRepo.AmendCommit(ctx, "right message")
+		require.NoError(t, err)
+		require.Equal(t, "right message", commit.Message)
+		require.Equal(t, headCommit.TreeHash.Hex(), commit.TreeHash.Hex())
+		require.Len(t, commit.ParentHashes, 1)
+		require.Equal(t, baseCommit.Hash.Hex(), commit.ParentHashes[0].Hex())
+
+		branch, err := repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(project.ID).SetName("main"))
+		require.NoError(t, err)
+		require.Equal(t, commit.Hash.Hex(), branch.CommitHash.Hex())
+	})
+
+	t.Run("amend tree", func(t *testing.T) {
+		err = workRepo.CheckOut(ctx, InWip, "main")
+		require.NoError(t, err)
+		err = workRepo.ChangeInWip(ctx, func(workTree *WorkTree) error {
+			return appendChangeToWorkTree(ctx, workRepo, workTree, `
+1|c.txt	|c
+`)
+		})
+		require.NoError(t, err)
+
+		commit, err := workRepo.AmendCommit(ctx, "")
+		require.NoError(t, err)
+		require.Equal(t, "right message", commit.Message)
+		require.Equal(t, baseCommit.Hash.Hex(), commit.ParentHashes[0].Hex())
+
+		baseTree, err := NewWorkTree(ctx, repo.FileTreeRepo(project.ID), models.NewRootTreeEntry(baseCommit.TreeHash))
+		require.NoError(t, err)
+		changes, err := baseTree.Diff(ctx, commit.TreeHash, "")
+		require.NoError(t, err)
+		require.Equal(t, 2, changes.Num())
+	})
+
+	t.Run("amend protected branch", func(t *testing.T) {
+		err = workRepo.CheckOut(ctx, InBranch, "main")
+		require.NoError(t, err)
+		branchID := workRepo.CurBranch().ID
+		require.NoError(t, repo.BranchRepo().UpdateProtection(ctx, branchID, models.BranchProtection{IsProtected: true, AllowForcePush: true}))
+		defer func() {
+			require.NoError(t, repo.BranchRepo().UpdateProtection(ctx, branchID, models.BranchProtection{}))
+		}()
+
+		err = workRepo.CheckOut(ctx, InWip, "main")
+		require.NoError(t, err)
+		_, err = workRepo.AmendCommit(ctx, "amend protected")
+		require.ErrorIs(t, err, models.ErrBranchProtected)
+	})
+}
+
+func TestWorkRepositoryCommitMetadata(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+
+	project, err := makeRepository(ctx, repo, user, "testCommitMetadata")
+	require.NoError(t, err)
+
+	metadata := map[string]string{"run_id": "42"}
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter).SetCommitMetadata(metadata)
+	commit, err := addChangesToWip(ctx, workRepo, "main", "base commit", `
+1|a.txt	|a
+`)
+	require.NoError(t, err)
+	require.Equal(t, metadata, commit.Metadata)
+
+	savedCommit, err := repo.CommitRepo(project.ID).Commit(ctx, commit.Hash)
+	require.NoError(t, err)
+	require.Equal(t, metadata, savedCommit.Metadata)
+
+	t.Run("amend keep metadata", func(t *testing.T) {
+		workRepo.SetCommitMetadata(nil)
+		err = workRepo.CheckOut(ctx, InWip, "main")
+		require.NoError(t, err)
+		amended, err := workRepo.AmendCommit(ctx, "amended")
+		require.NoError(t, err)
+		require.Equal(t, metadata, amended.Metadata)
+	})
+}
+
+func TestWorkRepositoryCommitRollback(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+
+	project, err := makeRepository(ctx, repo, user, "testCommitRollback")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	baseCommit, err := addChangesToWip(ctx, workRepo, "main", "base commit", `
+1|a.txt	|a
+`)
+	require.NoError(t, err)
+
+	err = workRepo.CheckOut(ctx, InWip, "main")
+	require.NoError(t, err)
+	err = workRepo.ChangeInWip(ctx, func(workTree *WorkTree) error {
+		return appendChangeToWorkTree(ctx, workRepo, workTree, `
+1|b.txt	|b
+`)
+	})
+	require.NoError(t, err)
+
+	// branch moved by others after checkout
+	otherCommit, err := makeCommit(ctx, repo.CommitRepo(project.ID), baseCommit.TreeHash, "other commit", baseCommit.Hash)
+	require.NoError(t, err)
+	err = repo.BranchRepo().UpdateCommitHash(ctx, workRepo.CurBranch().ID, baseCommit.Hash, otherCommit.Hash)
+	require.NoError(t, err)
+
+	eventsBefore, _, err := repo.EventRepo().List(ctx, models.NewListEventParams().SetRepositoryID(project.ID))
+	require.NoError(t, err)
+
+	_, err = workRepo.CommitChanges(ctx, "conflict commit")
+	require.Error(t, err)
+
+	// neither commit, wip nor event is left by failed commit
+	commits, err := repo.CommitRepo(project.ID).List(ctx)
+	require.NoError(t, err)
+	for _, commit := range commits {
+		require.NotEqual(t, "conflict commit", commit.Message)
+	}
+
+	wip, err := repo.WipRepo().Get(ctx, models.NewGetWipParams().SetID(workRepo.CurWip().ID))
+	require.NoError(t, err)
+	require.Equal(t, baseCommit.Hash.Hex(), wip.BaseCommit.Hex())
+
+	eventsAfter, _, err := repo.EventRepo().List(ctx, models.NewListEventParams().SetRepositoryID(project.ID))
+	require.NoError(t, err)
+	require.Len(t, eventsAfter, len(eventsBefore))
+}
+
+func TestWorkRepositoryEvents(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+
+	project, err := makeRepository(ctx, repo, user, "testEvents")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	commit, err := addChangesToWip(ctx, workRepo, "main", "base commit", `
+1|a.txt	|a
+`)
+	require.NoError(t, err)
+
+	err = workRepo.CheckOut(ctx, InBranch, "main")
+	require.NoError(t, err)
+	_, err = workRepo.CreateBranch(ctx, "feat")
+	require.NoError(t, err)
+	_, err = workRepo.CreateTag(ctx, "v1", nil)
+	require.NoError(t, err)
+
+	err = workRepo.CheckOut(ctx, InTag, "v1")
+	require.NoError(t, err)
+	err = workRepo.DeleteTag(ctx)
+	require.NoError(t, err)
+
+	err = workRepo.CheckOut(ctx, InBranch, "feat")
+	require.NoError(t, err)
+	err = workRepo.DeleteBranch(ctx)
+	require.NoError(t, err)
+
+	events, _, err := repo.EventRepo().List(ctx, models.NewListEventParams().SetRepositoryID(project.ID))
+	require.NoError(t, err)
+
+	var types []models.EventType
+	for _, event := range events {
+		require.Equal(t, user.ID, event.ActorID)
+		types = append(types, event.Type)
+	}
+	require.Equal(t, []models.EventType{
+		models.CommitCreatedEvent,
+		models.BranchUpdatedEvent,
+		models.BranchCreatedEvent,
+		models.TagCreatedEvent,
+		models.TagDeletedEvent,
+		models.BranchDeletedEvent,
+	}, types)
+	require.Equal(t, commit.Hash.Hex(), events[0].Payload["commit"])
+	require.Equal(t, "main", events[1].Payload["branch"])
+	require.Equal(t, commit.Hash.Hex(), events[1].Payload["new"])
+	require.Equal(t, "feat", events[2].Payload["branch"])
+	require.Equal(t, "v1", events[3].Payload["tag"])
+}
+
+func TestWorkRepositoryFastForward(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+
+	project, err := makeRepository(ctx, repo, user, "testFastForward")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	baseCommit, err := addChangesToWip(ctx, workRepo, "main", "base commit", `
+1|a.txt	|a
+`)
+	require.NoError(t, err)
+
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+	_, err = workRepo.CreateBranch(ctx, "feat/ff")
+	require.NoError(t, err)
+	_, err = workRepo.CreateBranch(ctx, "feat/diverge")
+	require.NoError(t, err)
+
+	ffCommit, err := addChangesToWip(ctx, workRepo, "feat/ff", "ff commit", `
+1|b.txt	|b
+`)
+	require.NoError(t, err)
+
+	divergeCommit, err := addChangesToWip(ctx, workRepo, "feat/diverge", "diverge commit", `
+1|c.txt	|c
+`)
+	require.NoError(t, err)
+
+	t.Run("fast forward", func(t *testing.T) {
+		err = workRepo.CheckOut(ctx, InBranch, "main")
+		require.NoError(t, err)
+		commit, err := workRepo.FastForward(ctx, ffCommit.Hash)
+		require.NoError(t, err)
+		require.Equal(t, ffCommit.Hash, commit.Hash)
+
+		branch, err := repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(project.ID).SetName("main"))
+		require.NoError(t, err)
+		require.Equal(t, ffCommit.Hash, branch.CommitHash)
+	})
+
+	t.Run("already merged", func(t *testing.T) {
+		err = workRepo.CheckOut(ctx, InBranch, "main")
+		require.NoError(t, err)
+		commit, err := workRepo.FastForward(ctx, baseCommit.Hash)
+		require.NoError(t, err)
+		require.Equal(t, ffCommit.Hash, commit.Hash)
+	})
+
+	t.Run("diverged", func(t *testing.T) {
+		err = workRepo.CheckOut(ctx, InBranch, "main")
+		require.NoError(t, err)
+		_, err := workRepo.FastForward(ctx, divergeCommit.Hash)
+		var notFastForwardErr NotFastForwardError
+		require.ErrorAs(t, err, &notFastForwardErr)
+		require.Equal(t, ffCommit.Hash, notFastForwardErr.Head)
+	})
+}
+
 func TestWorkRepositoryMergeState(t *testing.T) {
 	ctx := context.Background()
 	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
@@ -564,12 +959,18 @@ func TestWorkRepositoryCreateTag(t *testing.T) {
 	err = workRepo.CheckOut(ctx, InBranch, "main")
 	require.NoError(t, err)
 
-	_, err = workRepo.CreateTag(ctx, "v0.0.1", nil)
+	tag, err := workRepo.CreateTag(ctx, "v0.0.1", nil)
 	require.NoError(t, err)
+	require.Equal(t, models.LightweightTag, tag.Type)
 
 	//duplicate tag
 	_, err = workRepo.CreateTag(ctx, "v0.0.1", nil)
 	require.Error(t, err)
+
+	tag, err = workRepo.CreateTag(ctx, "v0.0.2", utils.String("release v0.0.2"))
+	require.NoError(t, err)
+	require.Equal(t, models.AnnotatedTag, tag.Type)
+	require.Equal(t, "release v0.0.2", utils.StringValue(tag.Message))
 }
 func TestWorkRepository_Archive(t *testing.T) {
 	ctx := context.Background()
@@ -623,6 +1024,89 @@ func TestWorkRepository_Archive(t *testing.T) {
 		require.NoError(t, err)
 	}
 }
+func TestProtectedBranch(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+
+	project, err := makeRepository(ctx, repo, user, "testProtected")
+	require.NoError(t, err)
+
+	addFile := func(workRepo *WorkRepository, path string) error {
+		_, err := workRepo.CommitOnBranch(ctx, "add "+path, func(root *WorkTree) error {
+			blob, err := workRepo.WriteBlob(ctx, bytes.NewReader([]byte(path)), int64(len(path)), models.DefaultLeafProperty())
+			if err != nil {
+				return err
+			}
+			return root.AddLeaf(ctx, path, blob)
+		})
+		return err
+	}
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+	require.NoError(t, addFile(workRepo, "a.txt"))
+
+	require.NoError(t, repo.BranchRepo().UpdateProtection(ctx, workRepo.CurBranch().ID, models.BranchProtection{IsProtected: true}))
+	workRepo = NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+	require.ErrorIs(t, addFile(workRepo, "b.txt"), models.ErrBranchProtected)
+	require.ErrorIs(t, workRepo.DeleteBranch(ctx), models.ErrBranchProtected)
+}
+
+func TestArchivedRepository(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+
+	project, err := makeRepository(ctx, repo, user, "testArchived")
+	require.NoError(t, err)
+
+	addFile := func(workRepo *WorkRepository, path string) error {
+		_, err := workRepo.CommitOnBranch(ctx, "add "+path, func(root *WorkTree) error {
+			blob, err := workRepo.WriteBlob(ctx, bytes.NewReader([]byte(path)), int64(len(path)), models.DefaultLeafProperty())
+			if err != nil {
+				return err
+			}
+			return root.AddLeaf(ctx, path, blob)
+		})
+		return err
+	}
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+	require.NoError(t, addFile(workRepo, "a.txt"))
+
+	project.Archived = true
+	workRepo = NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+	require.ErrorIs(t, addFile(workRepo, "b.txt"), models.ErrRepositoryArchived)
+	_, err = workRepo.CreateBranch(ctx, "feat/a")
+	require.ErrorIs(t, err, models.ErrRepositoryArchived)
+	_, err = workRepo.CreateTag(ctx, "v1", nil)
+	require.ErrorIs(t, err, models.ErrRepositoryArchived)
+	_, _, err = workRepo.GetOrCreateWip(ctx)
+	require.ErrorIs(t, err, models.ErrRepositoryArchived)
+	require.ErrorIs(t, workRepo.DeleteBranch(ctx), models.ErrRepositoryArchived)
+
+	project.Archived = false
+	workRepo = NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+	require.NoError(t, addFile(workRepo, "b.txt"))
+}
+
 func makeUser(ctx context.Context, userRepo models.IUserRepo, name string) (*models.User, error) {
 	user := &models.User{
 		Name:              name,
@@ -762,3 +1246,40 @@ func appendChangeToWorkTree(ctx context.Context, workRepo *WorkRepository, workT
 	}
 	return nil
 }
+
+func TestWorkRepositoryDeleteBranch(t *testing.T) {
+	ctx := context.Background()
+	closeDB, _, db := testhelper.SetupDatabase(ctx, t)
+	defer closeDB()
+
+	repo := models.NewRepo(db)
+	adapter := mem.New(ctx)
+
+	user, err := makeUser(ctx, repo.UserRepo(), "admin")
+	require.NoError(t, err)
+
+	project, err := makeRepository(ctx, repo, user, "testDeleteBranch")
+	require.NoError(t, err)
+
+	workRepo := NewWorkRepositoryFromAdapter(ctx, user, project, repo, adapter)
+	_, err = addChangesToWip(ctx, workRepo, "main", "base commit", `
+1|a.txt	|a
+`)
+	require.NoError(t, err)
+
+	t.Run("HEAD branch", func(t *testing.T) {
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+		require.ErrorIs(t, workRepo.DeleteBranch(ctx), models.ErrBranchProtected)
+	})
+
+	t.Run("other branch", func(t *testing.T) {
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, "main"))
+		_, err := workRepo.CreateBranch(ctx, "feat")
+		require.NoError(t, err)
+		require.NoError(t, workRepo.CheckOut(ctx, InBranch, "feat"))
+		require.NoError(t, workRepo.DeleteBranch(ctx))
+
+		_, err = repo.BranchRepo().Get(ctx, models.NewGetBranchParams().SetRepositoryID(project.ID).SetName("feat"))
+		require.ErrorIs(t, err, models.ErrNotFound)
+	})
+}
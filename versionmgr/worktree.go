@@ -61,6 +61,8 @@ func (objectName FullObject) Node() *models.FileTree {
 type WorkTree struct {
 	object models.IFileTreeRepo
 	root   *TreeNode
+	// hashType algorithm to calculate hash of new tree nodes
+	hashType hash.HashType
 }
 
 func NewWorkTree(ctx context.Context, object models.IFileTreeRepo, root models.TreeEntry) (*WorkTree, error) {
@@ -74,6 +76,12 @@ func NewWorkTree(ctx context.Context, object models.IFileTreeRepo, root models.T
 	}, nil
 }
 
+// SetHashType set algorithm used to calculate hash of tree nodes created by this worktree, default is md5
+func (workTree *WorkTree) SetHashType(hashType hash.HashType) *WorkTree {
+	workTree.hashType = hashType
+	return workTree
+}
+
 func (workTree *WorkTree) Root() *TreeNode {
 	return workTree.root
 }
@@ -94,7 +102,7 @@ func (workTree *WorkTree) AppendDirectEntry(ctx context.Context, treeEntry model
 
 	subObjects := models.SortSubObjects(append(workTree.root.SubObjects(), treeEntry))
 
-	newTree, err := models.NewTreeNode(models.Property{Mode: filemode.Dir}, workTree.RepositoryID(), subObjects...)
+	newTree, err := models.NewTreeNodeWithHashType(workTree.hashType, models.Property{Mode: filemode.Dir}, workTree.RepositoryID(), subObjects...)
 	if err != nil {
 		return nil, err
 	}
@@ -119,7 +127,7 @@ func (workTree *WorkTree) DeleteDirectEntry(ctx context.Context, name string) (*
 		return nil, true, nil
 	}
 
-	newTree, err := models.NewTreeNode(workTree.root.Properties(), workTree.RepositoryID(), subObjects...)
+	newTree, err := models.NewTreeNodeWithHashType(workTree.hashType, workTree.root.Properties(), workTree.RepositoryID(), subObjects...)
 	if err != nil {
 		return nil, false, err
 	}
@@ -147,7 +155,7 @@ func (workTree *WorkTree) ReplaceSubTreeEntry(ctx context.Context, treeEntry mod
 	copy(subObjects, workTree.root.SubObjects())
 	subObjects[index] = treeEntry
 
-	newTree, err := models.NewTreeNode(workTree.Root().Properties(), workTree.RepositoryID(), subObjects...)
+	newTree, err := models.NewTreeNodeWithHashType(workTree.hashType, workTree.Root().Properties(), workTree.RepositoryID(), subObjects...)
 	if err != nil {
 		return nil, err
 	}
@@ -217,11 +225,8 @@ func (workTree *WorkTree) AddLeaf(ctx context.Context, fullPath string, blob *mo
 		return ErrEntryExit
 	}
 
-	_, err = workTree.object.Insert(ctx, blob.FileTree())
-	if err != nil {
-		return err
-	}
-
+	// blob and directories of missing path are new objects, insert them together
+	newObjects := []models.FileTree{*blob.FileTree()}
 	slices.Reverse(missingPath)
 	var lastEntry models.TreeEntry
 	for index, path := range missingPath {
@@ -229,10 +234,6 @@ func (workTree *WorkTree) AddLeaf(ctx context.Context, fullPath string, blob *mo
 			return fmt.Errorf("name is empty")
 		}
 		if index == 0 {
-			_, err = workTree.object.Insert(ctx, blob.FileTree())
-			if err != nil {
-				return err
-			}
 			lastEntry = models.TreeEntry{
 				Name:  path,
 				IsDir: false,
@@ -241,20 +242,21 @@ func (workTree *WorkTree) AddLeaf(ctx context.Context, fullPath string, blob *mo
 			continue
 		}
 
-		newTree, err := models.NewTreeNode(models.DefaultDirProperty(), workTree.RepositoryID(), lastEntry)
-		if err != nil {
-			return err
-		}
-		_, err = workTree.object.Insert(ctx, newTree.FileTree())
+		newTree, err := models.NewTreeNodeWithHashType(workTree.hashType, models.DefaultDirProperty(), workTree.RepositoryID(), lastEntry)
 		if err != nil {
 			return err
 		}
+		newObjects = append(newObjects, *newTree.FileTree())
 		lastEntry = models.TreeEntry{
 			Name:  path,
 			IsDir: true,
 			Hash:  newTree.Hash,
 		}
 	}
+	_, err = workTree.object.BatchInsert(ctx, newObjects)
+	if err != nil {
+		return err
+	}
 
 	slices.Reverse(existNode)
 	existNode = append(existNode, FullObject{
@@ -267,6 +269,7 @@ func (workTree *WorkTree) AddLeaf(ctx context.Context, fullPath string, blob *mo
 		if err != nil {
 			return err
 		}
+		newWorkTree.hashType = workTree.hashType
 		var newNode *models.TreeNode
 		if index == 0 { //insert new node
 			newNode, err = newWorkTree.AppendDirectEntry(ctx, lastEntry)
@@ -325,6 +328,7 @@ func (workTree *WorkTree) ReplaceLeaf(ctx context.Context, fullPath string, blob
 		if err != nil {
 			return err
 		}
+		subWorkTree.hashType = workTree.hashType
 		newNode, err = subWorkTree.ReplaceSubTreeEntry(ctx, lastEntry)
 		if err != nil {
 			return err
@@ -376,6 +380,7 @@ func (workTree *WorkTree) RemoveEntry(ctx context.Context, fullPath string) erro
 		if err != nil {
 			return err
 		}
+		subWorkTree.hashType = workTree.hashType
 		if index == 0 || lastEntry.Hash.IsEmpty() {
 			var isEmpty bool
 			newNode, isEmpty, err = subWorkTree.DeleteDirectEntry(ctx, lastEntry.Name)
@@ -524,7 +529,35 @@ func (workTree *WorkTree) FindBlob(ctx context.Context, fullPath string) (*model
 	return lastNode.Node().Blob(), lastNode.Entry().Name, nil
 }
 
+// FindTree return tree node of directory at fullPath, empty path means root
+func (workTree *WorkTree) FindTree(ctx context.Context, fullPath string) (*TreeNode, error) {
+	fullPath = CleanPath(fullPath)
+	if len(fullPath) == 0 {
+		return workTree.root, nil
+	}
+
+	existNode, missingPath, err := workTree.findNodeByPath(ctx, fullPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(missingPath) > 0 {
+		return nil, ErrPathNotFound
+	}
+
+	lastNode := existNode[len(existNode)-1]
+	if lastNode.Node().Type != models.TreeObject {
+		return nil, ErrNotDirectory
+	}
+
+	return NewTreeNode(ctx, lastNode.Entry(), workTree.object)
+}
+
 func (workTree *WorkTree) ApplyOneChange(ctx context.Context, change IChange) error {
+	if merged, ok := change.(*MergedChange); ok {
+		return workTree.applyMergedChange(ctx, merged)
+	}
+
 	action, err := change.Action()
 	if err != nil {
 		return err
@@ -548,6 +581,19 @@ func (workTree *WorkTree) ApplyOneChange(ctx context.Context, change IChange) er
 	return fmt.Errorf("unexpect change action: %s", action)
 }
 
+func (workTree *WorkTree) applyMergedChange(ctx context.Context, change *MergedChange) error {
+	switch {
+	case change.Blob == nil && change.From() == nil:
+		// inserted on both sides and dropped by strategy, nothing to remove from merge base
+		return nil
+	case change.Blob == nil:
+		return workTree.RemoveEntry(ctx, change.From().String())
+	case change.From() == nil:
+		return workTree.AddLeaf(ctx, change.Path(), change.Blob)
+	}
+	return workTree.ReplaceLeaf(ctx, change.Path(), change.Blob)
+}
+
 func (workTree *WorkTree) Diff(ctx context.Context, rootTreeHash hash.Hash, prefix string) (*Changes, error) {
 	toNode, err := NewTreeNode(ctx, models.NewRootTreeEntry(rootTreeHash), workTree.object)
 	if err != nil {